@@ -0,0 +1,189 @@
+// Package admin implements the session administration API
+package admin
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/auth/pages"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/session"
+	"go.uber.org/zap"
+)
+
+// Handler serves session listing and revocation endpoints
+type Handler struct {
+	store  session.Store
+	logger *zap.Logger
+}
+
+// NewHandler creates a new admin API handler
+func NewHandler(store session.Store, logger *zap.Logger) *Handler {
+	return &Handler{
+		store:  store,
+		logger: logger,
+	}
+}
+
+// Register adds the admin routes to a router group
+func (h *Handler) Register(routes gin.IRoutes) {
+	routes.GET("/sessions", h.ListSessions)
+	routes.DELETE("/sessions/:handle", h.DeleteSession)
+	routes.GET("/users/:user_id/sessions", h.ListUserSessions)
+	routes.DELETE("/users/:user_id/sessions", h.DeleteUserSessions)
+}
+
+// RequireGroups only lets through users that belong to one of the groups
+func RequireGroups(groups []string) gin.HandlerFunc {
+	return RequireGroupsWithPages(groups, pages.Default())
+}
+
+// RequireGroupsWithPages is RequireGroups showing browsers the access denied
+// page from p
+func RequireGroupsWithPages(groups []string, p *pages.Pages) gin.HandlerFunc {
+	allowed := make(map[string]bool, len(groups))
+	for _, group := range groups {
+		allowed[group] = true
+	}
+
+	return func(c *gin.Context) {
+		userGroups, _ := c.Get("user_groups")
+		if groups, ok := userGroups.([]string); ok {
+			for _, group := range groups {
+				if allowed[group] {
+					c.Next()
+					return
+				}
+			}
+		}
+
+		p.Respond(c, http.StatusForbidden, pages.AccessDenied, "Insufficient permissions", nil)
+	}
+}
+
+// ListSessions returns a page of all sessions
+func (h *Handler) ListSessions(c *gin.Context) {
+	limit := 0
+	if value := c.Query("limit"); value != "" {
+		var err error
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "limit must be a positive integer",
+			})
+			return
+		}
+	}
+
+	page, err := h.store.ListSessions(c.Request.Context(), c.Query("cursor"), limit)
+	if errors.Is(err, session.ErrInvalidCursor) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid cursor",
+		})
+		return
+	}
+	if err != nil {
+		h.logger.Error("Failed to list sessions", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to list sessions",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+// ListUserSessions returns the sessions of one user
+func (h *Handler) ListUserSessions(c *gin.Context) {
+	userID := c.Param("user_id")
+
+	sessions, err := h.store.ListUserSessions(c.Request.Context(), userID)
+	if err != nil {
+		h.logger.Error("Failed to list user sessions", zap.Error(err), zap.String("user_id", userID))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to list sessions",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"user_id":  userID,
+		"sessions": sessions,
+	})
+}
+
+// DeleteUserSessions revokes every session of one user
+func (h *Handler) DeleteUserSessions(c *gin.Context) {
+	userID := c.Param("user_id")
+
+	deleted, err := h.store.DeleteUserSessions(c.Request.Context(), userID)
+	if err != nil {
+		h.logger.Error("Failed to delete user sessions", zap.Error(err), zap.String("user_id", userID))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to delete sessions",
+		})
+		return
+	}
+
+	h.logger.Info("User sessions revoked",
+		zap.String("user_id", userID),
+		zap.Int("count", deleted),
+		zap.String("admin", c.GetString("user_id")),
+	)
+
+	c.JSON(http.StatusOK, gin.H{
+		"user_id": userID,
+		"deleted": deleted,
+	})
+}
+
+// DeleteSession revokes one session by the handle shown in listings
+func (h *Handler) DeleteSession(c *gin.Context) {
+	handle := c.Param("handle")
+	ctx := c.Request.Context()
+
+	indexer, ok := h.store.(session.Indexer)
+	if !ok {
+		h.logger.Error("Session store cannot look up handles")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to delete session",
+		})
+		return
+	}
+
+	keys, err := indexer.IndexedKeys(ctx, session.HandleIndex(handle))
+	if err != nil {
+		h.logger.Error("Failed to look up session handle", zap.Error(err), zap.String("handle", handle))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to delete session",
+		})
+		return
+	}
+	if len(keys) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Session not found",
+		})
+		return
+	}
+
+	for _, key := range keys {
+		if err := h.store.Delete(ctx, key); err != nil {
+			h.logger.Error("Failed to delete session", zap.Error(err), zap.String("handle", handle))
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to delete session",
+			})
+			return
+		}
+	}
+
+	h.logger.Info("Session revoked",
+		zap.String("handle", handle),
+		zap.String("admin", c.GetString("user_id")),
+	)
+
+	c.JSON(http.StatusOK, gin.H{
+		"handle":  handle,
+		"deleted": len(keys),
+	})
+}
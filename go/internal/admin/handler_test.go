@@ -0,0 +1,190 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/session"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/session/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func newTestRouter(store session.Store, groups []string) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+
+	// Stands in for the auth middleware
+	authenticated := func(c *gin.Context) {
+		c.Set("user_id", "admin-user")
+		c.Set("user_groups", groups)
+	}
+
+	routes := router.Group("/admin", authenticated, RequireGroups([]string{"admins"}))
+	NewHandler(store, zap.NewNop()).Register(routes)
+	return router
+}
+
+func newTestStore(t *testing.T) session.Store {
+	t.Helper()
+
+	store := memory.NewStore(&memory.Config{}, zap.NewNop())
+	t.Cleanup(func() { store.Close() })
+
+	ctx := context.Background()
+	base := time.Now().Add(-time.Hour)
+	for i, s := range []struct{ key, user string }{
+		{"a1", "alice"}, {"a2", "alice"}, {"b1", "bob"},
+	} {
+		_, err := store.Create(ctx, s.key, map[string]string{"id": s.user}, time.Hour)
+		require.NoError(t, err)
+		require.NoError(t, store.SetMetadata(ctx, s.key, &session.Metadata{
+			UserID:    s.user,
+			IP:        "192.0.2.1",
+			Device:    "desktop",
+			CreatedAt: base.Add(time.Duration(i) * time.Minute),
+		}))
+	}
+	return store
+}
+
+func serve(router *gin.Engine, method, target string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestRequireGroups(t *testing.T) {
+	store := newTestStore(t)
+
+	w := serve(newTestRouter(store, []string{"users"}), http.MethodGet, "/admin/sessions")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = serve(newTestRouter(store, nil), http.MethodDelete, "/admin/users/alice/sessions")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	exists, err := store.Exists(context.Background(), "a1")
+	require.NoError(t, err)
+	assert.True(t, exists, "forbidden requests must not revoke sessions")
+
+	w = serve(newTestRouter(store, []string{"users", "admins"}), http.MethodGet, "/admin/sessions")
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestRequireGroupsPage(t *testing.T) {
+	router := newTestRouter(newTestStore(t), []string{"users"})
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/sessions", nil)
+	req.Header.Set("Sec-Fetch-Mode", "navigate")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "text/html")
+	assert.Contains(t, w.Body.String(), "Access denied")
+
+	w = serve(router, http.MethodGet, "/admin/sessions")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.JSONEq(t, `{"error":"Insufficient permissions"}`, w.Body.String())
+}
+
+func TestListSessions(t *testing.T) {
+	router := newTestRouter(newTestStore(t), []string{"admins"})
+
+	w := serve(router, http.MethodGet, "/admin/sessions?limit=2")
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var page session.SessionPage
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+	require.Len(t, page.Sessions, 2)
+	assert.NotEmpty(t, page.Sessions[0].Handle)
+	assert.Equal(t, "alice", page.Sessions[0].UserID)
+	assert.Equal(t, "desktop", page.Sessions[0].Device)
+	require.NotEmpty(t, page.NextCursor)
+	assert.NotContains(t, w.Body.String(), `"key"`)
+	assert.NotContains(t, w.Body.String(), `"a1"`, "listings must not reveal session keys")
+
+	w = serve(router, http.MethodGet, "/admin/sessions?limit=2&cursor="+page.NextCursor)
+	require.Equal(t, http.StatusOK, w.Code)
+	page = session.SessionPage{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+	require.Len(t, page.Sessions, 1)
+	assert.Equal(t, "bob", page.Sessions[0].UserID)
+	assert.Empty(t, page.NextCursor)
+
+	t.Run("Invalid parameters", func(t *testing.T) {
+		for _, target := range []string{
+			"/admin/sessions?limit=0",
+			"/admin/sessions?limit=ten",
+			"/admin/sessions?cursor=%21%21",
+		} {
+			w := serve(router, http.MethodGet, target)
+			assert.Equal(t, http.StatusBadRequest, w.Code, target)
+		}
+	})
+}
+
+func TestUserSessions(t *testing.T) {
+	store := newTestStore(t)
+	router := newTestRouter(store, []string{"admins"})
+
+	w := serve(router, http.MethodGet, "/admin/users/alice/sessions")
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var listing struct {
+		UserID   string                 `json:"user_id"`
+		Sessions []*session.SessionInfo `json:"sessions"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &listing))
+	assert.Equal(t, "alice", listing.UserID)
+	require.Len(t, listing.Sessions, 2)
+	assert.Equal(t, "192.0.2.1", listing.Sessions[0].IP)
+
+	w = serve(router, http.MethodDelete, "/admin/users/alice/sessions")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"user_id":"alice","deleted":2}`, w.Body.String())
+
+	for key, want := range map[string]bool{"a1": false, "a2": false, "b1": true} {
+		exists, err := store.Exists(context.Background(), key)
+		require.NoError(t, err)
+		assert.Equal(t, want, exists, key)
+	}
+
+	w = serve(router, http.MethodGet, "/admin/users/alice/sessions")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"user_id":"alice","sessions":[]}`, w.Body.String())
+}
+
+func TestDeleteSession(t *testing.T) {
+	store := newTestStore(t)
+	router := newTestRouter(store, []string{"admins"})
+
+	sessions, err := store.ListUserSessions(context.Background(), "bob")
+	require.NoError(t, err)
+	require.Len(t, sessions, 1)
+	handle := sessions[0].Handle
+
+	w := serve(router, http.MethodDelete, "/admin/sessions/"+handle)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"handle":"`+handle+`","deleted":1}`, w.Body.String())
+
+	for key, want := range map[string]bool{"a1": true, "a2": true, "b1": false} {
+		exists, err := store.Exists(context.Background(), key)
+		require.NoError(t, err)
+		assert.Equal(t, want, exists, key)
+	}
+
+	w = serve(router, http.MethodDelete, "/admin/sessions/"+handle)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// A session key is not a handle
+	w = serve(router, http.MethodDelete, "/admin/sessions/a1")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
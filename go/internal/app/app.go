@@ -11,13 +11,19 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/admin"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/auth/assertion"
 	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/auth/bypass"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/auth/identity"
 	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/auth/oidc"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/auth/pages"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/auth/tokenexchange"
 	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/config"
 	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/metrics"
 	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/middleware"
 	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/proxy"
 	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/session"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/session/cookiestore"
 	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/server"
 	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/tracing"
 	"github.com/sh03m2a5h/mcp-oidc-proxy-go/pkg/version"
@@ -32,6 +38,9 @@ type App struct {
 	server         *server.Server
 	proxy          *proxy.Proxy
 	oidcHandler    *oidc.Handler
+	propagator     *identity.Propagator
+	pages          *pages.Pages
+	assertion      *assertion.Issuer
 	sessionStore   session.Store
 	tracingShutdown func(context.Context) error
 }
@@ -67,6 +76,12 @@ func New(configPath string) (*App, error) {
 		return nil, fmt.Errorf("failed to create session store: %w", err)
 	}
 
+	// Load the pages shown to browsers
+	authPages, err := pages.New(&cfg.Auth.Pages)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load auth pages: %w", err)
+	}
+
 	// Create OIDC handler only if not in bypass mode
 	var oidcHandler *oidc.Handler
 	if cfg.Auth.Mode != "bypass" {
@@ -74,6 +89,23 @@ func New(configPath string) (*App, error) {
 		if err != nil {
 			return nil, fmt.Errorf("failed to create OIDC handler: %w", err)
 		}
+		oidcHandler.SetPages(authPages)
+	}
+
+	// Create identity header propagator shared by the auth middlewares
+	propagator, err := identity.NewPropagator(&cfg.Auth.Headers)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create identity header propagator: %w", err)
+	}
+
+	// Create identity assertion issuer (audience defaults to the upstream URL)
+	var assertionIssuer *assertion.Issuer
+	if cfg.Auth.Assertion.Enabled {
+		targetURL := fmt.Sprintf("%s://%s:%d", cfg.Proxy.TargetScheme, cfg.Proxy.TargetHost, cfg.Proxy.TargetPort)
+		assertionIssuer, err = assertion.NewIssuer(&cfg.Auth.Assertion, targetURL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create identity assertion issuer: %w", err)
+		}
 	}
 
 	// Create reverse proxy
@@ -84,6 +116,17 @@ func New(configPath string) (*App, error) {
 		Retry:          proxy.RetryConfig(cfg.Proxy.Retry),
 		CircuitBreaker: proxy.CircuitBreakerConfig(cfg.Proxy.CircuitBreaker),
 		Headers:        &cfg.Auth.Headers,
+		Assertion:      assertionIssuer,
+	}
+	if oidcHandler != nil {
+		proxyConfig.ClaimMapper = oidcHandler.ClaimMapper()
+
+		if len(cfg.OIDC.TokenExchange.Routes) > 0 {
+			proxyConfig.TokenExchange, err = tokenexchange.NewExchanger(&cfg.OIDC.TokenExchange, oidcHandler.TokenEndpoint(), cfg.OIDC.ClientID, cfg.OIDC.ClientSecret)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create token exchanger: %w", err)
+			}
+		}
 	}
 	reverseProxy, err := proxy.New(proxyConfig, logger)
 	if err != nil {
@@ -99,6 +142,9 @@ func New(configPath string) (*App, error) {
 		server:          httpServer,
 		proxy:           reverseProxy,
 		oidcHandler:     oidcHandler,
+		propagator:      propagator,
+		pages:           authPages,
+		assertion:       assertionIssuer,
 		sessionStore:    sessionStore,
 		tracingShutdown: tracingShutdown,
 	}
@@ -114,7 +160,21 @@ func (a *App) setupRoutes() {
 	router := a.server.Router()
 
 	// Apply security headers (first for all responses)
-	router.Use(middleware.SecurityHeadersMiddleware())
+	securityHeaders := middleware.NewSecurityHeaders(&a.config.Security.Headers, a.config.Server.TLS.Enabled)
+	if a.config.Auth.Mode != "bypass" {
+		// The logout pages set their own policies: the front-channel logout
+		// page loads other relying parties in frames and the provider frames
+		// the front-channel logout response
+		defaultPolicy := middleware.DefaultSecurityHeaders[middleware.HeaderContentSecurityPolicy]
+		securityHeaders.Route("/logout", middleware.HeaderRule{
+			Name: middleware.HeaderContentSecurityPolicy, Value: defaultPolicy, Action: middleware.HeaderPassthrough,
+		})
+		securityHeaders.Route(a.oidcHandler.FrontChannelLogoutPath(),
+			middleware.HeaderRule{Name: middleware.HeaderContentSecurityPolicy, Value: defaultPolicy, Action: middleware.HeaderPassthrough},
+			middleware.HeaderRule{Name: middleware.HeaderXFrameOptions, Action: middleware.HeaderRemove},
+		)
+	}
+	router.Use(securityHeaders.Middleware())
 
 	// Apply tracing middleware (capture everything)
 	if a.config.Tracing.Enabled {
@@ -128,6 +188,17 @@ func (a *App) setupRoutes() {
 	router.Use(middleware.StructuredLoggingMiddleware(a.logger))
 	router.Use(middleware.RequestContextMiddleware())
 
+	// Answer CORS preflights before authentication, which browsers send
+	// without credentials
+	if a.config.Security.CORS.Enabled {
+		router.Use(middleware.NewCORS(&a.config.Security.CORS).Middleware())
+	}
+
+	// The cookie session store reads and writes sessions through the request
+	if a.config.Session.Store == "cookie" {
+		router.Use(cookiestore.Middleware())
+	}
+
 	// Health check endpoint (public)
 	router.GET("/health", a.healthHandler)
 	
@@ -139,26 +210,74 @@ func (a *App) setupRoutes() {
 		router.GET(a.config.Metrics.Path, gin.WrapH(promhttp.Handler()))
 	}
 
+	// Identity assertion verification keys (public)
+	if a.assertion != nil {
+		router.GET(a.config.Auth.Assertion.JWKSPath, gin.WrapH(a.assertion.JWKSHandler()))
+	}
+
 	// Setup auth based on mode
 	var authMiddleware gin.HandlerFunc
+	// csrfMiddleware rejects cross-site state-changing requests to the
+	// proxy's own endpoints; bypass mode has no sessions to protect
+	csrfMiddleware := gin.HandlerFunc(func(c *gin.Context) { c.Next() })
+	// stepUpMiddleware requires a stronger or more recent login for the
+	// paths and MCP tools of the step-up rules
+	stepUpMiddleware := gin.HandlerFunc(func(c *gin.Context) { c.Next() })
 	
 	if a.config.Auth.Mode == "bypass" {
 		// Bypass mode - no login/logout routes needed
-		authMiddleware = bypass.AuthMiddleware(a.logger, &a.config.Auth.Headers)
+		authMiddleware = bypass.AuthMiddlewareWithPropagator(a.logger, a.propagator)
 	} else {
 		// OIDC mode - setup authentication routes
-		router.GET("/login", a.oidcHandler.Authorize)
+		csrfMiddleware = a.oidcHandler.CSRFMiddleware()
+		stepUpMiddleware = a.oidcHandler.StepUpMiddleware(a.config.Auth.Realm)
+		router.GET(oidc.LoginPath, a.oidcHandler.Authorize)
 		router.GET("/callback", a.oidcHandler.Callback)
-		router.POST("/logout", a.oidcHandler.Logout)
+		router.POST("/logout", csrfMiddleware, a.oidcHandler.Logout)
+		router.GET(a.oidcHandler.LogoutCallbackPath(), a.oidcHandler.LogoutCallback)
+		router.GET(a.oidcHandler.FrontChannelLogoutPath(), a.oidcHandler.FrontChannelLogout)
+		router.POST("/backchannel-logout", a.oidcHandler.BackChannelLogout)
 		
-		authMiddleware = oidc.AuthMiddleware(a.sessionStore, a.logger, []string{"/health", "/login", "/callback", a.config.Metrics.Path})
+		authMiddleware = oidc.AuthMiddlewareWithConfig(a.sessionStore, a.logger, &oidc.MiddlewareConfig{
+			ExcludePaths: []string{
+				"/health", oidc.LoginPath, "/callback", "/backchannel-logout",
+				a.oidcHandler.LogoutCallbackPath(), a.oidcHandler.FrontChannelLogoutPath(),
+				a.config.Metrics.Path,
+			},
+			// Device credentials, and JWT access tokens when oidc.bearer is enabled
+			Bearer:       a.oidcHandler,
+			Propagator:   a.propagator,
+			Lifetime:     a.oidcHandler.Lifetime(),
+			Refresher:    a.oidcHandler,
+			Rotation:     a.oidcHandler.Rotation(),
+			Cookies:      a.oidcHandler.Cookies(),
+			Codec:        a.oidcHandler.Codec(),
+			LoginPath:    oidc.LoginPath,
+			Realm:        a.config.Auth.Realm,
+			Pages:        a.pages,
+		})
+
+		// Device authorization grant: devices start and poll without a
+		// session, the user approves them after logging in
+		if a.config.OIDC.Device.Enabled {
+			router.POST(oidc.DevicePath, a.oidcHandler.DeviceAuthorization)
+			router.POST(oidc.DeviceTokenPath, a.oidcHandler.DeviceToken)
+			router.GET(oidc.DeviceVerifyPath, authMiddleware, a.oidcHandler.DeviceVerify)
+			router.POST(oidc.DeviceVerifyPath, authMiddleware, csrfMiddleware, a.oidcHandler.DeviceApprove)
+		}
 	}
-	
+
 	// Session management route (with auth)
 	router.GET("/session", authMiddleware, a.sessionHandler)
+
+	// Session administration API (with auth, restricted to admin groups)
+	if a.config.Admin.Enabled {
+		adminRoutes := router.Group(a.config.Admin.PathPrefix, authMiddleware, csrfMiddleware, stepUpMiddleware, admin.RequireGroupsWithPages(a.config.Admin.RequiredGroups, a.pages))
+		admin.NewHandler(a.sessionStore, a.logger).Register(adminRoutes)
+	}
 	
 	// Proxy all other requests to the target (with auth)
-	router.NoRoute(authMiddleware, gin.WrapH(a.proxy))
+	router.NoRoute(authMiddleware, stepUpMiddleware, gin.WrapH(a.proxy))
 }
 
 // Run starts the application
@@ -252,10 +371,8 @@ func (a *App) healthHandler(c *gin.Context) {
 		sessionHealth["error"] = err.Error()
 		overallHealthy = false
 	} else {
-		if s, ok := stats.(*session.Stats); ok {
-			sessionHealth["active_sessions"] = s.ActiveSessions
-			sessionHealth["store_type"] = s.StoreType
-		}
+		sessionHealth["active_sessions"] = stats.ActiveSessions
+		sessionHealth["store_type"] = stats.Store
 	}
 	health["checks"].(gin.H)["session_store"] = sessionHealth
 	
@@ -276,12 +393,20 @@ func (a *App) sessionHandler(c *gin.Context) {
 	userEmail := c.GetString("user_email")
 	userName := c.GetString("user_name")
 
-	c.JSON(http.StatusOK, gin.H{
+	response := gin.H{
 		"user_id":    userID,
 		"user_email": userEmail,
 		"user_name":  userName,
 		"authenticated": userID != "",
-	})
+	}
+	// Pages posting to the proxy's endpoints send this token back in the
+	// X-CSRF-Token header or csrf_token form field
+	if a.oidcHandler != nil {
+		if userSession := oidc.GetSessionFromContext(c.Request.Context()); userSession != nil {
+			response["csrf_token"] = a.oidcHandler.CSRF().Token(userSession)
+		}
+	}
+	c.JSON(http.StatusOK, response)
 }
 
 // versionHandler handles version info requests
@@ -0,0 +1,212 @@
+package assertion
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/auth/oidc"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/config"
+)
+
+// MCPSessionHeader is the Streamable HTTP transport session header
+const MCPSessionHeader = "Mcp-Session-Id"
+
+// Claims are the private claims of an identity assertion
+type Claims struct {
+	Email        string   `json:"email,omitempty"`
+	Name         string   `json:"name,omitempty"`
+	Groups       []string `json:"groups,omitempty"`
+	Roles        []string `json:"roles,omitempty"`
+	Provider     string   `json:"idp,omitempty"`
+	MCPSessionID string   `json:"mcp_session_id,omitempty"`
+}
+
+// Issuer mints short-lived identity assertion JWTs for the upstream
+type Issuer struct {
+	keyring  *Keyring
+	header   string
+	issuer   string
+	audience string
+	ttl      time.Duration
+	now      func() time.Time
+
+	cacheMu sync.Mutex
+	cache   map[string]cachedAssertion
+}
+
+// cachedAssertion is a minted assertion reused until half its lifetime has passed
+type cachedAssertion struct {
+	token    string
+	renewAt  time.Time
+	expireAt time.Time
+}
+
+// NewIssuer creates an assertion issuer. The audience defaults to the
+// configured audience, falling back to defaultAudience (the upstream URL).
+func NewIssuer(cfg *config.AssertionConfig, defaultAudience string) (*Issuer, error) {
+	var keyring *Keyring
+	var err error
+	if len(cfg.KeyFiles) > 0 {
+		keyring, err = LoadKeyFiles(cfg.KeyFiles)
+	} else {
+		keyring, err = NewGeneratedKeyring(cfg.Algorithm, cfg.RotationInterval, cfg.TTL+RetentionSkew)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create assertion keyring: %w", err)
+	}
+
+	return NewIssuerWithKeyring(cfg, defaultAudience, keyring), nil
+}
+
+// NewIssuerWithKeyring creates an assertion issuer signing with the given keyring
+func NewIssuerWithKeyring(cfg *config.AssertionConfig, defaultAudience string, keyring *Keyring) *Issuer {
+	audience := cfg.Audience
+	if audience == "" {
+		audience = defaultAudience
+	}
+
+	is := &Issuer{
+		keyring:  keyring,
+		header:   http.CanonicalHeaderKey(cfg.Header),
+		issuer:   cfg.Issuer,
+		audience: audience,
+		ttl:      cfg.TTL,
+		now:      time.Now,
+	}
+	if cfg.CachePerSession {
+		is.cache = make(map[string]cachedAssertion)
+	}
+	return is
+}
+
+// Header returns the header name carrying the assertion
+func (is *Issuer) Header() string {
+	return is.header
+}
+
+// Audience returns the "aud" claim of minted assertions
+func (is *Issuer) Audience() string {
+	return is.audience
+}
+
+// Keyring returns the issuer's keyring
+func (is *Issuer) Keyring() *Keyring {
+	return is.keyring
+}
+
+// Mint returns a signed assertion for the session and MCP session ID
+func (is *Issuer) Mint(sess *oidc.UserSession, mcpSessionID string) (string, error) {
+	now := is.now()
+
+	var cacheKey string
+	if is.cache != nil {
+		cacheKey = sess.ID + "\x00" + strconv.FormatInt(sess.ExpiresAt.UnixNano(), 10) + "\x00" + mcpSessionID
+
+		is.cacheMu.Lock()
+		cached, ok := is.cache[cacheKey]
+		is.cacheMu.Unlock()
+		if ok && now.Before(cached.renewAt) {
+			return cached.token, nil
+		}
+	}
+
+	key, err := is.keyring.signer()
+	if err != nil {
+		return "", err
+	}
+
+	signer, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: key.algorithm, Key: key.jwk},
+		(&jose.SignerOptions{}).WithType("JWT"),
+	)
+	if err != nil {
+		return "", fmt.Errorf("failed to create signer: %w", err)
+	}
+
+	jti, err := randomID()
+	if err != nil {
+		return "", err
+	}
+
+	subject := sess.Subject
+	if subject == "" {
+		subject = sess.ID
+	}
+	expiry := now.Add(is.ttl)
+
+	token, err := jwt.Signed(signer).
+		Claims(jwt.Claims{
+			Issuer:    is.issuer,
+			Subject:   subject,
+			Audience:  jwt.Audience{is.audience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			Expiry:    jwt.NewNumericDate(expiry),
+			ID:        jti,
+		}).
+		Claims(Claims{
+			Email:        sess.Email,
+			Name:         sess.Name,
+			Groups:       sess.Groups,
+			Roles:        sess.Roles,
+			Provider:     sess.Provider,
+			MCPSessionID: mcpSessionID,
+		}).
+		Serialize()
+	if err != nil {
+		return "", fmt.Errorf("failed to sign assertion: %w", err)
+	}
+
+	if is.cache != nil {
+		is.cacheMu.Lock()
+		is.pruneCache(now)
+		is.cache[cacheKey] = cachedAssertion{
+			token:    token,
+			renewAt:  now.Add(is.ttl / 2),
+			expireAt: expiry,
+		}
+		is.cacheMu.Unlock()
+	}
+
+	return token, nil
+}
+
+// pruneCache removes expired assertions; the caller holds cacheMu
+func (is *Issuer) pruneCache(now time.Time) {
+	for key, cached := range is.cache {
+		if !now.Before(cached.expireAt) {
+			delete(is.cache, key)
+		}
+	}
+}
+
+// JWKSHandler serves the public signing keys as a JSON Web Key Set
+func (is *Issuer) JWKSHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := json.Marshal(is.keyring.JWKS())
+		if err != nil {
+			http.Error(w, "Failed to encode key set", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/jwk-set+json")
+		w.Header().Set("Cache-Control", "public, max-age=300")
+		w.Write(body)
+	})
+}
+
+// randomID generates a random assertion ID
+func randomID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate assertion ID: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,277 @@
+package assertion
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/auth/oidc"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testAssertionConfig() *config.AssertionConfig {
+	return &config.AssertionConfig{
+		Enabled:          true,
+		Header:           "X-Identity-Assertion",
+		Issuer:           "https://proxy.example.com",
+		TTL:              5 * time.Minute,
+		Algorithm:        "ES256",
+		RotationInterval: time.Hour,
+	}
+}
+
+func testSession() *oidc.UserSession {
+	return &oidc.UserSession{
+		ID:        "user123",
+		Subject:   "sub-123",
+		Email:     "user@example.com",
+		Name:      "Test User",
+		Groups:    []string{"dev", "ops"},
+		Roles:     []string{"reader"},
+		Provider:  "keycloak",
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+}
+
+// fetchJWKS retrieves the key set from the issuer's JWKS handler
+func fetchJWKS(t *testing.T, is *Issuer) jose.JSONWebKeySet {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	is.JWKSHandler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/jwk-set+json", w.Header().Get("Content-Type"))
+
+	var set jose.JSONWebKeySet
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &set))
+	return set
+}
+
+// verify checks an assertion against the published key set
+func verify(t *testing.T, is *Issuer, token string) (jwt.Claims, Claims) {
+	t.Helper()
+
+	parsed, err := jwt.ParseSigned(token, []jose.SignatureAlgorithm{jose.RS256, jose.ES256})
+	require.NoError(t, err)
+	require.Len(t, parsed.Headers, 1)
+
+	set := fetchJWKS(t, is)
+	keys := set.Key(parsed.Headers[0].KeyID)
+	require.Len(t, keys, 1, "signing key must be published")
+
+	var std jwt.Claims
+	var custom Claims
+	require.NoError(t, parsed.Claims(keys[0].Key, &std, &custom))
+	return std, custom
+}
+
+func TestIssuer_Mint(t *testing.T) {
+	is, err := NewIssuer(testAssertionConfig(), "http://backend:3000")
+	require.NoError(t, err)
+
+	token, err := is.Mint(testSession(), "mcp-session-1")
+	require.NoError(t, err)
+
+	std, custom := verify(t, is, token)
+	require.NoError(t, std.Validate(jwt.Expected{
+		Issuer:      "https://proxy.example.com",
+		AnyAudience: jwt.Audience{"http://backend:3000"},
+		Time:        time.Now(),
+	}))
+	assert.Equal(t, "sub-123", std.Subject)
+	assert.NotEmpty(t, std.ID)
+	assert.WithinDuration(t, time.Now().Add(5*time.Minute), std.Expiry.Time(), 5*time.Second)
+
+	assert.Equal(t, Claims{
+		Email:        "user@example.com",
+		Name:         "Test User",
+		Groups:       []string{"dev", "ops"},
+		Roles:        []string{"reader"},
+		Provider:     "keycloak",
+		MCPSessionID: "mcp-session-1",
+	}, custom)
+}
+
+func TestIssuer_ConfiguredAudience(t *testing.T) {
+	cfg := testAssertionConfig()
+	cfg.Audience = "urn:mcp:backend"
+	is, err := NewIssuer(cfg, "http://backend:3000")
+	require.NoError(t, err)
+	assert.Equal(t, "urn:mcp:backend", is.Audience())
+
+	token, err := is.Mint(testSession(), "")
+	require.NoError(t, err)
+	std, _ := verify(t, is, token)
+	assert.Equal(t, jwt.Audience{"urn:mcp:backend"}, std.Audience)
+}
+
+func TestIssuer_CachePerSession(t *testing.T) {
+	cfg := testAssertionConfig()
+	cfg.CachePerSession = true
+	is, err := NewIssuer(cfg, "http://backend:3000")
+	require.NoError(t, err)
+
+	now := time.Now()
+	is.now = func() time.Time { return now }
+
+	sess := testSession()
+	first, err := is.Mint(sess, "mcp-1")
+	require.NoError(t, err)
+
+	again, err := is.Mint(sess, "mcp-1")
+	require.NoError(t, err)
+	assert.Equal(t, first, again, "assertion should be reused within the session")
+
+	other, err := is.Mint(sess, "mcp-2")
+	require.NoError(t, err)
+	assert.NotEqual(t, first, other, "different MCP sessions get different assertions")
+
+	now = now.Add(3 * time.Minute)
+	renewed, err := is.Mint(sess, "mcp-1")
+	require.NoError(t, err)
+	assert.NotEqual(t, first, renewed, "assertion should be renewed after half its lifetime")
+}
+
+func TestIssuer_NoCacheMintsPerRequest(t *testing.T) {
+	is, err := NewIssuer(testAssertionConfig(), "http://backend:3000")
+	require.NoError(t, err)
+
+	first, err := is.Mint(testSession(), "mcp-1")
+	require.NoError(t, err)
+	second, err := is.Mint(testSession(), "mcp-1")
+	require.NoError(t, err)
+	assert.NotEqual(t, first, second)
+}
+
+func TestKeyring_Rotation(t *testing.T) {
+	is, err := NewIssuer(testAssertionConfig(), "http://backend:3000")
+	require.NoError(t, err)
+
+	now := time.Now()
+	is.keyring.now = func() time.Time { return now }
+
+	before, err := is.Mint(testSession(), "")
+	require.NoError(t, err)
+	require.Len(t, fetchJWKS(t, is).Keys, 1)
+
+	now = now.Add(time.Hour)
+	after, err := is.Mint(testSession(), "")
+	require.NoError(t, err)
+
+	set := fetchJWKS(t, is)
+	require.Len(t, set.Keys, 2, "previous key stays published after rotation")
+	assert.NotEqual(t, set.Keys[0].KeyID, set.Keys[1].KeyID)
+
+	// Both assertions verify against the published set
+	verify(t, is, before)
+	verify(t, is, after)
+
+	// A second rotation within the retention period keeps both retired keys
+	require.NoError(t, is.keyring.Rotate())
+	assert.Len(t, fetchJWKS(t, is).Keys, 3, "retired keys stay published for the assertion lifetime")
+	verify(t, is, before)
+	verify(t, is, after)
+
+	now = now.Add(testAssertionConfig().TTL + RetentionSkew)
+	assert.Len(t, fetchJWKS(t, is).Keys, 1, "retired keys are dropped once their assertions have expired")
+}
+
+func TestKeyring_ConcurrentRotation(t *testing.T) {
+	is, err := NewIssuer(testAssertionConfig(), "http://backend:3000")
+	require.NoError(t, err)
+
+	now := time.Now().Add(time.Hour)
+	is.keyring.now = func() time.Time { return now }
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := is.Mint(testSession(), "aud-"+strconv.Itoa(i))
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	assert.Len(t, fetchJWKS(t, is).Keys, 2, "a due key is rotated once")
+}
+
+func TestKeyring_JWKSPublicOnly(t *testing.T) {
+	is, err := NewIssuer(testAssertionConfig(), "http://backend:3000")
+	require.NoError(t, err)
+
+	for _, key := range fetchJWKS(t, is).Keys {
+		assert.True(t, key.IsPublic())
+		assert.Equal(t, "sig", key.Use)
+		assert.Equal(t, "ES256", key.Algorithm)
+	}
+}
+
+func TestLoadKeyFiles(t *testing.T) {
+	dir := t.TempDir()
+
+	writeKey := func(name string) string {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		require.NoError(t, err)
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600))
+		return path
+	}
+
+	active := writeKey("active.pem")
+	next := writeKey("next.pem")
+
+	cfg := testAssertionConfig()
+	cfg.KeyFiles = []string{active, next}
+	is, err := NewIssuer(cfg, "http://backend:3000")
+	require.NoError(t, err)
+
+	assert.Len(t, fetchJWKS(t, is).Keys, 2, "secondary keys are published")
+	assert.Error(t, is.keyring.Rotate(), "static keyrings cannot rotate")
+
+	token, err := is.Mint(testSession(), "")
+	require.NoError(t, err)
+	verify(t, is, token)
+
+	t.Run("Missing file", func(t *testing.T) {
+		_, err := LoadKeyFiles([]string{filepath.Join(dir, "missing.pem")})
+		assert.Error(t, err)
+	})
+
+	t.Run("Invalid PEM", func(t *testing.T) {
+		path := filepath.Join(dir, "invalid.pem")
+		require.NoError(t, os.WriteFile(path, []byte("not a key"), 0600))
+		_, err := LoadKeyFiles([]string{path})
+		assert.Error(t, err)
+	})
+}
+
+func TestNewStaticKeyring_UnsupportedKey(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+
+	_, err = NewStaticKeyring([]crypto.Signer{key})
+	assert.Error(t, err)
+
+	_, err = NewStaticKeyring(nil)
+	assert.Error(t, err)
+}
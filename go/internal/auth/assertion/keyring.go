@@ -0,0 +1,263 @@
+package assertion
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+)
+
+// RetentionSkew is added to the assertion lifetime when deciding how long a
+// rotated-out key stays published, covering verifier clock skew
+const RetentionSkew = time.Minute
+
+// signingKey is a private key with its JOSE metadata
+type signingKey struct {
+	jwk       jose.JSONWebKey
+	algorithm jose.SignatureAlgorithm
+}
+
+// publishedKey is a key that no longer signs but is still published
+type publishedKey struct {
+	key *signingKey
+	// until is when the key is dropped from the JWKS (zero: never)
+	until time.Time
+}
+
+// Keyring holds the active signing key and the previous keys that are still
+// published so that assertions signed before a rotation remain verifiable.
+//
+// Keys loaded from files are static: the first signs and the others are only
+// published, which lets operators roll keys by adding the new key as a
+// secondary, waiting for verifiers to pick up the JWKS, then promoting it.
+// Generated keys are rotated in memory every rotation interval; they are not
+// shared between replicas. A rotated-out key stays published for the
+// retention period, so every assertion it signed can still be verified.
+type Keyring struct {
+	mu        sync.RWMutex
+	current   *signingKey
+	previous  []publishedKey
+	algorithm jose.SignatureAlgorithm
+	interval  time.Duration
+	retention time.Duration
+	rotatedAt time.Time
+	generate  bool
+	now       func() time.Time
+}
+
+// NewStaticKeyring creates a keyring from private keys; the first key signs
+func NewStaticKeyring(keys []crypto.Signer) (*Keyring, error) {
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("at least one key is required")
+	}
+
+	kr := &Keyring{now: time.Now}
+	for i, key := range keys {
+		sk, err := newSigningKey(key)
+		if err != nil {
+			return nil, fmt.Errorf("key %d: %w", i, err)
+		}
+		if i == 0 {
+			kr.current = sk
+			kr.algorithm = sk.algorithm
+		} else {
+			kr.previous = append(kr.previous, publishedKey{key: sk})
+		}
+	}
+	return kr, nil
+}
+
+// NewGeneratedKeyring creates a keyring with an in-memory key that is replaced
+// every interval (0 disables rotation). Replaced keys stay published for
+// retention, which must cover the lifetime of the assertions they signed.
+func NewGeneratedKeyring(algorithm string, interval, retention time.Duration) (*Keyring, error) {
+	kr := &Keyring{
+		algorithm: jose.SignatureAlgorithm(algorithm),
+		interval:  interval,
+		retention: retention,
+		generate:  true,
+		now:       time.Now,
+	}
+	if err := kr.Rotate(); err != nil {
+		return nil, err
+	}
+	return kr, nil
+}
+
+// LoadKeyFiles loads PEM-encoded private keys into a static keyring
+func LoadKeyFiles(paths []string) (*Keyring, error) {
+	keys := make([]crypto.Signer, 0, len(paths))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read key file: %w", err)
+		}
+		key, err := ParsePrivateKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key file %s: %w", path, err)
+		}
+		keys = append(keys, key)
+	}
+	return NewStaticKeyring(keys)
+}
+
+// ParsePrivateKey parses a PEM-encoded PKCS#8, PKCS#1 or SEC 1 private key
+func ParsePrivateKey(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block found")
+	}
+
+	if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("unsupported private key type %T", key)
+		}
+		return signer, nil
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unsupported private key format %q", block.Type)
+}
+
+// Rotate replaces the signing key with a newly generated one. The replaced key
+// stays published for the retention period. Static keyrings cannot be rotated.
+func (kr *Keyring) Rotate() error {
+	if !kr.generate {
+		return fmt.Errorf("static keyring cannot be rotated")
+	}
+
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+	return kr.rotateLocked()
+}
+
+// rotateLocked generates the new signing key and retires the current one;
+// the caller holds mu
+func (kr *Keyring) rotateLocked() error {
+	key, err := generateKey(kr.algorithm)
+	if err != nil {
+		return err
+	}
+	sk, err := newSigningKey(key)
+	if err != nil {
+		return err
+	}
+
+	now := kr.now()
+	kept := kr.previous[:0]
+	for _, pk := range kr.previous {
+		if pk.until.IsZero() || now.Before(pk.until) {
+			kept = append(kept, pk)
+		}
+	}
+	kr.previous = kept
+	if kr.current != nil {
+		kr.previous = append([]publishedKey{{key: kr.current, until: now.Add(kr.retention)}}, kr.previous...)
+	}
+	kr.current = sk
+	kr.rotatedAt = now
+	return nil
+}
+
+// due reports whether the generated key should be rotated; the caller holds mu
+func (kr *Keyring) due() bool {
+	return kr.generate && kr.interval > 0 && kr.now().Sub(kr.rotatedAt) >= kr.interval
+}
+
+// signer returns the active signing key, rotating it first when due
+func (kr *Keyring) signer() (*signingKey, error) {
+	kr.mu.RLock()
+	due := kr.due()
+	current := kr.current
+	kr.mu.RUnlock()
+
+	if !due {
+		return current, nil
+	}
+
+	kr.mu.Lock()
+	defer kr.mu.Unlock()
+	// Another caller may have rotated while the lock was released
+	if kr.due() {
+		if err := kr.rotateLocked(); err != nil {
+			return nil, fmt.Errorf("failed to rotate signing key: %w", err)
+		}
+	}
+	return kr.current, nil
+}
+
+// JWKS returns the public keys of the active key and of the previous keys
+// that are still within their retention period
+func (kr *Keyring) JWKS() jose.JSONWebKeySet {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+
+	now := kr.now()
+	set := jose.JSONWebKeySet{Keys: []jose.JSONWebKey{kr.current.jwk.Public()}}
+	for _, pk := range kr.previous {
+		if pk.until.IsZero() || now.Before(pk.until) {
+			set.Keys = append(set.Keys, pk.key.jwk.Public())
+		}
+	}
+	return set
+}
+
+// newSigningKey derives the key ID and algorithm for a private key
+func newSigningKey(key crypto.Signer) (*signingKey, error) {
+	var algorithm jose.SignatureAlgorithm
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		if k.N.BitLen() < 2048 {
+			return nil, fmt.Errorf("RSA key must be at least 2048 bits")
+		}
+		algorithm = jose.RS256
+	case *ecdsa.PrivateKey:
+		if k.Curve != elliptic.P256() {
+			return nil, fmt.Errorf("ECDSA key must use the P-256 curve")
+		}
+		algorithm = jose.ES256
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", key)
+	}
+
+	jwk := jose.JSONWebKey{
+		Key:       key,
+		Algorithm: string(algorithm),
+		Use:       "sig",
+	}
+	public := jwk.Public()
+	thumbprint, err := public.Thumbprint(crypto.SHA256)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute key thumbprint: %w", err)
+	}
+	jwk.KeyID = base64.RawURLEncoding.EncodeToString(thumbprint)
+
+	return &signingKey{jwk: jwk, algorithm: algorithm}, nil
+}
+
+// generateKey creates a private key for the algorithm
+func generateKey(algorithm jose.SignatureAlgorithm) (crypto.Signer, error) {
+	switch algorithm {
+	case jose.RS256:
+		return rsa.GenerateKey(rand.Reader, 2048)
+	case jose.ES256:
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	default:
+		return nil, fmt.Errorf("unsupported algorithm: %s", algorithm)
+	}
+}
@@ -1,7 +1,11 @@
 package bypass
 
 import (
+	"context"
+
 	"github.com/gin-gonic/gin"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/auth/identity"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/auth/oidc"
 	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/config"
 	"go.uber.org/zap"
 )
@@ -16,30 +20,54 @@ const (
 // AuthMiddleware creates a middleware that bypasses authentication
 func AuthMiddleware(logger *zap.Logger, headerConfig *config.HeadersConfig) gin.HandlerFunc {
 	// Use default header names if not configured
-	userIDHeader := headerConfig.UserID
-	if userIDHeader == "" {
-		userIDHeader = "X-User-ID"
+	headers := *headerConfig
+	if headers.UserID == "" {
+		headers.UserID = identity.DefaultUserIDHeader
+	}
+	if headers.UserEmail == "" {
+		headers.UserEmail = identity.DefaultUserEmailHeader
 	}
-	userEmailHeader := headerConfig.UserEmail
-	if userEmailHeader == "" {
-		userEmailHeader = "X-User-Email"
+	if headers.UserName == "" {
+		headers.UserName = identity.DefaultUserNameHeader
 	}
-	userNameHeader := headerConfig.UserName
-	if userNameHeader == "" {
-		userNameHeader = "X-User-Name"
+
+	propagator, err := identity.NewPropagator(&headers)
+	if err != nil {
+		logger.Error("Invalid identity header signing configuration, headers will not be signed", zap.Error(err))
+		propagator = identity.NewUnsignedPropagator(&headers)
 	}
-	
+
+	return AuthMiddlewareWithPropagator(logger, propagator)
+}
+
+// AuthMiddlewareWithPropagator creates a bypass middleware that injects the
+// mock user through the given identity propagator
+func AuthMiddlewareWithPropagator(logger *zap.Logger, propagator *identity.Propagator) gin.HandlerFunc {
+	mockUser := &identity.Identity{
+		UserID: DefaultUserID,
+		Email:  DefaultUserEmail,
+		Name:   DefaultUserName,
+	}
+
 	return func(c *gin.Context) {
-		// In bypass mode, set mock user headers using configured header names
-		c.Request.Header.Set(userIDHeader, DefaultUserID)
-		c.Request.Header.Set(userEmailHeader, DefaultUserEmail)
-		c.Request.Header.Set(userNameHeader, DefaultUserName)
+		// In bypass mode, replace any client-supplied identity headers with the mock user
+		propagator.Apply(c.Request, mockUser, nil)
 		
 		// Set context values for handlers
 		c.Set("user_id", DefaultUserID)
 		c.Set("user_email", DefaultUserEmail)
 		c.Set("user_name", DefaultUserName)
 		
+		// Expose the mock session so the proxy injects the same identity
+		ctx := context.WithValue(c.Request.Context(), oidc.SessionContextKey{}, &oidc.UserSession{
+			ID:       DefaultUserID,
+			Subject:  DefaultUserID,
+			Email:    DefaultUserEmail,
+			Name:     DefaultUserName,
+			Provider: "bypass",
+		})
+		c.Request = c.Request.WithContext(ctx)
+		
 		logger.Debug("Bypass auth mode - setting mock user headers",
 			zap.String("user_id", DefaultUserID),
 			zap.String("user_email", DefaultUserEmail),
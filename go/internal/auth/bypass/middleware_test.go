@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/gin-gonic/gin"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/auth/oidc"
 	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/config"
 	"github.com/stretchr/testify/assert"
 	"go.uber.org/zap"
@@ -144,4 +145,33 @@ func TestAuthMiddlewareHeaderForwarding(t *testing.T) {
 	assert.Equal(t, "bypass-user", req.Header.Get("X-Custom-ID"))
 	assert.Equal(t, "bypass@example.com", req.Header.Get("X-Custom-Mail"))
 	assert.Equal(t, "Bypass User", req.Header.Get("X-Custom-Name"))
-}
\ No newline at end of file
+}
+func TestAuthMiddlewareStripsSpoofedIdentityHeaders(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logger := zap.NewNop()
+
+	headerConfig := &config.HeadersConfig{
+		UserID:     "X-User-ID",
+		UserEmail:  "X-User-Email",
+		UserName:   "X-User-Name",
+		UserGroups: "X-User-Groups",
+	}
+
+	router := gin.New()
+	router.Use(AuthMiddleware(logger, headerConfig))
+	router.GET("/echo", func(c *gin.Context) {
+		sess := oidc.GetSessionFromContext(c.Request.Context())
+		assert.NotNil(t, sess)
+		c.Status(http.StatusOK)
+	})
+
+	req := httptest.NewRequest("GET", "/echo", nil)
+	req.Header.Set("X-User-ID", "attacker")
+	req.Header.Set("X-User-Groups", "admin")
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, req)
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, []string{DefaultUserID}, req.Header.Values("X-User-ID"))
+	assert.Empty(t, req.Header.Values("X-User-Groups"))
+}
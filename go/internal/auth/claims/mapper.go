@@ -0,0 +1,250 @@
+package claims
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/config"
+)
+
+// Transform types supported in claim mappings
+const (
+	TransformStripPrefix  = "strip_prefix"
+	TransformLowercase    = "lowercase"
+	TransformUppercase    = "uppercase"
+	TransformRegexExtract = "regex_extract"
+	TransformSplit        = "split"
+)
+
+// Default claim paths used when no mapping is configured
+var (
+	DefaultUserIDPaths = []string{"sub"}
+	DefaultEmailPaths  = []string{"email"}
+	DefaultNamePaths   = []string{"name"}
+	DefaultGroupsPaths = []string{"groups"}
+	DefaultRolesPaths  = []string{"roles"}
+)
+
+// Identity holds the user attributes extracted from a claim set
+type Identity struct {
+	Subject string
+	UserID  string
+	Email   string
+	Name    string
+	Groups  []string
+	Roles   []string
+	// GroupsOverage is set when the provider omitted the groups claim because
+	// the user belongs to too many groups (Azure AD "_claim_names")
+	GroupsOverage bool
+}
+
+// Mapper extracts identity attributes from token claims using configured paths
+type Mapper struct {
+	userID *attribute
+	email  *attribute
+	name   *attribute
+	groups *attribute
+	roles  *attribute
+}
+
+// attribute is a compiled claim mapping
+type attribute struct {
+	paths      []Path
+	transforms []transformFunc
+}
+
+// transformFunc rewrites a single value; returning no values drops it
+type transformFunc func(value string) []string
+
+// NewMapper compiles a claim mapper from configuration
+func NewMapper(cfg *config.ClaimsConfig) (*Mapper, error) {
+	if cfg == nil {
+		cfg = &config.ClaimsConfig{}
+	}
+
+	m := &Mapper{}
+	var err error
+	if m.userID, err = compileAttribute("user_id", cfg.UserID, DefaultUserIDPaths); err != nil {
+		return nil, err
+	}
+	if m.email, err = compileAttribute("email", cfg.Email, DefaultEmailPaths); err != nil {
+		return nil, err
+	}
+	if m.name, err = compileAttribute("name", cfg.Name, DefaultNamePaths); err != nil {
+		return nil, err
+	}
+	if m.groups, err = compileAttribute("groups", cfg.Groups, DefaultGroupsPaths); err != nil {
+		return nil, err
+	}
+	if m.roles, err = compileAttribute("roles", cfg.Roles, DefaultRolesPaths); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// defaultMapper is shared by all callers without a configured mapping
+var defaultMapper = func() *Mapper {
+	m, err := NewMapper(&config.ClaimsConfig{})
+	if err != nil {
+		// Default paths are static and always valid
+		panic(err)
+	}
+	return m
+}()
+
+// DefaultMapper returns a mapper reading the standard OIDC claims
+func DefaultMapper() *Mapper {
+	return defaultMapper
+}
+
+// compileAttribute compiles a single attribute mapping
+func compileAttribute(name string, mapping config.ClaimMapping, defaults []string) (*attribute, error) {
+	exprs := mapping.Paths
+	if len(exprs) == 0 {
+		exprs = defaults
+	}
+
+	attr := &attribute{}
+	for _, expr := range exprs {
+		path, err := ParsePath(expr)
+		if err != nil {
+			return nil, fmt.Errorf("claim mapping %s: %w", name, err)
+		}
+		attr.paths = append(attr.paths, path)
+	}
+
+	for _, t := range mapping.Transforms {
+		fn, err := compileTransform(t)
+		if err != nil {
+			return nil, fmt.Errorf("claim mapping %s: %w", name, err)
+		}
+		attr.transforms = append(attr.transforms, fn)
+	}
+
+	return attr, nil
+}
+
+// compileTransform compiles a transform definition
+func compileTransform(t config.ClaimTransform) (transformFunc, error) {
+	switch strings.ToLower(t.Type) {
+	case TransformStripPrefix:
+		prefix := t.Value
+		return func(v string) []string {
+			return []string{strings.TrimPrefix(v, prefix)}
+		}, nil
+	case TransformLowercase:
+		return func(v string) []string {
+			return []string{strings.ToLower(v)}
+		}, nil
+	case TransformUppercase:
+		return func(v string) []string {
+			return []string{strings.ToUpper(v)}
+		}, nil
+	case TransformRegexExtract:
+		re, err := regexp.Compile(t.Value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex %q: %w", t.Value, err)
+		}
+		return func(v string) []string {
+			match := re.FindStringSubmatch(v)
+			if match == nil {
+				return nil
+			}
+			// Use the first capture group if present, otherwise the whole match
+			if len(match) > 1 {
+				return []string{match[1]}
+			}
+			return []string{match[0]}
+		}, nil
+	case TransformSplit:
+		sep := t.Value
+		return func(v string) []string {
+			if sep == "" {
+				return strings.Fields(v)
+			}
+			return strings.Split(v, sep)
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported transform type: %s", t.Type)
+	}
+}
+
+// values evaluates an attribute; the first path yielding values wins
+func (a *attribute) values(claims map[string]interface{}) []string {
+	for _, path := range a.paths {
+		raw := path.Strings(claims)
+		if len(raw) == 0 {
+			continue
+		}
+
+		result := raw
+		for _, fn := range a.transforms {
+			var next []string
+			for _, v := range result {
+				next = append(next, fn(v)...)
+			}
+			result = next
+		}
+
+		// Drop empty values and duplicates while preserving order
+		seen := make(map[string]bool, len(result))
+		out := make([]string, 0, len(result))
+		for _, v := range result {
+			v = strings.TrimSpace(v)
+			if v == "" || seen[v] {
+				continue
+			}
+			seen[v] = true
+			out = append(out, v)
+		}
+		if len(out) > 0 {
+			return out
+		}
+	}
+	return nil
+}
+
+// first returns the first value of an attribute
+func (a *attribute) first(claims map[string]interface{}) string {
+	if values := a.values(claims); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// Map extracts the identity from a claim set
+func (m *Mapper) Map(claims map[string]interface{}) *Identity {
+	if m == nil {
+		m = DefaultMapper()
+	}
+
+	subject, _ := claims["sub"].(string)
+	return &Identity{
+		Subject:       subject,
+		UserID:        m.userID.first(claims),
+		Email:         m.email.first(claims),
+		Name:          m.name.first(claims),
+		Groups:        m.groups.values(claims),
+		Roles:         m.roles.values(claims),
+		GroupsOverage: hasGroupsOverage(claims),
+	}
+}
+
+// Groups extracts only the groups attribute from a claim set
+func (m *Mapper) Groups(claims map[string]interface{}) []string {
+	if m == nil {
+		m = DefaultMapper()
+	}
+	return m.groups.values(claims)
+}
+
+// hasGroupsOverage detects the Azure AD groups overage indicator
+func hasGroupsOverage(claims map[string]interface{}) bool {
+	names, ok := claims["_claim_names"].(map[string]interface{})
+	if !ok {
+		return false
+	}
+	_, ok = names["groups"]
+	return ok
+}
@@ -0,0 +1,232 @@
+package claims
+
+import (
+	"testing"
+
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePath(t *testing.T) {
+	tests := []struct {
+		name        string
+		expr        string
+		expectError bool
+	}{
+		{name: "Simple key", expr: "groups"},
+		{name: "Nested key", expr: "realm_access.roles"},
+		{name: "Quoted key", expr: `["https://example.com/claims"].groups`},
+		{name: "Single quoted key", expr: `resource_access['mcp-client'].roles`},
+		{name: "Array index", expr: "groups[0]"},
+		{name: "Empty", expr: "", expectError: true},
+		{name: "Trailing dot", expr: "groups.", expectError: true},
+		{name: "Unterminated bracket", expr: "groups[0", expectError: true},
+		{name: "Invalid index", expr: "groups[abc]", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, err := ParsePath(tt.expr)
+			if tt.expectError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expr, path.String())
+		})
+	}
+}
+
+func TestPathStrings(t *testing.T) {
+	claims := map[string]interface{}{
+		"sub":    "user123",
+		"groups": []interface{}{"admin", "users"},
+		"realm_access": map[string]interface{}{
+			"roles": []interface{}{"offline_access", "mcp-admin"},
+		},
+		"https://example.com/claims": map[string]interface{}{
+			"department": "engineering",
+		},
+		"memberships": []interface{}{
+			map[string]interface{}{"name": "team-a"},
+			map[string]interface{}{"name": "team-b"},
+		},
+		"employee_number": float64(12345),
+	}
+
+	tests := []struct {
+		expr     string
+		expected []string
+	}{
+		{expr: "sub", expected: []string{"user123"}},
+		{expr: "groups", expected: []string{"admin", "users"}},
+		{expr: "groups[1]", expected: []string{"users"}},
+		{expr: "realm_access.roles", expected: []string{"offline_access", "mcp-admin"}},
+		{expr: `["https://example.com/claims"].department`, expected: []string{"engineering"}},
+		{expr: "memberships.name", expected: []string{"team-a", "team-b"}},
+		{expr: "employee_number", expected: []string{"12345"}},
+		{expr: "missing.path", expected: nil},
+		{expr: "groups[5]", expected: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			path, err := ParsePath(tt.expr)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, path.Strings(claims))
+		})
+	}
+}
+
+func TestMapper_Defaults(t *testing.T) {
+	identity := DefaultMapper().Map(map[string]interface{}{
+		"sub":    "user123",
+		"email":  "test@example.com",
+		"name":   "Test User",
+		"groups": []interface{}{"admin"},
+		"roles":  "reader",
+	})
+
+	assert.Equal(t, "user123", identity.Subject)
+	assert.Equal(t, "user123", identity.UserID)
+	assert.Equal(t, "test@example.com", identity.Email)
+	assert.Equal(t, "Test User", identity.Name)
+	assert.Equal(t, []string{"admin"}, identity.Groups)
+	assert.Equal(t, []string{"reader"}, identity.Roles)
+	assert.False(t, identity.GroupsOverage)
+}
+
+func TestMapper_Providers(t *testing.T) {
+	tests := []struct {
+		name           string
+		config         config.ClaimsConfig
+		claims         map[string]interface{}
+		expectedUserID string
+		expectedGroups []string
+		expectedRoles  []string
+	}{
+		{
+			name: "Keycloak realm roles",
+			config: config.ClaimsConfig{
+				UserID: config.ClaimMapping{Paths: []string{"preferred_username"}},
+				Groups: config.ClaimMapping{
+					Paths:      []string{"groups"},
+					Transforms: []config.ClaimTransform{{Type: "strip_prefix", Value: "/"}},
+				},
+				Roles: config.ClaimMapping{Paths: []string{"realm_access.roles"}},
+			},
+			claims: map[string]interface{}{
+				"sub":                "f1e2",
+				"preferred_username": "alice",
+				"groups":             []interface{}{"/platform", "/sre"},
+				"realm_access":       map[string]interface{}{"roles": []interface{}{"mcp-user"}},
+			},
+			expectedUserID: "alice",
+			expectedGroups: []string{"platform", "sre"},
+			expectedRoles:  []string{"mcp-user"},
+		},
+		{
+			name: "Auth0 namespaced claims",
+			config: config.ClaimsConfig{
+				Groups: config.ClaimMapping{
+					Paths:      []string{`["https://mcp.example.com/groups"]`},
+					Transforms: []config.ClaimTransform{{Type: "lowercase"}},
+				},
+			},
+			claims: map[string]interface{}{
+				"sub":                            "auth0|123",
+				"https://mcp.example.com/groups": []interface{}{"Admins", "admins", "Developers"},
+			},
+			expectedUserID: "auth0|123",
+			expectedGroups: []string{"admins", "developers"},
+		},
+		{
+			name: "Azure roles with regex extraction",
+			config: config.ClaimsConfig{
+				UserID: config.ClaimMapping{Paths: []string{"oid", "sub"}},
+				Roles: config.ClaimMapping{
+					Paths:      []string{"roles"},
+					Transforms: []config.ClaimTransform{{Type: "regex_extract", Value: `^MCP\.(\w+)$`}},
+				},
+			},
+			claims: map[string]interface{}{
+				"sub":   "pairwise-sub",
+				"oid":   "object-id",
+				"roles": []interface{}{"MCP.Reader", "Other.Role", "MCP.Writer"},
+			},
+			expectedUserID: "object-id",
+			expectedRoles:  []string{"Reader", "Writer"},
+		},
+		{
+			name: "Fallback path and split scope-style claim",
+			config: config.ClaimsConfig{
+				Groups: config.ClaimMapping{
+					Paths:      []string{"groups", "permissions"},
+					Transforms: []config.ClaimTransform{{Type: "split", Value: " "}},
+				},
+			},
+			claims: map[string]interface{}{
+				"sub":         "user123",
+				"permissions": "read:tools write:tools",
+			},
+			expectedUserID: "user123",
+			expectedGroups: []string{"read:tools", "write:tools"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mapper, err := NewMapper(&tt.config)
+			require.NoError(t, err)
+
+			identity := mapper.Map(tt.claims)
+			assert.Equal(t, tt.expectedUserID, identity.UserID)
+			assert.Equal(t, tt.expectedGroups, identity.Groups)
+			assert.Equal(t, tt.expectedRoles, identity.Roles)
+		})
+	}
+}
+
+func TestMapper_GroupsOverage(t *testing.T) {
+	identity := DefaultMapper().Map(map[string]interface{}{
+		"sub": "user123",
+		"_claim_names": map[string]interface{}{
+			"groups": "src1",
+		},
+	})
+
+	assert.True(t, identity.GroupsOverage)
+	assert.Empty(t, identity.Groups)
+}
+
+func TestNewMapper_InvalidConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		config config.ClaimsConfig
+	}{
+		{
+			name:   "Invalid path",
+			config: config.ClaimsConfig{Groups: config.ClaimMapping{Paths: []string{"groups["}}},
+		},
+		{
+			name: "Invalid regex",
+			config: config.ClaimsConfig{Roles: config.ClaimMapping{
+				Transforms: []config.ClaimTransform{{Type: "regex_extract", Value: "("}},
+			}},
+		},
+		{
+			name: "Unknown transform",
+			config: config.ClaimsConfig{Email: config.ClaimMapping{
+				Transforms: []config.ClaimTransform{{Type: "reverse"}},
+			}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewMapper(&tt.config)
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,186 @@
+package claims
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Path is a compiled claim path such as "realm_access.roles" or
+// `["https://example.com/claims"].groups`
+type Path struct {
+	raw      string
+	segments []segment
+}
+
+// segment is a single step in a claim path
+type segment struct {
+	key   string
+	index int
+	isIdx bool
+}
+
+// ParsePath compiles a JSON-path-like claim expression.
+//
+// Supported syntax:
+//   - dot separated keys: realm_access.roles
+//   - quoted keys for names containing dots or slashes: ["https://example.com/roles"]
+//   - array indexes: groups[0]
+//
+// When a key segment is applied to an array, it is applied to every element.
+func ParsePath(expr string) (Path, error) {
+	expr = strings.TrimSpace(expr)
+	if expr == "" {
+		return Path{}, fmt.Errorf("empty claim path")
+	}
+
+	var segments []segment
+	i := 0
+	for i < len(expr) {
+		switch expr[i] {
+		case '.':
+			if i == 0 || i == len(expr)-1 {
+				return Path{}, fmt.Errorf("invalid claim path %q: unexpected '.' at %d", expr, i)
+			}
+			i++
+		case '[':
+			end := strings.IndexByte(expr[i:], ']')
+			if end < 0 {
+				return Path{}, fmt.Errorf("invalid claim path %q: unterminated '['", expr)
+			}
+			inner := expr[i+1 : i+end]
+			seg, err := parseBracket(inner)
+			if err != nil {
+				return Path{}, fmt.Errorf("invalid claim path %q: %w", expr, err)
+			}
+			segments = append(segments, seg)
+			i += end + 1
+		default:
+			end := strings.IndexAny(expr[i:], ".[")
+			if end < 0 {
+				end = len(expr) - i
+			}
+			segments = append(segments, segment{key: expr[i : i+end]})
+			i += end
+		}
+	}
+
+	if len(segments) == 0 {
+		return Path{}, fmt.Errorf("invalid claim path %q", expr)
+	}
+
+	return Path{raw: expr, segments: segments}, nil
+}
+
+// parseBracket parses the contents of a bracket segment
+func parseBracket(inner string) (segment, error) {
+	if len(inner) >= 2 {
+		quote := inner[0]
+		if (quote == '"' || quote == '\'') && inner[len(inner)-1] == quote {
+			key := inner[1 : len(inner)-1]
+			if key == "" {
+				return segment{}, fmt.Errorf("empty quoted key")
+			}
+			return segment{key: key}, nil
+		}
+	}
+
+	idx, err := strconv.Atoi(inner)
+	if err != nil || idx < 0 {
+		return segment{}, fmt.Errorf("invalid bracket expression [%s]", inner)
+	}
+	return segment{index: idx, isIdx: true}, nil
+}
+
+// String returns the original expression
+func (p Path) String() string {
+	return p.raw
+}
+
+// Lookup evaluates the path against a claim set and returns the matched values
+func (p Path) Lookup(claims map[string]interface{}) []interface{} {
+	if claims == nil {
+		return nil
+	}
+
+	current := []interface{}{claims}
+	for _, seg := range p.segments {
+		var next []interface{}
+		for _, value := range current {
+			next = append(next, seg.apply(value)...)
+		}
+		if len(next) == 0 {
+			return nil
+		}
+		current = next
+	}
+
+	// Flatten a trailing array so that "groups" yields its elements
+	var result []interface{}
+	for _, value := range current {
+		if arr, ok := value.([]interface{}); ok {
+			result = append(result, arr...)
+			continue
+		}
+		if arr, ok := value.([]string); ok {
+			for _, s := range arr {
+				result = append(result, s)
+			}
+			continue
+		}
+		result = append(result, value)
+	}
+	return result
+}
+
+// apply applies a single segment to a value
+func (s segment) apply(value interface{}) []interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if s.isIdx {
+			return nil
+		}
+		if child, ok := v[s.key]; ok && child != nil {
+			return []interface{}{child}
+		}
+		return nil
+	case []interface{}:
+		if s.isIdx {
+			if s.index < len(v) {
+				return []interface{}{v[s.index]}
+			}
+			return nil
+		}
+		// Project the key over every element of the array
+		var out []interface{}
+		for _, elem := range v {
+			out = append(out, s.apply(elem)...)
+		}
+		return out
+	case []string:
+		if s.isIdx && s.index < len(v) {
+			return []interface{}{v[s.index]}
+		}
+		return nil
+	default:
+		return nil
+	}
+}
+
+// Strings evaluates the path and converts all scalar results to strings
+func (p Path) Strings(claims map[string]interface{}) []string {
+	var out []string
+	for _, value := range p.Lookup(claims) {
+		switch v := value.(type) {
+		case string:
+			if v != "" {
+				out = append(out, v)
+			}
+		case float64:
+			out = append(out, strconv.FormatFloat(v, 'f', -1, 64))
+		case bool, int, int64:
+			out = append(out, fmt.Sprintf("%v", v))
+		}
+	}
+	return out
+}
@@ -0,0 +1,191 @@
+package identity
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/config"
+)
+
+// Default identity header names
+const (
+	DefaultUserIDHeader     = "X-User-ID"
+	DefaultUserEmailHeader  = "X-User-Email"
+	DefaultUserNameHeader   = "X-User-Name"
+	DefaultUserGroupsHeader = "X-User-Groups"
+)
+
+// Identity holds the trusted user attributes propagated to the upstream
+type Identity struct {
+	UserID string
+	Email  string
+	Name   string
+	Groups []string
+}
+
+// Propagator removes client-supplied identity headers and injects trusted ones.
+// Every header name it manages is deleted from the request before any value is
+// set, so a client can never smuggle an identity header the proxy did not produce.
+type Propagator struct {
+	userID     string
+	userEmail  string
+	userName   string
+	userGroups string
+	protected  []string
+	signer     *Signer
+}
+
+// NewPropagator creates a propagator for the configured identity headers,
+// signing them when header signing is enabled
+func NewPropagator(cfg *config.HeadersConfig) (*Propagator, error) {
+	p := NewUnsignedPropagator(cfg)
+	if !cfg.Signing.Enabled {
+		return p, nil
+	}
+
+	signer, err := NewSigner(cfg.Signing.Header, []byte(cfg.Signing.Secret))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create identity header signer: %w", err)
+	}
+	p.signer = signer
+	p.protected = appendName(p.protected, signer.Header())
+	sort.Strings(p.protected)
+
+	return p, nil
+}
+
+// NewUnsignedPropagator creates a propagator that strips and injects the
+// configured identity headers without signing them. Empty header names are disabled.
+func NewUnsignedPropagator(cfg *config.HeadersConfig) *Propagator {
+	p := &Propagator{
+		userID:     http.CanonicalHeaderKey(cfg.UserID),
+		userEmail:  http.CanonicalHeaderKey(cfg.UserEmail),
+		userName:   http.CanonicalHeaderKey(cfg.UserName),
+		userGroups: http.CanonicalHeaderKey(cfg.UserGroups),
+	}
+
+	names := []string{p.userID, p.userEmail, p.userName, p.userGroups}
+	for name := range cfg.Templates {
+		names = append(names, name)
+	}
+	for _, route := range cfg.Routes {
+		for name := range route.Templates {
+			names = append(names, name)
+		}
+	}
+	names = append(names, cfg.Strip...)
+	if cfg.Signing.Header != "" {
+		// Never forward a client-supplied signature, even when signing is off
+		names = append(names, cfg.Signing.Header)
+	}
+
+	for _, name := range names {
+		p.protected = appendName(p.protected, name)
+	}
+	sort.Strings(p.protected)
+
+	return p
+}
+
+// DefaultPropagator returns an unsigned propagator using the default header names
+func DefaultPropagator() *Propagator {
+	return NewUnsignedPropagator(&config.HeadersConfig{
+		UserID:     DefaultUserIDHeader,
+		UserEmail:  DefaultUserEmailHeader,
+		UserName:   DefaultUserNameHeader,
+		UserGroups: DefaultUserGroupsHeader,
+	})
+}
+
+// HeaderNames returns every header name managed by the propagator
+func (p *Propagator) HeaderNames() []string {
+	return p.protected
+}
+
+// Signed reports whether injected headers are signed
+func (p *Propagator) Signed() bool {
+	return p.signer != nil
+}
+
+// Strip removes all managed identity headers from the request
+func (p *Propagator) Strip(r *http.Request) {
+	for _, name := range p.protected {
+		r.Header.Del(name)
+	}
+}
+
+// Apply strips client-supplied identity headers and injects the trusted identity
+// and any extra identity headers (e.g. rendered templates). A nil identity only
+// strips. When signing is enabled, the resulting header set is signed.
+func (p *Propagator) Apply(r *http.Request, id *Identity, extra http.Header) {
+	p.Strip(r)
+
+	if id != nil {
+		setHeader(r.Header, p.userID, id.UserID)
+		setHeader(r.Header, p.userEmail, id.Email)
+		setHeader(r.Header, p.userName, id.Name)
+		setHeader(r.Header, p.userGroups, strings.Join(id.Groups, ","))
+	}
+
+	for name, values := range extra {
+		for _, value := range values {
+			if value = SanitizeHeaderValue(value); value != "" {
+				r.Header.Add(name, value)
+			}
+		}
+	}
+
+	if p.signer != nil && id != nil {
+		p.signer.Sign(r, p.signedNames())
+	}
+}
+
+// signedNames returns the managed headers covered by the signature
+func (p *Propagator) signedNames() []string {
+	names := make([]string, 0, len(p.protected))
+	for _, name := range p.protected {
+		if name != p.signer.Header() {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// setHeader sets a sanitized header value when both name and value are non-empty
+func setHeader(h http.Header, name, value string) {
+	if name == "" {
+		return
+	}
+	if value = SanitizeHeaderValue(value); value != "" {
+		h.Set(name, value)
+	}
+}
+
+// appendName adds a canonical header name to the list unless it is empty or present
+func appendName(names []string, name string) []string {
+	name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+	if name == "" {
+		return names
+	}
+	for _, existing := range names {
+		if existing == name {
+			return names
+		}
+	}
+	return append(names, name)
+}
+
+// SanitizeHeaderValue removes control characters that could split or corrupt headers
+func SanitizeHeaderValue(value string) string {
+	var b strings.Builder
+	b.Grow(len(value))
+	for _, r := range value {
+		if r < 0x20 || r == 0x7f {
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return strings.TrimSpace(b.String())
+}
@@ -0,0 +1,203 @@
+package identity
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testSecret = "0123456789abcdef0123456789abcdef"
+
+func testHeadersConfig() *config.HeadersConfig {
+	return &config.HeadersConfig{
+		UserID:     "X-User-ID",
+		UserEmail:  "X-User-Email",
+		UserName:   "X-User-Name",
+		UserGroups: "X-User-Groups",
+		Templates: map[string]string{
+			"X-Tenant-ID": `{{ .Claim "tenant_id" }}`,
+		},
+		Routes: []config.HeaderRouteConfig{
+			{PathPrefix: "/hr", Templates: map[string]string{"X-Employee-Number": ""}},
+		},
+		Strip: []string{"x-forwarded-user"},
+	}
+}
+
+func TestPropagator_HeaderNames(t *testing.T) {
+	cfg := testHeadersConfig()
+	cfg.Signing = config.HeaderSigningConfig{Enabled: true, Header: "X-Proxy-Signature", Secret: testSecret}
+
+	p, err := NewPropagator(cfg)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"X-Employee-Number",
+		"X-Forwarded-User",
+		"X-Proxy-Signature",
+		"X-Tenant-Id",
+		"X-User-Email",
+		"X-User-Groups",
+		"X-User-Id",
+		"X-User-Name",
+	}, p.HeaderNames())
+	assert.True(t, p.Signed())
+}
+
+func TestPropagator_ApplyStripsSpoofedHeaders(t *testing.T) {
+	p, err := NewPropagator(testHeadersConfig())
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodGet, "/mcp", nil)
+	req.Header.Set("X-User-ID", "attacker")
+	req.Header.Set("X-User-Groups", "admin")
+	req.Header.Set("X-Tenant-ID", "other-tenant")
+	req.Header.Set("X-Forwarded-User", "attacker")
+	req.Header.Set("X-Unrelated", "kept")
+
+	p.Apply(req, &Identity{
+		UserID: "user123",
+		Email:  "user@example.com",
+		Name:   "Test\r\nUser",
+	}, http.Header{"X-Tenant-Id": {"tenant-42"}})
+
+	assert.Equal(t, []string{"user123"}, req.Header.Values("X-User-ID"))
+	assert.Equal(t, "user@example.com", req.Header.Get("X-User-Email"))
+	assert.Equal(t, "TestUser", req.Header.Get("X-User-Name"))
+	assert.Empty(t, req.Header.Values("X-User-Groups"), "spoofed groups must not survive when the user has none")
+	assert.Equal(t, []string{"tenant-42"}, req.Header.Values("X-Tenant-ID"))
+	assert.Empty(t, req.Header.Get("X-Forwarded-User"))
+	assert.Equal(t, "kept", req.Header.Get("X-Unrelated"))
+	assert.Empty(t, req.Header.Get(DefaultSignatureHeader))
+}
+
+func TestPropagator_ApplyWithoutIdentity(t *testing.T) {
+	cfg := testHeadersConfig()
+	cfg.Signing = config.HeaderSigningConfig{Enabled: true, Secret: testSecret}
+	p, err := NewPropagator(cfg)
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodGet, "/mcp", nil)
+	req.Header.Set("X-User-ID", "attacker")
+	req.Header.Set(DefaultSignatureHeader, "t=1,h=x-user-id,v1=forged")
+
+	p.Apply(req, nil, nil)
+
+	assert.Empty(t, req.Header.Get("X-User-ID"))
+	assert.Empty(t, req.Header.Get(DefaultSignatureHeader))
+}
+
+func TestPropagator_UnsignedStripsSignatureHeader(t *testing.T) {
+	cfg := testHeadersConfig()
+	cfg.Signing.Header = DefaultSignatureHeader
+
+	p := NewUnsignedPropagator(cfg)
+
+	req := httptest.NewRequest(http.MethodGet, "/mcp", nil)
+	req.Header.Set(DefaultSignatureHeader, "t=1,h=x-user-id,v1=forged")
+	p.Apply(req, &Identity{UserID: "user123"}, nil)
+
+	assert.Empty(t, req.Header.Get(DefaultSignatureHeader))
+	assert.False(t, p.Signed())
+}
+
+func TestNewPropagator_ShortSecret(t *testing.T) {
+	cfg := testHeadersConfig()
+	cfg.Signing = config.HeaderSigningConfig{Enabled: true, Secret: "too-short"}
+
+	_, err := NewPropagator(cfg)
+	assert.Error(t, err)
+}
+
+func TestSignatureRoundTrip(t *testing.T) {
+	cfg := testHeadersConfig()
+	cfg.Signing = config.HeaderSigningConfig{Enabled: true, Secret: testSecret}
+	p, err := NewPropagator(cfg)
+	require.NoError(t, err)
+
+	signed := func() *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/mcp/tools?session=abc", nil)
+		p.Apply(req, &Identity{
+			UserID: "user123",
+			Email:  "user@example.com",
+			Groups: []string{"dev", "ops"},
+		}, nil)
+		return req
+	}
+
+	t.Run("Valid signature", func(t *testing.T) {
+		req := signed()
+		assert.True(t, strings.HasPrefix(req.Header.Get(DefaultSignatureHeader), "t="))
+		assert.NoError(t, Verify(req, "", []byte(testSecret), time.Minute))
+	})
+
+	t.Run("Wrong secret", func(t *testing.T) {
+		req := signed()
+		err := Verify(req, "", []byte("ffffffffffffffffffffffffffffffff"), time.Minute)
+		assert.ErrorIs(t, err, ErrInvalidSignature)
+	})
+
+	t.Run("Tampered header", func(t *testing.T) {
+		req := signed()
+		req.Header.Set("X-User-Groups", "admin")
+		assert.ErrorIs(t, Verify(req, "", []byte(testSecret), time.Minute), ErrInvalidSignature)
+	})
+
+	t.Run("Added header", func(t *testing.T) {
+		req := signed()
+		req.Header.Set("X-User-Name", "Injected")
+		assert.ErrorIs(t, Verify(req, "", []byte(testSecret), time.Minute), ErrInvalidSignature)
+	})
+
+	t.Run("Removed header", func(t *testing.T) {
+		req := signed()
+		req.Header.Del("X-User-Email")
+		assert.ErrorIs(t, Verify(req, "", []byte(testSecret), time.Minute), ErrInvalidSignature)
+	})
+
+	t.Run("Different path", func(t *testing.T) {
+		req := signed()
+		req.URL.Path = "/admin"
+		assert.ErrorIs(t, Verify(req, "", []byte(testSecret), time.Minute), ErrInvalidSignature)
+	})
+
+	t.Run("Different query", func(t *testing.T) {
+		req := signed()
+		req.URL.RawQuery = "session=xyz"
+		assert.ErrorIs(t, Verify(req, "", []byte(testSecret), time.Minute), ErrInvalidSignature)
+
+		req = signed()
+		req.URL.RawQuery = ""
+		assert.ErrorIs(t, Verify(req, "", []byte(testSecret), time.Minute), ErrInvalidSignature)
+	})
+
+	t.Run("Missing signature", func(t *testing.T) {
+		req := signed()
+		req.Header.Del(DefaultSignatureHeader)
+		assert.ErrorIs(t, Verify(req, "", []byte(testSecret), time.Minute), ErrMissingSignature)
+	})
+
+	t.Run("Expired signature", func(t *testing.T) {
+		p.signer.now = func() time.Time { return time.Now().Add(-time.Hour) }
+		defer func() { p.signer.now = time.Now }()
+
+		req := signed()
+		assert.ErrorIs(t, Verify(req, "", []byte(testSecret), time.Minute), ErrExpiredSignature)
+		assert.ErrorIs(t, Verify(req, "", []byte(testSecret), 0), ErrExpiredSignature, "zero uses the default max age")
+		assert.NoError(t, Verify(req, "", []byte(testSecret), 2*time.Hour))
+	})
+
+	t.Run("Future signature", func(t *testing.T) {
+		p.signer.now = func() time.Time { return time.Now().Add(time.Hour) }
+		defer func() { p.signer.now = time.Now }()
+
+		req := signed()
+		assert.ErrorIs(t, Verify(req, "", []byte(testSecret), 2*time.Hour), ErrExpiredSignature)
+	})
+}
@@ -0,0 +1,216 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/gin-gonic/gin"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/session"
+	"go.uber.org/zap"
+)
+
+// BackChannelLogoutEvent is the event type a logout token must carry
+// (OpenID Connect Back-Channel Logout 1.0 section 2.4)
+const BackChannelLogoutEvent = "http://schemas.openid.net/event/backchannel-logout"
+
+// logoutTokenLeeway is the clock skew tolerated when checking the logout token expiry
+const logoutTokenLeeway = time.Minute
+
+// LogoutToken is a verified back-channel logout token
+type LogoutToken struct {
+	Subject   string
+	SessionID string
+	IssuedAt  time.Time
+}
+
+// SubjectIndex returns the session index for sessions of a subject
+func SubjectIndex(subject string) string {
+	return "sub:" + subject
+}
+
+// IdPSessionIndex returns the session index for sessions of an IdP session
+func IdPSessionIndex(sid string) string {
+	return "sid:" + sid
+}
+
+// GrantIndex returns the session index for sessions holding the refresh
+// token of a login
+func GrantIndex(grantID string) string {
+	return "grant:" + grantID
+}
+
+// VerifyLogoutToken verifies the signature, issuer and audience of a logout
+// token and checks the claims required by the back-channel logout spec
+func (c *Client) VerifyLogoutToken(ctx context.Context, rawToken string) (*LogoutToken, error) {
+	ctx = oidc.ClientContext(ctx, c.httpClient)
+	token, err := c.logoutVerifier.Verify(ctx, rawToken)
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify logout token: %w", err)
+	}
+
+	// exp is optional in logout tokens, so it is checked here rather than
+	// by the verifier
+	if !token.Expiry.IsZero() && time.Now().After(token.Expiry.Add(logoutTokenLeeway)) {
+		return nil, fmt.Errorf("logout token expired at %s", token.Expiry)
+	}
+	if token.IssuedAt.IsZero() {
+		return nil, fmt.Errorf("logout token has no iat claim")
+	}
+
+	var claims struct {
+		SessionID string                     `json:"sid"`
+		Events    map[string]json.RawMessage `json:"events"`
+		Nonce     json.RawMessage            `json:"nonce"`
+	}
+	if err := token.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("failed to extract logout token claims: %w", err)
+	}
+
+	event, ok := claims.Events[BackChannelLogoutEvent]
+	if !ok {
+		return nil, fmt.Errorf("logout token has no %s event", BackChannelLogoutEvent)
+	}
+	var eventValue map[string]interface{}
+	if err := json.Unmarshal(event, &eventValue); err != nil || eventValue == nil {
+		return nil, fmt.Errorf("logout token event must be a JSON object")
+	}
+	if claims.Nonce != nil {
+		return nil, fmt.Errorf("logout token must not contain a nonce")
+	}
+	if token.Subject == "" && claims.SessionID == "" {
+		return nil, fmt.Errorf("logout token has neither sub nor sid")
+	}
+
+	return &LogoutToken{
+		Subject:   token.Subject,
+		SessionID: claims.SessionID,
+		IssuedAt:  token.IssuedAt,
+	}, nil
+}
+
+// BackChannelLogout receives logout tokens posted by the provider and
+// deletes every session of the identified IdP session or subject
+func (h *Handler) BackChannelLogout(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+
+	rawToken := c.PostForm("logout_token")
+	if rawToken == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":             "invalid_request",
+			"error_description": "missing logout_token",
+		})
+		return
+	}
+
+	logoutToken, err := h.client.VerifyLogoutToken(c.Request.Context(), rawToken)
+	if err != nil {
+		h.logger.Warn("Rejected back-channel logout token", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":             "invalid_request",
+			"error_description": "invalid logout_token",
+		})
+		return
+	}
+
+	logged, err := h.endLogoutSessions(c.Request.Context(), logoutToken)
+	if err != nil {
+		h.logger.Error("Failed to log out sessions for back-channel logout", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":             "invalid_request",
+			"error_description": "failed to log out sessions",
+		})
+		return
+	}
+
+	h.logger.Info("Back-channel logout processed",
+		zap.String("subject", logoutToken.Subject),
+		zap.String("sid", logoutToken.SessionID),
+		zap.Int("sessions_deleted", logged),
+	)
+
+	c.Status(http.StatusOK)
+}
+
+// errNoSessionIndex is returned when the session store can neither list nor
+// revoke the sessions of a logout token
+var errNoSessionIndex = errors.New("session store cannot resolve logout tokens")
+
+// endLogoutSessions ends the sessions a logout token refers to and returns how
+// many were deleted. A sid limits the logout to that IdP session; otherwise
+// all sessions of the subject end. Stores that cannot list an index, like
+// the cookie store, revoke it as a whole and report no count.
+func (h *Handler) endLogoutSessions(ctx context.Context, token *LogoutToken) (int, error) {
+	index := SubjectIndex(token.Subject)
+	if token.SessionID != "" {
+		index = IdPSessionIndex(token.SessionID)
+	}
+
+	if revoker, ok := h.sessionStore.(session.IndexRevoker); ok {
+		err := revoker.RevokeIndex(ctx, index)
+		if !errors.Is(err, session.ErrIndexNotSupported) {
+			return 0, err
+		}
+	}
+
+	indexer, ok := h.sessionStore.(session.Indexer)
+	if !ok {
+		return 0, errNoSessionIndex
+	}
+	keys, err := indexer.IndexedKeys(ctx, index)
+	if errors.Is(err, session.ErrIndexNotSupported) {
+		// Session IDs are random, so without an index no session can be found
+		return 0, errNoSessionIndex
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	deleted := 0
+	for _, key := range keys {
+		if err := h.sessionStore.Delete(ctx, key); err != nil {
+			h.logger.Debug("Failed to delete session", zap.Error(err), zap.String("key", key))
+			continue
+		}
+		deleted++
+	}
+	return deleted, nil
+}
+
+// indexUserSession records a user session under its subject and IdP session
+// ID so back-channel logout can find it
+func (h *Handler) indexUserSession(ctx context.Context, key string, userSession *UserSession) {
+	indexUserSession(ctx, h.sessionStore, key, userSession, h.logger)
+}
+
+// indexUserSession records a user session in the indexes of store
+func indexUserSession(ctx context.Context, store session.Store, key string, userSession *UserSession, logger *zap.Logger) {
+	indexer, ok := store.(session.Indexer)
+	if !ok {
+		return
+	}
+
+	var indexes []string
+	if userSession.Subject != "" {
+		indexes = append(indexes, SubjectIndex(userSession.Subject))
+	}
+	if userSession.IdPSessionID != "" {
+		indexes = append(indexes, IdPSessionIndex(userSession.IdPSessionID))
+	}
+	if userSession.GrantID != "" && userSession.RefreshToken != "" {
+		indexes = append(indexes, GrantIndex(userSession.GrantID))
+	}
+
+	for _, index := range indexes {
+		if err := indexer.AddIndex(ctx, index, key); err != nil {
+			if errors.Is(err, session.ErrIndexNotSupported) {
+				return
+			}
+			logger.Warn("Failed to index user session", zap.Error(err), zap.String("index", index))
+		}
+	}
+}
@@ -0,0 +1,228 @@
+package oidc
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/config"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/session"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/session/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// logoutEvents is the events claim of a valid logout token
+var logoutEvents = map[string]interface{}{BackChannelLogoutEvent: map[string]interface{}{}}
+
+func newBackChannelHandler(t *testing.T, provider *testProvider, store session.Store) *Handler {
+	t.Helper()
+
+	handler, err := NewHandler(context.Background(), &config.OIDCConfig{
+		DiscoveryURL: provider.URL(),
+		ClientID:     "test-client",
+		ClientSecret: "test-secret",
+		RedirectURL:  "http://localhost:8080/callback",
+		Scopes:       []string{"openid"},
+	}, &config.SessionConfig{}, store, zap.NewNop())
+	require.NoError(t, err)
+	return handler
+}
+
+func postLogoutToken(handler *Handler, token string) *httptest.ResponseRecorder {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/backchannel-logout", handler.BackChannelLogout)
+
+	form := url.Values{}
+	if token != "" {
+		form.Set("logout_token", token)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/backchannel-logout", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// createIndexedSession stores a user session indexed the way Callback does
+func createIndexedSession(t *testing.T, handler *Handler, key, subject, sid string) {
+	t.Helper()
+
+	userSession := &UserSession{ID: subject, Subject: subject, IdPSessionID: sid}
+	_, err := handler.sessionStore.Create(context.Background(), key, userSession, time.Hour)
+	require.NoError(t, err)
+	handler.indexUserSession(context.Background(), key, userSession)
+}
+
+func sessionExists(t *testing.T, store session.Store, key string) bool {
+	t.Helper()
+
+	exists, err := store.Exists(context.Background(), key)
+	require.NoError(t, err)
+	return exists
+}
+
+func TestBackChannelLogout(t *testing.T) {
+	provider := newTestProvider(t)
+
+	t.Run("Subject logs out every session", func(t *testing.T) {
+		store := session.NewMetricsStore(memory.NewStore(&memory.Config{}, zap.NewNop()), "memory")
+		handler := newBackChannelHandler(t, provider, store)
+		createIndexedSession(t, handler, "a", "alice", "idp-1")
+		createIndexedSession(t, handler, "b", "alice", "idp-2")
+		createIndexedSession(t, handler, "c", "bob", "idp-3")
+
+		w := postLogoutToken(handler, provider.sign(t, map[string]interface{}{
+			"aud":    "test-client",
+			"sub":    "alice",
+			"jti":    "logout-1",
+			"events": logoutEvents,
+		}))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+		assert.False(t, sessionExists(t, store, "a"))
+		assert.False(t, sessionExists(t, store, "b"))
+		assert.True(t, sessionExists(t, store, "c"))
+	})
+
+	t.Run("Sid logs out only that IdP session", func(t *testing.T) {
+		store := memory.NewStore(&memory.Config{}, zap.NewNop())
+		handler := newBackChannelHandler(t, provider, store)
+		createIndexedSession(t, handler, "a", "alice", "idp-1")
+		createIndexedSession(t, handler, "b", "alice", "idp-2")
+
+		w := postLogoutToken(handler, provider.sign(t, map[string]interface{}{
+			"aud":    "test-client",
+			"sub":    "alice",
+			"sid":    "idp-1",
+			"events": logoutEvents,
+		}))
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.False(t, sessionExists(t, store, "a"))
+		assert.True(t, sessionExists(t, store, "b"))
+	})
+
+	t.Run("Unknown subject succeeds", func(t *testing.T) {
+		handler := newBackChannelHandler(t, provider, memory.NewStore(&memory.Config{}, zap.NewNop()))
+
+		w := postLogoutToken(handler, provider.sign(t, map[string]interface{}{
+			"aud":    "test-client",
+			"sid":    "unknown",
+			"events": logoutEvents,
+		}))
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("Stores that cannot list an index revoke it", func(t *testing.T) {
+		store := &revokingStore{Store: memory.NewStore(&memory.Config{}, zap.NewNop())}
+		handler := newBackChannelHandler(t, provider, session.NewMetricsStore(store, "cookie"))
+
+		w := postLogoutToken(handler, provider.sign(t, map[string]interface{}{
+			"aud":    "test-client",
+			"sub":    "alice",
+			"sid":    "idp-1",
+			"events": logoutEvents,
+		}))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, []string{IdPSessionIndex("idp-1")}, store.revoked)
+	})
+
+	t.Run("Stores without indexes reject the token", func(t *testing.T) {
+		store := struct{ session.Store }{memory.NewStore(&memory.Config{}, zap.NewNop())}
+		handler := newBackChannelHandler(t, provider, session.NewMetricsStore(store, "memory"))
+
+		w := postLogoutToken(handler, provider.sign(t, map[string]interface{}{
+			"aud":    "test-client",
+			"sub":    "alice",
+			"events": logoutEvents,
+		}))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+// revokingStore revokes indexes without listing them, like the cookie store
+type revokingStore struct {
+	session.Store
+	revoked []string
+}
+
+func (s *revokingStore) RevokeIndex(ctx context.Context, index string) error {
+	s.revoked = append(s.revoked, index)
+	return nil
+}
+
+func TestBackChannelLogout_InvalidTokens(t *testing.T) {
+	provider := newTestProvider(t)
+
+	tests := []struct {
+		name   string
+		claims map[string]interface{}
+	}{
+		{
+			name:   "Wrong audience",
+			claims: map[string]interface{}{"aud": "other-client", "sub": "alice", "events": logoutEvents},
+		},
+		{
+			name:   "Wrong issuer",
+			claims: map[string]interface{}{"iss": "https://evil.example.com", "aud": "test-client", "sub": "alice", "events": logoutEvents},
+		},
+		{
+			name:   "Missing events",
+			claims: map[string]interface{}{"aud": "test-client", "sub": "alice"},
+		},
+		{
+			name: "Wrong event type",
+			claims: map[string]interface{}{"aud": "test-client", "sub": "alice", "events": map[string]interface{}{
+				"http://schemas.openid.net/event/other": map[string]interface{}{},
+			}},
+		},
+		{
+			name: "Event is not an object",
+			claims: map[string]interface{}{"aud": "test-client", "sub": "alice", "events": map[string]interface{}{
+				BackChannelLogoutEvent: "logout",
+			}},
+		},
+		{
+			name:   "Missing sub and sid",
+			claims: map[string]interface{}{"aud": "test-client", "events": logoutEvents},
+		},
+		{
+			name:   "Nonce present",
+			claims: map[string]interface{}{"aud": "test-client", "sub": "alice", "nonce": "n", "events": logoutEvents},
+		},
+		{
+			name: "Expired",
+			claims: map[string]interface{}{"aud": "test-client", "sub": "alice", "events": logoutEvents,
+				"exp": time.Now().Add(-time.Hour).Unix()},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := memory.NewStore(&memory.Config{}, zap.NewNop())
+			handler := newBackChannelHandler(t, provider, store)
+			createIndexedSession(t, handler, "a", "alice", "idp-1")
+
+			w := postLogoutToken(handler, provider.sign(t, tt.claims))
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Contains(t, w.Body.String(), "invalid_request")
+			assert.True(t, sessionExists(t, store, "a"), "session must survive an invalid logout token")
+		})
+	}
+
+	t.Run("Missing token", func(t *testing.T) {
+		handler := newBackChannelHandler(t, provider, memory.NewStore(&memory.Config{}, zap.NewNop()))
+		w := postLogoutToken(handler, "")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
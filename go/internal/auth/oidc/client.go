@@ -5,8 +5,10 @@ import (
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/coreos/go-oidc/v3/oidc"
@@ -15,10 +17,22 @@ import (
 
 // Client represents an OIDC client with PKCE support
 type Client struct {
-	provider     *oidc.Provider
-	oauth2Config *oauth2.Config
-	verifier     *oidc.IDTokenVerifier
-	httpClient   *http.Client
+	provider          *oidc.Provider
+	oauth2Config      *oauth2.Config
+	verifier          *oidc.IDTokenVerifier
+	bearerVerifier    *oidc.IDTokenVerifier
+	logoutVerifier    *oidc.IDTokenVerifier
+	bearer            *BearerPolicy
+	httpClient        *http.Client
+	metadata          providerMetadata
+}
+
+// providerMetadata holds discovery document fields not exposed by go-oidc
+type providerMetadata struct {
+	Issuer                      string `json:"issuer"`
+	EndSessionEndpoint          string `json:"end_session_endpoint"`
+	FrontChannelLogoutSupported bool   `json:"frontchannel_logout_supported"`
+	BackChannelLogoutSupported  bool   `json:"backchannel_logout_supported"`
 }
 
 // NewClient creates a new OIDC client with discovery support
@@ -37,6 +51,11 @@ func NewClient(ctx context.Context, discoveryURL, clientID, clientSecret, redire
 		return nil, fmt.Errorf("failed to discover OIDC provider: %w", err)
 	}
 
+	var metadata providerMetadata
+	if err := provider.Claims(&metadata); err != nil {
+		return nil, fmt.Errorf("failed to parse provider metadata: %w", err)
+	}
+
 	// Configure OAuth2 client
 	oauth2Config := &oauth2.Config{
 		ClientID:     clientID,
@@ -51,16 +70,48 @@ func NewClient(ctx context.Context, discoveryURL, clientID, clientSecret, redire
 		ClientID: clientID,
 	})
 
+	// Access tokens are issued for other audiences, which are checked
+	// against the bearer policy after signature verification
+	bearerVerifier := provider.Verifier(&oidc.Config{
+		SkipClientIDCheck: true,
+	})
+
+	// Logout tokens are addressed to the client but exp is optional
+	logoutVerifier := provider.Verifier(&oidc.Config{
+		ClientID:        clientID,
+		SkipExpiryCheck: true,
+	})
+
 	return &Client{
-		provider:     provider,
-		oauth2Config: oauth2Config,
-		verifier:     verifier,
-		httpClient:   httpClient,
+		provider:          provider,
+		oauth2Config:      oauth2Config,
+		verifier:          verifier,
+		bearerVerifier:    bearerVerifier,
+		logoutVerifier:    logoutVerifier,
+		httpClient:        httpClient,
+		metadata:          metadata,
 	}, nil
 }
 
-// AuthCodeURL generates the authorization URL with PKCE parameters
-func (c *Client) AuthCodeURL(state string) (string, string, string, error) {
+// TokenEndpoint returns the provider's token endpoint URL
+func (c *Client) TokenEndpoint() string {
+	return c.oauth2Config.Endpoint.TokenURL
+}
+
+// Issuer returns the provider's issuer identifier
+func (c *Client) Issuer() string {
+	return c.metadata.Issuer
+}
+
+// EndSessionEndpoint returns the provider's RP-initiated logout endpoint,
+// or an empty string if the provider does not advertise one
+func (c *Client) EndSessionEndpoint() string {
+	return c.metadata.EndSessionEndpoint
+}
+
+// AuthCodeURL generates the authorization URL with PKCE parameters and any
+// additional parameters in opts
+func (c *Client) AuthCodeURL(state string, opts ...oauth2.AuthCodeOption) (string, string, string, error) {
 	// Generate PKCE code verifier
 	codeVerifier, err := generateCodeVerifier()
 	if err != nil {
@@ -71,10 +122,10 @@ func (c *Client) AuthCodeURL(state string) (string, string, string, error) {
 	codeChallenge := generateCodeChallenge(codeVerifier)
 
 	// Build authorization URL with PKCE parameters
-	authURL := c.oauth2Config.AuthCodeURL(state,
+	authURL := c.oauth2Config.AuthCodeURL(state, append([]oauth2.AuthCodeOption{
 		oauth2.SetAuthURLParam("code_challenge", codeChallenge),
 		oauth2.SetAuthURLParam("code_challenge_method", "S256"),
-	)
+	}, opts...)...)
 
 	return authURL, codeVerifier, codeChallenge, nil
 }
@@ -162,6 +213,107 @@ func (c *Client) RefreshToken(ctx context.Context, refreshToken string) (*TokenR
 	}, nil
 }
 
+// BearerPolicy restricts the JWT access tokens accepted as bearer credentials
+type BearerPolicy struct {
+	// Audiences lists the accepted aud values
+	Audiences []string
+	// AuthorizedParties lists the accepted azp or client_id values (empty: any)
+	AuthorizedParties []string
+	// RequiredScopes must all be present in the scope claim
+	RequiredScopes []string
+}
+
+// accessTokenTypes are the JWT typ values of access tokens (RFC 9068 section 2.1)
+var accessTokenTypes = map[string]bool{
+	"at+jwt":             true,
+	"application/at+jwt": true,
+}
+
+// VerifyAccessToken verifies a JWT access token issued by the provider
+// against the bearer policy and returns its claims and expiry. ID tokens are
+// rejected: they are signed by the same provider but lack the at+jwt type.
+func (c *Client) VerifyAccessToken(ctx context.Context, rawToken string) (map[string]interface{}, time.Time, error) {
+	if c.bearer == nil {
+		return nil, time.Time{}, fmt.Errorf("bearer token authentication is disabled")
+	}
+
+	typ, err := tokenType(rawToken)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	if !accessTokenTypes[strings.ToLower(typ)] {
+		return nil, time.Time{}, fmt.Errorf("bearer token type %q is not an access token", typ)
+	}
+
+	ctx = oidc.ClientContext(ctx, c.httpClient)
+	token, err := c.bearerVerifier.Verify(ctx, rawToken)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to verify bearer token: %w", err)
+	}
+
+	if !anyIn(token.Audience, c.bearer.Audiences) {
+		return nil, time.Time{}, fmt.Errorf("bearer token audience %v not accepted", token.Audience)
+	}
+
+	var claims map[string]interface{}
+	if err := token.Claims(&claims); err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to extract claims: %w", err)
+	}
+
+	// RFC 9068 names the client in client_id; many providers use azp
+	party, _ := claims["client_id"].(string)
+	if party == "" {
+		party, _ = claims["azp"].(string)
+	}
+	if party == "" {
+		return nil, time.Time{}, fmt.Errorf("bearer token has no azp or client_id claim")
+	}
+	if len(c.bearer.AuthorizedParties) > 0 && !anyIn([]string{party}, c.bearer.AuthorizedParties) {
+		return nil, time.Time{}, fmt.Errorf("bearer token client %q not accepted", party)
+	}
+
+	scope, _ := claims["scope"].(string)
+	granted := strings.Fields(scope)
+	for _, required := range c.bearer.RequiredScopes {
+		if !anyIn([]string{required}, granted) {
+			return nil, time.Time{}, fmt.Errorf("bearer token lacks scope %q", required)
+		}
+	}
+
+	return claims, token.Expiry, nil
+}
+
+// tokenType returns the typ header of a compact JWT
+func tokenType(rawToken string) (string, error) {
+	header, _, ok := strings.Cut(rawToken, ".")
+	if !ok {
+		return "", fmt.Errorf("bearer token is not a JWT")
+	}
+	decoded, err := base64.RawURLEncoding.DecodeString(header)
+	if err != nil {
+		return "", fmt.Errorf("bearer token has a malformed header: %w", err)
+	}
+	var fields struct {
+		Type string `json:"typ"`
+	}
+	if err := json.Unmarshal(decoded, &fields); err != nil {
+		return "", fmt.Errorf("bearer token has a malformed header: %w", err)
+	}
+	return fields.Type, nil
+}
+
+// anyIn reports whether any of values is in the accepted list
+func anyIn(values, accepted []string) bool {
+	for _, value := range values {
+		for _, a := range accepted {
+			if value == a {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // UserInfo fetches user information from the userinfo endpoint
 func (c *Client) UserInfo(ctx context.Context, accessToken string) (map[string]interface{}, error) {
 	userInfo, err := c.provider.UserInfo(ctx, oauth2.StaticTokenSource(&oauth2.Token{
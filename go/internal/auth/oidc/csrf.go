@@ -0,0 +1,185 @@
+package oidc
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/auth/pages"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/config"
+	"go.uber.org/zap"
+)
+
+// CSRF token transport
+const (
+	CSRFHeader    = "X-CSRF-Token"
+	CSRFFormField = "csrf_token"
+)
+
+// CSRFProtection guards the proxy's own state-changing endpoints against
+// cross-site request forgery. Cookie-authenticated requests must come from a
+// trusted origin, as shown by the Origin or Sec-Fetch-Site header, and
+// requests without either header must carry the session's CSRF token.
+//
+// The token is an HMAC of the user and login time, so it is bound to one
+// login, survives session ID rotation and needs no storage.
+type CSRFProtection struct {
+	enabled        bool
+	requireToken   bool
+	trustedOrigins map[string]bool
+	key            []byte
+}
+
+// NewCSRFProtection creates the CSRF protection. The proxy's own origin,
+// taken from the redirect URL, is always trusted. The token key is derived
+// from the client secret, which every proxy instance shares.
+func NewCSRFProtection(cfg *config.CSRFConfig, redirectURL, clientSecret string) *CSRFProtection {
+	p := &CSRFProtection{trustedOrigins: make(map[string]bool)}
+	if cfg != nil {
+		p.enabled = cfg.Enabled
+		p.requireToken = cfg.RequireToken
+		for _, origin := range cfg.TrustedOrigins {
+			p.trustedOrigins[normalizeOrigin(origin)] = true
+		}
+	}
+	if origin := normalizeOrigin(redirectURL); origin != "" {
+		p.trustedOrigins[origin] = true
+	}
+
+	h := hmac.New(sha256.New, []byte(clientSecret))
+	h.Write([]byte("csrf token key"))
+	p.key = h.Sum(nil)
+	return p
+}
+
+// Token returns the CSRF token of a session
+func (p *CSRFProtection) Token(userSession *UserSession) string {
+	if userSession == nil {
+		return ""
+	}
+	h := hmac.New(sha256.New, p.key)
+	h.Write([]byte(userSession.ID))
+	h.Write([]byte{0})
+	h.Write([]byte(strconv.FormatInt(userSession.CreatedAt.UnixNano(), 10)))
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+}
+
+// Check reports why a request must be rejected, or "" when it may proceed.
+// userSession is the session the request's cookie authenticates, if any.
+func (p *CSRFProtection) Check(r *http.Request, userSession *UserSession) string {
+	if !p.enabled || isSafeMethod(r.Method) {
+		return ""
+	}
+	// Browsers only attach cookies on their own; a bearer token is added by
+	// the client, and cross-site pages cannot add headers without CORS. Only
+	// requests the auth middleware authenticated by the token are exempt: a
+	// bearer header next to a session cookie does not vouch for the cookie.
+	if AuthenticatedByBearer(r.Context()) {
+		return ""
+	}
+
+	sameOrigin := false
+	if origin := r.Header.Get("Origin"); origin != "" {
+		if !p.trustedOrigins[normalizeOrigin(origin)] {
+			return "untrusted origin"
+		}
+		sameOrigin = true
+	} else {
+		switch r.Header.Get("Sec-Fetch-Site") {
+		case "same-origin":
+			sameOrigin = true
+		case "same-site", "cross-site":
+			return "cross-site request"
+		}
+	}
+
+	if userSession == nil || (sameOrigin && !p.requireToken) {
+		return ""
+	}
+
+	token := r.Header.Get(CSRFHeader)
+	if token == "" {
+		token = r.PostFormValue(CSRFFormField)
+	}
+	if token == "" {
+		return "missing CSRF token"
+	}
+	if !hmac.Equal([]byte(token), []byte(p.Token(userSession))) {
+		return "invalid CSRF token"
+	}
+	return ""
+}
+
+// CSRF returns the CSRF protection
+func (h *Handler) CSRF() *CSRFProtection {
+	if h.csrf == nil {
+		h.csrf = NewCSRFProtection(&h.config.CSRF, h.config.RedirectURL, h.config.ClientSecret)
+	}
+	return h.csrf
+}
+
+// CSRFMiddleware rejects cross-site state-changing requests. It uses the
+// session set by the auth middleware and otherwise reads the session cookie,
+// so it also protects endpoints that run without the auth middleware.
+func (h *Handler) CSRFMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		protection := h.CSRF()
+		if !protection.enabled || isSafeMethod(c.Request.Method) {
+			c.Next()
+			return
+		}
+
+		userSession := GetSessionFromContext(c.Request.Context())
+		if userSession == nil {
+			if sessionID, _, err := h.cookies.Read(c.Request); err == nil {
+				userSession, _ = h.userSessions.Get(c.Request.Context(), sessionID)
+			}
+		}
+
+		if reason := protection.Check(c.Request, userSession); reason != "" {
+			h.logger.Warn("Rejected cross-site request",
+				zap.String("reason", reason),
+				zap.String("method", c.Request.Method),
+				zap.String("path", c.Request.URL.Path),
+				zap.String("origin", c.Request.Header.Get("Origin")),
+			)
+			h.Pages().Respond(c, http.StatusForbidden, pages.AccessDenied, "Cross-site request rejected", nil)
+			return
+		}
+		c.Next()
+	}
+}
+
+// isSafeMethod reports whether a method does not change state
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return false
+}
+
+// normalizeOrigin returns the scheme://host[:port] of a URL in lower case,
+// without default ports, or "" when it has none
+func normalizeOrigin(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return ""
+	}
+	scheme := strings.ToLower(u.Scheme)
+	host := strings.ToLower(u.Hostname())
+	port := u.Port()
+	if port == "" || (scheme == "http" && port == "80") || (scheme == "https" && port == "443") {
+		if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+		return scheme + "://" + host
+	}
+	return scheme + "://" + net.JoinHostPort(host, port)
+}
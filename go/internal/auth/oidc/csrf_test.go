@@ -0,0 +1,202 @@
+package oidc
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/config"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/session/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestCSRFProtectionCheck(t *testing.T) {
+	protection := NewCSRFProtection(&config.CSRFConfig{
+		Enabled:        true,
+		TrustedOrigins: []string{"https://app.example.com"},
+	}, "https://proxy.example.com/callback", "secret")
+
+	userSession := &UserSession{ID: "alice", CreatedAt: time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)}
+	token := protection.Token(userSession)
+
+	tests := []struct {
+		name        string
+		method      string
+		headers     map[string]string
+		form        url.Values
+		userSession *UserSession
+		bearer      bool
+		want        string
+	}{
+		{name: "safe method", method: http.MethodGet, headers: map[string]string{"Sec-Fetch-Site": "cross-site"}, userSession: userSession},
+		{name: "cross-site form post", method: http.MethodPost, headers: map[string]string{"Origin": "https://evil.com", "Sec-Fetch-Site": "cross-site"}, userSession: userSession, want: "untrusted origin"},
+		{name: "cross-site post with a valid token", method: http.MethodPost, headers: map[string]string{"Origin": "https://evil.com", CSRFHeader: token}, userSession: userSession, want: "untrusted origin"},
+		{name: "opaque origin", method: http.MethodPost, headers: map[string]string{"Origin": "null"}, userSession: userSession, want: "untrusted origin"},
+		{name: "cross-site fetch metadata", method: http.MethodDelete, headers: map[string]string{"Sec-Fetch-Site": "cross-site"}, userSession: userSession, want: "cross-site request"},
+		{name: "same-site fetch metadata", method: http.MethodPost, headers: map[string]string{"Sec-Fetch-Site": "same-site"}, userSession: userSession, want: "cross-site request"},
+		{name: "proxy origin", method: http.MethodPost, headers: map[string]string{"Origin": "https://Proxy.example.com:443"}, userSession: userSession},
+		{name: "trusted origin", method: http.MethodPost, headers: map[string]string{"Origin": "https://app.example.com"}, userSession: userSession},
+		{name: "same-origin fetch metadata", method: http.MethodPost, headers: map[string]string{"Sec-Fetch-Site": "same-origin"}, userSession: userSession},
+		{name: "no headers and no token", method: http.MethodPost, userSession: userSession, want: "missing CSRF token"},
+		{name: "token header", method: http.MethodPost, headers: map[string]string{CSRFHeader: token}, userSession: userSession},
+		{name: "token form field", method: http.MethodPost, form: url.Values{CSRFFormField: {token}}, userSession: userSession},
+		{name: "token of another login", method: http.MethodPost, headers: map[string]string{CSRFHeader: protection.Token(&UserSession{ID: "alice", CreatedAt: time.Now()})}, userSession: userSession, want: "invalid CSRF token"},
+		{name: "bearer client", method: http.MethodPost, headers: map[string]string{"Authorization": "Bearer token", "Sec-Fetch-Site": "cross-site"}, userSession: userSession, bearer: true},
+		{name: "bearer header next to a session cookie", method: http.MethodPost, headers: map[string]string{"Authorization": "Bearer token", "Sec-Fetch-Site": "cross-site"}, userSession: userSession, want: "cross-site request"},
+		{name: "no session", method: http.MethodPost},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body *strings.Reader
+			if tt.form != nil {
+				body = strings.NewReader(tt.form.Encode())
+			} else {
+				body = strings.NewReader("")
+			}
+			req := httptest.NewRequest(tt.method, "/logout", body)
+			if tt.form != nil {
+				req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			}
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			if tt.bearer {
+				req = req.WithContext(context.WithValue(req.Context(), bearerAuthContextKey{}, true))
+			}
+			assert.Equal(t, tt.want, protection.Check(req, tt.userSession))
+		})
+	}
+
+	t.Run("Token survives session ID rotation", func(t *testing.T) {
+		rotated := *userSession
+		rotated.RotatedAt = time.Now()
+		assert.Equal(t, token, protection.Token(&rotated))
+	})
+
+	t.Run("Token required for same-origin requests", func(t *testing.T) {
+		strict := NewCSRFProtection(&config.CSRFConfig{Enabled: true, RequireToken: true}, "https://proxy.example.com/callback", "secret")
+		req := httptest.NewRequest(http.MethodPost, "/logout", nil)
+		req.Header.Set("Origin", "https://proxy.example.com")
+		assert.Equal(t, "missing CSRF token", strict.Check(req, userSession))
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/logout", nil)
+		req.Header.Set("Origin", "https://evil.com")
+		assert.Empty(t, NewCSRFProtection(&config.CSRFConfig{}, "", "secret").Check(req, userSession))
+	})
+}
+
+func TestCSRFMiddlewareLogout(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	provider := newTestProvider(t)
+
+	store := memory.NewStore(&memory.Config{}, zap.NewNop())
+	defer store.Close()
+
+	handler, err := NewHandler(context.Background(), &config.OIDCConfig{
+		DiscoveryURL: provider.URL(),
+		ClientID:     "test-client",
+		ClientSecret: "test-secret",
+		RedirectURL:  "https://proxy.example.com/callback",
+		Scopes:       []string{"openid"},
+		CSRF:         config.CSRFConfig{Enabled: true},
+	}, &config.SessionConfig{}, store, zap.NewNop())
+	require.NoError(t, err)
+
+	router := gin.New()
+	router.POST("/logout", handler.CSRFMiddleware(), handler.Logout)
+
+	_, err = store.Create(context.Background(), "user:alice", &UserSession{ID: "alice", CreatedAt: time.Now()}, time.Hour)
+	require.NoError(t, err)
+
+	logout := func(headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/logout", nil)
+		req.AddCookie(&http.Cookie{Name: "session_id", Value: "user:alice"})
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// A cross-site page auto-submitting a logout form
+	w := logout(map[string]string{"Origin": "https://evil.com", "Sec-Fetch-Site": "cross-site", "Sec-Fetch-Mode": "navigate"})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "text/html")
+	assert.True(t, sessionExists(t, store, "user:alice"))
+
+	// A bearer header does not exempt the cookie-authenticated logout
+	w = logout(map[string]string{"Authorization": "Bearer token", "Sec-Fetch-Site": "cross-site"})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.True(t, sessionExists(t, store, "user:alice"))
+
+	w = logout(nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.JSONEq(t, `{"error":"Cross-site request rejected"}`, w.Body.String())
+	assert.True(t, sessionExists(t, store, "user:alice"))
+
+	w = logout(map[string]string{"Origin": "https://proxy.example.com"})
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.False(t, sessionExists(t, store, "user:alice"))
+}
+
+func TestCSRFMiddlewareBearerWithCookie(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	provider := newTestProvider(t)
+
+	store := memory.NewStore(&memory.Config{}, zap.NewNop())
+	defer store.Close()
+
+	handler, err := NewHandler(context.Background(), &config.OIDCConfig{
+		DiscoveryURL: provider.URL(),
+		ClientID:     "test-client",
+		ClientSecret: "test-secret",
+		RedirectURL:  "https://proxy.example.com/callback",
+		Scopes:       []string{"openid"},
+		CSRF:         config.CSRFConfig{Enabled: true},
+	}, &config.SessionConfig{}, store, zap.NewNop())
+	require.NoError(t, err)
+
+	router := gin.New()
+	router.POST("/api", AuthMiddlewareWithConfig(store, zap.NewNop(), &MiddlewareConfig{
+		Bearer:  &stubBearer{session: &UserSession{ID: "api-user", ExpiresAt: time.Now().Add(time.Hour)}},
+		Cookies: handler.Cookies(),
+		Codec:   handler.Codec(),
+	}), handler.CSRFMiddleware(), func(c *gin.Context) {
+		c.String(http.StatusOK, c.GetString("user_id"))
+	})
+
+	_, err = store.Create(context.Background(), "user:alice", &UserSession{ID: "alice", CreatedAt: time.Now(), ExpiresAt: time.Now().Add(time.Hour)}, time.Hour)
+	require.NoError(t, err)
+
+	post := func(cookie bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api", nil)
+		req.Header.Set("Authorization", "Bearer token")
+		req.Header.Set("Sec-Fetch-Site", "cross-site")
+		if cookie {
+			req.AddCookie(&http.Cookie{Name: "session_id", Value: "user:alice"})
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// The cookie authenticates the request, so the bearer header does not
+	// exempt it from the CSRF check
+	w := post(true)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = post(false)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "api-user", w.Body.String())
+}
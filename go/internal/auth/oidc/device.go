@@ -0,0 +1,628 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/auth/pages"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/session"
+	"go.uber.org/zap"
+)
+
+// Device authorization endpoints (RFC 8628)
+const (
+	DevicePath       = "/device"
+	DeviceVerifyPath = "/device/verify"
+	DeviceTokenPath  = "/device/token"
+)
+
+// DeviceCodeGrantType is the grant_type of device access token requests
+const DeviceCodeGrantType = "urn:ietf:params:oauth:grant-type:device_code"
+
+// DeviceTokenPrefix marks the bearer credentials issued to devices
+const DeviceTokenPrefix = "mcpd_"
+
+// IsDeviceCredential reports whether the request carries a device credential
+// as its bearer token. Device credentials authenticate to the proxy only and
+// must not be forwarded upstream.
+func IsDeviceCredential(r *http.Request) bool {
+	return strings.HasPrefix(bearerToken(r), DeviceTokenPrefix)
+}
+
+// Defaults used when the device configuration leaves them empty
+const (
+	DefaultDeviceCodeTTL  = 10 * time.Minute
+	DefaultDeviceInterval = 5 * time.Second
+)
+
+// User codes use consonants only, which avoids words and look-alike
+// characters (RFC 8628 section 6.1). 20^8 codes make guessing one within its
+// lifetime impractical.
+const (
+	userCodeAlphabet = "BCDFGHJKLMNPQRSTVWXZ"
+	userCodeLength   = 8
+)
+
+// slowDownStep is added to the polling interval of a client that polls too often
+const slowDownStep = 5 * time.Second
+
+// Device authorization states
+const (
+	devicePending  = "pending"
+	deviceApproved = "approved"
+	deviceDenied   = "denied"
+)
+
+// DeviceAuthorization is the state of a device login, stored under its device code
+type DeviceAuthorization struct {
+	DeviceCode   string        `json:"device_code"`
+	UserCode     string        `json:"user_code"`
+	ClientID     string        `json:"client_id,omitempty"`
+	Status       string        `json:"status"`
+	Interval     time.Duration `json:"interval"`
+	LastPolledAt time.Time     `json:"last_polled_at"`
+	ExpiresAt    time.Time     `json:"expires_at"`
+	// Session is the approving user's session, copied to the device on its next poll
+	Session *UserSession `json:"session,omitempty"`
+	// StepUp is the login the approving user must meet, from the acr_values
+	// and max_age of a device retrying a step-up challenge (RFC 9470)
+	StepUp *StepUpRequirement `json:"step_up,omitempty"`
+}
+
+// DeviceUserCode points a user code at its device authorization
+type DeviceUserCode struct {
+	DeviceCode string `json:"device_code"`
+}
+
+// DeviceCredential binds a device bearer credential to the device's session
+type DeviceCredential struct {
+	SessionID string `json:"session_id"`
+}
+
+// deviceVerifyPage lets an authenticated user enter a user code and approve
+// or deny the device
+var deviceVerifyPage = template.Must(template.New("device").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Device sign-in</title>
+</head>
+<body>
+<h1>Device sign-in</h1>
+{{if .Message}}<p>{{.Message}}</p>
+{{end}}{{if .Confirm}}<p>Signed in as {{.User}}. Allow the device showing <strong>{{.UserCode}}</strong>{{if .ClientID}} ({{.ClientID}}){{end}} to access the proxy as you?</p>
+<form method="post" action="{{.Action}}">
+<input type="hidden" name="user_code" value="{{.UserCode}}">
+<input type="hidden" name="csrf_token" value="{{.CSRFToken}}">
+<button type="submit" name="action" value="approve">Approve</button>
+<button type="submit" name="action" value="deny">Deny</button>
+</form>
+{{else if not .Done}}<form method="get" action="{{.Action}}">
+<label>Code shown on your device <input name="user_code" value="{{.UserCode}}" autocomplete="off" autofocus></label>
+<button type="submit">Continue</button>
+</form>
+{{end}}</body>
+</html>
+`))
+
+// deviceCodeTTL returns how long device and user codes are valid
+func (h *Handler) deviceCodeTTL() time.Duration {
+	if h.config.Device.CodeTTL > 0 {
+		return h.config.Device.CodeTTL
+	}
+	return DefaultDeviceCodeTTL
+}
+
+// deviceInterval returns the minimum polling interval
+func (h *Handler) deviceInterval() time.Duration {
+	if h.config.Device.Interval > 0 {
+		return h.config.Device.Interval
+	}
+	return DefaultDeviceInterval
+}
+
+// deviceTokenTTL returns the lifetime of device credentials; zero means they
+// last as long as the provider's tokens
+func (h *Handler) deviceTokenTTL() time.Duration {
+	if h.config.Device.TokenTTL > 0 {
+		return h.config.Device.TokenTTL
+	}
+	return h.lifetime.Absolute
+}
+
+// DeviceAuthorization starts a device login. It issues the device code the
+// client polls with and the user code the user enters at the verification page.
+func (h *Handler) DeviceAuthorization(c *gin.Context) {
+	ctx := c.Request.Context()
+	c.Header("Cache-Control", "no-store")
+
+	stepUp, err := requirementFromParams(c.PostForm("acr_values"), c.PostForm("max_age"))
+	if err != nil {
+		oauthError(c, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+
+	deviceCode, err := generateRandomString(32)
+	if err != nil {
+		h.logger.Error("Failed to generate device code", zap.Error(err))
+		oauthError(c, http.StatusInternalServerError, "server_error", "Failed to generate device code")
+		return
+	}
+	userCode, err := h.newUserCode(ctx)
+	if err != nil {
+		h.logger.Error("Failed to generate user code", zap.Error(err))
+		oauthError(c, http.StatusInternalServerError, "server_error", "Failed to generate user code")
+		return
+	}
+
+	ttl := h.deviceCodeTTL()
+	authorization := &DeviceAuthorization{
+		DeviceCode: deviceCode,
+		UserCode:   userCode,
+		ClientID:   c.PostForm("client_id"),
+		Status:     devicePending,
+		Interval:   h.deviceInterval(),
+		ExpiresAt:  time.Now().Add(ttl),
+		StepUp:     stepUp,
+	}
+	if _, err := h.deviceAuths.Create(ctx, deviceKey(deviceCode), authorization, ttl); err != nil {
+		h.logger.Error("Failed to create device authorization", zap.Error(err))
+		oauthError(c, http.StatusInternalServerError, "server_error", "Failed to create device authorization")
+		return
+	}
+	if _, err := h.deviceUserCodes.Create(ctx, userCodeKey(userCode), &DeviceUserCode{DeviceCode: deviceCode}, ttl); err != nil {
+		h.logger.Error("Failed to store user code", zap.Error(err))
+		oauthError(c, http.StatusInternalServerError, "server_error", "Failed to create device authorization")
+		return
+	}
+
+	verificationURI := h.publicURL(DeviceVerifyPath)
+	c.JSON(http.StatusOK, gin.H{
+		"device_code":               deviceCode,
+		"user_code":                 formatUserCode(userCode),
+		"verification_uri":          verificationURI,
+		"verification_uri_complete": verificationURI + "?user_code=" + url.QueryEscape(formatUserCode(userCode)),
+		"expires_in":                int(ttl / time.Second),
+		"interval":                  int(authorization.Interval / time.Second),
+	})
+}
+
+// DeviceVerify shows the verification page. It runs behind the authentication
+// middleware, so the user has logged in through the normal flow.
+func (h *Handler) DeviceVerify(c *gin.Context) {
+	data := gin.H{"Action": DeviceVerifyPath}
+
+	raw := c.Query("user_code")
+	if raw == "" {
+		h.renderDevicePage(c, http.StatusOK, data)
+		return
+	}
+
+	data["UserCode"] = raw
+	authorization, err := h.pendingDeviceAuthorization(c.Request.Context(), raw)
+	if err != nil {
+		data["Message"] = "The code is invalid or has expired. Check the code shown on your device."
+		h.renderDevicePage(c, http.StatusBadRequest, data)
+		return
+	}
+
+	// The user signs in again when the device asks for a stronger login
+	userSession := GetSessionFromContext(c.Request.Context())
+	if authorization.StepUp != nil && authorization.StepUp.Unmet(userSession.Authentication, time.Now()) != "" {
+		c.Redirect(http.StatusFound, deviceStepUpURL(authorization))
+		return
+	}
+
+	data["Confirm"] = true
+	data["UserCode"] = formatUserCode(authorization.UserCode)
+	data["ClientID"] = authorization.ClientID
+	data["User"] = displayName(userSession)
+	data["CSRFToken"] = h.CSRF().Token(userSession)
+	h.renderDevicePage(c, http.StatusOK, data)
+}
+
+// DeviceApprove records the user's decision for a user code. The user's
+// session is copied to the device when it next polls.
+func (h *Handler) DeviceApprove(c *gin.Context) {
+	ctx := c.Request.Context()
+	data := gin.H{"Action": DeviceVerifyPath, "Done": true}
+
+	userSession := GetSessionFromContext(ctx)
+	if userSession == nil {
+		h.Pages().Respond(c, http.StatusUnauthorized, pages.LoginRequired, "Authentication required", nil)
+		return
+	}
+
+	raw := c.PostForm("user_code")
+	authorization, err := h.pendingDeviceAuthorization(ctx, raw)
+	if err != nil {
+		data["Done"] = false
+		data["UserCode"] = raw
+		data["Message"] = "The code is invalid or has expired. Check the code shown on your device."
+		h.renderDevicePage(c, http.StatusBadRequest, data)
+		return
+	}
+
+	switch c.PostForm("action") {
+	case "approve":
+		if authorization.StepUp != nil && authorization.StepUp.Unmet(userSession.Authentication, time.Now()) != "" {
+			data["Done"] = false
+			data["UserCode"] = formatUserCode(authorization.UserCode)
+			data["Message"] = "The device asks for a stronger or more recent sign-in. Continue to sign in again."
+			h.renderDevicePage(c, http.StatusForbidden, data)
+			return
+		}
+		approved := *userSession
+		authorization.Status = deviceApproved
+		authorization.Session = &approved
+		data["Message"] = "Device approved. You can return to your device."
+	case "deny":
+		authorization.Status = deviceDenied
+		data["Message"] = "Device denied."
+	default:
+		data["Done"] = false
+		data["Confirm"] = true
+		data["UserCode"] = formatUserCode(authorization.UserCode)
+		data["User"] = displayName(userSession)
+		data["CSRFToken"] = h.CSRF().Token(userSession)
+		data["Message"] = "Choose whether to approve the device."
+		h.renderDevicePage(c, http.StatusBadRequest, data)
+		return
+	}
+
+	if err := h.deviceAuths.Update(ctx, deviceKey(authorization.DeviceCode), authorization); err != nil {
+		h.logger.Error("Failed to record device decision", zap.Error(err))
+		h.Pages().Respond(c, http.StatusInternalServerError, pages.Error, "Failed to record the decision", nil)
+		return
+	}
+	// A user code is used once
+	if err := h.sessionStore.Delete(ctx, userCodeKey(authorization.UserCode)); err != nil {
+		h.logger.Warn("Failed to delete user code", zap.Error(err))
+	}
+
+	h.logger.Info("Device authorization decided",
+		zap.String("user_id", userSession.ID),
+		zap.String("client_id", authorization.ClientID),
+		zap.String("status", authorization.Status),
+	)
+	h.renderDevicePage(c, http.StatusOK, data)
+}
+
+// DeviceToken is polled by the device. Once the user has approved it returns
+// a bearer credential bound to a new session for the device.
+func (h *Handler) DeviceToken(c *gin.Context) {
+	ctx := c.Request.Context()
+	c.Header("Cache-Control", "no-store")
+
+	if c.PostForm("grant_type") != DeviceCodeGrantType {
+		oauthError(c, http.StatusBadRequest, "unsupported_grant_type", "grant_type must be "+DeviceCodeGrantType)
+		return
+	}
+	deviceCode := c.PostForm("device_code")
+	if deviceCode == "" {
+		oauthError(c, http.StatusBadRequest, "invalid_request", "device_code is required")
+		return
+	}
+
+	authorization, err := h.deviceAuths.Get(ctx, deviceKey(deviceCode))
+	if err != nil || time.Now().After(authorization.ExpiresAt) {
+		oauthError(c, http.StatusBadRequest, "expired_token", "The device code is unknown or has expired")
+		return
+	}
+
+	now := time.Now()
+	tooSoon := now.Sub(authorization.LastPolledAt) < authorization.Interval
+	authorization.LastPolledAt = now
+	if tooSoon {
+		authorization.Interval += slowDownStep
+	}
+
+	if tooSoon || authorization.Status == devicePending {
+		if err := h.deviceAuths.Update(ctx, deviceKey(deviceCode), authorization); err != nil {
+			h.logger.Warn("Failed to record device poll", zap.Error(err))
+		}
+		if tooSoon {
+			oauthError(c, http.StatusBadRequest, "slow_down", "Polling too often")
+		} else {
+			oauthError(c, http.StatusBadRequest, "authorization_pending", "The user has not yet approved the device")
+		}
+		return
+	}
+
+	// Approved and denied authorizations end with this response
+	if err := h.sessionStore.Delete(ctx, deviceKey(deviceCode)); err != nil {
+		h.logger.Warn("Failed to delete device authorization", zap.Error(err))
+	}
+	if authorization.Status != deviceApproved || authorization.Session == nil {
+		oauthError(c, http.StatusBadRequest, "access_denied", "The user denied the device")
+		return
+	}
+
+	token, expiresIn, err := h.issueDeviceCredential(c, authorization.Session)
+	if err != nil {
+		h.logger.Error("Failed to issue device credential", zap.Error(err))
+		oauthError(c, http.StatusInternalServerError, "server_error", "Failed to issue credential")
+		return
+	}
+
+	h.logger.Info("Device authenticated",
+		zap.String("user_id", authorization.Session.ID),
+		zap.String("client_id", authorization.ClientID),
+	)
+
+	response := gin.H{
+		"access_token": token,
+		"token_type":   "Bearer",
+	}
+	if expiresIn > 0 {
+		response["expires_in"] = int(expiresIn / time.Second)
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// issueDeviceCredential stores a copy of the approving session under a new ID
+// for the device and returns a bearer credential bound to it. The device
+// session is indexed like a browser session, so it is listed, limited and
+// ended by back-channel logout with the user's other sessions. It gets no
+// refresh token: two sessions refreshing with one token break each other
+// with providers that rotate refresh tokens, so the device renews its access
+// token from the approving session instead (see renewFromGrant).
+func (h *Handler) issueDeviceCredential(c *gin.Context, approved *UserSession) (string, time.Duration, error) {
+	ctx := c.Request.Context()
+	now := time.Now()
+
+	userSession := *approved
+	userSession.CreatedAt = now
+	userSession.LastActivityAt = now
+	userSession.RotatedAt = now
+	userSession.ReplacedBy = ""
+	userSession.RefreshToken = ""
+	userSession.PrivilegeHash = privilegeHash(&userSession)
+	userSession.AbsoluteExpiresAt = time.Time{}
+
+	// The credential outlives the provider's access token, which is renewed
+	// from the approving session when the device uses it after expiry
+	ttl := h.deviceTokenTTL()
+	if ttl > 0 {
+		userSession.AbsoluteExpiresAt = now.Add(ttl)
+	}
+
+	issuer := h.issuer()
+	sessionID, err := issuer.create(c, &userSession, ttl, time.Time{})
+	if err != nil {
+		return "", 0, err
+	}
+
+	secret, err := generateRandomString(32)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to generate credential: %w", err)
+	}
+	token := DeviceTokenPrefix + secret
+	if _, err := h.deviceCredentials.Create(ctx, deviceCredentialKey(token), &DeviceCredential{SessionID: sessionID}, ttl); err != nil {
+		if err := h.sessionStore.Delete(ctx, sessionID); err != nil {
+			h.logger.Warn("Failed to delete device session", zap.Error(err))
+		}
+		return "", 0, fmt.Errorf("failed to store credential: %w", err)
+	}
+
+	issuer.enforceLimit(ctx, userSession.ID, sessionID)
+	return token, ttl, nil
+}
+
+// authenticateDeviceCredential returns the session a device credential is bound to
+func (h *Handler) authenticateDeviceCredential(ctx context.Context, token string) (*UserSession, error) {
+	credential, err := h.deviceCredentials.Get(ctx, deviceCredentialKey(token))
+	if err != nil {
+		return nil, fmt.Errorf("unknown device credential")
+	}
+	userSession, err := h.userSessions.Get(ctx, credential.SessionID)
+	if err != nil {
+		return nil, fmt.Errorf("device session not found: %w", err)
+	}
+
+	now := time.Now()
+	if !userSession.AbsoluteExpiresAt.IsZero() && !now.Before(userSession.AbsoluteExpiresAt) {
+		return nil, fmt.Errorf("device session expired")
+	}
+	if accessTokenExpired(userSession, now) {
+		err := h.renewFromGrant(ctx, credential.SessionID, userSession)
+		if errors.Is(err, errGrantEnded) {
+			return nil, err
+		}
+		if err != nil {
+			h.logger.Warn("Failed to renew device access token", zap.Error(err), zap.String("user_id", userSession.ID))
+		}
+	}
+
+	// Record activity for session listings
+	if err := h.sessionStore.Touch(ctx, credential.SessionID, now); err != nil {
+		h.logger.Debug("Failed to record device session activity", zap.Error(err))
+	}
+	return userSession, nil
+}
+
+// errGrantEnded is returned for a device session whose approving login has
+// no session left to renew its access token from
+var errGrantEnded = errors.New("the approving session has ended")
+
+// renewFromGrant copies a fresh access token from the browser session holding
+// the device session's grant, refreshing that session first when needed, so
+// the device ends with the login it was approved from
+func (h *Handler) renewFromGrant(ctx context.Context, sessionID string, device *UserSession) error {
+	indexer, ok := h.sessionStore.(session.Indexer)
+	if !ok || device.GrantID == "" {
+		return errGrantEnded
+	}
+	keys, err := indexer.IndexedKeys(ctx, GrantIndex(device.GrantID))
+	if err != nil {
+		return fmt.Errorf("failed to look up the approving session: %w", err)
+	}
+
+	for _, key := range keys {
+		holder, err := h.userSessions.Get(ctx, key)
+		if err != nil || holder.ReplacedBy != "" || holder.RefreshToken == "" {
+			// Rotated-out sessions leave the grant to their successor
+			continue
+		}
+		if accessTokenExpired(holder, time.Now()) && !refreshAccessToken(ctx, h, &sessionRefreshes, h.userSessions, key, holder, h.logger) {
+			return fmt.Errorf("failed to refresh the approving session")
+		}
+
+		device.AccessToken = holder.AccessToken
+		device.IDToken = holder.IDToken
+		device.ExpiresAt = holder.ExpiresAt
+		device.Groups = holder.Groups
+		device.Roles = holder.Roles
+		device.Claims = holder.Claims
+		return h.userSessions.Update(ctx, sessionID, device)
+	}
+	return errGrantEnded
+}
+
+// deviceStepUpURL returns the login URL that meets a device's step-up
+// requirement and returns to its verification page
+func deviceStepUpURL(authorization *DeviceAuthorization) string {
+	query := url.Values{"redirect_uri": {DeviceVerifyPath + "?user_code=" + url.QueryEscape(formatUserCode(authorization.UserCode))}}
+	if len(authorization.StepUp.ACRValues) > 0 {
+		query.Set("acr_values", strings.Join(authorization.StepUp.ACRValues, " "))
+	}
+	if authorization.StepUp.MaxAge > 0 {
+		query.Set("max_age", strconv.Itoa(maxAgeSeconds(authorization.StepUp.MaxAge)))
+	}
+	return LoginPath + "?" + query.Encode()
+}
+
+// pendingDeviceAuthorization looks up the pending authorization of a user code
+func (h *Handler) pendingDeviceAuthorization(ctx context.Context, raw string) (*DeviceAuthorization, error) {
+	userCode, ok := normalizeUserCode(raw)
+	if !ok {
+		return nil, fmt.Errorf("malformed user code")
+	}
+	pointer, err := h.deviceUserCodes.Get(ctx, userCodeKey(userCode))
+	if err != nil {
+		return nil, err
+	}
+	authorization, err := h.deviceAuths.Get(ctx, deviceKey(pointer.DeviceCode))
+	if err != nil {
+		return nil, err
+	}
+	if authorization.Status != devicePending || time.Now().After(authorization.ExpiresAt) {
+		return nil, fmt.Errorf("device authorization is no longer pending")
+	}
+	return authorization, nil
+}
+
+// newUserCode returns a user code that is not in use
+func (h *Handler) newUserCode(ctx context.Context) (string, error) {
+	for attempt := 0; attempt < 3; attempt++ {
+		userCode, err := generateUserCode()
+		if err != nil {
+			return "", err
+		}
+		exists, err := h.sessionStore.Exists(ctx, userCodeKey(userCode))
+		if err != nil {
+			return "", err
+		}
+		if !exists {
+			return userCode, nil
+		}
+	}
+	return "", fmt.Errorf("no unused user code found")
+}
+
+// renderDevicePage writes the verification page
+func (h *Handler) renderDevicePage(c *gin.Context, status int, data gin.H) {
+	c.Header("Cache-Control", "no-store")
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Status(status)
+	if err := deviceVerifyPage.Execute(c.Writer, data); err != nil {
+		h.logger.Error("Failed to render device verification page", zap.Error(err))
+	}
+}
+
+// generateUserCode returns a random user code without separators
+func generateUserCode() (string, error) {
+	code := make([]byte, 0, userCodeLength)
+	buf := make([]byte, 1)
+	// Bytes at or above the largest multiple of the alphabet size are
+	// rejected so every character is equally likely
+	limit := byte(256 - 256%len(userCodeAlphabet))
+	for len(code) < userCodeLength {
+		if _, err := rand.Read(buf); err != nil {
+			return "", err
+		}
+		if buf[0] < limit {
+			code = append(code, userCodeAlphabet[int(buf[0])%len(userCodeAlphabet)])
+		}
+	}
+	return string(code), nil
+}
+
+// normalizeUserCode accepts user codes typed in any case, with or without
+// separators
+func normalizeUserCode(raw string) (string, bool) {
+	code := strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, strings.ToUpper(raw))
+
+	if len(code) != userCodeLength || strings.Trim(code, userCodeAlphabet) != "" {
+		return "", false
+	}
+	return code, true
+}
+
+// formatUserCode splits a user code in two halves for readability
+func formatUserCode(code string) string {
+	return code[:len(code)/2] + "-" + code[len(code)/2:]
+}
+
+// displayName returns how the verification page refers to the user
+func displayName(userSession *UserSession) string {
+	if userSession == nil {
+		return ""
+	}
+	if userSession.Email != "" {
+		return userSession.Email
+	}
+	return userSession.ID
+}
+
+// deviceKey returns the store key of a device authorization
+func deviceKey(deviceCode string) string {
+	return "device:" + deviceCode
+}
+
+// userCodeKey returns the store key of a user code
+func userCodeKey(userCode string) string {
+	return "device_user:" + userCode
+}
+
+// deviceCredentialKey returns the store key of a device credential. Only a
+// hash is stored so the store contents cannot be replayed as credentials.
+func deviceCredentialKey(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return "device_token:" + hex.EncodeToString(sum[:])
+}
+
+// oauthError writes an OAuth 2.0 error response
+func oauthError(c *gin.Context, status int, code, description string) {
+	c.JSON(status, gin.H{
+		"error":             code,
+		"error_description": description,
+	})
+}
@@ -0,0 +1,394 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/config"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/session/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestNormalizeUserCode(t *testing.T) {
+	code, err := generateUserCode()
+	require.NoError(t, err)
+	assert.Len(t, code, userCodeLength)
+
+	tests := []struct {
+		raw  string
+		want string
+		ok   bool
+	}{
+		{raw: formatUserCode(code), want: code, ok: true},
+		{raw: strings.ToLower(code), want: code, ok: true},
+		{raw: "bcdf ghjk", want: "BCDFGHJK", ok: true},
+		{raw: "BCDF-GHJ"},
+		{raw: "BCDF-GHJA"},
+		{raw: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			got, ok := normalizeUserCode(tt.raw)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestDeviceAuthorizationGrant(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	provider := newTestProvider(t)
+
+	store := memory.NewStore(&memory.Config{}, zap.NewNop())
+	defer store.Close()
+
+	newRouter := func(device config.DeviceConfig) (*gin.Engine, *Handler) {
+		handler, err := NewHandler(context.Background(), &config.OIDCConfig{
+			DiscoveryURL: provider.URL(),
+			ClientID:     "test-client",
+			ClientSecret: "test-secret",
+			RedirectURL:  "https://proxy.example.com/callback",
+			Scopes:       []string{"openid"},
+			Device:       device,
+			CSRF:         config.CSRFConfig{Enabled: true},
+		}, &config.SessionConfig{}, store, zap.NewNop())
+		require.NoError(t, err)
+
+		authMiddleware := AuthMiddlewareWithConfig(store, zap.NewNop(), &MiddlewareConfig{
+			Bearer:   handler,
+			Lifetime: handler.Lifetime(),
+			Cookies:  handler.Cookies(),
+			Codec:    handler.Codec(),
+		})
+
+		router := gin.New()
+		router.GET("/login", handler.Authorize)
+		router.GET("/callback", handler.Callback)
+		router.POST(DevicePath, handler.DeviceAuthorization)
+		router.POST(DeviceTokenPath, handler.DeviceToken)
+		router.GET(DeviceVerifyPath, authMiddleware, handler.DeviceVerify)
+		router.POST(DeviceVerifyPath, authMiddleware, handler.CSRFMiddleware(), handler.DeviceApprove)
+		router.GET("/api", authMiddleware, func(c *gin.Context) {
+			c.String(http.StatusOK, c.GetString("user_id"))
+		})
+		return router, handler
+	}
+
+	post := func(router *gin.Engine, path string, form url.Values, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Origin", "https://proxy.example.com")
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	start := func(router *gin.Engine) (deviceCode, userCode string) {
+		w := post(router, DevicePath, url.Values{"client_id": {"cli"}})
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var response map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, "https://proxy.example.com/device/verify", response["verification_uri"])
+		assert.Contains(t, response["verification_uri_complete"], "user_code=")
+		return response["device_code"].(string), response["user_code"].(string)
+	}
+
+	poll := func(router *gin.Engine, deviceCode string) (int, map[string]interface{}) {
+		w := post(router, DeviceTokenPath, url.Values{"grant_type": {DeviceCodeGrantType}, "device_code": {deviceCode}})
+		var response map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		return w.Code, response
+	}
+
+	t.Run("Approved device gets a credential bound to a new session", func(t *testing.T) {
+		router, _ := newRouter(config.DeviceConfig{Interval: time.Millisecond})
+		deviceCode, userCode := start(router)
+
+		status, response := poll(router, deviceCode)
+		assert.Equal(t, http.StatusBadRequest, status)
+		assert.Equal(t, "authorization_pending", response["error"])
+
+		cookie := &http.Cookie{Name: "session_id", Value: login(t, provider, router, "alice")}
+
+		req := httptest.NewRequest(http.MethodGet, DeviceVerifyPath+"?user_code="+strings.ToLower(userCode), nil)
+		req.AddCookie(cookie)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), userCode)
+		assert.Contains(t, w.Body.String(), `value="approve"`)
+		assert.Contains(t, w.Body.String(), `name="csrf_token"`)
+
+		w = post(router, DeviceVerifyPath, url.Values{"user_code": {userCode}, "action": {"approve"}}, cookie)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "Device approved")
+
+		// A user code is used once
+		w = post(router, DeviceVerifyPath, url.Values{"user_code": {userCode}, "action": {"approve"}}, cookie)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		time.Sleep(2 * time.Millisecond)
+		status, response = poll(router, deviceCode)
+		require.Equal(t, http.StatusOK, status, response)
+		assert.Equal(t, "Bearer", response["token_type"])
+		token := response["access_token"].(string)
+		assert.True(t, strings.HasPrefix(token, DeviceTokenPrefix))
+
+		// The device code is used once
+		status, response = poll(router, deviceCode)
+		assert.Equal(t, http.StatusBadRequest, status)
+		assert.Equal(t, "expired_token", response["error"])
+
+		req = httptest.NewRequest(http.MethodGet, "/api", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "alice", w.Body.String())
+
+		// The device session is listed next to the browser session
+		infos, err := store.ListUserSessions(context.Background(), "alice")
+		require.NoError(t, err)
+		assert.Len(t, infos, 2)
+
+		// Ending the device session revokes the credential
+		for _, info := range infos {
+			if info.Key != cookie.Value {
+				require.NoError(t, store.Delete(context.Background(), info.Key))
+			}
+		}
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("Credential outlives the provider access token", func(t *testing.T) {
+		router, handler := newRouter(config.DeviceConfig{Interval: time.Millisecond, TokenTTL: 720 * time.Hour})
+		deviceCode, userCode := start(router)
+
+		cookie := &http.Cookie{Name: "session_id", Value: login(t, provider, router, "dave")}
+		w := post(router, DeviceVerifyPath, url.Values{"user_code": {userCode}, "action": {"approve"}}, cookie)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		time.Sleep(2 * time.Millisecond)
+		status, response := poll(router, deviceCode)
+		require.Equal(t, http.StatusOK, status, response)
+		assert.Equal(t, float64(720*time.Hour/time.Second), response["expires_in"], "not capped by the access token expiry")
+		token := response["access_token"].(string)
+
+		// The device does not share the browser session's refresh token
+		ctx := context.Background()
+		credential, err := handler.deviceCredentials.Get(ctx, deviceCredentialKey(token))
+		require.NoError(t, err)
+		deviceSession, err := handler.userSessions.Get(ctx, credential.SessionID)
+		require.NoError(t, err)
+		assert.Empty(t, deviceSession.RefreshToken)
+
+		// Let the access tokens of both sessions expire
+		expire := func(sessionID string) {
+			userSession, err := handler.userSessions.Get(ctx, sessionID)
+			require.NoError(t, err)
+			userSession.ExpiresAt = time.Now().Add(-time.Minute)
+			require.NoError(t, handler.userSessions.Update(ctx, sessionID, userSession))
+		}
+		expire(credential.SessionID)
+		expire(cookie.Value)
+
+		refreshes := 0
+		provider.handlers["/token"] = func(w http.ResponseWriter, r *http.Request) {
+			require.NoError(t, r.ParseForm())
+			assert.Equal(t, "refresh-dave", r.PostForm.Get("refresh_token"))
+			refreshes++
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"access_token":  "access-dave-2",
+				"refresh_token": "refresh-dave-2",
+				"token_type":    "Bearer",
+				"expires_in":    3600,
+			})
+		}
+
+		req := httptest.NewRequest(http.MethodGet, "/api", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "dave", w.Body.String())
+
+		// The browser session was refreshed once and the device copied its token
+		assert.Equal(t, 1, refreshes)
+		browserSession, err := handler.userSessions.Get(ctx, cookie.Value)
+		require.NoError(t, err)
+		assert.Equal(t, "refresh-dave-2", browserSession.RefreshToken)
+		deviceSession, err = handler.userSessions.Get(ctx, credential.SessionID)
+		require.NoError(t, err)
+		assert.Equal(t, "access-dave-2", deviceSession.AccessToken)
+		assert.Empty(t, deviceSession.RefreshToken)
+
+		// Once the browser session has ended, the device cannot renew
+		require.NoError(t, store.Delete(ctx, cookie.Value))
+		expire(credential.SessionID)
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("A device retrying a step-up challenge needs a login that meets it", func(t *testing.T) {
+		router, handler := newRouter(config.DeviceConfig{Interval: time.Millisecond})
+
+		w := post(router, DevicePath, url.Values{"client_id": {"cli"}, "max_age": {"-1"}})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "invalid_request")
+
+		w = post(router, DevicePath, url.Values{"client_id": {"cli"}, "acr_values": {"mfa"}, "max_age": {"300"}})
+		require.Equal(t, http.StatusOK, w.Code)
+		var started map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &started))
+		deviceCode, userCode := started["device_code"].(string), started["user_code"].(string)
+
+		verify := func(cookie *http.Cookie) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(http.MethodGet, DeviceVerifyPath+"?user_code="+userCode, nil)
+			req.AddCookie(cookie)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			return w
+		}
+
+		// A password login is sent to sign in again and cannot approve
+		cookie := &http.Cookie{Name: "session_id", Value: login(t, provider, router, "alice")}
+		w = verify(cookie)
+		require.Equal(t, http.StatusFound, w.Code)
+		loginURL, err := url.Parse(w.Header().Get("Location"))
+		require.NoError(t, err)
+		assert.Equal(t, LoginPath, loginURL.Path)
+		assert.Equal(t, "mfa", loginURL.Query().Get("acr_values"))
+		assert.Equal(t, "300", loginURL.Query().Get("max_age"))
+		assert.Equal(t, DeviceVerifyPath+"?user_code="+userCode, loginURL.Query().Get("redirect_uri"))
+
+		w = post(router, DeviceVerifyPath, url.Values{"user_code": {userCode}, "action": {"approve"}}, cookie)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		_, response := poll(router, deviceCode)
+		assert.Equal(t, "authorization_pending", response["error"])
+
+		// The step-up login asks the provider for the device's requirement
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, loginURL.String(), nil))
+		require.Equal(t, http.StatusFound, w.Code)
+		authorize, err := url.Parse(w.Header().Get("Location"))
+		require.NoError(t, err)
+		assert.Equal(t, "login", authorize.Query().Get("prompt"))
+		assert.Equal(t, "mfa", authorize.Query().Get("acr_values"))
+		assert.Equal(t, "300", authorize.Query().Get("max_age"))
+
+		provider.handlers["/token"] = func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"access_token":  "access-alice",
+				"refresh_token": "refresh-alice",
+				"token_type":    "Bearer",
+				"expires_in":    3600,
+				"id_token":      provider.sign(t, map[string]interface{}{"aud": "test-client", "sub": "alice", "acr": "mfa", "auth_time": time.Now().Unix()}),
+			})
+		}
+		req := httptest.NewRequest(http.MethodGet, "/callback?code=test-code&state="+authorize.Query().Get("state"), nil)
+		req.AddCookie(cookie)
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusFound, w.Code, w.Body.String())
+		assert.Equal(t, DeviceVerifyPath+"?user_code="+userCode, w.Header().Get("Location"))
+		for _, c := range w.Result().Cookies() {
+			if c.Name == "session_id" {
+				cookie = c
+			}
+		}
+
+		w = verify(cookie)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `value="approve"`)
+		w = post(router, DeviceVerifyPath, url.Values{"user_code": {userCode}, "action": {"approve"}}, cookie)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		// The device session carries the stepped-up login
+		time.Sleep(2 * time.Millisecond)
+		status, response := poll(router, deviceCode)
+		require.Equal(t, http.StatusOK, status, response)
+		credential, err := handler.deviceCredentials.Get(context.Background(), deviceCredentialKey(response["access_token"].(string)))
+		require.NoError(t, err)
+		deviceSession, err := handler.userSessions.Get(context.Background(), credential.SessionID)
+		require.NoError(t, err)
+		assert.Equal(t, "mfa", deviceSession.Authentication.ACR)
+	})
+
+	t.Run("Denied device gets access_denied", func(t *testing.T) {
+		router, _ := newRouter(config.DeviceConfig{Interval: time.Millisecond})
+		deviceCode, userCode := start(router)
+
+		cookie := &http.Cookie{Name: "session_id", Value: login(t, provider, router, "bob")}
+		w := post(router, DeviceVerifyPath, url.Values{"user_code": {userCode}, "action": {"deny"}}, cookie)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		status, response := poll(router, deviceCode)
+		assert.Equal(t, http.StatusBadRequest, status)
+		assert.Equal(t, "access_denied", response["error"])
+	})
+
+	t.Run("Polling too often slows the device down", func(t *testing.T) {
+		router, handler := newRouter(config.DeviceConfig{Interval: time.Hour})
+		deviceCode, _ := start(router)
+
+		_, response := poll(router, deviceCode)
+		assert.Equal(t, "authorization_pending", response["error"])
+		_, response = poll(router, deviceCode)
+		assert.Equal(t, "slow_down", response["error"])
+
+		authorization, err := handler.deviceAuths.Get(context.Background(), deviceKey(deviceCode))
+		require.NoError(t, err)
+		assert.Equal(t, time.Hour+slowDownStep, authorization.Interval)
+	})
+
+	t.Run("Cross-site approval is rejected", func(t *testing.T) {
+		router, _ := newRouter(config.DeviceConfig{})
+		deviceCode, userCode := start(router)
+
+		cookie := &http.Cookie{Name: "session_id", Value: login(t, provider, router, "mallory")}
+		req := httptest.NewRequest(http.MethodPost, DeviceVerifyPath, strings.NewReader(url.Values{"user_code": {userCode}, "action": {"approve"}}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Origin", "https://evil.com")
+		req.AddCookie(cookie)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+
+		_, response := poll(router, deviceCode)
+		assert.Equal(t, "authorization_pending", response["error"])
+	})
+
+	t.Run("Verification requires login", func(t *testing.T) {
+		router, _ := newRouter(config.DeviceConfig{})
+		_, userCode := start(router)
+
+		w := post(router, DeviceVerifyPath, url.Values{"user_code": {userCode}, "action": {"approve"}})
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("Other grant types are rejected", func(t *testing.T) {
+		router, _ := newRouter(config.DeviceConfig{})
+		w := post(router, DeviceTokenPath, url.Values{"grant_type": {"authorization_code"}})
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "unsupported_grant_type")
+	})
+}
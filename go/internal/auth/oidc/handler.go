@@ -6,19 +6,37 @@ import (
 	"encoding/base64"
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/auth/claims"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/auth/pages"
 	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/config"
 	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/metrics"
 	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/session"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/session/cookie"
 	"go.uber.org/zap"
+	"golang.org/x/oauth2"
 )
 
+// LoginPath is the path of the Authorize handler
+const LoginPath = "/login"
+
 // SessionContextKey is the key for UserSession in context.
 // Using a custom type avoids string collisions.
 type SessionContextKey struct{}
 
+// bearerAuthContextKey marks requests authenticated by a bearer token
+type bearerAuthContextKey struct{}
+
+// AuthenticatedByBearer reports whether the auth middleware authenticated the
+// request by its Authorization bearer token rather than a session cookie
+func AuthenticatedByBearer(ctx context.Context) bool {
+	bearer, _ := ctx.Value(bearerAuthContextKey{}).(bool)
+	return bearer
+}
+
 // GetSessionFromContext retrieves the UserSession from the request context.
 // Returns nil if no session is found or if the session type is incorrect.
 func GetSessionFromContext(ctx context.Context) *UserSession {
@@ -34,8 +52,24 @@ func GetSessionFromContext(ctx context.Context) *UserSession {
 type Handler struct {
 	client         *Client
 	sessionStore   session.Store
+	authSessions   *session.TypedStore[AuthSession]
+	userSessions   *session.TypedStore[UserSession]
+	logoutSessions *session.TypedStore[LogoutSession]
+	// Device authorization grant state
+	deviceAuths       *session.TypedStore[DeviceAuthorization]
+	deviceUserCodes   *session.TypedStore[DeviceUserCode]
+	deviceCredentials *session.TypedStore[DeviceCredential]
+	codec          session.Codec
 	config         *config.OIDCConfig
 	sessionConfig  *config.SessionConfig
+	lifetime       *SessionLifetime
+	rotation       *SessionRotation
+	cookies        *cookie.Manager
+	claimMapper    *claims.Mapper
+	returnURLs     *ReturnURLPolicy
+	csrf           *CSRFProtection
+	stepUp         *StepUpPolicy
+	pages          *pages.Pages
 	logger         *zap.Logger
 }
 
@@ -55,21 +89,134 @@ func NewHandler(ctx context.Context, cfg *config.OIDCConfig, sessionCfg *config.
 		return nil, fmt.Errorf("OIDC redirect URL is required")
 	}
 
+	// Compile claim mapping
+	claimMapper, err := claims.NewMapper(&cfg.Claims)
+	if err != nil {
+		return nil, fmt.Errorf("invalid claim mapping: %w", err)
+	}
+
+	// Create session cookie manager
+	cookies, err := cookie.NewManager(sessionCfg)
+	if err != nil {
+		return nil, fmt.Errorf("invalid session cookie configuration: %w", err)
+	}
+
+	codec, err := session.CodecByName(sessionCfg.Codec)
+	if err != nil {
+		return nil, err
+	}
+
 	// Create OIDC client
 	client, err := NewClient(ctx, cfg.DiscoveryURL, cfg.ClientID, cfg.ClientSecret, cfg.RedirectURL, cfg.Scopes)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create OIDC client: %w", err)
 	}
+	if cfg.Bearer.Enabled {
+		client.bearer = &BearerPolicy{
+			Audiences:         cfg.Bearer.Audiences,
+			AuthorizedParties: cfg.Bearer.AuthorizedParties,
+			RequiredScopes:    cfg.Bearer.RequiredScopes,
+		}
+	}
 
 	return &Handler{
 		client:        client,
-		sessionStore:  sessionStore,
+		sessionStore:   sessionStore,
+		authSessions:   session.NewTypedStore[AuthSession](sessionStore, codec),
+		userSessions:   session.NewTypedStore[UserSession](sessionStore, codec),
+		logoutSessions: session.NewTypedStore[LogoutSession](sessionStore, codec),
+		deviceAuths:       session.NewTypedStore[DeviceAuthorization](sessionStore, codec),
+		deviceUserCodes:   session.NewTypedStore[DeviceUserCode](sessionStore, codec),
+		deviceCredentials: session.NewTypedStore[DeviceCredential](sessionStore, codec),
+		codec:          codec,
 		config:        cfg,
 		sessionConfig: sessionCfg,
+		lifetime:      NewSessionLifetime(sessionCfg),
+		rotation:      NewSessionRotation(sessionCfg),
+		cookies:       cookies,
+		claimMapper:   claimMapper,
+		returnURLs:    NewReturnURLPolicy(&cfg.ReturnURL, cfg.ClientSecret),
+		csrf:          NewCSRFProtection(&cfg.CSRF, cfg.RedirectURL, cfg.ClientSecret),
+		stepUp:        NewStepUpPolicy(&cfg.StepUp),
 		logger:        logger,
 	}, nil
 }
 
+// Cookies returns the session cookie manager
+func (h *Handler) Cookies() *cookie.Manager {
+	return h.cookies
+}
+
+// Codec returns the codec used to encode sessions
+func (h *Handler) Codec() session.Codec {
+	return h.codec
+}
+
+// Lifetime returns the idle and absolute expiry policy of user sessions
+func (h *Handler) Lifetime() *SessionLifetime {
+	return h.lifetime
+}
+
+// Rotation returns the session ID rotation policy
+func (h *Handler) Rotation() *SessionRotation {
+	return h.rotation
+}
+
+// issuer returns the issuer of user session IDs
+func (h *Handler) issuer() *sessionIssuer {
+	rotation := h.rotation
+	if rotation == nil {
+		rotation = NewSessionRotation(nil)
+	}
+	maxPerUser := 0
+	if h.sessionConfig != nil {
+		maxPerUser = h.sessionConfig.MaxSessionsPerUser
+	}
+	return &sessionIssuer{
+		store:      h.sessionStore,
+		sessions:   h.userSessions,
+		cookies:    h.cookies,
+		lifetime:   h.lifetime,
+		rotation:   rotation,
+		maxPerUser: maxPerUser,
+		logger:     h.logger,
+	}
+}
+
+// ClaimMapper returns the claim mapper used by the handler
+func (h *Handler) ClaimMapper() *claims.Mapper {
+	if h.claimMapper == nil {
+		return claims.DefaultMapper()
+	}
+	return h.claimMapper
+}
+
+// SetPages sets the pages shown to browsers when login fails
+func (h *Handler) SetPages(p *pages.Pages) {
+	h.pages = p
+}
+
+// Pages returns the pages shown to browsers
+func (h *Handler) Pages() *pages.Pages {
+	if h.pages == nil {
+		return pages.Default()
+	}
+	return h.pages
+}
+
+// ReturnURLs returns the policy for return URLs after login
+func (h *Handler) ReturnURLs() *ReturnURLPolicy {
+	if h.returnURLs == nil {
+		return NewReturnURLPolicy(nil, h.config.ClientSecret)
+	}
+	return h.returnURLs
+}
+
+// TokenEndpoint returns the provider's token endpoint URL
+func (h *Handler) TokenEndpoint() string {
+	return h.client.TokenEndpoint()
+}
+
 // Authorize handles the authorization request
 func (h *Handler) Authorize(c *gin.Context) {
 	// Generate state for CSRF protection
@@ -81,37 +228,52 @@ func (h *Handler) Authorize(c *gin.Context) {
 			zap.String("user_agent", c.Request.UserAgent()),
 		)
 		metrics.AuthRequestsTotal.WithLabelValues(h.config.ProviderName, "error").Inc()
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to generate state",
-		})
+		h.fail(c, http.StatusInternalServerError, "Failed to generate state", nil)
 		return
 	}
 
+	// A step-up login asks the provider for a fresh login meeting the rule
+	var opts []oauth2.AuthCodeOption
+	stepUp := h.stepUpRequested(c)
+	if stepUp != nil {
+		opts = stepUp.authCodeOptions()
+	}
+
 	// Generate authorization URL with PKCE
-	authURL, codeVerifier, _, err := h.client.AuthCodeURL(state)
+	authURL, codeVerifier, _, err := h.client.AuthCodeURL(state, opts...)
 	if err != nil {
 		h.logger.Error("Failed to generate auth URL", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to generate authorization URL",
-		})
+		h.fail(c, http.StatusInternalServerError, "Failed to generate authorization URL", nil)
 		return
 	}
 
+	// Only allowed return URLs are kept, signed together with the state
+	var redirectURI string
+	if requested := c.Query("redirect_uri"); requested != "" {
+		if returnURL, ok := h.ReturnURLs().Validate(requested); ok {
+			redirectURI = h.ReturnURLs().Sign(state, returnURL)
+		} else {
+			h.logger.Warn("Rejected return URL",
+				zap.String("redirect_uri", requested),
+				zap.String("remote_addr", c.Request.RemoteAddr),
+			)
+		}
+	}
+
 	// Store state and PKCE verifier in session
 	authSession := &AuthSession{
 		State:        state,
 		CodeVerifier: codeVerifier,
 		CreatedAt:    time.Now(),
-		RedirectURI:  c.Query("redirect_uri"),
+		RedirectURI:  redirectURI,
+		StepUp:       stepUp,
 	}
 
 	// Create temporary session for auth flow
-	sessionID, err := h.sessionStore.Create(c.Request.Context(), fmt.Sprintf("auth:%s", state), authSession, 10*time.Minute)
+	sessionID, err := h.authSessions.Create(c.Request.Context(), fmt.Sprintf("auth:%s", state), authSession, 10*time.Minute)
 	if err != nil {
 		h.logger.Error("Failed to create auth session", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to create session",
-		})
+		h.fail(c, http.StatusInternalServerError, "Failed to create session", nil)
 		return
 	}
 
@@ -143,7 +305,11 @@ func (h *Handler) Callback(c *gin.Context) {
 			zap.String("description", errorDesc),
 		)
 		metrics.AuthRequestsTotal.WithLabelValues(h.config.ProviderName, "error").Inc()
-		c.JSON(http.StatusBadRequest, gin.H{
+		message := errorDesc
+		if message == "" {
+			message = errorParam
+		}
+		h.fail(c, http.StatusBadRequest, "Sign-in was not completed: "+message, gin.H{
 			"error":             errorParam,
 			"error_description": errorDesc,
 		})
@@ -153,24 +319,19 @@ func (h *Handler) Callback(c *gin.Context) {
 	// Validate required parameters
 	if state == "" || code == "" {
 		h.logger.Error("Missing state or code parameter")
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Missing required parameters",
-		})
+		h.fail(c, http.StatusBadRequest, "Missing required parameters", nil)
 		return
 	}
 
 	// Retrieve auth session
-	var authSession AuthSession
 	sessionKey := fmt.Sprintf("auth:%s", state)
-	err := h.sessionStore.Get(c.Request.Context(), sessionKey, &authSession)
+	authSession, err := h.authSessions.Get(c.Request.Context(), sessionKey)
 	if err != nil {
 		h.logger.Error("Failed to retrieve auth session",
 			zap.Error(err),
 			zap.String("state", state),
 		)
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Invalid or expired state",
-		})
+		h.fail(c, http.StatusBadRequest, "Invalid or expired state", nil)
 		return
 	}
 
@@ -185,9 +346,7 @@ func (h *Handler) Callback(c *gin.Context) {
 			zap.String("expected", authSession.State),
 			zap.String("received", state),
 		)
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Invalid or expired state",
-		})
+		h.fail(c, http.StatusBadRequest, "Invalid or expired state", nil)
 		return
 	}
 
@@ -195,131 +354,150 @@ func (h *Handler) Callback(c *gin.Context) {
 	tokenResp, err := h.client.Exchange(c.Request.Context(), code, authSession.CodeVerifier)
 	if err != nil {
 		h.logger.Error("Failed to exchange code for tokens", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to exchange authorization code",
-		})
+		h.fail(c, http.StatusInternalServerError, "Failed to exchange authorization code", nil)
 		return
 	}
 
+	// The provider may not honour acr_values or max_age; a login that does
+	// not meet the step-up rule leaves the existing session as it was
+	if authSession.StepUp != nil {
+		if reason := authSession.StepUp.Unmet(authenticationFromClaims(tokenResp.Claims), time.Now()); reason != "" {
+			h.logger.Warn("Step-up login did not meet the requirement", zap.String("reason", reason))
+			h.fail(c, http.StatusForbidden, "The sign-in did not meet the required authentication level", gin.H{
+				"error":             InsufficientUserAuthentication,
+				"error_description": reason,
+			})
+			return
+		}
+	}
+
 	// Extract user information from claims
-	userID, _ := tokenResp.Claims["sub"].(string)
-	email, _ := tokenResp.Claims["email"].(string)
-	name, _ := tokenResp.Claims["name"].(string)
+	identity := h.ClaimMapper().Map(tokenResp.Claims)
 	
 	// If email is not in ID token, try userinfo endpoint
-	if email == "" && h.config.UseUserInfo {
+	if identity.Email == "" && h.config.UseUserInfo {
 		userInfo, err := h.client.UserInfo(c.Request.Context(), tokenResp.AccessToken)
 		if err != nil {
 			h.logger.Warn("Failed to fetch user info", zap.Error(err))
 		} else {
-			if e, ok := userInfo["email"].(string); ok {
-				email = e
-			}
-			if n, ok := userInfo["name"].(string); ok && name == "" {
-				name = n
-			}
+			tokenResp.Claims = mergeClaims(tokenResp.Claims, userInfo)
+			identity = h.ClaimMapper().Map(tokenResp.Claims)
 		}
 	}
 
+	if identity.GroupsOverage {
+		h.logger.Warn("Groups claim omitted by provider due to overage; group headers will be incomplete",
+			zap.String("user_id", identity.UserID),
+		)
+	}
+
 	// Create user session
-	userSession := &UserSession{
-		ID:           userID,
-		Email:        email,
-		Name:         name,
-		AccessToken:  tokenResp.AccessToken,
-		RefreshToken: tokenResp.RefreshToken,
-		IDToken:      tokenResp.IDToken,
-		ExpiresAt:    tokenResp.Expiry,
-		CreatedAt:    time.Now(),
-		Claims:       tokenResp.Claims,
+	userSession := newUserSession(identity, tokenResp.Claims)
+	userSession.Provider = h.config.ProviderName
+	userSession.AccessToken = tokenResp.AccessToken
+	userSession.RefreshToken = tokenResp.RefreshToken
+	userSession.IDToken = tokenResp.IDToken
+	userSession.ExpiresAt = tokenResp.Expiry
+	userSession.IdPSessionID, _ = tokenResp.Claims["sid"].(string)
+
+	// A session the browser held before login must not survive it, so that
+	// a planted session ID cannot be carried into the authenticated session
+	if previousID, _, err := h.cookies.Read(c.Request); err == nil {
+		if err := h.sessionStore.Delete(c.Request.Context(), previousID); err != nil {
+			h.logger.Debug("Failed to delete pre-login session", zap.Error(err))
+		}
 	}
 
-	// Store user session
-	sessionID, err := h.sessionStore.Create(c.Request.Context(), fmt.Sprintf("user:%s", userID), userSession, 0)
+	// Store the user session under a new random ID and set the cookie. The
+	// store entry and the cookie expire at the idle timeout, capped by the
+	// absolute lifetime.
+	sessionID, err := h.issuer().issue(c, userSession)
 	if err != nil {
 		h.logger.Error("Failed to create user session", zap.Error(err))
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to create user session",
-		})
+		h.fail(c, http.StatusInternalServerError, "Failed to create user session", nil)
 		return
 	}
 
 	h.logger.Info("User authenticated successfully",
-		zap.String("user_id", userID),
-		zap.String("email", email),
+		zap.String("user_id", identity.UserID),
+		zap.String("email", identity.Email),
 		zap.String("session_id", sessionID),
 	)
 
 	// Record successful callback
 	metrics.AuthRequestsTotal.WithLabelValues(h.config.ProviderName, "success").Inc()
 
-	// Set session cookie
-	c.SetCookie(
-		"session_id",
-		sessionID,
-		int(24*time.Hour/time.Second), // 24 hours
-		"/",
-		"", // Domain (empty = current domain)
-		h.sessionConfig.CookieSecure, // Use session config
-		true,  // HttpOnly
-	)
-
 	// Redirect to original URL or default
-	redirectURI := authSession.RedirectURI
-	if redirectURI == "" {
-		redirectURI = "/"
+	redirectURI := DefaultReturnURL
+	if authSession.RedirectURI != "" {
+		if returnURL, ok := h.ReturnURLs().Verify(state, authSession.RedirectURI); ok {
+			redirectURI = returnURL
+		} else {
+			h.logger.Warn("Discarded invalid return URL", zap.String("user_id", identity.UserID))
+		}
 	}
 	c.Redirect(http.StatusFound, redirectURI)
 }
 
-// Logout handles user logout
-func (h *Handler) Logout(c *gin.Context) {
-	// Get session ID from cookie
-	sessionID, err := c.Cookie("session_id")
-	if err == nil && sessionID != "" {
-		// Delete session from store
-		if err := h.sessionStore.Delete(c.Request.Context(), sessionID); err != nil {
-			h.logger.Warn("Failed to delete session", zap.Error(err), zap.String("session_id", sessionID))
-		}
+// fail ends a login attempt with an error: browsers get the error page with a
+// link to try again, other clients the JSON body (default: {"error": message})
+func (h *Handler) fail(c *gin.Context, status int, message string, body gin.H) {
+	c.Set(pages.LoginURLKey, LoginPath)
+	h.Pages().Respond(c, status, pages.Error, message, body)
+}
+
+// AuthenticateBearer verifies a JWT access token, when bearer authentication
+// is enabled, and builds a request-scoped session from its claims.
+// Credentials issued through the device authorization grant are resolved to
+// the device's session instead.
+func (h *Handler) AuthenticateBearer(ctx context.Context, rawToken string) (*UserSession, error) {
+	if strings.HasPrefix(rawToken, DeviceTokenPrefix) {
+		return h.authenticateDeviceCredential(ctx, rawToken)
 	}
 
-	// Clear session cookie
-	c.SetCookie(
-		"session_id",
-		"",
-		-1, // Max age -1 = delete cookie
-		"/",
-		"",
-		false,
-		true,
-	)
+	tokenClaims, expiry, err := h.client.VerifyAccessToken(ctx, rawToken)
+	if err != nil {
+		return nil, err
+	}
 
-	// Check if OIDC provider supports end session endpoint
-	if h.config.EndSessionEndpoint != "" {
-		// Build logout URL
-		logoutURL := fmt.Sprintf("%s?post_logout_redirect_uri=%s",
-			h.config.EndSessionEndpoint,
-			h.config.PostLogoutRedirectURI,
-		)
+	identity := h.ClaimMapper().Map(tokenClaims)
+	if identity.UserID == "" {
+		return nil, fmt.Errorf("bearer token has no user identifier")
+	}
 
-		// If we have ID token, include it
-		var userSession UserSession
-		if err == nil && sessionID != "" {
-			if err := h.sessionStore.Get(c.Request.Context(), sessionID, &userSession); err == nil && userSession.IDToken != "" {
-				logoutURL += "&id_token_hint=" + userSession.IDToken
-			}
-		}
+	userSession := newUserSession(identity, tokenClaims)
+	userSession.Provider = h.config.ProviderName
+	userSession.AccessToken = rawToken
+	userSession.ExpiresAt = expiry
+	return userSession, nil
+}
 
-		c.Redirect(http.StatusFound, logoutURL)
-		return
+// newUserSession creates a user session from a mapped identity
+func newUserSession(identity *claims.Identity, tokenClaims map[string]interface{}) *UserSession {
+	return &UserSession{
+		ID:        identity.UserID,
+		Subject:   identity.Subject,
+		Email:     identity.Email,
+		Name:      identity.Name,
+		Groups:    identity.Groups,
+		Roles:     identity.Roles,
+		CreatedAt: time.Now(),
+		Claims:    tokenClaims,
+
+		Authentication: authenticationFromClaims(tokenClaims),
 	}
+}
 
-	// Otherwise, redirect to post-logout URL or home
-	redirectURL := h.config.PostLogoutRedirectURI
-	if redirectURL == "" {
-		redirectURL = "/"
+// mergeClaims returns base claims extended with any extra claims not already present
+func mergeClaims(base, extra map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(base)+len(extra))
+	for k, v := range extra {
+		merged[k] = v
+	}
+	for k, v := range base {
+		merged[k] = v
 	}
-	c.Redirect(http.StatusFound, redirectURL)
+	return merged
 }
 
 // generateRandomString generates a random string of specified length
@@ -337,17 +515,40 @@ type AuthSession struct {
 	CodeVerifier string    `json:"code_verifier"`
 	CreatedAt    time.Time `json:"created_at"`
 	RedirectURI  string    `json:"redirect_uri"`
+	// StepUp is the requirement a step-up login must meet
+	StepUp *StepUpRequirement `json:"step_up,omitempty"`
 }
 
 // UserSession represents authenticated user session data
 type UserSession struct {
 	ID           string                 `json:"id"`
+	Subject      string                 `json:"subject,omitempty"`
 	Email        string                 `json:"email"`
 	Name         string                 `json:"name"`
+	Groups       []string               `json:"groups,omitempty"`
+	Roles        []string               `json:"roles,omitempty"`
+	Provider     string                 `json:"provider,omitempty"`
+	IdPSessionID string                 `json:"idp_sid,omitempty"`
 	AccessToken  string                 `json:"access_token"`
 	RefreshToken string                 `json:"refresh_token"`
 	IDToken      string                 `json:"id_token"`
 	ExpiresAt    time.Time              `json:"expires_at"`
 	CreatedAt    time.Time              `json:"created_at"`
 	Claims       map[string]interface{} `json:"claims"`
-}
\ No newline at end of file
+
+	// AbsoluteExpiresAt ends the session regardless of activity (zero: no limit)
+	AbsoluteExpiresAt time.Time `json:"absolute_expires_at"`
+	// LastActivityAt is when the idle timeout was last extended
+	LastActivityAt time.Time `json:"last_activity_at"`
+	// RotatedAt is when the session was last issued a new ID
+	RotatedAt time.Time `json:"rotated_at"`
+	// PrivilegeHash fingerprints the groups and roles at the last rotation
+	PrivilegeHash string `json:"privilege_hash,omitempty"`
+	// ReplacedBy is the successor ID of a rotated-out session in its grace period
+	ReplacedBy string `json:"replaced_by,omitempty"`
+	// GrantID identifies the login whose refresh token the session holds. It
+	// survives rotation; device sessions keep their approver's to renew from it.
+	GrantID string `json:"grant_id,omitempty"`
+	// Authentication records the login for step-up rules
+	Authentication Authentication `json:"authentication"`
+}
@@ -12,6 +12,8 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/config"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/session"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/session/cookie"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
@@ -63,9 +65,37 @@ func (m *MockSessionStore) Cleanup(ctx context.Context) error {
 	return args.Error(0)
 }
 
-func (m *MockSessionStore) Stats(ctx context.Context) (interface{}, error) {
+func (m *MockSessionStore) Stats(ctx context.Context) (*session.Stats, error) {
 	args := m.Called(ctx)
-	return args.Get(0), args.Error(1)
+	stats, _ := args.Get(0).(*session.Stats)
+	return stats, args.Error(1)
+}
+
+func (m *MockSessionStore) SetMetadata(ctx context.Context, key string, metadata *session.Metadata) error {
+	args := m.Called(ctx, key, metadata)
+	return args.Error(0)
+}
+
+func (m *MockSessionStore) Touch(ctx context.Context, key string, seen time.Time) error {
+	args := m.Called(ctx, key, seen)
+	return args.Error(0)
+}
+
+func (m *MockSessionStore) ListUserSessions(ctx context.Context, userID string) ([]*session.SessionInfo, error) {
+	args := m.Called(ctx, userID)
+	infos, _ := args.Get(0).([]*session.SessionInfo)
+	return infos, args.Error(1)
+}
+
+func (m *MockSessionStore) DeleteUserSessions(ctx context.Context, userID string) (int, error) {
+	args := m.Called(ctx, userID)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockSessionStore) ListSessions(ctx context.Context, cursor string, limit int) (*session.SessionPage, error) {
+	args := m.Called(ctx, cursor, limit)
+	page, _ := args.Get(0).(*session.SessionPage)
+	return page, args.Error(1)
 }
 
 func TestNewHandler(t *testing.T) {
@@ -274,6 +304,7 @@ func TestCallback(t *testing.T) {
 					CookieSecure: false, // For testing
 				},
 				sessionStore: mockStore,
+				authSessions: session.NewTypedStore[AuthSession](mockStore, nil),
 				logger:       logger,
 			}
 
@@ -306,16 +337,37 @@ func TestCallback(t *testing.T) {
 	}
 }
 
+func TestCallbackErrorPage(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	handler := &Handler{
+		config: &config.OIDCConfig{ProviderName: "test-provider"},
+		logger: zap.NewNop(),
+	}
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("GET", "/callback?error=access_denied&error_description=<b>denied</b>", nil)
+	c.Request.Header.Set("Sec-Fetch-Mode", "navigate")
+
+	handler.Callback(c)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "text/html")
+	assert.Contains(t, w.Body.String(), "Sign-in was not completed: &lt;b&gt;denied&lt;/b&gt;")
+	assert.Contains(t, w.Body.String(), `href="/login"`)
+}
+
 func TestLogout(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	logger := zap.NewNop()
 
 	tests := []struct {
-		name               string
-		sessionCookie      string
-		endSessionEndpoint string
-		expectedLocation   string
-		setupMock          func(*MockSessionStore)
+		name             string
+		sessionCookie    string
+		postLogoutURI    string
+		expectedLocation string
+		setupMock        func(*MockSessionStore)
 	}{
 		{
 			name:             "No session cookie",
@@ -328,17 +380,18 @@ func TestLogout(t *testing.T) {
 			sessionCookie:    "session-123",
 			expectedLocation: "/",
 			setupMock: func(m *MockSessionStore) {
+				m.On("Get", mock.Anything, "session-123", mock.Anything).Return(nil)
 				m.On("Delete", mock.Anything, "session-123").Return(nil)
 			},
 		},
 		{
-			name:               "With end session endpoint",
-			sessionCookie:      "session-123",
-			endSessionEndpoint: "https://example.com/logout",
-			expectedLocation:   "https://example.com/logout?post_logout_redirect_uri=http://localhost:8080",
+			name:             "Post logout redirect without end session endpoint",
+			sessionCookie:    "session-123",
+			postLogoutURI:    "http://localhost:8080/bye",
+			expectedLocation: "http://localhost:8080/bye",
 			setupMock: func(m *MockSessionStore) {
-				m.On("Delete", mock.Anything, "session-123").Return(nil)
 				m.On("Get", mock.Anything, "session-123", mock.Anything).Return(nil)
+				m.On("Delete", mock.Anything, "session-123").Return(nil)
 			},
 		},
 	}
@@ -348,17 +401,14 @@ func TestLogout(t *testing.T) {
 			mockStore := new(MockSessionStore)
 			tt.setupMock(mockStore)
 
-			postLogoutURI := ""
-			if tt.endSessionEndpoint != "" {
-				postLogoutURI = "http://localhost:8080"
-			}
 			handler := &Handler{
 				sessionStore: mockStore,
+				userSessions: session.NewTypedStore[UserSession](mockStore, nil),
+				cookies:      cookie.DefaultManager(),
 				logger:       logger,
 				config: &config.OIDCConfig{
 					ProviderName:          "test-provider",
-					EndSessionEndpoint:    tt.endSessionEndpoint,
-					PostLogoutRedirectURI: postLogoutURI,
+					PostLogoutRedirectURI: tt.postLogoutURI,
 				},
 			}
 
@@ -407,4 +457,114 @@ func TestGenerateRandomString(t *testing.T) {
 		assert.False(t, seen[str], "Generated duplicate random string")
 		seen[str] = true
 	}
-}
\ No newline at end of file
+}
+func TestAuthenticateBearer(t *testing.T) {
+	provider := newTestProvider(t)
+
+	cfg := &config.OIDCConfig{
+		DiscoveryURL: provider.URL(),
+		ClientID:     "test-client",
+		ClientSecret: "test-secret",
+		RedirectURL:  "http://localhost:8080/callback",
+		Scopes:       []string{"openid"},
+		Bearer: config.BearerConfig{
+			Enabled:           true,
+			Audiences:         []string{"mcp-api"},
+			AuthorizedParties: []string{"cli"},
+			RequiredScopes:    []string{"mcp"},
+		},
+		Claims: config.ClaimsConfig{
+			Groups: config.ClaimMapping{
+				Paths:      []string{"realm_access.roles"},
+				Transforms: []config.ClaimTransform{{Type: "strip_prefix", Value: "mcp-"}},
+			},
+		},
+	}
+
+	handler, err := NewHandler(context.Background(), cfg, &config.SessionConfig{}, new(MockSessionStore), zap.NewNop())
+	require.NoError(t, err)
+
+	accessToken := func(extra map[string]interface{}) map[string]interface{} {
+		claims := map[string]interface{}{
+			"sub":   "user123",
+			"aud":   "mcp-api",
+			"azp":   "cli",
+			"scope": "openid mcp",
+		}
+		for k, v := range extra {
+			claims[k] = v
+		}
+		return claims
+	}
+
+	t.Run("Valid token", func(t *testing.T) {
+		token := provider.signAccessToken(t, accessToken(map[string]interface{}{
+			"email": "test@example.com",
+			"realm_access": map[string]interface{}{
+				"roles": []string{"mcp-admin", "mcp-users"},
+			},
+		}))
+
+		sess, err := handler.AuthenticateBearer(context.Background(), token)
+		require.NoError(t, err)
+		assert.Equal(t, "user123", sess.ID)
+		assert.Equal(t, "test@example.com", sess.Email)
+		assert.Equal(t, []string{"admin", "users"}, sess.Groups)
+		assert.Equal(t, token, sess.AccessToken)
+		assert.True(t, sess.ExpiresAt.After(time.Now()))
+	})
+
+	t.Run("ID token", func(t *testing.T) {
+		token := provider.sign(t, accessToken(nil))
+
+		_, err := handler.AuthenticateBearer(context.Background(), token)
+		assert.ErrorContains(t, err, "not an access token")
+	})
+
+	t.Run("Audience not accepted", func(t *testing.T) {
+		token := provider.signAccessToken(t, accessToken(map[string]interface{}{"aud": "test-client"}))
+
+		_, err := handler.AuthenticateBearer(context.Background(), token)
+		assert.ErrorContains(t, err, "not accepted")
+	})
+
+	t.Run("Client not accepted", func(t *testing.T) {
+		token := provider.signAccessToken(t, accessToken(map[string]interface{}{"azp": "other"}))
+
+		_, err := handler.AuthenticateBearer(context.Background(), token)
+		assert.ErrorContains(t, err, `client "other" not accepted`)
+	})
+
+	t.Run("Missing scope", func(t *testing.T) {
+		token := provider.signAccessToken(t, accessToken(map[string]interface{}{"scope": "openid"}))
+
+		_, err := handler.AuthenticateBearer(context.Background(), token)
+		assert.ErrorContains(t, err, `lacks scope "mcp"`)
+	})
+
+	t.Run("Invalid signature", func(t *testing.T) {
+		_, err := handler.AuthenticateBearer(context.Background(), "not-a-jwt")
+		assert.Error(t, err)
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		disabled := *cfg
+		disabled.Bearer = config.BearerConfig{}
+		handler, err := NewHandler(context.Background(), &disabled, &config.SessionConfig{}, new(MockSessionStore), zap.NewNop())
+		require.NoError(t, err)
+
+		_, err = handler.AuthenticateBearer(context.Background(), provider.signAccessToken(t, accessToken(nil)))
+		assert.ErrorContains(t, err, "disabled")
+	})
+}
+
+func TestMergeClaims(t *testing.T) {
+	merged := mergeClaims(
+		map[string]interface{}{"sub": "user123", "name": "ID Token Name"},
+		map[string]interface{}{"name": "UserInfo Name", "email": "test@example.com"},
+	)
+
+	assert.Equal(t, "user123", merged["sub"])
+	assert.Equal(t, "ID Token Name", merged["name"])
+	assert.Equal(t, "test@example.com", merged["email"])
+}
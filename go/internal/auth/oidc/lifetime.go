@@ -0,0 +1,85 @@
+package oidc
+
+import (
+	"time"
+
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/config"
+)
+
+// DefaultRefreshInterval is the minimum time between sliding expiration
+// writes when the configuration does not set one
+const DefaultRefreshInterval = time.Minute
+
+// SessionLifetime applies the idle timeout and absolute lifetime of user
+// sessions. The store entry and the cookie always expire at the earlier of
+// the two.
+type SessionLifetime struct {
+	// Idle is how long a session survives without activity (0 disables)
+	Idle time.Duration
+	// Absolute is the maximum lifetime of a session since login (0 disables)
+	Absolute time.Duration
+	// RefreshInterval rate-limits sliding expiration writes
+	RefreshInterval time.Duration
+}
+
+// NewSessionLifetime creates the session lifetime policy from the session configuration
+func NewSessionLifetime(cfg *config.SessionConfig) *SessionLifetime {
+	if cfg == nil {
+		return &SessionLifetime{}
+	}
+
+	refreshInterval := cfg.RefreshInterval
+	if refreshInterval <= 0 {
+		refreshInterval = DefaultRefreshInterval
+	}
+	return &SessionLifetime{
+		Idle:            cfg.IdleTimeout,
+		Absolute:        cfg.TTL,
+		RefreshInterval: refreshInterval,
+	}
+}
+
+// Start stamps a new session with its login and absolute expiry times
+func (l *SessionLifetime) Start(userSession *UserSession, now time.Time) {
+	userSession.LastActivityAt = now
+	if l.Absolute > 0 {
+		userSession.AbsoluteExpiresAt = now.Add(l.Absolute)
+	}
+}
+
+// TTL returns how long the session may live from now: the idle timeout capped
+// by the remaining absolute lifetime. Zero means the session does not expire.
+func (l *SessionLifetime) TTL(userSession *UserSession, now time.Time) time.Duration {
+	ttl := l.Idle
+	if !userSession.AbsoluteExpiresAt.IsZero() {
+		remaining := userSession.AbsoluteExpiresAt.Sub(now)
+		if ttl <= 0 || remaining < ttl {
+			ttl = remaining
+		}
+	}
+	return ttl
+}
+
+// Expired reports whether the session has reached its absolute lifetime or
+// has been idle for longer than the idle timeout
+func (l *SessionLifetime) Expired(userSession *UserSession, now time.Time) bool {
+	if !userSession.AbsoluteExpiresAt.IsZero() && !now.Before(userSession.AbsoluteExpiresAt) {
+		return true
+	}
+	return l.Idle > 0 && !userSession.LastActivityAt.IsZero() && now.Sub(userSession.LastActivityAt) > l.Idle
+}
+
+// NeedsRefresh reports whether activity should extend the idle timeout now.
+// Writes are skipped until RefreshInterval has passed since the last one.
+func (l *SessionLifetime) NeedsRefresh(userSession *UserSession, now time.Time) bool {
+	return l.Idle > 0 && now.Sub(userSession.LastActivityAt) >= l.RefreshInterval
+}
+
+// CookieMaxAge converts a session TTL to a cookie Max-Age in seconds; zero
+// yields a browser session cookie
+func CookieMaxAge(ttl time.Duration) int {
+	if ttl <= 0 {
+		return 0
+	}
+	return int((ttl + time.Second - 1) / time.Second)
+}
@@ -0,0 +1,150 @@
+package oidc
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/config"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/session/cookie"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/session/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestSessionLifetime(t *testing.T) {
+	lifetime := NewSessionLifetime(&config.SessionConfig{
+		TTL:         8 * time.Hour,
+		IdleTimeout: time.Hour,
+	})
+	assert.Equal(t, DefaultRefreshInterval, lifetime.RefreshInterval)
+
+	login := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	userSession := &UserSession{}
+	lifetime.Start(userSession, login)
+	assert.Equal(t, login.Add(8*time.Hour), userSession.AbsoluteExpiresAt)
+	assert.Equal(t, login, userSession.LastActivityAt)
+
+	t.Run("TTL is the idle timeout capped by the absolute lifetime", func(t *testing.T) {
+		assert.Equal(t, time.Hour, lifetime.TTL(userSession, login))
+		assert.Equal(t, 30*time.Minute, lifetime.TTL(userSession, login.Add(7*time.Hour+30*time.Minute)))
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		assert.False(t, lifetime.Expired(userSession, login.Add(time.Hour)))
+		assert.True(t, lifetime.Expired(userSession, login.Add(time.Hour+time.Second)), "idle")
+
+		active := *userSession
+		active.LastActivityAt = login.Add(7*time.Hour + 59*time.Minute)
+		assert.False(t, lifetime.Expired(&active, login.Add(7*time.Hour+59*time.Minute)))
+		assert.True(t, lifetime.Expired(&active, login.Add(8*time.Hour)), "absolute")
+	})
+
+	t.Run("Refresh is rate limited", func(t *testing.T) {
+		assert.False(t, lifetime.NeedsRefresh(userSession, login.Add(30*time.Second)))
+		assert.True(t, lifetime.NeedsRefresh(userSession, login.Add(time.Minute)))
+	})
+
+	t.Run("Without idle timeout", func(t *testing.T) {
+		absoluteOnly := NewSessionLifetime(&config.SessionConfig{TTL: 8 * time.Hour})
+		assert.Equal(t, 8*time.Hour, absoluteOnly.TTL(userSession, login))
+		assert.False(t, absoluteOnly.Expired(userSession, login.Add(7*time.Hour)))
+		assert.False(t, absoluteOnly.NeedsRefresh(userSession, login.Add(7*time.Hour)))
+	})
+
+	assert.Equal(t, 0, CookieMaxAge(0))
+	assert.Equal(t, 3600, CookieMaxAge(time.Hour))
+	assert.Equal(t, 2, CookieMaxAge(1500*time.Millisecond))
+}
+
+func TestAuthMiddlewareSlidingExpiration(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	store := memory.NewStore(&memory.Config{}, zap.NewNop())
+	defer store.Close()
+
+	cookies, err := cookie.NewManager(&config.SessionConfig{CookieName: "session_id", CookieSecure: true, CookieHTTPOnly: true})
+	require.NoError(t, err)
+
+	lifetime := &SessionLifetime{Idle: time.Hour, Absolute: 8 * time.Hour, RefreshInterval: time.Minute}
+	router := gin.New()
+	router.Use(AuthMiddlewareWithConfig(store, zap.NewNop(), &MiddlewareConfig{
+		Lifetime: lifetime,
+		Cookies:  cookies,
+	}))
+	router.GET("/api", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	request := func(sessionID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api", nil)
+		req.AddCookie(&http.Cookie{Name: "session_id", Value: sessionID})
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	createSession := func(key string, userSession *UserSession) {
+		userSession.ID = "alice"
+		userSession.ExpiresAt = time.Now().Add(time.Hour)
+		_, err := store.Create(context.Background(), key, userSession, time.Hour)
+		require.NoError(t, err)
+	}
+
+	t.Run("Recent activity is not rewritten", func(t *testing.T) {
+		userSession := &UserSession{}
+		lifetime.Start(userSession, time.Now())
+		createSession("fresh", userSession)
+
+		w := request("fresh")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Header().Get("Set-Cookie"))
+	})
+
+	t.Run("Activity extends the store entry and the cookie", func(t *testing.T) {
+		userSession := &UserSession{}
+		lifetime.Start(userSession, time.Now().Add(-10*time.Minute))
+		createSession("active", userSession)
+
+		w := request("active")
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		setCookie := w.Header().Get("Set-Cookie")
+		assert.True(t, strings.HasPrefix(setCookie, "session_id=active"))
+		assert.Contains(t, setCookie, "Max-Age=3600")
+		assert.Contains(t, setCookie, "Secure")
+
+		var stored UserSession
+		require.NoError(t, store.Get(context.Background(), "active", &stored))
+		assert.WithinDuration(t, time.Now(), stored.LastActivityAt, time.Second)
+		assert.Equal(t, userSession.AbsoluteExpiresAt.Unix(), stored.AbsoluteExpiresAt.Unix())
+	})
+
+	t.Run("Extension is capped by the absolute lifetime", func(t *testing.T) {
+		userSession := &UserSession{}
+		lifetime.Start(userSession, time.Now().Add(-7*time.Hour-50*time.Minute))
+		userSession.LastActivityAt = time.Now().Add(-5 * time.Minute)
+		createSession("ending", userSession)
+
+		w := request("ending")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Header().Get("Set-Cookie"), "Max-Age=600")
+	})
+
+	t.Run("Absolute lifetime ends the session", func(t *testing.T) {
+		userSession := &UserSession{}
+		lifetime.Start(userSession, time.Now().Add(-8*time.Hour))
+		userSession.LastActivityAt = time.Now()
+		createSession("expired", userSession)
+
+		w := request("expired")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Body.String(), "Session expired")
+		assert.False(t, sessionExists(t, store, "expired"))
+	})
+}
@@ -0,0 +1,342 @@
+package oidc
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// Default logout paths used when the configuration leaves them empty
+const (
+	DefaultLogoutCallbackPath     = "/logout/callback"
+	DefaultFrontChannelLogoutPath = "/frontchannel-logout"
+)
+
+// logoutStateTTL bounds how long the provider may take to return to the
+// logout landing page
+const logoutStateTTL = 10 * time.Minute
+
+// LogoutSession represents temporary RP-initiated logout state
+type LogoutSession struct {
+	State       string    `json:"state"`
+	RedirectURI string    `json:"redirect_uri"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// frontChannelLogoutPage loads the front-channel logout URIs of other relying
+// parties in hidden iframes and continues once they have loaded
+var frontChannelLogoutPage = template.Must(template.New("frontchannel").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta http-equiv="refresh" content="5;url={{.Next}}">
+<title>Signing out</title>
+</head>
+<body>
+<p>Signing out&hellip;</p>
+{{range .Frames}}<iframe src="{{.}}" hidden title="logout"></iframe>
+{{end}}<script nonce="{{.Nonce}}">
+var frames = document.querySelectorAll("iframe");
+var pending = frames.length;
+frames.forEach(function (frame) {
+	frame.addEventListener("load", function () {
+		if (--pending === 0) {
+			window.location.replace({{.Next}});
+		}
+	});
+});
+</script>
+</body>
+</html>
+`))
+
+// loggedOutPage is returned from the front-channel logout endpoint
+const loggedOutPage = `<!DOCTYPE html><html><head><meta charset="utf-8"><title>Logged out</title></head><body></body></html>`
+
+// LogoutCallbackPath returns the path of the post-logout landing handler
+func (h *Handler) LogoutCallbackPath() string {
+	if h.config.Logout.CallbackPath != "" {
+		return h.config.Logout.CallbackPath
+	}
+	return DefaultLogoutCallbackPath
+}
+
+// FrontChannelLogoutPath returns the path of the front-channel logout handler
+func (h *Handler) FrontChannelLogoutPath() string {
+	if h.config.Logout.FrontChannelPath != "" {
+		return h.config.Logout.FrontChannelPath
+	}
+	return DefaultFrontChannelLogoutPath
+}
+
+// Logout handles user logout. The session is deleted locally, other relying
+// parties are logged out through front-channel iframes, and the browser is
+// sent to the provider's end_session_endpoint when one is known.
+func (h *Handler) Logout(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	// Read the session before deleting it; the ID token and IdP session ID
+	// are needed to log out at the provider
+	userSession := &UserSession{}
+	sessionID, _, err := h.cookies.Read(c.Request)
+	if err == nil {
+		if stored, err := h.userSessions.Get(ctx, sessionID); err != nil {
+			h.logger.Debug("Logout without a readable session", zap.Error(err), zap.String("session_id", sessionID))
+		} else {
+			userSession = stored
+		}
+		if err := h.sessionStore.Delete(ctx, sessionID); err != nil {
+			h.logger.Warn("Failed to delete session", zap.Error(err), zap.String("session_id", sessionID))
+		}
+	}
+
+	// Clear session cookie
+	h.cookies.Clear(c.Writer, c.Request)
+
+	next := h.postLogoutRedirectURI()
+	if endpoint := h.endSessionEndpoint(); endpoint != "" {
+		logoutURL, err := h.endSessionURL(ctx, endpoint, userSession.IDToken)
+		if err != nil {
+			h.logger.Error("Failed to build end session URL", zap.Error(err))
+		} else {
+			next = logoutURL
+		}
+	}
+
+	if frames := h.frontChannelFrames(userSession.IdPSessionID); len(frames) > 0 {
+		nonce, err := generateRandomString(16)
+		if err != nil {
+			h.logger.Error("Failed to generate script nonce", zap.Error(err))
+			c.Redirect(http.StatusFound, next)
+			return
+		}
+		c.Header("Cache-Control", "no-store")
+		c.Header("Content-Type", "text/html; charset=utf-8")
+		c.Header("Content-Security-Policy", frontChannelLogoutPolicy(frames, nonce))
+		c.Status(http.StatusOK)
+		if err := frontChannelLogoutPage.Execute(c.Writer, map[string]interface{}{
+			"Frames": frames,
+			"Next":   next,
+			"Nonce":  nonce,
+		}); err != nil {
+			h.logger.Error("Failed to render front-channel logout page", zap.Error(err))
+		}
+		return
+	}
+
+	c.Redirect(http.StatusFound, next)
+}
+
+// LogoutCallback is the post-logout landing page the provider returns to.
+// It validates the state created by Logout and redirects to the configured
+// post-logout destination.
+func (h *Handler) LogoutCallback(c *gin.Context) {
+	state := c.Query("state")
+	if state == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Missing state parameter",
+		})
+		return
+	}
+
+	sessionKey := fmt.Sprintf("logout:%s", state)
+	logoutSession, err := h.logoutSessions.Get(c.Request.Context(), sessionKey)
+	if err != nil {
+		h.logger.Warn("Failed to retrieve logout session", zap.Error(err), zap.String("state", state))
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid or expired state",
+		})
+		return
+	}
+
+	// Delete logout session (one-time use)
+	if err := h.sessionStore.Delete(c.Request.Context(), sessionKey); err != nil {
+		h.logger.Warn("Failed to delete logout session", zap.Error(err), zap.String("key", sessionKey))
+	}
+
+	if logoutSession.State != state {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid or expired state",
+		})
+		return
+	}
+
+	redirectURI := logoutSession.RedirectURI
+	if redirectURI == "" {
+		redirectURI = "/"
+	}
+	c.Redirect(http.StatusFound, redirectURI)
+}
+
+// FrontChannelLogout is loaded by the provider in an iframe when the user
+// logs out elsewhere. With a sid, every session of that IdP session is
+// deleted; otherwise the session identified by the browser cookie is. A sid
+// is only accepted together with the issuer it belongs to.
+func (h *Handler) FrontChannelLogout(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+
+	ctx := c.Request.Context()
+	iss := c.Query("iss")
+	sid := c.Query("sid")
+
+	if sid != "" && iss == "" {
+		h.logger.Warn("Rejected front-channel logout with sid but no issuer", zap.String("sid", sid))
+		c.String(http.StatusBadRequest, "iss is required with sid")
+		return
+	}
+	if iss != "" && h.client != nil && iss != h.client.Issuer() {
+		h.logger.Warn("Rejected front-channel logout from unknown issuer", zap.String("iss", iss))
+		c.String(http.StatusBadRequest, "invalid issuer")
+		return
+	}
+
+	if sid != "" {
+		if _, err := h.endLogoutSessions(ctx, &LogoutToken{SessionID: sid}); err != nil {
+			h.logger.Error("Failed to log out sessions for front-channel logout", zap.Error(err))
+		}
+	} else if sessionID, _, err := h.cookies.Read(c.Request); err == nil {
+		if err := h.sessionStore.Delete(ctx, sessionID); err != nil {
+			h.logger.Debug("Failed to delete session", zap.Error(err), zap.String("session_id", sessionID))
+		}
+		h.cookies.Clear(c.Writer, c.Request)
+	}
+
+	h.logger.Info("Front-channel logout processed", zap.String("sid", sid))
+	c.Header("Content-Security-Policy", h.loggedOutPolicy())
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(loggedOutPage))
+}
+
+// endSessionEndpoint returns the configured end session endpoint, falling
+// back to the one advertised in the discovery document
+func (h *Handler) endSessionEndpoint() string {
+	if h.config.EndSessionEndpoint != "" {
+		return h.config.EndSessionEndpoint
+	}
+	if h.client != nil {
+		return h.client.EndSessionEndpoint()
+	}
+	return ""
+}
+
+// postLogoutRedirectURI returns where the user ends up after logout
+func (h *Handler) postLogoutRedirectURI() string {
+	if h.config.PostLogoutRedirectURI != "" {
+		return h.config.PostLogoutRedirectURI
+	}
+	return "/"
+}
+
+// logoutCallbackURL returns the absolute post_logout_redirect_uri
+func (h *Handler) logoutCallbackURL() string {
+	return h.publicURL(h.LogoutCallbackPath())
+}
+
+// publicURL returns the absolute URL of a proxy path, using the scheme and
+// host of the login redirect URL
+func (h *Handler) publicURL(path string) string {
+	u := &url.URL{Path: path}
+	if redirectURL, err := url.Parse(h.config.RedirectURL); err == nil {
+		u.Scheme = redirectURL.Scheme
+		u.Host = redirectURL.Host
+	}
+	return u.String()
+}
+
+// endSessionURL builds the RP-initiated logout request and stores the state
+// the landing handler validates
+func (h *Handler) endSessionURL(ctx context.Context, endpoint, idToken string) (string, error) {
+	logoutURL, err := url.Parse(endpoint)
+	if err != nil {
+		return "", fmt.Errorf("invalid end session endpoint: %w", err)
+	}
+
+	state, err := generateRandomString(32)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate state: %w", err)
+	}
+
+	logoutSession := &LogoutSession{
+		State:       state,
+		RedirectURI: h.postLogoutRedirectURI(),
+		CreatedAt:   time.Now(),
+	}
+	if _, err := h.logoutSessions.Create(ctx, fmt.Sprintf("logout:%s", state), logoutSession, logoutStateTTL); err != nil {
+		return "", fmt.Errorf("failed to create logout session: %w", err)
+	}
+
+	query := logoutURL.Query()
+	query.Set("post_logout_redirect_uri", h.logoutCallbackURL())
+	query.Set("state", state)
+	if idToken != "" {
+		query.Set("id_token_hint", idToken)
+	}
+	if h.config.ClientID != "" {
+		query.Set("client_id", h.config.ClientID)
+	}
+	logoutURL.RawQuery = query.Encode()
+
+	return logoutURL.String(), nil
+}
+
+// frontChannelLogoutPolicy is the content security policy of the page
+// logging out other relying parties: only its own script and frames of
+// their origins may load
+func frontChannelLogoutPolicy(frames []string, nonce string) string {
+	policy := "default-src 'none'; script-src 'nonce-" + nonce + "'; frame-ancestors 'none'"
+	origins := make([]string, 0, len(frames))
+	for _, frame := range frames {
+		if u, err := url.Parse(frame); err == nil && u.Host != "" {
+			if origin := u.Scheme + "://" + u.Host; !containsString(origins, origin) {
+				origins = append(origins, origin)
+			}
+		}
+	}
+	if len(origins) > 0 {
+		policy += "; frame-src " + strings.Join(origins, " ")
+	}
+	return policy
+}
+
+// loggedOutPolicy is the content security policy of the front-channel logout
+// response, which the provider loads in an iframe
+func (h *Handler) loggedOutPolicy() string {
+	policy := "default-src 'none'"
+	if h.client == nil {
+		return policy
+	}
+	if u, err := url.Parse(h.client.Issuer()); err == nil && u.Host != "" {
+		policy += "; frame-ancestors " + u.Scheme + "://" + u.Host
+	}
+	return policy
+}
+
+// frontChannelFrames returns the front-channel logout URIs of the configured
+// relying parties with the iss and sid parameters added
+func (h *Handler) frontChannelFrames(sid string) []string {
+	frames := make([]string, 0, len(h.config.Logout.FrontChannelURIs))
+	for _, uri := range h.config.Logout.FrontChannelURIs {
+		frameURL, err := url.Parse(uri)
+		if err != nil {
+			h.logger.Warn("Skipping invalid front-channel logout URI", zap.String("uri", uri), zap.Error(err))
+			continue
+		}
+
+		query := frameURL.Query()
+		if h.client != nil && h.client.Issuer() != "" {
+			query.Set("iss", h.client.Issuer())
+		}
+		if sid != "" {
+			query.Set("sid", sid)
+		}
+		frameURL.RawQuery = query.Encode()
+		frames = append(frames, frameURL.String())
+	}
+	return frames
+}
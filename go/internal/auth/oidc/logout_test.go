@@ -0,0 +1,200 @@
+package oidc
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/config"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/session"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/session/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func newLogoutTestHandler(t *testing.T, provider *testProvider, store session.Store, logoutCfg config.LogoutConfig) *Handler {
+	t.Helper()
+
+	handler, err := NewHandler(context.Background(), &config.OIDCConfig{
+		DiscoveryURL:          provider.URL(),
+		ClientID:              "test-client",
+		ClientSecret:          "test-secret",
+		RedirectURL:           "https://proxy.example.com/callback",
+		Scopes:                []string{"openid"},
+		PostLogoutRedirectURI: "https://proxy.example.com/goodbye",
+		Logout:                logoutCfg,
+	}, &config.SessionConfig{}, store, zap.NewNop())
+	require.NoError(t, err)
+	return handler
+}
+
+func newLogoutRouter(handler *Handler) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/logout", handler.Logout)
+	router.GET(handler.LogoutCallbackPath(), handler.LogoutCallback)
+	router.GET(handler.FrontChannelLogoutPath(), handler.FrontChannelLogout)
+	return router
+}
+
+func serveLogout(router *gin.Engine, method, target, sessionCookie string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, nil)
+	if sessionCookie != "" {
+		req.AddCookie(&http.Cookie{Name: "session_id", Value: sessionCookie})
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestLogout_RPInitiated(t *testing.T) {
+	provider := newTestProvider(t)
+	store := memory.NewStore(&memory.Config{}, zap.NewNop())
+	handler := newLogoutTestHandler(t, provider, store, config.LogoutConfig{})
+	router := newLogoutRouter(handler)
+
+	idToken := "header.payload+/=&.signature"
+	_, err := store.Create(context.Background(), "user:alice", &UserSession{ID: "alice", IDToken: idToken}, time.Hour)
+	require.NoError(t, err)
+
+	w := serveLogout(router, http.MethodPost, "/logout", "user:alice")
+	require.Equal(t, http.StatusFound, w.Code)
+
+	exists, err := store.Exists(context.Background(), "user:alice")
+	require.NoError(t, err)
+	assert.False(t, exists, "local session must be deleted")
+
+	location, err := url.Parse(w.Header().Get("Location"))
+	require.NoError(t, err)
+	assert.Equal(t, provider.URL()+"/logout", location.Scheme+"://"+location.Host+location.Path,
+		"end_session_endpoint comes from discovery")
+
+	query := location.Query()
+	assert.Equal(t, "en", query.Get("ui_locales"), "existing endpoint parameters are kept")
+	assert.Equal(t, idToken, query.Get("id_token_hint"), "ID token is read before the session is deleted")
+	assert.Equal(t, "https://proxy.example.com/logout/callback", query.Get("post_logout_redirect_uri"))
+	assert.Equal(t, "test-client", query.Get("client_id"))
+	state := query.Get("state")
+	require.NotEmpty(t, state)
+
+	t.Run("Landing page validates state", func(t *testing.T) {
+		w := serveLogout(router, http.MethodGet, "/logout/callback?state="+url.QueryEscape(state), "")
+		assert.Equal(t, http.StatusFound, w.Code)
+		assert.Equal(t, "https://proxy.example.com/goodbye", w.Header().Get("Location"))
+
+		// State is single use
+		w = serveLogout(router, http.MethodGet, "/logout/callback?state="+url.QueryEscape(state), "")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Landing page rejects unknown state", func(t *testing.T) {
+		w := serveLogout(router, http.MethodGet, "/logout/callback?state=forged", "")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		w = serveLogout(router, http.MethodGet, "/logout/callback", "")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+func TestLogout_ConfiguredEndSessionEndpoint(t *testing.T) {
+	provider := newTestProvider(t)
+	handler := newLogoutTestHandler(t, provider, memory.NewStore(&memory.Config{}, zap.NewNop()), config.LogoutConfig{})
+	handler.config.EndSessionEndpoint = "https://idp.example.com/v2/logout"
+
+	w := serveLogout(newLogoutRouter(handler), http.MethodPost, "/logout", "")
+	require.Equal(t, http.StatusFound, w.Code)
+	assert.True(t, strings.HasPrefix(w.Header().Get("Location"), "https://idp.example.com/v2/logout?"))
+	assert.NotContains(t, w.Header().Get("Location"), "id_token_hint", "no hint without a session")
+}
+
+func TestLogout_FrontChannelIframes(t *testing.T) {
+	provider := newTestProvider(t)
+	store := memory.NewStore(&memory.Config{}, zap.NewNop())
+	handler := newLogoutTestHandler(t, provider, store, config.LogoutConfig{
+		FrontChannelURIs: []string{
+			"https://app-one.example.com/logout",
+			"https://app-two.example.com/signout?tenant=a",
+		},
+	})
+
+	_, err := store.Create(context.Background(), "user:alice", &UserSession{ID: "alice", IdPSessionID: "idp-1"}, time.Hour)
+	require.NoError(t, err)
+
+	w := serveLogout(newLogoutRouter(handler), http.MethodPost, "/logout", "user:alice")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+
+	body := w.Body.String()
+	iss := url.QueryEscape(provider.URL())
+	assert.Contains(t, body, `<iframe src="https://app-one.example.com/logout?iss=`+iss+`&amp;sid=idp-1"`)
+	assert.Contains(t, body, `<iframe src="https://app-two.example.com/signout?iss=`+iss+`&amp;sid=idp-1&amp;tenant=a"`)
+	assert.Contains(t, body, "hidden title=\"logout\"")
+
+	// Only the page's own script and frames of the relying parties may load
+	policy := w.Header().Get("Content-Security-Policy")
+	assert.Contains(t, policy, "frame-src https://app-one.example.com https://app-two.example.com")
+	nonce := regexp.MustCompile(`'nonce-([^']+)'`).FindStringSubmatch(policy)
+	require.Len(t, nonce, 2)
+	assert.Contains(t, body, `<script nonce="`+nonce[1]+`">`)
+	assert.Contains(t, body, `window.location.replace("`+provider.URL()+`/logout?client_id=test-client\u0026`,
+		"continues to the provider's end session endpoint")
+	assert.Contains(t, body, `content="5;url=`+provider.URL()+`/logout?`)
+}
+
+func TestFrontChannelLogout(t *testing.T) {
+	provider := newTestProvider(t)
+
+	t.Run("Sid deletes the IdP session", func(t *testing.T) {
+		store := memory.NewStore(&memory.Config{}, zap.NewNop())
+		handler := newLogoutTestHandler(t, provider, store, config.LogoutConfig{})
+		createIndexedSession(t, handler, "a", "alice", "idp-1")
+		createIndexedSession(t, handler, "b", "alice", "idp-2")
+
+		target := "/frontchannel-logout?iss=" + url.QueryEscape(provider.URL()) + "&sid=idp-1"
+		w := serveLogout(newLogoutRouter(handler), http.MethodGet, target, "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+		assert.Equal(t, "default-src 'none'; frame-ancestors "+provider.URL(), w.Header().Get("Content-Security-Policy"),
+			"only the provider may frame the response")
+
+		assert.False(t, sessionExists(t, store, "a"))
+		assert.True(t, sessionExists(t, store, "b"))
+	})
+
+	t.Run("Cookie session without sid", func(t *testing.T) {
+		store := memory.NewStore(&memory.Config{}, zap.NewNop())
+		handler := newLogoutTestHandler(t, provider, store, config.LogoutConfig{})
+		createIndexedSession(t, handler, "a", "alice", "")
+
+		w := serveLogout(newLogoutRouter(handler), http.MethodGet, "/frontchannel-logout", "a")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.False(t, sessionExists(t, store, "a"))
+	})
+
+	t.Run("Unknown issuer", func(t *testing.T) {
+		store := memory.NewStore(&memory.Config{}, zap.NewNop())
+		handler := newLogoutTestHandler(t, provider, store, config.LogoutConfig{})
+		createIndexedSession(t, handler, "a", "alice", "idp-1")
+
+		w := serveLogout(newLogoutRouter(handler), http.MethodGet, "/frontchannel-logout?iss=https://evil.example.com&sid=idp-1", "")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.True(t, sessionExists(t, store, "a"))
+	})
+
+	t.Run("Sid without issuer", func(t *testing.T) {
+		store := memory.NewStore(&memory.Config{}, zap.NewNop())
+		handler := newLogoutTestHandler(t, provider, store, config.LogoutConfig{})
+		createIndexedSession(t, handler, "a", "alice", "idp-1")
+
+		w := serveLogout(newLogoutRouter(handler), http.MethodGet, "/frontchannel-logout?sid=idp-1", "")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.True(t, sessionExists(t, store, "a"))
+	})
+}
@@ -1,57 +1,175 @@
 package oidc
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"net/http"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/auth/identity"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/auth/pages"
 	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/session"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/session/cookie"
 	"go.uber.org/zap"
+	"golang.org/x/sync/singleflight"
 )
 
+// BearerAuthenticator authenticates requests carrying an Authorization bearer token
+type BearerAuthenticator interface {
+	AuthenticateBearer(ctx context.Context, rawToken string) (*UserSession, error)
+}
+
+// MiddlewareConfig holds configuration for the authentication middleware
+type MiddlewareConfig struct {
+	// ExcludePaths are served without authentication
+	ExcludePaths []string
+	// Bearer validates Authorization bearer tokens when no session cookie is present (optional)
+	Bearer BearerAuthenticator
+	// Propagator strips and injects identity headers (default: X-User-* headers, unsigned)
+	Propagator *identity.Propagator
+	// Lifetime enforces the idle timeout and absolute lifetime and slides the
+	// idle timeout on activity. Without it a session ends with its access
+	// token (optional)
+	Lifetime *SessionLifetime
+	// Refresher renews expired access tokens with the session's refresh
+	// token (optional)
+	Refresher TokenRefresher
+	// Rotation issues sessions new IDs periodically and on privilege changes;
+	// it requires Lifetime (optional)
+	Rotation *SessionRotation
+	// Cookies reads and re-issues the session cookie (default: unsigned session_id cookie)
+	Cookies *cookie.Manager
+	// Codec decodes user sessions (default: JSON)
+	Codec session.Codec
+	// LoginPath is where browser navigations without a valid session are
+	// sent, with the original URL as redirect_uri ("" answers them with 401)
+	LoginPath string
+	// Realm is sent in the WWW-Authenticate challenge (default: DefaultRealm)
+	Realm string
+	// Pages renders the pages shown to browsers (default: built-in pages)
+	Pages *pages.Pages
+}
+
+// DefaultRealm is the WWW-Authenticate realm when none is configured
+const DefaultRealm = "mcp-oidc-proxy"
+
 // AuthMiddleware creates a middleware that checks for valid authentication
 func AuthMiddleware(sessionStore session.Store, logger *zap.Logger, excludePaths []string) gin.HandlerFunc {
+	return AuthMiddlewareWithConfig(sessionStore, logger, &MiddlewareConfig{
+		ExcludePaths: excludePaths,
+	})
+}
+
+// AuthMiddlewareWithConfig creates an authentication middleware with the given configuration
+func AuthMiddlewareWithConfig(sessionStore session.Store, logger *zap.Logger, cfg *MiddlewareConfig) gin.HandlerFunc {
 	// Create a map for faster lookup of excluded paths
 	excludeMap := make(map[string]bool)
-	for _, path := range excludePaths {
+	for _, path := range cfg.ExcludePaths {
 		excludeMap[path] = true
 	}
 
+	propagator := cfg.Propagator
+	if propagator == nil {
+		propagator = identity.DefaultPropagator()
+	}
+	cookies := cfg.Cookies
+	if cookies == nil {
+		cookies = cookie.DefaultManager()
+	}
+	userSessions := session.NewTypedStore[UserSession](sessionStore, cfg.Codec)
+	challenge := newChallenger(cfg)
+	var issuer *sessionIssuer
+	if cfg.Rotation != nil && cfg.Lifetime != nil {
+		issuer = &sessionIssuer{
+			store:    sessionStore,
+			sessions: userSessions,
+			cookies:  cookies,
+			lifetime: cfg.Lifetime,
+			rotation: cfg.Rotation,
+			logger:   logger,
+		}
+	}
+
 	return func(c *gin.Context) {
 		// Check if path is excluded
 		if excludeMap[c.Request.URL.Path] {
+			propagator.Strip(c.Request)
 			c.Next()
 			return
 		}
 
 		// Get session ID from cookie
-		sessionID, err := c.Cookie("session_id")
-		if err != nil || sessionID == "" {
+		sessionID, legacyCookie, err := cookies.Read(c.Request)
+		if errors.Is(err, cookie.ErrInvalidSignature) {
+			// Forged or tampered cookies never reach the store
+			logger.Debug("Session cookie rejected", zap.Error(err))
+			challenge.reject(c, "Invalid or expired session", true)
+			return
+		}
+		if err != nil {
+			// Fall back to bearer token authentication for API clients
+			if token := bearerToken(c.Request); token != "" && cfg.Bearer != nil {
+				userSession, err := cfg.Bearer.AuthenticateBearer(c.Request.Context(), token)
+				if err != nil {
+					logger.Debug("Bearer token rejected", zap.Error(err))
+					challenge.reject(c, "Invalid bearer token", true)
+					return
+				}
+
+				setAuthenticatedUser(c, userSession, propagator)
+				c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), bearerAuthContextKey{}, true))
+				logger.Debug("User authenticated with bearer token",
+					zap.String("user_id", userSession.ID),
+				)
+				c.Next()
+				return
+			}
+
 			logger.Debug("No session cookie found")
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"error": "Authentication required",
-			})
-			c.Abort()
+			challenge.reject(c, "Authentication required", false)
 			return
 		}
 
 		// Retrieve user session
-		var userSession UserSession
-		err = sessionStore.Get(c.Request.Context(), sessionID, &userSession)
+		userSession, err := userSessions.Get(c.Request.Context(), sessionID)
 		if err != nil {
 			logger.Debug("Failed to retrieve session",
 				zap.String("session_id", sessionID),
 				zap.Error(err),
 			)
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"error": "Invalid or expired session",
-			})
-			c.Abort()
+			challenge.reject(c, "Invalid or expired session", true)
+			return
+		}
+
+		// Enforce the absolute lifetime and idle timeout
+		now := time.Now()
+		if cfg.Lifetime != nil && cfg.Lifetime.Expired(userSession, now) {
+			logger.Debug("Session lifetime exceeded",
+				zap.String("user_id", userSession.ID),
+				zap.Time("absolute_expires_at", userSession.AbsoluteExpiresAt),
+				zap.Time("last_activity_at", userSession.LastActivityAt),
+			)
+
+			if err := sessionStore.Delete(c.Request.Context(), sessionID); err != nil {
+				logger.Warn("Failed to delete expired session", zap.Error(err), zap.String("session_id", sessionID))
+			}
+
+			challenge.reject(c, "Session expired", true)
 			return
 		}
 
-		// Check if token is expired
-		if time.Now().After(userSession.ExpiresAt) {
+		// Renew an expired access token; a rotated-out session leaves that
+		// to its successor
+		if accessTokenExpired(userSession, now) && cfg.Refresher != nil && userSession.ReplacedBy == "" {
+			refreshAccessToken(c.Request.Context(), cfg.Refresher, &sessionRefreshes, userSessions, sessionID, userSession, logger)
+		}
+
+		// Without a session lifetime the session ends with its access token
+		if cfg.Lifetime == nil && now.After(userSession.ExpiresAt) {
 			logger.Debug("Session expired",
 				zap.String("user_id", userSession.ID),
 				zap.Time("expired_at", userSession.ExpiresAt),
@@ -62,23 +180,48 @@ func AuthMiddleware(sessionStore session.Store, logger *zap.Logger, excludePaths
 				logger.Warn("Failed to delete expired session", zap.Error(err), zap.String("session_id", sessionID))
 			}
 			
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"error": "Session expired",
-			})
-			c.Abort()
+			challenge.reject(c, "Session expired", true)
 			return
 		}
 
-		// Add user information to context
-		c.Set("user_id", userSession.ID)
-		c.Set("user_email", userSession.Email)
-		c.Set("user_name", userSession.Name)
-		c.Set("user_session", &userSession)
+		// Rotate the session ID when due, or slide the idle timeout at most
+		// once per refresh interval
+		if cfg.Lifetime != nil {
+			if userSession.ReplacedBy != "" {
+				// A request that raced a rotation; the browser already holds
+				// the new ID, so this one is left to expire
+				legacyCookie = false
+			} else if due, immediate := rotationDue(issuer, userSession, now); due {
+				newID, err := issuer.rotate(c, sessionID, userSession, immediate, now)
+				if err != nil {
+					logger.Warn("Failed to rotate session ID", zap.Error(err), zap.String("user_id", userSession.ID))
+				} else {
+					sessionID = newID
+					legacyCookie = false
+				}
+			} else if cfg.Lifetime.NeedsRefresh(userSession, now) && extendSession(c, userSessions, cfg.Lifetime, cookies, sessionID, userSession, now, logger) {
+				legacyCookie = false
+			}
+		}
 
-		// Add user headers for proxy
-		c.Request.Header.Set("X-User-ID", userSession.ID)
-		c.Request.Header.Set("X-User-Email", userSession.Email)
-		c.Request.Header.Set("X-User-Name", userSession.Name)
+		// Move sessions found under a legacy cookie name to the current one
+		if legacyCookie {
+			maxAge := 0
+			if cfg.Lifetime != nil {
+				maxAge = CookieMaxAge(cfg.Lifetime.TTL(userSession, now))
+			}
+			cookies.Set(c.Writer, c.Request, sessionID, maxAge)
+		}
+
+		// Record activity for session listings; a rotated-out session's
+		// activity belongs to its successor
+		if userSession.ReplacedBy == "" {
+			if err := sessionStore.Touch(c.Request.Context(), sessionID, time.Now()); err != nil {
+				logger.Debug("Failed to record session activity", zap.Error(err), zap.String("session_id", sessionID))
+			}
+		}
+
+		setAuthenticatedUser(c, userSession, propagator)
 
 		logger.Debug("User authenticated",
 			zap.String("user_id", userSession.ID),
@@ -91,18 +234,35 @@ func AuthMiddleware(sessionStore session.Store, logger *zap.Logger, excludePaths
 
 // OptionalAuthMiddleware is like AuthMiddleware but doesn't block unauthenticated requests
 func OptionalAuthMiddleware(sessionStore session.Store, logger *zap.Logger) gin.HandlerFunc {
+	return OptionalAuthMiddlewareWithConfig(sessionStore, logger, &MiddlewareConfig{})
+}
+
+// OptionalAuthMiddlewareWithConfig creates an optional authentication middleware with the given configuration
+func OptionalAuthMiddlewareWithConfig(sessionStore session.Store, logger *zap.Logger, cfg *MiddlewareConfig) gin.HandlerFunc {
+	propagator := cfg.Propagator
+	if propagator == nil {
+		propagator = identity.DefaultPropagator()
+	}
+	cookies := cfg.Cookies
+	if cookies == nil {
+		cookies = cookie.DefaultManager()
+	}
+	userSessions := session.NewTypedStore[UserSession](sessionStore, cfg.Codec)
+
 	return func(c *gin.Context) {
+		// Never trust identity headers supplied by the client
+		propagator.Strip(c.Request)
+
 		// Get session ID from cookie
-		sessionID, err := c.Cookie("session_id")
-		if err != nil || sessionID == "" {
-			// No session, but that's okay
+		sessionID, _, err := cookies.Read(c.Request)
+		if err != nil {
+			// No valid session, but that's okay
 			c.Next()
 			return
 		}
 
 		// Try to retrieve user session
-		var userSession UserSession
-		err = sessionStore.Get(c.Request.Context(), sessionID, &userSession)
+		userSession, err := userSessions.Get(c.Request.Context(), sessionID)
 		if err != nil {
 			// Session invalid, but continue anyway
 			logger.Debug("Failed to retrieve optional session",
@@ -113,8 +273,19 @@ func OptionalAuthMiddleware(sessionStore session.Store, logger *zap.Logger) gin.
 			return
 		}
 
-		// Check if token is expired
-		if time.Now().After(userSession.ExpiresAt) {
+		now := time.Now()
+		if accessTokenExpired(userSession, now) && cfg.Refresher != nil && userSession.ReplacedBy == "" {
+			refreshAccessToken(c.Request.Context(), cfg.Refresher, &sessionRefreshes, userSessions, sessionID, userSession, logger)
+		}
+
+		// Check if the session is expired
+		var expired bool
+		if cfg.Lifetime != nil {
+			expired = cfg.Lifetime.Expired(userSession, now)
+		} else {
+			expired = now.After(userSession.ExpiresAt)
+		}
+		if expired {
 			// Session expired, delete it but continue
 			if err := sessionStore.Delete(c.Request.Context(), sessionID); err != nil {
 				logger.Warn("Failed to delete expired session", zap.Error(err), zap.String("session_id", sessionID))
@@ -123,18 +294,147 @@ func OptionalAuthMiddleware(sessionStore session.Store, logger *zap.Logger) gin.
 			return
 		}
 
-		// Add user information to context
-		c.Set("user_id", userSession.ID)
-		c.Set("user_email", userSession.Email)
-		c.Set("user_name", userSession.Name)
-		c.Set("user_session", &userSession)
+		setAuthenticatedUser(c, userSession, propagator)
 		c.Set("authenticated", true)
 
-		// Add user headers for proxy
-		c.Request.Header.Set("X-User-ID", userSession.ID)
-		c.Request.Header.Set("X-User-Email", userSession.Email)
-		c.Request.Header.Set("X-User-Name", userSession.Name)
-
 		c.Next()
 	}
-}
\ No newline at end of file
+}
+
+// sessionRefreshes coalesces access token refreshes of a session, keyed by
+// session ID, across the middlewares and the device sessions renewing from it
+var sessionRefreshes singleflight.Group
+
+// refreshAccessToken renews the expired access token of a session and stores
+// the result. Concurrent requests of a session share one refresh, since
+// providers may accept each refresh token only once. It reports whether the
+// token was renewed.
+func refreshAccessToken(ctx context.Context, refresher TokenRefresher, refreshes *singleflight.Group, userSessions *session.TypedStore[UserSession], sessionID string, userSession *UserSession, logger *zap.Logger) bool {
+	// One client disconnecting must not fail the refresh for the others
+	ctx = context.WithoutCancel(ctx)
+	renewed, err, _ := refreshes.Do(sessionID, func() (interface{}, error) {
+		renewed := *userSession
+		if err := refresher.RefreshSession(ctx, &renewed); err != nil {
+			// Remember a refresh token the provider rejected
+			if renewed.RefreshToken != userSession.RefreshToken {
+				if err := userSessions.Update(ctx, sessionID, &renewed); err != nil {
+					logger.Warn("Failed to drop rejected refresh token", zap.Error(err), zap.String("session_id", sessionID))
+				}
+			}
+			return nil, err
+		}
+		if err := userSessions.Update(ctx, sessionID, &renewed); err != nil {
+			return nil, err
+		}
+		return &renewed, nil
+	})
+	if err != nil {
+		logger.Warn("Failed to refresh access token", zap.Error(err), zap.String("user_id", userSession.ID))
+		return false
+	}
+
+	*userSession = *renewed.(*UserSession)
+	return true
+}
+
+// extendSession slides the idle timeout of an active session, keeping the
+// store entry and the cookie in step. It reports whether the cookie was re-issued.
+func extendSession(c *gin.Context, userSessions *session.TypedStore[UserSession], lifetime *SessionLifetime, cookies *cookie.Manager, sessionID string, userSession *UserSession, now time.Time, logger *zap.Logger) bool {
+	ctx := c.Request.Context()
+	userSession.LastActivityAt = now
+	ttl := lifetime.TTL(userSession, now)
+
+	if err := userSessions.Update(ctx, sessionID, userSession); err != nil {
+		logger.Warn("Failed to record session activity", zap.Error(err), zap.String("session_id", sessionID))
+		return false
+	}
+	if err := userSessions.Refresh(ctx, sessionID, ttl); err != nil {
+		logger.Warn("Failed to extend session", zap.Error(err), zap.String("session_id", sessionID))
+		return false
+	}
+
+	cookies.Set(c.Writer, c.Request, sessionID, CookieMaxAge(ttl))
+	return true
+}
+
+// challenger answers requests that lack a valid session
+type challenger struct {
+	loginPath string
+	realm     string
+	pages     *pages.Pages
+}
+
+func newChallenger(cfg *MiddlewareConfig) *challenger {
+	ch := &challenger{loginPath: cfg.LoginPath, realm: cfg.Realm, pages: cfg.Pages}
+	if ch.realm == "" {
+		ch.realm = DefaultRealm
+	}
+	if ch.pages == nil {
+		ch.pages = pages.Default()
+	}
+	return ch
+}
+
+// reject aborts an unauthenticated request. Browsers navigating to a page are
+// redirected to log in and return to it; other clients get a 401 with a
+// WWW-Authenticate challenge, with error="invalid_token" when the credentials
+// they presented were rejected.
+func (ch *challenger) reject(c *gin.Context, message string, invalidCredentials bool) {
+	challenge := fmt.Sprintf(`Bearer realm="%s"`, ch.realm)
+	if invalidCredentials {
+		challenge += `, error="invalid_token"`
+	}
+	c.Header("WWW-Authenticate", challenge)
+
+	if ch.loginPath != "" && pages.IsNavigation(c.Request) {
+		loginURL := ch.loginPath + "?redirect_uri=" + url.QueryEscape(c.Request.URL.RequestURI())
+		if c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead {
+			c.Redirect(http.StatusFound, loginURL)
+			c.Abort()
+			return
+		}
+		// Redirecting would drop the request body; let the user sign in first
+		c.Set(pages.LoginURLKey, loginURL)
+	}
+	ch.pages.Respond(c, http.StatusUnauthorized, pages.LoginRequired, message, nil)
+}
+
+// rotationDue reports whether the session should be rotated by issuer, which
+// is nil when rotation is disabled
+func rotationDue(issuer *sessionIssuer, userSession *UserSession, now time.Time) (due, immediate bool) {
+	if issuer == nil {
+		return false, false
+	}
+	return issuer.rotation.Due(userSession, now)
+}
+
+// setAuthenticatedUser exposes the authenticated user to handlers and the proxy
+func setAuthenticatedUser(c *gin.Context, userSession *UserSession, propagator *identity.Propagator) {
+	// Add user information to context
+	c.Set("user_id", userSession.ID)
+	c.Set("user_email", userSession.Email)
+	c.Set("user_name", userSession.Name)
+	c.Set("user_groups", userSession.Groups)
+	c.Set("user_session", userSession)
+
+	// Make the session available to http.Handlers such as the proxy
+	ctx := context.WithValue(c.Request.Context(), SessionContextKey{}, userSession)
+	c.Request = c.Request.WithContext(ctx)
+
+	// Replace any client-supplied identity headers with trusted values for the proxy
+	propagator.Apply(c.Request, &identity.Identity{
+		UserID: userSession.ID,
+		Email:  userSession.Email,
+		Name:   userSession.Name,
+		Groups: userSession.Groups,
+	}, nil)
+}
+
+// bearerToken extracts the token from an "Authorization: Bearer" header
+func bearerToken(r *http.Request) string {
+	auth := r.Header.Get("Authorization")
+	if len(auth) > 7 && strings.EqualFold(auth[:7], "Bearer ") {
+		return strings.TrimSpace(auth[7:])
+	}
+	return ""
+}
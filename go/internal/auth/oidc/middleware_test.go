@@ -1,15 +1,23 @@
 package oidc
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/auth/identity"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/config"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/session"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/session/cookie"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/session/memory"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
 )
 
@@ -82,6 +90,7 @@ func TestAuthMiddleware(t *testing.T) {
 					userSession.Name = "Test User"
 					userSession.ExpiresAt = time.Now().Add(time.Hour)
 				}).Return(nil)
+				m.On("Touch", mock.Anything, "valid-session", mock.Anything).Return(nil)
 			},
 			expectedStatus: http.StatusOK,
 			checkHeaders:   true,
@@ -247,4 +256,298 @@ func TestOptionalAuthMiddleware(t *testing.T) {
 			mockStore.AssertExpectations(t)
 		})
 	}
-}
\ No newline at end of file
+}
+// stubBearer is a BearerAuthenticator returning a fixed result
+type stubBearer struct {
+	session *UserSession
+	err     error
+}
+
+func (s *stubBearer) AuthenticateBearer(ctx context.Context, token string) (*UserSession, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	return s.session, nil
+}
+
+func TestAuthMiddlewareWithBearer(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logger := zap.NewNop()
+
+	tests := []struct {
+		name           string
+		authorization  string
+		bearer         *stubBearer
+		expectedStatus int
+		expectedError  string
+	}{
+		{
+			name:           "Valid bearer token",
+			authorization:  "Bearer good-token",
+			bearer:         &stubBearer{session: &UserSession{ID: "api-user", Groups: []string{"ops"}, ExpiresAt: time.Now().Add(time.Hour)}},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Rejected bearer token",
+			authorization:  "Bearer bad-token",
+			bearer:         &stubBearer{err: assert.AnError},
+			expectedStatus: http.StatusUnauthorized,
+			expectedError:  "Invalid bearer token",
+		},
+		{
+			name:           "Non-bearer authorization",
+			authorization:  "Basic dXNlcjpwYXNz",
+			bearer:         &stubBearer{err: assert.AnError},
+			expectedStatus: http.StatusUnauthorized,
+			expectedError:  "Authentication required",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.Use(AuthMiddlewareWithConfig(new(MockSessionStore), logger, &MiddlewareConfig{
+				Bearer: tt.bearer,
+			}))
+			router.GET("/api", func(c *gin.Context) {
+				sess := GetSessionFromContext(c.Request.Context())
+				require.NotNil(t, sess)
+				c.JSON(http.StatusOK, gin.H{"user_id": sess.ID, "groups": c.GetStringSlice("user_groups")})
+			})
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", "/api", nil)
+			req.Header.Set("Authorization", tt.authorization)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+
+			var response map[string]interface{}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			if tt.expectedError != "" {
+				assert.Contains(t, response["error"], tt.expectedError)
+			} else {
+				assert.Equal(t, "api-user", response["user_id"])
+				assert.Equal(t, []interface{}{"ops"}, response["groups"])
+			}
+		})
+	}
+}
+
+func TestAuthMiddlewareStripsSpoofedIdentityHeaders(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	logger := zap.NewNop()
+
+	propagator, err := identity.NewPropagator(&config.HeadersConfig{
+		UserID:     "X-Auth-User",
+		UserEmail:  "X-Auth-Email",
+		UserName:   "X-Auth-Name",
+		UserGroups: "X-Auth-Groups",
+	})
+	require.NoError(t, err)
+
+	router := gin.New()
+	router.Use(AuthMiddlewareWithConfig(new(MockSessionStore), logger, &MiddlewareConfig{
+		Bearer:     &stubBearer{session: &UserSession{ID: "api-user", Email: "api@example.com", ExpiresAt: time.Now().Add(time.Hour)}},
+		Propagator: propagator,
+	}))
+
+	var forwarded http.Header
+	router.GET("/api", func(c *gin.Context) {
+		forwarded = c.Request.Header.Clone()
+		c.Status(http.StatusOK)
+	})
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/api", nil)
+	req.Header.Set("Authorization", "Bearer good-token")
+	req.Header.Set("X-Auth-User", "attacker")
+	req.Header.Set("X-Auth-Groups", "admin")
+	router.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, []string{"api-user"}, forwarded.Values("X-Auth-User"))
+	assert.Equal(t, "api@example.com", forwarded.Get("X-Auth-Email"))
+	assert.Empty(t, forwarded.Values("X-Auth-Groups"))
+	assert.Empty(t, forwarded.Get("X-User-ID"), "default header names must not be used when configured")
+}
+
+func TestAuthMiddlewareSessionCookie(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cookies, err := cookie.NewManager(&config.SessionConfig{
+		CookieName:        "__Host-mcp_session",
+		CookiePath:        "/",
+		CookieSecure:      true,
+		CookieHTTPOnly:    true,
+		CookieLegacyNames: []string{"session_id"},
+		CookieSigning:     config.CookieSigningConfig{Enabled: true, Keys: []string{"0123456789abcdef0123456789abcdef"}},
+	})
+	require.NoError(t, err)
+
+	newRouter := func(store *MockSessionStore) *gin.Engine {
+		router := gin.New()
+		router.Use(AuthMiddlewareWithConfig(store, zap.NewNop(), &MiddlewareConfig{Cookies: cookies}))
+		router.GET("/api", func(c *gin.Context) {
+			c.String(http.StatusOK, c.GetString("user_id"))
+		})
+		return router
+	}
+
+	serve := func(router *gin.Engine, c *http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api", nil)
+		req.AddCookie(c)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	signed := func(sessionID string) string {
+		w := httptest.NewRecorder()
+		cookies.Set(w, nil, sessionID, 0)
+		return w.Result().Cookies()[0].Value
+	}
+
+	validSession := func(m *MockSessionStore) {
+		m.On("Get", mock.Anything, "user:alice", mock.Anything).Run(func(args mock.Arguments) {
+			*args.Get(2).(*UserSession) = UserSession{ID: "alice", ExpiresAt: time.Now().Add(time.Hour)}
+		}).Return(nil)
+		m.On("Touch", mock.Anything, "user:alice", mock.Anything).Return(nil)
+	}
+
+	t.Run("Signed cookie", func(t *testing.T) {
+		store := new(MockSessionStore)
+		validSession(store)
+
+		w := serve(newRouter(store), &http.Cookie{Name: "__Host-mcp_session", Value: signed("user:alice")})
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "alice", w.Body.String())
+		store.AssertExpectations(t)
+	})
+
+	t.Run("Forged cookie never reaches the store", func(t *testing.T) {
+		store := new(MockSessionStore)
+
+		w := serve(newRouter(store), &http.Cookie{Name: "__Host-mcp_session", Value: "user:alice"})
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		store.AssertNotCalled(t, "Get", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("Legacy cookie is migrated", func(t *testing.T) {
+		store := new(MockSessionStore)
+		validSession(store)
+
+		w := serve(newRouter(store), &http.Cookie{Name: "session_id", Value: signed("user:alice")})
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		set := w.Result().Cookies()
+		require.Len(t, set, 2)
+		assert.Equal(t, "__Host-mcp_session", set[0].Name)
+		assert.Equal(t, signed("user:alice"), set[0].Value)
+		assert.True(t, set[0].Secure)
+		assert.Equal(t, "session_id", set[1].Name)
+		assert.Equal(t, -1, set[1].MaxAge)
+	})
+}
+
+func TestAuthMiddlewareCodec(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	store := memory.NewStore(&memory.Config{}, zap.NewNop())
+	defer store.Close()
+
+	userSessions := session.NewTypedStore[UserSession](store, session.MsgpackCodec{})
+	_, err := userSessions.Create(context.Background(), "user:alice", &UserSession{
+		ID:        "alice",
+		ExpiresAt: time.Now().Add(time.Hour),
+		Claims:    map[string]interface{}{"email_verified": true},
+	}, time.Hour)
+	require.NoError(t, err)
+
+	router := gin.New()
+	router.Use(AuthMiddlewareWithConfig(store, zap.NewNop(), &MiddlewareConfig{Codec: session.MsgpackCodec{}}))
+	router.GET("/api", func(c *gin.Context) {
+		c.String(http.StatusOK, c.GetString("user_id"))
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/api", nil)
+	req.AddCookie(&http.Cookie{Name: "session_id", Value: "user:alice"})
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "alice", w.Body.String())
+}
+
+func TestAuthMiddlewareContentNegotiation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	store := memory.NewStore(&memory.Config{}, zap.NewNop())
+	defer store.Close()
+
+	_, err := store.Create(context.Background(), "expired", &UserSession{ID: "alice", ExpiresAt: time.Now().Add(-time.Minute)}, time.Hour)
+	require.NoError(t, err)
+
+	router := gin.New()
+	router.Use(AuthMiddlewareWithConfig(store, zap.NewNop(), &MiddlewareConfig{
+		LoginPath: "/login",
+		Realm:     "example",
+		Bearer:    &stubBearer{err: errors.New("invalid token")},
+	}))
+	router.Any("/*path", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	navigate := func(method, target string, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		req.Header.Set("Sec-Fetch-Mode", "navigate")
+		req.Header.Set("Accept", "text/html")
+		for _, c := range cookies {
+			req.AddCookie(c)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Navigation is redirected to login", func(t *testing.T) {
+		w := navigate(http.MethodGet, "/ui/tools?tab=1")
+		assert.Equal(t, http.StatusFound, w.Code)
+		assert.Equal(t, "/login?redirect_uri=%2Fui%2Ftools%3Ftab%3D1", w.Header().Get("Location"))
+	})
+
+	t.Run("Navigation with an expired session is redirected to login", func(t *testing.T) {
+		w := navigate(http.MethodGet, "/ui", &http.Cookie{Name: "session_id", Value: "expired"})
+		assert.Equal(t, http.StatusFound, w.Code)
+		assert.Equal(t, "/login?redirect_uri=%2Fui", w.Header().Get("Location"))
+	})
+
+	t.Run("Form post shows the login page", func(t *testing.T) {
+		w := navigate(http.MethodPost, "/ui/submit")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Header().Get("Content-Type"), "text/html")
+		assert.Contains(t, w.Body.String(), `href="/login?redirect_uri=%2Fui%2Fsubmit"`)
+	})
+
+	t.Run("API client gets a challenge", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/mcp", nil)
+		req.Header.Set("Accept", "application/json, text/event-stream")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Equal(t, `Bearer realm="example"`, w.Header().Get("WWW-Authenticate"))
+		assert.JSONEq(t, `{"error":"Authentication required"}`, w.Body.String())
+	})
+
+	t.Run("Rejected bearer token", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/mcp", nil)
+		req.Header.Set("Authorization", "Bearer invalid")
+		req.Header.Set("Sec-Fetch-Mode", "navigate")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Equal(t, `Bearer realm="example", error="invalid_token"`, w.Header().Get("WWW-Authenticate"))
+	})
+}
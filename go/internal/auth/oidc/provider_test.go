@@ -0,0 +1,108 @@
+package oidc
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+	"github.com/stretchr/testify/require"
+)
+
+// testProvider is a minimal OIDC provider that signs real tokens
+type testProvider struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+	signer jose.Signer
+	// accessSigner signs JWT access tokens (typ at+jwt)
+	accessSigner jose.Signer
+	// handlers allows tests to serve additional endpoints such as /token
+	handlers map[string]http.HandlerFunc
+}
+
+// newTestProvider starts a test OIDC provider with discovery and JWKS endpoints
+func newTestProvider(t *testing.T) *testProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: key},
+		(&jose.SignerOptions{}).WithType("JWT").WithHeader("kid", "test-key"))
+	require.NoError(t, err)
+
+	accessSigner, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: key},
+		(&jose.SignerOptions{}).WithType("at+jwt").WithHeader("kid", "test-key"))
+	require.NoError(t, err)
+
+	p := &testProvider{
+		key:          key,
+		signer:       signer,
+		accessSigner: accessSigner,
+		handlers:     make(map[string]http.HandlerFunc),
+	}
+
+	p.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h, ok := p.handlers[r.URL.Path]; ok {
+			h(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"issuer":                 p.server.URL,
+				"authorization_endpoint": p.server.URL + "/auth",
+				"token_endpoint":         p.server.URL + "/token",
+				"userinfo_endpoint":      p.server.URL + "/userinfo",
+				"jwks_uri":               p.server.URL + "/jwks",
+				"end_session_endpoint":   p.server.URL + "/logout?ui_locales=en",
+			})
+		case "/jwks":
+			json.NewEncoder(w).Encode(jose.JSONWebKeySet{
+				Keys: []jose.JSONWebKey{{Key: &key.PublicKey, KeyID: "test-key", Algorithm: "RS256", Use: "sig"}},
+			})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(p.server.Close)
+
+	return p
+}
+
+// URL returns the issuer URL
+func (p *testProvider) URL() string {
+	return p.server.URL
+}
+
+// sign signs the given claims, filling in iss, iat and exp when missing
+func (p *testProvider) sign(t *testing.T, claims map[string]interface{}) string {
+	return p.signWith(t, p.signer, claims)
+}
+
+// signAccessToken signs the given claims as a JWT access token
+func (p *testProvider) signAccessToken(t *testing.T, claims map[string]interface{}) string {
+	return p.signWith(t, p.accessSigner, claims)
+}
+
+// signWith signs the given claims with signer, filling in iss, iat and exp when missing
+func (p *testProvider) signWith(t *testing.T, signer jose.Signer, claims map[string]interface{}) string {
+	if _, ok := claims["iss"]; !ok {
+		claims["iss"] = p.server.URL
+	}
+	if _, ok := claims["iat"]; !ok {
+		claims["iat"] = time.Now().Unix()
+	}
+	if _, ok := claims["exp"]; !ok {
+		claims["exp"] = time.Now().Add(time.Hour).Unix()
+	}
+
+	token, err := jwt.Signed(signer).Claims(claims).Serialize()
+	require.NoError(t, err)
+	return token
+}
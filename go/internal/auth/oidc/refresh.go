@@ -0,0 +1,64 @@
+package oidc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// ErrNoRefreshToken is returned when a session's access token cannot be renewed
+var ErrNoRefreshToken = errors.New("session has no refresh token")
+
+// TokenRefresher renews the provider tokens of a user session
+type TokenRefresher interface {
+	RefreshSession(ctx context.Context, userSession *UserSession) error
+}
+
+// RefreshSession renews the session's access token with its refresh token.
+// Tokens the provider does not reissue are kept; a refresh token the provider
+// rejects as invalid_grant is dropped, so that it is not retried on every
+// request. Other failures, such as an outage or rate limiting, leave the
+// session unchanged. Groups and roles are re-derived from a reissued ID token.
+func (h *Handler) RefreshSession(ctx context.Context, userSession *UserSession) error {
+	if userSession.RefreshToken == "" {
+		return ErrNoRefreshToken
+	}
+
+	tokenResp, err := h.client.RefreshToken(ctx, userSession.RefreshToken)
+	if err != nil {
+		var retrieveErr *oauth2.RetrieveError
+		if errors.As(err, &retrieveErr) && retrieveErr.ErrorCode == "invalid_grant" {
+			userSession.RefreshToken = ""
+		}
+		return err
+	}
+
+	// A reissued ID token carries the user's current groups and roles; a
+	// change makes the session due for rotation
+	if tokenResp.IDToken != "" {
+		if subject, _ := tokenResp.Claims["sub"].(string); subject != userSession.Subject {
+			return fmt.Errorf("refreshed ID token is for a different subject")
+		}
+		identity := h.ClaimMapper().Map(tokenResp.Claims)
+		userSession.Groups = identity.Groups
+		userSession.Roles = identity.Roles
+		userSession.Claims = mergeClaims(tokenResp.Claims, userSession.Claims)
+		userSession.IDToken = tokenResp.IDToken
+	}
+
+	userSession.AccessToken = tokenResp.AccessToken
+	if tokenResp.RefreshToken != "" {
+		userSession.RefreshToken = tokenResp.RefreshToken
+	}
+	userSession.ExpiresAt = tokenResp.Expiry
+	return nil
+}
+
+// accessTokenExpired reports whether the provider access token of a session
+// has expired; sessions without an expiry never do
+func accessTokenExpired(userSession *UserSession, now time.Time) bool {
+	return !userSession.ExpiresAt.IsZero() && now.After(userSession.ExpiresAt)
+}
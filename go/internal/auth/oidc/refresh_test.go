@@ -0,0 +1,302 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/config"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/session/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// stubRefresher renews access tokens without a provider
+type stubRefresher struct {
+	calls atomic.Int32
+	err   error
+}
+
+func (r *stubRefresher) RefreshSession(ctx context.Context, userSession *UserSession) error {
+	r.calls.Add(1)
+	if r.err != nil {
+		return r.err
+	}
+	userSession.AccessToken = "renewed-access-token"
+	userSession.ExpiresAt = time.Now().Add(time.Hour)
+	return nil
+}
+
+func TestAuthMiddlewareAccessTokenExpiry(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	store := memory.NewStore(&memory.Config{}, zap.NewNop())
+	defer store.Close()
+
+	newRouter := func(cfg *MiddlewareConfig) *gin.Engine {
+		router := gin.New()
+		router.Use(AuthMiddlewareWithConfig(store, zap.NewNop(), cfg))
+		router.GET("/api", func(c *gin.Context) {
+			c.String(http.StatusOK, GetSessionFromContext(c.Request.Context()).AccessToken)
+		})
+		return router
+	}
+
+	request := func(router *gin.Engine, sessionID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api", nil)
+		req.AddCookie(&http.Cookie{Name: "session_id", Value: sessionID})
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// createSession stores a session within its lifetime whose access token
+	// expired a minute ago
+	lifetime := &SessionLifetime{Idle: time.Hour, Absolute: 8 * time.Hour, RefreshInterval: time.Minute}
+	createSession := func(key string) {
+		userSession := &UserSession{ID: "alice", AccessToken: "stale-access-token", RefreshToken: "refresh-token"}
+		lifetime.Start(userSession, time.Now())
+		userSession.ExpiresAt = time.Now().Add(-time.Minute)
+		_, err := store.Create(context.Background(), key, userSession, time.Hour)
+		require.NoError(t, err)
+	}
+
+	t.Run("Session outlives its access token", func(t *testing.T) {
+		createSession("outlives")
+
+		w := request(newRouter(&MiddlewareConfig{Lifetime: lifetime}), "outlives")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "stale-access-token", w.Body.String())
+		assert.True(t, sessionExists(t, store, "outlives"))
+	})
+
+	t.Run("Expired access token is refreshed", func(t *testing.T) {
+		createSession("refreshed")
+		refresher := &stubRefresher{}
+		router := newRouter(&MiddlewareConfig{Lifetime: lifetime, Refresher: refresher})
+
+		w := request(router, "refreshed")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "renewed-access-token", w.Body.String())
+
+		var stored UserSession
+		require.NoError(t, store.Get(context.Background(), "refreshed", &stored))
+		assert.Equal(t, "renewed-access-token", stored.AccessToken)
+		assert.True(t, stored.ExpiresAt.After(time.Now()))
+
+		request(router, "refreshed")
+		assert.Equal(t, int32(1), refresher.calls.Load(), "a renewed token is not refreshed again")
+	})
+
+	t.Run("Failed refresh keeps the session", func(t *testing.T) {
+		createSession("unrefreshed")
+
+		w := request(newRouter(&MiddlewareConfig{Lifetime: lifetime, Refresher: &stubRefresher{err: errors.New("invalid_grant")}}), "unrefreshed")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "stale-access-token", w.Body.String())
+	})
+
+	t.Run("Without a lifetime the session ends with its access token", func(t *testing.T) {
+		createSession("legacy")
+
+		w := request(newRouter(&MiddlewareConfig{Refresher: &stubRefresher{err: errors.New("invalid_grant")}}), "legacy")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.False(t, sessionExists(t, store, "legacy"))
+	})
+}
+
+func TestHandlerRefreshSession(t *testing.T) {
+	provider := newTestProvider(t)
+
+	handler, err := NewHandler(context.Background(), &config.OIDCConfig{
+		DiscoveryURL: provider.URL(),
+		ClientID:     "test-client",
+		ClientSecret: "test-secret",
+		RedirectURL:  "http://localhost:8080/callback",
+		Scopes:       []string{"openid"},
+	}, &config.SessionConfig{}, memory.NewStore(&memory.Config{}, zap.NewNop()), zap.NewNop())
+	require.NoError(t, err)
+
+	t.Run("Tokens are renewed", func(t *testing.T) {
+		provider.handlers["/token"] = func(w http.ResponseWriter, r *http.Request) {
+			require.NoError(t, r.ParseForm())
+			assert.Equal(t, "refresh_token", r.PostForm.Get("grant_type"))
+			assert.Equal(t, "refresh-1", r.PostForm.Get("refresh_token"))
+
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"access_token":  "access-2",
+				"refresh_token": "refresh-2",
+				"token_type":    "Bearer",
+				"expires_in":    3600,
+			})
+		}
+
+		userSession := &UserSession{AccessToken: "access-1", RefreshToken: "refresh-1", IDToken: "id-1"}
+		require.NoError(t, handler.RefreshSession(context.Background(), userSession))
+		assert.Equal(t, "access-2", userSession.AccessToken)
+		assert.Equal(t, "refresh-2", userSession.RefreshToken)
+		assert.Equal(t, "id-1", userSession.IDToken, "tokens not reissued are kept")
+		assert.WithinDuration(t, time.Now().Add(time.Hour), userSession.ExpiresAt, 5*time.Second)
+	})
+
+	t.Run("Rejected refresh token is dropped", func(t *testing.T) {
+		provider.handlers["/token"] = func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"invalid_grant"}`))
+		}
+
+		userSession := &UserSession{RefreshToken: "revoked"}
+		assert.Error(t, handler.RefreshSession(context.Background(), userSession))
+		assert.Empty(t, userSession.RefreshToken)
+		assert.ErrorIs(t, handler.RefreshSession(context.Background(), userSession), ErrNoRefreshToken)
+	})
+
+	t.Run("Transient failures keep the refresh token", func(t *testing.T) {
+		responses := map[string]func(w http.ResponseWriter){
+			"Server error": func(w http.ResponseWriter) {
+				w.WriteHeader(http.StatusBadGateway)
+			},
+			"Temporarily unavailable": func(w http.ResponseWriter) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusServiceUnavailable)
+				w.Write([]byte(`{"error":"temporarily_unavailable"}`))
+			},
+			"Rate limited": func(w http.ResponseWriter) {
+				w.WriteHeader(http.StatusTooManyRequests)
+			},
+		}
+
+		for name, respond := range responses {
+			t.Run(name, func(t *testing.T) {
+				provider.handlers["/token"] = func(w http.ResponseWriter, r *http.Request) {
+					respond(w)
+				}
+
+				userSession := &UserSession{AccessToken: "access-1", RefreshToken: "refresh-1"}
+				assert.Error(t, handler.RefreshSession(context.Background(), userSession))
+				assert.Equal(t, "refresh-1", userSession.RefreshToken)
+				assert.Equal(t, "access-1", userSession.AccessToken)
+			})
+		}
+	})
+}
+
+func TestRefreshRotatesOnPrivilegeChange(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	provider := newTestProvider(t)
+
+	store := memory.NewStore(&memory.Config{}, zap.NewNop())
+	defer store.Close()
+
+	handler, err := NewHandler(context.Background(), &config.OIDCConfig{
+		DiscoveryURL: provider.URL(),
+		ClientID:     "test-client",
+		ClientSecret: "test-secret",
+		RedirectURL:  "http://localhost:8080/callback",
+		Scopes:       []string{"openid"},
+	}, &config.SessionConfig{}, store, zap.NewNop())
+	require.NoError(t, err)
+
+	// The provider reissues an ID token with the groups of the moment
+	groups := []interface{}{"users"}
+	subject := "alice-sub"
+	provider.handlers["/token"] = func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "access-2",
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+			"id_token":     provider.sign(t, map[string]interface{}{"sub": subject, "aud": "test-client", "groups": groups}),
+		})
+	}
+
+	lifetime := &SessionLifetime{Idle: time.Hour, RefreshInterval: time.Minute}
+	router := gin.New()
+	router.Use(AuthMiddlewareWithConfig(store, zap.NewNop(), &MiddlewareConfig{
+		Lifetime:  lifetime,
+		Rotation:  &SessionRotation{Grace: 30 * time.Second},
+		Refresher: handler,
+	}))
+	router.GET("/api", func(c *gin.Context) {
+		c.String(http.StatusOK, c.GetString("user_id"))
+	})
+
+	request := func(sessionID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api", nil)
+		req.AddCookie(&http.Cookie{Name: "session_id", Value: sessionID})
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	newCookie := func(w *httptest.ResponseRecorder) string {
+		for _, c := range w.Result().Cookies() {
+			if c.Name == "session_id" {
+				return c.Value
+			}
+		}
+		return ""
+	}
+
+	// createSession stores a session in the users group whose access token expired
+	createSession := func(key string) {
+		userSession := &UserSession{ID: "alice", Subject: "alice-sub", Groups: []string{"users"}, RefreshToken: "refresh-1"}
+		lifetime.Start(userSession, time.Now())
+		userSession.RotatedAt = time.Now()
+		userSession.PrivilegeHash = privilegeHash(userSession)
+		userSession.ExpiresAt = time.Now().Add(-time.Minute)
+		_, err := store.Create(context.Background(), key, userSession, time.Hour)
+		require.NoError(t, err)
+	}
+
+	t.Run("Unchanged groups keep the session ID", func(t *testing.T) {
+		createSession("unchanged")
+
+		w := request("unchanged")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, newCookie(w))
+
+		var stored UserSession
+		require.NoError(t, store.Get(context.Background(), "unchanged", &stored))
+		assert.Equal(t, "access-2", stored.AccessToken)
+	})
+
+	t.Run("Changed groups rotate the session immediately", func(t *testing.T) {
+		createSession("privileged")
+		groups = []interface{}{"users", "admins"}
+
+		w := request("privileged")
+		assert.Equal(t, http.StatusOK, w.Code)
+		rotated := newCookie(w)
+		require.NotEmpty(t, rotated)
+		assert.False(t, sessionExists(t, store, "privileged"))
+
+		var stored UserSession
+		require.NoError(t, store.Get(context.Background(), rotated, &stored))
+		assert.Equal(t, []string{"users", "admins"}, stored.Groups)
+		assert.Equal(t, privilegeHash(&stored), stored.PrivilegeHash)
+	})
+
+	t.Run("An ID token for another subject is rejected", func(t *testing.T) {
+		createSession("other")
+		subject = "mallory-sub"
+
+		w := request("other")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, newCookie(w))
+
+		var stored UserSession
+		require.NoError(t, store.Get(context.Background(), "other", &stored))
+		assert.Equal(t, []string{"users"}, stored.Groups)
+		assert.Empty(t, stored.AccessToken)
+	})
+}
@@ -0,0 +1,125 @@
+package oidc
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/url"
+	"strings"
+
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/config"
+)
+
+// DefaultReturnURL is where users land after login without a valid return URL
+const DefaultReturnURL = "/"
+
+// ReturnURLPolicy decides where users may be sent after login. Relative paths
+// are always allowed; absolute URLs only to the configured hosts. Accepted
+// return URLs are signed together with the login state so they cannot be
+// swapped or altered before the callback.
+type ReturnURLPolicy struct {
+	allowedHosts []string
+	key          []byte
+}
+
+// NewReturnURLPolicy creates the return URL policy. Without a signing key the
+// key is derived from the client secret, which every proxy instance shares.
+func NewReturnURLPolicy(cfg *config.ReturnURLConfig, clientSecret string) *ReturnURLPolicy {
+	policy := &ReturnURLPolicy{}
+	signingKey := ""
+	if cfg != nil {
+		for _, host := range cfg.AllowedHosts {
+			policy.allowedHosts = append(policy.allowedHosts, strings.ToLower(host))
+		}
+		signingKey = cfg.SigningKey
+	}
+
+	if signingKey != "" {
+		policy.key = []byte(signingKey)
+	} else {
+		h := hmac.New(sha256.New, []byte(clientSecret))
+		h.Write([]byte("return-url signing key"))
+		policy.key = h.Sum(nil)
+	}
+	return policy
+}
+
+// Validate normalises a requested return URL and reports whether it is
+// allowed. Backslashes are treated as slashes, as browsers do, so that
+// "/\evil.com" is caught as the protocol-relative "//evil.com".
+func (p *ReturnURLPolicy) Validate(raw string) (string, bool) {
+	if raw == "" || strings.ContainsFunc(raw, isControl) {
+		return "", false
+	}
+
+	normalized := strings.ReplaceAll(raw, "\\", "/")
+	u, err := url.Parse(normalized)
+	if err != nil || u.User != nil || u.Opaque != "" {
+		return "", false
+	}
+
+	if u.Scheme == "" && u.Host == "" {
+		// Relative paths only; "//host" and "host/path" are not
+		if !strings.HasPrefix(normalized, "/") || strings.HasPrefix(normalized, "//") {
+			return "", false
+		}
+		if path, err := url.PathUnescape(u.EscapedPath()); err != nil || strings.HasPrefix(strings.ReplaceAll(path, "\\", "/"), "//") {
+			return "", false
+		}
+		return normalized, true
+	}
+
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || !p.hostAllowed(u.Hostname(), u.Host) {
+		return "", false
+	}
+	return u.String(), true
+}
+
+// hostAllowed matches a host, with or without its port, against the allowlist
+func (p *ReturnURLPolicy) hostAllowed(hostname, hostport string) bool {
+	hostname = strings.ToLower(hostname)
+	hostport = strings.ToLower(hostport)
+	for _, allowed := range p.allowedHosts {
+		if suffix, ok := strings.CutPrefix(allowed, "*."); ok {
+			if strings.HasSuffix(hostname, "."+suffix) || strings.HasSuffix(hostport, "."+suffix) {
+				return true
+			}
+			continue
+		}
+		if hostname == allowed || hostport == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// Sign binds a validated return URL to the login state
+func (p *ReturnURLPolicy) Sign(state, returnURL string) string {
+	return returnURL + "." + p.mac(state, returnURL)
+}
+
+// Verify checks a value created by Sign for the same state and re-validates
+// the return URL against the current policy
+func (p *ReturnURLPolicy) Verify(state, signed string) (string, bool) {
+	i := strings.LastIndexByte(signed, '.')
+	if i <= 0 {
+		return "", false
+	}
+	returnURL, signature := signed[:i], signed[i+1:]
+	if !hmac.Equal([]byte(p.mac(state, returnURL)), []byte(signature)) {
+		return "", false
+	}
+	return p.Validate(returnURL)
+}
+
+// mac returns the base64url HMAC-SHA256 of a return URL and its login state
+func (p *ReturnURLPolicy) mac(state, returnURL string) string {
+	h := hmac.New(sha256.New, p.key)
+	h.Write([]byte("return-url\n" + state + "\n" + returnURL))
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+}
+
+// isControl reports whether r is an ASCII control character
+func isControl(r rune) bool {
+	return r < 0x20 || r == 0x7f
+}
@@ -0,0 +1,127 @@
+package oidc
+
+import (
+	"context"
+	"net/url"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/config"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/session/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestReturnURLPolicyValidate(t *testing.T) {
+	policy := NewReturnURLPolicy(&config.ReturnURLConfig{
+		AllowedHosts: []string{"app.example.com", "*.tools.example.com", "localhost:3000"},
+	}, "secret")
+
+	tests := []struct {
+		name    string
+		raw     string
+		want    string
+		allowed bool
+	}{
+		{name: "relative path", raw: "/dashboard?tab=1#top", want: "/dashboard?tab=1#top", allowed: true},
+		{name: "root", raw: "/", want: "/", allowed: true},
+		{name: "empty", raw: ""},
+		{name: "protocol relative", raw: "//evil.com/path"},
+		{name: "backslash", raw: "/\\evil.com"},
+		{name: "double backslash", raw: "\\\\evil.com"},
+		{name: "encoded slashes", raw: "/%2F%2Fevil.com"},
+		{name: "path without leading slash", raw: "evil.com/path"},
+		{name: "javascript", raw: "javascript:alert(1)"},
+		{name: "data", raw: "data:text/html,hi"},
+		{name: "control characters", raw: "/\r\nLocation: https://evil.com"},
+		{name: "tab in scheme", raw: "/\t/evil.com"},
+		{name: "userinfo", raw: "https://app.example.com@evil.com/"},
+		{name: "unlisted host", raw: "https://evil.com/"},
+		{name: "suffix of an allowed host", raw: "https://evilapp.example.com/"},
+		{name: "allowed host", raw: "https://App.Example.com/home", want: "https://App.Example.com/home", allowed: true},
+		{name: "allowed subdomain", raw: "https://a.tools.example.com/", want: "https://a.tools.example.com/", allowed: true},
+		{name: "wildcard apex", raw: "https://tools.example.com/"},
+		{name: "allowed host and port", raw: "http://localhost:3000/", want: "http://localhost:3000/", allowed: true},
+		{name: "other port", raw: "http://localhost:4000/"},
+		{name: "non-http scheme", raw: "ftp://app.example.com/"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, allowed := policy.Validate(tt.raw)
+			assert.Equal(t, tt.allowed, allowed)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	t.Run("relative only by default", func(t *testing.T) {
+		_, allowed := NewReturnURLPolicy(nil, "secret").Validate("https://app.example.com/")
+		assert.False(t, allowed)
+	})
+}
+
+func TestReturnURLPolicySignature(t *testing.T) {
+	policy := NewReturnURLPolicy(&config.ReturnURLConfig{}, "secret")
+	signed := policy.Sign("state-1", "/reports/2024.html")
+
+	got, ok := policy.Verify("state-1", signed)
+	assert.True(t, ok)
+	assert.Equal(t, "/reports/2024.html", got)
+
+	_, ok = policy.Verify("state-2", signed)
+	assert.False(t, ok, "bound to the state")
+
+	_, ok = policy.Verify("state-1", "/admin"+signed[len("/reports/2024.html"):])
+	assert.False(t, ok, "altered URL")
+
+	_, ok = NewReturnURLPolicy(&config.ReturnURLConfig{}, "other").Verify("state-1", signed)
+	assert.False(t, ok, "other key")
+
+	_, ok = policy.Verify("state-1", "/reports")
+	assert.False(t, ok, "unsigned")
+}
+
+func TestLoginReturnURL(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	provider := newTestProvider(t)
+
+	store := memory.NewStore(&memory.Config{}, zap.NewNop())
+	defer store.Close()
+
+	handler, err := NewHandler(context.Background(), &config.OIDCConfig{
+		DiscoveryURL: provider.URL(),
+		ClientID:     "test-client",
+		ClientSecret: "test-secret",
+		RedirectURL:  "http://localhost:8080/callback",
+		Scopes:       []string{"openid"},
+	}, &config.SessionConfig{}, store, zap.NewNop())
+	require.NoError(t, err)
+
+	router := gin.New()
+	router.GET("/login", handler.Authorize)
+	router.GET("/callback", handler.Callback)
+
+	tests := []struct {
+		name        string
+		redirectURI string
+		want        string
+	}{
+		{name: "relative path", redirectURI: "/dashboard?tab=1", want: "/dashboard?tab=1"},
+		{name: "open redirect", redirectURI: "https://evil.com/", want: "/"},
+		{name: "protocol relative", redirectURI: "//evil.com", want: "/"},
+		{name: "backslash", redirectURI: "/\\evil.com", want: "/"},
+		{name: "none", want: "/"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loginURL := "/login"
+			if tt.redirectURI != "" {
+				loginURL += "?redirect_uri=" + url.QueryEscape(tt.redirectURI)
+			}
+			w := authorizationCodeFlow(t, provider, router, loginURL, "alice")
+			assert.Equal(t, tt.want, w.Header().Get("Location"))
+		})
+	}
+}
@@ -0,0 +1,278 @@
+package oidc
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/config"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/session"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/session/cookie"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/session/meta"
+	"go.uber.org/zap"
+	"golang.org/x/sync/singleflight"
+)
+
+// DefaultRotationGrace is how long a rotated-out session ID keeps working
+// when the configuration does not set a grace period
+const DefaultRotationGrace = 30 * time.Second
+
+// SessionRotation decides when a user session gets a new ID. IDs rotate
+// periodically, and immediately when the session's groups or roles change.
+type SessionRotation struct {
+	// Interval is the time between periodic rotations (0 disables)
+	Interval time.Duration
+	// Grace is how long the previous ID stays valid after a periodic
+	// rotation, so that concurrent requests still carrying it succeed
+	Grace time.Duration
+}
+
+// NewSessionRotation creates the session rotation policy from the session configuration
+func NewSessionRotation(cfg *config.SessionConfig) *SessionRotation {
+	if cfg == nil {
+		return &SessionRotation{Grace: DefaultRotationGrace}
+	}
+
+	grace := cfg.RotationGrace
+	if grace <= 0 {
+		grace = DefaultRotationGrace
+	}
+	return &SessionRotation{
+		Interval: cfg.RotationInterval,
+		Grace:    grace,
+	}
+}
+
+// Due reports whether the session should get a new ID now, and whether the
+// current ID must stop working immediately rather than after the grace
+// period. Sessions issued before rotation existed are always due.
+func (r *SessionRotation) Due(userSession *UserSession, now time.Time) (due, immediate bool) {
+	if userSession.ReplacedBy != "" {
+		// Already rotated; the successor carries on
+		return false, false
+	}
+	if userSession.PrivilegeHash != "" && userSession.PrivilegeHash != privilegeHash(userSession) {
+		return true, true
+	}
+	if userSession.RotatedAt.IsZero() {
+		return true, false
+	}
+	return r.Interval > 0 && now.Sub(userSession.RotatedAt) >= r.Interval, false
+}
+
+// privilegeHash fingerprints the groups and roles of a session
+func privilegeHash(userSession *UserSession) string {
+	groups := append([]string(nil), userSession.Groups...)
+	roles := append([]string(nil), userSession.Roles...)
+	sort.Strings(groups)
+	sort.Strings(roles)
+
+	sum := sha256.Sum256([]byte(strings.Join(groups, "\x00") + "\x01" + strings.Join(roles, "\x00")))
+	return hex.EncodeToString(sum[:])
+}
+
+// newSessionID returns an unpredictable user session key
+func newSessionID() (string, error) {
+	id, err := generateRandomString(32)
+	if err != nil {
+		return "", err
+	}
+	return "user:" + id, nil
+}
+
+// sessionIssuer stores user sessions under fresh random IDs and points the
+// session cookie at them
+type sessionIssuer struct {
+	store      session.Store
+	sessions   *session.TypedStore[UserSession]
+	cookies    *cookie.Manager
+	lifetime   *SessionLifetime
+	rotation   *SessionRotation
+	maxPerUser int
+	logger     *zap.Logger
+}
+
+// issue stores a newly authenticated session under a new ID, sets the cookie
+// and evicts the user's oldest sessions beyond the per-user limit
+func (i *sessionIssuer) issue(c *gin.Context, userSession *UserSession) (string, error) {
+	ctx := c.Request.Context()
+	now := userSession.CreatedAt
+
+	if userSession.GrantID == "" {
+		grantID, err := newSessionID()
+		if err != nil {
+			return "", fmt.Errorf("failed to generate grant ID: %w", err)
+		}
+		userSession.GrantID = grantID
+	}
+
+	i.lifetime.Start(userSession, now)
+	userSession.RotatedAt = now
+	userSession.PrivilegeHash = privilegeHash(userSession)
+	ttl := i.lifetime.TTL(userSession, now)
+
+	sessionID, err := i.create(c, userSession, ttl, time.Time{})
+	if err != nil {
+		return "", err
+	}
+
+	i.cookies.Set(c.Writer, c.Request, sessionID, CookieMaxAge(ttl))
+	i.enforceLimit(ctx, userSession.ID, sessionID)
+	return sessionID, nil
+}
+
+// sessionRotations coalesces concurrent rotations of a session, keyed by the
+// old session ID, so parallel requests with one cookie share a successor
+var sessionRotations singleflight.Group
+
+// rotated is the outcome of a rotation shared between concurrent requests
+type rotated struct {
+	sessionID string
+	session   UserSession
+	ttl       time.Duration
+}
+
+// rotate moves a session to a new ID and re-issues the cookie. The old ID is
+// deleted when immediate is set; otherwise it is marked as replaced and
+// expires after the grace period. Concurrent rotations of one session create
+// a single successor.
+func (i *sessionIssuer) rotate(c *gin.Context, oldID string, userSession *UserSession, immediate bool, now time.Time) (string, error) {
+	result, err, _ := sessionRotations.Do(oldID, func() (interface{}, error) {
+		// A request that read the session before a finished rotation marked
+		// it joins that rotation instead of starting another
+		ctx := c.Request.Context()
+		if current, err := i.sessions.Get(ctx, oldID); err == nil && current.ReplacedBy != "" {
+			successor, err := i.sessions.Get(ctx, current.ReplacedBy)
+			if err != nil {
+				return nil, fmt.Errorf("failed to load rotated session: %w", err)
+			}
+			return &rotated{sessionID: current.ReplacedBy, session: *successor, ttl: i.lifetime.TTL(successor, now)}, nil
+		}
+
+		successor := *userSession
+		return i.replace(c, oldID, &successor, immediate, now)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	r := result.(*rotated)
+	*userSession = r.session
+	i.cookies.Set(c.Writer, c.Request, r.sessionID, CookieMaxAge(r.ttl))
+	return r.sessionID, nil
+}
+
+// replace stores the session under a new ID and retires the old one
+func (i *sessionIssuer) replace(c *gin.Context, oldID string, userSession *UserSession, immediate bool, now time.Time) (*rotated, error) {
+	ctx := c.Request.Context()
+
+	userSession.RotatedAt = now
+	userSession.PrivilegeHash = privilegeHash(userSession)
+	ttl := i.lifetime.TTL(userSession, now)
+
+	sessionID, err := i.create(c, userSession, ttl, userSession.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if immediate {
+		if err := i.store.Delete(ctx, oldID); err != nil {
+			i.logger.Warn("Failed to delete rotated session", zap.Error(err), zap.String("session_id", oldID))
+		}
+	} else {
+		replaced := *userSession
+		replaced.ReplacedBy = sessionID
+		if err := i.sessions.Update(ctx, oldID, &replaced); err != nil {
+			i.logger.Warn("Failed to mark rotated session", zap.Error(err), zap.String("session_id", oldID))
+		}
+		grace := i.rotation.Grace
+		if ttl > 0 && ttl < grace {
+			grace = ttl
+		}
+		if err := i.store.Refresh(ctx, oldID, grace); err != nil {
+			i.logger.Warn("Failed to shorten rotated session", zap.Error(err), zap.String("session_id", oldID))
+		}
+	}
+
+	i.logger.Debug("Rotated session ID",
+		zap.String("user_id", userSession.ID),
+		zap.Bool("immediate", immediate),
+	)
+	return &rotated{sessionID: sessionID, session: *userSession, ttl: ttl}, nil
+}
+
+// create stores the session under a new ID, indexes it and records its
+// metadata. A non-zero createdAt is kept as the metadata creation time.
+func (i *sessionIssuer) create(c *gin.Context, userSession *UserSession, ttl time.Duration, createdAt time.Time) (string, error) {
+	ctx := c.Request.Context()
+
+	key, err := newSessionID()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate session ID: %w", err)
+	}
+	sessionID, err := i.sessions.Create(ctx, key, userSession, ttl)
+	if err != nil {
+		return "", err
+	}
+	indexUserSession(ctx, i.store, sessionID, userSession, i.logger)
+
+	// Record who owns the session and from where, for session listings
+	if err := i.store.SetMetadata(ctx, sessionID, &session.Metadata{
+		UserID:    userSession.ID,
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+		Device:    meta.Device(c.Request.UserAgent()),
+		CreatedAt: createdAt,
+	}); err != nil {
+		i.logger.Warn("Failed to store session metadata", zap.Error(err), zap.String("session_id", sessionID))
+	}
+	return sessionID, nil
+}
+
+// enforceLimit deletes the user's oldest sessions beyond the per-user limit,
+// never the current one
+func (i *sessionIssuer) enforceLimit(ctx context.Context, userID, currentID string) {
+	if i.maxPerUser <= 0 || userID == "" {
+		return
+	}
+
+	infos, err := i.store.ListUserSessions(ctx, userID)
+	if err != nil {
+		i.logger.Warn("Failed to list user sessions", zap.Error(err), zap.String("user_id", userID))
+		return
+	}
+	if len(infos) <= i.maxPerUser {
+		return
+	}
+
+	sort.Slice(infos, func(a, b int) bool {
+		if !infos[a].CreatedAt.Equal(infos[b].CreatedAt) {
+			return infos[a].CreatedAt.Before(infos[b].CreatedAt)
+		}
+		return infos[a].LastSeen.Before(infos[b].LastSeen)
+	})
+
+	excess := len(infos) - i.maxPerUser
+	for _, info := range infos {
+		if excess == 0 {
+			break
+		}
+		if info.Key == currentID {
+			continue
+		}
+		if err := i.store.Delete(ctx, info.Key); err != nil {
+			i.logger.Warn("Failed to evict session", zap.Error(err), zap.String("session_id", info.Key))
+			continue
+		}
+		i.logger.Info("Evicted session beyond per-user limit",
+			zap.String("user_id", userID),
+			zap.String("session_id", info.Key),
+		)
+		excess--
+	}
+}
@@ -0,0 +1,340 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/config"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/session"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/session/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestSessionRotationDue(t *testing.T) {
+	rotation := NewSessionRotation(&config.SessionConfig{RotationInterval: 15 * time.Minute})
+	assert.Equal(t, DefaultRotationGrace, rotation.Grace)
+
+	now := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	issued := func(rotatedAt time.Time) *UserSession {
+		userSession := &UserSession{ID: "alice", Groups: []string{"users", "admins"}, RotatedAt: rotatedAt}
+		userSession.PrivilegeHash = privilegeHash(userSession)
+		return userSession
+	}
+
+	tests := []struct {
+		name          string
+		userSession   *UserSession
+		wantDue       bool
+		wantImmediate bool
+	}{
+		{name: "recently rotated", userSession: issued(now.Add(-time.Minute))},
+		{name: "interval elapsed", userSession: issued(now.Add(-15 * time.Minute)), wantDue: true},
+		{name: "issued before rotation", userSession: &UserSession{ID: "alice"}, wantDue: true},
+		{
+			name: "privileges changed",
+			userSession: func() *UserSession {
+				userSession := issued(now.Add(-time.Minute))
+				userSession.Groups = append(userSession.Groups, "operators")
+				return userSession
+			}(),
+			wantDue:       true,
+			wantImmediate: true,
+		},
+		{
+			name: "group order does not matter",
+			userSession: func() *UserSession {
+				userSession := issued(now.Add(-time.Minute))
+				userSession.Groups = []string{"admins", "users"}
+				return userSession
+			}(),
+		},
+		{
+			name: "already replaced",
+			userSession: func() *UserSession {
+				userSession := issued(now.Add(-time.Hour))
+				userSession.ReplacedBy = "user:next"
+				return userSession
+			}(),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			due, immediate := rotation.Due(tt.userSession, now)
+			assert.Equal(t, tt.wantDue, due)
+			assert.Equal(t, tt.wantImmediate, immediate)
+		})
+	}
+
+	t.Run("periodic rotation disabled", func(t *testing.T) {
+		due, _ := NewSessionRotation(&config.SessionConfig{}).Due(issued(now.Add(-24*time.Hour)), now)
+		assert.False(t, due)
+	})
+}
+
+// login runs the authorization code flow for subject and returns the
+// session ID set by the callback
+func login(t *testing.T, provider *testProvider, router *gin.Engine, subject string, cookies ...*http.Cookie) string {
+	t.Helper()
+
+	w := authorizationCodeFlow(t, provider, router, "/login", subject, cookies...)
+	for _, c := range w.Result().Cookies() {
+		if c.Name == "session_id" {
+			return c.Value
+		}
+	}
+	t.Fatal("callback did not set a session cookie")
+	return ""
+}
+
+// authorizationCodeFlow starts a login at loginURL, completes it at the
+// provider as subject and returns the callback response
+func authorizationCodeFlow(t *testing.T, provider *testProvider, router *gin.Engine, loginURL, subject string, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+	t.Helper()
+
+	provider.handlers["/token"] = func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token":  "access-" + subject,
+			"refresh_token": "refresh-" + subject,
+			"token_type":    "Bearer",
+			"expires_in":    3600,
+			"id_token":      provider.sign(t, map[string]interface{}{"aud": "test-client", "sub": subject}),
+		})
+	}
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, loginURL, nil))
+	require.Equal(t, http.StatusFound, w.Code)
+	location, err := url.Parse(w.Header().Get("Location"))
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodGet, "/callback?code=test-code&state="+location.Query().Get("state"), nil)
+	for _, c := range cookies {
+		req.AddCookie(c)
+	}
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusFound, w.Code, w.Body.String())
+	return w
+}
+
+func TestCallbackSessionIDs(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	provider := newTestProvider(t)
+
+	store := memory.NewStore(&memory.Config{}, zap.NewNop())
+	defer store.Close()
+
+	handler, err := NewHandler(context.Background(), &config.OIDCConfig{
+		DiscoveryURL: provider.URL(),
+		ClientID:     "test-client",
+		ClientSecret: "test-secret",
+		RedirectURL:  "http://localhost:8080/callback",
+		Scopes:       []string{"openid"},
+	}, &config.SessionConfig{MaxSessionsPerUser: 2}, store, zap.NewNop())
+	require.NoError(t, err)
+
+	router := gin.New()
+	router.GET("/login", handler.Authorize)
+	router.GET("/callback", handler.Callback)
+
+	t.Run("Each login gets a new random ID", func(t *testing.T) {
+		first := login(t, provider, router, "carol")
+		second := login(t, provider, router, "carol")
+
+		assert.NotEqual(t, first, second)
+		assert.NotEqual(t, "user:carol", first)
+		assert.True(t, strings.HasPrefix(first, "user:"))
+		assert.True(t, sessionExists(t, store, first))
+		assert.True(t, sessionExists(t, store, second))
+
+		var stored UserSession
+		require.NoError(t, store.Get(context.Background(), second, &stored))
+		assert.Equal(t, "carol", stored.ID)
+		assert.False(t, stored.RotatedAt.IsZero())
+		assert.Equal(t, privilegeHash(&stored), stored.PrivilegeHash)
+	})
+
+	t.Run("The pre-login session is invalidated", func(t *testing.T) {
+		_, err := store.Create(context.Background(), "planted", &UserSession{ID: "mallory"}, time.Hour)
+		require.NoError(t, err)
+
+		sessionID := login(t, provider, router, "dave", &http.Cookie{Name: "session_id", Value: "planted"})
+		assert.NotEqual(t, "planted", sessionID)
+		assert.False(t, sessionExists(t, store, "planted"))
+	})
+
+	t.Run("The oldest sessions beyond the limit are evicted", func(t *testing.T) {
+		first := login(t, provider, router, "erin")
+		second := login(t, provider, router, "erin")
+		third := login(t, provider, router, "erin")
+
+		assert.False(t, sessionExists(t, store, first))
+		assert.True(t, sessionExists(t, store, second))
+		assert.True(t, sessionExists(t, store, third))
+
+		infos, err := store.ListUserSessions(context.Background(), "erin")
+		require.NoError(t, err)
+		assert.Len(t, infos, 2)
+	})
+}
+
+func TestAuthMiddlewareRotation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	store := memory.NewStore(&memory.Config{}, zap.NewNop())
+	defer store.Close()
+
+	lifetime := &SessionLifetime{Idle: time.Hour, RefreshInterval: time.Minute}
+	router := gin.New()
+	router.Use(AuthMiddlewareWithConfig(store, zap.NewNop(), &MiddlewareConfig{
+		Lifetime: lifetime,
+		Rotation: &SessionRotation{Interval: 15 * time.Minute, Grace: 30 * time.Second},
+	}))
+	router.GET("/api", func(c *gin.Context) {
+		c.String(http.StatusOK, c.GetString("user_id"))
+	})
+
+	request := func(sessionID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api", nil)
+		req.AddCookie(&http.Cookie{Name: "session_id", Value: sessionID})
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	newCookie := func(w *httptest.ResponseRecorder) string {
+		for _, c := range w.Result().Cookies() {
+			if c.Name == "session_id" {
+				return c.Value
+			}
+		}
+		return ""
+	}
+
+	createSession := func(key string, rotatedAt time.Time) *UserSession {
+		userSession := &UserSession{ID: "alice", Groups: []string{"users"}, ExpiresAt: time.Now().Add(time.Hour)}
+		lifetime.Start(userSession, time.Now())
+		userSession.RotatedAt = rotatedAt
+		userSession.PrivilegeHash = privilegeHash(userSession)
+		_, err := store.Create(context.Background(), key, userSession, time.Hour)
+		require.NoError(t, err)
+		return userSession
+	}
+
+	t.Run("A recently rotated session keeps its ID", func(t *testing.T) {
+		createSession("fresh", time.Now())
+
+		w := request("fresh")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, newCookie(w))
+	})
+
+	t.Run("Periodic rotation keeps the old ID for the grace period", func(t *testing.T) {
+		createSession("old", time.Now().Add(-20*time.Minute))
+
+		w := request("old")
+		assert.Equal(t, http.StatusOK, w.Code)
+		rotated := newCookie(w)
+		require.NotEmpty(t, rotated)
+		assert.NotEqual(t, "old", rotated)
+
+		var replaced UserSession
+		require.NoError(t, store.Get(context.Background(), "old", &replaced))
+		assert.Equal(t, rotated, replaced.ReplacedBy)
+
+		// A concurrent request with the old ID succeeds without rotating again
+		w = request("old")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "alice", w.Body.String())
+		assert.Empty(t, newCookie(w))
+
+		w = request(rotated)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, newCookie(w))
+	})
+
+	t.Run("Parallel requests share one successor", func(t *testing.T) {
+		createSession("parallel", time.Now().Add(-20*time.Minute))
+		before, err := store.ListUserSessions(context.Background(), "alice")
+		require.NoError(t, err)
+
+		// Every request reads the session before any of them rotates it
+		const requests = 8
+		barrier := &barrierStore{Store: store, key: "parallel", waiting: requests}
+		barrier.arrived.Add(requests)
+		parallel := gin.New()
+		parallel.Use(AuthMiddlewareWithConfig(barrier, zap.NewNop(), &MiddlewareConfig{
+			Lifetime: lifetime,
+			Rotation: &SessionRotation{Interval: 15 * time.Minute, Grace: 30 * time.Second},
+		}))
+		parallel.GET("/api", func(c *gin.Context) {
+			c.String(http.StatusOK, c.GetString("user_id"))
+		})
+
+		cookies := make(chan string, requests)
+		var wg sync.WaitGroup
+		for i := 0; i < requests; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				req := httptest.NewRequest(http.MethodGet, "/api", nil)
+				req.AddCookie(&http.Cookie{Name: "session_id", Value: "parallel"})
+				w := httptest.NewRecorder()
+				parallel.ServeHTTP(w, req)
+				assert.Equal(t, http.StatusOK, w.Code)
+				cookies <- newCookie(w)
+			}()
+		}
+		wg.Wait()
+		close(cookies)
+
+		successors := make(map[string]bool)
+		for c := range cookies {
+			require.NotEmpty(t, c, "every request is pointed at the successor")
+			successors[c] = true
+		}
+		assert.Len(t, successors, 1)
+
+		after, err := store.ListUserSessions(context.Background(), "alice")
+		require.NoError(t, err)
+		assert.Len(t, after, len(before)+1, "one successor, no orphans")
+	})
+}
+
+// barrierStore holds the first reads of key until all of them have read it
+type barrierStore struct {
+	session.Store
+	key     string
+	mu      sync.Mutex
+	waiting int
+	arrived sync.WaitGroup
+}
+
+func (s *barrierStore) Get(ctx context.Context, key string, data interface{}) error {
+	err := s.Store.Get(ctx, key, data)
+	if key == s.key {
+		s.mu.Lock()
+		hold := s.waiting > 0
+		if hold {
+			s.waiting--
+		}
+		s.mu.Unlock()
+		if hold {
+			s.arrived.Done()
+			s.arrived.Wait()
+		}
+	}
+	return err
+}
@@ -0,0 +1,468 @@
+package oidc
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/auth/pages"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/config"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/pathprefix"
+	"go.uber.org/zap"
+	"golang.org/x/oauth2"
+)
+
+// InsufficientUserAuthentication is the error code of step-up challenges (RFC 9470)
+const InsufficientUserAuthentication = "insufficient_user_authentication"
+
+// StepUpParam is the login query parameter naming the step-up rule to satisfy
+const StepUpParam = "step_up"
+
+// maxToolCallBody is how much of a request body is read to find MCP tool calls
+const maxToolCallBody = 1 << 20
+
+// StepUpRequirement is the authentication a request needs beyond a valid session
+type StepUpRequirement struct {
+	// ACRValues lists the acceptable acr claim values
+	ACRValues []string `json:"acr_values,omitempty"`
+	// AMR lists the methods that must all appear in the amr claim
+	AMR []string `json:"amr,omitempty"`
+	// MaxAge is how long ago the user may have last authenticated
+	MaxAge time.Duration `json:"max_age,omitempty"`
+}
+
+// Authentication is how and when the user last authenticated at the provider.
+// It is kept apart from the session's claims, whose non-essential entries the
+// cookie store drops when the session would not fit its cookies.
+type Authentication struct {
+	// ACR is the authentication context class reference (acr claim)
+	ACR string `json:"acr,omitempty"`
+	// AMR lists the authentication methods used (amr claim)
+	AMR []string `json:"amr,omitempty"`
+	// AuthTime is when the user authenticated (auth_time claim; zero: unknown)
+	AuthTime time.Time `json:"auth_time,omitempty"`
+}
+
+// authenticationFromClaims reads the acr, amr and auth_time claims of an ID token
+func authenticationFromClaims(claims map[string]interface{}) Authentication {
+	acr, _ := claims["acr"].(string)
+	authTime, _ := claimTime(claims["auth_time"])
+	return Authentication{
+		ACR:      acr,
+		AMR:      claimStrings(claims["amr"]),
+		AuthTime: authTime,
+	}
+}
+
+// Unmet returns why a login does not meet the requirement, or ""
+func (r *StepUpRequirement) Unmet(auth Authentication, now time.Time) string {
+	if len(r.ACRValues) > 0 && !containsString(r.ACRValues, auth.ACR) {
+		return "authentication context class not accepted"
+	}
+	for _, method := range r.AMR {
+		if !containsString(auth.AMR, method) {
+			return "authentication method " + method + " missing"
+		}
+	}
+	if r.MaxAge > 0 && (auth.AuthTime.IsZero() || now.Sub(auth.AuthTime) > r.MaxAge) {
+		return "authentication too old"
+	}
+	return ""
+}
+
+// authCodeOptions returns the authorization request parameters asking the
+// provider for a login that meets the requirement
+func (r *StepUpRequirement) authCodeOptions() []oauth2.AuthCodeOption {
+	opts := []oauth2.AuthCodeOption{oauth2.SetAuthURLParam("prompt", "login")}
+	if len(r.ACRValues) > 0 {
+		opts = append(opts, oauth2.SetAuthURLParam("acr_values", strings.Join(r.ACRValues, " ")))
+	}
+	if r.MaxAge > 0 {
+		opts = append(opts, oauth2.SetAuthURLParam("max_age", strconv.Itoa(maxAgeSeconds(r.MaxAge))))
+	}
+	return opts
+}
+
+// challenge returns the WWW-Authenticate challenge for API clients (RFC 9470)
+func (r *StepUpRequirement) challenge(realm string) string {
+	challenge := fmt.Sprintf(`Bearer realm="%s", error="%s", error_description="A stronger or more recent authentication is required"`,
+		realm, InsufficientUserAuthentication)
+	if len(r.ACRValues) > 0 {
+		challenge += fmt.Sprintf(`, acr_values="%s"`, strings.Join(r.ACRValues, " "))
+	}
+	if r.MaxAge > 0 {
+		challenge += fmt.Sprintf(`, max_age=%d`, maxAgeSeconds(r.MaxAge))
+	}
+	return challenge
+}
+
+// stepUpRule applies a requirement to requests under a path prefix,
+// optionally only to calls of some MCP tools
+type stepUpRule struct {
+	pathPrefix  string
+	tools       map[string]bool
+	requirement StepUpRequirement
+}
+
+// StepUpPolicy decides which requests need a stronger or more recent login
+// than the session's. Rules are tried in order; the first match wins.
+type StepUpPolicy struct {
+	rules []stepUpRule
+	// inspectTools is set when a rule matches MCP tools, which requires
+	// reading request bodies
+	inspectTools bool
+}
+
+// NewStepUpPolicy creates the step-up policy from the configured rules
+func NewStepUpPolicy(cfg *config.StepUpConfig) *StepUpPolicy {
+	policy := &StepUpPolicy{}
+	if cfg == nil {
+		return policy
+	}
+	for _, r := range cfg.Rules {
+		rule := stepUpRule{
+			pathPrefix: r.PathPrefix,
+			requirement: StepUpRequirement{
+				ACRValues: r.ACRValues,
+				AMR:       r.AMR,
+				MaxAge:    r.MaxAge,
+			},
+		}
+		if len(r.Tools) > 0 {
+			rule.tools = make(map[string]bool, len(r.Tools))
+			for _, tool := range r.Tools {
+				rule.tools[tool] = true
+			}
+			policy.inspectTools = true
+		}
+		policy.rules = append(policy.rules, rule)
+	}
+	return policy
+}
+
+// Enabled reports whether any rule is configured
+func (p *StepUpPolicy) Enabled() bool {
+	return len(p.rules) > 0
+}
+
+// Match returns the index and requirement of the first rule matching a
+// request for path calling tools, or -1 and nil. anyTool is set when the
+// called tools could not be determined; tool rules then match as well.
+func (p *StepUpPolicy) Match(path string, tools []string, anyTool bool) (int, *StepUpRequirement) {
+	for i := range p.rules {
+		rule := &p.rules[i]
+		if !pathprefix.Match(path, rule.pathPrefix) {
+			continue
+		}
+		if rule.tools == nil || anyTool {
+			return i, &rule.requirement
+		}
+		for _, tool := range tools {
+			if rule.tools[tool] {
+				return i, &rule.requirement
+			}
+		}
+	}
+	return -1, nil
+}
+
+// Rule returns the requirement of a rule by index
+func (p *StepUpPolicy) Rule(index int) (*StepUpRequirement, bool) {
+	if index < 0 || index >= len(p.rules) {
+		return nil, false
+	}
+	return &p.rules[index].requirement, true
+}
+
+// StepUp returns the step-up policy
+func (h *Handler) StepUp() *StepUpPolicy {
+	if h.stepUp == nil {
+		h.stepUp = NewStepUpPolicy(&h.config.StepUp)
+	}
+	return h.stepUp
+}
+
+// StepUpMiddleware enforces the step-up rules on authenticated requests. It
+// runs after the auth middleware. Browsers loading a page with GET are sent
+// to log in again with the required acr_values and max_age and return to the
+// page. Every other request, form POSTs included, gets a 401 challenge
+// instead, since a redirect would drop its body; the client retries it after
+// logging in at the login URL.
+func (h *Handler) StepUpMiddleware(realm string) gin.HandlerFunc {
+	if realm == "" {
+		realm = DefaultRealm
+	}
+	return func(c *gin.Context) {
+		policy := h.StepUp()
+		userSession := GetSessionFromContext(c.Request.Context())
+		if !policy.Enabled() || userSession == nil {
+			c.Next()
+			return
+		}
+
+		var tools []string
+		anyTool := false
+		if policy.inspectTools {
+			tools, anyTool = mcpToolCalls(c.Request)
+		}
+		index, requirement := policy.Match(c.Request.URL.Path, tools, anyTool)
+		if requirement == nil {
+			c.Next()
+			return
+		}
+		reason := requirement.Unmet(userSession.Authentication, time.Now())
+		if reason == "" {
+			c.Next()
+			return
+		}
+
+		h.logger.Info("Step-up authentication required",
+			zap.String("user_id", userSession.ID),
+			zap.String("path", c.Request.URL.Path),
+			zap.Strings("tools", tools),
+			zap.String("reason", reason),
+		)
+
+		c.Header("WWW-Authenticate", requirement.challenge(realm))
+		body := gin.H{
+			"error":             InsufficientUserAuthentication,
+			"error_description": "A stronger or more recent authentication is required",
+		}
+
+		// Bearer clients obtain a new token from the provider themselves;
+		// device clients start a new device authorization with the
+		// challenge's acr_values and max_age
+		if AuthenticatedByBearer(c.Request.Context()) {
+			c.JSON(http.StatusUnauthorized, body)
+			c.Abort()
+			return
+		}
+
+		loginURL := LoginPath + "?redirect_uri=" + url.QueryEscape(c.Request.URL.RequestURI()) +
+			"&" + StepUpParam + "=" + strconv.Itoa(index)
+		if pages.IsNavigation(c.Request) && (c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead) {
+			c.Redirect(http.StatusFound, loginURL)
+			c.Abort()
+			return
+		}
+
+		// Redirecting would drop the request body; the client retries after login
+		body["login_url"] = loginURL
+		c.Set(pages.LoginURLKey, loginURL)
+		h.Pages().Respond(c, http.StatusUnauthorized, pages.LoginRequired, "Please sign in again to continue", body)
+	}
+}
+
+// stepUpRequested returns the requirement named by a login request's step_up
+// parameter, or given by its acr_values and max_age parameters, if any
+func (h *Handler) stepUpRequested(c *gin.Context) *StepUpRequirement {
+	raw := c.Query(StepUpParam)
+	if raw == "" {
+		requirement, err := requirementFromParams(c.Query("acr_values"), c.Query("max_age"))
+		if err != nil {
+			return nil
+		}
+		return requirement
+	}
+	index, err := strconv.Atoi(raw)
+	if err != nil {
+		return nil
+	}
+	requirement, ok := h.StepUp().Rule(index)
+	if !ok {
+		return nil
+	}
+	return requirement
+}
+
+// requirementFromParams reads the acr_values and max_age parameters of an
+// authorization request; nil when neither is set
+func requirementFromParams(acrValues, maxAge string) (*StepUpRequirement, error) {
+	if acrValues == "" && maxAge == "" {
+		return nil, nil
+	}
+	requirement := &StepUpRequirement{ACRValues: strings.Fields(acrValues)}
+	if maxAge != "" {
+		seconds, err := strconv.Atoi(maxAge)
+		if err != nil || seconds <= 0 {
+			return nil, fmt.Errorf("max_age must be a positive number of seconds")
+		}
+		requirement.MaxAge = time.Duration(seconds) * time.Second
+	}
+	return requirement, nil
+}
+
+// errAmbiguousJSON is returned for JSON-RPC messages that backends may read
+// differently than the proxy
+var errAmbiguousJSON = errors.New("ambiguous JSON-RPC message")
+
+// mcpToolCalls returns the names of the MCP tools a JSON-RPC request or batch
+// calls. anyTool is set when a POST body cannot be read strictly, whatever its
+// Content-Type: too large, malformed, or with keys a lenient decoder could
+// read differently. Such a request may call any tool, so that padding or
+// obfuscating it cannot hide a tool call. The body is restored for the proxy.
+func mcpToolCalls(r *http.Request) (tools []string, anyTool bool) {
+	if r.Method != http.MethodPost || r.Body == nil || r.Body == http.NoBody {
+		return nil, false
+	}
+
+	head, err := io.ReadAll(io.LimitReader(r.Body, maxToolCallBody+1))
+	r.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(head), r.Body), r.Body}
+	if err != nil || len(head) > maxToolCallBody {
+		return nil, true
+	}
+	if len(bytes.TrimSpace(head)) == 0 {
+		return nil, false
+	}
+
+	var messages []json.RawMessage
+	if trimmed := bytes.TrimSpace(head); trimmed[0] == '[' {
+		if err := json.Unmarshal(trimmed, &messages); err != nil {
+			return nil, true
+		}
+	} else {
+		messages = []json.RawMessage{trimmed}
+	}
+
+	for _, message := range messages {
+		tool, err := toolCallName(message)
+		if err != nil {
+			return nil, true
+		}
+		if tool != "" {
+			tools = append(tools, tool)
+		}
+	}
+	return tools, false
+}
+
+// toolCallName returns the tool a JSON-RPC message calls, or "" for other
+// methods. Keys are matched exactly.
+func toolCallName(message json.RawMessage) (string, error) {
+	fields, err := decodeObject(message, "method", "params")
+	if err != nil {
+		return "", err
+	}
+	var method string
+	if raw, ok := fields["method"]; ok {
+		if err := json.Unmarshal(raw, &method); err != nil {
+			return "", err
+		}
+	}
+	if method != "tools/call" {
+		return "", nil
+	}
+
+	params, err := decodeObject(fields["params"], "name")
+	if err != nil {
+		return "", err
+	}
+	var name string
+	if err := json.Unmarshal(params["name"], &name); err != nil || name == "" {
+		return "", errAmbiguousJSON
+	}
+	return name, nil
+}
+
+// decodeObject decodes a JSON object into its raw members. Duplicate keys, and
+// keys that differ from one of the known keys only in case, are rejected,
+// since decoders disagree on which one wins.
+func decodeObject(data json.RawMessage, known ...string) (map[string]json.RawMessage, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return nil, errAmbiguousJSON
+	}
+
+	fields := make(map[string]json.RawMessage)
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		key := tok.(string)
+		if _, ok := fields[key]; ok {
+			return nil, errAmbiguousJSON
+		}
+		for _, k := range known {
+			if key != k && strings.EqualFold(key, k) {
+				return nil, errAmbiguousJSON
+			}
+		}
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return nil, err
+		}
+		fields[key] = value
+	}
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+	if dec.More() {
+		return nil, errAmbiguousJSON
+	}
+	return fields, nil
+}
+
+// claimStrings reads a string or list of strings claim
+func claimStrings(value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		return []string{v}
+	case []string:
+		return v
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+// claimTime reads a NumericDate claim
+func claimTime(value interface{}) (time.Time, bool) {
+	switch v := value.(type) {
+	case float64:
+		return time.Unix(int64(v), 0), true
+	case int64:
+		return time.Unix(v, 0), true
+	case int:
+		return time.Unix(int64(v), 0), true
+	case uint64:
+		return time.Unix(int64(v), 0), true
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return time.Unix(n, 0), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// maxAgeSeconds converts a max age to whole seconds, rounding down
+func maxAgeSeconds(d time.Duration) int {
+	return int(d / time.Second)
+}
+
+// containsString reports whether values contains s
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
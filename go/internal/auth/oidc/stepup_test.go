@@ -0,0 +1,390 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/auth/claims"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/config"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/session/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestStepUpRequirementUnmet(t *testing.T) {
+	now := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	requirement := &StepUpRequirement{
+		ACRValues: []string{"urn:mfa", "urn:hwk"},
+		AMR:       []string{"otp"},
+		MaxAge:    5 * time.Minute,
+	}
+
+	tests := []struct {
+		name   string
+		claims map[string]interface{}
+		want   string
+	}{
+		{
+			name:   "met",
+			claims: map[string]interface{}{"acr": "urn:hwk", "amr": []interface{}{"pwd", "otp"}, "auth_time": float64(now.Add(-time.Minute).Unix())},
+		},
+		{
+			name:   "no acr",
+			claims: map[string]interface{}{"amr": []interface{}{"otp"}, "auth_time": float64(now.Unix())},
+			want:   "authentication context class not accepted",
+		},
+		{
+			name:   "other acr",
+			claims: map[string]interface{}{"acr": "urn:pwd", "amr": []interface{}{"otp"}, "auth_time": float64(now.Unix())},
+			want:   "authentication context class not accepted",
+		},
+		{
+			name:   "method missing",
+			claims: map[string]interface{}{"acr": "urn:mfa", "amr": []interface{}{"pwd"}, "auth_time": float64(now.Unix())},
+			want:   "authentication method otp missing",
+		},
+		{
+			name:   "login too old",
+			claims: map[string]interface{}{"acr": "urn:mfa", "amr": []string{"otp"}, "auth_time": now.Add(-time.Hour).Unix()},
+			want:   "authentication too old",
+		},
+		{
+			name:   "no auth_time",
+			claims: map[string]interface{}{"acr": "urn:mfa", "amr": "otp"},
+			want:   "authentication too old",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, requirement.Unmet(authenticationFromClaims(tt.claims), now))
+		})
+	}
+}
+
+func TestUserSessionKeepsAuthentication(t *testing.T) {
+	authTime := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	userSession := newUserSession(&claims.Identity{UserID: "alice"}, map[string]interface{}{
+		"acr": "urn:mfa", "amr": []interface{}{"pwd", "otp"}, "auth_time": float64(authTime.Unix()),
+	})
+
+	// Stores may drop claims; step-up rules read the dedicated fields
+	userSession.Claims = nil
+	data, err := json.Marshal(userSession)
+	require.NoError(t, err)
+	var decoded UserSession
+	require.NoError(t, json.Unmarshal(data, &decoded))
+
+	assert.Equal(t, "urn:mfa", decoded.Authentication.ACR)
+	assert.Equal(t, []string{"pwd", "otp"}, decoded.Authentication.AMR)
+	assert.True(t, authTime.Equal(decoded.Authentication.AuthTime))
+	assert.Empty(t, (&StepUpRequirement{ACRValues: []string{"urn:mfa"}, MaxAge: time.Hour}).Unmet(decoded.Authentication, authTime.Add(time.Minute)))
+}
+
+func TestStepUpPolicyMatch(t *testing.T) {
+	policy := NewStepUpPolicy(&config.StepUpConfig{Rules: []config.StepUpRule{
+		{PathPrefix: "/mcp", Tools: []string{"deploy_production", "db_write"}, ACRValues: []string{"mfa"}},
+		{PathPrefix: "/admin", MaxAge: 10 * time.Minute},
+	}})
+
+	index, requirement := policy.Match("/mcp", []string{"list_files", "db_write"}, false)
+	assert.Equal(t, 0, index)
+	assert.Equal(t, []string{"mfa"}, requirement.ACRValues)
+
+	index, requirement = policy.Match("/mcp", []string{"list_files"}, false)
+	assert.Equal(t, -1, index)
+	assert.Nil(t, requirement)
+
+	index, _ = policy.Match("/mcp", nil, true)
+	assert.Equal(t, 0, index, "tool rules match when the tools are unknown")
+
+	index, requirement = policy.Match("/admin/users", nil, false)
+	assert.Equal(t, 1, index)
+	assert.Equal(t, 10*time.Minute, requirement.MaxAge)
+
+	// Prefixes cover the path itself and everything below it, but not
+	// sibling paths that merely start with the same characters
+	for _, path := range []string{"/admin", "/admin/", "/admin/users/42"} {
+		index, _ = policy.Match(path, nil, false)
+		assert.Equal(t, 1, index, path)
+	}
+	index, _ = policy.Match("/administrator", nil, false)
+	assert.Equal(t, -1, index)
+
+	catchAll := NewStepUpPolicy(&config.StepUpConfig{Rules: []config.StepUpRule{{PathPrefix: "/", MaxAge: time.Minute}}})
+	for _, path := range []string{"/", "/mcp", "/admin/users"} {
+		index, _ = catchAll.Match(path, nil, false)
+		assert.Equal(t, 0, index, path)
+	}
+
+	_, ok := policy.Rule(2)
+	assert.False(t, ok)
+}
+
+func TestMCPToolCalls(t *testing.T) {
+	request := func(body string) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/mcp", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json; charset=utf-8")
+		return req
+	}
+
+	req := request(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"deploy_production","arguments":{}}}`)
+	tools, truncated := mcpToolCalls(req)
+	assert.Equal(t, []string{"deploy_production"}, tools)
+	assert.False(t, truncated)
+
+	// The proxy still reads the whole body
+	body, err := io.ReadAll(req.Body)
+	require.NoError(t, err)
+	assert.Contains(t, string(body), "deploy_production")
+
+	tools, _ = mcpToolCalls(request(`[{"method":"tools/list"},{"method":"tools/call","params":{"name":"db_write"}}]`))
+	assert.Equal(t, []string{"db_write"}, tools)
+
+	tools, anyTool := mcpToolCalls(request(`{"method":"tools/list"}`))
+	assert.Empty(t, tools)
+	assert.False(t, anyTool)
+
+	// Bodies that cannot be read strictly may call any tool
+	for _, body := range []string{
+		`{"method":"tools/call","params":{"name":"db_write"}`,
+		`{"method":"tools/call","Method":"ping","params":{"name":"db_write"}}`,
+		`{"method":"ping","method":"tools/call","params":{"name":"db_write"}}`,
+		`{"method":"tools/call","params":{"Name":"db_write"}}`,
+		`{"method":"tools/call","params":{"name":"a","name":"db_write"}}`,
+		`{"method":"tools/call"}`,
+		`[{"method":"tools/list"},"tools/call"]`,
+		`{"method":"tools/list"} {"method":"tools/call","params":{"name":"db_write"}}`,
+		`method=tools/call&name=db_write`,
+	} {
+		tools, anyTool = mcpToolCalls(request(body))
+		assert.Empty(t, tools, body)
+		assert.True(t, anyTool, body)
+	}
+
+	// The Content-Type does not decide whether a body is inspected
+	req = request(`{"method":"tools/call","params":{"name":"db_write"}}`)
+	req.Header.Set("Content-Type", "text/plain")
+	tools, anyTool = mcpToolCalls(req)
+	assert.Equal(t, []string{"db_write"}, tools)
+	assert.False(t, anyTool)
+
+	tools, anyTool = mcpToolCalls(httptest.NewRequest(http.MethodGet, "/mcp", nil))
+	assert.Empty(t, tools)
+	assert.False(t, anyTool)
+
+	padded := `{"method":"tools/call","params":{"name":"db_write"},"pad":"` + strings.Repeat("x", maxToolCallBody) + `"}`
+	req = request(padded)
+	_, truncated = mcpToolCalls(req)
+	assert.True(t, truncated)
+	body, err = io.ReadAll(req.Body)
+	require.NoError(t, err)
+	assert.Equal(t, padded, string(body))
+}
+
+func TestStepUpFlow(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	provider := newTestProvider(t)
+
+	store := memory.NewStore(&memory.Config{}, zap.NewNop())
+	defer store.Close()
+
+	handler, err := NewHandler(context.Background(), &config.OIDCConfig{
+		DiscoveryURL: provider.URL(),
+		ClientID:     "test-client",
+		ClientSecret: "test-secret",
+		RedirectURL:  "http://localhost:8080/callback",
+		Scopes:       []string{"openid"},
+		StepUp: config.StepUpConfig{Rules: []config.StepUpRule{
+			{PathPrefix: "/mcp", Tools: []string{"deploy_production"}, ACRValues: []string{"mfa"}, MaxAge: 5 * time.Minute},
+		}},
+	}, &config.SessionConfig{}, store, zap.NewNop())
+	require.NoError(t, err)
+
+	authMiddleware := AuthMiddlewareWithConfig(store, zap.NewNop(), &MiddlewareConfig{
+		Lifetime:  handler.Lifetime(),
+		Cookies:   handler.Cookies(),
+		Codec:     handler.Codec(),
+		LoginPath: LoginPath,
+	})
+	router := gin.New()
+	router.GET("/login", handler.Authorize)
+	router.GET("/callback", handler.Callback)
+	router.Any("/mcp", authMiddleware, handler.StepUpMiddleware(""), func(c *gin.Context) {
+		c.String(http.StatusOK, "ok")
+	})
+
+	// authorize follows a login URL to the provider and returns the
+	// authorization request parameters
+	authorize := func(loginURL string) url.Values {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, loginURL, nil))
+		require.Equal(t, http.StatusFound, w.Code)
+		location, err := url.Parse(w.Header().Get("Location"))
+		require.NoError(t, err)
+		return location.Query()
+	}
+
+	// callback completes the login with an ID token carrying claims
+	callback := func(state string, claims map[string]interface{}, cookie *http.Cookie) *httptest.ResponseRecorder {
+		claims["aud"] = "test-client"
+		claims["sub"] = "alice"
+		provider.handlers["/token"] = func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"access_token": "access-alice",
+				"token_type":   "Bearer",
+				"expires_in":   3600,
+				"id_token":     provider.sign(t, claims),
+			})
+		}
+		req := httptest.NewRequest(http.MethodGet, "/callback?code=test-code&state="+state, nil)
+		req.AddCookie(cookie)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	sessionCookie := func(w *httptest.ResponseRecorder) *http.Cookie {
+		for _, c := range w.Result().Cookies() {
+			if c.Name == "session_id" {
+				return c
+			}
+		}
+		return nil
+	}
+
+	callTool := func(cookie *http.Cookie, tool string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/mcp", strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"`+tool+`"}}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.AddCookie(cookie)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	params := authorize("/login")
+	assert.Empty(t, params.Get("prompt"), "ordinary logins are not forced")
+	w := callback(params.Get("state"), map[string]interface{}{"acr": "pwd", "auth_time": time.Now().Unix()}, &http.Cookie{Name: "session_id", Value: "none"})
+	require.Equal(t, http.StatusFound, w.Code)
+	cookie := sessionCookie(w)
+	require.NotNil(t, cookie)
+
+	t.Run("Other tools need no step-up", func(t *testing.T) {
+		w := callTool(cookie, "list_files")
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	var loginURL string
+	t.Run("API clients are challenged", func(t *testing.T) {
+		w := callTool(cookie, "deploy_production")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		challenge := w.Header().Get("WWW-Authenticate")
+		assert.Contains(t, challenge, `error="insufficient_user_authentication"`)
+		assert.Contains(t, challenge, `acr_values="mfa"`)
+		assert.Contains(t, challenge, `max_age=300`)
+
+		var body map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		assert.Equal(t, InsufficientUserAuthentication, body["error"])
+		loginURL, _ = body["login_url"].(string)
+		assert.Equal(t, "/login?redirect_uri=%2Fmcp&step_up=0", loginURL)
+	})
+
+	t.Run("Browser form posts are challenged rather than redirected", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/mcp", strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"deploy_production"}}`))
+		req.Header.Set("Content-Type", "text/plain")
+		req.Header.Set("Sec-Fetch-Mode", "navigate")
+		req.AddCookie(cookie)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Empty(t, w.Header().Get("Location"))
+		assert.Contains(t, w.Header().Get("WWW-Authenticate"), `acr_values="mfa"`)
+		assert.Contains(t, w.Body.String(), `href="/login?redirect_uri=%2Fmcp&amp;step_up=0"`)
+	})
+
+	t.Run("Page loads carry no tool call", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/mcp?view=1", nil)
+		req.Header.Set("Sec-Fetch-Mode", "navigate")
+		req.AddCookie(cookie)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("A login below the requirement keeps the old session", func(t *testing.T) {
+		params := authorize(loginURL)
+		w := callback(params.Get("state"), map[string]interface{}{"acr": "pwd", "auth_time": time.Now().Unix()}, cookie)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Contains(t, w.Body.String(), InsufficientUserAuthentication)
+		assert.True(t, sessionExists(t, store, cookie.Value))
+	})
+
+	t.Run("The call is retried after a step-up login", func(t *testing.T) {
+		params := authorize(loginURL)
+		assert.Equal(t, "login", params.Get("prompt"))
+		assert.Equal(t, "mfa", params.Get("acr_values"))
+		assert.Equal(t, "300", params.Get("max_age"))
+
+		w := callback(params.Get("state"), map[string]interface{}{"acr": "mfa", "auth_time": time.Now().Unix()}, cookie)
+		require.Equal(t, http.StatusFound, w.Code, w.Body.String())
+		assert.Equal(t, "/mcp", w.Header().Get("Location"))
+		stepped := sessionCookie(w)
+		require.NotNil(t, stepped)
+		assert.NotEqual(t, cookie.Value, stepped.Value)
+		assert.False(t, sessionExists(t, store, cookie.Value))
+
+		w = callTool(stepped, "deploy_production")
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+}
+
+func TestStepUpMiddlewareNavigation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	handler := &Handler{
+		stepUp: NewStepUpPolicy(&config.StepUpConfig{Rules: []config.StepUpRule{
+			{PathPrefix: "/deploy", MaxAge: 5 * time.Minute},
+		}}),
+		logger: zap.NewNop(),
+	}
+
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		userSession := &UserSession{ID: "alice", Authentication: Authentication{AuthTime: time.Now().Add(-time.Hour)}}
+		ctx := context.WithValue(c.Request.Context(), SessionContextKey{}, userSession)
+		if bearerToken(c.Request) != "" {
+			ctx = context.WithValue(ctx, bearerAuthContextKey{}, true)
+		}
+		c.Request = c.Request.WithContext(ctx)
+	})
+	router.GET("/deploy/*path", handler.StepUpMiddleware("proxy"), func(c *gin.Context) {
+		c.String(http.StatusOK, "ok")
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/deploy/prod?confirm=1", nil)
+	req.Header.Set("Sec-Fetch-Mode", "navigate")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "/login?redirect_uri=%2Fdeploy%2Fprod%3Fconfirm%3D1&step_up=0", w.Header().Get("Location"))
+
+	req = httptest.NewRequest(http.MethodGet, "/deploy/prod", nil)
+	req.Header.Set("Authorization", "Bearer token")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, `Bearer realm="proxy", error="insufficient_user_authentication", error_description="A stronger or more recent authentication is required", max_age=300`,
+		w.Header().Get("WWW-Authenticate"))
+	assert.NotContains(t, w.Body.String(), "login_url")
+}
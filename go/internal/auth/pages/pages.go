@@ -0,0 +1,202 @@
+// Package pages renders the HTML pages shown to browsers during
+// authentication and tells browser navigations apart from API clients.
+package pages
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/config"
+)
+
+// Kind identifies a page
+type Kind string
+
+// Pages shown to browsers
+const (
+	LoginRequired Kind = "login_required"
+	AccessDenied  Kind = "access_denied"
+	Error         Kind = "error"
+)
+
+// LoginURLKey is the gin context key under which the auth middleware stores
+// the URL that signs the user in and returns to the current page
+const LoginURLKey = "login_url"
+
+// Data is passed to the page templates
+type Data struct {
+	// Status is the HTTP status code of the response
+	Status int
+	// Title is a short heading for the page
+	Title string
+	// Message explains what happened
+	Message string
+	// LoginURL signs the user in (again) and returns to the current page;
+	// empty when login is not available
+	LoginURL string
+	// RequestID identifies the request in the proxy logs
+	RequestID string
+}
+
+// defaultTitles are the page titles used when the caller sets none
+var defaultTitles = map[Kind]string{
+	LoginRequired: "Sign in required",
+	AccessDenied:  "Access denied",
+	Error:         "Something went wrong",
+}
+
+// defaultTemplate is used for every page without a custom template
+const defaultTemplate = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>
+body { font-family: system-ui, sans-serif; max-width: 32rem; margin: 4rem auto; padding: 0 1rem; color: #222; }
+.meta { color: #777; font-size: 0.8rem; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+{{if .Message}}<p>{{.Message}}</p>
+{{end}}{{if .LoginURL}}<p><a href="{{.LoginURL}}">Sign in</a></p>
+{{end}}{{if .RequestID}}<p class="meta">Request ID: {{.RequestID}}</p>
+{{end}}</body>
+</html>
+`
+
+// Pages holds the page templates
+type Pages struct {
+	templates map[Kind]*template.Template
+}
+
+// New loads the page templates, using the built-in page for every template
+// file that is not configured
+func New(cfg *config.PagesConfig) (*Pages, error) {
+	p := Default()
+	if cfg == nil {
+		return p, nil
+	}
+
+	files := map[Kind]string{
+		LoginRequired: cfg.LoginRequired,
+		AccessDenied:  cfg.AccessDenied,
+		Error:         cfg.Error,
+	}
+	for kind, path := range files {
+		if path == "" {
+			continue
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s page template: %w", kind, err)
+		}
+		tmpl, err := template.New(string(kind)).Parse(string(content))
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s page template: %w", kind, err)
+		}
+		p.templates[kind] = tmpl
+	}
+	return p, nil
+}
+
+// Default returns the built-in pages
+func Default() *Pages {
+	tmpl := template.Must(template.New("default").Parse(defaultTemplate))
+	return &Pages{
+		templates: map[Kind]*template.Template{
+			LoginRequired: tmpl,
+			AccessDenied:  tmpl,
+			Error:         tmpl,
+		},
+	}
+}
+
+// Render writes a page with the status in data. The page is rendered before
+// anything is written, so a failing template yields a plain 500.
+func (p *Pages) Render(w http.ResponseWriter, kind Kind, data *Data) error {
+	if data.Title == "" {
+		data.Title = defaultTitles[kind]
+	}
+
+	var buf bytes.Buffer
+	if err := p.templates[kind].Execute(&buf, data); err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return fmt.Errorf("failed to render %s page: %w", kind, err)
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(data.Status)
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// Respond aborts the request with status: browsers navigating to a page get
+// the page, other clients the JSON body (default: {"error": message})
+func (p *Pages) Respond(c *gin.Context, status int, kind Kind, message string, body gin.H) {
+	defer c.Abort()
+
+	if !IsNavigation(c.Request) {
+		if body == nil {
+			body = gin.H{"error": message}
+		}
+		c.JSON(status, body)
+		return
+	}
+
+	data := &Data{
+		Status:    status,
+		Message:   message,
+		RequestID: c.GetString("request_id"),
+	}
+	if loginURL, ok := c.Get(LoginURLKey); ok {
+		data.LoginURL, _ = loginURL.(string)
+	}
+	if err := p.Render(c.Writer, kind, data); err != nil {
+		_ = c.Error(err)
+	}
+}
+
+// IsNavigation reports whether the request is a browser loading a page, as
+// opposed to an API, MCP or script client. Fetch metadata is used when the
+// browser sends it; otherwise a GET that accepts HTML counts. Requests with
+// an Authorization header are never navigations.
+func IsNavigation(r *http.Request) bool {
+	if r.Header.Get("Authorization") != "" {
+		return false
+	}
+	if mode := r.Header.Get("Sec-Fetch-Mode"); mode != "" {
+		return mode == "navigate"
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	return acceptsHTML(r.Header.Get("Accept"))
+}
+
+// acceptsHTML reports whether an Accept header lists text/html
+func acceptsHTML(accept string) bool {
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if !strings.EqualFold(strings.TrimSpace(mediaType), "text/html") {
+			continue
+		}
+		// text/html;q=0 refuses HTML
+		for _, param := range strings.Split(params, ";") {
+			if key, value, ok := strings.Cut(strings.TrimSpace(param), "="); ok && strings.TrimSpace(key) == "q" {
+				if strings.Trim(strings.TrimSpace(value), "0.") == "" {
+					return false
+				}
+			}
+		}
+		return true
+	}
+	return false
+}
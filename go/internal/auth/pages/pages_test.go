@@ -0,0 +1,123 @@
+package pages
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsNavigation(t *testing.T) {
+	tests := []struct {
+		name    string
+		method  string
+		headers map[string]string
+		want    bool
+	}{
+		{
+			name:    "browser page load",
+			method:  http.MethodGet,
+			headers: map[string]string{"Sec-Fetch-Mode": "navigate", "Accept": "text/html,application/xhtml+xml,*/*;q=0.8"},
+			want:    true,
+		},
+		{
+			name:    "form post",
+			method:  http.MethodPost,
+			headers: map[string]string{"Sec-Fetch-Mode": "navigate"},
+			want:    true,
+		},
+		{
+			name:    "fetch from a page",
+			method:  http.MethodGet,
+			headers: map[string]string{"Sec-Fetch-Mode": "cors", "Accept": "text/html"},
+		},
+		{
+			name:    "HTML accepted without fetch metadata",
+			method:  http.MethodGet,
+			headers: map[string]string{"Accept": "text/html"},
+			want:    true,
+		},
+		{
+			name:    "HTML refused",
+			method:  http.MethodGet,
+			headers: map[string]string{"Accept": "application/json, text/html;q=0"},
+		},
+		{
+			name:    "MCP client",
+			method:  http.MethodPost,
+			headers: map[string]string{"Accept": "application/json, text/event-stream"},
+		},
+		{
+			name:    "bearer token",
+			method:  http.MethodGet,
+			headers: map[string]string{"Sec-Fetch-Mode": "navigate", "Authorization": "Bearer token"},
+		},
+		{name: "no headers", method: http.MethodGet},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/", nil)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			assert.Equal(t, tt.want, IsNavigation(req))
+		})
+	}
+}
+
+func TestCustomPages(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "denied.html")
+	require.NoError(t, os.WriteFile(path, []byte(`<p>{{.Status}} {{.Message}}</p>`), 0o600))
+
+	p, err := New(&config.PagesConfig{AccessDenied: path})
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	require.NoError(t, p.Render(w, AccessDenied, &Data{Status: http.StatusForbidden, Message: "<admins> only"}))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Equal(t, "<p>403 &lt;admins&gt; only</p>", w.Body.String())
+	assert.Equal(t, "no-store", w.Header().Get("Cache-Control"))
+
+	// Pages without a template file keep the built-in page
+	w = httptest.NewRecorder()
+	require.NoError(t, p.Render(w, Error, &Data{Status: http.StatusBadRequest}))
+	assert.Contains(t, w.Body.String(), "Something went wrong")
+
+	_, err = New(&config.PagesConfig{Error: filepath.Join(dir, "missing.html")})
+	assert.Error(t, err)
+
+	require.NoError(t, os.WriteFile(path, []byte(`{{.Message`), 0o600))
+	_, err = New(&config.PagesConfig{AccessDenied: path})
+	assert.Error(t, err)
+}
+
+func TestRespond(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/", func(c *gin.Context) {
+		c.Set(LoginURLKey, "/login?redirect_uri=%2F")
+		Default().Respond(c, http.StatusUnauthorized, LoginRequired, "Authentication required", nil)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Sec-Fetch-Mode", "navigate")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "text/html")
+	assert.Contains(t, w.Body.String(), "Sign in required")
+	assert.Contains(t, w.Body.String(), `href="/login?redirect_uri=%2F"`)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.JSONEq(t, `{"error":"Authentication required"}`, w.Body.String())
+}
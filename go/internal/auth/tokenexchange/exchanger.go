@@ -0,0 +1,292 @@
+package tokenexchange
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/config"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/metrics"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/pathprefix"
+	"golang.org/x/sync/singleflight"
+)
+
+// Token type identifiers (RFC 8693 section 3)
+const (
+	GrantType            = "urn:ietf:params:oauth:grant-type:token-exchange"
+	TokenTypeAccessToken = "urn:ietf:params:oauth:token-type:access_token"
+)
+
+// expiryLeeway is subtracted from the token lifetime so cached tokens are
+// replaced before the upstream would reject them
+const expiryLeeway = 30 * time.Second
+
+// ErrNoSubjectToken is returned when the session has no token to exchange
+var ErrNoSubjectToken = errors.New("no subject token available for exchange")
+
+// Error is an error response from the token endpoint (RFC 6749 section 5.2)
+type Error struct {
+	StatusCode  int
+	Code        string
+	Description string
+}
+
+// Error implements the error interface
+func (e *Error) Error() string {
+	if e.Description != "" {
+		return fmt.Sprintf("token exchange failed: %s: %s", e.Code, e.Description)
+	}
+	return fmt.Sprintf("token exchange failed: %s (status %d)", e.Code, e.StatusCode)
+}
+
+// IsInvalidGrant reports whether the subject token was rejected, meaning the
+// user has to re-authenticate
+func IsInvalidGrant(err error) bool {
+	var exchangeErr *Error
+	return errors.As(err, &exchangeErr) && exchangeErr.Code == "invalid_grant"
+}
+
+// Route is the token requested for upstream paths under a prefix
+type Route struct {
+	PathPrefix         string
+	Audience           string
+	Resource           string
+	Scopes             []string
+	RequestedTokenType string
+}
+
+// Token is an exchanged token
+type Token struct {
+	AccessToken     string
+	IssuedTokenType string
+	TokenType       string
+	ExpiresAt       time.Time
+}
+
+// tokenResponse is the token endpoint success response
+type tokenResponse struct {
+	AccessToken     string `json:"access_token"`
+	IssuedTokenType string `json:"issued_token_type"`
+	TokenType       string `json:"token_type"`
+	ExpiresIn       int64  `json:"expires_in"`
+}
+
+// errorResponse is the token endpoint error response
+type errorResponse struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// Exchanger trades user access tokens for audience-specific tokens at the
+// IdP token endpoint and caches them per session until they expire
+type Exchanger struct {
+	tokenURL     string
+	clientID     string
+	clientSecret string
+	httpClient   *http.Client
+	routes       []Route
+	now          func() time.Time
+
+	mu    sync.Mutex
+	cache map[string]*Token
+	// flights coalesces concurrent exchanges of the same cache key
+	flights singleflight.Group
+}
+
+// NewExchanger creates a token exchanger for the configured routes
+func NewExchanger(cfg *config.TokenExchangeConfig, tokenURL, clientID, clientSecret string) (*Exchanger, error) {
+	if tokenURL == "" {
+		return nil, fmt.Errorf("token endpoint is required")
+	}
+
+	e := &Exchanger{
+		tokenURL:     tokenURL,
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		httpClient:   &http.Client{Timeout: cfg.Timeout},
+		now:          time.Now,
+		cache:        make(map[string]*Token),
+	}
+
+	for _, r := range cfg.Routes {
+		route := Route{
+			PathPrefix:         r.PathPrefix,
+			Audience:           r.Audience,
+			Resource:           r.Resource,
+			Scopes:             r.Scopes,
+			RequestedTokenType: r.RequestedTokenType,
+		}
+		if route.RequestedTokenType == "" {
+			route.RequestedTokenType = TokenTypeAccessToken
+		}
+		e.routes = append(e.routes, route)
+	}
+
+	// Longest prefix first so the most specific route wins
+	sort.SliceStable(e.routes, func(i, j int) bool {
+		return len(e.routes[i].PathPrefix) > len(e.routes[j].PathPrefix)
+	})
+
+	return e, nil
+}
+
+// Match returns the route for an upstream path, or nil if no exchange is needed
+func (e *Exchanger) Match(path string) *Route {
+	for i := range e.routes {
+		if pathprefix.Match(path, e.routes[i].PathPrefix) {
+			return &e.routes[i]
+		}
+	}
+	return nil
+}
+
+// Token returns a token for the route, exchanging the subject token at the
+// token endpoint unless a cached token for the same session is still valid
+func (e *Exchanger) Token(ctx context.Context, subjectToken string, route *Route) (*Token, error) {
+	if subjectToken == "" {
+		return nil, ErrNoSubjectToken
+	}
+
+	key := cacheKey(subjectToken, route)
+	now := e.now()
+
+	e.mu.Lock()
+	cached, ok := e.cache[key]
+	e.mu.Unlock()
+	if ok && now.Before(cached.ExpiresAt) {
+		metrics.TokenExchangeTotal.WithLabelValues(route.PathPrefix, "cache_hit").Inc()
+		return cached, nil
+	}
+
+	// Requests of a session that miss the cache together share one
+	// exchange. It is detached from the caller's cancellation, which would
+	// otherwise fail every request waiting on it; the HTTP client timeout
+	// still bounds it.
+	flight := e.flights.DoChan(key, func() (interface{}, error) {
+		token, err := e.exchange(context.WithoutCancel(ctx), subjectToken, route)
+		if err != nil {
+			metrics.TokenExchangeTotal.WithLabelValues(route.PathPrefix, "error").Inc()
+			return nil, err
+		}
+		metrics.TokenExchangeTotal.WithLabelValues(route.PathPrefix, "success").Inc()
+
+		// Tokens without a lifetime are not cached
+		if !token.ExpiresAt.IsZero() {
+			e.mu.Lock()
+			e.pruneCache(now)
+			e.cache[key] = token
+			e.mu.Unlock()
+		}
+		return token, nil
+	})
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case result := <-flight:
+		if result.Err != nil {
+			return nil, result.Err
+		}
+		return result.Val.(*Token), nil
+	}
+}
+
+// exchange performs the token exchange request
+func (e *Exchanger) exchange(ctx context.Context, subjectToken string, route *Route) (*Token, error) {
+	form := url.Values{
+		"grant_type":           {GrantType},
+		"subject_token":        {subjectToken},
+		"subject_token_type":   {TokenTypeAccessToken},
+		"requested_token_type": {route.RequestedTokenType},
+	}
+	if route.Audience != "" {
+		form.Set("audience", route.Audience)
+	}
+	if route.Resource != "" {
+		form.Set("resource", route.Resource)
+	}
+	if len(route.Scopes) > 0 {
+		form.Set("scope", strings.Join(route.Scopes, " "))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create token exchange request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(e.clientID), url.QueryEscape(e.clientSecret))
+
+	resp, err := e.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("token exchange request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read token exchange response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var errResp errorResponse
+		if json.Unmarshal(body, &errResp) != nil || errResp.Error == "" {
+			errResp.Error = "server_error"
+		}
+		return nil, &Error{
+			StatusCode:  resp.StatusCode,
+			Code:        errResp.Error,
+			Description: errResp.ErrorDescription,
+		}
+	}
+
+	var tokenResp tokenResponse
+	if err := json.Unmarshal(body, &tokenResp); err != nil {
+		return nil, fmt.Errorf("invalid token exchange response: %w", err)
+	}
+	if tokenResp.AccessToken == "" {
+		return nil, fmt.Errorf("token exchange response has no access_token")
+	}
+	if tokenResp.TokenType != "" && !strings.EqualFold(tokenResp.TokenType, "Bearer") && !strings.EqualFold(tokenResp.TokenType, "N_A") {
+		return nil, fmt.Errorf("unsupported issued token type: %s", tokenResp.TokenType)
+	}
+
+	token := &Token{
+		AccessToken:     tokenResp.AccessToken,
+		IssuedTokenType: tokenResp.IssuedTokenType,
+		TokenType:       tokenResp.TokenType,
+	}
+	if tokenResp.ExpiresIn > 0 {
+		lifetime := time.Duration(tokenResp.ExpiresIn) * time.Second
+		if lifetime > 2*expiryLeeway {
+			lifetime -= expiryLeeway
+		}
+		token.ExpiresAt = e.now().Add(lifetime)
+	}
+	return token, nil
+}
+
+// pruneCache removes expired tokens; the caller holds mu
+func (e *Exchanger) pruneCache(now time.Time) {
+	for key, token := range e.cache {
+		if !now.Before(token.ExpiresAt) {
+			delete(e.cache, key)
+		}
+	}
+}
+
+// cacheKey identifies a token by session (a hash of its subject token) and requested audience
+func cacheKey(subjectToken string, route *Route) string {
+	sum := sha256.Sum256([]byte(subjectToken))
+	return hex.EncodeToString(sum[:]) + "|" + route.Audience + "|" + route.Resource + "|" + strings.Join(route.Scopes, " ") + "|" + route.RequestedTokenType
+}
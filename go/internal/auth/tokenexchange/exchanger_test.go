@@ -0,0 +1,246 @@
+package tokenexchange
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// tokenEndpoint is an httptest stand-in for the IdP token endpoint
+type tokenEndpoint struct {
+	*httptest.Server
+	calls    atomic.Int32
+	lastForm map[string]string
+	respond  func(w http.ResponseWriter, r *http.Request)
+}
+
+func newTokenEndpoint(t *testing.T) *tokenEndpoint {
+	t.Helper()
+
+	te := &tokenEndpoint{}
+	te.respond = func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token":      "exchanged-for-" + r.PostForm.Get("audience"),
+			"issued_token_type": TokenTypeAccessToken,
+			"token_type":        "Bearer",
+			"expires_in":        300,
+		})
+	}
+	te.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		te.calls.Add(1)
+
+		clientID, clientSecret, ok := r.BasicAuth()
+		if !ok || clientID != "proxy-client" || clientSecret != "proxy-secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_client"})
+			return
+		}
+		require.NoError(t, r.ParseForm())
+		te.lastForm = make(map[string]string)
+		for key := range r.PostForm {
+			te.lastForm[key] = r.PostForm.Get(key)
+		}
+		te.respond(w, r)
+	}))
+	t.Cleanup(te.Close)
+	return te
+}
+
+func newTestExchanger(t *testing.T, tokenURL string) *Exchanger {
+	t.Helper()
+
+	e, err := NewExchanger(&config.TokenExchangeConfig{
+		Timeout: 5 * time.Second,
+		Routes: []config.TokenExchangeRoute{
+			{PathPrefix: "/api", Audience: "internal-api"},
+			{PathPrefix: "/api/billing", Audience: "billing-api", Scopes: []string{"billing.read", "billing.write"}},
+			{PathPrefix: "/files", Resource: "https://files.example.com/"},
+		},
+	}, tokenURL, "proxy-client", "proxy-secret")
+	require.NoError(t, err)
+	return e
+}
+
+func TestExchanger_Match(t *testing.T) {
+	e := newTestExchanger(t, "http://idp.example.com/token")
+
+	assert.Equal(t, "internal-api", e.Match("/api/users").Audience)
+	assert.Equal(t, "billing-api", e.Match("/api/billing/invoices").Audience, "longest prefix wins")
+	assert.Equal(t, "https://files.example.com/", e.Match("/files/a.txt").Resource)
+	assert.Nil(t, e.Match("/mcp"))
+	assert.Nil(t, e.Match("/apiary"), "prefixes match whole path segments")
+	assert.Equal(t, "internal-api", e.Match("/api/billingx").Audience)
+}
+
+func TestExchanger_Token(t *testing.T) {
+	te := newTokenEndpoint(t)
+	e := newTestExchanger(t, te.URL)
+	ctx := context.Background()
+
+	token, err := e.Token(ctx, "user-access-token", e.Match("/api/billing/x"))
+	require.NoError(t, err)
+	assert.Equal(t, "exchanged-for-billing-api", token.AccessToken)
+	assert.Equal(t, TokenTypeAccessToken, token.IssuedTokenType)
+	assert.WithinDuration(t, time.Now().Add(300*time.Second-expiryLeeway), token.ExpiresAt, 5*time.Second)
+
+	assert.Equal(t, map[string]string{
+		"grant_type":           GrantType,
+		"subject_token":        "user-access-token",
+		"subject_token_type":   TokenTypeAccessToken,
+		"requested_token_type": TokenTypeAccessToken,
+		"audience":             "billing-api",
+		"scope":                "billing.read billing.write",
+	}, te.lastForm)
+
+	_, err = e.Token(ctx, "user-access-token", e.Match("/files/a"))
+	require.NoError(t, err)
+	assert.Equal(t, "https://files.example.com/", te.lastForm["resource"])
+	assert.NotContains(t, te.lastForm, "audience")
+}
+
+func TestExchanger_CachePerSession(t *testing.T) {
+	te := newTokenEndpoint(t)
+	e := newTestExchanger(t, te.URL)
+	ctx := context.Background()
+
+	now := time.Now()
+	e.now = func() time.Time { return now }
+
+	route := e.Match("/api/users")
+	_, err := e.Token(ctx, "session-a-token", route)
+	require.NoError(t, err)
+	_, err = e.Token(ctx, "session-a-token", route)
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), te.calls.Load(), "token should be cached for the session")
+
+	_, err = e.Token(ctx, "session-b-token", route)
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), te.calls.Load(), "other sessions exchange their own token")
+
+	_, err = e.Token(ctx, "session-a-token", e.Match("/api/billing"))
+	require.NoError(t, err)
+	assert.Equal(t, int32(3), te.calls.Load(), "each audience is cached separately")
+
+	now = now.Add(5 * time.Minute)
+	_, err = e.Token(ctx, "session-a-token", route)
+	require.NoError(t, err)
+	assert.Equal(t, int32(4), te.calls.Load(), "expired tokens are exchanged again")
+}
+
+func TestExchanger_ConcurrentMissesShareExchange(t *testing.T) {
+	te := newTokenEndpoint(t)
+	release := make(chan struct{})
+	respond := te.respond
+	te.respond = func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		respond(w, r)
+	}
+	e := newTestExchanger(t, te.URL)
+	route := e.Match("/api/users")
+
+	const requests = 10
+	var wg sync.WaitGroup
+	tokens := make([]*Token, requests)
+	errs := make([]error, requests)
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			tokens[i], errs[i] = e.Token(context.Background(), "session-a-token", route)
+		}(i)
+	}
+
+	// Let every request reach the exchange before the endpoint answers
+	require.Eventually(t, func() bool { return te.calls.Load() == 1 }, time.Second, time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), te.calls.Load(), "concurrent misses should share one exchange")
+	for i := 0; i < requests; i++ {
+		require.NoError(t, errs[i])
+		assert.Equal(t, "exchanged-for-internal-api", tokens[i].AccessToken)
+	}
+}
+
+func TestExchanger_NoExpiryNotCached(t *testing.T) {
+	te := newTokenEndpoint(t)
+	te.respond = func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token":"no-expiry","token_type":"Bearer"}`))
+	}
+	e := newTestExchanger(t, te.URL)
+
+	for i := 0; i < 2; i++ {
+		token, err := e.Token(context.Background(), "user-token", e.Match("/api"))
+		require.NoError(t, err)
+		assert.Equal(t, "no-expiry", token.AccessToken)
+	}
+	assert.Equal(t, int32(2), te.calls.Load())
+}
+
+func TestExchanger_Errors(t *testing.T) {
+	t.Run("No subject token", func(t *testing.T) {
+		e := newTestExchanger(t, "http://idp.example.com/token")
+		_, err := e.Token(context.Background(), "", e.Match("/api"))
+		assert.ErrorIs(t, err, ErrNoSubjectToken)
+	})
+
+	t.Run("Invalid grant", func(t *testing.T) {
+		te := newTokenEndpoint(t)
+		te.respond = func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"invalid_grant","error_description":"subject token expired"}`))
+		}
+		e := newTestExchanger(t, te.URL)
+
+		_, err := e.Token(context.Background(), "expired", e.Match("/api"))
+		require.Error(t, err)
+		assert.True(t, IsInvalidGrant(err))
+		assert.Contains(t, err.Error(), "subject token expired")
+	})
+
+	t.Run("Server error", func(t *testing.T) {
+		te := newTokenEndpoint(t)
+		te.respond = func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("oops"))
+		}
+		e := newTestExchanger(t, te.URL)
+
+		_, err := e.Token(context.Background(), "token", e.Match("/api"))
+		require.Error(t, err)
+		assert.False(t, IsInvalidGrant(err))
+
+		var exchangeErr *Error
+		require.ErrorAs(t, err, &exchangeErr)
+		assert.Equal(t, http.StatusInternalServerError, exchangeErr.StatusCode)
+		assert.Equal(t, "server_error", exchangeErr.Code)
+	})
+
+	t.Run("Unsupported token type", func(t *testing.T) {
+		te := newTokenEndpoint(t)
+		te.respond = func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"access_token":"x","token_type":"DPoP","expires_in":60}`))
+		}
+		e := newTestExchanger(t, te.URL)
+
+		_, err := e.Token(context.Background(), "token", e.Match("/api"))
+		assert.Error(t, err)
+	})
+}
+
+func TestNewExchanger_RequiresTokenURL(t *testing.T) {
+	_, err := NewExchanger(&config.TokenExchangeConfig{}, "", "client", "secret")
+	assert.Error(t, err)
+}
@@ -20,6 +20,8 @@ type Config struct {
 	Logging  LoggingConfig  `mapstructure:"logging"`
 	Metrics  MetricsConfig  `mapstructure:"metrics"`
 	Tracing  TracingConfig  `mapstructure:"tracing"`
+	Admin    AdminConfig    `mapstructure:"admin"`
+	Security SecurityConfig `mapstructure:"security"`
 }
 
 // ServerConfig holds HTTP server configuration
@@ -72,34 +74,244 @@ type OIDCConfig struct {
 	PostLogoutRedirectURI  string   `mapstructure:"post_logout_redirect_uri"`
 	UseUserInfo            bool     `mapstructure:"use_userinfo"`
 	ProviderName           string   `mapstructure:"provider_name"`
+	Bearer                 BearerConfig `mapstructure:"bearer"`
+	Claims                 ClaimsConfig `mapstructure:"claims"`
+	TokenExchange          TokenExchangeConfig `mapstructure:"token_exchange"`
+	Logout                 LogoutConfig `mapstructure:"logout"`
+	ReturnURL              ReturnURLConfig `mapstructure:"return_url"`
+	Device                 DeviceConfig `mapstructure:"device"`
+	CSRF                   CSRFConfig `mapstructure:"csrf"`
+	StepUp                 StepUpConfig `mapstructure:"step_up"`
+}
+
+// BearerConfig holds the optional authentication of API clients by JWT
+// access tokens (RFC 9068) issued by the provider
+type BearerConfig struct {
+	Enabled           bool     `mapstructure:"enabled"`
+	Audiences         []string `mapstructure:"audiences"`          // Accepted aud values; required when enabled
+	AuthorizedParties []string `mapstructure:"authorized_parties"` // Accepted azp/client_id values (default: any client)
+	RequiredScopes    []string `mapstructure:"required_scopes"`    // Scopes the token must carry
+}
+
+// DeviceConfig holds the OAuth 2.0 Device Authorization Grant (RFC 8628)
+// settings for clients that cannot receive a browser redirect
+type DeviceConfig struct {
+	Enabled  bool          `mapstructure:"enabled"`
+	CodeTTL  time.Duration `mapstructure:"code_ttl"`  // Lifetime of device and user codes
+	Interval time.Duration `mapstructure:"interval"`  // Minimum polling interval
+	TokenTTL time.Duration `mapstructure:"token_ttl"` // Lifetime of issued credentials (0: session.ttl)
+}
+
+// CSRFConfig holds the cross-site request forgery protection of the proxy's
+// own state-changing endpoints for cookie-authenticated requests
+type CSRFConfig struct {
+	Enabled        bool     `mapstructure:"enabled"`
+	TrustedOrigins []string `mapstructure:"trusted_origins"` // Origins besides the proxy's own (redirect_url) allowed to send requests, e.g. "https://app.example.com"
+	RequireToken   bool     `mapstructure:"require_token"`   // Require the CSRF token even when Origin or Sec-Fetch-Site show a same-origin request
+}
+
+// StepUpConfig holds the rules requiring a stronger or more recent login for
+// some upstream paths or MCP tools
+type StepUpConfig struct {
+	Rules []StepUpRule `mapstructure:"rules"` // First matching rule wins
+}
+
+// StepUpRule describes the authentication required for requests under a path
+// prefix, optionally only for calls of the listed MCP tools
+type StepUpRule struct {
+	PathPrefix string        `mapstructure:"path_prefix"` // Default: every path
+	Tools      []string      `mapstructure:"tools"`       // MCP tools (tools/call params.name); empty: every request
+	ACRValues  []string      `mapstructure:"acr_values"`  // The acr claim must be one of these
+	AMR        []string      `mapstructure:"amr"`         // Every method must be listed in the amr claim
+	MaxAge     time.Duration `mapstructure:"max_age"`     // auth_time must be at most this old
+}
+
+// ReturnURLConfig restricts where users are sent after login
+type ReturnURLConfig struct {
+	AllowedHosts []string `mapstructure:"allowed_hosts"` // Hosts absolute return URLs may target; "*.example.com" matches subdomains (default: relative paths only)
+	SigningKey   string   `mapstructure:"signing_key"`   // HMAC key binding the return URL to the login (default: derived from the client secret)
+}
+
+// LogoutConfig holds RP-initiated and front-channel logout settings
+type LogoutConfig struct {
+	CallbackPath     string   `mapstructure:"callback_path"`     // Landing path sent as post_logout_redirect_uri
+	FrontChannelPath string   `mapstructure:"frontchannel_path"` // Path the provider loads in a front-channel logout iframe
+	FrontChannelURIs []string `mapstructure:"frontchannel_uris"` // Other relying parties logged out via iframes on logout
+}
+
+// TokenExchangeConfig holds OAuth 2.0 Token Exchange (RFC 8693) settings for
+// obtaining audience-specific upstream tokens
+type TokenExchangeConfig struct {
+	Timeout time.Duration        `mapstructure:"timeout"` // Token endpoint request timeout
+	Routes  []TokenExchangeRoute `mapstructure:"routes"`  // Upstream routes requiring an exchanged token
+}
+
+// TokenExchangeRoute describes the token requested for upstream paths under a prefix
+type TokenExchangeRoute struct {
+	PathPrefix         string   `mapstructure:"path_prefix"`
+	Audience           string   `mapstructure:"audience"`             // "audience" parameter
+	Resource           string   `mapstructure:"resource"`             // "resource" parameter (absolute URI)
+	Scopes             []string `mapstructure:"scopes"`               // "scope" parameter
+	RequestedTokenType string   `mapstructure:"requested_token_type"` // Default: access token
+}
+
+// ClaimsConfig maps token claims to user identity attributes
+type ClaimsConfig struct {
+	UserID ClaimMapping `mapstructure:"user_id"`
+	Email  ClaimMapping `mapstructure:"email"`
+	Name   ClaimMapping `mapstructure:"name"`
+	Groups ClaimMapping `mapstructure:"groups"`
+	Roles  ClaimMapping `mapstructure:"roles"`
+}
+
+// ClaimMapping defines where an identity attribute is read from
+type ClaimMapping struct {
+	Paths      []string         `mapstructure:"paths"`      // Claim paths tried in order, e.g. "realm_access.roles"
+	Transforms []ClaimTransform `mapstructure:"transforms"` // Transformations applied to every value
+}
+
+// ClaimTransform defines a transformation applied to claim values
+type ClaimTransform struct {
+	Type  string `mapstructure:"type"`  // strip_prefix, lowercase, uppercase, regex_extract, split
+	Value string `mapstructure:"value"` // Prefix, regex pattern or separator
 }
 
 // SessionConfig holds session management configuration
 type SessionConfig struct {
 	Store        string        `mapstructure:"store"`
-	TTL          time.Duration `mapstructure:"ttl"`
+	Codec        string        `mapstructure:"codec"` // Encoding of session data: json, msgpack or gob
+	TTL          time.Duration `mapstructure:"ttl"` // Absolute session lifetime since login
+	IdleTimeout  time.Duration `mapstructure:"idle_timeout"` // Expire after this long without activity (0 disables)
+	RefreshInterval time.Duration `mapstructure:"refresh_interval"` // Minimum time between idle timeout extensions
+	RotationInterval time.Duration `mapstructure:"rotation_interval"` // Issue a new session ID after this long (0 disables)
+	RotationGrace time.Duration `mapstructure:"rotation_grace"` // How long the previous ID keeps working after a periodic rotation
+	MaxSessionsPerUser int `mapstructure:"max_sessions_per_user"` // Concurrent sessions per user; the oldest are evicted (0: unlimited)
 	CookieName   string        `mapstructure:"cookie_name"`
 	CookieDomain string        `mapstructure:"cookie_domain"`
 	CookiePath   string        `mapstructure:"cookie_path"`
 	CookieSecure bool          `mapstructure:"cookie_secure"`
 	CookieHTTPOnly bool        `mapstructure:"cookie_http_only"`
 	CookieSameSite string      `mapstructure:"cookie_same_site"`
+	CookieHostPrefix bool      `mapstructure:"cookie_host_prefix"`  // Prefix the name with __Host- (requires secure, path "/" and no domain)
+	CookieLegacyNames []string `mapstructure:"cookie_legacy_names"` // Also accepted and migrated to cookie_name
+	CookieSigning CookieSigningConfig `mapstructure:"cookie_signing"`
 	Redis        RedisConfig   `mapstructure:"redis"`
+	CookieStore  CookieStoreConfig `mapstructure:"cookie_store"` // Settings of the "cookie" store
+	File         FileStoreConfig   `mapstructure:"file"`   // Settings of the "file" store
+	SQL          SQLStoreConfig    `mapstructure:"sql"`    // Settings of the "sql" store
+	Encryption   EncryptionConfig  `mapstructure:"encryption"` // Encryption of session data at rest
+	Cache        SessionCacheConfig `mapstructure:"cache"`     // In-process cache in front of the store
+	Options      map[string]interface{} `mapstructure:"options"` // Settings of stores registered by other packages
+}
+
+// SessionCacheConfig holds configuration for the in-process session cache
+type SessionCacheConfig struct {
+	Enabled bool          `mapstructure:"enabled"`
+	Size    int           `mapstructure:"size"`    // Maximum number of cached sessions
+	TTL     time.Duration `mapstructure:"ttl"`     // Maximum staleness when an invalidation is lost
+	Channel string        `mapstructure:"channel"` // Redis pub/sub channel for invalidations
+}
+
+// EncryptionConfig holds configuration for encrypting session data at rest
+type EncryptionConfig struct {
+	Enabled bool     `mapstructure:"enabled"`
+	Keys    []string `mapstructure:"keys"`     // Encryption keys (at least 32 bytes); the first encrypts, all decrypt
+	KeyFile string   `mapstructure:"key_file"` // File with one key per line, used after keys
+}
+
+// SQLStoreConfig holds configuration for the relational database session store
+type SQLStoreConfig struct {
+	Driver          string        `mapstructure:"driver"`             // postgres, mysql or sqlite
+	DSN             string        `mapstructure:"dsn"`                // Driver-specific data source name
+	MaxOpenConns    int           `mapstructure:"max_open_conns"`     // Ignored for sqlite, which uses one connection
+	MaxIdleConns    int           `mapstructure:"max_idle_conns"`
+	ConnMaxLifetime time.Duration `mapstructure:"conn_max_lifetime"`
+	ConnMaxIdleTime time.Duration `mapstructure:"conn_max_idle_time"`
+}
+
+// FileStoreConfig holds configuration for the embedded file session store
+type FileStoreConfig struct {
+	Path string `mapstructure:"path"` // Database file; its directory is created if missing
+}
+
+// CookieSigningConfig holds session cookie signing configuration
+type CookieSigningConfig struct {
+	Enabled bool     `mapstructure:"enabled"`
+	Keys    []string `mapstructure:"keys"` // HMAC keys (at least 32 bytes); the first signs, all verify
+}
+
+// CookieStoreConfig holds configuration for the stateless cookie session store
+type CookieStoreConfig struct {
+	Keys       []string `mapstructure:"keys"`        // Encryption keys (at least 32 bytes); the first seals, all open
+	NamePrefix string   `mapstructure:"name_prefix"` // Prefix of the session data cookie names
+	MaxChunks  int      `mapstructure:"max_chunks"`  // Maximum number of cookies per session
+	Denylist   string   `mapstructure:"denylist"`    // Revocation storage: "" (none), "memory" or "redis"
 }
 
 // RedisConfig holds Redis configuration
 type RedisConfig struct {
-	URL       string `mapstructure:"url"`
-	Password  string `mapstructure:"password"`
-	DB        int    `mapstructure:"db"`
-	KeyPrefix string `mapstructure:"key_prefix"`
+	Mode             string         `mapstructure:"mode"`  // standalone, sentinel or cluster
+	URL              string         `mapstructure:"url"`   // Standalone mode only
+	Addrs            []string       `mapstructure:"addrs"` // Sentinel addresses or cluster seed nodes
+	MasterName       string         `mapstructure:"master_name"`
+	Username         string         `mapstructure:"username"`
+	Password         string         `mapstructure:"password"`
+	SentinelUsername string         `mapstructure:"sentinel_username"`
+	SentinelPassword string         `mapstructure:"sentinel_password"`
+	DB               int            `mapstructure:"db"`
+	KeyPrefix        string         `mapstructure:"key_prefix"`
+	PoolSize         int            `mapstructure:"pool_size"` // Per node in cluster mode
+	MinIdleConns     int            `mapstructure:"min_idle_conns"`
+	PoolTimeout      time.Duration  `mapstructure:"pool_timeout"`
+	DialTimeout      time.Duration  `mapstructure:"dial_timeout"`
+	ReadTimeout      time.Duration  `mapstructure:"read_timeout"`
+	WriteTimeout     time.Duration  `mapstructure:"write_timeout"`
+	MaxRetries       int            `mapstructure:"max_retries"` // -1 disables retries
+	TLS              RedisTLSConfig `mapstructure:"tls"`
+}
+
+// RedisTLSConfig holds TLS configuration for Redis connections
+type RedisTLSConfig struct {
+	Enabled            bool   `mapstructure:"enabled"`
+	CAFile             string `mapstructure:"ca_file"`
+	CertFile           string `mapstructure:"cert_file"` // Client certificate for mutual TLS
+	KeyFile            string `mapstructure:"key_file"`
+	ServerName         string `mapstructure:"server_name"`
+	InsecureSkipVerify bool   `mapstructure:"insecure_skip_verify"`
 }
 
 // AuthConfig holds authentication configuration
 type AuthConfig struct {
 	Mode          string              `mapstructure:"mode"`
 	Headers       HeadersConfig       `mapstructure:"headers"`
+	Assertion     AssertionConfig     `mapstructure:"assertion"`
 	AccessControl AccessControlConfig `mapstructure:"access_control"`
+	Realm         string              `mapstructure:"realm"` // Realm of the WWW-Authenticate challenge sent to API clients
+	Pages         PagesConfig         `mapstructure:"pages"`
+}
+
+// PagesConfig holds custom html/template files for the pages shown to browsers
+// (empty: built-in page). Templates receive .Status, .Title, .Message,
+// .LoginURL and .RequestID.
+type PagesConfig struct {
+	LoginRequired string `mapstructure:"login_required"` // Sign-in needed but no redirect is possible
+	AccessDenied  string `mapstructure:"access_denied"`  // Authenticated but not allowed
+	Error         string `mapstructure:"error"`          // Login failures
+}
+
+// AssertionConfig holds configuration for the signed identity assertion JWT
+// forwarded to the upstream
+type AssertionConfig struct {
+	Enabled          bool          `mapstructure:"enabled"`
+	Header           string        `mapstructure:"header"`            // Header carrying the assertion
+	Issuer           string        `mapstructure:"issuer"`            // "iss" claim, usually the proxy's public URL
+	Audience         string        `mapstructure:"audience"`          // "aud" claim (default: upstream target URL)
+	TTL              time.Duration `mapstructure:"ttl"`               // Assertion lifetime
+	CachePerSession  bool          `mapstructure:"cache_per_session"` // Reuse assertions per session instead of minting per request
+	Algorithm        string        `mapstructure:"algorithm"`         // RS256 or ES256 for generated keys
+	KeyFiles         []string      `mapstructure:"key_files"`         // PEM private keys; the first signs, all are published
+	RotationInterval time.Duration `mapstructure:"rotation_interval"` // Rotation interval for generated keys (0 disables)
+	JWKSPath         string        `mapstructure:"jwks_path"`         // Path serving the public JWKS
 }
 
 // HeadersConfig holds header configuration
@@ -110,6 +322,24 @@ type HeadersConfig struct {
 	UserGroups string            `mapstructure:"user_groups"`
 	Custom     map[string]string `mapstructure:"custom"`     // Static custom headers
 	Dynamic    DynamicHeaders    `mapstructure:"dynamic"`   // Dynamic header configuration
+	Templates  map[string]string `mapstructure:"templates"` // Header name -> Go template rendered from the user session
+	Routes     []HeaderRouteConfig `mapstructure:"routes"`  // Per-route template header sets
+	MaxValueLength int           `mapstructure:"max_value_length"` // Rendered values longer than this are dropped
+	Strip      []string          `mapstructure:"strip"`     // Additional client-supplied headers always removed
+	Signing    HeaderSigningConfig `mapstructure:"signing"` // HMAC signing of identity headers
+}
+
+// HeaderSigningConfig holds identity header signing configuration
+type HeaderSigningConfig struct {
+	Enabled bool   `mapstructure:"enabled"`
+	Header  string `mapstructure:"header"` // Header carrying the signature
+	Secret  string `mapstructure:"secret"` // Shared HMAC secret (at least 32 bytes)
+}
+
+// HeaderRouteConfig holds template headers applied to requests under a path prefix
+type HeaderRouteConfig struct {
+	PathPrefix string            `mapstructure:"path_prefix"`
+	Templates  map[string]string `mapstructure:"templates"` // Merged over the global templates; empty value disables a header
 }
 
 // DynamicHeaders holds dynamic header generation configuration
@@ -135,6 +365,13 @@ type AccessControlConfig struct {
 	RequiredGroups []string `mapstructure:"required_groups"`
 }
 
+// AdminConfig holds the session administration API configuration
+type AdminConfig struct {
+	Enabled        bool     `mapstructure:"enabled"`
+	PathPrefix     string   `mapstructure:"path_prefix"`
+	RequiredGroups []string `mapstructure:"required_groups"` // Users in any of these groups may use the API
+}
+
 // LoggingConfig holds logging configuration
 type LoggingConfig struct {
 	Level  string          `mapstructure:"level"`
@@ -166,6 +403,57 @@ type TracingConfig struct {
 	SampleRate  float64 `mapstructure:"sample_rate"`
 }
 
+// SecurityConfig holds response security header and CORS configuration
+type SecurityConfig struct {
+	Headers SecurityHeadersConfig `mapstructure:"headers"`
+	CORS    CORSConfig            `mapstructure:"cors"`
+}
+
+// SecurityHeadersConfig holds the security headers added to responses.
+// Rules are applied on top of the built-in defaults; route rules under the
+// longest matching path prefix are applied last. Rules for a header replace
+// the earlier rules for the same header.
+type SecurityHeadersConfig struct {
+	Enabled bool                 `mapstructure:"enabled"`
+	Rules   []HeaderRule         `mapstructure:"rules"`
+	Routes  []RouteHeadersConfig `mapstructure:"routes"`
+	HSTS    HSTSConfig           `mapstructure:"hsts"`
+}
+
+// HeaderRule changes one response header
+type HeaderRule struct {
+	Name   string `mapstructure:"name"`
+	Value  string `mapstructure:"value"`
+	Action string `mapstructure:"action"` // set (default), append, remove or passthrough
+}
+
+// RouteHeadersConfig holds the header rules for requests under a path prefix
+type RouteHeadersConfig struct {
+	PathPrefix string       `mapstructure:"path_prefix"`
+	Rules      []HeaderRule `mapstructure:"rules"`
+}
+
+// HSTSConfig holds the Strict-Transport-Security header, sent only when
+// server.tls.enabled is set
+type HSTSConfig struct {
+	Enabled           bool          `mapstructure:"enabled"`
+	MaxAge            time.Duration `mapstructure:"max_age"`
+	IncludeSubdomains bool          `mapstructure:"include_subdomains"`
+	Preload           bool          `mapstructure:"preload"`
+}
+
+// CORSConfig holds the cross-origin resource sharing policy for browser-based
+// MCP clients
+type CORSConfig struct {
+	Enabled          bool          `mapstructure:"enabled"`
+	AllowedOrigins   []string      `mapstructure:"allowed_origins"` // Exact origins, "https://*.example.com" patterns or "*"
+	AllowedMethods   []string      `mapstructure:"allowed_methods"`
+	AllowedHeaders   []string      `mapstructure:"allowed_headers"` // "*" allows any requested header
+	ExposedHeaders   []string      `mapstructure:"exposed_headers"`
+	AllowCredentials bool          `mapstructure:"allow_credentials"`
+	MaxAge           time.Duration `mapstructure:"max_age"` // How long browsers may cache preflight results
+}
+
 // Load loads configuration from file, environment variables, and command line flags
 func Load(configPath string) (*Config, error) {
 	v := viper.New()
@@ -240,27 +528,113 @@ func setDefaults(v *viper.Viper) {
 	v.SetDefault("oidc.scopes", []string{"openid", "email", "profile"})
 	v.SetDefault("oidc.use_pkce", true)
 	v.SetDefault("oidc.redirect_url", "http://localhost:8080/callback")
-	v.SetDefault("oidc.post_logout_redirect_url", "http://localhost:8080/")
+	v.SetDefault("oidc.end_session_endpoint", "")
+	v.SetDefault("oidc.post_logout_redirect_uri", "http://localhost:8080/")
+	v.SetDefault("oidc.logout.callback_path", "/logout/callback")
+	v.SetDefault("oidc.logout.frontchannel_path", "/frontchannel-logout")
+	v.SetDefault("oidc.logout.frontchannel_uris", []string{})
+	v.SetDefault("oidc.return_url.allowed_hosts", []string{})
+	v.SetDefault("oidc.return_url.signing_key", "")
+	v.SetDefault("oidc.bearer.enabled", false)
+	v.SetDefault("oidc.bearer.audiences", []string{})
+	v.SetDefault("oidc.bearer.authorized_parties", []string{})
+	v.SetDefault("oidc.bearer.required_scopes", []string{})
+	v.SetDefault("oidc.device.enabled", false)
+	v.SetDefault("oidc.device.code_ttl", "10m")
+	v.SetDefault("oidc.device.interval", "5s")
+	v.SetDefault("oidc.device.token_ttl", "0s")
+	v.SetDefault("oidc.csrf.enabled", true)
+	v.SetDefault("oidc.csrf.trusted_origins", []string{})
+	v.SetDefault("oidc.csrf.require_token", false)
 	v.SetDefault("oidc.provider_name", "oidc")
+	v.SetDefault("oidc.claims.user_id.paths", []string{"sub"})
+	v.SetDefault("oidc.claims.email.paths", []string{"email"})
+	v.SetDefault("oidc.claims.name.paths", []string{"name"})
+	v.SetDefault("oidc.claims.groups.paths", []string{"groups"})
+	v.SetDefault("oidc.claims.roles.paths", []string{"roles"})
 
 	// Session defaults
 	v.SetDefault("session.store", "memory")
+	v.SetDefault("session.codec", "json")
+	v.SetDefault("session.options", map[string]interface{}{})
 	v.SetDefault("session.ttl", "24h")
+	v.SetDefault("session.idle_timeout", "1h")
+	v.SetDefault("session.refresh_interval", "1m")
+	v.SetDefault("session.rotation_interval", "15m")
+	v.SetDefault("session.rotation_grace", "30s")
+	v.SetDefault("session.max_sessions_per_user", 0)
 	v.SetDefault("session.cookie_name", "mcp_session")
 	v.SetDefault("session.cookie_path", "/")
 	v.SetDefault("session.cookie_secure", false)
 	v.SetDefault("session.cookie_http_only", true)
 	v.SetDefault("session.cookie_same_site", "lax")
+	v.SetDefault("session.cookie_host_prefix", false)
+	v.SetDefault("session.cookie_legacy_names", []string{})
+	v.SetDefault("session.cookie_signing.enabled", false)
+	v.SetDefault("session.cookie_signing.keys", []string{})
+	v.SetDefault("session.encryption.enabled", false)
+	v.SetDefault("session.encryption.keys", []string{})
+	v.SetDefault("session.encryption.key_file", "")
+	v.SetDefault("session.cache.enabled", false)
+	v.SetDefault("session.cache.size", 10000)
+	v.SetDefault("session.cache.ttl", "5s")
+	v.SetDefault("session.cache.channel", "mcp:session:invalidate")
+	v.SetDefault("session.cookie_store.keys", []string{})
+	v.SetDefault("session.cookie_store.name_prefix", "mcp_sd_")
+	v.SetDefault("session.cookie_store.max_chunks", 4)
+	v.SetDefault("session.cookie_store.denylist", "")
+	v.SetDefault("session.file.path", "data/sessions.db")
+	v.SetDefault("session.sql.driver", "")
+	v.SetDefault("session.sql.dsn", "")
+	v.SetDefault("session.sql.max_open_conns", 10)
+	v.SetDefault("session.sql.max_idle_conns", 5)
+	v.SetDefault("session.sql.conn_max_lifetime", "30m")
+	v.SetDefault("session.sql.conn_max_idle_time", "5m")
+	v.SetDefault("session.redis.mode", "standalone")
 	v.SetDefault("session.redis.url", "redis://localhost:6379")
+	v.SetDefault("session.redis.addrs", []string{})
+	v.SetDefault("session.redis.master_name", "")
+	v.SetDefault("session.redis.username", "")
+	v.SetDefault("session.redis.sentinel_username", "")
+	v.SetDefault("session.redis.sentinel_password", "")
 	v.SetDefault("session.redis.db", 0)
 	v.SetDefault("session.redis.key_prefix", "mcp:session:")
+	v.SetDefault("session.redis.pool_size", 10)
+	v.SetDefault("session.redis.min_idle_conns", 5)
+	v.SetDefault("session.redis.pool_timeout", "4s")
+	v.SetDefault("session.redis.dial_timeout", "5s")
+	v.SetDefault("session.redis.read_timeout", "3s")
+	v.SetDefault("session.redis.write_timeout", "3s")
+	v.SetDefault("session.redis.max_retries", 3)
+	v.SetDefault("session.redis.tls.enabled", false)
+	v.SetDefault("session.redis.tls.ca_file", "")
+	v.SetDefault("session.redis.tls.cert_file", "")
+	v.SetDefault("session.redis.tls.key_file", "")
+	v.SetDefault("session.redis.tls.server_name", "")
+	v.SetDefault("session.redis.tls.insecure_skip_verify", false)
 
 	// Auth defaults
 	v.SetDefault("auth.mode", "oidc")
+	v.SetDefault("auth.realm", "mcp-oidc-proxy")
+	v.SetDefault("auth.pages.login_required", "")
+	v.SetDefault("auth.pages.access_denied", "")
+	v.SetDefault("auth.pages.error", "")
 	v.SetDefault("auth.headers.user_id", "X-User-ID")
 	v.SetDefault("auth.headers.user_email", "X-User-Email")
 	v.SetDefault("auth.headers.user_name", "X-User-Name")
 	v.SetDefault("auth.headers.user_groups", "X-User-Groups")
+	v.SetDefault("auth.headers.max_value_length", 2048)
+	v.SetDefault("auth.headers.signing.enabled", false)
+	v.SetDefault("auth.headers.signing.header", "X-Identity-Signature")
+	v.SetDefault("auth.headers.signing.secret", "")
+	v.SetDefault("oidc.token_exchange.timeout", "10s")
+	v.SetDefault("auth.assertion.enabled", false)
+	v.SetDefault("auth.assertion.header", "X-Identity-Assertion")
+	v.SetDefault("auth.assertion.ttl", "5m")
+	v.SetDefault("auth.assertion.cache_per_session", false)
+	v.SetDefault("auth.assertion.algorithm", "RS256")
+	v.SetDefault("auth.assertion.rotation_interval", "24h")
+	v.SetDefault("auth.assertion.jwks_path", "/.well-known/jwks.json")
 	v.SetDefault("auth.access_control.public_paths", []string{"/health", "/metrics"})
 
 	// Logging defaults
@@ -272,11 +646,30 @@ func setDefaults(v *viper.Viper) {
 	v.SetDefault("metrics.enabled", true)
 	v.SetDefault("metrics.path", "/metrics")
 
+	// Admin defaults
+	v.SetDefault("admin.enabled", false)
+	v.SetDefault("admin.path_prefix", "/api/v1/admin")
+	v.SetDefault("admin.required_groups", []string{})
+
 	// Tracing defaults
 	v.SetDefault("tracing.enabled", false)
 	v.SetDefault("tracing.provider", "jaeger")
 	v.SetDefault("tracing.service_name", "mcp-oidc-proxy")
 	v.SetDefault("tracing.sample_rate", 0.1)
+
+	// Security defaults
+	v.SetDefault("security.headers.enabled", true)
+	v.SetDefault("security.headers.hsts.enabled", true)
+	v.SetDefault("security.headers.hsts.max_age", "8760h")
+	v.SetDefault("security.headers.hsts.include_subdomains", false)
+	v.SetDefault("security.headers.hsts.preload", false)
+	v.SetDefault("security.cors.enabled", false)
+	v.SetDefault("security.cors.allowed_origins", []string{})
+	v.SetDefault("security.cors.allowed_methods", []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE"})
+	v.SetDefault("security.cors.allowed_headers", []string{"Accept", "Authorization", "Content-Type", "Last-Event-ID", "Mcp-Protocol-Version", "Mcp-Session-Id", "X-CSRF-Token"})
+	v.SetDefault("security.cors.exposed_headers", []string{"Mcp-Session-Id", "WWW-Authenticate"})
+	v.SetDefault("security.cors.allow_credentials", false)
+	v.SetDefault("security.cors.max_age", "10m")
 }
 
 // bindEnvVars manually binds environment variables for better control
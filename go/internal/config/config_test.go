@@ -29,6 +29,20 @@ func TestLoad_DefaultConfig(t *testing.T) {
 	assert.Equal(t, "localhost", cfg.Proxy.TargetHost)
 	assert.Equal(t, 3000, cfg.Proxy.TargetPort)
 	assert.Equal(t, "memory", cfg.Session.Store)
+	assert.Equal(t, 24*time.Hour, cfg.Session.TTL)
+	assert.Equal(t, time.Hour, cfg.Session.IdleTimeout)
+	assert.Equal(t, time.Minute, cfg.Session.RefreshInterval)
+	assert.Equal(t, 4, cfg.Session.CookieStore.MaxChunks)
+	assert.Equal(t, "mcp_sd_", cfg.Session.CookieStore.NamePrefix)
+	assert.Equal(t, "data/sessions.db", cfg.Session.File.Path)
+	assert.False(t, cfg.Session.Encryption.Enabled)
+	assert.Equal(t, 10000, cfg.Session.Cache.Size)
+	assert.Equal(t, 5*time.Second, cfg.Session.Cache.TTL)
+	assert.Equal(t, "standalone", cfg.Session.Redis.Mode)
+	assert.Equal(t, 10, cfg.Session.Redis.PoolSize)
+	assert.Equal(t, 4*time.Second, cfg.Session.Redis.PoolTimeout)
+	assert.Equal(t, 10, cfg.Session.SQL.MaxOpenConns)
+	assert.Equal(t, 30*time.Minute, cfg.Session.SQL.ConnMaxLifetime)
 	assert.Equal(t, "bypass", cfg.Auth.Mode) // We set it to bypass
 	assert.Equal(t, "info", cfg.Logging.Level)
 
@@ -42,6 +56,14 @@ func TestLoad_DefaultConfig(t *testing.T) {
 	assert.Equal(t, "", cfg.Tracing.Endpoint) // No default endpoint
 	assert.Equal(t, "mcp-oidc-proxy", cfg.Tracing.ServiceName)
 	assert.Equal(t, 0.1, cfg.Tracing.SampleRate)
+
+	// Security defaults
+	assert.True(t, cfg.Security.Headers.Enabled)
+	assert.True(t, cfg.Security.Headers.HSTS.Enabled)
+	assert.Equal(t, 365*24*time.Hour, cfg.Security.Headers.HSTS.MaxAge)
+	assert.False(t, cfg.Security.CORS.Enabled)
+	assert.Equal(t, 10*time.Minute, cfg.Security.CORS.MaxAge)
+	assert.Contains(t, cfg.Security.CORS.AllowedHeaders, "Mcp-Session-Id")
 }
 
 func TestLoad_FromFile(t *testing.T) {
@@ -287,6 +309,270 @@ func TestValidate_OIDCConfig(t *testing.T) {
 			},
 			wantErr: "at least one scope is required",
 		},
+		{
+			name: "invalid claim transform",
+			config: OIDCConfig{
+				DiscoveryURL: "https://example.com/.well-known/openid-configuration",
+				ClientID:     "test",
+				ClientSecret: "secret",
+				Scopes:       []string{"openid"},
+				RedirectURL:  "http://localhost/callback",
+				Claims: ClaimsConfig{
+					Groups: ClaimMapping{Transforms: []ClaimTransform{{Type: "reverse"}}},
+				},
+			},
+			wantErr: "invalid transform type",
+		},
+		{
+			name: "invalid claim regex",
+			config: OIDCConfig{
+				DiscoveryURL: "https://example.com/.well-known/openid-configuration",
+				ClientID:     "test",
+				ClientSecret: "secret",
+				Scopes:       []string{"openid"},
+				RedirectURL:  "http://localhost/callback",
+				Claims: ClaimsConfig{
+					Roles: ClaimMapping{Transforms: []ClaimTransform{{Type: "regex_extract", Value: "("}}},
+				},
+			},
+			wantErr: "invalid regex_extract pattern",
+		},
+		{
+			name: "token exchange route without audience",
+			config: OIDCConfig{
+				DiscoveryURL: "https://example.com/.well-known/openid-configuration",
+				ClientID:     "test",
+				ClientSecret: "secret",
+				Scopes:       []string{"openid"},
+				RedirectURL:  "http://localhost/callback",
+				TokenExchange: TokenExchangeConfig{
+					Timeout: 10 * time.Second,
+					Routes:  []TokenExchangeRoute{{PathPrefix: "/api"}},
+				},
+			},
+			wantErr: "requires an audience or resource",
+		},
+		{
+			name: "token exchange relative resource",
+			config: OIDCConfig{
+				DiscoveryURL: "https://example.com/.well-known/openid-configuration",
+				ClientID:     "test",
+				ClientSecret: "secret",
+				Scopes:       []string{"openid"},
+				RedirectURL:  "http://localhost/callback",
+				TokenExchange: TokenExchangeConfig{
+					Timeout: 10 * time.Second,
+					Routes:  []TokenExchangeRoute{{PathPrefix: "/api", Resource: "api"}},
+				},
+			},
+			wantErr: "resource must be an absolute URI",
+		},
+		{
+			name: "relative end session endpoint",
+			config: OIDCConfig{
+				DiscoveryURL:       "https://example.com/.well-known/openid-configuration",
+				ClientID:           "test",
+				ClientSecret:       "secret",
+				Scopes:             []string{"openid"},
+				RedirectURL:        "http://localhost/callback",
+				EndSessionEndpoint: "/logout",
+			},
+			wantErr: "end session endpoint must be an absolute URL",
+		},
+		{
+			name: "logout callback path without slash",
+			config: OIDCConfig{
+				DiscoveryURL: "https://example.com/.well-known/openid-configuration",
+				ClientID:     "test",
+				ClientSecret: "secret",
+				Scopes:       []string{"openid"},
+				RedirectURL:  "http://localhost/callback",
+				Logout:       LogoutConfig{CallbackPath: "logout/callback"},
+			},
+			wantErr: "callback path must start with '/'",
+		},
+		{
+			name: "invalid front-channel logout URI",
+			config: OIDCConfig{
+				DiscoveryURL: "https://example.com/.well-known/openid-configuration",
+				ClientID:     "test",
+				ClientSecret: "secret",
+				Scopes:       []string{"openid"},
+				RedirectURL:  "http://localhost/callback",
+				Logout:       LogoutConfig{FrontChannelURIs: []string{"javascript:alert(1)"}},
+			},
+			wantErr: "front-channel logout URI must be an absolute http(s) URL",
+		},
+		{
+			name: "valid logout config",
+			config: OIDCConfig{
+				DiscoveryURL: "https://example.com/.well-known/openid-configuration",
+				ClientID:     "test",
+				ClientSecret: "secret",
+				Scopes:       []string{"openid"},
+				RedirectURL:  "http://localhost/callback",
+				Logout: LogoutConfig{
+					CallbackPath:     "/logout/callback",
+					FrontChannelPath: "/frontchannel-logout",
+					FrontChannelURIs: []string{"https://app.example.com/logout"},
+				},
+			},
+		},
+		{
+			name: "return URL host with a scheme",
+			config: OIDCConfig{
+				DiscoveryURL: "https://example.com/.well-known/openid-configuration",
+				ClientID:     "test",
+				ClientSecret: "secret",
+				Scopes:       []string{"openid"},
+				RedirectURL:  "http://localhost/callback",
+				ReturnURL:    ReturnURLConfig{AllowedHosts: []string{"https://app.example.com"}},
+			},
+			wantErr: "allowed host must be a host name or *.domain pattern",
+		},
+		{
+			name: "short return URL signing key",
+			config: OIDCConfig{
+				DiscoveryURL: "https://example.com/.well-known/openid-configuration",
+				ClientID:     "test",
+				ClientSecret: "secret",
+				Scopes:       []string{"openid"},
+				RedirectURL:  "http://localhost/callback",
+				ReturnURL:    ReturnURLConfig{SigningKey: "short"},
+			},
+			wantErr: "signing key must be at least 32 bytes",
+		},
+		{
+			name: "bearer authentication without audiences",
+			config: OIDCConfig{
+				DiscoveryURL: "https://example.com/.well-known/openid-configuration",
+				ClientID:     "test",
+				ClientSecret: "secret",
+				Scopes:       []string{"openid"},
+				RedirectURL:  "http://localhost/callback",
+				Bearer:       BearerConfig{Enabled: true},
+			},
+			wantErr: "bearer: at least one audience is required",
+		},
+		{
+			name: "device polling interval longer than the code TTL",
+			config: OIDCConfig{
+				DiscoveryURL: "https://example.com/.well-known/openid-configuration",
+				ClientID:     "test",
+				ClientSecret: "secret",
+				Scopes:       []string{"openid"},
+				RedirectURL:  "http://localhost/callback",
+				Device:       DeviceConfig{Enabled: true, CodeTTL: 5 * time.Second, Interval: 10 * time.Second},
+			},
+			wantErr: "polling interval must be shorter than the code TTL",
+		},
+		{
+			name: "device polling interval below one second",
+			config: OIDCConfig{
+				DiscoveryURL: "https://example.com/.well-known/openid-configuration",
+				ClientID:     "test",
+				ClientSecret: "secret",
+				Scopes:       []string{"openid"},
+				RedirectURL:  "http://localhost/callback",
+				Device:       DeviceConfig{Enabled: true, CodeTTL: 10 * time.Minute, Interval: 500 * time.Millisecond},
+			},
+			wantErr: "polling interval must be at least 1s",
+		},
+		{
+			name: "CSRF trusted origin with a path",
+			config: OIDCConfig{
+				DiscoveryURL: "https://example.com/.well-known/openid-configuration",
+				ClientID:     "test",
+				ClientSecret: "secret",
+				Scopes:       []string{"openid"},
+				RedirectURL:  "http://localhost/callback",
+				CSRF:         CSRFConfig{Enabled: true, TrustedOrigins: []string{"https://app.example.com/admin"}},
+			},
+			wantErr: "trusted origin must be scheme://host[:port]",
+		},
+		{
+			name: "CSRF trusted origin without a scheme",
+			config: OIDCConfig{
+				DiscoveryURL: "https://example.com/.well-known/openid-configuration",
+				ClientID:     "test",
+				ClientSecret: "secret",
+				Scopes:       []string{"openid"},
+				RedirectURL:  "http://localhost/callback",
+				CSRF:         CSRFConfig{Enabled: true, TrustedOrigins: []string{"app.example.com"}},
+			},
+			wantErr: "trusted origin must be scheme://host[:port]",
+		},
+		{
+			name: "valid CSRF config",
+			config: OIDCConfig{
+				DiscoveryURL: "https://example.com/.well-known/openid-configuration",
+				ClientID:     "test",
+				ClientSecret: "secret",
+				Scopes:       []string{"openid"},
+				RedirectURL:  "http://localhost/callback",
+				CSRF:         CSRFConfig{Enabled: true, TrustedOrigins: []string{"https://app.example.com", "http://localhost:3000"}},
+			},
+		},
+		{
+			name: "step-up rule without a requirement",
+			config: OIDCConfig{
+				DiscoveryURL: "https://example.com/.well-known/openid-configuration",
+				ClientID:     "test",
+				ClientSecret: "secret",
+				Scopes:       []string{"openid"},
+				RedirectURL:  "http://localhost/callback",
+				StepUp:       StepUpConfig{Rules: []StepUpRule{{PathPrefix: "/mcp", Tools: []string{"deploy"}}}},
+			},
+			wantErr: "rule 0: at least one of acr_values, amr or max_age is required",
+		},
+		{
+			name: "step-up rule with a relative path prefix",
+			config: OIDCConfig{
+				DiscoveryURL: "https://example.com/.well-known/openid-configuration",
+				ClientID:     "test",
+				ClientSecret: "secret",
+				Scopes:       []string{"openid"},
+				RedirectURL:  "http://localhost/callback",
+				StepUp:       StepUpConfig{Rules: []StepUpRule{{PathPrefix: "mcp", ACRValues: []string{"mfa"}}}},
+			},
+			wantErr: "path prefix must start with '/'",
+		},
+		{
+			name: "valid step-up rules",
+			config: OIDCConfig{
+				DiscoveryURL: "https://example.com/.well-known/openid-configuration",
+				ClientID:     "test",
+				ClientSecret: "secret",
+				Scopes:       []string{"openid"},
+				RedirectURL:  "http://localhost/callback",
+				StepUp: StepUpConfig{Rules: []StepUpRule{
+					{PathPrefix: "/mcp", Tools: []string{"deploy_production"}, ACRValues: []string{"mfa"}, MaxAge: 5 * time.Minute},
+					{PathPrefix: "/admin", AMR: []string{"hwk"}},
+				}},
+			},
+		},
+		{
+			name: "valid device config",
+			config: OIDCConfig{
+				DiscoveryURL: "https://example.com/.well-known/openid-configuration",
+				ClientID:     "test",
+				ClientSecret: "secret",
+				Scopes:       []string{"openid"},
+				RedirectURL:  "http://localhost/callback",
+				Device:       DeviceConfig{Enabled: true, CodeTTL: 10 * time.Minute, Interval: 5 * time.Second, TokenTTL: 720 * time.Hour},
+			},
+		},
+		{
+			name: "valid return URL config",
+			config: OIDCConfig{
+				DiscoveryURL: "https://example.com/.well-known/openid-configuration",
+				ClientID:     "test",
+				ClientSecret: "secret",
+				Scopes:       []string{"openid"},
+				RedirectURL:  "http://localhost/callback",
+				ReturnURL:    ReturnURLConfig{AllowedHosts: []string{"app.example.com", "*.example.org", "localhost:3000"}},
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -319,6 +605,77 @@ func TestValidate_SessionConfig(t *testing.T) {
 			},
 			wantErr: "invalid session store",
 		},
+		{
+			name: "redis sentinel without master name",
+			config: SessionConfig{
+				Store:          "redis",
+				TTL:            time.Hour,
+				CookieName:     "session",
+				CookiePath:     "/",
+				CookieSameSite: "lax",
+				Redis:          RedisConfig{Mode: "sentinel", Addrs: []string{"sentinel:26379"}},
+			},
+			wantErr: "redis master name is required",
+		},
+		{
+			name: "redis sentinel",
+			config: SessionConfig{
+				Store:          "redis",
+				TTL:            time.Hour,
+				CookieName:     "session",
+				CookiePath:     "/",
+				CookieSameSite: "lax",
+				Redis:          RedisConfig{Mode: "sentinel", MasterName: "mymaster", Addrs: []string{"sentinel:26379"}},
+			},
+		},
+		{
+			name: "redis cluster without addresses",
+			config: SessionConfig{
+				Store:          "redis",
+				TTL:            time.Hour,
+				CookieName:     "session",
+				CookiePath:     "/",
+				CookieSameSite: "lax",
+				Redis:          RedisConfig{Mode: "cluster"},
+			},
+			wantErr: "redis cluster addresses are required",
+		},
+		{
+			name: "redis cluster with DB",
+			config: SessionConfig{
+				Store:          "redis",
+				TTL:            time.Hour,
+				CookieName:     "session",
+				CookiePath:     "/",
+				CookieSameSite: "lax",
+				Redis:          RedisConfig{Mode: "cluster", Addrs: []string{"node1:6379"}, DB: 1},
+			},
+			wantErr: "only supports DB 0",
+		},
+		{
+			name: "redis invalid mode",
+			config: SessionConfig{
+				Store:          "redis",
+				TTL:            time.Hour,
+				CookieName:     "session",
+				CookiePath:     "/",
+				CookieSameSite: "lax",
+				Redis:          RedisConfig{Mode: "replicated"},
+			},
+			wantErr: "invalid redis mode",
+		},
+		{
+			name: "redis TLS cert without key",
+			config: SessionConfig{
+				Store:          "redis",
+				TTL:            time.Hour,
+				CookieName:     "session",
+				CookiePath:     "/",
+				CookieSameSite: "lax",
+				Redis:          RedisConfig{URL: "rediss://cache:6379", TLS: RedisTLSConfig{Enabled: true, CertFile: "client.pem"}},
+			},
+			wantErr: "cert file and key file must be set together",
+		},
 		{
 			name: "redis without URL",
 			config: SessionConfig{
@@ -341,11 +698,767 @@ func TestValidate_SessionConfig(t *testing.T) {
 			},
 			wantErr: "invalid cookie same site",
 		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			err := validateSessionConfig(&tt.config)
+		{
+			name: "idle timeout longer than ttl",
+			config: SessionConfig{
+				Store:          "memory",
+				TTL:            time.Hour,
+				IdleTimeout:    2 * time.Hour,
+				CookieName:     "session",
+				CookiePath:     "/",
+				CookieSameSite: "lax",
+			},
+			wantErr: "idle timeout must not exceed the session TTL",
+		},
+		{
+			name: "refresh interval not shorter than idle timeout",
+			config: SessionConfig{
+				Store:           "memory",
+				TTL:             time.Hour,
+				IdleTimeout:     time.Minute,
+				RefreshInterval: time.Minute,
+				CookieName:      "session",
+				CookiePath:      "/",
+				CookieSameSite:  "lax",
+			},
+			wantErr: "refresh interval must be shorter than the idle timeout",
+		},
+		{
+			name: "negative rotation interval",
+			config: SessionConfig{
+				Store:            "memory",
+				TTL:              time.Hour,
+				RotationInterval: -time.Minute,
+				CookieName:       "session",
+				CookiePath:       "/",
+				CookieSameSite:   "lax",
+			},
+			wantErr: "rotation interval cannot be negative",
+		},
+		{
+			name: "negative max sessions per user",
+			config: SessionConfig{
+				Store:              "memory",
+				TTL:                time.Hour,
+				MaxSessionsPerUser: -1,
+				CookieName:         "session",
+				CookiePath:         "/",
+				CookieSameSite:     "lax",
+			},
+			wantErr: "max sessions per user cannot be negative",
+		},
+		{
+			name: "max sessions per user with cookie store",
+			config: SessionConfig{
+				Store:              "cookie",
+				TTL:                time.Hour,
+				MaxSessionsPerUser: 3,
+				CookieName:         "session",
+				CookiePath:         "/",
+				CookieSameSite:     "lax",
+			},
+			wantErr: "max sessions per user requires a server-side session store, not cookie",
+		},
+		{
+			name: "same site none without secure",
+			config: SessionConfig{
+				Store:          "memory",
+				TTL:            time.Hour,
+				CookieName:     "session",
+				CookiePath:     "/",
+				CookieSameSite: "none",
+			},
+			wantErr: "requires a secure cookie",
+		},
+		{
+			name: "host prefix with domain",
+			config: SessionConfig{
+				Store:            "memory",
+				TTL:              time.Hour,
+				CookieName:       "session",
+				CookieDomain:     "example.com",
+				CookiePath:       "/",
+				CookieSecure:     true,
+				CookieSameSite:   "lax",
+				CookieHostPrefix: true,
+			},
+			wantErr: "__Host- cookie prefix requires path '/' and no domain",
+		},
+		{
+			name: "legacy name equal to cookie name",
+			config: SessionConfig{
+				Store:             "memory",
+				TTL:               time.Hour,
+				CookieName:        "session",
+				CookiePath:        "/",
+				CookieSameSite:    "lax",
+				CookieLegacyNames: []string{"session"},
+			},
+			wantErr: "legacy cookie names",
+		},
+		{
+			name: "short signing key",
+			config: SessionConfig{
+				Store:          "memory",
+				TTL:            time.Hour,
+				CookieName:     "session",
+				CookiePath:     "/",
+				CookieSameSite: "lax",
+				CookieSigning:  CookieSigningConfig{Enabled: true, Keys: []string{"short"}},
+			},
+			wantErr: "cookie signing keys must be at least 32 bytes",
+		},
+		{
+			name: "signed host cookie",
+			config: SessionConfig{
+				Store:             "memory",
+				TTL:               time.Hour,
+				CookieName:        "mcp_session",
+				CookiePath:        "/",
+				CookieSecure:      true,
+				CookieSameSite:    "strict",
+				CookieHostPrefix:  true,
+				CookieLegacyNames: []string{"session_id"},
+				CookieSigning: CookieSigningConfig{Enabled: true, Keys: []string{
+					"0123456789abcdef0123456789abcdef", "fedcba9876543210fedcba9876543210",
+				}},
+			},
+		},
+		{
+			name: "encryption without keys",
+			config: SessionConfig{
+				Store:          "redis",
+				TTL:            time.Hour,
+				CookieName:     "session",
+				CookiePath:     "/",
+				CookieSameSite: "lax",
+				Redis:          RedisConfig{URL: "redis://localhost:6379"},
+				Encryption:     EncryptionConfig{Enabled: true},
+			},
+			wantErr: "encryption keys or a key file are required",
+		},
+		{
+			name: "encryption with short key",
+			config: SessionConfig{
+				Store:          "memory",
+				TTL:            time.Hour,
+				CookieName:     "session",
+				CookiePath:     "/",
+				CookieSameSite: "lax",
+				Encryption:     EncryptionConfig{Enabled: true, Keys: []string{"short"}},
+			},
+			wantErr: "session encryption keys must be at least 32 bytes",
+		},
+		{
+			name: "encryption with cookie store",
+			config: SessionConfig{
+				Store:          "cookie",
+				TTL:            time.Hour,
+				CookieName:     "session",
+				CookiePath:     "/",
+				CookieSameSite: "lax",
+				CookieStore:    CookieStoreConfig{Keys: []string{"0123456789abcdef0123456789abcdef"}, MaxChunks: 4},
+				Encryption:     EncryptionConfig{Enabled: true, KeyFile: "/etc/mcp/session-keys"},
+			},
+			wantErr: "does not apply to the cookie store",
+		},
+		{
+			name: "encryption with key file",
+			config: SessionConfig{
+				Store:          "redis",
+				TTL:            time.Hour,
+				CookieName:     "session",
+				CookiePath:     "/",
+				CookieSameSite: "lax",
+				Redis:          RedisConfig{URL: "redis://localhost:6379"},
+				Encryption:     EncryptionConfig{Enabled: true, KeyFile: "/etc/mcp/session-keys"},
+			},
+		},
+		{
+			name: "cache with memory store",
+			config: SessionConfig{
+				Store:          "memory",
+				TTL:            time.Hour,
+				CookieName:     "session",
+				CookiePath:     "/",
+				CookieSameSite: "lax",
+				Cache:          SessionCacheConfig{Enabled: true, Size: 100, TTL: 5 * time.Second},
+			},
+			wantErr: "session cache requires the redis, file or sql store",
+		},
+		{
+			name: "cache without TTL",
+			config: SessionConfig{
+				Store:          "redis",
+				TTL:            time.Hour,
+				CookieName:     "session",
+				CookiePath:     "/",
+				CookieSameSite: "lax",
+				Redis:          RedisConfig{URL: "redis://localhost:6379"},
+				Cache:          SessionCacheConfig{Enabled: true, Size: 100},
+			},
+			wantErr: "session cache TTL must be positive",
+		},
+		{
+			name: "cache with redis store",
+			config: SessionConfig{
+				Store:          "redis",
+				TTL:            time.Hour,
+				CookieName:     "session",
+				CookiePath:     "/",
+				CookieSameSite: "lax",
+				Redis:          RedisConfig{URL: "redis://localhost:6379"},
+				Cache:          SessionCacheConfig{Enabled: true, Size: 100, TTL: 5 * time.Second},
+			},
+		},
+		{
+			name: "msgpack codec",
+			config: SessionConfig{
+				Store:          "memory",
+				Codec:          "msgpack",
+				TTL:            time.Hour,
+				CookieName:     "session",
+				CookiePath:     "/",
+				CookieSameSite: "lax",
+			},
+		},
+		{
+			name: "unknown codec",
+			config: SessionConfig{
+				Store:          "memory",
+				Codec:          "protobuf",
+				TTL:            time.Hour,
+				CookieName:     "session",
+				CookiePath:     "/",
+				CookieSameSite: "lax",
+			},
+			wantErr: "invalid session codec",
+		},
+		{
+			name: "sql store with unknown driver",
+			config: SessionConfig{
+				Store:          "sql",
+				TTL:            time.Hour,
+				CookieName:     "session",
+				CookiePath:     "/",
+				CookieSameSite: "lax",
+				SQL:            SQLStoreConfig{Driver: "oracle", DSN: "x"},
+			},
+			wantErr: "invalid SQL driver",
+		},
+		{
+			name: "sql store without dsn",
+			config: SessionConfig{
+				Store:          "sql",
+				TTL:            time.Hour,
+				CookieName:     "session",
+				CookiePath:     "/",
+				CookieSameSite: "lax",
+				SQL:            SQLStoreConfig{Driver: "postgres"},
+			},
+			wantErr: "SQL DSN is required",
+		},
+		{
+			name: "sql store with more idle than open connections",
+			config: SessionConfig{
+				Store:          "sql",
+				TTL:            time.Hour,
+				CookieName:     "session",
+				CookiePath:     "/",
+				CookieSameSite: "lax",
+				SQL:            SQLStoreConfig{Driver: "mysql", DSN: "user:pass@tcp(db:3306)/sessions", MaxOpenConns: 2, MaxIdleConns: 5},
+			},
+			wantErr: "max idle connections",
+		},
+		{
+			name: "sql store",
+			config: SessionConfig{
+				Store:          "sql",
+				TTL:            time.Hour,
+				CookieName:     "session",
+				CookiePath:     "/",
+				CookieSameSite: "lax",
+				SQL:            SQLStoreConfig{Driver: "postgres", DSN: "postgres://proxy@db/sessions", MaxOpenConns: 10, MaxIdleConns: 5},
+			},
+		},
+		{
+			name: "file store without path",
+			config: SessionConfig{
+				Store:          "file",
+				TTL:            time.Hour,
+				CookieName:     "session",
+				CookiePath:     "/",
+				CookieSameSite: "lax",
+			},
+			wantErr: "file path is required",
+		},
+		{
+			name: "file store",
+			config: SessionConfig{
+				Store:          "file",
+				TTL:            time.Hour,
+				CookieName:     "session",
+				CookiePath:     "/",
+				CookieSameSite: "lax",
+				File:           FileStoreConfig{Path: "/var/lib/mcp-oidc-proxy/sessions.db"},
+			},
+		},
+		{
+			name: "cookie store without keys",
+			config: SessionConfig{
+				Store:          "cookie",
+				TTL:            time.Hour,
+				CookieName:     "session",
+				CookiePath:     "/",
+				CookieSameSite: "lax",
+				CookieStore:    CookieStoreConfig{MaxChunks: 4},
+			},
+			wantErr: "at least one encryption key is required",
+		},
+		{
+			name: "cookie store with short key",
+			config: SessionConfig{
+				Store:          "cookie",
+				TTL:            time.Hour,
+				CookieName:     "session",
+				CookiePath:     "/",
+				CookieSameSite: "lax",
+				CookieStore:    CookieStoreConfig{Keys: []string{"short"}, MaxChunks: 4},
+			},
+			wantErr: "encryption keys must be at least 32 bytes",
+		},
+		{
+			name: "cookie store with invalid denylist",
+			config: SessionConfig{
+				Store:          "cookie",
+				TTL:            time.Hour,
+				CookieName:     "session",
+				CookiePath:     "/",
+				CookieSameSite: "lax",
+				CookieStore:    CookieStoreConfig{Keys: []string{"0123456789abcdef0123456789abcdef"}, MaxChunks: 4, Denylist: "file"},
+			},
+			wantErr: "invalid cookie store denylist",
+		},
+		{
+			name: "cookie store with strict same site",
+			config: SessionConfig{
+				Store:          "cookie",
+				TTL:            time.Hour,
+				CookieName:     "session",
+				CookiePath:     "/",
+				CookieSameSite: "strict",
+				CookieStore:    CookieStoreConfig{Keys: []string{"0123456789abcdef0123456789abcdef"}, MaxChunks: 4},
+			},
+			wantErr: "cookie store requires cookie same site",
+		},
+		{
+			name: "cookie store with redis denylist",
+			config: SessionConfig{
+				Store:          "cookie",
+				TTL:            time.Hour,
+				CookieName:     "session",
+				CookiePath:     "/",
+				CookieSameSite: "lax",
+				CookieStore:    CookieStoreConfig{Keys: []string{"0123456789abcdef0123456789abcdef"}, MaxChunks: 4, Denylist: "redis"},
+				Redis:          RedisConfig{URL: "redis://localhost:6379"},
+			},
+		},
+		{
+			name: "idle timeout with refresh interval",
+			config: SessionConfig{
+				Store:           "memory",
+				TTL:             24 * time.Hour,
+				IdleTimeout:     time.Hour,
+				RefreshInterval: time.Minute,
+				CookieName:      "session",
+				CookiePath:      "/",
+				CookieSameSite:  "lax",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateSessionConfig(&tt.config)
+			if tt.wantErr != "" {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestValidate_AuthHeaderSigning(t *testing.T) {
+	headers := func(signing HeaderSigningConfig) HeadersConfig {
+		return HeadersConfig{
+			UserID:     "X-User-ID",
+			UserEmail:  "X-User-Email",
+			UserName:   "X-User-Name",
+			UserGroups: "X-User-Groups",
+			Signing:    signing,
+		}
+	}
+
+	tests := []struct {
+		name    string
+		signing HeaderSigningConfig
+		wantErr string
+	}{
+		{
+			name:    "disabled",
+			signing: HeaderSigningConfig{},
+		},
+		{
+			name: "valid",
+			signing: HeaderSigningConfig{
+				Enabled: true,
+				Header:  "X-Identity-Signature",
+				Secret:  "0123456789abcdef0123456789abcdef",
+			},
+		},
+		{
+			name: "short secret",
+			signing: HeaderSigningConfig{
+				Enabled: true,
+				Header:  "X-Identity-Signature",
+				Secret:  "secret",
+			},
+			wantErr: "at least 32 bytes",
+		},
+		{
+			name: "missing header",
+			signing: HeaderSigningConfig{
+				Enabled: true,
+				Secret:  "0123456789abcdef0123456789abcdef",
+			},
+			wantErr: "signature header name is required",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateAuthConfig(&AuthConfig{Mode: "oidc", Headers: headers(tt.signing)})
+			if tt.wantErr != "" {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestValidate_AuthRealm(t *testing.T) {
+	config := AuthConfig{
+		Mode: "oidc",
+		Headers: HeadersConfig{
+			UserID:     "X-User-ID",
+			UserEmail:  "X-User-Email",
+			UserName:   "X-User-Name",
+			UserGroups: "X-User-Groups",
+		},
+		Realm: "mcp-oidc-proxy",
+	}
+	assert.NoError(t, validateAuthConfig(&config))
+
+	config.Realm = `evil", error="x`
+	assert.EqualError(t, validateAuthConfig(&config), "realm must not contain quotes, backslashes or line breaks")
+}
+
+func TestValidate_AssertionConfig(t *testing.T) {
+	valid := func() AssertionConfig {
+		return AssertionConfig{
+			Enabled:          true,
+			Header:           "X-Identity-Assertion",
+			Issuer:           "https://proxy.example.com",
+			TTL:              5 * time.Minute,
+			Algorithm:        "RS256",
+			RotationInterval: 24 * time.Hour,
+			JWKSPath:         "/.well-known/jwks.json",
+		}
+	}
+
+	tests := []struct {
+		name    string
+		modify  func(*AssertionConfig)
+		wantErr string
+	}{
+		{
+			name:   "valid",
+			modify: func(c *AssertionConfig) {},
+		},
+		{
+			name:    "missing issuer",
+			modify:  func(c *AssertionConfig) { c.Issuer = "" },
+			wantErr: "issuer is required",
+		},
+		{
+			name:    "unsupported algorithm",
+			modify:  func(c *AssertionConfig) { c.Algorithm = "HS256" },
+			wantErr: "unsupported algorithm",
+		},
+		{
+			name:    "rotation shorter than ttl",
+			modify:  func(c *AssertionConfig) { c.RotationInterval = time.Minute },
+			wantErr: "rotation interval must not be shorter than ttl",
+		},
+		{
+			name:    "relative jwks path",
+			modify:  func(c *AssertionConfig) { c.JWKSPath = "jwks.json" },
+			wantErr: "jwks path must start with '/'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := valid()
+			tt.modify(&cfg)
+			err := validateAssertionConfig(&cfg)
+			if tt.wantErr != "" {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestValidate_AdminConfig(t *testing.T) {
+	valid := func() AdminConfig {
+		return AdminConfig{
+			Enabled:        true,
+			PathPrefix:     "/api/v1/admin",
+			RequiredGroups: []string{"admins"},
+		}
+	}
+
+	tests := []struct {
+		name    string
+		modify  func(*AdminConfig)
+		wantErr string
+	}{
+		{
+			name:   "valid",
+			modify: func(c *AdminConfig) {},
+		},
+		{
+			name:    "relative path prefix",
+			modify:  func(c *AdminConfig) { c.PathPrefix = "admin" },
+			wantErr: "path prefix must start with '/'",
+		},
+		{
+			name:    "no required groups",
+			modify:  func(c *AdminConfig) { c.RequiredGroups = nil },
+			wantErr: "at least one required group",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := valid()
+			tt.modify(&cfg)
+			err := validateAdminConfig(&cfg)
+			if tt.wantErr != "" {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestValidate_SessionStoreFeatures(t *testing.T) {
+	valid := func() Config {
+		return Config{
+			Auth:    AuthConfig{Mode: "oidc"},
+			OIDC:    OIDCConfig{Device: DeviceConfig{Enabled: true}},
+			Session: SessionConfig{Store: "memory"},
+		}
+	}
+
+	tests := []struct {
+		name    string
+		modify  func(*Config)
+		wantErr string
+	}{
+		{
+			name:   "device grant with a server-side store",
+			modify: func(c *Config) {},
+		},
+		{
+			name:    "device grant with cookie store",
+			modify:  func(c *Config) { c.Session.Store = "cookie" },
+			wantErr: "the device authorization grant requires a server-side session store, not cookie",
+		},
+		{
+			name: "cookie store without device grant",
+			modify: func(c *Config) {
+				c.Session.Store = "cookie"
+				c.OIDC.Device.Enabled = false
+			},
+		},
+		{
+			name: "admin API with cookie store",
+			modify: func(c *Config) {
+				c.Session.Store = "cookie"
+				c.OIDC.Device.Enabled = false
+				c.Admin.Enabled = true
+			},
+			wantErr: "the admin API requires a server-side session store, not cookie",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := valid()
+			tt.modify(&cfg)
+			err := validateSessionStoreFeatures(&cfg)
+			if tt.wantErr != "" {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestValidate_SecurityConfig(t *testing.T) {
+	valid := func() SecurityConfig {
+		return SecurityConfig{
+			Headers: SecurityHeadersConfig{
+				Enabled: true,
+				Rules:   []HeaderRule{{Name: "X-Frame-Options", Action: "remove"}},
+				Routes: []RouteHeadersConfig{{
+					PathPrefix: "/ui",
+					Rules:      []HeaderRule{{Name: "Content-Security-Policy", Action: "passthrough"}},
+				}},
+				HSTS: HSTSConfig{Enabled: true, MaxAge: 365 * 24 * time.Hour},
+			},
+			CORS: CORSConfig{
+				Enabled:        true,
+				AllowedOrigins: []string{"https://app.example.com", "https://*.example.com", "http://localhost:*"},
+				AllowedMethods: []string{"GET", "POST"},
+				AllowedHeaders: []string{"Authorization", "Content-Type"},
+				ExposedHeaders: []string{"Mcp-Session-Id"},
+				MaxAge:         10 * time.Minute,
+			},
+		}
+	}
+
+	tests := []struct {
+		name    string
+		modify  func(*SecurityConfig)
+		wantErr string
+	}{
+		{
+			name:   "valid",
+			modify: func(c *SecurityConfig) {},
+		},
+		{
+			name:    "invalid header name",
+			modify:  func(c *SecurityConfig) { c.Headers.Rules = []HeaderRule{{Name: "X-Bad Header", Value: "x"}} },
+			wantErr: "invalid header name",
+		},
+		{
+			name:    "set without value",
+			modify:  func(c *SecurityConfig) { c.Headers.Rules = []HeaderRule{{Name: "X-Frame-Options", Action: "set"}} },
+			wantErr: "value is required",
+		},
+		{
+			name:    "unknown action",
+			modify:  func(c *SecurityConfig) { c.Headers.Rules = []HeaderRule{{Name: "X-Frame-Options", Action: "replace"}} },
+			wantErr: "invalid action",
+		},
+		{
+			name:    "value with line break",
+			modify:  func(c *SecurityConfig) { c.Headers.Rules = []HeaderRule{{Name: "X-Test", Value: "a\r\nb"}} },
+			wantErr: "line breaks",
+		},
+		{
+			name:    "relative route prefix",
+			modify:  func(c *SecurityConfig) { c.Headers.Routes[0].PathPrefix = "ui" },
+			wantErr: "path prefix must start with '/'",
+		},
+		{
+			name:    "route without rules",
+			modify:  func(c *SecurityConfig) { c.Headers.Routes[0].Rules = nil },
+			wantErr: "at least one rule",
+		},
+		{
+			name: "hsts preload without subdomains",
+			modify: func(c *SecurityConfig) {
+				c.Headers.HSTS.Preload = true
+			},
+			wantErr: "preload requires include_subdomains",
+		},
+		{
+			name: "hsts preload",
+			modify: func(c *SecurityConfig) {
+				c.Headers.HSTS.Preload = true
+				c.Headers.HSTS.IncludeSubdomains = true
+			},
+		},
+		{
+			name:    "cors without origins",
+			modify:  func(c *SecurityConfig) { c.CORS.AllowedOrigins = nil },
+			wantErr: "at least one allowed origin",
+		},
+		{
+			name:    "cors origin with path",
+			modify:  func(c *SecurityConfig) { c.CORS.AllowedOrigins = []string{"https://app.example.com/ui"} },
+			wantErr: "allowed origin must be",
+		},
+		{
+			name:    "cors origin with wildcard in the middle",
+			modify:  func(c *SecurityConfig) { c.CORS.AllowedOrigins = []string{"https://app.*.example.com"} },
+			wantErr: "allowed origin must be",
+		},
+		{
+			name:   "cors any origin",
+			modify: func(c *SecurityConfig) { c.CORS.AllowedOrigins = []string{"*"} },
+		},
+		{
+			name: "cors any origin with credentials",
+			modify: func(c *SecurityConfig) {
+				c.CORS.AllowedOrigins = []string{"*"}
+				c.CORS.AllowCredentials = true
+			},
+			wantErr: "cannot be used with allow_credentials",
+		},
+		{
+			name:    "cors lowercase method",
+			modify:  func(c *SecurityConfig) { c.CORS.AllowedMethods = []string{"get"} },
+			wantErr: "invalid allowed method",
+		},
+		{
+			name:    "cors invalid header",
+			modify:  func(c *SecurityConfig) { c.CORS.ExposedHeaders = []string{"A, B"} },
+			wantErr: "invalid header name",
+		},
+		{
+			name:    "cors negative max age",
+			modify:  func(c *SecurityConfig) { c.CORS.MaxAge = -time.Second },
+			wantErr: "max age cannot be negative",
+		},
+		{
+			name: "cors disabled is not validated",
+			modify: func(c *SecurityConfig) {
+				c.CORS.Enabled = false
+				c.CORS.AllowedOrigins = nil
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := valid()
+			tt.modify(&cfg)
+			err := validateSecurityConfig(&cfg)
 			if tt.wantErr != "" {
 				assert.Error(t, err)
 				assert.Contains(t, err.Error(), tt.wantErr)
@@ -3,7 +3,11 @@ package config
 import (
 	"fmt"
 	"net/url"
+	"regexp"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 )
 
 // Validate validates the configuration
@@ -35,11 +39,28 @@ func Validate(config *Config) error {
 		return fmt.Errorf("session config: %w", err)
 	}
 
+	// Reject features the configured session store cannot support
+	if err := validateSessionStoreFeatures(config); err != nil {
+		return fmt.Errorf("session config: %w", err)
+	}
+
 	// Validate logging config
 	if err := validateLoggingConfig(&config.Logging); err != nil {
 		return fmt.Errorf("logging config: %w", err)
 	}
 
+	// Validate admin config if enabled
+	if config.Admin.Enabled {
+		if err := validateAdminConfig(&config.Admin); err != nil {
+			return fmt.Errorf("admin config: %w", err)
+		}
+	}
+
+	// Validate security headers and CORS config
+	if err := validateSecurityConfig(&config.Security); err != nil {
+		return fmt.Errorf("security config: %w", err)
+	}
+
 	// Validate tracing config if enabled
 	if config.Tracing.Enabled {
 		if err := validateTracingConfig(&config.Tracing); err != nil {
@@ -115,6 +136,10 @@ func validateAuthConfig(config *AuthConfig) error {
 		return fmt.Errorf("invalid auth mode: %s (must be 'oidc' or 'bypass')", config.Mode)
 	}
 
+	if strings.ContainsAny(config.Realm, "\"\\\r\n") {
+		return fmt.Errorf("realm must not contain quotes, backslashes or line breaks")
+	}
+
 	// Validate header names
 	if config.Headers.UserID == "" {
 		return fmt.Errorf("user ID header name is required")
@@ -128,10 +153,58 @@ func validateAuthConfig(config *AuthConfig) error {
 	if config.Headers.UserGroups == "" {
 		return fmt.Errorf("user groups header name is required")
 	}
+	if config.Headers.MaxValueLength < 0 {
+		return fmt.Errorf("header max value length must be non-negative")
+	}
+	for _, route := range config.Headers.Routes {
+		if !strings.HasPrefix(route.PathPrefix, "/") {
+			return fmt.Errorf("header route path prefix must start with '/': %q", route.PathPrefix)
+		}
+	}
+	if config.Headers.Signing.Enabled {
+		if config.Headers.Signing.Header == "" {
+			return fmt.Errorf("identity signature header name is required when signing is enabled")
+		}
+		if len(config.Headers.Signing.Secret) < 32 {
+			return fmt.Errorf("identity signing secret must be at least 32 bytes")
+		}
+	}
+	if config.Assertion.Enabled {
+		if err := validateAssertionConfig(&config.Assertion); err != nil {
+			return fmt.Errorf("assertion: %w", err)
+		}
+	}
 
 	return nil
 }
 
+func validateAssertionConfig(config *AssertionConfig) error {
+	if config.Header == "" {
+		return fmt.Errorf("header name is required")
+	}
+	if config.Issuer == "" {
+		return fmt.Errorf("issuer is required")
+	}
+	if config.TTL <= 0 {
+		return fmt.Errorf("ttl must be positive")
+	}
+	switch config.Algorithm {
+	case "RS256", "ES256":
+	default:
+		return fmt.Errorf("unsupported algorithm: %s (must be 'RS256' or 'ES256')", config.Algorithm)
+	}
+	if config.RotationInterval < 0 {
+		return fmt.Errorf("rotation interval cannot be negative")
+	}
+	if config.RotationInterval > 0 && config.RotationInterval < config.TTL {
+		return fmt.Errorf("rotation interval must not be shorter than ttl")
+	}
+	if !strings.HasPrefix(config.JWKSPath, "/") {
+		return fmt.Errorf("jwks path must start with '/'")
+	}
+	return nil
+}
+
 func validateOIDCConfig(config *OIDCConfig) error {
 	if config.DiscoveryURL == "" {
 		return fmt.Errorf("discovery URL is required")
@@ -172,21 +245,371 @@ func validateOIDCConfig(config *OIDCConfig) error {
 		}
 	}
 
+	if err := validateClaimsConfig(&config.Claims); err != nil {
+		return fmt.Errorf("claims: %w", err)
+	}
+
+	if err := validateTokenExchangeConfig(&config.TokenExchange); err != nil {
+		return fmt.Errorf("token exchange: %w", err)
+	}
+
+	if config.EndSessionEndpoint != "" {
+		parsed, err := url.Parse(config.EndSessionEndpoint)
+		if err != nil || !parsed.IsAbs() {
+			return fmt.Errorf("end session endpoint must be an absolute URL")
+		}
+	}
+
+	if err := validateLogoutConfig(&config.Logout); err != nil {
+		return fmt.Errorf("logout: %w", err)
+	}
+
+	if err := validateReturnURLConfig(&config.ReturnURL); err != nil {
+		return fmt.Errorf("return URL: %w", err)
+	}
+
+	if err := validateBearerConfig(config); err != nil {
+		return fmt.Errorf("bearer: %w", err)
+	}
+
+	if err := validateDeviceConfig(&config.Device); err != nil {
+		return fmt.Errorf("device: %w", err)
+	}
+
+	if err := validateCSRFConfig(&config.CSRF); err != nil {
+		return fmt.Errorf("csrf: %w", err)
+	}
+
+	if err := validateStepUpConfig(&config.StepUp); err != nil {
+		return fmt.Errorf("step_up: %w", err)
+	}
+
+	return nil
+}
+
+func validateBearerConfig(config *OIDCConfig) error {
+	if !config.Bearer.Enabled {
+		return nil
+	}
+	if len(config.Bearer.Audiences) == 0 {
+		return fmt.Errorf("at least one audience is required")
+	}
+	return nil
+}
+
+func validateDeviceConfig(config *DeviceConfig) error {
+	if !config.Enabled {
+		return nil
+	}
+	if config.CodeTTL <= 0 {
+		return fmt.Errorf("code TTL must be positive")
+	}
+	if config.Interval < time.Second {
+		return fmt.Errorf("polling interval must be at least 1s")
+	}
+	if config.Interval >= config.CodeTTL {
+		return fmt.Errorf("polling interval must be shorter than the code TTL")
+	}
+	if config.TokenTTL < 0 {
+		return fmt.Errorf("token TTL cannot be negative")
+	}
+	return nil
+}
+
+func validateCSRFConfig(config *CSRFConfig) error {
+	for _, origin := range config.TrustedOrigins {
+		u, err := url.Parse(origin)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.User != nil ||
+			(u.Path != "" && u.Path != "/") || u.RawQuery != "" || u.Fragment != "" {
+			return fmt.Errorf("trusted origin must be scheme://host[:port]: %q", origin)
+		}
+	}
+	return nil
+}
+
+func validateSecurityConfig(config *SecurityConfig) error {
+	for _, rule := range config.Headers.Rules {
+		if err := validateHeaderRule(&rule); err != nil {
+			return fmt.Errorf("headers: %w", err)
+		}
+	}
+	for i, route := range config.Headers.Routes {
+		if !strings.HasPrefix(route.PathPrefix, "/") {
+			return fmt.Errorf("headers: route %d: path prefix must start with '/'", i)
+		}
+		if len(route.Rules) == 0 {
+			return fmt.Errorf("headers: route %d: at least one rule is required", i)
+		}
+		for _, rule := range route.Rules {
+			if err := validateHeaderRule(&rule); err != nil {
+				return fmt.Errorf("headers: route %d: %w", i, err)
+			}
+		}
+	}
+
+	hsts := &config.Headers.HSTS
+	if hsts.Enabled {
+		if hsts.MaxAge < 0 {
+			return fmt.Errorf("hsts: max age cannot be negative")
+		}
+		// Preload lists only accept one year or more covering all subdomains
+		if hsts.Preload && (!hsts.IncludeSubdomains || hsts.MaxAge < 365*24*time.Hour) {
+			return fmt.Errorf("hsts: preload requires include_subdomains and a max age of at least 8760h")
+		}
+	}
+
+	cors := &config.CORS
+	if cors.Enabled {
+		if len(cors.AllowedOrigins) == 0 {
+			return fmt.Errorf("cors: at least one allowed origin is required")
+		}
+		for _, origin := range cors.AllowedOrigins {
+			if origin == "*" {
+				// Browsers never send credentials to a wildcard origin
+				if cors.AllowCredentials {
+					return fmt.Errorf("cors: allowed origin \"*\" cannot be used with allow_credentials")
+				}
+				continue
+			}
+			if err := validateOriginPattern(origin); err != nil {
+				return fmt.Errorf("cors: %w", err)
+			}
+		}
+		if len(cors.AllowedMethods) == 0 {
+			return fmt.Errorf("cors: at least one allowed method is required")
+		}
+		for _, method := range cors.AllowedMethods {
+			if method == "" || method != strings.ToUpper(method) || strings.ContainsAny(method, " \t\r\n,") {
+				return fmt.Errorf("cors: invalid allowed method: %q", method)
+			}
+		}
+		for _, header := range append(append([]string{}, cors.AllowedHeaders...), cors.ExposedHeaders...) {
+			if header == "" || strings.ContainsAny(header, " \t\r\n,:") {
+				return fmt.Errorf("cors: invalid header name: %q", header)
+			}
+		}
+		if cors.MaxAge < 0 {
+			return fmt.Errorf("cors: max age cannot be negative")
+		}
+	}
+	return nil
+}
+
+func validateHeaderRule(rule *HeaderRule) error {
+	if rule.Name == "" || strings.ContainsAny(rule.Name, " \t\r\n:") {
+		return fmt.Errorf("invalid header name: %q", rule.Name)
+	}
+	if strings.ContainsAny(rule.Value, "\r\n") {
+		return fmt.Errorf("header %s: value must not contain line breaks", rule.Name)
+	}
+	switch rule.Action {
+	case "", "set", "append":
+		if rule.Value == "" {
+			return fmt.Errorf("header %s: value is required", rule.Name)
+		}
+	case "remove", "passthrough":
+	default:
+		return fmt.Errorf("header %s: invalid action %q (must be 'set', 'append', 'remove' or 'passthrough')", rule.Name, rule.Action)
+	}
+	return nil
+}
+
+// validateOriginPattern checks a scheme://host[:port] origin in which the
+// host may start with "*." and the port may be "*"
+func validateOriginPattern(origin string) error {
+	pattern := strings.Replace(origin, "://*.", "://wildcard.", 1)
+	if strings.HasSuffix(pattern, ":*") {
+		pattern = strings.TrimSuffix(pattern, "*") + "1"
+	}
+	u, err := url.Parse(pattern)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.User != nil ||
+		u.Path != "" || u.RawQuery != "" || u.Fragment != "" || strings.Contains(u.Host, "*") {
+		return fmt.Errorf("allowed origin must be scheme://host[:port], *.domain or :* patterns allowed: %q", origin)
+	}
+	return nil
+}
+
+func validateStepUpConfig(config *StepUpConfig) error {
+	for i, rule := range config.Rules {
+		if rule.PathPrefix != "" && !strings.HasPrefix(rule.PathPrefix, "/") {
+			return fmt.Errorf("rule %d: path prefix must start with '/'", i)
+		}
+		if len(rule.ACRValues) == 0 && len(rule.AMR) == 0 && rule.MaxAge == 0 {
+			return fmt.Errorf("rule %d: at least one of acr_values, amr or max_age is required", i)
+		}
+		if rule.MaxAge < 0 {
+			return fmt.Errorf("rule %d: max age cannot be negative", i)
+		}
+		if rule.MaxAge > 0 && rule.MaxAge < time.Second {
+			return fmt.Errorf("rule %d: max age must be at least 1s", i)
+		}
+		for _, tool := range rule.Tools {
+			if tool == "" {
+				return fmt.Errorf("rule %d: tool name cannot be empty", i)
+			}
+		}
+	}
+	return nil
+}
+
+func validateReturnURLConfig(config *ReturnURLConfig) error {
+	for _, host := range config.AllowedHosts {
+		name := strings.TrimPrefix(host, "*.")
+		if name == "" || strings.ContainsAny(name, "/\\@*?#") {
+			return fmt.Errorf("allowed host must be a host name or *.domain pattern: %q", host)
+		}
+	}
+	if config.SigningKey != "" && len(config.SigningKey) < 32 {
+		return fmt.Errorf("signing key must be at least 32 bytes")
+	}
+	return nil
+}
+
+func validateLogoutConfig(config *LogoutConfig) error {
+	if config.CallbackPath != "" && !strings.HasPrefix(config.CallbackPath, "/") {
+		return fmt.Errorf("callback path must start with '/'")
+	}
+	if config.FrontChannelPath != "" && !strings.HasPrefix(config.FrontChannelPath, "/") {
+		return fmt.Errorf("front-channel path must start with '/'")
+	}
+	if config.CallbackPath != "" && config.CallbackPath == config.FrontChannelPath {
+		return fmt.Errorf("callback and front-channel paths must differ")
+	}
+	for _, uri := range config.FrontChannelURIs {
+		parsed, err := url.Parse(uri)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return fmt.Errorf("front-channel logout URI must be an absolute http(s) URL: %q", uri)
+		}
+	}
+	return nil
+}
+
+func validateTokenExchangeConfig(config *TokenExchangeConfig) error {
+	if len(config.Routes) > 0 && config.Timeout <= 0 {
+		return fmt.Errorf("timeout must be positive")
+	}
+	for _, route := range config.Routes {
+		if !strings.HasPrefix(route.PathPrefix, "/") {
+			return fmt.Errorf("route path prefix must start with '/': %q", route.PathPrefix)
+		}
+		if route.Audience == "" && route.Resource == "" {
+			return fmt.Errorf("route %s requires an audience or resource", route.PathPrefix)
+		}
+		if route.Resource != "" {
+			parsed, err := url.Parse(route.Resource)
+			if err != nil || !parsed.IsAbs() {
+				return fmt.Errorf("route %s resource must be an absolute URI", route.PathPrefix)
+			}
+		}
+	}
 	return nil
 }
 
+func validateClaimsConfig(config *ClaimsConfig) error {
+	mappings := map[string]ClaimMapping{
+		"user_id": config.UserID,
+		"email":   config.Email,
+		"name":    config.Name,
+		"groups":  config.Groups,
+		"roles":   config.Roles,
+	}
+
+	for name, mapping := range mappings {
+		for _, path := range mapping.Paths {
+			if strings.TrimSpace(path) == "" {
+				return fmt.Errorf("%s: empty claim path", name)
+			}
+		}
+		for _, t := range mapping.Transforms {
+			switch strings.ToLower(t.Type) {
+			case "lowercase", "uppercase", "split":
+				// No value required
+			case "strip_prefix":
+				if t.Value == "" {
+					return fmt.Errorf("%s: strip_prefix transform requires a value", name)
+				}
+			case "regex_extract":
+				if _, err := regexp.Compile(t.Value); err != nil {
+					return fmt.Errorf("%s: invalid regex_extract pattern: %w", name, err)
+				}
+			default:
+				return fmt.Errorf("%s: invalid transform type: %s (must be 'strip_prefix', 'lowercase', 'uppercase', 'regex_extract', or 'split')", name, t.Type)
+			}
+		}
+	}
+
+	return nil
+}
+
+// sessionStores holds the session store types accepted by the validation
+var (
+	sessionStoresMu sync.RWMutex
+	sessionStores   = map[string]bool{"memory": true, "redis": true, "cookie": true, "file": true, "sql": true}
+)
+
+// RegisterSessionStore accepts a session store type implemented outside the
+// built-in stores. It is called by session.Register.
+func RegisterSessionStore(name string) {
+	sessionStoresMu.Lock()
+	defer sessionStoresMu.Unlock()
+	sessionStores[name] = true
+}
+
+// validSessionStore reports whether a session store type is known and
+// returns the sorted known types
+func validSessionStore(name string) (bool, []string) {
+	sessionStoresMu.RLock()
+	defer sessionStoresMu.RUnlock()
+
+	names := make([]string, 0, len(sessionStores))
+	for store := range sessionStores {
+		names = append(names, "'"+store+"'")
+	}
+	sort.Strings(names)
+	return sessionStores[name], names
+}
+
 func validateSessionConfig(config *SessionConfig) error {
-	switch config.Store {
-	case "memory", "redis":
-		// Valid stores
+	if ok, names := validSessionStore(config.Store); !ok {
+		return fmt.Errorf("invalid session store: %s (must be one of %s)", config.Store, strings.Join(names, ", "))
+	}
+
+	switch config.Codec {
+	case "", "json", "msgpack", "gob":
+		// Valid codecs
 	default:
-		return fmt.Errorf("invalid session store: %s (must be 'memory' or 'redis')", config.Store)
+		return fmt.Errorf("invalid session codec: %s (must be 'json', 'msgpack' or 'gob')", config.Codec)
 	}
 
 	if config.TTL <= 0 {
 		return fmt.Errorf("session TTL must be positive")
 	}
 
+	if config.IdleTimeout < 0 {
+		return fmt.Errorf("idle timeout cannot be negative")
+	}
+	if config.IdleTimeout > config.TTL {
+		return fmt.Errorf("idle timeout must not exceed the session TTL")
+	}
+	if config.RefreshInterval < 0 {
+		return fmt.Errorf("refresh interval cannot be negative")
+	}
+	if config.IdleTimeout > 0 && config.RefreshInterval >= config.IdleTimeout {
+		return fmt.Errorf("refresh interval must be shorter than the idle timeout")
+	}
+	if config.RotationInterval < 0 {
+		return fmt.Errorf("rotation interval cannot be negative")
+	}
+	if config.RotationGrace < 0 {
+		return fmt.Errorf("rotation grace cannot be negative")
+	}
+	if config.MaxSessionsPerUser < 0 {
+		return fmt.Errorf("max sessions per user cannot be negative")
+	}
+	if config.MaxSessionsPerUser > 0 && config.Store == "cookie" {
+		return fmt.Errorf("max sessions per user requires a server-side session store, not cookie")
+	}
+
 	if config.CookieName == "" {
 		return fmt.Errorf("cookie name is required")
 	}
@@ -201,18 +624,132 @@ func validateSessionConfig(config *SessionConfig) error {
 	default:
 		return fmt.Errorf("invalid cookie same site: %s (must be 'strict', 'lax', or 'none')", config.CookieSameSite)
 	}
+	if strings.EqualFold(config.CookieSameSite, "none") && !config.CookieSecure {
+		return fmt.Errorf("cookie same site 'none' requires a secure cookie")
+	}
+
+	if config.CookieHostPrefix {
+		if !config.CookieSecure {
+			return fmt.Errorf("__Host- cookie prefix requires a secure cookie")
+		}
+		if config.CookiePath != "/" || config.CookieDomain != "" {
+			return fmt.Errorf("__Host- cookie prefix requires path '/' and no domain")
+		}
+	}
+
+	for _, name := range config.CookieLegacyNames {
+		if name == "" || name == config.CookieName {
+			return fmt.Errorf("legacy cookie names must be non-empty and differ from the cookie name")
+		}
+	}
+
+	if config.CookieSigning.Enabled {
+		if len(config.CookieSigning.Keys) == 0 {
+			return fmt.Errorf("at least one cookie signing key is required when signing is enabled")
+		}
+		for _, key := range config.CookieSigning.Keys {
+			if len(key) < 32 {
+				return fmt.Errorf("cookie signing keys must be at least 32 bytes")
+			}
+		}
+	}
+
+	if config.Encryption.Enabled {
+		if config.Store == "cookie" {
+			return fmt.Errorf("session encryption does not apply to the cookie store, which encrypts sessions itself")
+		}
+		if len(config.Encryption.Keys) == 0 && config.Encryption.KeyFile == "" {
+			return fmt.Errorf("encryption keys or a key file are required when session encryption is enabled")
+		}
+		for _, key := range config.Encryption.Keys {
+			if len(key) < 32 {
+				return fmt.Errorf("session encryption keys must be at least 32 bytes")
+			}
+		}
+	}
+
+	if config.Cache.Enabled {
+		switch config.Store {
+		case "redis", "file", "sql":
+		default:
+			return fmt.Errorf("session cache requires the redis, file or sql store")
+		}
+		if config.Cache.Size <= 0 {
+			return fmt.Errorf("session cache size must be positive")
+		}
+		if config.Cache.TTL <= 0 {
+			return fmt.Errorf("session cache TTL must be positive")
+		}
+	}
+
+	if config.Store == "file" && config.File.Path == "" {
+		return fmt.Errorf("file path is required when using file store")
+	}
+
+	if config.Store == "sql" {
+		if err := validateSQLStoreConfig(&config.SQL); err != nil {
+			return err
+		}
+	}
+
+	if config.Store == "cookie" {
+		if err := validateCookieStoreConfig(config); err != nil {
+			return err
+		}
+	}
 
 	// Validate Redis config if using Redis store
-	if config.Store == "redis" {
-		if config.Redis.URL == "" {
+	if config.Store == "redis" || (config.Store == "cookie" && config.CookieStore.Denylist == "redis") {
+		if err := validateRedisConfig(&config.Redis); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validateRedisConfig validates the Redis connection settings
+func validateRedisConfig(config *RedisConfig) error {
+	switch config.Mode {
+	case "", "standalone":
+		if config.URL == "" {
 			return fmt.Errorf("redis URL is required when using redis store")
 		}
-		if _, err := url.Parse(config.Redis.URL); err != nil {
+		if _, err := url.Parse(config.URL); err != nil {
 			return fmt.Errorf("invalid redis URL: %w", err)
 		}
-		if config.Redis.DB < 0 {
-			return fmt.Errorf("redis DB must be non-negative")
+	case "sentinel":
+		if config.MasterName == "" {
+			return fmt.Errorf("redis master name is required in sentinel mode")
+		}
+		if len(config.Addrs) == 0 {
+			return fmt.Errorf("redis sentinel addresses are required in sentinel mode")
+		}
+	case "cluster":
+		if len(config.Addrs) == 0 {
+			return fmt.Errorf("redis cluster addresses are required in cluster mode")
 		}
+		if config.DB != 0 {
+			return fmt.Errorf("redis cluster only supports DB 0")
+		}
+	default:
+		return fmt.Errorf("invalid redis mode: %s (must be 'standalone', 'sentinel' or 'cluster')", config.Mode)
+	}
+
+	if config.DB < 0 {
+		return fmt.Errorf("redis DB must be non-negative")
+	}
+	if config.PoolSize < 0 || config.MinIdleConns < 0 {
+		return fmt.Errorf("redis pool sizes cannot be negative")
+	}
+	if config.PoolTimeout < 0 || config.DialTimeout < 0 || config.ReadTimeout < 0 || config.WriteTimeout < 0 {
+		return fmt.Errorf("redis timeouts cannot be negative")
+	}
+	if config.MaxRetries < -1 {
+		return fmt.Errorf("redis max retries must be -1 (disabled) or greater")
+	}
+	if (config.TLS.CertFile == "") != (config.TLS.KeyFile == "") {
+		return fmt.Errorf("redis TLS cert file and key file must be set together")
 	}
 
 	return nil
@@ -279,3 +816,85 @@ func validateTracingConfig(config *TracingConfig) error {
 	return nil
 }
 
+
+func validateAdminConfig(config *AdminConfig) error {
+	if !strings.HasPrefix(config.PathPrefix, "/") {
+		return fmt.Errorf("path prefix must start with '/'")
+	}
+	if len(config.RequiredGroups) == 0 {
+		return fmt.Errorf("at least one required group must be set when the admin API is enabled")
+	}
+	return nil
+}
+
+// validateSessionStoreFeatures rejects features that look sessions up by
+// something other than the cookie, which the stateless cookie store cannot do
+func validateSessionStoreFeatures(config *Config) error {
+	if config.Session.Store != "cookie" {
+		return nil
+	}
+	if config.Auth.Mode == "oidc" && config.OIDC.Device.Enabled {
+		return fmt.Errorf("the device authorization grant requires a server-side session store, not cookie")
+	}
+	if config.Admin.Enabled {
+		return fmt.Errorf("the admin API requires a server-side session store, not cookie")
+	}
+	return nil
+}
+
+// validateCookieStoreConfig validates the stateless cookie session store
+func validateCookieStoreConfig(config *SessionConfig) error {
+	if len(config.CookieStore.Keys) == 0 {
+		return fmt.Errorf("at least one encryption key is required for the cookie store")
+	}
+	for _, key := range config.CookieStore.Keys {
+		if len(key) < 32 {
+			return fmt.Errorf("cookie store encryption keys must be at least 32 bytes")
+		}
+	}
+
+	if config.CookieStore.MaxChunks < 1 {
+		return fmt.Errorf("cookie store max chunks must be at least 1")
+	}
+
+	switch config.CookieStore.Denylist {
+	case "", "memory", "redis":
+		// Valid denylists
+	default:
+		return fmt.Errorf("invalid cookie store denylist: %s (must be empty, 'memory' or 'redis')", config.CookieStore.Denylist)
+	}
+
+	// The login state is kept in a cookie too, and strict cookies are not
+	// sent on the redirect back from the provider
+	if strings.EqualFold(config.CookieSameSite, "strict") {
+		return fmt.Errorf("cookie store requires cookie same site 'lax' or 'none'")
+	}
+
+	return nil
+}
+
+// validateSQLStoreConfig validates the relational database session store
+func validateSQLStoreConfig(config *SQLStoreConfig) error {
+	switch strings.ToLower(config.Driver) {
+	case "postgres", "mysql", "sqlite":
+		// Valid drivers
+	default:
+		return fmt.Errorf("invalid SQL driver: %s (must be 'postgres', 'mysql' or 'sqlite')", config.Driver)
+	}
+
+	if config.DSN == "" {
+		return fmt.Errorf("SQL DSN is required when using sql store")
+	}
+
+	if config.MaxOpenConns < 0 || config.MaxIdleConns < 0 {
+		return fmt.Errorf("SQL connection pool sizes cannot be negative")
+	}
+	if config.MaxOpenConns > 0 && config.MaxIdleConns > config.MaxOpenConns {
+		return fmt.Errorf("SQL max idle connections must not exceed max open connections")
+	}
+	if config.ConnMaxLifetime < 0 || config.ConnMaxIdleTime < 0 {
+		return fmt.Errorf("SQL connection lifetimes cannot be negative")
+	}
+
+	return nil
+}
@@ -84,6 +84,14 @@ var (
 		},
 	)
 
+	TokenExchangeTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "mcp_oidc_proxy_token_exchange_total",
+			Help: "Total number of upstream token exchanges",
+		},
+		[]string{"route", "status"},
+	)
+
 	// Session metrics
 	SessionsActive = promauto.NewGauge(
 		prometheus.GaugeOpts{
@@ -109,6 +117,22 @@ var (
 		[]string{"operation", "store_type"},
 	)
 
+	SessionCacheRequestsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "mcp_oidc_proxy_session_cache_requests_total",
+			Help: "Total number of session cache lookups by result (hit, miss)",
+		},
+		[]string{"store_type", "result"},
+	)
+
+	SessionCacheInvalidationsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "mcp_oidc_proxy_session_cache_invalidations_total",
+			Help: "Total number of session cache invalidations by origin (local, remote)",
+		},
+		[]string{"store_type", "origin"},
+	)
+
 	// Streaming metrics
 	ProxyStreamingRequestsTotal = promauto.NewCounterVec(
 		prometheus.CounterOpts{
@@ -0,0 +1,272 @@
+package middleware
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/config"
+)
+
+// CORS header constants
+const (
+	HeaderOrigin                        = "Origin"
+	HeaderAccessControlRequestMethod    = "Access-Control-Request-Method"
+	HeaderAccessControlRequestHeaders   = "Access-Control-Request-Headers"
+	HeaderAccessControlAllowOrigin      = "Access-Control-Allow-Origin"
+	HeaderAccessControlAllowCredentials = "Access-Control-Allow-Credentials"
+	HeaderAccessControlAllowMethods     = "Access-Control-Allow-Methods"
+	HeaderAccessControlAllowHeaders     = "Access-Control-Allow-Headers"
+	HeaderAccessControlExposeHeaders    = "Access-Control-Expose-Headers"
+	HeaderAccessControlMaxAge           = "Access-Control-Max-Age"
+)
+
+// corsResponseHeaders are the headers the CORS policy owns; the upstream's
+// values are replaced
+var corsResponseHeaders = []string{
+	HeaderAccessControlAllowOrigin,
+	HeaderAccessControlAllowCredentials,
+	HeaderAccessControlAllowMethods,
+	HeaderAccessControlAllowHeaders,
+	HeaderAccessControlExposeHeaders,
+	HeaderAccessControlMaxAge,
+}
+
+// originPattern matches an origin exactly or, with a wildcard, any subdomain
+// of a domain or any port
+type originPattern struct {
+	scheme string
+	// host is the exact host, or the parent domain when subdomains is set
+	host       string
+	subdomains bool
+	// port is "" for the scheme's default port or "*" for any port
+	port string
+}
+
+// CORS answers preflight requests and adds the CORS headers to responses for
+// the allowed origins
+type CORS struct {
+	anyOrigin      bool
+	origins        []originPattern
+	methods        []string
+	anyHeader      bool
+	headers        map[string]bool
+	exposedHeaders string
+	credentials    bool
+	maxAge         string
+}
+
+// NewCORS creates the CORS policy. Origins are assumed to be validated.
+func NewCORS(cfg *config.CORSConfig) *CORS {
+	c := &CORS{
+		methods:        cfg.AllowedMethods,
+		headers:        make(map[string]bool, len(cfg.AllowedHeaders)),
+		exposedHeaders: strings.Join(cfg.ExposedHeaders, ", "),
+		credentials:    cfg.AllowCredentials,
+	}
+	for _, origin := range cfg.AllowedOrigins {
+		if origin == "*" {
+			c.anyOrigin = true
+			continue
+		}
+		if pattern, ok := parseOriginPattern(origin); ok {
+			c.origins = append(c.origins, pattern)
+		}
+	}
+	for _, header := range cfg.AllowedHeaders {
+		if header == "*" {
+			c.anyHeader = true
+			continue
+		}
+		c.headers[strings.ToLower(header)] = true
+	}
+	if seconds := int64(cfg.MaxAge.Seconds()); seconds > 0 {
+		c.maxAge = strconv.FormatInt(seconds, 10)
+	}
+	return c
+}
+
+// AllowOrigin reports whether requests from origin are allowed
+func (c *CORS) AllowOrigin(origin string) bool {
+	if origin == "" || origin == "null" {
+		return false
+	}
+	if c.anyOrigin {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil || u.Host == "" || u.Path != "" || u.User != nil {
+		return false
+	}
+	scheme := strings.ToLower(u.Scheme)
+	host := strings.ToLower(u.Hostname())
+	port := u.Port()
+	for _, pattern := range c.origins {
+		if pattern.matches(scheme, host, port) {
+			return true
+		}
+	}
+	return false
+}
+
+// Middleware returns the gin middleware. Preflight requests are answered
+// here, before authentication, since browsers send them without credentials.
+func (c *CORS) Middleware() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		origin := ctx.GetHeader(HeaderOrigin)
+		if origin == "" {
+			ctx.Next()
+			return
+		}
+		allowed := c.AllowOrigin(origin)
+
+		if ctx.Request.Method == http.MethodOptions && ctx.GetHeader(HeaderAccessControlRequestMethod) != "" {
+			c.preflight(ctx, origin, allowed)
+			return
+		}
+
+		beforeHeaders(ctx, func(header http.Header) {
+			for _, name := range corsResponseHeaders {
+				header.Del(name)
+			}
+			addVary(header, HeaderOrigin)
+			if !allowed {
+				return
+			}
+			c.allowOrigin(header, origin)
+			if c.exposedHeaders != "" {
+				header.Set(HeaderAccessControlExposeHeaders, c.exposedHeaders)
+			}
+		})
+	}
+}
+
+// preflight answers a preflight request. Disallowed requests get a 403
+// without CORS headers, which the browser reports as a CORS failure.
+func (c *CORS) preflight(ctx *gin.Context, origin string, allowed bool) {
+	header := ctx.Writer.Header()
+	addVary(header, HeaderOrigin)
+	addVary(header, HeaderAccessControlRequestMethod)
+	addVary(header, HeaderAccessControlRequestHeaders)
+
+	method := ctx.GetHeader(HeaderAccessControlRequestMethod)
+	requested := requestedHeaders(ctx.GetHeader(HeaderAccessControlRequestHeaders))
+	if !allowed || !c.allowMethod(method) || !c.allowHeaders(requested) {
+		ctx.AbortWithStatus(http.StatusForbidden)
+		return
+	}
+
+	c.allowOrigin(header, origin)
+	header.Set(HeaderAccessControlAllowMethods, strings.Join(c.methods, ", "))
+	if len(requested) > 0 {
+		header.Set(HeaderAccessControlAllowHeaders, strings.Join(requested, ", "))
+	}
+	if c.maxAge != "" {
+		header.Set(HeaderAccessControlMaxAge, c.maxAge)
+	}
+	ctx.AbortWithStatus(http.StatusNoContent)
+}
+
+// allowOrigin sets the allowed origin. With credentials the origin must be
+// named explicitly; "*" is only sent when any origin is allowed without them.
+func (c *CORS) allowOrigin(header http.Header, origin string) {
+	if c.anyOrigin && !c.credentials {
+		header.Set(HeaderAccessControlAllowOrigin, "*")
+		return
+	}
+	header.Set(HeaderAccessControlAllowOrigin, origin)
+	if c.credentials {
+		header.Set(HeaderAccessControlAllowCredentials, "true")
+	}
+}
+
+func (c *CORS) allowMethod(method string) bool {
+	for _, m := range c.methods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *CORS) allowHeaders(requested []string) bool {
+	if c.anyHeader {
+		return true
+	}
+	for _, header := range requested {
+		if !c.headers[header] {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *originPattern) matches(scheme, host, port string) bool {
+	if scheme != p.scheme {
+		return false
+	}
+	if p.port != "*" && port != p.port {
+		return false
+	}
+	if p.subdomains {
+		return strings.HasSuffix(host, "."+p.host)
+	}
+	return host == p.host
+}
+
+// parseOriginPattern parses a scheme://host[:port] origin in which the host
+// may start with "*." and the port may be "*"
+func parseOriginPattern(origin string) (originPattern, bool) {
+	scheme, rest, ok := strings.Cut(strings.ToLower(origin), "://")
+	if !ok {
+		return originPattern{}, false
+	}
+	pattern := originPattern{scheme: scheme}
+	if strings.HasSuffix(rest, ":*") {
+		pattern.port = "*"
+		rest = strings.TrimSuffix(rest, ":*")
+	}
+	if strings.HasPrefix(rest, "*.") {
+		pattern.subdomains = true
+		rest = strings.TrimPrefix(rest, "*.")
+	}
+	u, err := url.Parse(scheme + "://" + rest)
+	if err != nil || u.Host == "" {
+		return originPattern{}, false
+	}
+	pattern.host = u.Hostname()
+	if pattern.port == "" {
+		pattern.port = u.Port()
+		// An explicit default port is the same origin as none
+		if (scheme == "https" && pattern.port == "443") || (scheme == "http" && pattern.port == "80") {
+			pattern.port = ""
+		}
+	}
+	return pattern, true
+}
+
+// requestedHeaders splits an Access-Control-Request-Headers value into
+// lowercase header names
+func requestedHeaders(value string) []string {
+	var headers []string
+	for _, header := range strings.Split(value, ",") {
+		if header = strings.ToLower(strings.TrimSpace(header)); header != "" {
+			headers = append(headers, header)
+		}
+	}
+	return headers
+}
+
+// addVary adds a name to the Vary header unless it is already listed
+func addVary(header http.Header, name string) {
+	for _, value := range header.Values("Vary") {
+		for _, existing := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(existing), name) {
+				return
+			}
+		}
+	}
+	header.Add("Vary", name)
+}
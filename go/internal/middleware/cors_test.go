@@ -0,0 +1,191 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func testCORSConfig() *config.CORSConfig {
+	return &config.CORSConfig{
+		Enabled:        true,
+		AllowedOrigins: []string{"https://app.example.com", "https://*.tools.example.com", "http://localhost:*"},
+		AllowedMethods: []string{"GET", "POST", "DELETE"},
+		AllowedHeaders: []string{"Authorization", "Content-Type", "Mcp-Session-Id"},
+		ExposedHeaders: []string{"Mcp-Session-Id", "WWW-Authenticate"},
+		MaxAge:         10 * time.Minute,
+	}
+}
+
+func newCORSRouter(cfg *config.CORSConfig) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(NewCORS(cfg).Middleware())
+	router.NoRoute(func(c *gin.Context) {
+		// The upstream's own CORS headers are replaced by the policy
+		c.Header(HeaderAccessControlAllowOrigin, "*")
+		c.Header("Vary", "Accept-Encoding")
+		c.String(http.StatusOK, "ok")
+	})
+	return router
+}
+
+func TestCORS_AllowOrigin(t *testing.T) {
+	cors := NewCORS(testCORSConfig())
+
+	tests := []struct {
+		origin  string
+		allowed bool
+	}{
+		{origin: "https://app.example.com", allowed: true},
+		{origin: "https://APP.example.com", allowed: true},
+		{origin: "http://app.example.com", allowed: false},
+		{origin: "https://app.example.com:8443", allowed: false},
+		{origin: "https://evil.com", allowed: false},
+		{origin: "https://app.example.com.evil.com", allowed: false},
+		{origin: "https://a.tools.example.com", allowed: true},
+		{origin: "https://a.b.tools.example.com", allowed: true},
+		{origin: "https://tools.example.com", allowed: false},
+		{origin: "https://eviltools.example.com", allowed: false},
+		{origin: "http://localhost:5173", allowed: true},
+		{origin: "http://localhost", allowed: true},
+		{origin: "null", allowed: false},
+		{origin: "", allowed: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.origin, func(t *testing.T) {
+			assert.Equal(t, tt.allowed, cors.AllowOrigin(tt.origin))
+		})
+	}
+}
+
+func TestCORS_Preflight(t *testing.T) {
+	router := newCORSRouter(testCORSConfig())
+
+	t.Run("allowed", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodOptions, "/mcp", nil)
+		req.Header.Set(HeaderOrigin, "https://app.example.com")
+		req.Header.Set(HeaderAccessControlRequestMethod, "POST")
+		req.Header.Set(HeaderAccessControlRequestHeaders, "content-type, Mcp-Session-Id")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		assert.Equal(t, "https://app.example.com", w.Header().Get(HeaderAccessControlAllowOrigin))
+		assert.Equal(t, "GET, POST, DELETE", w.Header().Get(HeaderAccessControlAllowMethods))
+		assert.Equal(t, "content-type, mcp-session-id", w.Header().Get(HeaderAccessControlAllowHeaders))
+		assert.Equal(t, "600", w.Header().Get(HeaderAccessControlMaxAge))
+		assert.Empty(t, w.Header().Get(HeaderAccessControlAllowCredentials))
+		assert.Contains(t, w.Header().Values("Vary"), HeaderOrigin)
+		assert.Empty(t, w.Body.String())
+	})
+
+	rejected := []struct {
+		name    string
+		origin  string
+		method  string
+		headers string
+	}{
+		{name: "origin", origin: "https://evil.com", method: "POST"},
+		{name: "method", origin: "https://app.example.com", method: "PUT"},
+		{name: "header", origin: "https://app.example.com", method: "POST", headers: "X-Custom"},
+	}
+	for _, tt := range rejected {
+		t.Run("rejected "+tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodOptions, "/mcp", nil)
+			req.Header.Set(HeaderOrigin, tt.origin)
+			req.Header.Set(HeaderAccessControlRequestMethod, tt.method)
+			if tt.headers != "" {
+				req.Header.Set(HeaderAccessControlRequestHeaders, tt.headers)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusForbidden, w.Code)
+			assert.Empty(t, w.Header().Get(HeaderAccessControlAllowOrigin))
+		})
+	}
+
+	t.Run("plain OPTIONS is not a preflight", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodOptions, "/mcp", nil)
+		req.Header.Set(HeaderOrigin, "https://app.example.com")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "ok", w.Body.String())
+	})
+}
+
+func TestCORS_ActualRequest(t *testing.T) {
+	router := newCORSRouter(testCORSConfig())
+
+	t.Run("allowed origin", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/mcp", nil)
+		req.Header.Set(HeaderOrigin, "https://a.tools.example.com")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, []string{"https://a.tools.example.com"}, w.Header().Values(HeaderAccessControlAllowOrigin))
+		assert.Equal(t, "Mcp-Session-Id, WWW-Authenticate", w.Header().Get(HeaderAccessControlExposeHeaders))
+		assert.Equal(t, []string{"Accept-Encoding", HeaderOrigin}, w.Header().Values("Vary"))
+	})
+
+	t.Run("disallowed origin", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/mcp", nil)
+		req.Header.Set(HeaderOrigin, "https://evil.com")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Header().Get(HeaderAccessControlAllowOrigin))
+		assert.Empty(t, w.Header().Get(HeaderAccessControlExposeHeaders))
+	})
+
+	t.Run("same-origin request without Origin", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/mcp", nil))
+
+		assert.Equal(t, "*", w.Header().Get(HeaderAccessControlAllowOrigin))
+	})
+}
+
+func TestCORS_Credentials(t *testing.T) {
+	cfg := testCORSConfig()
+	cfg.AllowCredentials = true
+	router := newCORSRouter(cfg)
+
+	req := httptest.NewRequest(http.MethodGet, "/mcp", nil)
+	req.Header.Set(HeaderOrigin, "https://app.example.com")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, "https://app.example.com", w.Header().Get(HeaderAccessControlAllowOrigin))
+	assert.Equal(t, "true", w.Header().Get(HeaderAccessControlAllowCredentials))
+}
+
+func TestCORS_AnyOrigin(t *testing.T) {
+	cfg := testCORSConfig()
+	cfg.AllowedOrigins = []string{"*"}
+	cfg.AllowedHeaders = []string{"*"}
+	router := newCORSRouter(cfg)
+
+	req := httptest.NewRequest(http.MethodOptions, "/mcp", nil)
+	req.Header.Set(HeaderOrigin, "https://anywhere.example.org")
+	req.Header.Set(HeaderAccessControlRequestMethod, "GET")
+	req.Header.Set(HeaderAccessControlRequestHeaders, "X-Custom")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "*", w.Header().Get(HeaderAccessControlAllowOrigin))
+	assert.Equal(t, "x-custom", w.Header().Get(HeaderAccessControlAllowHeaders))
+	assert.Empty(t, w.Header().Get(HeaderAccessControlAllowCredentials))
+}
@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// headerHookWriter runs a hook on the response headers just before they are
+// sent, after the handlers and the proxied upstream have set theirs. gin
+// sends headers on the first write, or after the handlers return when
+// nothing was written.
+type headerHookWriter struct {
+	gin.ResponseWriter
+	hook func(http.Header)
+	done bool
+}
+
+// beforeHeaders runs hook on the response headers of c before they are sent.
+// It wraps the writer and calls c.Next.
+func beforeHeaders(c *gin.Context, hook func(http.Header)) {
+	w := &headerHookWriter{ResponseWriter: c.Writer, hook: hook}
+	c.Writer = w
+	c.Next()
+	w.run()
+}
+
+func (w *headerHookWriter) run() {
+	if w.done || w.ResponseWriter.Written() {
+		return
+	}
+	w.done = true
+	w.hook(w.Header())
+}
+
+func (w *headerHookWriter) WriteHeaderNow() {
+	w.run()
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *headerHookWriter) Write(data []byte) (int, error) {
+	w.run()
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *headerHookWriter) WriteString(s string) (int, error) {
+	w.run()
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *headerHookWriter) Flush() {
+	w.run()
+	w.ResponseWriter.Flush()
+}
+
+func (w *headerHookWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	w.run()
+	return w.ResponseWriter.Hijack()
+}
@@ -0,0 +1,220 @@
+package middleware
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/auth/claims"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/auth/identity"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/auth/oidc"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/config"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/pathprefix"
+)
+
+// HeaderTemplateData is the data available to header templates
+type HeaderTemplateData struct {
+	UserID    string
+	Subject   string
+	Email     string
+	Name      string
+	Groups    []string
+	Roles     []string
+	Provider  string
+	ExpiresAt time.Time
+	Claims    map[string]interface{}
+}
+
+// HeaderTemplates renders upstream identity headers from the user session
+type HeaderTemplates struct {
+	global         map[string]*template.Template
+	routes         []headerRoute
+	names          []string
+	maxValueLength int
+}
+
+// headerRoute holds the compiled template set for a path prefix
+type headerRoute struct {
+	pathPrefix string
+	templates  map[string]*template.Template
+}
+
+// NewHeaderTemplates compiles the template headers in the configuration
+func NewHeaderTemplates(cfg *config.HeadersConfig) (*HeaderTemplates, error) {
+	ht := &HeaderTemplates{
+		maxValueLength: cfg.MaxValueLength,
+	}
+	names := make(map[string]bool)
+
+	var err error
+	if ht.global, err = compileHeaderTemplates(cfg.Templates, names); err != nil {
+		return nil, err
+	}
+
+	for _, route := range cfg.Routes {
+		compiled, err := compileHeaderTemplates(route.Templates, names)
+		if err != nil {
+			return nil, fmt.Errorf("route %s: %w", route.PathPrefix, err)
+		}
+		ht.routes = append(ht.routes, headerRoute{
+			pathPrefix: route.PathPrefix,
+			templates:  compiled,
+		})
+	}
+
+	// Longest prefix first so the most specific route wins
+	sort.SliceStable(ht.routes, func(i, j int) bool {
+		return len(ht.routes[i].pathPrefix) > len(ht.routes[j].pathPrefix)
+	})
+
+	for name := range names {
+		ht.names = append(ht.names, name)
+	}
+	sort.Strings(ht.names)
+
+	return ht, nil
+}
+
+// compileHeaderTemplates compiles a header name to template map; an empty
+// template is kept as nil so that routes can disable a global header
+func compileHeaderTemplates(templates map[string]string, names map[string]bool) (map[string]*template.Template, error) {
+	compiled := make(map[string]*template.Template, len(templates))
+	for name, text := range templates {
+		canonical := http.CanonicalHeaderKey(strings.TrimSpace(name))
+		if canonical == "" {
+			return nil, fmt.Errorf("empty header name")
+		}
+		names[canonical] = true
+
+		if strings.TrimSpace(text) == "" {
+			compiled[canonical] = nil
+			continue
+		}
+
+		tmpl, err := template.New(canonical).Funcs(headerTemplateFuncs).Option("missingkey=zero").Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid template for header %s: %w", canonical, err)
+		}
+		compiled[canonical] = tmpl
+	}
+	return compiled, nil
+}
+
+// headerTemplateFuncs are the helper functions available in header templates
+var headerTemplateFuncs = template.FuncMap{
+	"join": func(sep string, values []string) string {
+		return strings.Join(values, sep)
+	},
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+	"default": func(fallback, value string) string {
+		if value == "" {
+			return fallback
+		}
+		return value
+	},
+}
+
+// Claim returns the first value at a claim path, e.g. {{ .Claim "tenant_id" }}
+func (d *HeaderTemplateData) Claim(path string) string {
+	if values := d.ClaimValues(path); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// ClaimValues returns all values at a claim path, e.g. {{ join "," (.ClaimValues "realm_access.roles") }}
+func (d *HeaderTemplateData) ClaimValues(path string) []string {
+	p, err := claims.ParsePath(path)
+	if err != nil {
+		return nil
+	}
+	return p.Strings(d.Claims)
+}
+
+// Names returns every header name managed by the templates
+func (ht *HeaderTemplates) Names() []string {
+	return ht.names
+}
+
+// Strip removes client-supplied copies of all template-managed headers
+func (ht *HeaderTemplates) Strip(r *http.Request) {
+	for _, name := range ht.names {
+		r.Header.Del(name)
+	}
+}
+
+// Render renders the template headers applicable to the request path.
+// Values exceeding the size limit are dropped and reported in the returned errors.
+func (ht *HeaderTemplates) Render(path string, sess *oidc.UserSession) (http.Header, []error) {
+	headers := make(http.Header)
+	if sess == nil {
+		return headers, nil
+	}
+
+	templates := make(map[string]*template.Template, len(ht.global))
+	for name, tmpl := range ht.global {
+		templates[name] = tmpl
+	}
+	if route := ht.matchRoute(path); route != nil {
+		for name, tmpl := range route.templates {
+			templates[name] = tmpl
+		}
+	}
+
+	data := newHeaderTemplateData(sess)
+
+	var errs []error
+	for name, tmpl := range templates {
+		if tmpl == nil {
+			continue
+		}
+
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, data); err != nil {
+			errs = append(errs, fmt.Errorf("header %s: %w", name, err))
+			continue
+		}
+
+		value := identity.SanitizeHeaderValue(buf.String())
+		if value == "" {
+			continue
+		}
+		if ht.maxValueLength > 0 && len(value) > ht.maxValueLength {
+			errs = append(errs, fmt.Errorf("header %s: value length %d exceeds limit %d", name, len(value), ht.maxValueLength))
+			continue
+		}
+		headers.Set(name, value)
+	}
+
+	return headers, errs
+}
+
+// matchRoute returns the most specific route matching the path
+func (ht *HeaderTemplates) matchRoute(path string) *headerRoute {
+	for i := range ht.routes {
+		if pathprefix.Match(path, ht.routes[i].pathPrefix) {
+			return &ht.routes[i]
+		}
+	}
+	return nil
+}
+
+// newHeaderTemplateData builds template data from a session
+func newHeaderTemplateData(sess *oidc.UserSession) *HeaderTemplateData {
+	return &HeaderTemplateData{
+		UserID:    sess.ID,
+		Subject:   sess.Subject,
+		Email:     sess.Email,
+		Name:      sess.Name,
+		Groups:    sess.Groups,
+		Roles:     sess.Roles,
+		Provider:  sess.Provider,
+		ExpiresAt: sess.ExpiresAt,
+		Claims:    sess.Claims,
+	}
+}
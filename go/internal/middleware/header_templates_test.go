@@ -0,0 +1,183 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/auth/oidc"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zaptest"
+)
+
+func testTemplateSession() *oidc.UserSession {
+	return &oidc.UserSession{
+		ID:        "user123",
+		Email:     "alice@example.com",
+		Name:      "Alice",
+		Groups:    []string{"platform", "sre"},
+		Provider:  "keycloak",
+		ExpiresAt: time.Unix(1700000000, 0),
+		Claims: map[string]interface{}{
+			"tenant_id":       "tenant-42",
+			"employee_number": float64(1001),
+			"https://example.com/org": map[string]interface{}{
+				"department": "Engineering",
+			},
+		},
+	}
+}
+
+func TestHeaderTemplates_Render(t *testing.T) {
+	templates, err := NewHeaderTemplates(&config.HeadersConfig{
+		Templates: map[string]string{
+			"X-Tenant-ID":       `{{ .Claim "tenant_id" }}`,
+			"x-employee-number": `{{ .Claim "employee_number" }}`,
+			"X-Department":      `{{ .Claim "[\"https://example.com/org\"].department" | lower }}`,
+			"X-Groups":          `{{ join ";" .Groups }}`,
+			"X-Provider":        `{{ .Provider }}`,
+			"X-Token-Expiry":    `{{ .ExpiresAt.Unix }}`,
+			"X-Region":          `{{ .Claim "region" | default "global" }}`,
+			"X-Missing":         `{{ .Claim "missing" }}`,
+		},
+	})
+	require.NoError(t, err)
+
+	headers, errs := templates.Render("/mcp", testTemplateSession())
+	assert.Empty(t, errs)
+	assert.Equal(t, "tenant-42", headers.Get("X-Tenant-ID"))
+	assert.Equal(t, "1001", headers.Get("X-Employee-Number"))
+	assert.Equal(t, "engineering", headers.Get("X-Department"))
+	assert.Equal(t, "platform;sre", headers.Get("X-Groups"))
+	assert.Equal(t, "keycloak", headers.Get("X-Provider"))
+	assert.Equal(t, "1700000000", headers.Get("X-Token-Expiry"))
+	assert.Equal(t, "global", headers.Get("X-Region"))
+	_, exists := headers["X-Missing"]
+	assert.False(t, exists, "empty values should not produce a header")
+}
+
+func TestHeaderTemplates_Routes(t *testing.T) {
+	templates, err := NewHeaderTemplates(&config.HeadersConfig{
+		Templates: map[string]string{
+			"X-Tenant-ID": `{{ .Claim "tenant_id" }}`,
+			"X-Email":     `{{ .Email }}`,
+		},
+		Routes: []config.HeaderRouteConfig{
+			{
+				PathPrefix: "/hr",
+				Templates: map[string]string{
+					"X-Employee-Number": `{{ .Claim "employee_number" }}`,
+				},
+			},
+			{
+				PathPrefix: "/hr/public",
+				Templates: map[string]string{
+					"X-Email": "",
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"X-Email", "X-Employee-Number", "X-Tenant-Id"}, templates.Names())
+
+	tests := []struct {
+		path     string
+		expected map[string]string
+	}{
+		{
+			path:     "/mcp",
+			expected: map[string]string{"X-Tenant-Id": "tenant-42", "X-Email": "alice@example.com"},
+		},
+		{
+			path:     "/hr/tools",
+			expected: map[string]string{"X-Tenant-Id": "tenant-42", "X-Email": "alice@example.com", "X-Employee-Number": "1001"},
+		},
+		{
+			path:     "/hrm",
+			expected: map[string]string{"X-Tenant-Id": "tenant-42", "X-Email": "alice@example.com"},
+		},
+		{
+			path:     "/hr/public/info",
+			expected: map[string]string{"X-Tenant-Id": "tenant-42"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			headers, errs := templates.Render(tt.path, testTemplateSession())
+			assert.Empty(t, errs)
+			assert.Len(t, headers, len(tt.expected))
+			for name, value := range tt.expected {
+				assert.Equal(t, value, headers.Get(name))
+			}
+		})
+	}
+}
+
+func TestHeaderTemplates_EscapingAndLimits(t *testing.T) {
+	templates, err := NewHeaderTemplates(&config.HeadersConfig{
+		Templates: map[string]string{
+			"X-Name":  `{{ .Name }}`,
+			"X-Large": `{{ .Claim "large" }}`,
+		},
+		MaxValueLength: 32,
+	})
+	require.NoError(t, err)
+
+	sess := testTemplateSession()
+	sess.Name = "Alice\r\nX-Injected: evil"
+	sess.Claims["large"] = strings.Repeat("a", 64)
+
+	headers, errs := templates.Render("/", sess)
+	assert.Equal(t, "AliceX-Injected: evil", headers.Get("X-Name"))
+	assert.Empty(t, headers.Get("X-Injected"))
+	assert.Empty(t, headers.Get("X-Large"))
+	require.Len(t, errs, 1)
+	assert.Contains(t, errs[0].Error(), "exceeds limit")
+}
+
+func TestHeaderTemplates_InvalidTemplate(t *testing.T) {
+	_, err := NewHeaderTemplates(&config.HeadersConfig{
+		Templates: map[string]string{
+			"X-Broken": `{{ .Claim "x" `,
+		},
+	})
+	assert.Error(t, err)
+}
+
+func TestHeaderInjector_TemplateHeadersStripClientCopies(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+
+	injector, err := NewHeaderInjectorWithMapper(&config.HeadersConfig{
+		Templates: map[string]string{
+			"X-Tenant-ID": `{{ .Claim "tenant_id" }}`,
+			"X-Role":      `{{ .Claim "role" }}`,
+		},
+	}, nil, logger)
+	require.NoError(t, err)
+
+	t.Run("Authenticated request", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/mcp", nil)
+		req.Header.Set("X-Tenant-ID", "spoofed-tenant")
+		req.Header.Set("X-Role", "admin")
+
+		injector.InjectHeaders(req, testTemplateSession())
+
+		assert.Equal(t, []string{"tenant-42"}, req.Header.Values("X-Tenant-ID"))
+		assert.Empty(t, req.Header.Values("X-Role"), "spoofed header without a rendered value must be removed")
+	})
+
+	t.Run("Unauthenticated request", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/mcp", nil)
+		req.Header.Set("X-Tenant-ID", "spoofed-tenant")
+
+		injector.InjectHeaders(req, nil)
+
+		assert.Empty(t, req.Header.Get("X-Tenant-ID"))
+	})
+}
@@ -8,6 +8,8 @@ import (
 	"strings"
 	"time"
 
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/auth/claims"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/auth/identity"
 	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/auth/oidc"
 	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/config"
 	"go.uber.org/zap"
@@ -15,16 +17,54 @@ import (
 
 // HeaderInjector handles custom header injection
 type HeaderInjector struct {
-	config *config.HeadersConfig
-	logger *zap.Logger
+	config      *config.HeadersConfig
+	claimMapper *claims.Mapper
+	templates   *HeaderTemplates
+	propagator  *identity.Propagator
+	logger      *zap.Logger
 }
 
-// NewHeaderInjector creates a new header injector
+// NewHeaderInjector creates a new header injector.
+// Invalid header templates or signing settings are logged and disabled; use
+// NewHeaderInjectorWithMapper to receive the error instead.
 func NewHeaderInjector(config *config.HeadersConfig, logger *zap.Logger) *HeaderInjector {
-	return &HeaderInjector{
-		config: config,
-		logger: logger,
+	hi, err := NewHeaderInjectorWithMapper(config, nil, logger)
+	if err != nil {
+		logger.Error("Invalid header configuration, template headers and signing disabled", zap.Error(err))
+		return &HeaderInjector{
+			config:      config,
+			claimMapper: claims.DefaultMapper(),
+			propagator:  identity.NewUnsignedPropagator(config),
+			logger:      logger,
+		}
+	}
+	return hi
+}
+
+// NewHeaderInjectorWithMapper creates a header injector that extracts groups
+// from session claims using the given claim mapper
+func NewHeaderInjectorWithMapper(config *config.HeadersConfig, mapper *claims.Mapper, logger *zap.Logger) (*HeaderInjector, error) {
+	if mapper == nil {
+		mapper = claims.DefaultMapper()
 	}
+
+	templates, err := NewHeaderTemplates(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile header templates: %w", err)
+	}
+
+	propagator, err := identity.NewPropagator(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &HeaderInjector{
+		config:      config,
+		claimMapper: mapper,
+		templates:   templates,
+		propagator:  propagator,
+		logger:      logger,
+	}, nil
 }
 
 // InjectHeaders injects custom headers into the request
@@ -35,10 +75,9 @@ func (hi *HeaderInjector) InjectHeaders(r *http.Request, sess *oidc.UserSession)
 	// Inject dynamic headers
 	hi.injectDynamicHeaders(r, sess)
 	
-	// Inject user headers from session if available
-	if sess != nil {
-		hi.injectUserHeaders(r, sess)
-	}
+	// Replace client-supplied identity headers with the session identity;
+	// without a session they are only removed
+	hi.injectUserHeaders(r, sess)
 }
 
 // injectStaticHeaders injects static custom headers from configuration
@@ -128,64 +167,44 @@ func (hi *HeaderInjector) injectDynamicHeaders(r *http.Request, sess *oidc.UserS
 	}
 }
 
-// injectUserHeaders injects user-related headers from session
+// injectUserHeaders strips all identity headers from the request and injects
+// the user and template headers derived from the session
 func (hi *HeaderInjector) injectUserHeaders(r *http.Request, sess *oidc.UserSession) {
-	// User ID header
-	if hi.config.UserID != "" && sess.ID != "" {
-		r.Header.Set(hi.config.UserID, sess.ID)
-		hi.logger.Debug("Injected user ID header",
-			zap.String("header_name", hi.config.UserID),
-			zap.String("user_id", sess.ID),
-		)
+	if sess == nil {
+		hi.propagator.Apply(r, nil, nil)
+		return
 	}
 	
-	// User Email header
-	if hi.config.UserEmail != "" && sess.Email != "" {
-		r.Header.Set(hi.config.UserEmail, sess.Email)
-		hi.logger.Debug("Injected user email header",
-			zap.String("header_name", hi.config.UserEmail),
-			zap.String("user_email", sess.Email),
-		)
+	// User groups - mapped at login, or extracted from claims for older sessions
+	groups := sess.Groups
+	if len(groups) == 0 && sess.Claims != nil {
+		groups = hi.claimMapper.Groups(sess.Claims)
 	}
 	
-	// User Name header
-	if hi.config.UserName != "" && sess.Name != "" {
-		r.Header.Set(hi.config.UserName, sess.Name)
-		hi.logger.Debug("Injected user name header",
-			zap.String("header_name", hi.config.UserName),
-			zap.String("user_name", sess.Name),
-		)
+	hi.propagator.Apply(r, &identity.Identity{
+		UserID: sess.ID,
+		Email:  sess.Email,
+		Name:   sess.Name,
+		Groups: groups,
+	}, hi.renderTemplateHeaders(r, sess))
+	
+	hi.logger.Debug("Injected user headers",
+		zap.String("user_id", sess.ID),
+		zap.Bool("signed", hi.propagator.Signed()),
+	)
+}
+
+// renderTemplateHeaders renders the configured header templates for the session
+func (hi *HeaderInjector) renderTemplateHeaders(r *http.Request, sess *oidc.UserSession) http.Header {
+	if hi.templates == nil {
+		return nil
 	}
 	
-	// User Groups header - extract from claims
-	if hi.config.UserGroups != "" && sess.Claims != nil {
-		if groupsValue, exists := sess.Claims["groups"]; exists {
-			var groups []string
-			// Handle different types of groups claim
-			switch v := groupsValue.(type) {
-			case []string:
-				groups = v
-			case []interface{}:
-				for _, g := range v {
-					if gStr, ok := g.(string); ok {
-						groups = append(groups, gStr)
-					}
-				}
-			case string:
-				// Single group as string
-				groups = []string{v}
-			}
-			
-			if len(groups) > 0 {
-				groupsStr := strings.Join(groups, ",")
-				r.Header.Set(hi.config.UserGroups, groupsStr)
-				hi.logger.Debug("Injected user groups header",
-					zap.String("header_name", hi.config.UserGroups),
-					zap.String("user_groups", groupsStr),
-				)
-			}
-		}
+	headers, errs := hi.templates.Render(r.URL.Path, sess)
+	for _, err := range errs {
+		hi.logger.Warn("Failed to render template header", zap.Error(err))
 	}
+	return headers
 }
 
 // formatTimestamp formats timestamp according to the specified format
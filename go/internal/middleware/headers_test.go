@@ -8,6 +8,8 @@ import (
 	"testing"
 	"time"
 
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/auth/claims"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/auth/identity"
 	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/auth/oidc"
 	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/config"
 	"github.com/stretchr/testify/assert"
@@ -380,3 +382,86 @@ func TestHeaderInjector_DisabledHeaders(t *testing.T) {
 	assert.Empty(t, req.Header.Get("X-Request-ID"))
 	assert.Empty(t, req.Header.Get("X-Timestamp"))
 }
+
+func TestHeaderInjector_MappedGroups(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+
+	headerConfig := &config.HeadersConfig{
+		UserGroups: "X-User-Groups",
+	}
+
+	mapper, err := claims.NewMapper(&config.ClaimsConfig{
+		Groups: config.ClaimMapping{Paths: []string{"realm_access.roles"}},
+	})
+	require.NoError(t, err)
+
+	injector, err := NewHeaderInjectorWithMapper(headerConfig, mapper, logger)
+	require.NoError(t, err)
+
+	t.Run("Groups stored in session", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		injector.injectUserHeaders(req, &oidc.UserSession{
+			ID:     "user123",
+			Groups: []string{"platform", "sre"},
+		})
+		assert.Equal(t, "platform,sre", req.Header.Get("X-User-Groups"))
+	})
+
+	t.Run("Groups mapped from claims", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		injector.injectUserHeaders(req, &oidc.UserSession{
+			ID: "user123",
+			Claims: map[string]interface{}{
+				"realm_access": map[string]interface{}{
+					"roles": []interface{}{"mcp-user"},
+				},
+			},
+		})
+		assert.Equal(t, "mcp-user", req.Header.Get("X-User-Groups"))
+	})
+}
+
+func TestHeaderInjector_SignedIdentityHeaders(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+	secret := "0123456789abcdef0123456789abcdef"
+
+	injector, err := NewHeaderInjectorWithMapper(&config.HeadersConfig{
+		UserID:     "X-User-ID",
+		UserEmail:  "X-User-Email",
+		UserName:   "X-User-Name",
+		UserGroups: "X-User-Groups",
+		Templates: map[string]string{
+			"X-User-Roles": `{{ join "," .Roles }}`,
+		},
+		Signing: config.HeaderSigningConfig{
+			Enabled: true,
+			Header:  "X-Identity-Signature",
+			Secret:  secret,
+		},
+	}, nil, logger)
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodGet, "/mcp", nil)
+	req.Header.Set("X-User-Groups", "spoofed")
+	req.Header.Set("X-Identity-Signature", "t=1,h=x-user-id,v1=forged")
+
+	injector.InjectHeaders(req, &oidc.UserSession{
+		ID:    "user123",
+		Email: "test@example.com",
+		Roles: []string{"reader"},
+	})
+
+	assert.Equal(t, "user123", req.Header.Get("X-User-ID"))
+	assert.Equal(t, "reader", req.Header.Get("X-User-Roles"))
+	assert.Empty(t, req.Header.Get("X-User-Groups"))
+	assert.NotEqual(t, "t=1,h=x-user-id,v1=forged", req.Header.Get("X-Identity-Signature"))
+	assert.NoError(t, identity.Verify(req, "X-Identity-Signature", []byte(secret), time.Minute))
+
+	// Headers injected without a session are removed along with the signature
+	unauthenticated := httptest.NewRequest(http.MethodGet, "/mcp", nil)
+	unauthenticated.Header.Set("X-User-ID", "attacker")
+	unauthenticated.Header.Set("X-Identity-Signature", "t=1,h=x-user-id,v1=forged")
+	injector.InjectHeaders(unauthenticated, nil)
+	assert.Empty(t, unauthenticated.Header.Get("X-User-ID"))
+	assert.Empty(t, unauthenticated.Header.Get("X-Identity-Signature"))
+}
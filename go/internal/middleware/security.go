@@ -1,17 +1,24 @@
 package middleware
 
 import (
+	"fmt"
+	"net/http"
+	"sort"
+
 	"github.com/gin-gonic/gin"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/config"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/pathprefix"
 )
 
 // Security header constants
 const (
-	HeaderXFrameOptions       = "X-Frame-Options"
-	HeaderXContentTypeOptions = "X-Content-Type-Options"
-	HeaderXXSSProtection      = "X-XSS-Protection"
-	HeaderReferrerPolicy      = "Referrer-Policy"
-	HeaderPermissionsPolicy   = "Permissions-Policy"
-	HeaderContentSecurityPolicy = "Content-Security-Policy"
+	HeaderXFrameOptions           = "X-Frame-Options"
+	HeaderXContentTypeOptions     = "X-Content-Type-Options"
+	HeaderXXSSProtection          = "X-XSS-Protection"
+	HeaderReferrerPolicy          = "Referrer-Policy"
+	HeaderPermissionsPolicy       = "Permissions-Policy"
+	HeaderContentSecurityPolicy   = "Content-Security-Policy"
+	HeaderStrictTransportSecurity = "Strict-Transport-Security"
 )
 
 // Default security header values
@@ -25,15 +32,180 @@ var DefaultSecurityHeaders = map[string]string{
 	HeaderContentSecurityPolicy: "default-src 'self'; script-src 'self'; style-src 'self' 'unsafe-inline'; img-src 'self' data: https:; font-src 'self'; connect-src 'self'; frame-ancestors 'none'",
 }
 
-// SecurityHeadersMiddleware adds security headers to responses
-func SecurityHeadersMiddleware() gin.HandlerFunc {
+// HeaderAction says how a header rule treats the values already set by the
+// handler or the proxied upstream
+type HeaderAction string
+
+const (
+	// HeaderSet replaces any existing value
+	HeaderSet HeaderAction = "set"
+	// HeaderAppend adds the value after the existing ones
+	HeaderAppend HeaderAction = "append"
+	// HeaderRemove deletes the header
+	HeaderRemove HeaderAction = "remove"
+	// HeaderPassthrough keeps an existing value and sets the rule's value,
+	// if any, only when there is none
+	HeaderPassthrough HeaderAction = "passthrough"
+)
+
+// HeaderRule changes one response header
+type HeaderRule struct {
+	Name   string
+	Value  string
+	Action HeaderAction
+}
+
+// routeHeaderRules holds the rules for requests under a path prefix
+type routeHeaderRules struct {
+	prefix string
+	rules  []HeaderRule
+	// configured routes are applied after built-in ones with the same prefix
+	configured bool
+}
+
+// SecurityHeaders applies security header rules to responses. The rules are
+// applied when the response headers are sent, so they can replace, extend,
+// remove or keep the values set by handlers and the upstream.
+type SecurityHeaders struct {
+	enabled bool
+	rules   []HeaderRule
+	routes  []routeHeaderRules
+}
+
+// NewSecurityHeaders creates the security headers from the defaults, the
+// HSTS header when tls is set, and the configured rules. A nil config applies
+// the defaults only.
+func NewSecurityHeaders(cfg *config.SecurityHeadersConfig, tls bool) *SecurityHeaders {
+	s := &SecurityHeaders{enabled: cfg == nil || cfg.Enabled}
+
+	names := make([]string, 0, len(DefaultSecurityHeaders))
+	for name := range DefaultSecurityHeaders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		s.rules = append(s.rules, HeaderRule{Name: name, Value: DefaultSecurityHeaders[name], Action: HeaderSet})
+	}
+	if cfg == nil {
+		return s
+	}
+
+	if tls && cfg.HSTS.Enabled {
+		s.rules = append(s.rules, HeaderRule{Name: HeaderStrictTransportSecurity, Value: hstsValue(&cfg.HSTS), Action: HeaderSet})
+	}
+	s.rules = mergeHeaderRules(s.rules, headerRules(cfg.Rules))
+	for _, route := range cfg.Routes {
+		s.addRoute(route.PathPrefix, headerRules(route.Rules), true)
+	}
+	return s
+}
+
+// Route adds built-in rules for requests under a path prefix. Configured
+// routes with the same prefix are applied after them.
+func (s *SecurityHeaders) Route(pathPrefix string, rules ...HeaderRule) {
+	s.addRoute(pathPrefix, rules, false)
+}
+
+func (s *SecurityHeaders) addRoute(prefix string, rules []HeaderRule, configured bool) {
+	s.routes = append(s.routes, routeHeaderRules{prefix: prefix, rules: rules, configured: configured})
+	// Shorter prefixes first so that the longest match is applied last
+	sort.SliceStable(s.routes, func(i, j int) bool {
+		a, b := s.routes[i], s.routes[j]
+		if len(a.prefix) != len(b.prefix) {
+			return len(a.prefix) < len(b.prefix)
+		}
+		return !a.configured && b.configured
+	})
+}
+
+// Rules returns the rules applied to responses for path
+func (s *SecurityHeaders) Rules(path string) []HeaderRule {
+	rules := s.rules
+	for _, route := range s.routes {
+		if pathprefix.Match(path, route.prefix) {
+			rules = mergeHeaderRules(rules, route.rules)
+		}
+	}
+	return rules
+}
+
+// Middleware returns the gin middleware applying the rules
+func (s *SecurityHeaders) Middleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Apply all security headers
-		for header, value := range DefaultSecurityHeaders {
-			c.Header(header, value)
+		if !s.enabled {
+			c.Next()
+			return
+		}
+		rules := s.Rules(c.Request.URL.Path)
+		beforeHeaders(c, func(header http.Header) {
+			applyHeaderRules(header, rules)
+		})
+	}
+}
+
+// SecurityHeadersMiddleware adds the default security headers to responses
+func SecurityHeadersMiddleware() gin.HandlerFunc {
+	return NewSecurityHeaders(nil, false).Middleware()
+}
+
+// applyHeaderRules applies rules in order to the response headers
+func applyHeaderRules(header http.Header, rules []HeaderRule) {
+	for _, rule := range rules {
+		switch rule.Action {
+		case HeaderRemove:
+			header.Del(rule.Name)
+		case HeaderAppend:
+			header.Add(rule.Name, rule.Value)
+		case HeaderPassthrough:
+			if header.Get(rule.Name) == "" && rule.Value != "" {
+				header.Set(rule.Name, rule.Value)
+			}
+		default:
+			header.Set(rule.Name, rule.Value)
+		}
+	}
+}
+
+// mergeHeaderRules returns base without the headers named in overrides,
+// followed by overrides
+func mergeHeaderRules(base, overrides []HeaderRule) []HeaderRule {
+	if len(overrides) == 0 {
+		return base
+	}
+	overridden := make(map[string]bool, len(overrides))
+	for _, rule := range overrides {
+		overridden[http.CanonicalHeaderKey(rule.Name)] = true
+	}
+	merged := make([]HeaderRule, 0, len(base)+len(overrides))
+	for _, rule := range base {
+		if !overridden[http.CanonicalHeaderKey(rule.Name)] {
+			merged = append(merged, rule)
 		}
-		
-		// Process request
-		c.Next()
 	}
-}
\ No newline at end of file
+	return append(merged, overrides...)
+}
+
+// headerRules converts configured rules; the action defaults to set
+func headerRules(configured []config.HeaderRule) []HeaderRule {
+	rules := make([]HeaderRule, 0, len(configured))
+	for _, r := range configured {
+		action := HeaderAction(r.Action)
+		if action == "" {
+			action = HeaderSet
+		}
+		rules = append(rules, HeaderRule{Name: r.Name, Value: r.Value, Action: action})
+	}
+	return rules
+}
+
+// hstsValue builds the Strict-Transport-Security header value
+func hstsValue(cfg *config.HSTSConfig) string {
+	value := fmt.Sprintf("max-age=%d", int64(cfg.MaxAge.Seconds()))
+	if cfg.IncludeSubdomains {
+		value += "; includeSubDomains"
+	}
+	if cfg.Preload {
+		value += "; preload"
+	}
+	return value
+}
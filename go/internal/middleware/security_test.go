@@ -4,8 +4,10 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/config"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -36,4 +38,152 @@ func TestSecurityHeadersMiddleware(t *testing.T) {
 			assert.Equal(t, expectedValue, actualValue, "Header %s should have value %s", header, expectedValue)
 		})
 	}
-}
\ No newline at end of file
+}
+func TestSecurityHeaders_Actions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cfg := &config.SecurityHeadersConfig{
+		Enabled: true,
+		Rules: []config.HeaderRule{
+			{Name: HeaderXFrameOptions, Action: "remove"},
+			{Name: HeaderContentSecurityPolicy, Value: "default-src 'none'", Action: "passthrough"},
+			{Name: "Cache-Control", Value: "no-transform", Action: "append"},
+			{Name: HeaderReferrerPolicy, Value: "no-referrer"},
+		},
+	}
+	router := gin.New()
+	router.Use(NewSecurityHeaders(cfg, false).Middleware())
+	router.GET("/upstream", func(c *gin.Context) {
+		// Headers set by the handler or upstream before the body is written
+		c.Header(HeaderXFrameOptions, "SAMEORIGIN")
+		c.Header(HeaderContentSecurityPolicy, "default-src 'self' 'unsafe-inline'")
+		c.Header("Cache-Control", "no-cache")
+		c.Header(HeaderReferrerPolicy, "unsafe-url")
+		c.String(http.StatusOK, "ok")
+	})
+	router.GET("/plain", func(c *gin.Context) {
+		c.String(http.StatusOK, "ok")
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/upstream", nil))
+	assert.Empty(t, w.Header().Values(HeaderXFrameOptions))
+	assert.Equal(t, []string{"default-src 'self' 'unsafe-inline'"}, w.Header().Values(HeaderContentSecurityPolicy))
+	assert.Equal(t, []string{"no-cache", "no-transform"}, w.Header().Values("Cache-Control"))
+	assert.Equal(t, []string{"no-referrer"}, w.Header().Values(HeaderReferrerPolicy))
+	assert.Equal(t, "nosniff", w.Header().Get(HeaderXContentTypeOptions))
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/plain", nil))
+	assert.Equal(t, "default-src 'none'", w.Header().Get(HeaderContentSecurityPolicy))
+	assert.Equal(t, "no-transform", w.Header().Get("Cache-Control"))
+}
+
+func TestSecurityHeaders_Routes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cfg := &config.SecurityHeadersConfig{
+		Enabled: true,
+		Rules:   []config.HeaderRule{{Name: "X-Test", Value: "global"}},
+		Routes: []config.RouteHeadersConfig{
+			{PathPrefix: "/ui", Rules: []config.HeaderRule{
+				{Name: HeaderContentSecurityPolicy, Action: "passthrough"},
+				{Name: "X-Test", Value: "ui"},
+			}},
+			{PathPrefix: "/ui/embed", Rules: []config.HeaderRule{
+				{Name: HeaderXFrameOptions, Action: "remove"},
+			}},
+			{PathPrefix: "/builtin", Rules: []config.HeaderRule{{Name: "X-Test", Value: "configured"}}},
+		},
+	}
+	headers := NewSecurityHeaders(cfg, false)
+	headers.Route("/builtin", HeaderRule{Name: "X-Test", Value: "builtin", Action: HeaderSet})
+
+	router := gin.New()
+	router.Use(headers.Middleware())
+	router.NoRoute(func(c *gin.Context) {
+		c.String(http.StatusOK, "ok")
+	})
+
+	tests := []struct {
+		path   string
+		xTest  string
+		csp    string
+		xFrame string
+	}{
+		{path: "/mcp", xTest: "global", csp: DefaultSecurityHeaders[HeaderContentSecurityPolicy], xFrame: "DENY"},
+		{path: "/ui/app", xTest: "ui", csp: "", xFrame: "DENY"},
+		{path: "/uikit", xTest: "global", csp: DefaultSecurityHeaders[HeaderContentSecurityPolicy], xFrame: "DENY"},
+		{path: "/ui/embed/app", xTest: "ui", csp: "", xFrame: ""},
+		{path: "/builtin", xTest: "configured", csp: DefaultSecurityHeaders[HeaderContentSecurityPolicy], xFrame: "DENY"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			assert.Equal(t, tt.xTest, w.Header().Get("X-Test"))
+			assert.Equal(t, tt.csp, w.Header().Get(HeaderContentSecurityPolicy))
+			assert.Equal(t, tt.xFrame, w.Header().Get(HeaderXFrameOptions))
+		})
+	}
+}
+
+func TestSecurityHeaders_HSTS(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cfg := &config.SecurityHeadersConfig{
+		Enabled: true,
+		HSTS:    config.HSTSConfig{Enabled: true, MaxAge: 365 * 24 * time.Hour, IncludeSubdomains: true, Preload: true},
+	}
+
+	for _, tls := range []bool{true, false} {
+		router := gin.New()
+		router.Use(NewSecurityHeaders(cfg, tls).Middleware())
+		router.GET("/test", func(c *gin.Context) {
+			c.String(http.StatusOK, "ok")
+		})
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/test", nil))
+		if tls {
+			assert.Equal(t, "max-age=31536000; includeSubDomains; preload", w.Header().Get(HeaderStrictTransportSecurity))
+		} else {
+			assert.Empty(t, w.Header().Get(HeaderStrictTransportSecurity))
+		}
+	}
+}
+
+func TestSecurityHeaders_ResponsesWithoutBody(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(SecurityHeadersMiddleware())
+	router.GET("/status", func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+	router.GET("/abort", func(c *gin.Context) {
+		c.AbortWithStatus(http.StatusForbidden)
+	})
+
+	for _, path := range []string{"/status", "/abort"} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Equal(t, "DENY", w.Header().Get(HeaderXFrameOptions), path)
+	}
+}
+
+func TestSecurityHeaders_Disabled(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(NewSecurityHeaders(&config.SecurityHeadersConfig{Enabled: false}, true).Middleware())
+	router.GET("/test", func(c *gin.Context) {
+		c.String(http.StatusOK, "ok")
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/test", nil))
+	for header := range DefaultSecurityHeaders {
+		assert.Empty(t, w.Header().Get(header), header)
+	}
+}
@@ -0,0 +1,14 @@
+// Package pathprefix matches request paths against configured route prefixes
+package pathprefix
+
+import "strings"
+
+// Match reports whether path lies under prefix on a segment boundary: "/api"
+// matches "/api" and "/api/users" but not "/apiary". A prefix ending in "/"
+// matches only below it, and an empty prefix matches every path.
+func Match(path, prefix string) bool {
+	if prefix == "" || strings.HasSuffix(prefix, "/") {
+		return strings.HasPrefix(path, prefix)
+	}
+	return path == prefix || strings.HasPrefix(path, prefix+"/")
+}
@@ -0,0 +1,28 @@
+package pathprefix
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		path, prefix string
+		expected     bool
+	}{
+		{"/api", "/api", true},
+		{"/api/", "/api", true},
+		{"/api/users", "/api", true},
+		{"/apiary", "/api", false},
+		{"/ap", "/api", false},
+		{"/api/users", "/api/", true},
+		{"/api", "/api/", false},
+		{"/anything", "/", true},
+		{"/anything", "", true},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, Match(tt.path, tt.prefix), "Match(%q, %q)", tt.path, tt.prefix)
+	}
+}
@@ -10,7 +10,10 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/auth/assertion"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/auth/claims"
 	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/auth/oidc"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/auth/tokenexchange"
 	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/config"
 	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/metrics"
 	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/middleware"
@@ -32,6 +35,9 @@ type Proxy struct {
 	logger         *zap.Logger
 	tracer         trace.Tracer
 	headerInjector *middleware.HeaderInjector
+	assertion      *assertion.Issuer
+	tokenExchange  *tokenexchange.Exchanger
+	loginURL       string
 }
 
 // Config holds proxy configuration
@@ -42,6 +48,10 @@ type Config struct {
 	Retry          RetryConfig
 	CircuitBreaker CircuitBreakerConfig
 	Headers        *config.HeadersConfig
+	ClaimMapper    *claims.Mapper
+	Assertion      *assertion.Issuer // Mints the identity assertion JWT (optional)
+	TokenExchange  *tokenexchange.Exchanger // Exchanges user tokens for upstream routes (optional)
+	LoginURL       string                   // Sent to SSE clients whose session reaches its absolute lifetime (default "/login")
 }
 
 // RetryConfig holds retry configuration
@@ -118,7 +128,16 @@ func New(config *Config, logger *zap.Logger) (*Proxy, error) {
 	// Create header injector if headers config is provided
 	var headerInjector *middleware.HeaderInjector
 	if config.Headers != nil {
-		headerInjector = middleware.NewHeaderInjector(config.Headers, logger)
+		var err error
+		headerInjector, err = middleware.NewHeaderInjectorWithMapper(config.Headers, config.ClaimMapper, logger)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	loginURL := config.LoginURL
+	if loginURL == "" {
+		loginURL = "/login"
 	}
 
 	return &Proxy{
@@ -129,9 +148,67 @@ func New(config *Config, logger *zap.Logger) (*Proxy, error) {
 		logger:         logger,
 		tracer:         tracer,
 		headerInjector: headerInjector,
+		assertion:      config.Assertion,
+		tokenExchange:  config.TokenExchange,
+		loginURL:       loginURL,
 	}, nil
 }
 
+// injectAssertion replaces any client-supplied assertion with one minted for the session
+func (p *Proxy) injectAssertion(r *http.Request) {
+	r.Header.Del(p.assertion.Header())
+
+	sess := oidc.GetSessionFromContext(r.Context())
+	if sess == nil {
+		return
+	}
+
+	token, err := p.assertion.Mint(sess, r.Header.Get(assertion.MCPSessionHeader))
+	if err != nil {
+		p.logger.Error("Failed to mint identity assertion",
+			zap.Error(err),
+			zap.String("user_id", sess.ID),
+		)
+		return
+	}
+	r.Header.Set(p.assertion.Header(), token)
+}
+
+// applyTokenExchange sets an audience-specific bearer token for routes that
+// require token exchange; it writes an error response and returns false on failure
+func (p *Proxy) applyTokenExchange(w http.ResponseWriter, r *http.Request) bool {
+	route := p.tokenExchange.Match(r.URL.Path)
+	if route == nil {
+		return true
+	}
+
+	var subjectToken string
+	if sess := oidc.GetSessionFromContext(r.Context()); sess != nil {
+		subjectToken = sess.AccessToken
+	}
+
+	token, err := p.tokenExchange.Token(r.Context(), subjectToken, route)
+	if err != nil {
+		status := http.StatusBadGateway
+		if errors.Is(err, tokenexchange.ErrNoSubjectToken) || tokenexchange.IsInvalidGrant(err) {
+			status = http.StatusUnauthorized
+		}
+		p.logger.Warn("Token exchange failed",
+			zap.Error(err),
+			zap.String("path", r.URL.Path),
+			zap.String("route", route.PathPrefix),
+			zap.Int("status", status),
+		)
+		metrics.ProxyRequestsTotal.WithLabelValues(r.Method, strconv.Itoa(status), p.target.String()).Inc()
+		w.WriteHeader(status)
+		w.Write([]byte(http.StatusText(status)))
+		return false
+	}
+
+	r.Header.Set("Authorization", "Bearer "+token.AccessToken)
+	return true
+}
+
 // ServeHTTP implements http.Handler interface
 func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
@@ -152,6 +229,12 @@ func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	// Update request context
 	r = r.WithContext(ctx)
+
+	// Device credentials are long-lived proxy credentials; an upstream that
+	// saw one could replay it
+	if oidc.IsDeviceCredential(r) {
+		r.Header.Del("Authorization")
+	}
 	
 	// Inject custom headers if configured
 	if p.headerInjector != nil {
@@ -160,6 +243,16 @@ func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		p.headerInjector.InjectHeaders(r, sess)
 	}
 	
+	// Inject the signed identity assertion if configured
+	if p.assertion != nil {
+		p.injectAssertion(r)
+	}
+	
+	// Replace the Authorization header with an exchanged token for matching routes
+	if p.tokenExchange != nil && !p.applyTokenExchange(w, r) {
+		return
+	}
+	
 	// Check if this is a streaming request
 	if isStreamingRequest(r) {
 		span.SetAttributes(attribute.Bool("proxy.streaming", true))
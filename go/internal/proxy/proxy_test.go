@@ -13,6 +13,12 @@ import (
 	"testing"
 	"time"
 
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/auth/assertion"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/auth/oidc"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/auth/tokenexchange"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/config"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap/zaptest"
@@ -488,4 +494,206 @@ func TestProxy_RetryBehavior(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+func TestProxy_IdentityAssertion(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+
+	var received http.Header
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Clone()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	backendURL, err := url.Parse(backend.URL)
+	require.NoError(t, err)
+	port, err := strconv.Atoi(backendURL.Port())
+	require.NoError(t, err)
+
+	issuer, err := assertion.NewIssuer(&config.AssertionConfig{
+		Header:    "X-Identity-Assertion",
+		Issuer:    "https://proxy.example.com",
+		TTL:       time.Minute,
+		Algorithm: "ES256",
+	}, backend.URL)
+	require.NoError(t, err)
+
+	proxy, err := New(&Config{
+		TargetHost:     backendURL.Hostname(),
+		TargetPort:     port,
+		TargetScheme:   backendURL.Scheme,
+		Retry:          RetryConfig{MaxAttempts: 1},
+		CircuitBreaker: CircuitBreakerConfig{Threshold: 3, Timeout: time.Second},
+		Assertion:      issuer,
+	}, logger)
+	require.NoError(t, err)
+
+	t.Run("Authenticated request", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/mcp", nil)
+		req.Header.Set("X-Identity-Assertion", "forged")
+		req.Header.Set("Mcp-Session-Id", "mcp-123")
+		req = req.WithContext(context.WithValue(req.Context(), oidc.SessionContextKey{}, &oidc.UserSession{
+			ID:        "user123",
+			ExpiresAt: time.Now().Add(time.Hour),
+		}))
+
+		w := httptest.NewRecorder()
+		proxy.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		token := received.Get("X-Identity-Assertion")
+		require.NotEqual(t, "forged", token)
+
+		parsed, err := jwt.ParseSigned(token, []jose.SignatureAlgorithm{jose.ES256})
+		require.NoError(t, err)
+		keys := issuer.Keyring().JWKS()
+		var claims struct {
+			jwt.Claims
+			MCPSessionID string `json:"mcp_session_id"`
+		}
+		require.NoError(t, parsed.Claims(keys.Keys[0].Key, &claims))
+		assert.Equal(t, "user123", claims.Subject)
+		assert.Equal(t, jwt.Audience{backend.URL}, claims.Audience)
+		assert.Equal(t, "mcp-123", claims.MCPSessionID)
+	})
+
+	t.Run("Unauthenticated request", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/mcp", nil)
+		req.Header.Set("X-Identity-Assertion", "forged")
+
+		w := httptest.NewRecorder()
+		proxy.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, received.Get("X-Identity-Assertion"))
+	})
+}
+
+func TestProxy_DeviceCredential(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+
+	var received http.Header
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Clone()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	backendURL, err := url.Parse(backend.URL)
+	require.NoError(t, err)
+	port, err := strconv.Atoi(backendURL.Port())
+	require.NoError(t, err)
+
+	proxy, err := New(&Config{
+		TargetHost:     backendURL.Hostname(),
+		TargetPort:     port,
+		TargetScheme:   backendURL.Scheme,
+		Retry:          RetryConfig{MaxAttempts: 1},
+		CircuitBreaker: CircuitBreakerConfig{Threshold: 3, Timeout: time.Second},
+	}, logger)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name          string
+		authorization string
+		forwarded     string
+	}{
+		{"Device credential is stripped", "Bearer " + oidc.DeviceTokenPrefix + "secret", ""},
+		{"Device credential in any case is stripped", "bearer " + oidc.DeviceTokenPrefix + "secret", ""},
+		{"Other bearer tokens are forwarded", "Bearer access-token", "Bearer access-token"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/mcp", nil)
+			req.Header.Set("Authorization", tt.authorization)
+
+			w := httptest.NewRecorder()
+			proxy.ServeHTTP(w, req)
+			require.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, tt.forwarded, received.Get("Authorization"))
+		})
+	}
+}
+
+func TestProxy_TokenExchange(t *testing.T) {
+	logger := zaptest.NewLogger(t)
+
+	tokenEndpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		if r.PostForm.Get("subject_token") == "expired-token" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"invalid_grant"}`))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token":"downstream-%s","token_type":"Bearer","expires_in":300}`, r.PostForm.Get("audience"))
+	}))
+	defer tokenEndpoint.Close()
+
+	var received http.Header
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Clone()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer backend.Close()
+
+	backendURL, err := url.Parse(backend.URL)
+	require.NoError(t, err)
+	port, err := strconv.Atoi(backendURL.Port())
+	require.NoError(t, err)
+
+	exchanger, err := tokenexchange.NewExchanger(&config.TokenExchangeConfig{
+		Timeout: 5 * time.Second,
+		Routes:  []config.TokenExchangeRoute{{PathPrefix: "/api", Audience: "internal-api"}},
+	}, tokenEndpoint.URL, "proxy-client", "proxy-secret")
+	require.NoError(t, err)
+
+	proxy, err := New(&Config{
+		TargetHost:     backendURL.Hostname(),
+		TargetPort:     port,
+		TargetScheme:   backendURL.Scheme,
+		Retry:          RetryConfig{MaxAttempts: 1},
+		CircuitBreaker: CircuitBreakerConfig{Threshold: 3, Timeout: time.Second},
+		TokenExchange:  exchanger,
+	}, logger)
+	require.NoError(t, err)
+
+	request := func(path, accessToken string) *httptest.ResponseRecorder {
+		received = nil
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+		if accessToken != "" {
+			req = req.WithContext(context.WithValue(req.Context(), oidc.SessionContextKey{}, &oidc.UserSession{
+				ID:          "user123",
+				AccessToken: accessToken,
+			}))
+		}
+		w := httptest.NewRecorder()
+		proxy.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Matching route", func(t *testing.T) {
+		w := request("/api/users", "user-token")
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "Bearer downstream-internal-api", received.Get("Authorization"))
+	})
+
+	t.Run("Non-matching route", func(t *testing.T) {
+		w := request("/mcp", "user-token")
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "Bearer user-token", received.Get("Authorization"))
+	})
+
+	t.Run("Rejected subject token", func(t *testing.T) {
+		w := request("/api/users", "expired-token")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Nil(t, received, "request must not reach the upstream")
+	})
+
+	t.Run("No session", func(t *testing.T) {
+		w := request("/api/users", "")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Nil(t, received)
+	})
+}
@@ -2,12 +2,17 @@ package proxy
 
 import (
 	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/auth/oidc"
 	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/metrics"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/codes"
@@ -16,6 +21,10 @@ import (
 	"go.uber.org/zap"
 )
 
+// SessionExpiredEvent is the SSE event type sent before a stream is closed
+// because the session reached its absolute lifetime
+const SessionExpiredEvent = "session_expired"
+
 // isStreamingRequest detects if the request is for SSE or WebSocket
 func isStreamingRequest(r *http.Request) bool {
 	// Check for SSE
@@ -97,8 +106,16 @@ func (p *Proxy) streamingProxy(w http.ResponseWriter, r *http.Request) int {
 		Timeout: 0,
 	}
 	
+	// Streams end when the session reaches its absolute lifetime
+	ctx := r.Context()
+	if sess := oidc.GetSessionFromContext(ctx); sess != nil && !sess.AbsoluteExpiresAt.IsZero() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, sess.AbsoluteExpiresAt)
+		defer cancel()
+	}
+
 	// Create proxy request
-	proxyReq, err := http.NewRequest(r.Method, targetURL.String(), r.Body)
+	proxyReq, err := http.NewRequestWithContext(ctx, r.Method, targetURL.String(), r.Body)
 	if err != nil {
 		p.logger.Error("Failed to create proxy request",
 			zap.Error(err),
@@ -145,7 +162,7 @@ func (p *Proxy) streamingProxy(w http.ResponseWriter, r *http.Request) int {
 	
 	// Handle SSE streaming
 	if strings.Contains(resp.Header.Get("Content-Type"), "text/event-stream") {
-		p.handleSSEStream(w, resp.Body)
+		p.handleSSEStream(ctx, w, resp.Body)
 		return resp.StatusCode
 	}
 	
@@ -155,7 +172,7 @@ func (p *Proxy) streamingProxy(w http.ResponseWriter, r *http.Request) int {
 }
 
 // handleSSEStream handles Server-Sent Events streaming
-func (p *Proxy) handleSSEStream(w http.ResponseWriter, body io.Reader) {
+func (p *Proxy) handleSSEStream(ctx context.Context, w http.ResponseWriter, body io.Reader) {
 	flusher, ok := w.(http.Flusher)
 	if !ok {
 		p.logger.Error("ResponseWriter does not support flushing")
@@ -166,7 +183,9 @@ func (p *Proxy) handleSSEStream(w http.ResponseWriter, body io.Reader) {
 	for {
 		line, err := reader.ReadBytes('\n')
 		if err != nil {
-			if err != io.EOF {
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				p.writeSessionExpired(w, flusher)
+			} else if err != io.EOF {
 				p.logger.Error("Error reading SSE stream", zap.Error(err))
 			}
 			break
@@ -183,6 +202,22 @@ func (p *Proxy) handleSSEStream(w http.ResponseWriter, body io.Reader) {
 	}
 }
 
+// writeSessionExpired asks the SSE client to authenticate again before the
+// stream is closed
+func (p *Proxy) writeSessionExpired(w http.ResponseWriter, flusher http.Flusher) {
+	data, _ := json.Marshal(map[string]string{
+		"error":     "Session expired",
+		"login_url": p.loginURL,
+	})
+
+	p.logger.Debug("Closing SSE stream at session absolute lifetime")
+	if _, err := fmt.Fprintf(w, "\nevent: %s\ndata: %s\n\n", SessionExpiredEvent, data); err != nil {
+		p.logger.Debug("Failed to send session expiry event", zap.Error(err))
+		return
+	}
+	flusher.Flush()
+}
+
 // copyHeaders copies headers from source to destination
 func copyHeaders(dst, src http.Header) {
 	for k, vv := range src {
@@ -2,6 +2,7 @@ package proxy
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -11,6 +12,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/auth/oidc"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
@@ -319,4 +321,60 @@ func TestWebSocketProxying(t *testing.T) {
 func TestStreamingMetrics(t *testing.T) {
 	// TODO: Add metrics verification once metrics are properly mocked
 	t.Skip("Metrics testing requires proper mocking")
-}
\ No newline at end of file
+}
+func TestSSEStreamingSessionExpiry(t *testing.T) {
+	// Stream events until the client goes away
+	sseServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		flusher := w.(http.Flusher)
+		for i := 0; ; i++ {
+			if _, err := fmt.Fprintf(w, "data: Event %d\n\n", i); err != nil {
+				return
+			}
+			flusher.Flush()
+			select {
+			case <-r.Context().Done():
+				return
+			case <-time.After(20 * time.Millisecond):
+			}
+		}
+	}))
+	defer sseServer.Close()
+
+	serverURL, err := url.Parse(sseServer.URL)
+	require.NoError(t, err)
+	port, _ := strconv.Atoi(serverURL.Port())
+
+	proxy, err := New(&Config{
+		TargetHost:     serverURL.Hostname(),
+		TargetPort:     port,
+		TargetScheme:   serverURL.Scheme,
+		Retry:          RetryConfig{MaxAttempts: 1},
+		CircuitBreaker: CircuitBreakerConfig{Threshold: 3, Timeout: time.Second},
+		LoginURL:       "/login?reauth=1",
+	}, zap.NewNop())
+	require.NoError(t, err)
+
+	sess := &oidc.UserSession{ID: "alice", AbsoluteExpiresAt: time.Now().Add(100 * time.Millisecond)}
+	req := httptest.NewRequest("GET", "/events", nil)
+	req.Header.Set("Accept", "text/event-stream")
+	req = req.WithContext(context.WithValue(req.Context(), oidc.SessionContextKey{}, sess))
+	recorder := httptest.NewRecorder()
+
+	done := make(chan struct{})
+	go func() {
+		proxy.ServeHTTP(recorder, req)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream was not closed at the session absolute lifetime")
+	}
+
+	body := recorder.Body.String()
+	assert.Contains(t, body, "data: Event 0")
+	assert.True(t, strings.HasSuffix(body,
+		"event: session_expired\ndata: {\"error\":\"Session expired\",\"login_url\":\"/login?reauth=1\"}\n\n"), body)
+}
@@ -0,0 +1,417 @@
+package session
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/metrics"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/session/meta"
+	"go.uber.org/zap"
+)
+
+// CacheInvalidator propagates cache invalidations between replicas
+type CacheInvalidator interface {
+	// Publish announces to every replica that a session changed
+	Publish(ctx context.Context, key string) error
+
+	// Subscribe calls evict with every key announced by any replica, and
+	// reset whenever announcements may have been missed, until Close
+	Subscribe(ctx context.Context, evict func(key string), reset func()) error
+
+	// Close stops the subscription
+	Close() error
+}
+
+// CacheConfig holds session cache configuration
+type CacheConfig struct {
+	// Maximum number of cached sessions
+	Size int
+	// How long a session is served from the cache before it is read again;
+	// bounds staleness when an invalidation is lost
+	TTL time.Duration
+}
+
+// CachingStore wraps a Store and serves recently read sessions from a
+// bounded in-process LRU cache. Writes through the wrapper evict the key
+// locally and, with an invalidator, on every other replica.
+type CachingStore struct {
+	store       Store
+	cache       *lruCache
+	touches     *lruCache // keys touched within meta.TouchInterval
+	invalidator CacheInvalidator
+	storeType   string
+	logger      *zap.Logger
+}
+
+// NewCachingStore creates a new caching store wrapper. The invalidator may
+// be nil when the store is not shared between replicas.
+func NewCachingStore(store Store, config *CacheConfig, invalidator CacheInvalidator, storeType string, logger *zap.Logger) (*CachingStore, error) {
+	if config.Size <= 0 || config.TTL <= 0 {
+		return nil, fmt.Errorf("session cache size and TTL must be positive")
+	}
+
+	s := &CachingStore{
+		store:       store,
+		cache:       newLRUCache(config.Size, config.TTL),
+		touches:     newLRUCache(config.Size, meta.TouchInterval),
+		invalidator: invalidator,
+		storeType:   storeType,
+		logger:      logger,
+	}
+
+	if invalidator != nil {
+		err := invalidator.Subscribe(context.Background(), func(key string) {
+			s.cache.remove(key)
+			metrics.SessionCacheInvalidationsTotal.WithLabelValues(storeType, "remote").Inc()
+		}, s.cache.reset)
+		if err != nil {
+			return nil, fmt.Errorf("failed to subscribe to session invalidations: %w", err)
+		}
+	}
+
+	return s, nil
+}
+
+// Get serves session data from the cache or reads it from the wrapped store
+func (s *CachingStore) Get(ctx context.Context, key string, data interface{}) error {
+	if value, ok := s.cache.get(key); ok {
+		metrics.SessionCacheRequestsTotal.WithLabelValues(s.storeType, "hit").Inc()
+		if err := json.Unmarshal(value, data); err != nil {
+			return fmt.Errorf("failed to unmarshal session data: %w", err)
+		}
+		return nil
+	}
+	metrics.SessionCacheRequestsTotal.WithLabelValues(s.storeType, "miss").Inc()
+
+	// An invalidation that arrives while the store is read must win over
+	// the value read
+	generation := s.cache.generation()
+
+	var raw json.RawMessage
+	if err := s.store.Get(ctx, key, &raw); err != nil {
+		return err
+	}
+	s.cache.add(key, raw, generation)
+
+	if err := json.Unmarshal(raw, data); err != nil {
+		return fmt.Errorf("failed to unmarshal session data: %w", err)
+	}
+	return nil
+}
+
+// GetRaw serves the stored bytes of a session from the cache or reads them
+// from the wrapped store, if it supports raw values
+func (s *CachingStore) GetRaw(ctx context.Context, key string) ([]byte, error) {
+	raw, ok := s.store.(RawStore)
+	if !ok {
+		return nil, ErrRawNotSupported
+	}
+	if value, ok := s.cache.get(key); ok {
+		metrics.SessionCacheRequestsTotal.WithLabelValues(s.storeType, "hit").Inc()
+		return append([]byte(nil), value...), nil
+	}
+	metrics.SessionCacheRequestsTotal.WithLabelValues(s.storeType, "miss").Inc()
+
+	generation := s.cache.generation()
+	value, err := raw.GetRaw(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	s.cache.add(key, append([]byte(nil), value...), generation)
+	return value, nil
+}
+
+// invalidate evicts a session from this replica and announces the change
+// to the others
+func (s *CachingStore) invalidate(ctx context.Context, keys ...string) {
+	for _, key := range keys {
+		s.cache.remove(key)
+		metrics.SessionCacheInvalidationsTotal.WithLabelValues(s.storeType, "local").Inc()
+
+		if s.invalidator == nil {
+			continue
+		}
+		if err := s.invalidator.Publish(ctx, key); err != nil {
+			s.logger.Warn("Failed to publish session invalidation", zap.Error(err), zap.String("key", key))
+		}
+	}
+}
+
+// Create creates a session; a session previously stored under the same
+// key is evicted everywhere
+func (s *CachingStore) Create(ctx context.Context, key string, data interface{}, ttl time.Duration) (string, error) {
+	id, err := s.store.Create(ctx, key, data, ttl)
+	if err == nil {
+		s.invalidate(ctx, key)
+	}
+	return id, err
+}
+
+// Update updates a session and evicts it everywhere
+func (s *CachingStore) Update(ctx context.Context, key string, data interface{}) error {
+	err := s.store.Update(ctx, key, data)
+	s.invalidate(ctx, key)
+	return err
+}
+
+// CreateRaw creates a session holding raw bytes, if the wrapped store
+// supports them; a session previously stored under the same key is evicted
+// everywhere
+func (s *CachingStore) CreateRaw(ctx context.Context, key string, value []byte, ttl time.Duration) (string, error) {
+	raw, ok := s.store.(RawStore)
+	if !ok {
+		return "", ErrRawNotSupported
+	}
+	id, err := raw.CreateRaw(ctx, key, value, ttl)
+	if err == nil {
+		s.invalidate(ctx, key)
+	}
+	return id, err
+}
+
+// UpdateRaw updates a session with raw bytes, if the wrapped store supports
+// them, and evicts it everywhere
+func (s *CachingStore) UpdateRaw(ctx context.Context, key string, value []byte) error {
+	raw, ok := s.store.(RawStore)
+	if !ok {
+		return ErrRawNotSupported
+	}
+	err := raw.UpdateRaw(ctx, key, value)
+	s.invalidate(ctx, key)
+	return err
+}
+
+// Delete deletes a session and evicts it everywhere
+func (s *CachingStore) Delete(ctx context.Context, key string) error {
+	err := s.store.Delete(ctx, key)
+	s.invalidate(ctx, key)
+	return err
+}
+
+// DeleteUserSessions deletes a user's sessions and evicts them everywhere
+func (s *CachingStore) DeleteUserSessions(ctx context.Context, userID string) (int, error) {
+	infos, err := s.store.ListUserSessions(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	deleted, err := s.store.DeleteUserSessions(ctx, userID)
+
+	keys := make([]string, len(infos))
+	for i, info := range infos {
+		keys[i] = info.Key
+	}
+	s.invalidate(ctx, keys...)
+	return deleted, err
+}
+
+// Exists checks if a session exists
+func (s *CachingStore) Exists(ctx context.Context, key string) (bool, error) {
+	return s.store.Exists(ctx, key)
+}
+
+// Refresh extends the TTL of a session
+func (s *CachingStore) Refresh(ctx context.Context, key string, ttl time.Duration) error {
+	return s.store.Refresh(ctx, key, ttl)
+}
+
+// SetMetadata attaches metadata to a session
+func (s *CachingStore) SetMetadata(ctx context.Context, key string, metadata *Metadata) error {
+	return s.store.SetMetadata(ctx, key, metadata)
+}
+
+// Touch records activity on a session. A session this replica touched within
+// meta.TouchInterval is not touched again, so cached sessions cost no store
+// round-trip per request.
+func (s *CachingStore) Touch(ctx context.Context, key string, seen time.Time) error {
+	if _, ok := s.touches.get(key); ok {
+		return nil
+	}
+
+	generation := s.touches.generation()
+	if err := s.store.Touch(ctx, key, seen); err != nil {
+		return err
+	}
+	s.touches.add(key, nil, generation)
+	return nil
+}
+
+// ListUserSessions returns the live sessions of a user
+func (s *CachingStore) ListUserSessions(ctx context.Context, userID string) ([]*SessionInfo, error) {
+	return s.store.ListUserSessions(ctx, userID)
+}
+
+// ListSessions returns one page of sessions with metadata
+func (s *CachingStore) ListSessions(ctx context.Context, cursor string, limit int) (*SessionPage, error) {
+	return s.store.ListSessions(ctx, cursor, limit)
+}
+
+// AddIndex adds a session to an index if the wrapped store supports indexes
+func (s *CachingStore) AddIndex(ctx context.Context, index, key string) error {
+	indexer, ok := s.store.(Indexer)
+	if !ok {
+		return ErrIndexNotSupported
+	}
+	return indexer.AddIndex(ctx, index, key)
+}
+
+// RemoveIndex removes a session from an index if the wrapped store supports indexes
+func (s *CachingStore) RemoveIndex(ctx context.Context, index, key string) error {
+	indexer, ok := s.store.(Indexer)
+	if !ok {
+		return ErrIndexNotSupported
+	}
+	return indexer.RemoveIndex(ctx, index, key)
+}
+
+// IndexedKeys lists the sessions in an index if the wrapped store supports indexes
+func (s *CachingStore) IndexedKeys(ctx context.Context, index string) ([]string, error) {
+	indexer, ok := s.store.(Indexer)
+	if !ok {
+		return nil, ErrIndexNotSupported
+	}
+	return indexer.IndexedKeys(ctx, index)
+}
+
+// RevokeIndex revokes the sessions in an index if the wrapped store supports
+// it. The cache does not know which keys the index holds, so it is emptied.
+func (s *CachingStore) RevokeIndex(ctx context.Context, index string) error {
+	revoker, ok := s.store.(IndexRevoker)
+	if !ok {
+		return ErrIndexNotSupported
+	}
+	err := revoker.RevokeIndex(ctx, index)
+	s.cache.reset()
+	return err
+}
+
+// Cleanup removes expired sessions
+func (s *CachingStore) Cleanup(ctx context.Context) error {
+	return s.store.Cleanup(ctx)
+}
+
+// Stats returns the statistics of the wrapped store
+func (s *CachingStore) Stats(ctx context.Context) (*Stats, error) {
+	return s.store.Stats(ctx)
+}
+
+// Close stops invalidations and closes the wrapped store
+func (s *CachingStore) Close() error {
+	if s.invalidator != nil {
+		if err := s.invalidator.Close(); err != nil {
+			s.logger.Debug("Failed to close session invalidator", zap.Error(err))
+		}
+	}
+	return s.store.Close()
+}
+
+// lruCache is a bounded, least recently used cache of session values
+type lruCache struct {
+	mu      sync.Mutex
+	size    int
+	ttl     time.Duration
+	entries map[string]*list.Element
+	order   *list.List // front is most recently used
+	gen     uint64     // incremented by every removal and reset
+	now     func() time.Time
+}
+
+type lruEntry struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+func newLRUCache(size int, ttl time.Duration) *lruCache {
+	return &lruCache{
+		size:    size,
+		ttl:     ttl,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+		now:     time.Now,
+	}
+}
+
+// get returns a live cached value and marks it recently used
+func (c *lruCache) get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*lruEntry)
+	if !c.now().Before(entry.expires) {
+		c.order.Remove(elem)
+		delete(c.entries, key)
+		return nil, false
+	}
+	c.order.MoveToFront(elem)
+	return entry.value, true
+}
+
+// generation returns the current invalidation generation
+func (c *lruCache) generation() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.gen
+}
+
+// add caches a value read at generation gen, unless something was
+// invalidated since, evicting the least recently used entry when full
+func (c *lruCache) add(key string, value []byte, gen uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if gen != c.gen {
+		return
+	}
+
+	entry := &lruEntry{key: key, value: value, expires: c.now().Add(c.ttl)}
+	if elem, ok := c.entries[key]; ok {
+		elem.Value = entry
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(entry)
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*lruEntry).key)
+	}
+}
+
+// remove evicts a key
+func (c *lruCache) remove(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.gen++
+	if elem, ok := c.entries[key]; ok {
+		c.order.Remove(elem)
+		delete(c.entries, key)
+	}
+}
+
+// reset evicts every key
+func (c *lruCache) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.gen++
+	c.entries = make(map[string]*list.Element)
+	c.order.Init()
+}
+
+// len returns the number of cached entries
+func (c *lruCache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
@@ -0,0 +1,208 @@
+package session
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/session/memory"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/session/redis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// newCachedReplica returns a cached Redis store sharing mr with the other
+// replicas, as a separate proxy instance would
+func newCachedReplica(t *testing.T, mr *miniredis.Miniredis, ttl time.Duration) *CachingStore {
+	client := goredis.NewClient(&goredis.Options{Addr: mr.Addr()})
+	backend := redis.NewStoreWithClient(client, "test:", zap.NewNop())
+
+	invalidator, err := redis.NewInvalidator(backend, "", zap.NewNop())
+	require.NoError(t, err)
+
+	store, err := NewCachingStore(backend, &CacheConfig{Size: 100, TTL: ttl}, invalidator, "redis", zap.NewNop())
+	require.NoError(t, err)
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+// assertRevoked waits for a session to become unreadable on a replica
+func assertRevoked(t *testing.T, store Store, key string) {
+	t.Helper()
+	assert.Eventually(t, func() bool {
+		var data tokenSession
+		return store.Get(context.Background(), key, &data) != nil
+	}, 2*time.Second, 10*time.Millisecond, "session %s still served", key)
+}
+
+func TestCachingStoreServesFromCache(t *testing.T) {
+	mr := miniredis.RunT(t)
+	store := newCachedReplica(t, mr, time.Minute)
+	ctx := context.Background()
+
+	_, err := store.Create(ctx, "user:alice", &tokenSession{UserID: "alice", AccessToken: "a"}, time.Hour)
+	require.NoError(t, err)
+
+	var got tokenSession
+	require.NoError(t, store.Get(ctx, "user:alice", &got))
+	assert.Equal(t, 1, store.cache.len())
+
+	// Changes made behind the wrapper's back are not seen while cached
+	mr.Set("test:user:alice", `{"user_id":"alice","access_token":"changed"}`)
+	require.NoError(t, store.Get(ctx, "user:alice", &got))
+	assert.Equal(t, "a", got.AccessToken)
+
+	// Writes through the wrapper are seen immediately
+	require.NoError(t, store.Update(ctx, "user:alice", &tokenSession{UserID: "alice", AccessToken: "b"}))
+	require.NoError(t, store.Get(ctx, "user:alice", &got))
+	assert.Equal(t, "b", got.AccessToken)
+
+	require.NoError(t, store.Delete(ctx, "user:alice"))
+	assert.Error(t, store.Get(ctx, "user:alice", &got))
+}
+
+func TestCachingStoreThrottlesTouch(t *testing.T) {
+	mr := miniredis.RunT(t)
+	store := newCachedReplica(t, mr, time.Minute)
+	ctx := context.Background()
+
+	_, err := store.Create(ctx, "user:alice", &tokenSession{UserID: "alice"}, time.Hour)
+	require.NoError(t, err)
+	require.NoError(t, store.SetMetadata(ctx, "user:alice", &Metadata{UserID: "alice"}))
+
+	seen := time.Now().Add(time.Minute).Truncate(time.Millisecond)
+	require.NoError(t, store.Touch(ctx, "user:alice", seen))
+	require.NoError(t, store.Touch(ctx, "user:alice", seen.Add(time.Second)))
+
+	// Only the first touch reached Redis
+	infos, err := store.ListUserSessions(ctx, "alice")
+	require.NoError(t, err)
+	require.Len(t, infos, 1)
+	assert.True(t, seen.Equal(infos[0].LastSeen))
+}
+
+func TestCachingStoreRevocationPropagation(t *testing.T) {
+	mr := miniredis.RunT(t)
+	replicaA := newCachedReplica(t, mr, time.Hour)
+	replicaB := newCachedReplica(t, mr, time.Hour)
+	ctx := context.Background()
+
+	for _, key := range []string{"user:alice", "user:bob"} {
+		_, err := replicaA.Create(ctx, key, &tokenSession{UserID: key, AccessToken: "a"}, time.Hour)
+		require.NoError(t, err)
+	}
+	require.NoError(t, replicaA.SetMetadata(ctx, "user:bob", &Metadata{UserID: "bob"}))
+
+	// Both replicas have the sessions cached
+	for _, replica := range []*CachingStore{replicaA, replicaB} {
+		for _, key := range []string{"user:alice", "user:bob"} {
+			var got tokenSession
+			require.NoError(t, replica.Get(ctx, key, &got))
+		}
+	}
+
+	t.Run("update", func(t *testing.T) {
+		require.NoError(t, replicaB.Update(ctx, "user:alice", &tokenSession{UserID: "alice", AccessToken: "refreshed"}))
+		assert.Eventually(t, func() bool {
+			var got tokenSession
+			return replicaA.Get(ctx, "user:alice", &got) == nil && got.AccessToken == "refreshed"
+		}, 2*time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		require.NoError(t, replicaB.Delete(ctx, "user:alice"))
+		assertRevoked(t, replicaA, "user:alice")
+	})
+
+	t.Run("delete user sessions", func(t *testing.T) {
+		deleted, err := replicaA.DeleteUserSessions(ctx, "bob")
+		require.NoError(t, err)
+		assert.Equal(t, 1, deleted)
+		assertRevoked(t, replicaB, "user:bob")
+	})
+
+	t.Run("recreated key", func(t *testing.T) {
+		_, err := replicaA.Create(ctx, "user:carol", &tokenSession{AccessToken: "first"}, time.Hour)
+		require.NoError(t, err)
+		var got tokenSession
+		require.NoError(t, replicaB.Get(ctx, "user:carol", &got))
+
+		require.NoError(t, replicaA.Delete(ctx, "user:carol"))
+		_, err = replicaA.Create(ctx, "user:carol", &tokenSession{AccessToken: "second"}, time.Hour)
+		require.NoError(t, err)
+		assert.Eventually(t, func() bool {
+			return replicaB.Get(ctx, "user:carol", &got) == nil && got.AccessToken == "second"
+		}, 2*time.Second, 10*time.Millisecond)
+	})
+}
+
+func TestCachingStoreTTL(t *testing.T) {
+	inner := memory.NewStore(&memory.Config{}, zap.NewNop())
+	store, err := NewCachingStore(inner, &CacheConfig{Size: 10, TTL: time.Minute}, nil, "memory", zap.NewNop())
+	require.NoError(t, err)
+	defer store.Close()
+	ctx := context.Background()
+
+	now := time.Now()
+	store.cache.now = func() time.Time { return now }
+
+	_, err = store.Create(ctx, "session1", &tokenSession{AccessToken: "a"}, time.Hour)
+	require.NoError(t, err)
+	var got tokenSession
+	require.NoError(t, store.Get(ctx, "session1", &got))
+
+	// A change the cache was not told about is served until the TTL passes
+	require.NoError(t, inner.Update(ctx, "session1", &tokenSession{AccessToken: "b"}))
+	require.NoError(t, store.Get(ctx, "session1", &got))
+	assert.Equal(t, "a", got.AccessToken)
+
+	now = now.Add(time.Minute)
+	require.NoError(t, store.Get(ctx, "session1", &got))
+	assert.Equal(t, "b", got.AccessToken)
+}
+
+func TestLRUCache(t *testing.T) {
+	t.Run("evicts least recently used", func(t *testing.T) {
+		cache := newLRUCache(2, time.Minute)
+		cache.add("a", []byte("1"), cache.generation())
+		cache.add("b", []byte("2"), cache.generation())
+		_, ok := cache.get("a")
+		require.True(t, ok)
+
+		cache.add("c", []byte("3"), cache.generation())
+		assert.Equal(t, 2, cache.len())
+		_, ok = cache.get("b")
+		assert.False(t, ok)
+		_, ok = cache.get("a")
+		assert.True(t, ok)
+	})
+
+	t.Run("drops values read before an invalidation", func(t *testing.T) {
+		cache := newLRUCache(2, time.Minute)
+		generation := cache.generation()
+		cache.remove("a")
+		cache.add("a", []byte("stale"), generation)
+		_, ok := cache.get("a")
+		assert.False(t, ok)
+	})
+
+	t.Run("reset", func(t *testing.T) {
+		cache := newLRUCache(2, time.Minute)
+		cache.add("a", []byte("1"), cache.generation())
+		cache.reset()
+		assert.Equal(t, 0, cache.len())
+	})
+}
+
+func TestNewCachingStoreInvalidConfig(t *testing.T) {
+	inner := memory.NewStore(&memory.Config{}, zap.NewNop())
+	defer inner.Close()
+
+	_, err := NewCachingStore(inner, &CacheConfig{Size: 0, TTL: time.Second}, nil, "memory", zap.NewNop())
+	assert.Error(t, err)
+	_, err = NewCachingStore(inner, &CacheConfig{Size: 10}, nil, "memory", zap.NewNop())
+	assert.Error(t, err)
+}
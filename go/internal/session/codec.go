@@ -0,0 +1,95 @@
+package session
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Codec encodes session data for a TypedStore
+type Codec interface {
+	// Name identifies the codec in stored values
+	Name() string
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+func init() {
+	// Claims are decoded into generic maps and slices, which gob only
+	// encodes inside interface values once registered
+	gob.Register(map[string]interface{}{})
+	gob.Register([]interface{}{})
+}
+
+// JSONCodec encodes session data as JSON, the native format of every store
+type JSONCodec struct{}
+
+// Name returns "json"
+func (JSONCodec) Name() string { return "json" }
+
+// Marshal encodes v as JSON
+func (JSONCodec) Marshal(v interface{}) ([]byte, error) { return json.Marshal(v) }
+
+// Unmarshal decodes JSON into v
+func (JSONCodec) Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+
+// MsgpackCodec encodes session data as MessagePack, honouring json tags
+type MsgpackCodec struct{}
+
+// Name returns "msgpack"
+func (MsgpackCodec) Name() string { return "msgpack" }
+
+// Marshal encodes v as MessagePack
+func (MsgpackCodec) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag("json")
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Unmarshal decodes MessagePack into v
+func (MsgpackCodec) Unmarshal(data []byte, v interface{}) error {
+	dec := msgpack.NewDecoder(bytes.NewReader(data))
+	dec.SetCustomStructTag("json")
+	return dec.Decode(v)
+}
+
+// GobCodec encodes session data with encoding/gob
+type GobCodec struct{}
+
+// Name returns "gob"
+func (GobCodec) Name() string { return "gob" }
+
+// Marshal encodes v with gob
+func (GobCodec) Marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Unmarshal decodes gob data into v
+func (GobCodec) Unmarshal(data []byte, v interface{}) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// CodecByName returns the codec with the given name; an empty name selects JSON
+func CodecByName(name string) (Codec, error) {
+	switch name {
+	case "", "json":
+		return JSONCodec{}, nil
+	case "msgpack":
+		return MsgpackCodec{}, nil
+	case "gob":
+		return GobCodec{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported session codec: %s (supported: json, msgpack, gob)", name)
+	}
+}
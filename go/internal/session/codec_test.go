@@ -0,0 +1,63 @@
+package session
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// claimsSession mirrors the shape of an OIDC user session
+type claimsSession struct {
+	ID        string                 `json:"id"`
+	Groups    []string               `json:"groups,omitempty"`
+	ExpiresAt time.Time              `json:"expires_at"`
+	Claims    map[string]interface{} `json:"claims"`
+}
+
+func newClaimsSession() *claimsSession {
+	return &claimsSession{
+		ID:        "user123",
+		Groups:    []string{"admins", "users"},
+		ExpiresAt: time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC),
+		Claims: map[string]interface{}{
+			"email":          "user@example.com",
+			"email_verified": true,
+			"exp":            float64(1893553445),
+			"amr":            []interface{}{"pwd", "mfa"},
+			"address":        map[string]interface{}{"country": "JP"},
+			"middle_name":    nil,
+		},
+	}
+}
+
+func TestCodecRoundTrip(t *testing.T) {
+	for _, name := range []string{"json", "msgpack", "gob"} {
+		t.Run(name, func(t *testing.T) {
+			codec, err := CodecByName(name)
+			require.NoError(t, err)
+			assert.Equal(t, name, codec.Name())
+
+			want := newClaimsSession()
+			encoded, err := codec.Marshal(want)
+			require.NoError(t, err)
+
+			var got claimsSession
+			require.NoError(t, codec.Unmarshal(encoded, &got))
+			assert.Equal(t, want.ID, got.ID)
+			assert.Equal(t, want.Groups, got.Groups)
+			assert.True(t, want.ExpiresAt.Equal(got.ExpiresAt))
+			assert.Equal(t, want.Claims, got.Claims)
+		})
+	}
+}
+
+func TestCodecByName(t *testing.T) {
+	codec, err := CodecByName("")
+	require.NoError(t, err)
+	assert.Equal(t, "json", codec.Name())
+
+	_, err = CodecByName("protobuf")
+	assert.Error(t, err)
+}
@@ -0,0 +1,215 @@
+// Package cookie reads and writes the session cookie with the configured
+// attributes, optional __Host- prefix and HMAC signature.
+package cookie
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/config"
+)
+
+// DefaultName is the cookie name used when the configuration leaves it empty
+const DefaultName = "session_id"
+
+// HostPrefix restricts a cookie to the exact host, over HTTPS, for all paths
+const HostPrefix = "__Host-"
+
+// MinKeyLength is the minimum HMAC signing key length in bytes
+const MinKeyLength = 32
+
+// Cookie read errors
+var (
+	ErrNoCookie         = errors.New("session cookie missing")
+	ErrInvalidSignature = errors.New("session cookie signature invalid")
+)
+
+// Manager applies the configured session cookie attributes. When signing keys
+// are set the session ID is sent as <id>.<base64url HMAC-SHA256>; the first
+// key signs and every key verifies, so keys can be rotated by prepending a
+// new one.
+type Manager struct {
+	name        string
+	legacyNames []string
+	domain      string
+	path        string
+	secure      bool
+	httpOnly    bool
+	sameSite    http.SameSite
+	keys        [][]byte
+}
+
+// NewManager creates a cookie manager from the session configuration
+func NewManager(cfg *config.SessionConfig) (*Manager, error) {
+	if cfg == nil {
+		return DefaultManager(), nil
+	}
+
+	m := &Manager{
+		name:        cfg.CookieName,
+		legacyNames: cfg.CookieLegacyNames,
+		domain:      cfg.CookieDomain,
+		path:        cfg.CookiePath,
+		secure:      cfg.CookieSecure,
+		httpOnly:    cfg.CookieHTTPOnly,
+	}
+	// Without a configured name fall back to the historical HttpOnly cookie
+	if m.name == "" {
+		m.name = DefaultName
+		m.httpOnly = true
+	}
+	if m.path == "" {
+		m.path = "/"
+	}
+
+	switch strings.ToLower(cfg.CookieSameSite) {
+	case "", "lax":
+		m.sameSite = http.SameSiteLaxMode
+	case "strict":
+		m.sameSite = http.SameSiteStrictMode
+	case "none":
+		m.sameSite = http.SameSiteNoneMode
+	default:
+		return nil, fmt.Errorf("invalid cookie same site: %s", cfg.CookieSameSite)
+	}
+
+	if cfg.CookieHostPrefix {
+		if !m.secure || m.path != "/" || m.domain != "" {
+			return nil, fmt.Errorf("%s cookies must be secure, have path \"/\" and no domain", HostPrefix)
+		}
+		if !strings.HasPrefix(m.name, HostPrefix) {
+			m.name = HostPrefix + m.name
+		}
+	}
+
+	if cfg.CookieSigning.Enabled {
+		if len(cfg.CookieSigning.Keys) == 0 {
+			return nil, fmt.Errorf("at least one cookie signing key is required")
+		}
+		for _, key := range cfg.CookieSigning.Keys {
+			if len(key) < MinKeyLength {
+				return nil, fmt.Errorf("cookie signing keys must be at least %d bytes", MinKeyLength)
+			}
+			m.keys = append(m.keys, []byte(key))
+		}
+	}
+
+	return m, nil
+}
+
+// DefaultManager returns a manager for an unsigned, HttpOnly session_id cookie
+func DefaultManager() *Manager {
+	return &Manager{
+		name:     DefaultName,
+		path:     "/",
+		httpOnly: true,
+		sameSite: http.SameSiteLaxMode,
+	}
+}
+
+// Name returns the cookie name, including any __Host- prefix
+func (m *Manager) Name() string {
+	return m.name
+}
+
+// Read returns the session ID carried by the request. legacy reports that it
+// was found under a legacy name and should be re-issued with Set. Cookies
+// with a missing or wrong signature are rejected.
+func (m *Manager) Read(r *http.Request) (sessionID string, legacy bool, err error) {
+	if c, err := r.Cookie(m.name); err == nil && c.Value != "" {
+		sessionID, err := m.verify(c.Value)
+		return sessionID, false, err
+	}
+
+	for _, name := range m.legacyNames {
+		if c, err := r.Cookie(name); err == nil && c.Value != "" {
+			sessionID, err := m.verify(c.Value)
+			return sessionID, true, err
+		}
+	}
+
+	return "", false, ErrNoCookie
+}
+
+// Set writes the session cookie and expires any legacy cookies sent with the
+// request. A zero maxAge sets a browser session cookie.
+func (m *Manager) Set(w http.ResponseWriter, r *http.Request, sessionID string, maxAge int) {
+	cookie := m.cookie(m.name, m.sign(sessionID))
+	cookie.MaxAge = maxAge
+	http.SetCookie(w, cookie)
+	m.clearLegacy(w, r)
+}
+
+// Clear expires the session cookie and any legacy cookies
+func (m *Manager) Clear(w http.ResponseWriter, r *http.Request) {
+	cookie := m.cookie(m.name, "")
+	cookie.MaxAge = -1
+	http.SetCookie(w, cookie)
+	m.clearLegacy(w, r)
+}
+
+// clearLegacy expires the legacy cookies present on the request
+func (m *Manager) clearLegacy(w http.ResponseWriter, r *http.Request) {
+	if r == nil {
+		return
+	}
+	for _, name := range m.legacyNames {
+		if _, err := r.Cookie(name); err == nil {
+			cookie := m.cookie(name, "")
+			cookie.MaxAge = -1
+			http.SetCookie(w, cookie)
+		}
+	}
+}
+
+// cookie returns a cookie with the configured attributes
+func (m *Manager) cookie(name, value string) *http.Cookie {
+	return &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     m.path,
+		Domain:   m.domain,
+		Secure:   m.secure,
+		HttpOnly: m.httpOnly,
+		SameSite: m.sameSite,
+	}
+}
+
+// sign appends the signature of the first key to a session ID
+func (m *Manager) sign(sessionID string) string {
+	if len(m.keys) == 0 {
+		return sessionID
+	}
+	return sessionID + "." + mac(m.keys[0], sessionID)
+}
+
+// verify checks the cookie signature against every key and returns the session ID
+func (m *Manager) verify(value string) (string, error) {
+	if len(m.keys) == 0 {
+		return value, nil
+	}
+
+	i := strings.LastIndexByte(value, '.')
+	if i <= 0 {
+		return "", ErrInvalidSignature
+	}
+	sessionID, signature := value[:i], value[i+1:]
+	for _, key := range m.keys {
+		if hmac.Equal([]byte(mac(key, sessionID)), []byte(signature)) {
+			return sessionID, nil
+		}
+	}
+	return "", ErrInvalidSignature
+}
+
+// mac returns the base64url HMAC-SHA256 of a session ID
+func mac(key []byte, sessionID string) string {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte("session\n" + sessionID))
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,176 @@
+package cookie
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	oldKey = "0123456789abcdef0123456789abcdef"
+	newKey = "fedcba9876543210fedcba9876543210"
+)
+
+func requestWithCookies(cookies ...*http.Cookie) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	for _, c := range cookies {
+		r.AddCookie(c)
+	}
+	return r
+}
+
+func TestManagerAttributes(t *testing.T) {
+	m, err := NewManager(&config.SessionConfig{
+		CookieName:     "mcp_session",
+		CookieDomain:   "example.com",
+		CookiePath:     "/app",
+		CookieSecure:   true,
+		CookieHTTPOnly: true,
+		CookieSameSite: "strict",
+	})
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	m.Set(w, requestWithCookies(), "user:alice", 3600)
+
+	set := w.Result().Cookies()
+	require.Len(t, set, 1)
+	assert.Equal(t, "mcp_session", set[0].Name)
+	assert.Equal(t, "user:alice", set[0].Value)
+	assert.Equal(t, "example.com", set[0].Domain)
+	assert.Equal(t, "/app", set[0].Path)
+	assert.Equal(t, 3600, set[0].MaxAge)
+	assert.True(t, set[0].Secure)
+	assert.True(t, set[0].HttpOnly)
+	assert.Equal(t, http.SameSiteStrictMode, set[0].SameSite)
+
+	sessionID, legacy, err := m.Read(requestWithCookies(set[0]))
+	require.NoError(t, err)
+	assert.Equal(t, "user:alice", sessionID)
+	assert.False(t, legacy)
+
+	_, _, err = m.Read(requestWithCookies(&http.Cookie{Name: "session_id", Value: "user:alice"}))
+	assert.ErrorIs(t, err, ErrNoCookie)
+}
+
+func TestManagerDefaults(t *testing.T) {
+	m, err := NewManager(&config.SessionConfig{})
+	require.NoError(t, err)
+	assert.Equal(t, DefaultName, m.Name())
+
+	w := httptest.NewRecorder()
+	m.Clear(w, requestWithCookies())
+	header := w.Header().Get("Set-Cookie")
+	assert.True(t, strings.HasPrefix(header, "session_id=;"))
+	assert.Contains(t, header, "Max-Age=0")
+	assert.Contains(t, header, "HttpOnly")
+	assert.Contains(t, header, "SameSite=Lax")
+}
+
+func TestManagerHostPrefix(t *testing.T) {
+	m, err := NewManager(&config.SessionConfig{
+		CookieName:       "mcp_session",
+		CookiePath:       "/",
+		CookieSecure:     true,
+		CookieHostPrefix: true,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "__Host-mcp_session", m.Name())
+
+	for _, cfg := range []*config.SessionConfig{
+		{CookieName: "s", CookiePath: "/", CookieHostPrefix: true},
+		{CookieName: "s", CookiePath: "/app", CookieSecure: true, CookieHostPrefix: true},
+		{CookieName: "s", CookiePath: "/", CookieDomain: "example.com", CookieSecure: true, CookieHostPrefix: true},
+	} {
+		_, err := NewManager(cfg)
+		assert.Error(t, err)
+	}
+}
+
+func TestManagerSigning(t *testing.T) {
+	signing := func(keys ...string) *Manager {
+		m, err := NewManager(&config.SessionConfig{
+			CookieName:    "mcp_session",
+			CookieSigning: config.CookieSigningConfig{Enabled: true, Keys: keys},
+		})
+		require.NoError(t, err)
+		return m
+	}
+
+	before := signing(oldKey)
+	w := httptest.NewRecorder()
+	before.Set(w, nil, "user:alice", 0)
+	signed := w.Result().Cookies()[0]
+	assert.True(t, strings.HasPrefix(signed.Value, "user:alice."))
+
+	t.Run("Verified with any key", func(t *testing.T) {
+		rotated := signing(newKey, oldKey)
+		sessionID, _, err := rotated.Read(requestWithCookies(signed))
+		require.NoError(t, err)
+		assert.Equal(t, "user:alice", sessionID)
+
+		// New cookies are signed with the first key only
+		w := httptest.NewRecorder()
+		rotated.Set(w, nil, "user:alice", 0)
+		_, _, err = before.Read(requestWithCookies(w.Result().Cookies()[0]))
+		assert.ErrorIs(t, err, ErrInvalidSignature)
+	})
+
+	t.Run("Tampered and unsigned values are rejected", func(t *testing.T) {
+		for _, value := range []string{
+			"user:alice",
+			"user:bob" + signed.Value[len("user:alice"):],
+			signed.Value + "x",
+			"." + strings.SplitN(signed.Value, ".", 2)[1],
+		} {
+			_, _, err := before.Read(requestWithCookies(&http.Cookie{Name: "mcp_session", Value: value}))
+			assert.ErrorIs(t, err, ErrInvalidSignature, value)
+		}
+	})
+
+	t.Run("Removed keys no longer verify", func(t *testing.T) {
+		_, _, err := signing(newKey).Read(requestWithCookies(signed))
+		assert.ErrorIs(t, err, ErrInvalidSignature)
+	})
+
+	_, err := NewManager(&config.SessionConfig{CookieSigning: config.CookieSigningConfig{Enabled: true, Keys: []string{"short"}}})
+	assert.Error(t, err)
+	_, err = NewManager(&config.SessionConfig{CookieSigning: config.CookieSigningConfig{Enabled: true}})
+	assert.Error(t, err)
+}
+
+func TestManagerLegacyNames(t *testing.T) {
+	m, err := NewManager(&config.SessionConfig{
+		CookieName:        "mcp_session",
+		CookieLegacyNames: []string{"session_id"},
+	})
+	require.NoError(t, err)
+
+	legacyCookie := &http.Cookie{Name: "session_id", Value: "user:alice"}
+	r := requestWithCookies(legacyCookie)
+
+	sessionID, legacy, err := m.Read(r)
+	require.NoError(t, err)
+	assert.Equal(t, "user:alice", sessionID)
+	assert.True(t, legacy)
+
+	// Setting the current cookie expires the legacy one
+	w := httptest.NewRecorder()
+	m.Set(w, r, sessionID, 60)
+	set := w.Result().Cookies()
+	require.Len(t, set, 2)
+	assert.Equal(t, "mcp_session", set[0].Name)
+	assert.Equal(t, "session_id", set[1].Name)
+	assert.Equal(t, -1, set[1].MaxAge)
+
+	// The current name wins when both are present
+	sessionID, legacy, err = m.Read(requestWithCookies(legacyCookie, &http.Cookie{Name: "mcp_session", Value: "user:bob"}))
+	require.NoError(t, err)
+	assert.Equal(t, "user:bob", sessionID)
+	assert.False(t, legacy)
+}
@@ -0,0 +1,632 @@
+// Package cookiestore keeps sessions in the browser instead of on the server.
+// Each session is sealed with AES-256-GCM into one or more cookies; only
+// revocations are kept server side, in an optional denylist.
+package cookiestore
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/session/meta"
+	"go.uber.org/zap"
+)
+
+// DefaultNamePrefix is prepended to the names of the session data cookies
+const DefaultNamePrefix = "mcp_sd_"
+
+// DefaultMaxChunks bounds the number of cookies a single session may use
+const DefaultMaxChunks = 4
+
+// chunkSize keeps each cookie, with its name and attributes, below the 4096
+// byte limit browsers apply per cookie
+const chunkSize = 3800
+
+// ErrNoJar is returned when a session is written outside a request handled
+// by Middleware
+var ErrNoJar = errors.New("cookie store used without a request cookie jar")
+
+// ErrDenylistRequired is returned for revocations that cannot be expressed
+// without a denylist
+var ErrDenylistRequired = errors.New("cookie store has no denylist")
+
+// ErrTooLarge is returned for a session that does not fit in MaxChunks cookies
+// even without its non-essential token claims
+var ErrTooLarge = errors.New("session too large for cookie store")
+
+// ErrIndexNotListable is returned by IndexedKeys: indexes are sealed into the
+// sessions, so the server cannot list them, only revoke them with RevokeIndex
+var ErrIndexNotListable = errors.New("cookie store cannot list indexed sessions")
+
+// Denylist stores revocations. It is satisfied by the memory and Redis
+// session stores.
+type Denylist interface {
+	Create(ctx context.Context, key string, data interface{}, ttl time.Duration) (string, error)
+	Get(ctx context.Context, key string, data interface{}) error
+	Delete(ctx context.Context, key string) error
+	Close() error
+}
+
+// Config holds cookie session store configuration
+type Config struct {
+	// Keys encrypt the session cookies; the first key seals and every key
+	// opens, so keys can be rotated by prepending a new one
+	Keys []string
+	// NamePrefix is prepended to the data cookie names
+	NamePrefix string
+	// MaxChunks bounds the number of cookies per session
+	MaxChunks int
+
+	// Cookie attributes of the data cookies
+	Domain   string
+	Path     string
+	Secure   bool
+	SameSite http.SameSite
+
+	// RevocationTTL is how long revocations are kept; it should cover the
+	// longest session lifetime
+	RevocationTTL time.Duration
+}
+
+// Stats holds session store statistics
+type Stats = meta.Stats
+
+// envelope is the sealed cookie content
+type envelope struct {
+	Data      json.RawMessage `json:"d"`
+	IssuedAt  time.Time       `json:"iat"`
+	ExpiresAt *time.Time      `json:"exp,omitempty"`
+	Meta      *meta.Metadata  `json:"m,omitempty"`
+	Indexes   []string        `json:"ix,omitempty"`
+}
+
+// expired reports whether the session has expired at the given time
+func (e *envelope) expired(now time.Time) bool {
+	return e.ExpiresAt != nil && now.After(*e.ExpiresAt)
+}
+
+// revocation is the denylist entry of a revoked session key, user or index
+type revocation struct {
+	RevokedAt time.Time `json:"revoked_at"`
+}
+
+// Store implements session.Store by sealing sessions into cookies
+type Store struct {
+	keys     *keyring
+	config   Config
+	denylist Denylist
+	logger   *zap.Logger
+}
+
+// NewStore creates a cookie session store. denylist may be nil, in which case
+// a deleted session stays valid in any copy of its cookies until it expires.
+func NewStore(config *Config, denylist Denylist, logger *zap.Logger) (*Store, error) {
+	if config == nil {
+		return nil, fmt.Errorf("cookie store configuration is required")
+	}
+
+	keys, err := newKeyring(config.Keys)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := *config
+	if cfg.NamePrefix == "" {
+		cfg.NamePrefix = DefaultNamePrefix
+	}
+	if cfg.MaxChunks <= 0 {
+		cfg.MaxChunks = DefaultMaxChunks
+	}
+	if cfg.Path == "" {
+		cfg.Path = "/"
+	}
+	if cfg.SameSite == 0 {
+		cfg.SameSite = http.SameSiteLaxMode
+	}
+
+	return &Store{
+		keys:     keys,
+		config:   cfg,
+		denylist: denylist,
+		logger:   logger,
+	}, nil
+}
+
+// Create seals a new session into the response cookies
+func (s *Store) Create(ctx context.Context, key string, data interface{}, ttl time.Duration) (string, error) {
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal session data: %w", err)
+	}
+
+	now := time.Now()
+	env := &envelope{
+		Data:     jsonData,
+		IssuedAt: now,
+	}
+	if ttl > 0 {
+		expiresAt := now.Add(ttl)
+		env.ExpiresAt = &expiresAt
+	}
+
+	if err := s.write(ctx, key, env); err != nil {
+		return "", err
+	}
+
+	s.logger.Debug("Session created",
+		zap.String("key", key),
+		zap.Duration("ttl", ttl),
+	)
+	return key, nil
+}
+
+// Get opens the session carried by the request cookies
+func (s *Store) Get(ctx context.Context, key string, data interface{}) error {
+	env, err := s.read(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(env.Data, data); err != nil {
+		return fmt.Errorf("failed to unmarshal session data: %w", err)
+	}
+	return nil
+}
+
+// Update re-seals the session with new data, keeping its expiry
+func (s *Store) Update(ctx context.Context, key string, data interface{}) error {
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to marshal session data: %w", err)
+	}
+
+	env, err := s.read(ctx, key)
+	if err != nil {
+		return err
+	}
+	env.Data = jsonData
+
+	return s.write(ctx, key, env)
+}
+
+// Delete expires the session cookies and, with a denylist, revokes every
+// copy of the session issued so far
+func (s *Store) Delete(ctx context.Context, key string) error {
+	if jar := jarFromContext(ctx); jar != nil {
+		s.clear(jar, s.cookieName(key))
+	}
+
+	if s.denylist != nil {
+		if err := s.revoke(ctx, revokedKey(key)); err != nil {
+			return err
+		}
+	}
+
+	s.logger.Debug("Session deleted", zap.String("key", key))
+	return nil
+}
+
+// Exists checks if the request carries a valid session
+func (s *Store) Exists(ctx context.Context, key string) (bool, error) {
+	if _, err := s.read(ctx, key); err != nil {
+		return false, nil
+	}
+	return true, nil
+}
+
+// Refresh re-seals the session with a new expiry
+func (s *Store) Refresh(ctx context.Context, key string, ttl time.Duration) error {
+	env, err := s.read(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	if ttl > 0 {
+		expiresAt := time.Now().Add(ttl)
+		env.ExpiresAt = &expiresAt
+	} else {
+		env.ExpiresAt = nil
+	}
+
+	return s.write(ctx, key, env)
+}
+
+// Close closes the denylist
+func (s *Store) Close() error {
+	if s.denylist != nil {
+		return s.denylist.Close()
+	}
+	return nil
+}
+
+// Cleanup is a no-op; expired cookies are dropped by the browser
+func (s *Store) Cleanup(ctx context.Context) error {
+	return nil
+}
+
+// Stats returns session store statistics. Sessions live in the browser, so
+// the store cannot count them.
+func (s *Store) Stats(ctx context.Context) (*Stats, error) {
+	info := "sessions are held in encrypted cookies"
+	if s.denylist != nil {
+		info += " with a server-side denylist"
+	}
+	return &Stats{
+		Store: "cookie",
+		Info:  info,
+	}, nil
+}
+
+// SetMetadata seals owner and client metadata into the session
+func (s *Store) SetMetadata(ctx context.Context, key string, metadata *meta.Metadata) error {
+	env, err := s.read(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	m := *metadata
+	if env.Meta != nil && !env.Meta.CreatedAt.IsZero() {
+		m.CreatedAt = env.Meta.CreatedAt
+	}
+	if m.CreatedAt.IsZero() {
+		m.CreatedAt = env.IssuedAt
+	}
+	if m.LastSeen.IsZero() {
+		m.LastSeen = time.Now()
+	}
+	env.Meta = &m
+
+	return s.write(ctx, key, env)
+}
+
+// Touch is a no-op; re-issuing the cookies on every request to record the
+// last activity would cost more than it is worth
+func (s *Store) Touch(ctx context.Context, key string, seen time.Time) error {
+	return nil
+}
+
+// ListUserSessions returns no sessions; the server does not know them
+func (s *Store) ListUserSessions(ctx context.Context, userID string) ([]*meta.Info, error) {
+	return []*meta.Info{}, nil
+}
+
+// DeleteUserSessions revokes every session of a user issued so far. The
+// number of sessions is unknown, so 0 is returned.
+func (s *Store) DeleteUserSessions(ctx context.Context, userID string) (int, error) {
+	if s.denylist == nil {
+		return 0, ErrDenylistRequired
+	}
+	if err := s.revoke(ctx, revokedUserKey(userID)); err != nil {
+		return 0, err
+	}
+
+	s.logger.Debug("User sessions revoked", zap.String("user_id", userID))
+	return 0, nil
+}
+
+// ListSessions returns an empty page; the server does not know the sessions
+func (s *Store) ListSessions(ctx context.Context, cursor string, limit int) (*meta.Page, error) {
+	if _, err := meta.DecodeCursor(cursor); err != nil {
+		return nil, err
+	}
+	return &meta.Page{Sessions: []*meta.Info{}}, nil
+}
+
+// AddIndex seals the index into the session so that RevokeIndex reaches it
+func (s *Store) AddIndex(ctx context.Context, index, key string) error {
+	env, err := s.read(ctx, key)
+	if err != nil {
+		return err
+	}
+	for _, existing := range env.Indexes {
+		if existing == index {
+			return nil
+		}
+	}
+	env.Indexes = append(env.Indexes, index)
+	return s.write(ctx, key, env)
+}
+
+// RemoveIndex removes the index from the session
+func (s *Store) RemoveIndex(ctx context.Context, index, key string) error {
+	env, err := s.read(ctx, key)
+	if err != nil {
+		return err
+	}
+	kept := env.Indexes[:0]
+	for _, existing := range env.Indexes {
+		if existing != index {
+			kept = append(kept, existing)
+		}
+	}
+	env.Indexes = kept
+	return s.write(ctx, key, env)
+}
+
+// IndexedKeys fails with ErrIndexNotListable; the server does not know the sessions
+func (s *Store) IndexedKeys(ctx context.Context, index string) ([]string, error) {
+	return nil, ErrIndexNotListable
+}
+
+// RevokeIndex revokes every session in the index issued so far
+func (s *Store) RevokeIndex(ctx context.Context, index string) error {
+	if s.denylist == nil {
+		return ErrDenylistRequired
+	}
+	if err := s.revoke(ctx, revokedIndexKey(index)); err != nil {
+		return err
+	}
+
+	s.logger.Debug("Indexed sessions revoked", zap.String("index", index))
+	return nil
+}
+
+// read opens the session cookies of the request
+func (s *Store) read(ctx context.Context, key string) (*envelope, error) {
+	jar := jarFromContext(ctx)
+	if jar == nil {
+		return nil, meta.ErrNotFound
+	}
+
+	name := s.cookieName(key)
+	var value []byte
+	for i := 0; i < s.config.MaxChunks; i++ {
+		chunk, ok := jar.get(chunkName(name, i))
+		if !ok {
+			break
+		}
+		value = append(value, chunk...)
+	}
+	if len(value) == 0 {
+		return nil, meta.ErrNotFound
+	}
+
+	plaintext, err := s.keys.open(string(value), []byte(key))
+	if err != nil {
+		s.logger.Debug("Rejected session cookie", zap.String("key", key), zap.Error(err))
+		return nil, meta.ErrNotFound
+	}
+
+	var env envelope
+	if err := json.Unmarshal(plaintext, &env); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal session envelope: %w", err)
+	}
+	if env.expired(time.Now()) {
+		return nil, meta.ErrNotFound
+	}
+
+	revoked, err := s.revoked(ctx, key, &env)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, meta.ErrNotFound
+	}
+
+	return &env, nil
+}
+
+// write seals the envelope into as many cookies as it needs. When the sealed
+// value does not fit, the largest non-essential token claims are dropped
+// from the session data until it does.
+func (s *Store) write(ctx context.Context, key string, env *envelope) error {
+	jar := jarFromContext(ctx)
+	if jar == nil {
+		return ErrNoJar
+	}
+
+	value, err := s.seal(key, env)
+	if err != nil {
+		return err
+	}
+
+	limit := s.config.MaxChunks * chunkSize
+	var dropped []string
+	for len(value) > limit {
+		data, claim, ok := trimClaims(env.Data)
+		if !ok {
+			return fmt.Errorf("%w: %d bytes (limit %d)", ErrTooLarge, len(value), limit)
+		}
+		env.Data = data
+		dropped = append(dropped, claim)
+		if value, err = s.seal(key, env); err != nil {
+			return err
+		}
+	}
+	if len(dropped) > 0 {
+		s.logger.Warn("Session too large for cookies; dropped token claims",
+			zap.String("key", key),
+			zap.Strings("claims", dropped),
+		)
+	}
+
+	maxAge := 0
+	if env.ExpiresAt != nil {
+		maxAge = int(math.Ceil(time.Until(*env.ExpiresAt).Seconds()))
+		if maxAge <= 0 {
+			maxAge = -1
+		}
+	}
+
+	name := s.cookieName(key)
+	chunks := 0
+	for start := 0; start < len(value); start += chunkSize {
+		end := start + chunkSize
+		if end > len(value) {
+			end = len(value)
+		}
+		c := s.cookie(chunkName(name, chunks), value[start:end])
+		c.MaxAge = maxAge
+		jar.set(c)
+		chunks++
+	}
+
+	// Expire the chunks left over from a larger value
+	for i := chunks; i < s.config.MaxChunks; i++ {
+		if _, ok := jar.get(chunkName(name, i)); ok {
+			s.expire(jar, chunkName(name, i))
+		}
+	}
+	return nil
+}
+
+// seal encodes and encrypts an envelope bound to its session key
+func (s *Store) seal(key string, env *envelope) (string, error) {
+	plaintext, err := json.Marshal(env)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal session envelope: %w", err)
+	}
+	return s.keys.seal(plaintext, []byte(key))
+}
+
+// clear expires every chunk of a session present on the request
+func (s *Store) clear(jar *Jar, name string) {
+	for i := 0; i < s.config.MaxChunks; i++ {
+		if _, ok := jar.get(chunkName(name, i)); ok {
+			s.expire(jar, chunkName(name, i))
+		}
+	}
+}
+
+// expire removes a cookie from the browser
+func (s *Store) expire(jar *Jar, name string) {
+	c := s.cookie(name, "")
+	c.MaxAge = -1
+	jar.set(c)
+}
+
+// cookie returns a data cookie with the configured attributes
+func (s *Store) cookie(name, value string) *http.Cookie {
+	return &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     s.config.Path,
+		Domain:   s.config.Domain,
+		Secure:   s.config.Secure,
+		HttpOnly: true,
+		SameSite: s.config.SameSite,
+	}
+}
+
+// cookieName derives the data cookie name of a session key. Keys may contain
+// characters not allowed in cookie names, so a hash is used.
+func (s *Store) cookieName(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return s.config.NamePrefix + hex.EncodeToString(sum[:6])
+}
+
+// chunkName returns the name of the i-th cookie of a session
+func chunkName(name string, i int) string {
+	return name + "_" + strconv.Itoa(i)
+}
+
+// revoke records a revocation in the denylist, replacing an older one
+func (s *Store) revoke(ctx context.Context, entry string) error {
+	_ = s.denylist.Delete(ctx, entry)
+	if _, err := s.denylist.Create(ctx, entry, &revocation{RevokedAt: time.Now()}, s.config.RevocationTTL); err != nil {
+		return fmt.Errorf("failed to record revocation: %w", err)
+	}
+	return nil
+}
+
+// revoked reports whether the session was issued before a revocation of its
+// key, its user or one of its indexes. A denylist that cannot be read fails
+// the check, so an outage does not let revoked sessions back in.
+func (s *Store) revoked(ctx context.Context, key string, env *envelope) (bool, error) {
+	if s.denylist == nil {
+		return false, nil
+	}
+
+	entries := []string{revokedKey(key)}
+	if env.Meta != nil && env.Meta.UserID != "" {
+		entries = append(entries, revokedUserKey(env.Meta.UserID))
+	}
+	for _, index := range env.Indexes {
+		entries = append(entries, revokedIndexKey(index))
+	}
+
+	for _, entry := range entries {
+		var r revocation
+		err := s.denylist.Get(ctx, entry, &r)
+		if errors.Is(err, meta.ErrNotFound) {
+			// Missing and expired entries are not revocations
+			continue
+		}
+		if err != nil {
+			return false, fmt.Errorf("failed to check session revocation: %w", err)
+		}
+		if !env.IssuedAt.After(r.RevokedAt) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// revokedKey is the denylist entry of a session key
+func revokedKey(key string) string {
+	return "revoked:" + key
+}
+
+// revokedUserKey is the denylist entry of all sessions of a user
+func revokedUserKey(userID string) string {
+	return "revoked-user:" + userID
+}
+
+// revokedIndexKey is the denylist entry of all sessions in an index
+func revokedIndexKey(index string) string {
+	return "revoked-index:" + index
+}
+
+// essentialClaims are kept by trimClaims: they identify the user and the
+// login, and are small
+var essentialClaims = map[string]bool{
+	"iss": true, "sub": true, "aud": true, "exp": true, "iat": true,
+	"sid": true, "acr": true, "amr": true, "auth_time": true,
+	"email": true, "name": true, "preferred_username": true,
+}
+
+// trimClaims drops the largest non-essential claim from the "claims" field of
+// JSON session data and returns its name. It reports false when there is
+// nothing left to drop.
+func trimClaims(data json.RawMessage) (json.RawMessage, string, bool) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return data, "", false
+	}
+	var claims map[string]json.RawMessage
+	if err := json.Unmarshal(fields["claims"], &claims); err != nil {
+		return data, "", false
+	}
+
+	largest := ""
+	for name, value := range claims {
+		if essentialClaims[name] {
+			continue
+		}
+		if largest == "" || len(value) > len(claims[largest]) || (len(value) == len(claims[largest]) && name < largest) {
+			largest = name
+		}
+	}
+	if largest == "" {
+		return data, "", false
+	}
+	delete(claims, largest)
+
+	trimmedClaims, err := json.Marshal(claims)
+	if err != nil {
+		return data, "", false
+	}
+	fields["claims"] = trimmedClaims
+	trimmed, err := json.Marshal(fields)
+	if err != nil {
+		return data, "", false
+	}
+	return trimmed, largest, true
+}
@@ -0,0 +1,432 @@
+package cookiestore
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/session/memory"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/session/meta"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+const (
+	testKey    = "0123456789abcdef0123456789abcdef"
+	rotatedKey = "fedcba9876543210fedcba9876543210"
+)
+
+type testData struct {
+	ID     string                 `json:"id"`
+	Claims map[string]interface{} `json:"claims,omitempty"`
+}
+
+// browser keeps the cookies set by responses and sends them with requests
+type browser struct {
+	cookies map[string]string
+}
+
+func newBrowser() *browser {
+	return &browser{cookies: make(map[string]string)}
+}
+
+// do runs fn within a request carrying the browser cookies and stores the
+// cookies of the response
+func (b *browser) do(fn func(ctx context.Context)) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	for name, value := range b.cookies {
+		req.AddCookie(&http.Cookie{Name: name, Value: value})
+	}
+	w := httptest.NewRecorder()
+	fn(WithJar(req.Context(), w, req))
+
+	for _, c := range w.Result().Cookies() {
+		if c.MaxAge < 0 {
+			delete(b.cookies, c.Name)
+		} else {
+			b.cookies[c.Name] = c.Value
+		}
+	}
+	return w
+}
+
+func newTestStore(t *testing.T, keys []string, denylist Denylist) *Store {
+	store, err := NewStore(&Config{Keys: keys, Secure: true}, denylist, zap.NewNop())
+	require.NoError(t, err)
+	return store
+}
+
+func TestNewStore(t *testing.T) {
+	_, err := NewStore(&Config{}, nil, zap.NewNop())
+	assert.Error(t, err)
+
+	_, err = NewStore(&Config{Keys: []string{"short"}}, nil, zap.NewNop())
+	assert.Error(t, err)
+
+	_, err = NewStore(&Config{Keys: []string{testKey}}, nil, zap.NewNop())
+	assert.NoError(t, err)
+}
+
+func TestStoreRoundTrip(t *testing.T) {
+	store := newTestStore(t, []string{testKey}, nil)
+	b := newBrowser()
+
+	w := b.do(func(ctx context.Context) {
+		_, err := store.Create(ctx, "user:alice", &testData{ID: "alice"}, time.Hour)
+		require.NoError(t, err)
+
+		// Later reads of the same request see the new session
+		var data testData
+		require.NoError(t, store.Get(ctx, "user:alice", &data))
+		assert.Equal(t, "alice", data.ID)
+	})
+
+	cookies := w.Result().Cookies()
+	require.Len(t, cookies, 1)
+	assert.True(t, strings.HasPrefix(cookies[0].Name, DefaultNamePrefix))
+	assert.True(t, cookies[0].HttpOnly)
+	assert.True(t, cookies[0].Secure)
+	assert.InDelta(t, 3600, cookies[0].MaxAge, 1)
+	assert.NotContains(t, cookies[0].Value, "alice")
+
+	b.do(func(ctx context.Context) {
+		var data testData
+		require.NoError(t, store.Get(ctx, "user:alice", &data))
+		assert.Equal(t, "alice", data.ID)
+
+		exists, err := store.Exists(ctx, "user:alice")
+		require.NoError(t, err)
+		assert.True(t, exists)
+
+		require.NoError(t, store.Update(ctx, "user:alice", &testData{ID: "alice2"}))
+	})
+
+	b.do(func(ctx context.Context) {
+		var data testData
+		require.NoError(t, store.Get(ctx, "user:alice", &data))
+		assert.Equal(t, "alice2", data.ID)
+
+		// A session sealed for one key cannot be read as another
+		assert.Error(t, store.Get(ctx, "user:bob", &data))
+
+		require.NoError(t, store.Delete(ctx, "user:alice"))
+	})
+	assert.Empty(t, b.cookies)
+
+	b.do(func(ctx context.Context) {
+		exists, err := store.Exists(ctx, "user:alice")
+		require.NoError(t, err)
+		assert.False(t, exists)
+	})
+}
+
+func TestStoreRejectsForeignCookies(t *testing.T) {
+	store := newTestStore(t, []string{testKey}, nil)
+	b := newBrowser()
+	b.do(func(ctx context.Context) {
+		_, err := store.Create(ctx, "user:alice", &testData{ID: "alice"}, time.Hour)
+		require.NoError(t, err)
+	})
+
+	// Move alice's sealed value to bob's cookie name
+	var value string
+	for _, v := range b.cookies {
+		value = v
+	}
+	bob := newBrowser()
+	bob.cookies[chunkName(store.cookieName("user:bob"), 0)] = value
+	bob.do(func(ctx context.Context) {
+		var data testData
+		assert.Error(t, store.Get(ctx, "user:bob", &data))
+	})
+
+	// Tampered value
+	tampered := newBrowser()
+	for name, v := range b.cookies {
+		tampered.cookies[name] = v[:len(v)-2] + "AA"
+	}
+	tampered.do(func(ctx context.Context) {
+		var data testData
+		assert.Error(t, store.Get(ctx, "user:alice", &data))
+	})
+}
+
+func TestStoreKeyRotation(t *testing.T) {
+	oldStore := newTestStore(t, []string{testKey}, nil)
+	b := newBrowser()
+	b.do(func(ctx context.Context) {
+		_, err := oldStore.Create(ctx, "user:alice", &testData{ID: "alice"}, time.Hour)
+		require.NoError(t, err)
+	})
+
+	rotated := newTestStore(t, []string{rotatedKey, testKey}, nil)
+	b.do(func(ctx context.Context) {
+		var data testData
+		require.NoError(t, rotated.Get(ctx, "user:alice", &data))
+		require.NoError(t, rotated.Refresh(ctx, "user:alice", time.Hour))
+	})
+
+	// Re-sealed with the new key, so the old key no longer opens it
+	newOnly := newTestStore(t, []string{rotatedKey}, nil)
+	b.do(func(ctx context.Context) {
+		var data testData
+		require.NoError(t, newOnly.Get(ctx, "user:alice", &data))
+		assert.Error(t, oldStore.Get(ctx, "user:alice", &data))
+	})
+}
+
+func TestStoreChunking(t *testing.T) {
+	store := newTestStore(t, []string{testKey}, nil)
+	b := newBrowser()
+
+	// Random-looking data does not compress, forcing several chunks
+	claims := make(map[string]interface{})
+	for i := 0; i < 120; i++ {
+		claims[strings.Repeat("k", i+1)] = randomString(t, 64)
+	}
+
+	w := b.do(func(ctx context.Context) {
+		_, err := store.Create(ctx, "user:alice", &testData{ID: "alice", Claims: claims}, time.Hour)
+		require.NoError(t, err)
+	})
+	cookies := w.Result().Cookies()
+	assert.Greater(t, len(cookies), 1)
+	for _, c := range cookies {
+		assert.LessOrEqual(t, len(c.Value), chunkSize)
+	}
+
+	b.do(func(ctx context.Context) {
+		var data testData
+		require.NoError(t, store.Get(ctx, "user:alice", &data))
+		assert.Len(t, data.Claims, 120)
+
+		// Shrinking the session expires the chunks it no longer needs
+		require.NoError(t, store.Update(ctx, "user:alice", &testData{ID: "alice"}))
+	})
+	assert.Len(t, b.cookies, 1)
+
+	b.do(func(ctx context.Context) {
+		var data testData
+		require.NoError(t, store.Get(ctx, "user:alice", &data))
+		assert.Equal(t, "alice", data.ID)
+	})
+}
+
+func TestStoreTrimsClaims(t *testing.T) {
+	store, err := NewStore(&Config{Keys: []string{testKey}, MaxChunks: 1}, nil, zap.NewNop())
+	require.NoError(t, err)
+	b := newBrowser()
+
+	claims := map[string]interface{}{
+		"sub":    "alice",
+		"email":  "alice@example.com",
+		"groups": randomString(t, 2*chunkSize),
+		"locale": "en",
+	}
+	b.do(func(ctx context.Context) {
+		_, err := store.Create(ctx, "user:alice", &testData{ID: "alice", Claims: claims}, time.Hour)
+		require.NoError(t, err)
+	})
+	b.do(func(ctx context.Context) {
+		var data testData
+		require.NoError(t, store.Get(ctx, "user:alice", &data))
+		assert.Equal(t, "alice", data.ID)
+		assert.Equal(t, map[string]interface{}{"sub": "alice", "email": "alice@example.com", "locale": "en"}, data.Claims,
+			"only the claims needed to fit are dropped")
+	})
+
+	t.Run("Essential claims are not dropped", func(t *testing.T) {
+		essential := map[string]interface{}{"sub": randomString(t, 2*chunkSize)}
+		w := b.do(func(ctx context.Context) {
+			_, err := store.Create(ctx, "user:bob", &testData{ID: "bob", Claims: essential}, time.Hour)
+			assert.ErrorIs(t, err, ErrTooLarge)
+		})
+		assert.Empty(t, w.Result().Cookies(), "nothing of the session is stored")
+	})
+
+	t.Run("Data without claims is rejected", func(t *testing.T) {
+		b.do(func(ctx context.Context) {
+			_, err := store.Create(ctx, "big", map[string]string{"blob": randomString(t, 2*chunkSize)}, time.Hour)
+			assert.ErrorIs(t, err, ErrTooLarge)
+		})
+	})
+}
+
+func TestStoreExpiry(t *testing.T) {
+	store := newTestStore(t, []string{testKey}, nil)
+	b := newBrowser()
+	b.do(func(ctx context.Context) {
+		_, err := store.Create(ctx, "user:alice", &testData{ID: "alice"}, time.Hour)
+		require.NoError(t, err)
+	})
+
+	// A cookie kept past its expiry is rejected by the sealed expiry
+	b.do(func(ctx context.Context) {
+		env, err := store.read(ctx, "user:alice")
+		require.NoError(t, err)
+		past := time.Now().Add(-time.Second)
+		env.ExpiresAt = &past
+		value, err := store.seal("user:alice", env)
+		require.NoError(t, err)
+		b.cookies[chunkName(store.cookieName("user:alice"), 0)] = value
+	})
+	b.do(func(ctx context.Context) {
+		var data testData
+		assert.Error(t, store.Get(ctx, "user:alice", &data))
+	})
+}
+
+func TestStoreDenylist(t *testing.T) {
+	denylist := memory.NewStore(&memory.Config{}, zap.NewNop())
+	store := newTestStore(t, []string{testKey}, denylist)
+	defer store.Close()
+
+	login := func(b *browser, userID string) {
+		b.do(func(ctx context.Context) {
+			key := "user:" + userID
+			_, err := store.Create(ctx, key, &testData{ID: userID}, time.Hour)
+			require.NoError(t, err)
+			require.NoError(t, store.SetMetadata(ctx, key, &meta.Metadata{UserID: userID}))
+		})
+	}
+	valid := func(b *browser, userID string) bool {
+		var ok bool
+		b.do(func(ctx context.Context) {
+			ok = store.Get(ctx, "user:"+userID, &testData{}) == nil
+		})
+		return ok
+	}
+
+	t.Run("Delete revokes copies of the cookie", func(t *testing.T) {
+		b := newBrowser()
+		login(b, "alice")
+
+		stolen := newBrowser()
+		for name, value := range b.cookies {
+			stolen.cookies[name] = value
+		}
+
+		// Logout without the cookies, e.g. back-channel
+		require.NoError(t, store.Delete(context.Background(), "user:alice"))
+		assert.False(t, valid(b, "alice"))
+		assert.False(t, valid(stolen, "alice"))
+
+		// A new login is not affected by the earlier revocation
+		time.Sleep(time.Millisecond)
+		login(b, "alice")
+		assert.True(t, valid(b, "alice"))
+	})
+
+	t.Run("DeleteUserSessions revokes by user", func(t *testing.T) {
+		b := newBrowser()
+		login(b, "bob")
+
+		_, err := store.DeleteUserSessions(context.Background(), "bob")
+		require.NoError(t, err)
+		assert.False(t, valid(b, "bob"))
+	})
+
+	t.Run("RevokeIndex revokes the indexed sessions", func(t *testing.T) {
+		b := newBrowser()
+		login(b, "carol")
+		other := newBrowser()
+		login(other, "dave")
+		b.do(func(ctx context.Context) {
+			require.NoError(t, store.AddIndex(ctx, "sid:idp-1", "user:carol"))
+		})
+		other.do(func(ctx context.Context) {
+			require.NoError(t, store.AddIndex(ctx, "sid:idp-2", "user:dave"))
+		})
+
+		_, err := store.IndexedKeys(context.Background(), "sid:idp-1")
+		assert.ErrorIs(t, err, ErrIndexNotListable)
+
+		require.NoError(t, store.RevokeIndex(context.Background(), "sid:idp-1"))
+		assert.False(t, valid(b, "carol"))
+		assert.True(t, valid(other, "dave"))
+	})
+
+	t.Run("An unavailable denylist fails closed", func(t *testing.T) {
+		broken := &failingDenylist{Denylist: denylist}
+		store := newTestStore(t, []string{testKey}, broken)
+		b := newBrowser()
+		b.do(func(ctx context.Context) {
+			_, err := store.Create(ctx, "user:erin", &testData{ID: "erin"}, time.Hour)
+			require.NoError(t, err)
+		})
+
+		broken.err = errors.New("connection refused")
+		b.do(func(ctx context.Context) {
+			assert.Error(t, store.Get(ctx, "user:erin", &testData{}))
+		})
+	})
+
+	t.Run("Without a denylist", func(t *testing.T) {
+		stateless := newTestStore(t, []string{testKey}, nil)
+		_, err := stateless.DeleteUserSessions(context.Background(), "bob")
+		assert.ErrorIs(t, err, ErrDenylistRequired)
+		assert.ErrorIs(t, stateless.RevokeIndex(context.Background(), "sid:idp-1"), ErrDenylistRequired)
+	})
+}
+
+// failingDenylist fails every lookup with err once it is set
+type failingDenylist struct {
+	Denylist
+	err error
+}
+
+func (d *failingDenylist) Get(ctx context.Context, key string, data interface{}) error {
+	if d.err != nil {
+		return d.err
+	}
+	return d.Denylist.Get(ctx, key, data)
+}
+
+func TestStoreWithoutJar(t *testing.T) {
+	store := newTestStore(t, []string{testKey}, nil)
+	ctx := context.Background()
+
+	_, err := store.Create(ctx, "user:alice", &testData{ID: "alice"}, time.Hour)
+	assert.ErrorIs(t, err, ErrNoJar)
+	assert.Error(t, store.Get(ctx, "user:alice", &testData{}))
+	assert.NoError(t, store.Delete(ctx, "user:alice"))
+
+	page, err := store.ListSessions(ctx, "", 10)
+	require.NoError(t, err)
+	assert.Empty(t, page.Sessions)
+	_, err = store.ListSessions(ctx, "not a cursor", 10)
+	assert.ErrorIs(t, err, meta.ErrInvalidCursor)
+}
+
+func TestJarReplacesCookies(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	w := httptest.NewRecorder()
+	w.Header().Add("Set-Cookie", "other=1")
+	jar := jarFromContext(WithJar(req.Context(), w, req))
+
+	jar.set(&http.Cookie{Name: "a", Value: "1"})
+	jar.set(&http.Cookie{Name: "a", Value: "2"})
+
+	assert.Equal(t, []string{"other=1", "a=2"}, w.Header()["Set-Cookie"])
+	value, ok := jar.get("a")
+	assert.True(t, ok)
+	assert.Equal(t, "2", value)
+}
+
+func randomString(t *testing.T, n int) string {
+	ring, err := newKeyring([]string{testKey})
+	require.NoError(t, err)
+
+	var b strings.Builder
+	for b.Len() < n {
+		value, err := ring.seal([]byte("x"), nil)
+		require.NoError(t, err)
+		b.WriteString(value)
+	}
+	return b.String()[:n]
+}
@@ -0,0 +1,87 @@
+package cookiestore
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// jarContextKey is the context key of the request cookie jar
+type jarContextKey struct{}
+
+// Jar gives the store access to the cookies of the current request and to
+// the response being written. Cookies set during the request are visible to
+// later reads of the same request.
+type Jar struct {
+	mu      sync.Mutex
+	request *http.Request
+	writer  http.ResponseWriter
+	pending map[string]*http.Cookie
+}
+
+// WithJar returns a context carrying the cookie jar of a request
+func WithJar(ctx context.Context, w http.ResponseWriter, r *http.Request) context.Context {
+	return context.WithValue(ctx, jarContextKey{}, &Jar{
+		request: r,
+		writer:  w,
+		pending: make(map[string]*http.Cookie),
+	})
+}
+
+// Middleware makes the request cookies and the response available to the
+// cookie store. It must run before any handler that uses the session store.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Request = c.Request.WithContext(WithJar(c.Request.Context(), c.Writer, c.Request))
+		c.Next()
+	}
+}
+
+// jarFromContext returns the cookie jar of the request, if any
+func jarFromContext(ctx context.Context) *Jar {
+	jar, _ := ctx.Value(jarContextKey{}).(*Jar)
+	return jar
+}
+
+// get returns a cookie value, preferring one set during this request
+func (j *Jar) get(name string) (string, bool) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if c, ok := j.pending[name]; ok {
+		if c.MaxAge < 0 {
+			return "", false
+		}
+		return c.Value, true
+	}
+	c, err := j.request.Cookie(name)
+	if err != nil {
+		return "", false
+	}
+	return c.Value, true
+}
+
+// set writes a cookie to the response, replacing one of the same name
+// written earlier in this request
+func (j *Jar) set(c *http.Cookie) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.pending[c.Name] = c
+
+	header := j.writer.Header()
+	prefix := c.Name + "="
+	kept := make([]string, 0, len(header["Set-Cookie"])+1)
+	for _, value := range header["Set-Cookie"] {
+		if !strings.HasPrefix(value, prefix) {
+			kept = append(kept, value)
+		}
+	}
+	if value := c.String(); value != "" {
+		kept = append(kept, value)
+	}
+	header["Set-Cookie"] = kept
+}
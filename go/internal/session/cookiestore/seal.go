@@ -0,0 +1,163 @@
+package cookiestore
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// MinKeyLength is the minimum encryption key length in bytes
+const MinKeyLength = 32
+
+// Sealed value layout (before base64url encoding):
+//
+//	version (1) | flags (1) | key ID (4) | nonce (12) | AES-256-GCM ciphertext
+//
+// The header and the session key are authenticated as additional data, so a
+// value cannot be moved to another session key or have its flags changed.
+const (
+	sealVersion    byte = 1
+	flagCompressed byte = 1 << 0
+
+	keyIDSize  = 4
+	headerSize = 2 + keyIDSize
+
+	// compressThreshold skips compression for values too small to benefit
+	compressThreshold = 256
+)
+
+var errUnsealable = errors.New("sealed value invalid")
+
+// sealKey is one AES-256-GCM key of the keyring
+type sealKey struct {
+	id   [keyIDSize]byte
+	aead cipher.AEAD
+}
+
+// keyring seals with its first key and opens with any key, so keys can be
+// rotated by prepending a new one
+type keyring struct {
+	keys []sealKey
+}
+
+// newKeyring derives AES-256 keys from the configured secrets
+func newKeyring(secrets []string) (*keyring, error) {
+	if len(secrets) == 0 {
+		return nil, fmt.Errorf("at least one encryption key is required")
+	}
+
+	ring := &keyring{}
+	for _, secret := range secrets {
+		if len(secret) < MinKeyLength {
+			return nil, fmt.Errorf("encryption keys must be at least %d bytes", MinKeyLength)
+		}
+
+		key := sha256.Sum256([]byte(secret))
+		block, err := aes.NewCipher(key[:])
+		if err != nil {
+			return nil, fmt.Errorf("failed to create cipher: %w", err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create AEAD: %w", err)
+		}
+
+		sk := sealKey{aead: aead}
+		id := sha256.Sum256(append([]byte("key-id\n"), key[:]...))
+		copy(sk.id[:], id[:keyIDSize])
+		ring.keys = append(ring.keys, sk)
+	}
+	return ring, nil
+}
+
+// seal compresses and encrypts plaintext bound to the additional data
+func (k *keyring) seal(plaintext, additionalData []byte) (string, error) {
+	key := k.keys[0]
+
+	var flags byte
+	if len(plaintext) >= compressThreshold {
+		if compressed, err := compress(plaintext); err == nil && len(compressed) < len(plaintext) {
+			plaintext = compressed
+			flags |= flagCompressed
+		}
+	}
+
+	header := make([]byte, headerSize, headerSize+key.aead.NonceSize()+len(plaintext)+key.aead.Overhead())
+	header[0] = sealVersion
+	header[1] = flags
+	copy(header[2:], key.id[:])
+
+	nonce := make([]byte, key.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	out := append(header, nonce...)
+	out = key.aead.Seal(out, nonce, plaintext, append(header[:headerSize:headerSize], additionalData...))
+	return base64.RawURLEncoding.EncodeToString(out), nil
+}
+
+// open decrypts a sealed value with the key that sealed it
+func (k *keyring) open(value string, additionalData []byte) ([]byte, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil || len(raw) < headerSize || raw[0] != sealVersion {
+		return nil, errUnsealable
+	}
+	header := raw[:headerSize]
+
+	for _, key := range k.keys {
+		if !bytes.Equal(header[2:], key.id[:]) {
+			continue
+		}
+
+		nonceSize := key.aead.NonceSize()
+		if len(raw) < headerSize+nonceSize {
+			return nil, errUnsealable
+		}
+		nonce := raw[headerSize : headerSize+nonceSize]
+		plaintext, err := key.aead.Open(nil, nonce, raw[headerSize+nonceSize:], append(header[:headerSize:headerSize], additionalData...))
+		if err != nil {
+			return nil, errUnsealable
+		}
+
+		if header[1]&flagCompressed != 0 {
+			return decompress(plaintext)
+		}
+		return plaintext, nil
+	}
+	return nil, errUnsealable
+}
+
+// compress deflates data
+func compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := flate.NewWriter(&buf, flate.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decompress inflates data, bounded to keep a crafted value from expanding
+// without limit
+func decompress(data []byte) ([]byte, error) {
+	const maxDecompressed = 1 << 20
+	plaintext, err := io.ReadAll(io.LimitReader(flate.NewReader(bytes.NewReader(data)), maxDecompressed+1))
+	if err != nil || len(plaintext) > maxDecompressed {
+		return nil, errUnsealable
+	}
+	return plaintext, nil
+}
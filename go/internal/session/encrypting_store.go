@@ -0,0 +1,344 @@
+package session
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// MinEncryptionKeyLength is the minimum encryption key length in bytes
+const MinEncryptionKeyLength = 32
+
+// Encrypted values are stored as a JSON string:
+//
+//	"enc:v1:" + base64url(key ID (4) | nonce (12) | AES-256-GCM ciphertext)
+//
+// The prefix, key ID and session key are authenticated as additional data,
+// so a value copied to another session key fails to decrypt.
+const (
+	encryptedPrefix  = "enc:v1:"
+	encryptionIDSize = 4
+)
+
+// ErrDecryptionFailed is returned by EncryptingStore.Get for a value that no
+// key of the keyring can decrypt
+var ErrDecryptionFailed = errors.New("failed to decrypt session data")
+
+// encryptionKey is one AES-256-GCM key of the keyring
+type encryptionKey struct {
+	id   [encryptionIDSize]byte
+	aead cipher.AEAD
+}
+
+// EncryptingStore wraps a Store and encrypts session data before it reaches
+// the wrapped store. It encrypts with the first key and decrypts with any,
+// so keys can be rotated by prepending a new one; values read with an older
+// key, or stored before encryption was enabled, are re-encrypted with the
+// first key. Metadata and indexes are stored unencrypted.
+type EncryptingStore struct {
+	store  Store
+	keys   []encryptionKey
+	logger *zap.Logger
+}
+
+// NewEncryptingStore creates a new encrypting store wrapper
+func NewEncryptingStore(store Store, secrets []string, logger *zap.Logger) (*EncryptingStore, error) {
+	if len(secrets) == 0 {
+		return nil, fmt.Errorf("at least one encryption key is required")
+	}
+
+	s := &EncryptingStore{store: store, logger: logger}
+	for _, secret := range secrets {
+		if len(secret) < MinEncryptionKeyLength {
+			return nil, fmt.Errorf("encryption keys must be at least %d bytes", MinEncryptionKeyLength)
+		}
+
+		key := sha256.Sum256([]byte(secret))
+		block, err := aes.NewCipher(key[:])
+		if err != nil {
+			return nil, fmt.Errorf("failed to create cipher: %w", err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create AEAD: %w", err)
+		}
+
+		ek := encryptionKey{aead: aead}
+		id := sha256.Sum256(append([]byte("session-encryption-key-id\n"), key[:]...))
+		copy(ek.id[:], id[:encryptionIDSize])
+		s.keys = append(s.keys, ek)
+	}
+	return s, nil
+}
+
+// LoadEncryptionKeys returns the configured keys followed by those of the
+// key file, which holds one key per line; blank lines and lines starting
+// with # are ignored
+func LoadEncryptionKeys(keys []string, keyFile string) ([]string, error) {
+	secrets := append([]string(nil), keys...)
+	if keyFile == "" {
+		return secrets, nil
+	}
+
+	content, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read encryption key file: %w", err)
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		secrets = append(secrets, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read encryption key file: %w", err)
+	}
+	return secrets, nil
+}
+
+// encrypt marshals data and encrypts it for the session key
+func (s *EncryptingStore) encrypt(key string, data interface{}) (string, error) {
+	plaintext, err := json.Marshal(data)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal session data: %w", err)
+	}
+	return s.seal(key, plaintext)
+}
+
+// seal encrypts plaintext with the first key, bound to the session key
+func (s *EncryptingStore) seal(key string, plaintext []byte) (string, error) {
+	ek := s.keys[0]
+
+	nonce := make([]byte, ek.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	out := make([]byte, 0, encryptionIDSize+len(nonce)+len(plaintext)+ek.aead.Overhead())
+	out = append(out, ek.id[:]...)
+	out = append(out, nonce...)
+	out = ek.aead.Seal(out, nonce, plaintext, additionalData(ek.id, key))
+	return encryptedPrefix + base64.RawURLEncoding.EncodeToString(out), nil
+}
+
+// open decrypts a value sealed for the session key and reports whether it
+// was sealed with a key other than the first
+func (s *EncryptingStore) open(key, value string) ([]byte, bool, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(value, encryptedPrefix))
+	if err != nil || len(raw) < encryptionIDSize {
+		return nil, false, ErrDecryptionFailed
+	}
+
+	for i, ek := range s.keys {
+		if !bytes.Equal(raw[:encryptionIDSize], ek.id[:]) {
+			continue
+		}
+
+		nonceSize := ek.aead.NonceSize()
+		if len(raw) < encryptionIDSize+nonceSize {
+			return nil, false, ErrDecryptionFailed
+		}
+		nonce := raw[encryptionIDSize : encryptionIDSize+nonceSize]
+		plaintext, err := ek.aead.Open(nil, nonce, raw[encryptionIDSize+nonceSize:], additionalData(ek.id, key))
+		if err != nil {
+			return nil, false, ErrDecryptionFailed
+		}
+		return plaintext, i > 0, nil
+	}
+	return nil, false, ErrDecryptionFailed
+}
+
+// additionalData binds a value to the format, its key ID and the session key
+func additionalData(id [encryptionIDSize]byte, key string) []byte {
+	ad := make([]byte, 0, len(encryptedPrefix)+encryptionIDSize+len(key))
+	ad = append(ad, encryptedPrefix...)
+	ad = append(ad, id[:]...)
+	return append(ad, key...)
+}
+
+// Create encrypts the data and creates the session in the wrapped store
+func (s *EncryptingStore) Create(ctx context.Context, key string, data interface{}, ttl time.Duration) (string, error) {
+	value, err := s.encrypt(key, data)
+	if err != nil {
+		return "", err
+	}
+	return s.store.Create(ctx, key, value, ttl)
+}
+
+// CreateRaw encrypts raw bytes and creates the session in the wrapped store.
+// The sealed value is a string whatever it holds, so every store supports it.
+func (s *EncryptingStore) CreateRaw(ctx context.Context, key string, value []byte, ttl time.Duration) (string, error) {
+	sealed, err := s.seal(key, value)
+	if err != nil {
+		return "", err
+	}
+	return s.store.Create(ctx, key, sealed, ttl)
+}
+
+// Get retrieves and decrypts session data. Values stored before encryption
+// was enabled are read as is.
+func (s *EncryptingStore) Get(ctx context.Context, key string, data interface{}) error {
+	plaintext, err := s.GetRaw(ctx, key)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(plaintext, data); err != nil {
+		return fmt.Errorf("failed to unmarshal session data: %w", err)
+	}
+	return nil
+}
+
+// GetRaw retrieves and decrypts the bytes of a session: raw bytes stored
+// with CreateRaw or UpdateRaw, or JSON
+func (s *EncryptingStore) GetRaw(ctx context.Context, key string) ([]byte, error) {
+	var raw json.RawMessage
+	if err := s.store.Get(ctx, key, &raw); err != nil {
+		return nil, err
+	}
+
+	var value string
+	if err := json.Unmarshal(raw, &value); err != nil || !strings.HasPrefix(value, encryptedPrefix) {
+		// Plaintext written before encryption was enabled
+		s.reencrypt(ctx, key, raw)
+		return raw, nil
+	}
+
+	plaintext, stale, err := s.open(key, value)
+	if err != nil {
+		return nil, err
+	}
+	if stale {
+		s.reencrypt(ctx, key, plaintext)
+	}
+	return plaintext, nil
+}
+
+// reencrypt rewrites a session with the first key. Failures are logged and
+// retried on the next read.
+func (s *EncryptingStore) reencrypt(ctx context.Context, key string, plaintext []byte) {
+	value, err := s.seal(key, plaintext)
+	if err == nil {
+		err = s.store.Update(ctx, key, value)
+	}
+	if err != nil {
+		s.logger.Debug("Failed to re-encrypt session", zap.String("key", key), zap.Error(err))
+		return
+	}
+	s.logger.Debug("Session re-encrypted with the current key", zap.String("key", key))
+}
+
+// Update encrypts the data and updates the session in the wrapped store
+func (s *EncryptingStore) Update(ctx context.Context, key string, data interface{}) error {
+	value, err := s.encrypt(key, data)
+	if err != nil {
+		return err
+	}
+	return s.store.Update(ctx, key, value)
+}
+
+// UpdateRaw encrypts raw bytes and updates the session in the wrapped store
+func (s *EncryptingStore) UpdateRaw(ctx context.Context, key string, value []byte) error {
+	sealed, err := s.seal(key, value)
+	if err != nil {
+		return err
+	}
+	return s.store.Update(ctx, key, sealed)
+}
+
+// Delete removes a session by key
+func (s *EncryptingStore) Delete(ctx context.Context, key string) error {
+	return s.store.Delete(ctx, key)
+}
+
+// Exists checks if a session exists
+func (s *EncryptingStore) Exists(ctx context.Context, key string) (bool, error) {
+	return s.store.Exists(ctx, key)
+}
+
+// Refresh extends the TTL of a session
+func (s *EncryptingStore) Refresh(ctx context.Context, key string, ttl time.Duration) error {
+	return s.store.Refresh(ctx, key, ttl)
+}
+
+// SetMetadata attaches metadata to a session
+func (s *EncryptingStore) SetMetadata(ctx context.Context, key string, metadata *Metadata) error {
+	return s.store.SetMetadata(ctx, key, metadata)
+}
+
+// Touch records activity on a session
+func (s *EncryptingStore) Touch(ctx context.Context, key string, seen time.Time) error {
+	return s.store.Touch(ctx, key, seen)
+}
+
+// ListUserSessions returns the live sessions of a user
+func (s *EncryptingStore) ListUserSessions(ctx context.Context, userID string) ([]*SessionInfo, error) {
+	return s.store.ListUserSessions(ctx, userID)
+}
+
+// DeleteUserSessions deletes every session of a user
+func (s *EncryptingStore) DeleteUserSessions(ctx context.Context, userID string) (int, error) {
+	return s.store.DeleteUserSessions(ctx, userID)
+}
+
+// ListSessions returns one page of sessions with metadata
+func (s *EncryptingStore) ListSessions(ctx context.Context, cursor string, limit int) (*SessionPage, error) {
+	return s.store.ListSessions(ctx, cursor, limit)
+}
+
+// AddIndex adds a session to an index if the wrapped store supports indexes
+func (s *EncryptingStore) AddIndex(ctx context.Context, index, key string) error {
+	indexer, ok := s.store.(Indexer)
+	if !ok {
+		return ErrIndexNotSupported
+	}
+	return indexer.AddIndex(ctx, index, key)
+}
+
+// RemoveIndex removes a session from an index if the wrapped store supports indexes
+func (s *EncryptingStore) RemoveIndex(ctx context.Context, index, key string) error {
+	indexer, ok := s.store.(Indexer)
+	if !ok {
+		return ErrIndexNotSupported
+	}
+	return indexer.RemoveIndex(ctx, index, key)
+}
+
+// IndexedKeys lists the sessions in an index if the wrapped store supports indexes
+func (s *EncryptingStore) IndexedKeys(ctx context.Context, index string) ([]string, error) {
+	indexer, ok := s.store.(Indexer)
+	if !ok {
+		return nil, ErrIndexNotSupported
+	}
+	return indexer.IndexedKeys(ctx, index)
+}
+
+// Cleanup removes expired sessions
+func (s *EncryptingStore) Cleanup(ctx context.Context) error {
+	return s.store.Cleanup(ctx)
+}
+
+// Stats returns the statistics of the wrapped store
+func (s *EncryptingStore) Stats(ctx context.Context) (*Stats, error) {
+	return s.store.Stats(ctx)
+}
+
+// Close closes the wrapped store
+func (s *EncryptingStore) Close() error {
+	return s.store.Close()
+}
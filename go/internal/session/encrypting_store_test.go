@@ -0,0 +1,189 @@
+package session
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/session/memory"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/session/redis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+const (
+	oldEncryptionKey = "old-encryption-key-0123456789abcdef"
+	newEncryptionKey = "new-encryption-key-0123456789abcdef"
+)
+
+type tokenSession struct {
+	UserID       string `json:"user_id"`
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// newEncryptedRedis returns a Redis store, backed by miniredis, wrapped to
+// encrypt with keys
+func newEncryptedRedis(t *testing.T, mr *miniredis.Miniredis, keys ...string) *EncryptingStore {
+	client := goredis.NewClient(&goredis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	store, err := NewEncryptingStore(redis.NewStoreWithClient(client, "test:", zap.NewNop()), keys, zap.NewNop())
+	require.NoError(t, err)
+	return store
+}
+
+func TestEncryptingStore(t *testing.T) {
+	mr := miniredis.RunT(t)
+	store := newEncryptedRedis(t, mr, newEncryptionKey)
+	ctx := context.Background()
+
+	session := &tokenSession{UserID: "alice", AccessToken: "access-secret", RefreshToken: "refresh-secret"}
+	_, err := store.Create(ctx, "user:alice", session, time.Hour)
+	require.NoError(t, err)
+
+	// Nothing readable reaches Redis
+	raw, err := mr.Get("test:user:alice")
+	require.NoError(t, err)
+	assert.Contains(t, raw, encryptedPrefix)
+	assert.NotContains(t, raw, "access-secret")
+	assert.NotContains(t, raw, "alice")
+	assert.True(t, mr.TTL("test:user:alice") > 0)
+
+	var got tokenSession
+	require.NoError(t, store.Get(ctx, "user:alice", &got))
+	assert.Equal(t, *session, got)
+
+	session.AccessToken = "rotated-access"
+	require.NoError(t, store.Update(ctx, "user:alice", session))
+	require.NoError(t, store.Get(ctx, "user:alice", &got))
+	assert.Equal(t, "rotated-access", got.AccessToken)
+
+	// A value copied to another session key does not decrypt
+	mr.Set("test:user:mallory", raw)
+	err = store.Get(ctx, "user:mallory", &got)
+	assert.ErrorIs(t, err, ErrDecryptionFailed)
+
+	// Neither does a tampered value
+	tampered := []byte(raw)
+	tampered[len(tampered)/2] ^= 'A' ^ 'B'
+	mr.Set("test:user:alice", string(tampered))
+	assert.ErrorIs(t, store.Get(ctx, "user:alice", &got), ErrDecryptionFailed)
+
+	err = store.Get(ctx, "missing", &got)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "session not found")
+}
+
+func TestEncryptingStoreKeyRotation(t *testing.T) {
+	mr := miniredis.RunT(t)
+	ctx := context.Background()
+
+	old := newEncryptedRedis(t, mr, oldEncryptionKey)
+	_, err := old.Create(ctx, "user:alice", &tokenSession{UserID: "alice", AccessToken: "a"}, time.Hour)
+	require.NoError(t, err)
+	sealedWithOld, _ := mr.Get("test:user:alice")
+
+	// The new key is prepended; the old value is read and re-encrypted
+	rotated := newEncryptedRedis(t, mr, newEncryptionKey, oldEncryptionKey)
+	var got tokenSession
+	require.NoError(t, rotated.Get(ctx, "user:alice", &got))
+	assert.Equal(t, "a", got.AccessToken)
+
+	sealedWithNew, _ := mr.Get("test:user:alice")
+	assert.NotEqual(t, sealedWithOld, sealedWithNew)
+	assert.True(t, mr.TTL("test:user:alice") > 0)
+
+	// Once re-encrypted, the old key can be retired
+	current := newEncryptedRedis(t, mr, newEncryptionKey)
+	require.NoError(t, current.Get(ctx, "user:alice", &got))
+	assert.Equal(t, "alice", got.UserID)
+
+	// Values sealed only with the retired key can no longer be read
+	mr.Set("test:user:bob", sealedWithOld)
+	assert.ErrorIs(t, current.Get(ctx, "user:bob", &got), ErrDecryptionFailed)
+}
+
+func TestEncryptingStorePlaintextMigration(t *testing.T) {
+	mr := miniredis.RunT(t)
+	ctx := context.Background()
+
+	// A session written before encryption was enabled
+	mr.Set("test:user:alice", `{"user_id":"alice","access_token":"legacy"}`)
+	mr.SetTTL("test:user:alice", time.Hour)
+
+	store := newEncryptedRedis(t, mr, newEncryptionKey)
+	var got tokenSession
+	require.NoError(t, store.Get(ctx, "user:alice", &got))
+	assert.Equal(t, "legacy", got.AccessToken)
+
+	raw, _ := mr.Get("test:user:alice")
+	assert.Contains(t, raw, encryptedPrefix)
+	assert.NotContains(t, raw, "legacy")
+
+	require.NoError(t, store.Get(ctx, "user:alice", &got))
+	assert.Equal(t, "alice", got.UserID)
+}
+
+func TestEncryptingStoreDelegates(t *testing.T) {
+	inner := memory.NewStore(&memory.Config{}, zap.NewNop())
+	store, err := NewEncryptingStore(inner, []string{newEncryptionKey}, zap.NewNop())
+	require.NoError(t, err)
+	defer store.Close()
+	ctx := context.Background()
+
+	_, err = store.Create(ctx, "user:alice", &tokenSession{UserID: "alice"}, time.Hour)
+	require.NoError(t, err)
+	require.NoError(t, store.SetMetadata(ctx, "user:alice", &Metadata{UserID: "alice"}))
+	require.NoError(t, store.AddIndex(ctx, "sub:alice", "user:alice"))
+
+	keys, err := store.IndexedKeys(ctx, "sub:alice")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"user:alice"}, keys)
+
+	infos, err := store.ListUserSessions(ctx, "alice")
+	require.NoError(t, err)
+	assert.Len(t, infos, 1)
+
+	exists, err := store.Exists(ctx, "user:alice")
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	require.NoError(t, store.Delete(ctx, "user:alice"))
+	exists, err = store.Exists(ctx, "user:alice")
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func TestNewEncryptingStoreInvalidKeys(t *testing.T) {
+	inner := memory.NewStore(&memory.Config{}, zap.NewNop())
+	defer inner.Close()
+
+	_, err := NewEncryptingStore(inner, nil, zap.NewNop())
+	assert.Error(t, err)
+
+	_, err = NewEncryptingStore(inner, []string{"short"}, zap.NewNop())
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "at least 32 bytes")
+}
+
+func TestLoadEncryptionKeys(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "keys")
+	require.NoError(t, os.WriteFile(keyFile, []byte("# rotated 2026-10\n"+newEncryptionKey+"\n\n  "+oldEncryptionKey+"  \n"), 0600))
+
+	keys, err := LoadEncryptionKeys([]string{"configured-key-0123456789abcdefgh"}, keyFile)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"configured-key-0123456789abcdefgh", newEncryptionKey, oldEncryptionKey}, keys)
+
+	keys, err = LoadEncryptionKeys([]string{newEncryptionKey}, "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{newEncryptionKey}, keys)
+
+	_, err = LoadEncryptionKeys(nil, filepath.Join(t.TempDir(), "missing"))
+	assert.Error(t, err)
+}
@@ -2,11 +2,17 @@ package session
 
 import (
 	"fmt"
+	"net/http"
+	"strings"
 	"time"
 
 	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/config"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/session/cookie"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/session/cookiestore"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/session/file"
 	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/session/memory"
 	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/session/redis"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/session/sqlstore"
 	"go.uber.org/zap"
 )
 
@@ -22,46 +28,140 @@ func NewFactory(logger *zap.Logger) *Factory {
 	}
 }
 
-// CreateStore creates a session store based on the configuration
-func (f *Factory) CreateStore(config *config.SessionConfig) (Store, error) {
-	var store Store
-	var err error
+// init registers the built-in session stores
+func init() {
+	Register("redis", Backend{New: createRedisStore, Validate: validateRedisStore})
+	Register("memory", Backend{New: createMemoryStore})
+	Register("cookie", Backend{New: createCookieStore, Validate: validateCookieStore})
+	Register("file", Backend{New: createFileStore, Validate: validateFileStore})
+	Register("sql", Backend{New: createSQLStore, Validate: validateSQLStore})
+}
 
-	switch config.Store {
-	case "redis":
-		store, err = f.createRedisStore(config)
-	case "memory":
-		store, err = f.createMemoryStore(config)
-	default:
+// CreateStore creates the registered session store selected by the
+// configuration and wraps it with encryption, caching and metrics
+func (f *Factory) CreateStore(config *config.SessionConfig) (Store, error) {
+	registered, ok := lookupBackend(config.Store)
+	if !ok {
 		return nil, fmt.Errorf("unsupported session store type: %s", config.Store)
 	}
 
+	store, err := registered.New(config, f.logger)
 	if err != nil {
 		return nil, err
 	}
 
+	backend := store
+
+	if config.Encryption.Enabled {
+		store, err = f.wrapEncryption(store, config)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if config.Cache.Enabled {
+		store, err = f.wrapCache(store, backend, config)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	// Wrap with metrics
 	store = NewMetricsStore(store, config.Store)
 
 	return store, nil
 }
 
+// wrapEncryption wraps a store to encrypt session data at rest
+func (f *Factory) wrapEncryption(store Store, config *config.SessionConfig) (Store, error) {
+	if config.Store == "cookie" {
+		store.Close()
+		return nil, fmt.Errorf("session encryption is not supported with the cookie store, which encrypts sessions itself")
+	}
+
+	keys, err := LoadEncryptionKeys(config.Encryption.Keys, config.Encryption.KeyFile)
+	if err != nil {
+		store.Close()
+		return nil, fmt.Errorf("failed to load session encryption keys: %w", err)
+	}
+
+	encrypting, err := NewEncryptingStore(store, keys, f.logger)
+	if err != nil {
+		store.Close()
+		return nil, fmt.Errorf("failed to enable session encryption: %w", err)
+	}
+
+	f.logger.Info("Session encryption enabled", zap.Int("keys", len(keys)))
+	return encrypting, nil
+}
+
+// wrapCache puts an in-process cache in front of a store. Invalidations
+// are shared through Redis pub/sub when the backend is Redis; other
+// backends only invalidate locally.
+func (f *Factory) wrapCache(store, backend Store, config *config.SessionConfig) (Store, error) {
+	var invalidator CacheInvalidator
+	if redisStore, ok := backend.(*redis.Store); ok {
+		redisInvalidator, err := redis.NewInvalidator(redisStore, config.Cache.Channel, f.logger)
+		if err != nil {
+			store.Close()
+			return nil, fmt.Errorf("failed to create session cache invalidator: %w", err)
+		}
+		invalidator = redisInvalidator
+	} else if config.Store == "sql" {
+		f.logger.Warn("Session cache invalidation is local to this instance; other instances may serve changed sessions for up to the cache TTL",
+			zap.Duration("ttl", config.Cache.TTL),
+		)
+	}
+
+	cached, err := NewCachingStore(store, &CacheConfig{
+		Size: config.Cache.Size,
+		TTL:  config.Cache.TTL,
+	}, invalidator, config.Store, f.logger)
+	if err != nil {
+		store.Close()
+		return nil, fmt.Errorf("failed to create session cache: %w", err)
+	}
+
+	f.logger.Info("Session cache enabled",
+		zap.Int("size", config.Cache.Size),
+		zap.Duration("ttl", config.Cache.TTL),
+		zap.Bool("shared_invalidation", invalidator != nil),
+	)
+	return cached, nil
+}
+
 // createRedisStore creates a Redis session store
-func (f *Factory) createRedisStore(config *config.SessionConfig) (Store, error) {
+func createRedisStore(config *config.SessionConfig, logger *zap.Logger) (Store, error) {
 	redisConfig := &redis.Config{
-		URL:          config.Redis.URL,
-		Password:     config.Redis.Password,
-		DB:           config.Redis.DB,
-		KeyPrefix:    config.Redis.KeyPrefix,
-		PoolSize:     10,
-		MinIdleConns: 5,
-		DialTimeout:  5 * time.Second,
-		ReadTimeout:  3 * time.Second,
-		WriteTimeout: 3 * time.Second,
+		Mode:             config.Redis.Mode,
+		URL:              config.Redis.URL,
+		Addrs:            config.Redis.Addrs,
+		MasterName:       config.Redis.MasterName,
+		Username:         config.Redis.Username,
+		Password:         config.Redis.Password,
+		SentinelUsername: config.Redis.SentinelUsername,
+		SentinelPassword: config.Redis.SentinelPassword,
+		DB:               config.Redis.DB,
+		KeyPrefix:        config.Redis.KeyPrefix,
+		PoolSize:         config.Redis.PoolSize,
+		MinIdleConns:     config.Redis.MinIdleConns,
+		PoolTimeout:      config.Redis.PoolTimeout,
+		DialTimeout:      config.Redis.DialTimeout,
+		ReadTimeout:      config.Redis.ReadTimeout,
+		WriteTimeout:     config.Redis.WriteTimeout,
+		MaxRetries:       config.Redis.MaxRetries,
+		TLS: redis.TLSConfig{
+			Enabled:            config.Redis.TLS.Enabled,
+			CAFile:             config.Redis.TLS.CAFile,
+			CertFile:           config.Redis.TLS.CertFile,
+			KeyFile:            config.Redis.TLS.KeyFile,
+			ServerName:         config.Redis.TLS.ServerName,
+			InsecureSkipVerify: config.Redis.TLS.InsecureSkipVerify,
+		},
 	}
 
 	// Validate Redis configuration
-	if redisConfig.URL == "" {
+	if (redisConfig.Mode == "" || redisConfig.Mode == redis.ModeStandalone) && redisConfig.URL == "" {
 		return nil, fmt.Errorf("Redis URL is required for Redis session store")
 	}
 
@@ -69,13 +169,15 @@ func (f *Factory) createRedisStore(config *config.SessionConfig) (Store, error)
 		redisConfig.KeyPrefix = "session:"
 	}
 
-	store, err := redis.NewStore(redisConfig, f.logger)
+	store, err := redis.NewStore(redisConfig, logger)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create Redis session store: %w", err)
 	}
 
-	f.logger.Info("Redis session store created",
+	logger.Info("Redis session store created",
+		zap.String("mode", redisConfig.Mode),
 		zap.String("url", redisConfig.URL),
+		zap.Strings("addrs", redisConfig.Addrs),
 		zap.String("key_prefix", redisConfig.KeyPrefix),
 	)
 
@@ -83,38 +185,145 @@ func (f *Factory) createRedisStore(config *config.SessionConfig) (Store, error)
 }
 
 // createMemoryStore creates an in-memory session store
-func (f *Factory) createMemoryStore(config *config.SessionConfig) (Store, error) {
+func createMemoryStore(config *config.SessionConfig, logger *zap.Logger) (Store, error) {
 	memoryConfig := &memory.Config{
 		CleanupInterval: 5 * time.Minute,
 	}
 
-	store := memory.NewStore(memoryConfig, f.logger)
+	store := memory.NewStore(memoryConfig, logger)
 
-	f.logger.Info("Memory session store created",
+	logger.Info("Memory session store created",
 		zap.Duration("cleanup_interval", memoryConfig.CleanupInterval),
 	)
 
 	return store, nil
 }
 
+// createFileStore creates a session store persisted in a local database file
+func createFileStore(config *config.SessionConfig, logger *zap.Logger) (Store, error) {
+	fileConfig := &file.Config{
+		Path:            config.File.Path,
+		CleanupInterval: 5 * time.Minute,
+		OpenTimeout:     5 * time.Second,
+	}
+
+	if fileConfig.Path == "" {
+		return nil, fmt.Errorf("file path is required for file session store")
+	}
+
+	store, err := file.NewStore(fileConfig, logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create file session store: %w", err)
+	}
+
+	logger.Info("File session store created",
+		zap.String("path", fileConfig.Path),
+		zap.Duration("cleanup_interval", fileConfig.CleanupInterval),
+	)
+
+	return store, nil
+}
+
+// createSQLStore creates a session store on a relational database
+func createSQLStore(config *config.SessionConfig, logger *zap.Logger) (Store, error) {
+	sqlConfig := &sqlstore.Config{
+		Driver:          config.SQL.Driver,
+		DSN:             config.SQL.DSN,
+		MaxOpenConns:    config.SQL.MaxOpenConns,
+		MaxIdleConns:    config.SQL.MaxIdleConns,
+		ConnMaxLifetime: config.SQL.ConnMaxLifetime,
+		ConnMaxIdleTime: config.SQL.ConnMaxIdleTime,
+		CleanupInterval: 5 * time.Minute,
+	}
+
+	if sqlConfig.DSN == "" {
+		return nil, fmt.Errorf("SQL DSN is required for SQL session store")
+	}
+
+	store, err := sqlstore.NewStore(sqlConfig, logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create SQL session store: %w", err)
+	}
+
+	logger.Info("SQL session store created",
+		zap.String("driver", sqlConfig.Driver),
+		zap.Int("max_open_conns", sqlConfig.MaxOpenConns),
+		zap.Duration("cleanup_interval", sqlConfig.CleanupInterval),
+	)
+
+	return store, nil
+}
+
+// createCookieStore creates a stateless cookie session store with an optional
+// revocation denylist
+func createCookieStore(config *config.SessionConfig, logger *zap.Logger) (Store, error) {
+	var denylist cookiestore.Denylist
+	switch config.CookieStore.Denylist {
+	case "":
+		// Deleted sessions stay valid until they expire
+	case "memory":
+		denylist = memory.NewStore(memory.DefaultConfig(), logger)
+	case "redis":
+		denylistConfig := *config
+		denylistConfig.Redis.KeyPrefix = config.Redis.KeyPrefix + "denylist:"
+		redisStore, err := createRedisStore(&denylistConfig, logger)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create cookie store denylist: %w", err)
+		}
+		denylist = redisStore
+	default:
+		return nil, fmt.Errorf("unsupported cookie store denylist: %s", config.CookieStore.Denylist)
+	}
+
+	namePrefix := config.CookieStore.NamePrefix
+	if config.CookieHostPrefix && !strings.HasPrefix(namePrefix, cookie.HostPrefix) {
+		namePrefix = cookie.HostPrefix + namePrefix
+	}
+
+	sameSite := http.SameSiteLaxMode
+	if strings.EqualFold(config.CookieSameSite, "none") {
+		sameSite = http.SameSiteNoneMode
+	}
+
+	store, err := cookiestore.NewStore(&cookiestore.Config{
+		Keys:          config.CookieStore.Keys,
+		NamePrefix:    namePrefix,
+		MaxChunks:     config.CookieStore.MaxChunks,
+		Domain:        config.CookieDomain,
+		Path:          config.CookiePath,
+		Secure:        config.CookieSecure,
+		SameSite:      sameSite,
+		RevocationTTL: config.TTL,
+	}, denylist, logger)
+	if err != nil {
+		if denylist != nil {
+			denylist.Close()
+		}
+		return nil, fmt.Errorf("failed to create cookie session store: %w", err)
+	}
+
+	logger.Info("Cookie session store created",
+		zap.Int("keys", len(config.CookieStore.Keys)),
+		zap.String("denylist", config.CookieStore.Denylist),
+	)
+
+	return store, nil
+}
+
 // ValidateConfig validates session configuration
 func ValidateConfig(config *config.SessionConfig) error {
 	if config.Store == "" {
 		return fmt.Errorf("session store type is required")
 	}
 
-	switch config.Store {
-	case "redis":
-		if config.Redis.URL == "" {
-			return fmt.Errorf("Redis URL is required for Redis session store")
-		}
-		if config.Redis.DB < 0 || config.Redis.DB > 15 {
-			return fmt.Errorf("Redis DB must be between 0 and 15")
+	backend, ok := lookupBackend(config.Store)
+	if !ok {
+		return fmt.Errorf("unsupported session store type: %s (supported: %s)", config.Store, strings.Join(Backends(), ", "))
+	}
+	if backend.Validate != nil {
+		if err := backend.Validate(config); err != nil {
+			return err
 		}
-	case "memory":
-		// Memory store has no specific requirements
-	default:
-		return fmt.Errorf("unsupported session store type: %s (supported: redis, memory)", config.Store)
 	}
 
 	// Validate session configuration
@@ -140,4 +349,55 @@ func ValidateConfig(config *config.SessionConfig) error {
 	}
 
 	return nil
-}
\ No newline at end of file
+}
+
+// validateRedisStore validates the Redis session store configuration
+func validateRedisStore(config *config.SessionConfig) error {
+	switch config.Redis.Mode {
+	case "", redis.ModeStandalone:
+		if config.Redis.URL == "" {
+			return fmt.Errorf("Redis URL is required for Redis session store")
+		}
+	case redis.ModeSentinel:
+		if config.Redis.MasterName == "" || len(config.Redis.Addrs) == 0 {
+			return fmt.Errorf("Redis master name and sentinel addresses are required in sentinel mode")
+		}
+	case redis.ModeCluster:
+		if len(config.Redis.Addrs) == 0 {
+			return fmt.Errorf("Redis cluster addresses are required in cluster mode")
+		}
+	default:
+		return fmt.Errorf("unsupported Redis mode: %s", config.Redis.Mode)
+	}
+	if config.Redis.DB < 0 || config.Redis.DB > 15 {
+		return fmt.Errorf("Redis DB must be between 0 and 15")
+	}
+	return nil
+}
+
+// validateFileStore validates the file session store configuration
+func validateFileStore(config *config.SessionConfig) error {
+	if config.File.Path == "" {
+		return fmt.Errorf("file path is required for file session store")
+	}
+	return nil
+}
+
+// validateSQLStore validates the SQL session store configuration
+func validateSQLStore(config *config.SessionConfig) error {
+	if config.SQL.Driver == "" || config.SQL.DSN == "" {
+		return fmt.Errorf("SQL driver and DSN are required for SQL session store")
+	}
+	return nil
+}
+
+// validateCookieStore validates the cookie session store configuration
+func validateCookieStore(config *config.SessionConfig) error {
+	if len(config.CookieStore.Keys) == 0 {
+		return fmt.Errorf("encryption keys are required for cookie session store")
+	}
+	if config.CookieStore.Denylist == "redis" && config.Redis.URL == "" {
+		return fmt.Errorf("Redis URL is required for the cookie store Redis denylist")
+	}
+	return nil
+}
@@ -2,7 +2,9 @@ package session
 
 import (
 	"context"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/alicebob/miniredis/v2"
 	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/config"
@@ -67,6 +69,157 @@ func TestCreateRedisStore(t *testing.T) {
 	assert.NotNil(t, statsInterface)
 }
 
+func TestCreateRedisClusterStore(t *testing.T) {
+	s, err := miniredis.Run()
+	require.NoError(t, err)
+	defer s.Close()
+
+	factory := NewFactory(zap.NewNop())
+	store, err := factory.CreateStore(&config.SessionConfig{
+		Store: "redis",
+		Redis: config.RedisConfig{
+			Mode:      "cluster",
+			Addrs:     []string{s.Addr()},
+			KeyPrefix: "test:",
+			PoolSize:  4,
+		},
+	})
+	require.NoError(t, err)
+	defer store.Close()
+
+	_, err = store.Create(context.Background(), "session1", map[string]string{"id": "1"}, time.Hour)
+	require.NoError(t, err)
+	assert.True(t, s.Exists("test:session1"))
+}
+
+func TestCreateEncryptedStore(t *testing.T) {
+	factory := NewFactory(zap.NewNop())
+
+	store, err := factory.CreateStore(&config.SessionConfig{
+		Store:      "memory",
+		TTL:        time.Hour,
+		Encryption: config.EncryptionConfig{Enabled: true, Keys: []string{"0123456789abcdef0123456789abcdef"}},
+	})
+	require.NoError(t, err)
+	defer store.Close()
+
+	metricsStore, ok := store.(*MetricsStore)
+	require.True(t, ok)
+	_, ok = metricsStore.store.(*EncryptingStore)
+	assert.True(t, ok)
+
+	ctx := context.Background()
+	_, err = store.Create(ctx, "session1", map[string]string{"id": "1"}, time.Hour)
+	require.NoError(t, err)
+	var data map[string]string
+	require.NoError(t, store.Get(ctx, "session1", &data))
+	assert.Equal(t, "1", data["id"])
+
+	_, err = factory.CreateStore(&config.SessionConfig{
+		Store:      "memory",
+		TTL:        time.Hour,
+		Encryption: config.EncryptionConfig{Enabled: true, KeyFile: filepath.Join(t.TempDir(), "missing")},
+	})
+	assert.Error(t, err)
+}
+
+func TestCreateCachedRedisStore(t *testing.T) {
+	s, err := miniredis.Run()
+	require.NoError(t, err)
+	defer s.Close()
+
+	factory := NewFactory(zap.NewNop())
+	store, err := factory.CreateStore(&config.SessionConfig{
+		Store:      "redis",
+		Redis:      config.RedisConfig{URL: "redis://" + s.Addr(), KeyPrefix: "test:"},
+		Encryption: config.EncryptionConfig{Enabled: true, Keys: []string{"0123456789abcdef0123456789abcdef"}},
+		Cache:      config.SessionCacheConfig{Enabled: true, Size: 10, TTL: time.Minute, Channel: "test:invalidate"},
+	})
+	require.NoError(t, err)
+	defer store.Close()
+
+	// Cache in front of encryption in front of Redis
+	cached, ok := store.(*MetricsStore).store.(*CachingStore)
+	require.True(t, ok)
+	_, ok = cached.store.(*EncryptingStore)
+	assert.True(t, ok)
+	assert.NotNil(t, cached.invalidator)
+
+	ctx := context.Background()
+	_, err = store.Create(ctx, "session1", map[string]string{"id": "1"}, time.Hour)
+	require.NoError(t, err)
+	var data map[string]string
+	require.NoError(t, store.Get(ctx, "session1", &data))
+	assert.Equal(t, "1", data["id"])
+	assert.Equal(t, 1, cached.cache.len())
+}
+
+func TestCreateFileStore(t *testing.T) {
+	factory := NewFactory(zap.NewNop())
+
+	store, err := factory.CreateStore(&config.SessionConfig{
+		Store: "file",
+		TTL:   time.Hour,
+		File:  config.FileStoreConfig{Path: filepath.Join(t.TempDir(), "sessions.db")},
+	})
+	require.NoError(t, err)
+	defer store.Close()
+
+	_, err = store.Create(context.Background(), "session1", map[string]string{"id": "1"}, time.Hour)
+	require.NoError(t, err)
+	_, ok := store.(Indexer)
+	assert.True(t, ok)
+
+	_, err = factory.CreateStore(&config.SessionConfig{Store: "file", TTL: time.Hour})
+	assert.Error(t, err)
+}
+
+func TestCreateSQLStore(t *testing.T) {
+	factory := NewFactory(zap.NewNop())
+
+	store, err := factory.CreateStore(&config.SessionConfig{
+		Store: "sql",
+		TTL:   time.Hour,
+		SQL: config.SQLStoreConfig{
+			Driver: "sqlite",
+			DSN:    filepath.Join(t.TempDir(), "sessions.db"),
+		},
+	})
+	require.NoError(t, err)
+	defer store.Close()
+
+	_, err = store.Create(context.Background(), "session1", map[string]string{"id": "1"}, time.Hour)
+	require.NoError(t, err)
+	_, ok := store.(Indexer)
+	assert.True(t, ok)
+
+	_, err = factory.CreateStore(&config.SessionConfig{Store: "sql", TTL: time.Hour, SQL: config.SQLStoreConfig{Driver: "sqlite"}})
+	assert.Error(t, err)
+}
+
+func TestCreateCookieStore(t *testing.T) {
+	factory := NewFactory(zap.NewNop())
+
+	store, err := factory.CreateStore(&config.SessionConfig{
+		Store:      "cookie",
+		TTL:        time.Hour,
+		CookiePath: "/",
+		CookieStore: config.CookieStoreConfig{
+			Keys:     []string{"0123456789abcdef0123456789abcdef"},
+			Denylist: "memory",
+		},
+	})
+	require.NoError(t, err)
+	defer store.Close()
+
+	stats, err := store.Stats(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "cookie", stats.Store)
+
+	_, err = factory.CreateStore(&config.SessionConfig{Store: "cookie", TTL: time.Hour})
+	assert.Error(t, err)
+}
+
 func TestCreateRedisStoreWithoutURL(t *testing.T) {
 	logger := zap.NewNop()
 	factory := NewFactory(logger)
@@ -160,6 +313,33 @@ func TestValidateConfig(t *testing.T) {
 			expectError: true,
 			errorMsg:    "Redis URL is required",
 		},
+		{
+			name: "Redis sentinel without master name",
+			config: &config.SessionConfig{
+				Store:      "redis",
+				TTL:        3600,
+				CookieName: "session_id",
+				Redis: config.RedisConfig{
+					Mode:  "sentinel",
+					Addrs: []string{"sentinel:26379"},
+				},
+			},
+			expectError: true,
+			errorMsg:    "master name and sentinel addresses are required",
+		},
+		{
+			name: "Valid redis cluster config",
+			config: &config.SessionConfig{
+				Store:      "redis",
+				TTL:        3600,
+				CookieName: "session_id",
+				Redis: config.RedisConfig{
+					Mode:  "cluster",
+					Addrs: []string{"node1:6379", "node2:6379"},
+				},
+			},
+			expectError: false,
+		},
 		{
 			name: "Redis with invalid DB",
 			config: &config.SessionConfig{
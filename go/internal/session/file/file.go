@@ -0,0 +1,820 @@
+// Package file implements a session store persisted in a single bbolt
+// database file, for single-node deployments that need sessions to survive
+// restarts without running Redis.
+package file
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/session/meta"
+	bolt "go.etcd.io/bbolt"
+	"go.uber.org/zap"
+)
+
+// Buckets of the database. Secondary buckets hold composite keys joined with
+// a zero byte and empty values, so they can be range scanned by prefix.
+var (
+	bucketSessions   = []byte("sessions")    // key -> sessionData
+	bucketExpiry     = []byte("expiry")      // expiry time | key
+	bucketIndexes    = []byte("indexes")     // index \0 key
+	bucketKeyIndexes = []byte("key_indexes") // key \0 index
+	bucketCreated    = []byte("created")     // meta.SortKey -> key
+
+	allBuckets = [][]byte{bucketSessions, bucketExpiry, bucketIndexes, bucketKeyIndexes, bucketCreated}
+)
+
+// sessionData holds session information
+type sessionData struct {
+	Data      json.RawMessage `json:"data"`
+	ExpiresAt *time.Time      `json:"expires_at,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+	Meta      *meta.Metadata  `json:"meta,omitempty"`
+}
+
+// expired reports whether the session has expired at the given time
+func (d *sessionData) expired(now time.Time) bool {
+	return d.ExpiresAt != nil && now.After(*d.ExpiresAt)
+}
+
+// Stats holds session store statistics
+type Stats = meta.Stats
+
+// Config holds file session store configuration
+type Config struct {
+	// Path of the database file; its directory is created if missing
+	Path string
+	// CleanupInterval for removing expired sessions
+	CleanupInterval time.Duration
+	// OpenTimeout bounds the wait for the file lock held by another process
+	OpenTimeout time.Duration
+}
+
+// DefaultConfig returns a default file store configuration
+func DefaultConfig() *Config {
+	return &Config{
+		Path:            "data/sessions.db",
+		CleanupInterval: 5 * time.Minute,
+		OpenTimeout:     time.Second,
+	}
+}
+
+// Store implements session.Store using a bbolt database file. Every
+// operation runs in a single transaction, so updates are atomic and safe for
+// concurrent use.
+type Store struct {
+	db           *bolt.DB
+	path         string
+	logger       *zap.Logger
+	cleanupDone  chan struct{}
+	cleanupWG    sync.WaitGroup
+	closeOnce    sync.Once
+	totalCreated atomic.Int64
+	totalDeleted atomic.Int64
+}
+
+// NewStore opens or creates the session database
+func NewStore(config *Config, logger *zap.Logger) (*Store, error) {
+	if config == nil {
+		config = DefaultConfig()
+	}
+	if config.Path == "" {
+		return nil, fmt.Errorf("session database path is required")
+	}
+
+	if dir := filepath.Dir(config.Path); dir != "." {
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return nil, fmt.Errorf("failed to create session database directory: %w", err)
+		}
+	}
+
+	db, err := bolt.Open(config.Path, 0o600, &bolt.Options{Timeout: config.OpenTimeout})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open session database: %w", err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range allBuckets {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize session database: %w", err)
+	}
+
+	store := &Store{
+		db:          db,
+		path:        config.Path,
+		logger:      logger,
+		cleanupDone: make(chan struct{}),
+	}
+
+	// Start cleanup routine
+	if config.CleanupInterval > 0 {
+		store.startCleanup(config.CleanupInterval)
+	}
+
+	return store, nil
+}
+
+// startCleanup starts the background cleanup routine
+func (s *Store) startCleanup(interval time.Duration) {
+	s.cleanupWG.Add(1)
+	go func() {
+		defer s.cleanupWG.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := s.cleanup(); err != nil {
+					s.logger.Warn("Failed to clean up expired sessions", zap.Error(err))
+				}
+			case <-s.cleanupDone:
+				return
+			}
+		}
+	}()
+}
+
+// cleanup removes expired sessions, found in expiry order. The scan runs in
+// a read-only transaction so passes that find nothing do not write.
+func (s *Store) cleanup() error {
+	now := time.Now()
+	limit := expiryKey(now, "")
+
+	var expiredKeys []string
+	err := s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(bucketExpiry).Cursor()
+		for k, _ := c.First(); k != nil && bytes.Compare(k[:8], limit[:8]) <= 0; k, _ = c.Next() {
+			expiredKeys = append(expiredKeys, string(k[8:]))
+		}
+		return nil
+	})
+	if err != nil || len(expiredKeys) == 0 {
+		return err
+	}
+
+	deleted := 0
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		for _, key := range expiredKeys {
+			// Double-check expiration in case session was refreshed
+			session, err := getSession(tx, key)
+			if err != nil {
+				return err
+			}
+			if session == nil || !session.expired(now) {
+				continue
+			}
+			if err := deleteSession(tx, key, session); err != nil {
+				return err
+			}
+			deleted++
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if deleted > 0 {
+		s.totalDeleted.Add(int64(deleted))
+		s.logger.Debug("Cleaned up expired sessions", zap.Int("count", deleted))
+	}
+	return nil
+}
+
+// Create creates a new session with the given key and data
+func (s *Store) Create(ctx context.Context, key string, data interface{}, ttl time.Duration) (string, error) {
+	// Serialize data to JSON
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal session data: %w", err)
+	}
+
+	now := time.Now()
+	session := &sessionData{
+		Data:      jsonData,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	// Set expiration if TTL is provided
+	if ttl > 0 {
+		expiresAt := now.Add(ttl)
+		session.ExpiresAt = &expiresAt
+	}
+
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		existing, err := getSession(tx, key)
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			if !existing.expired(now) {
+				return fmt.Errorf("session already exists")
+			}
+			if err := deleteSession(tx, key, existing); err != nil {
+				return err
+			}
+		}
+		return putSession(tx, key, session, nil)
+	})
+	if err != nil {
+		return "", err
+	}
+	s.totalCreated.Add(1)
+
+	s.logger.Debug("Session created",
+		zap.String("key", key),
+		zap.Duration("ttl", ttl),
+	)
+
+	return key, nil
+}
+
+// Get retrieves session data by key
+func (s *Store) Get(ctx context.Context, key string, data interface{}) error {
+	var session *sessionData
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		session, err = getSession(tx, key)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if session == nil {
+		return meta.ErrNotFound
+	}
+
+	// Check if session is expired
+	if session.expired(time.Now()) {
+		s.deleteExpired(key)
+		return meta.ErrExpired
+	}
+
+	// Deserialize JSON data
+	if err := json.Unmarshal(session.Data, data); err != nil {
+		return fmt.Errorf("failed to unmarshal session data: %w", err)
+	}
+
+	s.logger.Debug("Session retrieved", zap.String("key", key))
+	return nil
+}
+
+// Update updates existing session data
+func (s *Store) Update(ctx context.Context, key string, data interface{}) error {
+	// Serialize new data
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to marshal session data: %w", err)
+	}
+
+	err = s.updateSession(key, func(session *sessionData) {
+		session.Data = jsonData
+	})
+	if err != nil {
+		return err
+	}
+
+	s.logger.Debug("Session updated", zap.String("key", key))
+	return nil
+}
+
+// Delete removes a session by key
+func (s *Store) Delete(ctx context.Context, key string) error {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		session, err := getSession(tx, key)
+		if err != nil {
+			return err
+		}
+		if session == nil {
+			return meta.ErrNotFound
+		}
+		return deleteSession(tx, key, session)
+	})
+	if err != nil {
+		return err
+	}
+	s.totalDeleted.Add(1)
+
+	s.logger.Debug("Session deleted", zap.String("key", key))
+	return nil
+}
+
+// Exists checks if a session exists
+func (s *Store) Exists(ctx context.Context, key string) (bool, error) {
+	var session *sessionData
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		session, err = getSession(tx, key)
+		return err
+	})
+	if err != nil {
+		return false, err
+	}
+	if session == nil {
+		return false, nil
+	}
+
+	// Check if session is expired
+	if session.expired(time.Now()) {
+		s.deleteExpired(key)
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// Refresh extends the TTL of a session
+func (s *Store) Refresh(ctx context.Context, key string, ttl time.Duration) error {
+	err := s.updateSession(key, func(session *sessionData) {
+		if ttl > 0 {
+			expiresAt := time.Now().Add(ttl)
+			session.ExpiresAt = &expiresAt
+		} else {
+			session.ExpiresAt = nil // No expiration
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	s.logger.Debug("Session TTL refreshed",
+		zap.String("key", key),
+		zap.Duration("ttl", ttl),
+	)
+	return nil
+}
+
+// updateSession applies fn to a live session and stores the result
+func (s *Store) updateSession(key string, fn func(session *sessionData)) error {
+	expired := false
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		session, err := getSession(tx, key)
+		if err != nil {
+			return err
+		}
+		if session == nil {
+			return meta.ErrNotFound
+		}
+
+		now := time.Now()
+		if session.expired(now) {
+			expired = true
+			return deleteSession(tx, key, session)
+		}
+
+		updated := *session
+		fn(&updated)
+		updated.UpdatedAt = now
+		return putSession(tx, key, &updated, session)
+	})
+	if err != nil {
+		return err
+	}
+	if expired {
+		s.totalDeleted.Add(1)
+		return meta.ErrExpired
+	}
+	return nil
+}
+
+// deleteExpired removes a session found expired on read
+func (s *Store) deleteExpired(key string) {
+	deleted := false
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		session, err := getSession(tx, key)
+		if err != nil || session == nil || !session.expired(time.Now()) {
+			return err
+		}
+		deleted = true
+		return deleteSession(tx, key, session)
+	})
+	if err != nil {
+		s.logger.Debug("Failed to delete expired session", zap.Error(err), zap.String("key", key))
+		return
+	}
+	if deleted {
+		s.totalDeleted.Add(1)
+	}
+}
+
+// AddIndex associates an existing session key with the index
+func (s *Store) AddIndex(ctx context.Context, index, key string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		session, err := getSession(tx, key)
+		if err != nil {
+			return err
+		}
+		if session == nil {
+			return meta.ErrNotFound
+		}
+
+		return putIndex(tx, index, key)
+	})
+}
+
+// RemoveIndex removes a session key from the index
+func (s *Store) RemoveIndex(ctx context.Context, index, key string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return deleteIndex(tx, index, key)
+	})
+}
+
+// IndexedKeys returns the keys of the live sessions in the index
+func (s *Store) IndexedKeys(ctx context.Context, index string) ([]string, error) {
+	keys := make([]string, 0)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		now := time.Now()
+		for _, key := range scanPrefix(tx.Bucket(bucketIndexes), index) {
+			session, err := getSession(tx, key)
+			if err != nil {
+				return err
+			}
+			if session != nil && !session.expired(now) {
+				keys = append(keys, key)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// SetMetadata attaches owner and client metadata to an existing session
+func (s *Store) SetMetadata(ctx context.Context, key string, metadata *meta.Metadata) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		session, err := getSession(tx, key)
+		if err != nil {
+			return err
+		}
+		if session == nil || session.expired(time.Now()) {
+			return meta.ErrNotFound
+		}
+
+		m := *metadata
+		m.Handle = ""
+		if session.Meta != nil {
+			m.Handle = session.Meta.Handle
+			if !session.Meta.CreatedAt.IsZero() {
+				m.CreatedAt = session.Meta.CreatedAt
+			}
+		}
+		if m.Handle == "" {
+			handle, err := meta.NewHandle()
+			if err != nil {
+				return err
+			}
+			m.Handle = handle
+		}
+		if m.CreatedAt.IsZero() {
+			m.CreatedAt = session.CreatedAt
+		}
+		if m.LastSeen.IsZero() {
+			m.LastSeen = time.Now()
+		}
+
+		updated := *session
+		updated.Meta = &m
+		return putSession(tx, key, &updated, session)
+	})
+}
+
+// Touch records activity on a session that has metadata. The session is
+// rewritten at most once per meta.TouchInterval, since every write is synced
+// to disk.
+func (s *Store) Touch(ctx context.Context, key string, seen time.Time) error {
+	stale := false
+	err := s.db.View(func(tx *bolt.Tx) error {
+		session, err := getSession(tx, key)
+		if err != nil {
+			return err
+		}
+		stale = session != nil && session.Meta != nil && seen.Sub(session.Meta.LastSeen) >= meta.TouchInterval
+		return nil
+	})
+	if err != nil || !stale {
+		return err
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		session, err := getSession(tx, key)
+		if err != nil || session == nil || session.Meta == nil {
+			return err
+		}
+
+		updated := *session
+		m := *session.Meta
+		m.LastSeen = seen
+		updated.Meta = &m
+		return putSession(tx, key, &updated, session)
+	})
+}
+
+// ListUserSessions returns the live sessions of a user, oldest first
+func (s *Store) ListUserSessions(ctx context.Context, userID string) ([]*meta.Info, error) {
+	infos := make([]*meta.Info, 0)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		now := time.Now()
+		for _, key := range scanPrefix(tx.Bucket(bucketIndexes), meta.UserIndex(userID)) {
+			session, err := getSession(tx, key)
+			if err != nil {
+				return err
+			}
+			if session != nil && session.Meta != nil && !session.expired(now) {
+				infos = append(infos, sessionInfo(key, session))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sortInfos(infos)
+	return infos, nil
+}
+
+// DeleteUserSessions deletes every session of a user
+func (s *Store) DeleteUserSessions(ctx context.Context, userID string) (int, error) {
+	deleted, removed := 0, 0
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		now := time.Now()
+		for _, key := range scanPrefix(tx.Bucket(bucketIndexes), meta.UserIndex(userID)) {
+			session, err := getSession(tx, key)
+			if err != nil {
+				return err
+			}
+			if session == nil {
+				continue
+			}
+			if !session.expired(now) {
+				deleted++
+			}
+			if err := deleteSession(tx, key, session); err != nil {
+				return err
+			}
+			removed++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	s.totalDeleted.Add(int64(removed))
+
+	s.logger.Debug("User sessions deleted", zap.String("user_id", userID), zap.Int("count", deleted))
+	return deleted, nil
+}
+
+// ListSessions returns sessions with metadata, oldest first
+func (s *Store) ListSessions(ctx context.Context, cursor string, limit int) (*meta.Page, error) {
+	after, err := meta.DecodeCursor(cursor)
+	if err != nil {
+		return nil, err
+	}
+	limit = meta.PageSize(limit)
+
+	page := &meta.Page{Sessions: make([]*meta.Info, 0)}
+	err = s.db.View(func(tx *bolt.Tx) error {
+		now := time.Now()
+		c := tx.Bucket(bucketCreated).Cursor()
+
+		var k, v []byte
+		if after == "" {
+			k, v = c.First()
+		} else {
+			k, v = c.Seek([]byte(after))
+			if k != nil && string(k) == after {
+				k, v = c.Next()
+			}
+		}
+
+		for ; k != nil; k, v = c.Next() {
+			session, err := getSession(tx, string(v))
+			if err != nil {
+				return err
+			}
+			if session == nil || session.Meta == nil || session.expired(now) {
+				continue
+			}
+			if len(page.Sessions) == limit {
+				last := page.Sessions[limit-1]
+				page.NextCursor = meta.EncodeCursor(meta.SortKey(last.CreatedAt, last.Handle))
+				return nil
+			}
+			page.Sessions = append(page.Sessions, sessionInfo(string(v), session))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// sessionInfo builds the listing entry of a session with metadata
+func sessionInfo(key string, session *sessionData) *meta.Info {
+	info := &meta.Info{Key: key, Metadata: *session.Meta}
+	if session.ExpiresAt != nil {
+		expiresAt := *session.ExpiresAt
+		info.ExpiresAt = &expiresAt
+	}
+	return info
+}
+
+// sortInfos orders sessions by creation time, then handle
+func sortInfos(infos []*meta.Info) {
+	sort.Slice(infos, func(i, j int) bool {
+		return meta.SortKey(infos[i].CreatedAt, infos[i].Handle) < meta.SortKey(infos[j].CreatedAt, infos[j].Handle)
+	})
+}
+
+// Close stops the cleanup routine and closes the database
+func (s *Store) Close() error {
+	var err error
+	s.closeOnce.Do(func() {
+		close(s.cleanupDone)
+		s.cleanupWG.Wait()
+
+		err = s.db.Close()
+		s.logger.Debug("File session store closed", zap.String("path", s.path))
+	})
+	return err
+}
+
+// Cleanup manually triggers cleanup of expired sessions
+func (s *Store) Cleanup(ctx context.Context) error {
+	return s.cleanup()
+}
+
+// Stats returns session store statistics
+func (s *Store) Stats(ctx context.Context) (*Stats, error) {
+	var active int64
+	var size int64
+	err := s.db.View(func(tx *bolt.Tx) error {
+		active = int64(tx.Bucket(bucketSessions).Stats().KeyN)
+		size = tx.Size()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Stats{
+		ActiveSessions: active,
+		TotalCreated:   s.totalCreated.Load(),
+		TotalDeleted:   s.totalDeleted.Load(),
+		Store:          "file",
+		Info:           fmt.Sprintf("path=%s size_bytes=%d", s.path, size),
+	}, nil
+}
+
+// getSession reads a session record; nil if missing
+func getSession(tx *bolt.Tx, key string) (*sessionData, error) {
+	value := tx.Bucket(bucketSessions).Get([]byte(key))
+	if value == nil {
+		return nil, nil
+	}
+
+	var session sessionData
+	if err := json.Unmarshal(value, &session); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal session record: %w", err)
+	}
+	return &session, nil
+}
+
+// putSession writes a session record and keeps the expiry and creation order
+// buckets and the user and handle indexes in step with it. old is the record
+// being replaced.
+func putSession(tx *bolt.Tx, key string, session, old *sessionData) error {
+	value, err := json.Marshal(session)
+	if err != nil {
+		return fmt.Errorf("failed to marshal session record: %w", err)
+	}
+	if err := tx.Bucket(bucketSessions).Put([]byte(key), value); err != nil {
+		return err
+	}
+
+	if old != nil {
+		if err := removeSecondary(tx, key, old); err != nil {
+			return err
+		}
+	}
+
+	if session.ExpiresAt != nil {
+		if err := tx.Bucket(bucketExpiry).Put(expiryKey(*session.ExpiresAt, key), nil); err != nil {
+			return err
+		}
+	}
+	if session.Meta != nil {
+		if err := putIndex(tx, meta.UserIndex(session.Meta.UserID), key); err != nil {
+			return err
+		}
+		if err := putIndex(tx, meta.HandleIndex(session.Meta.Handle), key); err != nil {
+			return err
+		}
+		if err := tx.Bucket(bucketCreated).Put([]byte(meta.SortKey(session.Meta.CreatedAt, session.Meta.Handle)), []byte(key)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteSession removes a session record and its index entries
+func deleteSession(tx *bolt.Tx, key string, session *sessionData) error {
+	if err := tx.Bucket(bucketSessions).Delete([]byte(key)); err != nil {
+		return err
+	}
+	if err := removeSecondary(tx, key, session); err != nil {
+		return err
+	}
+
+	for _, index := range scanPrefix(tx.Bucket(bucketKeyIndexes), key) {
+		if err := deleteIndex(tx, index, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// putIndex associates a key with an index
+func putIndex(tx *bolt.Tx, index, key string) error {
+	if err := tx.Bucket(bucketIndexes).Put(joinKey(index, key), nil); err != nil {
+		return err
+	}
+	return tx.Bucket(bucketKeyIndexes).Put(joinKey(key, index), nil)
+}
+
+// deleteIndex drops a key from an index
+func deleteIndex(tx *bolt.Tx, index, key string) error {
+	if err := tx.Bucket(bucketIndexes).Delete(joinKey(index, key)); err != nil {
+		return err
+	}
+	return tx.Bucket(bucketKeyIndexes).Delete(joinKey(key, index))
+}
+
+// removeSecondary drops the expiry, creation order, user and handle index
+// entries of a record
+func removeSecondary(tx *bolt.Tx, key string, session *sessionData) error {
+	if session.ExpiresAt != nil {
+		if err := tx.Bucket(bucketExpiry).Delete(expiryKey(*session.ExpiresAt, key)); err != nil {
+			return err
+		}
+	}
+	if session.Meta != nil {
+		if err := deleteIndex(tx, meta.UserIndex(session.Meta.UserID), key); err != nil {
+			return err
+		}
+		if err := deleteIndex(tx, meta.HandleIndex(session.Meta.Handle), key); err != nil {
+			return err
+		}
+		if err := tx.Bucket(bucketCreated).Delete([]byte(meta.SortKey(session.Meta.CreatedAt, session.Meta.Handle))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// joinKey builds a composite key of a prefix and a member
+func joinKey(prefix, member string) []byte {
+	return []byte(prefix + "\x00" + member)
+}
+
+// scanPrefix returns the members stored under a composite key prefix
+func scanPrefix(bucket *bolt.Bucket, prefix string) []string {
+	p := joinKey(prefix, "")
+	var members []string
+	c := bucket.Cursor()
+	for k, _ := c.Seek(p); k != nil && bytes.HasPrefix(k, p); k, _ = c.Next() {
+		members = append(members, string(k[len(p):]))
+	}
+	return members
+}
+
+// expiryKey orders sessions by expiry time: big-endian Unix nanoseconds
+// followed by the session key
+func expiryKey(expiresAt time.Time, key string) []byte {
+	k := make([]byte, 8, 8+len(key))
+	binary.BigEndian.PutUint64(k, uint64(expiresAt.UnixNano()))
+	return append(k, key...)
+}
@@ -0,0 +1,414 @@
+package file
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/session/meta"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	bolt "go.etcd.io/bbolt"
+	"go.uber.org/zap"
+)
+
+// TestData represents test session data
+type TestData struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+func newTestStore(t *testing.T, path string) *Store {
+	if path == "" {
+		path = filepath.Join(t.TempDir(), "sessions.db")
+	}
+	store, err := NewStore(&Config{Path: path, OpenTimeout: time.Second}, zap.NewNop())
+	require.NoError(t, err)
+	return store
+}
+
+func TestDefaultConfig(t *testing.T) {
+	config := DefaultConfig()
+	assert.Equal(t, 5*time.Minute, config.CleanupInterval)
+	assert.NotEmpty(t, config.Path)
+}
+
+func TestNewStore(t *testing.T) {
+	t.Run("creates the directory", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "nested", "dir", "sessions.db")
+		store, err := NewStore(&Config{Path: path, CleanupInterval: time.Minute}, zap.NewNop())
+		require.NoError(t, err)
+		assert.FileExists(t, path)
+		assert.NoError(t, store.Close())
+		assert.NoError(t, store.Close(), "Close is idempotent")
+	})
+
+	t.Run("without path", func(t *testing.T) {
+		_, err := NewStore(&Config{}, zap.NewNop())
+		assert.Error(t, err)
+	})
+
+	t.Run("file locked by another store", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "sessions.db")
+		store := newTestStore(t, path)
+		defer store.Close()
+
+		_, err := NewStore(&Config{Path: path, OpenTimeout: 50 * time.Millisecond}, zap.NewNop())
+		assert.Error(t, err)
+	})
+}
+
+func TestStoreOperations(t *testing.T) {
+	store := newTestStore(t, "")
+	defer store.Close()
+
+	ctx := context.Background()
+	testData := &TestData{ID: "123", Name: "Test User", Email: "test@example.com"}
+
+	t.Run("Create and Get", func(t *testing.T) {
+		sessionID, err := store.Create(ctx, "session1", testData, time.Hour)
+		require.NoError(t, err)
+		assert.Equal(t, "session1", sessionID)
+
+		var retrieved TestData
+		require.NoError(t, store.Get(ctx, "session1", &retrieved))
+		assert.Equal(t, *testData, retrieved)
+
+		_, err = store.Create(ctx, "session1", testData, time.Hour)
+		assert.EqualError(t, err, "session already exists")
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		updated := &TestData{ID: "123", Name: "Updated User", Email: "updated@example.com"}
+		require.NoError(t, store.Update(ctx, "session1", updated))
+
+		var retrieved TestData
+		require.NoError(t, store.Get(ctx, "session1", &retrieved))
+		assert.Equal(t, *updated, retrieved)
+	})
+
+	t.Run("Exists", func(t *testing.T) {
+		exists, err := store.Exists(ctx, "session1")
+		require.NoError(t, err)
+		assert.True(t, exists)
+
+		exists, err = store.Exists(ctx, "missing")
+		require.NoError(t, err)
+		assert.False(t, exists)
+	})
+
+	t.Run("Refresh", func(t *testing.T) {
+		require.NoError(t, store.Refresh(ctx, "session1", 2*time.Hour))
+		assert.Error(t, store.Refresh(ctx, "missing", time.Hour))
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		require.NoError(t, store.Delete(ctx, "session1"))
+
+		var retrieved TestData
+		assert.EqualError(t, store.Get(ctx, "session1", &retrieved), "session not found")
+		assert.EqualError(t, store.Delete(ctx, "session1"), "session not found")
+		assert.EqualError(t, store.Update(ctx, "session1", testData), "session not found")
+	})
+}
+
+func TestPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sessions.db")
+	ctx := context.Background()
+
+	store := newTestStore(t, path)
+	_, err := store.Create(ctx, "session1", &TestData{ID: "123"}, time.Hour)
+	require.NoError(t, err)
+	require.NoError(t, store.SetMetadata(ctx, "session1", &meta.Metadata{UserID: "alice"}))
+	require.NoError(t, store.AddIndex(ctx, "sub:alice", "session1"))
+	require.NoError(t, store.Close())
+
+	reopened := newTestStore(t, path)
+	defer reopened.Close()
+
+	var retrieved TestData
+	require.NoError(t, reopened.Get(ctx, "session1", &retrieved))
+	assert.Equal(t, "123", retrieved.ID)
+
+	keys, err := reopened.IndexedKeys(ctx, "sub:alice")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"session1"}, keys)
+
+	infos, err := reopened.ListUserSessions(ctx, "alice")
+	require.NoError(t, err)
+	assert.Len(t, infos, 1)
+}
+
+func TestSessionExpiration(t *testing.T) {
+	store := newTestStore(t, "")
+	defer store.Close()
+
+	ctx := context.Background()
+	_, err := store.Create(ctx, "short", &TestData{ID: "1"}, 50*time.Millisecond)
+	require.NoError(t, err)
+	_, err = store.Create(ctx, "forever", &TestData{ID: "2"}, 0)
+	require.NoError(t, err)
+
+	time.Sleep(100 * time.Millisecond)
+
+	var retrieved TestData
+	assert.EqualError(t, store.Get(ctx, "short", &retrieved), "session expired")
+	assert.EqualError(t, store.Get(ctx, "short", &retrieved), "session not found")
+	require.NoError(t, store.Get(ctx, "forever", &retrieved))
+
+	// An expired key can be created again
+	_, err = store.Create(ctx, "recreated", &TestData{ID: "3"}, 50*time.Millisecond)
+	require.NoError(t, err)
+	time.Sleep(100 * time.Millisecond)
+	_, err = store.Create(ctx, "recreated", &TestData{ID: "3"}, time.Hour)
+	assert.NoError(t, err)
+}
+
+func TestCleanup(t *testing.T) {
+	store := newTestStore(t, "")
+	defer store.Close()
+
+	ctx := context.Background()
+	for i := 0; i < 5; i++ {
+		_, err := store.Create(ctx, fmt.Sprintf("expiring%d", i), &TestData{ID: "1"}, 50*time.Millisecond)
+		require.NoError(t, err)
+		require.NoError(t, store.AddIndex(ctx, "sub:alice", fmt.Sprintf("expiring%d", i)))
+	}
+	_, err := store.Create(ctx, "live", &TestData{ID: "2"}, time.Hour)
+	require.NoError(t, err)
+
+	time.Sleep(100 * time.Millisecond)
+	require.NoError(t, store.Cleanup(ctx))
+
+	stats, err := store.Stats(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), stats.ActiveSessions)
+	assert.Equal(t, int64(5), stats.TotalDeleted)
+
+	// Index entries go with their sessions
+	err = store.db.View(func(tx *bolt.Tx) error {
+		assert.Zero(t, tx.Bucket(bucketIndexes).Stats().KeyN)
+		assert.Zero(t, tx.Bucket(bucketKeyIndexes).Stats().KeyN)
+		assert.Equal(t, 1, tx.Bucket(bucketExpiry).Stats().KeyN)
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+func TestAutomaticCleanup(t *testing.T) {
+	store, err := NewStore(&Config{
+		Path:            filepath.Join(t.TempDir(), "sessions.db"),
+		CleanupInterval: 50 * time.Millisecond,
+	}, zap.NewNop())
+	require.NoError(t, err)
+	defer store.Close()
+
+	ctx := context.Background()
+	_, err = store.Create(ctx, "expiring", &TestData{ID: "1"}, 10*time.Millisecond)
+	require.NoError(t, err)
+
+	assert.Eventually(t, func() bool {
+		stats, err := store.Stats(ctx)
+		return err == nil && stats.ActiveSessions == 0
+	}, time.Second, 20*time.Millisecond)
+}
+
+func TestStats(t *testing.T) {
+	store := newTestStore(t, "")
+	defer store.Close()
+
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		_, err := store.Create(ctx, fmt.Sprintf("session%d", i), &TestData{ID: "1"}, time.Hour)
+		require.NoError(t, err)
+	}
+	require.NoError(t, store.Delete(ctx, "session0"))
+
+	statsInterface, err := store.Stats(ctx)
+	require.NoError(t, err)
+	stats := statsInterface
+	assert.Equal(t, int64(2), stats.ActiveSessions)
+	assert.Equal(t, int64(3), stats.TotalCreated)
+	assert.Equal(t, int64(1), stats.TotalDeleted)
+	assert.Equal(t, "file", stats.Store)
+}
+
+func TestConcurrentAccess(t *testing.T) {
+	store := newTestStore(t, "")
+	defer store.Close()
+
+	ctx := context.Background()
+	_, err := store.Create(ctx, "shared", &TestData{ID: "0"}, time.Hour)
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			key := fmt.Sprintf("session%d", i)
+			_, err := store.Create(ctx, key, &TestData{ID: key}, time.Hour)
+			assert.NoError(t, err)
+			assert.NoError(t, store.Update(ctx, "shared", &TestData{ID: key}))
+
+			var retrieved TestData
+			assert.NoError(t, store.Get(ctx, key, &retrieved))
+			assert.NoError(t, store.Get(ctx, "shared", &retrieved))
+		}(i)
+	}
+	wg.Wait()
+
+	stats, err := store.Stats(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(11), stats.ActiveSessions)
+}
+
+func TestIndexes(t *testing.T) {
+	store := newTestStore(t, "")
+	defer store.Close()
+
+	ctx := context.Background()
+	_, err := store.Create(ctx, "user:alice", &TestData{ID: "alice"}, time.Hour)
+	require.NoError(t, err)
+	_, err = store.Create(ctx, "user:alice2", &TestData{ID: "alice"}, time.Hour)
+	require.NoError(t, err)
+
+	require.NoError(t, store.AddIndex(ctx, "sub:alice", "user:alice"))
+	require.NoError(t, store.AddIndex(ctx, "sub:alice", "user:alice2"))
+	require.NoError(t, store.AddIndex(ctx, "sid:abc", "user:alice"))
+	assert.Error(t, store.AddIndex(ctx, "sub:alice", "missing"))
+
+	keys, err := store.IndexedKeys(ctx, "sub:alice")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"user:alice", "user:alice2"}, keys)
+
+	require.NoError(t, store.RemoveIndex(ctx, "sub:alice", "user:alice2"))
+	keys, err = store.IndexedKeys(ctx, "sub:alice")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"user:alice"}, keys)
+
+	require.NoError(t, store.Delete(ctx, "user:alice"))
+	keys, err = store.IndexedKeys(ctx, "sid:abc")
+	require.NoError(t, err)
+	assert.Empty(t, keys)
+}
+
+func TestUserSessions(t *testing.T) {
+	store := newTestStore(t, "")
+	defer store.Close()
+
+	ctx := context.Background()
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, userID := range []string{"alice", "bob", "alice"} {
+		key := fmt.Sprintf("session%d", i)
+		_, err := store.Create(ctx, key, &TestData{ID: userID}, time.Hour)
+		require.NoError(t, err)
+		require.NoError(t, store.SetMetadata(ctx, key, &meta.Metadata{
+			UserID:    userID,
+			Device:    "desktop",
+			CreatedAt: created.Add(time.Duration(i) * time.Minute),
+		}))
+	}
+	// Sessions without metadata are not listed
+	_, err := store.Create(ctx, "auth:state", &TestData{ID: "state"}, time.Hour)
+	require.NoError(t, err)
+
+	t.Run("ListUserSessions", func(t *testing.T) {
+		infos, err := store.ListUserSessions(ctx, "alice")
+		require.NoError(t, err)
+		require.Len(t, infos, 2)
+		assert.Equal(t, "session0", infos[0].Key)
+		assert.Equal(t, "session2", infos[1].Key)
+		assert.NotNil(t, infos[0].ExpiresAt)
+	})
+
+	t.Run("SetMetadata keeps CreatedAt", func(t *testing.T) {
+		require.NoError(t, store.SetMetadata(ctx, "session0", &meta.Metadata{UserID: "alice", Device: "mobile"}))
+		infos, err := store.ListUserSessions(ctx, "alice")
+		require.NoError(t, err)
+		assert.Equal(t, created, infos[0].CreatedAt.UTC())
+		assert.Equal(t, "mobile", infos[0].Device)
+	})
+
+	t.Run("User sessions share the session index", func(t *testing.T) {
+		keys, err := store.IndexedKeys(ctx, meta.UserIndex("alice"))
+		require.NoError(t, err)
+		assert.Equal(t, []string{"session0", "session2"}, keys)
+
+		require.NoError(t, store.SetMetadata(ctx, "session2", &meta.Metadata{UserID: "carol"}))
+		infos, err := store.ListUserSessions(ctx, "alice")
+		require.NoError(t, err)
+		require.Len(t, infos, 1)
+		infos, err = store.ListUserSessions(ctx, "carol")
+		require.NoError(t, err)
+		require.Len(t, infos, 1)
+		assert.Equal(t, "session2", infos[0].Key)
+		handle := infos[0].Handle
+		assert.NotEmpty(t, handle)
+		keys, err = store.IndexedKeys(ctx, meta.HandleIndex(handle))
+		require.NoError(t, err)
+		assert.Equal(t, []string{"session2"}, keys)
+		require.NoError(t, store.SetMetadata(ctx, "session2", &meta.Metadata{UserID: "alice"}))
+		infos, err = store.ListUserSessions(ctx, "alice")
+		require.NoError(t, err)
+		handles := make([]string, len(infos))
+		for i, info := range infos {
+			handles[i] = info.Handle
+		}
+		assert.Contains(t, handles, handle, "the handle survives metadata updates")
+	})
+
+	t.Run("Touch is rate limited", func(t *testing.T) {
+		infos, err := store.ListUserSessions(ctx, "bob")
+		require.NoError(t, err)
+		lastSeen := infos[0].LastSeen
+
+		require.NoError(t, store.Touch(ctx, "session1", lastSeen.Add(time.Second)))
+		infos, err = store.ListUserSessions(ctx, "bob")
+		require.NoError(t, err)
+		assert.True(t, lastSeen.Equal(infos[0].LastSeen))
+
+		seen := lastSeen.Add(2 * meta.TouchInterval)
+		require.NoError(t, store.Touch(ctx, "session1", seen))
+		infos, err = store.ListUserSessions(ctx, "bob")
+		require.NoError(t, err)
+		assert.True(t, seen.Equal(infos[0].LastSeen))
+	})
+
+	t.Run("ListSessions pages", func(t *testing.T) {
+		page, err := store.ListSessions(ctx, "", 2)
+		require.NoError(t, err)
+		require.Len(t, page.Sessions, 2)
+		assert.Equal(t, "session0", page.Sessions[0].Key)
+		assert.Equal(t, "session1", page.Sessions[1].Key)
+		require.NotEmpty(t, page.NextCursor)
+
+		page, err = store.ListSessions(ctx, page.NextCursor, 2)
+		require.NoError(t, err)
+		require.Len(t, page.Sessions, 1)
+		assert.Equal(t, "session2", page.Sessions[0].Key)
+		assert.Empty(t, page.NextCursor)
+
+		_, err = store.ListSessions(ctx, "bogus", 2)
+		assert.ErrorIs(t, err, meta.ErrInvalidCursor)
+	})
+
+	t.Run("DeleteUserSessions", func(t *testing.T) {
+		deleted, err := store.DeleteUserSessions(ctx, "alice")
+		require.NoError(t, err)
+		assert.Equal(t, 2, deleted)
+
+		infos, err := store.ListUserSessions(ctx, "alice")
+		require.NoError(t, err)
+		assert.Empty(t, infos)
+
+		page, err := store.ListSessions(ctx, "", 10)
+		require.NoError(t, err)
+		require.Len(t, page.Sessions, 1)
+		assert.Equal(t, "bob", page.Sessions[0].UserID)
+	})
+}
@@ -0,0 +1,16 @@
+package memory
+
+import (
+	"testing"
+
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/session/sessiontest"
+	"go.uber.org/zap"
+)
+
+func TestConformance(t *testing.T) {
+	sessiontest.Run(t, sessiontest.Config{
+		NewStore: func(t *testing.T) sessiontest.Store {
+			return NewStore(&Config{}, zap.NewNop())
+		},
+	})
+}
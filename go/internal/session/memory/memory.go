@@ -4,9 +4,11 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"sort"
 	"sync"
 	"time"
 
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/session/meta"
 	"go.uber.org/zap"
 )
 
@@ -14,6 +16,10 @@ import (
 type Store struct {
 	mu           sync.RWMutex
 	sessions     map[string]*sessionData
+	// indexes maps an index to its session keys; keyIndexes is the reverse
+	// mapping used to drop index entries when a session goes away
+	indexes      map[string]map[string]struct{}
+	keyIndexes   map[string]map[string]struct{}
 	logger       *zap.Logger
 	cleanupDone  chan struct{}
 	cleanupTimer *time.Timer
@@ -29,6 +35,12 @@ type sessionData struct {
 	ExpiresAt *time.Time      `json:"expires_at,omitempty"`
 	CreatedAt time.Time       `json:"created_at"`
 	UpdatedAt time.Time       `json:"updated_at"`
+	Meta      *meta.Metadata  `json:"meta,omitempty"`
+}
+
+// expired reports whether the session has expired at the given time
+func (d *sessionData) expired(now time.Time) bool {
+	return d.ExpiresAt != nil && now.After(*d.ExpiresAt)
 }
 
 // sessionStats tracks session statistics
@@ -38,13 +50,7 @@ type sessionStats struct {
 }
 
 // Stats holds session store statistics
-type Stats struct {
-	ActiveSessions int64  `json:"active_sessions"`
-	TotalCreated   int64  `json:"total_created"`
-	TotalDeleted   int64  `json:"total_deleted"`
-	Store          string `json:"store"`
-	Info           string `json:"info,omitempty"`
-}
+type Stats = meta.Stats
 
 // Config holds memory session store configuration
 type Config struct {
@@ -67,7 +73,9 @@ func NewStore(config *Config, logger *zap.Logger) *Store {
 
 	store := &Store{
 		sessions:    make(map[string]*sessionData),
-		logger:      logger,
+		indexes:     make(map[string]map[string]struct{}),
+		keyIndexes:   make(map[string]map[string]struct{}),
+		logger:       logger,
 		cleanupDone: make(chan struct{}),
 	}
 
@@ -110,8 +118,7 @@ func (s *Store) cleanup() {
 			// Double-check expiration in case session was updated
 			if session, exists := s.sessions[key]; exists {
 				if session.ExpiresAt != nil && now.After(*session.ExpiresAt) {
-					delete(s.sessions, key)
-					s.stats.totalDeleted++
+					s.deleteLocked(key)
 				}
 			}
 		}
@@ -128,17 +135,29 @@ func (s *Store) Create(ctx context.Context, key string, data interface{}, ttl ti
 	if err != nil {
 		return "", fmt.Errorf("failed to marshal session data: %w", err)
 	}
+	return s.create(key, jsonData, ttl)
+}
 
+// CreateRaw creates a new session holding value as is
+func (s *Store) CreateRaw(ctx context.Context, key string, value []byte, ttl time.Duration) (string, error) {
+	return s.create(key, append([]byte(nil), value...), ttl)
+}
+
+// create stores a new session holding value
+func (s *Store) create(key string, value []byte, ttl time.Duration) (string, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	// Check if session already exists
-	if _, exists := s.sessions[key]; exists {
-		return "", fmt.Errorf("session already exists")
+	// Check if session already exists; an expired one is replaced
+	if existing, exists := s.sessions[key]; exists {
+		if !existing.expired(time.Now()) {
+			return "", fmt.Errorf("session already exists")
+		}
+		s.deleteLocked(key)
 	}
 
 	session := &sessionData{
-		Data:      jsonData,
+		Data:      value,
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
 	}
@@ -160,33 +179,64 @@ func (s *Store) Create(ctx context.Context, key string, data interface{}, ttl ti
 	return key, nil
 }
 
+// deleteLocked removes a session and its index entries; the caller holds mu
+func (s *Store) deleteLocked(key string) {
+	s.stats.totalDeleted++
+
+	for index := range s.keyIndexes[key] {
+		delete(s.indexes[index], key)
+		if len(s.indexes[index]) == 0 {
+			delete(s.indexes, index)
+		}
+	}
+	delete(s.keyIndexes, key)
+	delete(s.sessions, key)
+}
+
 // Get retrieves session data by key
 func (s *Store) Get(ctx context.Context, key string, data interface{}) error {
+	value, err := s.get(key)
+	if err != nil {
+		return err
+	}
+
+	// Deserialize JSON data
+	if err := json.Unmarshal(value, data); err != nil {
+		return fmt.Errorf("failed to unmarshal session data: %w", err)
+	}
+	return nil
+}
+
+// GetRaw returns a copy of the stored bytes of a session
+func (s *Store) GetRaw(ctx context.Context, key string) ([]byte, error) {
+	value, err := s.get(key)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(nil), value...), nil
+}
+
+// get returns the stored bytes of a live session
+func (s *Store) get(key string) ([]byte, error) {
 	s.mu.RLock()
 	session, exists := s.sessions[key]
 	s.mu.RUnlock()
 
 	if !exists {
-		return fmt.Errorf("session not found")
+		return nil, meta.ErrNotFound
 	}
 
 	// Check if session is expired
 	if session.ExpiresAt != nil && time.Now().After(*session.ExpiresAt) {
 		// Remove expired session
 		s.mu.Lock()
-		delete(s.sessions, key)
-		s.stats.totalDeleted++
+		s.deleteLocked(key)
 		s.mu.Unlock()
-		return fmt.Errorf("session expired")
-	}
-
-	// Deserialize JSON data
-	if err := json.Unmarshal(session.Data, data); err != nil {
-		return fmt.Errorf("failed to unmarshal session data: %w", err)
+		return nil, meta.ErrExpired
 	}
 
 	s.logger.Debug("Session retrieved", zap.String("key", key))
-	return nil
+	return session.Data, nil
 }
 
 // Update updates existing session data
@@ -196,24 +246,32 @@ func (s *Store) Update(ctx context.Context, key string, data interface{}) error
 	if err != nil {
 		return fmt.Errorf("failed to marshal session data: %w", err)
 	}
+	return s.update(key, jsonData)
+}
 
+// UpdateRaw replaces the data of an existing session with value as is
+func (s *Store) UpdateRaw(ctx context.Context, key string, value []byte) error {
+	return s.update(key, append([]byte(nil), value...))
+}
+
+// update replaces the data of a live session with value
+func (s *Store) update(key string, value []byte) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	session, exists := s.sessions[key]
 	if !exists {
-		return fmt.Errorf("session not found")
+		return meta.ErrNotFound
 	}
 
 	// Check if session is expired
 	if session.ExpiresAt != nil && time.Now().After(*session.ExpiresAt) {
-		delete(s.sessions, key)
-		s.stats.totalDeleted++
-		return fmt.Errorf("session expired")
+		s.deleteLocked(key)
+		return meta.ErrExpired
 	}
 
 	// Update data and timestamp
-	session.Data = jsonData
+	session.Data = value
 	session.UpdatedAt = time.Now()
 
 	s.logger.Debug("Session updated", zap.String("key", key))
@@ -226,11 +284,10 @@ func (s *Store) Delete(ctx context.Context, key string) error {
 	defer s.mu.Unlock()
 
 	if _, exists := s.sessions[key]; !exists {
-		return fmt.Errorf("session not found")
+		return meta.ErrNotFound
 	}
 
-	delete(s.sessions, key)
-	s.stats.totalDeleted++
+	s.deleteLocked(key)
 
 	s.logger.Debug("Session deleted", zap.String("key", key))
 	return nil
@@ -250,8 +307,7 @@ func (s *Store) Exists(ctx context.Context, key string) (bool, error) {
 	if session.ExpiresAt != nil && time.Now().After(*session.ExpiresAt) {
 		// Remove expired session
 		s.mu.Lock()
-		delete(s.sessions, key)
-		s.stats.totalDeleted++
+		s.deleteLocked(key)
 		s.mu.Unlock()
 		return false, nil
 	}
@@ -266,14 +322,13 @@ func (s *Store) Refresh(ctx context.Context, key string, ttl time.Duration) erro
 
 	session, exists := s.sessions[key]
 	if !exists {
-		return fmt.Errorf("session not found")
+		return meta.ErrNotFound
 	}
 
 	// Check if session is expired
 	if session.ExpiresAt != nil && time.Now().After(*session.ExpiresAt) {
-		delete(s.sessions, key)
-		s.stats.totalDeleted++
-		return fmt.Errorf("session expired")
+		s.deleteLocked(key)
+		return meta.ErrExpired
 	}
 
 	// Update expiration
@@ -292,6 +347,208 @@ func (s *Store) Refresh(ctx context.Context, key string, ttl time.Duration) erro
 	return nil
 }
 
+// AddIndex associates an existing session key with the index
+func (s *Store) AddIndex(ctx context.Context, index, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.sessions[key]; !exists {
+		return meta.ErrNotFound
+	}
+
+	s.addIndexLocked(index, key)
+	return nil
+}
+
+// addIndexLocked associates a key with an index; the caller holds mu
+func (s *Store) addIndexLocked(index, key string) {
+	if s.indexes[index] == nil {
+		s.indexes[index] = make(map[string]struct{})
+	}
+	s.indexes[index][key] = struct{}{}
+
+	if s.keyIndexes[key] == nil {
+		s.keyIndexes[key] = make(map[string]struct{})
+	}
+	s.keyIndexes[key][index] = struct{}{}
+}
+
+// RemoveIndex removes a session key from the index
+func (s *Store) RemoveIndex(ctx context.Context, index, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.removeIndexLocked(index, key)
+	return nil
+}
+
+// removeIndexLocked drops a key from an index; the caller holds mu
+func (s *Store) removeIndexLocked(index, key string) {
+	delete(s.indexes[index], key)
+	if len(s.indexes[index]) == 0 {
+		delete(s.indexes, index)
+	}
+	delete(s.keyIndexes[key], index)
+	if len(s.keyIndexes[key]) == 0 {
+		delete(s.keyIndexes, key)
+	}
+}
+
+// IndexedKeys returns the keys of the live sessions in the index
+func (s *Store) IndexedKeys(ctx context.Context, index string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	now := time.Now()
+	keys := make([]string, 0, len(s.indexes[index]))
+	for key := range s.indexes[index] {
+		session, exists := s.sessions[key]
+		if !exists || (session.ExpiresAt != nil && now.After(*session.ExpiresAt)) {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys, nil
+}
+
+// SetMetadata attaches owner and client metadata to an existing session
+func (s *Store) SetMetadata(ctx context.Context, key string, metadata *meta.Metadata) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, exists := s.sessions[key]
+	if !exists || session.expired(time.Now()) {
+		return meta.ErrNotFound
+	}
+
+	m := *metadata
+	m.Handle = ""
+	if session.Meta != nil {
+		m.Handle = session.Meta.Handle
+		if !session.Meta.CreatedAt.IsZero() {
+			m.CreatedAt = session.Meta.CreatedAt
+		}
+		s.removeIndexLocked(meta.UserIndex(session.Meta.UserID), key)
+	}
+	if m.Handle == "" {
+		handle, err := meta.NewHandle()
+		if err != nil {
+			return err
+		}
+		m.Handle = handle
+	}
+	if m.CreatedAt.IsZero() {
+		m.CreatedAt = session.CreatedAt
+	}
+	if m.LastSeen.IsZero() {
+		m.LastSeen = time.Now()
+	}
+	session.Meta = &m
+	s.addIndexLocked(meta.UserIndex(m.UserID), key)
+	s.addIndexLocked(meta.HandleIndex(m.Handle), key)
+
+	return nil
+}
+
+// Touch records activity on a session that has metadata
+func (s *Store) Touch(ctx context.Context, key string, seen time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if session, exists := s.sessions[key]; exists && session.Meta != nil {
+		session.Meta.LastSeen = seen
+	}
+	return nil
+}
+
+// ListUserSessions returns the live sessions of a user, oldest first
+func (s *Store) ListUserSessions(ctx context.Context, userID string) ([]*meta.Info, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	now := time.Now()
+	index := s.indexes[meta.UserIndex(userID)]
+	infos := make([]*meta.Info, 0, len(index))
+	for key := range index {
+		if session := s.sessions[key]; session != nil && session.Meta != nil && !session.expired(now) {
+			infos = append(infos, sessionInfo(key, session))
+		}
+	}
+	sortInfos(infos)
+
+	return infos, nil
+}
+
+// DeleteUserSessions deletes every session of a user
+func (s *Store) DeleteUserSessions(ctx context.Context, userID string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	deleted := 0
+	for key := range s.indexes[meta.UserIndex(userID)] {
+		if session := s.sessions[key]; session != nil && !session.expired(now) {
+			deleted++
+		}
+		s.deleteLocked(key)
+	}
+
+	s.logger.Debug("User sessions deleted", zap.String("user_id", userID), zap.Int("count", deleted))
+	return deleted, nil
+}
+
+// ListSessions returns sessions with metadata, oldest first
+func (s *Store) ListSessions(ctx context.Context, cursor string, limit int) (*meta.Page, error) {
+	after, err := meta.DecodeCursor(cursor)
+	if err != nil {
+		return nil, err
+	}
+	limit = meta.PageSize(limit)
+
+	s.mu.RLock()
+	now := time.Now()
+	infos := make([]*meta.Info, 0)
+	for key, session := range s.sessions {
+		if session.Meta == nil || session.expired(now) {
+			continue
+		}
+		if after != "" && meta.SortKey(session.Meta.CreatedAt, session.Meta.Handle) <= after {
+			continue
+		}
+		infos = append(infos, sessionInfo(key, session))
+	}
+	s.mu.RUnlock()
+
+	sortInfos(infos)
+
+	page := &meta.Page{Sessions: infos}
+	if len(infos) > limit {
+		page.Sessions = infos[:limit]
+		last := page.Sessions[limit-1]
+		page.NextCursor = meta.EncodeCursor(meta.SortKey(last.CreatedAt, last.Handle))
+	}
+	return page, nil
+}
+
+// sessionInfo builds the listing entry of a session with metadata
+func sessionInfo(key string, session *sessionData) *meta.Info {
+	info := &meta.Info{Key: key, Metadata: *session.Meta}
+	if session.ExpiresAt != nil {
+		expiresAt := *session.ExpiresAt
+		info.ExpiresAt = &expiresAt
+	}
+	return info
+}
+
+// sortInfos orders sessions by creation time, then handle
+func sortInfos(infos []*meta.Info) {
+	sort.Slice(infos, func(i, j int) bool {
+		return meta.SortKey(infos[i].CreatedAt, infos[i].Handle) < meta.SortKey(infos[j].CreatedAt, infos[j].Handle)
+	})
+}
+
 // Close closes the store and stops cleanup routine
 func (s *Store) Close() error {
 	s.timerMu.Lock()
@@ -306,6 +563,8 @@ func (s *Store) Close() error {
 	
 	// Clear all sessions
 	s.sessions = make(map[string]*sessionData)
+	s.indexes = make(map[string]map[string]struct{})
+	s.keyIndexes = make(map[string]map[string]struct{})
 	
 	s.logger.Debug("Memory session store closed")
 	return nil
@@ -318,7 +577,7 @@ func (s *Store) Cleanup(ctx context.Context) error {
 }
 
 // Stats returns session store statistics
-func (s *Store) Stats(ctx context.Context) (interface{}, error) {
+func (s *Store) Stats(ctx context.Context) (*Stats, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
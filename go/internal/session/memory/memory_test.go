@@ -5,6 +5,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/session/meta"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
@@ -342,14 +343,12 @@ func TestStats(t *testing.T) {
 	require.NoError(t, err)
 	assert.NotNil(t, statsInterface)
 
-	// Type assert to verify structure
-	if stats, ok := statsInterface.(*Stats); ok {
-		assert.Equal(t, "memory", stats.Store)
-		assert.Equal(t, int64(1), stats.ActiveSessions)
-		assert.Equal(t, int64(2), stats.TotalCreated)
-		assert.Equal(t, int64(1), stats.TotalDeleted)
-		assert.Contains(t, stats.Info, "active_sessions=1")
-	}
+	stats := statsInterface
+	assert.Equal(t, "memory", stats.Store)
+	assert.Equal(t, int64(1), stats.ActiveSessions)
+	assert.Equal(t, int64(2), stats.TotalCreated)
+	assert.Equal(t, int64(1), stats.TotalDeleted)
+	assert.Contains(t, stats.Info, "active_sessions=1")
 }
 
 func TestClose(t *testing.T) {
@@ -381,4 +380,157 @@ func TestClose(t *testing.T) {
 	default:
 		t.Error("cleanup channel should be closed")
 	}
-}
\ No newline at end of file
+}
+func TestIndexes(t *testing.T) {
+	store := NewStore(&Config{CleanupInterval: 0}, zap.NewNop())
+	defer store.Close()
+
+	ctx := context.Background()
+	testData := TestData{ID: "user123"}
+
+	for _, key := range []string{"s1", "s2", "s3"} {
+		_, err := store.Create(ctx, key, testData, time.Hour)
+		require.NoError(t, err)
+	}
+	_, err := store.Create(ctx, "short_lived", testData, 50*time.Millisecond)
+	require.NoError(t, err)
+
+	require.NoError(t, store.AddIndex(ctx, "sub:alice", "s1"))
+	require.NoError(t, store.AddIndex(ctx, "sub:alice", "s2"))
+	require.NoError(t, store.AddIndex(ctx, "sub:alice", "short_lived"))
+	require.NoError(t, store.AddIndex(ctx, "sid:idp-1", "s1"))
+	require.NoError(t, store.AddIndex(ctx, "sub:bob", "s3"))
+
+	assert.Error(t, store.AddIndex(ctx, "sub:alice", "missing"), "only existing sessions can be indexed")
+
+	time.Sleep(100 * time.Millisecond)
+
+	keys, err := store.IndexedKeys(ctx, "sub:alice")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"s1", "s2"}, keys, "expired sessions are not returned")
+
+	// Deleting a session removes it from every index
+	require.NoError(t, store.Delete(ctx, "s1"))
+	keys, err = store.IndexedKeys(ctx, "sub:alice")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"s2"}, keys)
+	assert.NotContains(t, store.indexes, "sid:idp-1")
+
+	require.NoError(t, store.RemoveIndex(ctx, "sub:alice", "s2"))
+	keys, err = store.IndexedKeys(ctx, "sub:alice")
+	require.NoError(t, err)
+	assert.Empty(t, keys)
+
+	// Cleanup drops index entries of expired sessions
+	require.NoError(t, store.Cleanup(ctx))
+	assert.NotContains(t, store.keyIndexes, "short_lived")
+	assert.NotContains(t, store.indexes, "sub:alice")
+
+	keys, err = store.IndexedKeys(ctx, "sub:bob")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"s3"}, keys)
+}
+
+func TestUserSessions(t *testing.T) {
+	store := NewStore(&Config{CleanupInterval: 0}, zap.NewNop())
+	defer store.Close()
+
+	ctx := context.Background()
+	base := time.Now().Add(-time.Hour)
+
+	for i, key := range []string{"a1", "a2", "b1", "anonymous"} {
+		_, err := store.Create(ctx, key, TestData{ID: key}, time.Hour)
+		require.NoError(t, err)
+		if key == "anonymous" {
+			continue
+		}
+		require.NoError(t, store.SetMetadata(ctx, key, &meta.Metadata{
+			UserID:    key[:1],
+			IP:        "192.0.2.1",
+			UserAgent: "curl/8.0",
+			Device:    "cli",
+			CreatedAt: base.Add(time.Duration(i) * time.Minute),
+		}))
+	}
+
+	assert.Error(t, store.SetMetadata(ctx, "missing", &meta.Metadata{UserID: "a"}))
+
+	sessions, err := store.ListUserSessions(ctx, "a")
+	require.NoError(t, err)
+	require.Len(t, sessions, 2)
+	assert.Equal(t, "a1", sessions[0].Key)
+	assert.Equal(t, "a2", sessions[1].Key)
+	assert.Equal(t, "192.0.2.1", sessions[0].IP)
+	assert.NotNil(t, sessions[0].ExpiresAt)
+
+	// The listing reads the session index that back-channel logout uses
+	keys, err := store.IndexedKeys(ctx, meta.UserIndex("a"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a1", "a2"}, keys)
+
+	// A session moves to the index of its new user
+	require.NoError(t, store.SetMetadata(ctx, "b1", &meta.Metadata{UserID: "c"}))
+	sessions, err = store.ListUserSessions(ctx, "b")
+	require.NoError(t, err)
+	assert.Empty(t, sessions)
+	sessions, err = store.ListUserSessions(ctx, "c")
+	require.NoError(t, err)
+	require.Len(t, sessions, 1)
+	assert.Equal(t, "b1", sessions[0].Key)
+	handle := sessions[0].Handle
+	assert.NotEmpty(t, handle)
+	keys, err = store.IndexedKeys(ctx, meta.HandleIndex(handle))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"b1"}, keys)
+	require.NoError(t, store.SetMetadata(ctx, "b1", &meta.Metadata{UserID: "b"}))
+	sessions, err = store.ListUserSessions(ctx, "b")
+	require.NoError(t, err)
+	handles := make([]string, len(sessions))
+	for i, info := range sessions {
+		handles[i] = info.Handle
+	}
+	assert.Contains(t, handles, handle, "the handle survives metadata updates")
+
+	// Touch updates last seen but keeps the creation time
+	seen := time.Now().Add(time.Minute)
+	require.NoError(t, store.Touch(ctx, "a1", seen))
+	require.NoError(t, store.Touch(ctx, "anonymous", seen), "sessions without metadata are ignored")
+	sessions, err = store.ListUserSessions(ctx, "a")
+	require.NoError(t, err)
+	assert.True(t, sessions[0].LastSeen.Equal(seen))
+	assert.True(t, sessions[0].CreatedAt.Equal(base))
+
+	// Pages only include sessions with metadata, oldest first
+	page, err := store.ListSessions(ctx, "", 2)
+	require.NoError(t, err)
+	require.Len(t, page.Sessions, 2)
+	assert.Equal(t, "a1", page.Sessions[0].Key)
+	assert.Equal(t, "a2", page.Sessions[1].Key)
+	require.NotEmpty(t, page.NextCursor)
+
+	page, err = store.ListSessions(ctx, page.NextCursor, 2)
+	require.NoError(t, err)
+	require.Len(t, page.Sessions, 1)
+	assert.Equal(t, "b1", page.Sessions[0].Key)
+	assert.Empty(t, page.NextCursor)
+
+	_, err = store.ListSessions(ctx, "not a cursor", 2)
+	assert.ErrorIs(t, err, meta.ErrInvalidCursor)
+
+	// Revocation deletes every session of the user only
+	deleted, err := store.DeleteUserSessions(ctx, "a")
+	require.NoError(t, err)
+	assert.Equal(t, 2, deleted)
+
+	exists, err := store.Exists(ctx, "a1")
+	require.NoError(t, err)
+	assert.False(t, exists)
+	exists, err = store.Exists(ctx, "b1")
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	sessions, err = store.ListUserSessions(ctx, "a")
+	require.NoError(t, err)
+	assert.Empty(t, sessions)
+	assert.NotContains(t, store.indexes, meta.UserIndex("a"))
+}
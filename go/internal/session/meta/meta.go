@@ -0,0 +1,163 @@
+// Package meta defines the per-session metadata shared by the session stores.
+// It has no dependencies on the store implementations so that both the
+// session package and the stores can use it.
+package meta
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DefaultPageSize is used when a listing is requested without a limit
+const DefaultPageSize = 100
+
+// MaxPageSize bounds a single listing page
+const MaxPageSize = 1000
+
+// TouchInterval is how often activity on a session is recorded; Touch calls
+// within the interval of the recorded last seen time are skipped, since
+// Touch runs on every authenticated request
+const TouchInterval = time.Minute
+
+// ErrNotFound is returned for a session that does not exist
+var ErrNotFound = errors.New("session not found")
+
+// ErrExpired is returned by Get for an expired session; it matches ErrNotFound
+var ErrExpired error = expiredError{}
+
+type expiredError struct{}
+
+func (expiredError) Error() string { return "session expired" }
+
+func (expiredError) Is(target error) bool { return target == ErrNotFound }
+
+// ErrRawNotSupported is returned by the raw value methods of store wrappers
+// when the wrapped store does not keep raw values
+var ErrRawNotSupported = errors.New("session store does not support raw values")
+
+// ErrInvalidCursor is returned for a listing cursor that was not issued by a store
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Metadata describes the owner and client of a session
+type Metadata struct {
+	// Handle identifies the session in listings without revealing its key.
+	// Stores assign it when metadata is first set and ignore the argument's.
+	Handle    string    `json:"handle"`
+	UserID    string    `json:"user_id"`
+	IP        string    `json:"ip,omitempty"`
+	UserAgent string    `json:"user_agent,omitempty"`
+	Device    string    `json:"device,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+// Stats holds session store statistics; counters a store does not track are -1
+type Stats struct {
+	ActiveSessions int64  `json:"active_sessions"`
+	TotalCreated   int64  `json:"total_created"`
+	TotalDeleted   int64  `json:"total_deleted"`
+	Store          string `json:"store"`
+	Info           string `json:"info,omitempty"`
+}
+
+// Info describes a stored session
+type Info struct {
+	// Key is the session key for callers in this process; it is never
+	// serialized, so listings do not hand out usable session IDs
+	Key string `json:"-"`
+	Metadata
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// Page is one page of a session listing
+type Page struct {
+	Sessions []*Info `json:"sessions"`
+	// NextCursor continues the listing; empty on the last page
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// UserIndex returns the session index that SetMetadata adds a session to,
+// so that the sessions of a user are found like those of an OIDC subject
+func UserIndex(userID string) string {
+	return "user:" + userID
+}
+
+// HandleIndex returns the session index that maps a handle to its session
+func HandleIndex(handle string) string {
+	return "handle:" + handle
+}
+
+// NewHandle returns a random session handle
+func NewHandle() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate session handle: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// PageSize normalizes a requested page size
+func PageSize(limit int) int {
+	if limit <= 0 {
+		return DefaultPageSize
+	}
+	if limit > MaxPageSize {
+		return MaxPageSize
+	}
+	return limit
+}
+
+// SortKey orders sessions by creation time, then handle
+func SortKey(createdAt time.Time, handle string) string {
+	return fmt.Sprintf("%020d|%s", createdAt.UnixNano(), handle)
+}
+
+// HandleFromSortKey returns the session handle of a sort key
+func HandleFromSortKey(sortKey string) string {
+	if i := strings.IndexByte(sortKey, '|'); i >= 0 {
+		return sortKey[i+1:]
+	}
+	return sortKey
+}
+
+// EncodeCursor turns the sort key of the last listed session into an opaque cursor
+func EncodeCursor(sortKey string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(sortKey))
+}
+
+// DecodeCursor returns the sort key encoded in a cursor; an empty cursor
+// starts at the beginning
+func DecodeCursor(cursor string) (string, error) {
+	if cursor == "" {
+		return "", nil
+	}
+	sortKey, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || !strings.Contains(string(sortKey), "|") {
+		return "", ErrInvalidCursor
+	}
+	return string(sortKey), nil
+}
+
+// Device classifies the client from its User-Agent header
+func Device(userAgent string) string {
+	ua := strings.ToLower(userAgent)
+	switch {
+	case ua == "":
+		return "unknown"
+	case strings.Contains(ua, "ipad") || strings.Contains(ua, "tablet"):
+		return "tablet"
+	case strings.Contains(ua, "mobile") || strings.Contains(ua, "iphone") || strings.Contains(ua, "android"):
+		return "mobile"
+	case strings.HasPrefix(ua, "curl/") || strings.HasPrefix(ua, "wget/") ||
+		strings.Contains(ua, "python") || strings.Contains(ua, "go-http-client") || strings.HasPrefix(ua, "node"):
+		return "cli"
+	case strings.HasPrefix(ua, "mozilla/"):
+		return "desktop"
+	default:
+		return "other"
+	}
+}
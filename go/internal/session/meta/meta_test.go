@@ -0,0 +1,63 @@
+package meta
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCursor(t *testing.T) {
+	sortKey := SortKey(time.Unix(0, 42), "h4ndle")
+	assert.Equal(t, "00000000000000000042|h4ndle", sortKey)
+	assert.Equal(t, "h4ndle", HandleFromSortKey(sortKey))
+
+	decoded, err := DecodeCursor(EncodeCursor(sortKey))
+	require.NoError(t, err)
+	assert.Equal(t, sortKey, decoded)
+
+	decoded, err = DecodeCursor("")
+	require.NoError(t, err)
+	assert.Empty(t, decoded)
+
+	_, err = DecodeCursor("!!!")
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+	_, err = DecodeCursor(EncodeCursor("no separator"))
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+}
+
+func TestNewHandle(t *testing.T) {
+	first, err := NewHandle()
+	require.NoError(t, err)
+	second, err := NewHandle()
+	require.NoError(t, err)
+
+	assert.Len(t, first, 22)
+	assert.NotEqual(t, first, second)
+	assert.NotContains(t, first, "|", "handles must not break sort keys")
+}
+
+func TestPageSize(t *testing.T) {
+	assert.Equal(t, DefaultPageSize, PageSize(0))
+	assert.Equal(t, 5, PageSize(5))
+	assert.Equal(t, MaxPageSize, PageSize(MaxPageSize+1))
+}
+
+func TestDevice(t *testing.T) {
+	tests := map[string]string{
+		"": "unknown",
+		"Mozilla/5.0 (iPad; CPU OS 17_0 like Mac OS X)":                        "tablet",
+		"Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) Mobile/15E148": "mobile",
+		"Mozilla/5.0 (Linux; Android 14; Pixel 8)":                             "mobile",
+		"curl/8.4.0":             "cli",
+		"python-requests/2.31.0": "cli",
+		"Go-http-client/1.1":     "cli",
+		"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15": "desktop",
+		"mcp-client/1.0": "other",
+	}
+
+	for userAgent, want := range tests {
+		assert.Equal(t, want, Device(userAgent), userAgent)
+	}
+}
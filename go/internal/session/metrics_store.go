@@ -2,6 +2,7 @@ package session
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/metrics"
@@ -123,19 +124,172 @@ func (m *MetricsStore) Refresh(ctx context.Context, sessionID string, ttl time.D
 	return err
 }
 
+// SetMetadata attaches session metadata and records metrics
+func (m *MetricsStore) SetMetadata(ctx context.Context, key string, metadata *Metadata) error {
+	return m.observe("set_metadata", func() error {
+		return m.store.SetMetadata(ctx, key, metadata)
+	})
+}
+
+// Touch records session activity and records metrics
+func (m *MetricsStore) Touch(ctx context.Context, key string, seen time.Time) error {
+	return m.observe("touch", func() error {
+		return m.store.Touch(ctx, key, seen)
+	})
+}
+
+// ListUserSessions lists a user's sessions and records metrics
+func (m *MetricsStore) ListUserSessions(ctx context.Context, userID string) ([]*SessionInfo, error) {
+	var infos []*SessionInfo
+	err := m.observe("list_user", func() error {
+		var err error
+		infos, err = m.store.ListUserSessions(ctx, userID)
+		return err
+	})
+	return infos, err
+}
+
+// DeleteUserSessions deletes a user's sessions and records metrics
+func (m *MetricsStore) DeleteUserSessions(ctx context.Context, userID string) (int, error) {
+	var deleted int
+	err := m.observe("delete_user", func() error {
+		var err error
+		deleted, err = m.store.DeleteUserSessions(ctx, userID)
+		return err
+	})
+	return deleted, err
+}
+
+// ListSessions lists sessions and records metrics
+func (m *MetricsStore) ListSessions(ctx context.Context, cursor string, limit int) (*SessionPage, error) {
+	var page *SessionPage
+	err := m.observe("list", func() error {
+		var err error
+		page, err = m.store.ListSessions(ctx, cursor, limit)
+		return err
+	})
+	return page, err
+}
+
+// ErrIndexNotSupported is returned by MetricsStore index operations when the
+// wrapped store does not implement Indexer
+var ErrIndexNotSupported = errors.New("session store does not support indexes")
+
+// AddIndex adds a session to an index if the wrapped store supports indexes
+func (m *MetricsStore) AddIndex(ctx context.Context, index, key string) error {
+	indexer, ok := m.store.(Indexer)
+	if !ok {
+		return ErrIndexNotSupported
+	}
+	return m.observe("index_add", func() error {
+		return indexer.AddIndex(ctx, index, key)
+	})
+}
+
+// RemoveIndex removes a session from an index if the wrapped store supports indexes
+func (m *MetricsStore) RemoveIndex(ctx context.Context, index, key string) error {
+	indexer, ok := m.store.(Indexer)
+	if !ok {
+		return ErrIndexNotSupported
+	}
+	return m.observe("index_remove", func() error {
+		return indexer.RemoveIndex(ctx, index, key)
+	})
+}
+
+// IndexedKeys lists the sessions in an index if the wrapped store supports indexes
+func (m *MetricsStore) IndexedKeys(ctx context.Context, index string) ([]string, error) {
+	indexer, ok := m.store.(Indexer)
+	if !ok {
+		return nil, ErrIndexNotSupported
+	}
+	var keys []string
+	err := m.observe("index_list", func() error {
+		var err error
+		keys, err = indexer.IndexedKeys(ctx, index)
+		return err
+	})
+	return keys, err
+}
+
+// RevokeIndex revokes the sessions in an index if the wrapped store supports it
+func (m *MetricsStore) RevokeIndex(ctx context.Context, index string) error {
+	revoker, ok := m.store.(IndexRevoker)
+	if !ok {
+		return ErrIndexNotSupported
+	}
+	return m.observe("index_revoke", func() error {
+		return revoker.RevokeIndex(ctx, index)
+	})
+}
+
+// CreateRaw creates a session holding raw bytes if the wrapped store supports it
+func (m *MetricsStore) CreateRaw(ctx context.Context, key string, value []byte, ttl time.Duration) (string, error) {
+	raw, ok := m.store.(RawStore)
+	if !ok {
+		return "", ErrRawNotSupported
+	}
+	var id string
+	err := m.observe("create", func() error {
+		var err error
+		id, err = raw.CreateRaw(ctx, key, value, ttl)
+		return err
+	})
+	return id, err
+}
+
+// GetRaw reads the stored bytes of a session if the wrapped store supports it
+func (m *MetricsStore) GetRaw(ctx context.Context, key string) ([]byte, error) {
+	raw, ok := m.store.(RawStore)
+	if !ok {
+		return nil, ErrRawNotSupported
+	}
+	var value []byte
+	err := m.observe("get", func() error {
+		var err error
+		value, err = raw.GetRaw(ctx, key)
+		return err
+	})
+	return value, err
+}
+
+// UpdateRaw updates a session with raw bytes if the wrapped store supports it
+func (m *MetricsStore) UpdateRaw(ctx context.Context, key string, value []byte) error {
+	raw, ok := m.store.(RawStore)
+	if !ok {
+		return ErrRawNotSupported
+	}
+	return m.observe("update", func() error {
+		return raw.UpdateRaw(ctx, key, value)
+	})
+}
+
+// observe runs a store operation and records its metrics
+func (m *MetricsStore) observe(operation string, fn func() error) error {
+	start := time.Now()
+	err := fn()
+
+	status := "success"
+	if err != nil {
+		status = "error"
+	}
+
+	metrics.SessionOperationsTotal.WithLabelValues(operation, m.storeType, status).Inc()
+	metrics.SessionOperationDuration.WithLabelValues(operation, m.storeType).Observe(time.Since(start).Seconds())
+
+	return err
+}
+
 // Cleanup performs cleanup operations
 func (m *MetricsStore) Cleanup(ctx context.Context) error {
 	return m.store.Cleanup(ctx)
 }
 
 // Stats returns session statistics and updates metrics
-func (m *MetricsStore) Stats(ctx context.Context) (interface{}, error) {
+func (m *MetricsStore) Stats(ctx context.Context) (*Stats, error) {
 	stats, err := m.store.Stats(ctx)
 	if err == nil && stats != nil {
-		// Type assertion to get Stats
-		if s, ok := stats.(*Stats); ok {
-			metrics.SessionsActive.Set(float64(s.ActiveSessions))
-		}
+		metrics.SessionsActive.Set(float64(stats.ActiveSessions))
 	}
 	return stats, err
 }
@@ -0,0 +1,47 @@
+package redis
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/session/sessiontest"
+	"go.uber.org/zap"
+)
+
+func TestConformance(t *testing.T) {
+	// Expiry is driven by the miniredis clocks of the current store
+	var servers []*miniredis.Miniredis
+	advance := func(d time.Duration) {
+		for _, mr := range servers {
+			mr.FastForward(d)
+		}
+	}
+
+	t.Run("standalone", func(t *testing.T) {
+		sessiontest.Run(t, sessiontest.Config{
+			NewStore: func(t *testing.T) sessiontest.Store {
+				mr := miniredis.RunT(t)
+				servers = []*miniredis.Miniredis{mr}
+				return NewStoreWithClient(redis.NewClient(&redis.Options{Addr: mr.Addr()}), "test:", zap.NewNop())
+			},
+			Advance: advance,
+			// Refresh sets expiries in whole seconds
+			TTL: time.Second,
+		})
+	})
+
+	t.Run("cluster", func(t *testing.T) {
+		sessiontest.Run(t, sessiontest.Config{
+			NewStore: func(t *testing.T) sessiontest.Store {
+				client, shards := newTestCluster(t)
+				servers = shards
+				return NewStoreWithClient(client, "test:", zap.NewNop())
+			},
+			Advance: advance,
+			// Refresh sets expiries in whole seconds
+			TTL: time.Second,
+		})
+	})
+}
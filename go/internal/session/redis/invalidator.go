@@ -0,0 +1,114 @@
+package redis
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+// DefaultInvalidationChannel is the pub/sub channel used when none is configured
+const DefaultInvalidationChannel = "mcp:session:invalidate"
+
+// Invalidator propagates session cache invalidations between replicas
+// through Redis pub/sub. It implements session.CacheInvalidator.
+// Messages are "<sender> <key>" so a replica skips its own announcements.
+type Invalidator struct {
+	client  redis.UniversalClient
+	channel string
+	sender  string
+	logger  *zap.Logger
+
+	mu     sync.Mutex
+	pubsub *redis.PubSub
+	done   chan struct{}
+}
+
+// NewInvalidator creates an invalidator on the connection of a store
+func NewInvalidator(store *Store, channel string, logger *zap.Logger) (*Invalidator, error) {
+	client, ok := store.client.(redis.UniversalClient)
+	if !ok {
+		return nil, fmt.Errorf("redis client does not support pub/sub")
+	}
+	if channel == "" {
+		channel = DefaultInvalidationChannel
+	}
+
+	sender := make([]byte, 8)
+	if _, err := rand.Read(sender); err != nil {
+		return nil, fmt.Errorf("failed to generate invalidator ID: %w", err)
+	}
+
+	return &Invalidator{
+		client:  client,
+		channel: channel,
+		sender:  hex.EncodeToString(sender),
+		logger:  logger,
+	}, nil
+}
+
+// Publish announces that a session changed
+func (i *Invalidator) Publish(ctx context.Context, key string) error {
+	if err := i.client.Publish(ctx, i.channel, i.sender+" "+key).Err(); err != nil {
+		return fmt.Errorf("failed to publish session invalidation: %w", err)
+	}
+	return nil
+}
+
+// Subscribe waits for the subscription to be active, then calls evict for
+// every key announced by another replica. Messages published while the
+// connection is down are lost, so reset is called whenever the client
+// resubscribes.
+func (i *Invalidator) Subscribe(ctx context.Context, evict func(key string), reset func()) error {
+	pubsub := i.client.Subscribe(ctx, i.channel)
+	if _, err := pubsub.Receive(ctx); err != nil {
+		pubsub.Close()
+		return fmt.Errorf("failed to subscribe to %s: %w", i.channel, err)
+	}
+
+	i.mu.Lock()
+	i.pubsub = pubsub
+	i.done = make(chan struct{})
+	i.mu.Unlock()
+
+	messages := pubsub.ChannelWithSubscriptions()
+	go func(done chan struct{}) {
+		defer close(done)
+		for msg := range messages {
+			switch msg := msg.(type) {
+			case *redis.Message:
+				sender, key, ok := strings.Cut(msg.Payload, " ")
+				if ok && sender != i.sender {
+					evict(key)
+				}
+			case *redis.Subscription:
+				if msg.Kind == "subscribe" {
+					i.logger.Debug("Resubscribed to session invalidations", zap.String("channel", i.channel))
+					reset()
+				}
+			}
+		}
+	}(i.done)
+
+	return nil
+}
+
+// Close stops the subscription
+func (i *Invalidator) Close() error {
+	i.mu.Lock()
+	pubsub, done := i.pubsub, i.done
+	i.pubsub = nil
+	i.mu.Unlock()
+
+	if pubsub == nil {
+		return nil
+	}
+	err := pubsub.Close()
+	<-done
+	return err
+}
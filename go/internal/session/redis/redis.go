@@ -2,15 +2,47 @@ package redis
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/redis/go-redis/v9"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/session/meta"
 	"go.uber.org/zap"
 )
 
-// Store implements session.Store using Redis as the backend
+// Bookkeeping keys live under the key prefix followed by internalKeyPrefix;
+// session keys must not start with it
+const (
+	internalKeyPrefix = "_"
+	indexKeyPrefix    = internalKeyPrefix + "idx:"  // set of session keys per index
+	metaKeyPrefix     = internalKeyPrefix + "meta:" // hash of session metadata
+	allSessionsKey    = internalKeyPrefix + "all"   // sorted set of all sessions with metadata
+)
+
+// pttlMissing is the PTTL reply for a key that does not exist
+const pttlMissing = time.Duration(-2)
+
+// touchScript updates last_seen only for sessions that have metadata
+var touchScript = redis.NewScript(`
+	if redis.call('EXISTS', KEYS[1]) == 1 then
+		redis.call('HSET', KEYS[1], 'last_seen', ARGV[1])
+		return 1
+	end
+	return 0
+`)
+
+// Store implements session.Store using Redis as the backend.
+// In cluster mode a session, its metadata and the listings that refer to
+// it may live on different shards; updates spanning them are applied per
+// shard rather than in one transaction.
 type Store struct {
 	client    redis.Cmdable
 	keyPrefix string
@@ -18,39 +50,75 @@ type Store struct {
 }
 
 // Stats holds session store statistics
-type Stats struct {
-	ActiveSessions int64  `json:"active_sessions"`
-	TotalCreated   int64  `json:"total_created"`
-	TotalDeleted   int64  `json:"total_deleted"`
-	Store          string `json:"store"`
-	Info           string `json:"info,omitempty"`
-}
+type Stats = meta.Stats
+
+// Deployment modes of the Redis store
+const (
+	// ModeStandalone connects to a single server given by URL
+	ModeStandalone = "standalone"
+	// ModeSentinel follows the master of MasterName through the sentinels in Addrs
+	ModeSentinel = "sentinel"
+	// ModeCluster shards sessions across a Redis Cluster seeded from Addrs
+	ModeCluster = "cluster"
+)
 
 // Config holds Redis session store configuration
 type Config struct {
-	// Redis connection URL (redis://localhost:6379/0)
+	// Deployment mode: standalone (default), sentinel or cluster
+	Mode string
+	// Redis connection URL (redis://localhost:6379/0), standalone mode only
 	URL string
+	// Sentinel addresses (sentinel mode) or cluster seed nodes (cluster mode)
+	Addrs []string
+	// Name of the master monitored by the sentinels
+	MasterName string
+	// Username for Redis ACL authentication
+	Username string
 	// Password for Redis authentication
 	Password string
-	// Database number (0-15)
+	// Credentials of the sentinels, when they differ from the data nodes
+	SentinelUsername string
+	SentinelPassword string
+	// Database number (0-15); clusters only have database 0
 	DB int
 	// Key prefix for session keys
 	KeyPrefix string
-	// Connection pool size
+	// Connection pool size (per node in cluster mode)
 	PoolSize int
 	// Minimum idle connections
 	MinIdleConns int
+	// Time to wait for a free connection when the pool is exhausted
+	PoolTimeout time.Duration
 	// Connection timeout
 	DialTimeout time.Duration
 	// Read timeout
 	ReadTimeout time.Duration
 	// Write timeout
 	WriteTimeout time.Duration
+	// Retries of a failed command (-1 disables retries)
+	MaxRetries int
+	// TLS settings; a rediss:// URL enables TLS as well
+	TLS TLSConfig
+}
+
+// TLSConfig holds the TLS settings of Redis connections
+type TLSConfig struct {
+	Enabled bool
+	// CA bundle used to verify the server instead of the system roots
+	CAFile string
+	// Client certificate for mutual TLS
+	CertFile string
+	KeyFile  string
+	// Server name to verify, when it differs from the dialed host
+	ServerName string
+	// InsecureSkipVerify disables certificate verification (testing only)
+	InsecureSkipVerify bool
 }
 
 // DefaultConfig returns a default Redis configuration
 func DefaultConfig() *Config {
 	return &Config{
+		Mode:         ModeStandalone,
 		URL:          "redis://localhost:6379/0",
 		KeyPrefix:    "session:",
 		PoolSize:     10,
@@ -67,43 +135,21 @@ func NewStore(config *Config, logger *zap.Logger) (*Store, error) {
 		config = DefaultConfig()
 	}
 
-	// Parse Redis URL
-	opt, err := redis.ParseURL(config.URL)
+	opt, err := universalOptions(config)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse Redis URL: %w", err)
-	}
-
-	// Override with config values
-	if config.Password != "" {
-		opt.Password = config.Password
-	}
-	if config.DB > 0 {
-		opt.DB = config.DB
-	}
-	if config.PoolSize > 0 {
-		opt.PoolSize = config.PoolSize
-	}
-	if config.MinIdleConns > 0 {
-		opt.MinIdleConns = config.MinIdleConns
-	}
-	if config.DialTimeout > 0 {
-		opt.DialTimeout = config.DialTimeout
-	}
-	if config.ReadTimeout > 0 {
-		opt.ReadTimeout = config.ReadTimeout
-	}
-	if config.WriteTimeout > 0 {
-		opt.WriteTimeout = config.WriteTimeout
+		return nil, err
 	}
 
-	// Create Redis client
-	client := redis.NewClient(opt)
+	// Create Redis client: a plain client, a sentinel-backed failover client
+	// or a cluster client depending on the options
+	client := redis.NewUniversalClient(opt)
 
 	// Test connection
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
 	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
 		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
 	}
 
@@ -119,6 +165,106 @@ func NewStore(config *Config, logger *zap.Logger) (*Store, error) {
 	}, nil
 }
 
+// universalOptions translates the configuration into client options
+func universalOptions(config *Config) (*redis.UniversalOptions, error) {
+	opt := &redis.UniversalOptions{
+		Addrs:            config.Addrs,
+		Username:         config.Username,
+		Password:         config.Password,
+		SentinelUsername: config.SentinelUsername,
+		SentinelPassword: config.SentinelPassword,
+		DB:               config.DB,
+		PoolSize:         config.PoolSize,
+		MinIdleConns:     config.MinIdleConns,
+		PoolTimeout:      config.PoolTimeout,
+		DialTimeout:      config.DialTimeout,
+		ReadTimeout:      config.ReadTimeout,
+		WriteTimeout:     config.WriteTimeout,
+		MaxRetries:       config.MaxRetries,
+	}
+
+	switch config.Mode {
+	case "", ModeStandalone:
+		// Parse Redis URL
+		parsed, err := redis.ParseURL(config.URL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse Redis URL: %w", err)
+		}
+		opt.Addrs = []string{parsed.Addr}
+		opt.TLSConfig = parsed.TLSConfig
+
+		// Config values override the URL
+		if opt.Username == "" {
+			opt.Username = parsed.Username
+		}
+		if opt.Password == "" {
+			opt.Password = parsed.Password
+		}
+		if opt.DB == 0 {
+			opt.DB = parsed.DB
+		}
+	case ModeSentinel:
+		if config.MasterName == "" {
+			return nil, fmt.Errorf("master name is required in Redis sentinel mode")
+		}
+		if len(config.Addrs) == 0 {
+			return nil, fmt.Errorf("sentinel addresses are required in Redis sentinel mode")
+		}
+		opt.MasterName = config.MasterName
+	case ModeCluster:
+		if len(config.Addrs) == 0 {
+			return nil, fmt.Errorf("cluster addresses are required in Redis cluster mode")
+		}
+		if config.DB != 0 {
+			return nil, fmt.Errorf("redis cluster only supports database 0")
+		}
+		opt.IsClusterMode = true
+	default:
+		return nil, fmt.Errorf("unsupported Redis mode: %s (supported: standalone, sentinel, cluster)", config.Mode)
+	}
+
+	if config.TLS.Enabled {
+		tlsConfig, err := buildTLSConfig(&config.TLS)
+		if err != nil {
+			return nil, err
+		}
+		opt.TLSConfig = tlsConfig
+	}
+
+	return opt, nil
+}
+
+// buildTLSConfig loads the CA bundle and client certificate of a TLS configuration
+func buildTLSConfig(config *TLSConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         config.ServerName,
+		InsecureSkipVerify: config.InsecureSkipVerify,
+	}
+
+	if config.CAFile != "" {
+		pem, err := os.ReadFile(config.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read Redis CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in Redis CA file %s", config.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if config.CertFile != "" || config.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load Redis client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
 // NewStoreWithClient creates a new Redis session store with an existing Redis client
 func NewStoreWithClient(client redis.Cmdable, keyPrefix string, logger *zap.Logger) *Store {
 	if keyPrefix == "" {
@@ -138,15 +284,20 @@ func (s *Store) Create(ctx context.Context, key string, data interface{}, ttl ti
 	if err != nil {
 		return "", fmt.Errorf("failed to marshal session data: %w", err)
 	}
+	return s.CreateRaw(ctx, key, jsonData, ttl)
+}
 
+// CreateRaw creates a new session holding value as is
+func (s *Store) CreateRaw(ctx context.Context, key string, value []byte, ttl time.Duration) (string, error) {
 	// Generate full key with prefix
 	fullKey := s.keyPrefix + key
 
 	// Store in Redis
+	var err error
 	if ttl > 0 {
-		err = s.client.Set(ctx, fullKey, jsonData, ttl).Err()
+		err = s.client.Set(ctx, fullKey, value, ttl).Err()
 	} else {
-		err = s.client.Set(ctx, fullKey, jsonData, 0).Err()
+		err = s.client.Set(ctx, fullKey, value, 0).Err()
 	}
 
 	if err != nil {
@@ -163,25 +314,34 @@ func (s *Store) Create(ctx context.Context, key string, data interface{}, ttl ti
 
 // Get retrieves session data by key
 func (s *Store) Get(ctx context.Context, key string, data interface{}) error {
+	jsonData, err := s.GetRaw(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	// Deserialize JSON data
+	if err := json.Unmarshal(jsonData, data); err != nil {
+		return fmt.Errorf("failed to unmarshal session data: %w", err)
+	}
+	return nil
+}
+
+// GetRaw returns the stored bytes of a session
+func (s *Store) GetRaw(ctx context.Context, key string) ([]byte, error) {
 	// Generate full key with prefix
 	fullKey := s.keyPrefix + key
 
 	// Get from Redis
-	jsonData, err := s.client.Get(ctx, fullKey).Result()
+	value, err := s.client.Get(ctx, fullKey).Bytes()
 	if err != nil {
 		if err == redis.Nil {
-			return fmt.Errorf("session not found")
+			return nil, meta.ErrNotFound
 		}
-		return fmt.Errorf("failed to get session from Redis: %w", err)
-	}
-
-	// Deserialize JSON data
-	if err := json.Unmarshal([]byte(jsonData), data); err != nil {
-		return fmt.Errorf("failed to unmarshal session data: %w", err)
+		return nil, fmt.Errorf("failed to get session from Redis: %w", err)
 	}
 
 	s.logger.Debug("Session retrieved", zap.String("key", key))
-	return nil
+	return value, nil
 }
 
 // Update updates existing session data
@@ -191,7 +351,11 @@ func (s *Store) Update(ctx context.Context, key string, data interface{}) error
 	if err != nil {
 		return fmt.Errorf("failed to marshal session data: %w", err)
 	}
+	return s.UpdateRaw(ctx, key, jsonData)
+}
 
+// UpdateRaw replaces the data of an existing session with value as is
+func (s *Store) UpdateRaw(ctx context.Context, key string, value []byte) error {
 	fullKey := s.keyPrefix + key
 
 	// Use Lua script for atomic update operation
@@ -217,7 +381,7 @@ func (s *Store) Update(ctx context.Context, key string, data interface{}) error
 		return {ok = 'updated'}
 	`
 
-	result, err := s.client.Eval(ctx, script, []string{fullKey}, string(jsonData)).Result()
+	result, err := s.client.Eval(ctx, script, []string{fullKey}, value).Result()
 	if err != nil {
 		return fmt.Errorf("failed to execute update script: %w", err)
 	}
@@ -238,14 +402,26 @@ func (s *Store) Delete(ctx context.Context, key string) error {
 	// Generate full key with prefix
 	fullKey := s.keyPrefix + key
 
+	// Read the metadata first so the session can be removed from the listings
+	metadata, err := s.getMetadata(ctx, key)
+	if err != nil {
+		return err
+	}
+
 	// Delete from Redis
 	deleted, err := s.client.Del(ctx, fullKey).Result()
 	if err != nil {
 		return fmt.Errorf("failed to delete session from Redis: %w", err)
 	}
 
+	if metadata != nil {
+		if err := s.removeMetadata(ctx, key, metadata); err != nil {
+			return err
+		}
+	}
+
 	if deleted == 0 {
-		return fmt.Errorf("session not found")
+		return meta.ErrNotFound
 	}
 
 	s.logger.Debug("Session deleted", zap.String("key", key))
@@ -277,7 +453,7 @@ func (s *Store) Refresh(ctx context.Context, key string, ttl time.Duration) erro
 		return fmt.Errorf("failed to check session existence: %w", err)
 	}
 	if exists == 0 {
-		return fmt.Errorf("session not found")
+		return meta.ErrNotFound
 	}
 
 	// Update TTL
@@ -291,6 +467,16 @@ func (s *Store) Refresh(ctx context.Context, key string, ttl time.Duration) erro
 		return fmt.Errorf("failed to refresh session TTL: %w", err)
 	}
 
+	// Keep the metadata for as long as the session
+	if ttl > 0 {
+		err = s.client.Expire(ctx, s.metaKey(key), ttl).Err()
+	} else {
+		err = s.client.Persist(ctx, s.metaKey(key)).Err()
+	}
+	if err != nil {
+		return fmt.Errorf("failed to refresh session metadata TTL: %w", err)
+	}
+
 	s.logger.Debug("Session TTL refreshed",
 		zap.String("key", key),
 		zap.Duration("ttl", ttl),
@@ -300,47 +486,443 @@ func (s *Store) Refresh(ctx context.Context, key string, ttl time.Duration) erro
 
 // Close closes the Redis connection
 func (s *Store) Close() error {
-	if client, ok := s.client.(*redis.Client); ok {
+	if client, ok := s.client.(redis.UniversalClient); ok {
 		return client.Close()
 	}
 	// For redis.Cmdable interface, we can't close it directly
 	return nil
 }
 
-// Cleanup removes expired sessions (Redis handles this automatically)
+// forEachShard calls fn with every master of a cluster, concurrently, or
+// with the client itself otherwise. Commands on a single key are routed by
+// the cluster client; SCAN and INFO only see the node they are sent to.
+func (s *Store) forEachShard(ctx context.Context, fn func(ctx context.Context, client redis.Cmdable) error) error {
+	if cluster, ok := s.client.(*redis.ClusterClient); ok {
+		return cluster.ForEachMaster(ctx, func(ctx context.Context, client *redis.Client) error {
+			return fn(ctx, client)
+		})
+	}
+	return fn(ctx, s.client)
+}
+
+// metaKey returns the Redis key of a session's metadata hash
+func (s *Store) metaKey(key string) string {
+	return s.keyPrefix + metaKeyPrefix + key
+}
+
+// SetMetadata attaches owner and client metadata to an existing session
+// and adds it to the global listing and the user and handle indexes
+func (s *Store) SetMetadata(ctx context.Context, key string, metadata *meta.Metadata) error {
+	ttl, err := s.client.PTTL(ctx, s.keyPrefix+key).Result()
+	if err != nil {
+		return fmt.Errorf("failed to read session TTL: %w", err)
+	}
+	if ttl == pttlMissing {
+		return meta.ErrNotFound
+	}
+
+	existing, err := s.getMetadata(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	m := *metadata
+	m.Handle = ""
+	if existing != nil {
+		m.Handle = existing.Handle
+		if !existing.CreatedAt.IsZero() {
+			m.CreatedAt = existing.CreatedAt
+		}
+		if err := s.removeMetadata(ctx, key, existing); err != nil {
+			return err
+		}
+	}
+	if m.Handle == "" {
+		if m.Handle, err = meta.NewHandle(); err != nil {
+			return err
+		}
+	}
+	if m.CreatedAt.IsZero() {
+		m.CreatedAt = time.Now()
+	}
+	if m.LastSeen.IsZero() {
+		m.LastSeen = time.Now()
+	}
+
+	sortKey := meta.SortKey(m.CreatedAt, m.Handle)
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, s.metaKey(key), map[string]interface{}{
+			"handle":     m.Handle,
+			"user_id":    m.UserID,
+			"ip":         m.IP,
+			"user_agent": m.UserAgent,
+			"device":     m.Device,
+			"created_at": m.CreatedAt.Format(time.RFC3339Nano),
+			"last_seen":  m.LastSeen.Format(time.RFC3339Nano),
+		})
+		if ttl > 0 {
+			pipe.PExpire(ctx, s.metaKey(key), ttl)
+		}
+		pipe.ZAdd(ctx, s.keyPrefix+allSessionsKey, redis.Z{Member: sortKey})
+		pipe.SAdd(ctx, s.indexKey(meta.UserIndex(m.UserID)), key)
+		pipe.SAdd(ctx, s.indexKey(meta.HandleIndex(m.Handle)), key)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store session metadata: %w", err)
+	}
+	return nil
+}
+
+// Touch records activity on a session that has metadata
+func (s *Store) Touch(ctx context.Context, key string, seen time.Time) error {
+	if err := touchScript.Run(ctx, s.client, []string{s.metaKey(key)}, seen.Format(time.RFC3339Nano)).Err(); err != nil {
+		return fmt.Errorf("failed to touch session: %w", err)
+	}
+	return nil
+}
+
+// ListUserSessions returns the live sessions of a user, oldest first
+func (s *Store) ListUserSessions(ctx context.Context, userID string) ([]*meta.Info, error) {
+	index := s.indexKey(meta.UserIndex(userID))
+	keys, err := s.pruneIndex(ctx, index)
+	if err != nil {
+		return nil, err
+	}
+
+	infos, stale, err := s.sessionInfos(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+	if len(stale) > 0 {
+		members := make([]interface{}, len(stale))
+		for i, j := range stale {
+			members[i] = keys[j]
+		}
+		if err := s.client.SRem(ctx, index, members...).Err(); err != nil {
+			return nil, fmt.Errorf("failed to prune session index: %w", err)
+		}
+	}
+
+	sort.Slice(infos, func(i, j int) bool {
+		return meta.SortKey(infos[i].CreatedAt, infos[i].Handle) < meta.SortKey(infos[j].CreatedAt, infos[j].Handle)
+	})
+	return infos, nil
+}
+
+// DeleteUserSessions deletes every session of a user
+func (s *Store) DeleteUserSessions(ctx context.Context, userID string) (int, error) {
+	infos, err := s.ListUserSessions(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	deleted := 0
+	for _, info := range infos {
+		if err := s.Delete(ctx, info.Key); err != nil {
+			s.logger.Debug("Failed to delete user session", zap.Error(err), zap.String("key", info.Key))
+			continue
+		}
+		deleted++
+	}
+	return deleted, nil
+}
+
+// ListSessions returns sessions with metadata, oldest first
+func (s *Store) ListSessions(ctx context.Context, cursor string, limit int) (*meta.Page, error) {
+	after, err := meta.DecodeCursor(cursor)
+	if err != nil {
+		return nil, err
+	}
+	limit = meta.PageSize(limit)
+
+	min := "-"
+	if after != "" {
+		min = "(" + after
+	}
+	members, err := s.client.ZRangeByLex(ctx, s.keyPrefix+allSessionsKey, &redis.ZRangeBy{
+		Min:   min,
+		Max:   "+",
+		Count: int64(limit),
+	}).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+
+	// Members carry handles; the handle index leads back to the session key
+	keys := make([]string, len(members))
+	if len(members) > 0 {
+		pipe := s.client.Pipeline()
+		indexed := make([]*redis.StringSliceCmd, len(members))
+		for i, member := range members {
+			indexed[i] = pipe.SMembers(ctx, s.indexKey(meta.HandleIndex(meta.HandleFromSortKey(member))))
+		}
+		if _, err := pipe.Exec(ctx); err != nil {
+			return nil, fmt.Errorf("failed to resolve session handles: %w", err)
+		}
+		for i := range members {
+			if found := indexed[i].Val(); len(found) == 1 {
+				keys[i] = found[0]
+			}
+		}
+	}
+
+	infos, stale, err := s.sessionInfos(ctx, keys)
+	if err != nil {
+		return nil, err
+	}
+	if len(stale) > 0 {
+		sortKeys := make([]interface{}, len(stale))
+		for i, j := range stale {
+			sortKeys[i] = members[j]
+		}
+		if err := s.client.ZRem(ctx, s.keyPrefix+allSessionsKey, sortKeys...).Err(); err != nil {
+			return nil, fmt.Errorf("failed to prune session listing: %w", err)
+		}
+	}
+
+	page := &meta.Page{Sessions: infos}
+	if len(members) == limit {
+		page.NextCursor = meta.EncodeCursor(members[len(members)-1])
+	}
+	return page, nil
+}
+
+// sessionInfos loads the listing entries of session keys and returns the
+// positions of the keys whose session has expired or has no metadata
+func (s *Store) sessionInfos(ctx context.Context, keys []string) ([]*meta.Info, []int, error) {
+	infos := make([]*meta.Info, 0, len(keys))
+	if len(keys) == 0 {
+		return infos, nil, nil
+	}
+
+	pipe := s.client.Pipeline()
+	hashes := make([]*redis.MapStringStringCmd, len(keys))
+	ttls := make([]*redis.DurationCmd, len(keys))
+	for i, key := range keys {
+		hashes[i] = pipe.HGetAll(ctx, s.metaKey(key))
+		ttls[i] = pipe.PTTL(ctx, s.keyPrefix+key)
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, nil, fmt.Errorf("failed to load session metadata: %w", err)
+	}
+
+	var stale []int
+	for i, key := range keys {
+		ttl := ttls[i].Val()
+		if len(hashes[i].Val()) == 0 || ttl == pttlMissing {
+			stale = append(stale, i)
+			continue
+		}
+
+		info := &meta.Info{Key: key, Metadata: *parseMetadata(hashes[i].Val())}
+		if ttl > 0 {
+			expiresAt := time.Now().Add(ttl)
+			info.ExpiresAt = &expiresAt
+		}
+		infos = append(infos, info)
+	}
+	return infos, stale, nil
+}
+
+// getMetadata returns a session's metadata, or nil if it has none
+func (s *Store) getMetadata(ctx context.Context, key string) (*meta.Metadata, error) {
+	fields, err := s.client.HGetAll(ctx, s.metaKey(key)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read session metadata: %w", err)
+	}
+	if len(fields) == 0 {
+		return nil, nil
+	}
+	return parseMetadata(fields), nil
+}
+
+// removeMetadata deletes a session's metadata, listing entry and index entries
+func (s *Store) removeMetadata(ctx context.Context, key string, metadata *meta.Metadata) error {
+	sortKey := meta.SortKey(metadata.CreatedAt, metadata.Handle)
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, s.metaKey(key))
+		pipe.ZRem(ctx, s.keyPrefix+allSessionsKey, sortKey)
+		pipe.SRem(ctx, s.indexKey(meta.UserIndex(metadata.UserID)), key)
+		pipe.SRem(ctx, s.indexKey(meta.HandleIndex(metadata.Handle)), key)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to remove session metadata: %w", err)
+	}
+	return nil
+}
+
+// parseMetadata decodes a metadata hash
+func parseMetadata(fields map[string]string) *meta.Metadata {
+	m := &meta.Metadata{
+		Handle:    fields["handle"],
+		UserID:    fields["user_id"],
+		IP:        fields["ip"],
+		UserAgent: fields["user_agent"],
+		Device:    fields["device"],
+	}
+	m.CreatedAt, _ = time.Parse(time.RFC3339Nano, fields["created_at"])
+	m.LastSeen, _ = time.Parse(time.RFC3339Nano, fields["last_seen"])
+	return m
+}
+
+// indexKey returns the Redis key of the set backing an index
+func (s *Store) indexKey(index string) string {
+	return s.keyPrefix + indexKeyPrefix + index
+}
+
+// AddIndex associates an existing session key with the index.
+// Index sets do not expire; entries of deleted or expired sessions are
+// pruned whenever the index is read or written and by Cleanup.
+func (s *Store) AddIndex(ctx context.Context, index, key string) error {
+	exists, err := s.client.Exists(ctx, s.keyPrefix+key).Result()
+	if err != nil {
+		return fmt.Errorf("failed to check session existence: %w", err)
+	}
+	if exists == 0 {
+		return meta.ErrNotFound
+	}
+
+	if err := s.client.SAdd(ctx, s.indexKey(index), key).Err(); err != nil {
+		return fmt.Errorf("failed to add session to index: %w", err)
+	}
+
+	if _, err := s.pruneIndex(ctx, s.indexKey(index)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// RemoveIndex removes a session key from the index
+func (s *Store) RemoveIndex(ctx context.Context, index, key string) error {
+	if err := s.client.SRem(ctx, s.indexKey(index), key).Err(); err != nil {
+		return fmt.Errorf("failed to remove session from index: %w", err)
+	}
+	return nil
+}
+
+// IndexedKeys returns the keys of the live sessions in the index
+func (s *Store) IndexedKeys(ctx context.Context, index string) ([]string, error) {
+	keys, err := s.pruneIndex(ctx, s.indexKey(index))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// pruneIndex removes members whose session no longer exists from an index
+// set and returns the remaining members
+func (s *Store) pruneIndex(ctx context.Context, setKey string) ([]string, error) {
+	members, err := s.client.SMembers(ctx, setKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read session index: %w", err)
+	}
+	if len(members) == 0 {
+		return members, nil
+	}
+
+	pipe := s.client.Pipeline()
+	checks := make([]*redis.IntCmd, len(members))
+	for i, member := range members {
+		checks[i] = pipe.Exists(ctx, s.keyPrefix+member)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, fmt.Errorf("failed to check indexed sessions: %w", err)
+	}
+
+	live := make([]string, 0, len(members))
+	var stale []interface{}
+	for i, member := range members {
+		if checks[i].Val() > 0 {
+			live = append(live, member)
+		} else {
+			stale = append(stale, member)
+		}
+	}
+	if len(stale) > 0 {
+		if err := s.client.SRem(ctx, setKey, stale...).Err(); err != nil {
+			return nil, fmt.Errorf("failed to prune session index: %w", err)
+		}
+	}
+
+	return live, nil
+}
+
+// Cleanup prunes index entries of expired sessions (Redis expires the
+// sessions themselves automatically)
 func (s *Store) Cleanup(ctx context.Context) error {
-	// Redis automatically handles expiration, but we can implement
-	// custom cleanup logic if needed
-	s.logger.Debug("Session cleanup requested (Redis handles expiration automatically)")
+	var pruned atomic.Int64
+	err := s.forEachShard(ctx, func(ctx context.Context, shard redis.Cmdable) error {
+		iter := shard.Scan(ctx, 0, s.keyPrefix+indexKeyPrefix+"*", 0).Iterator()
+		for iter.Next(ctx) {
+			if _, err := s.pruneIndex(ctx, iter.Val()); err != nil {
+				return err
+			}
+			pruned.Add(1)
+		}
+		if err := iter.Err(); err != nil {
+			return fmt.Errorf("failed to scan session indexes: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	s.logger.Debug("Session indexes pruned", zap.Int64("indexes", pruned.Load()))
 	return nil
 }
 
 // Stats returns session store statistics
-func (s *Store) Stats(ctx context.Context) (interface{}, error) {
-	// Get Redis info (simplified for interface compatibility)
-	info := "keyspace info not available"
-	if client, ok := s.client.(*redis.Client); ok {
-		if result, err := client.Do(ctx, "INFO", "keyspace").Result(); err == nil {
-			info = fmt.Sprintf("%v", result)
+func (s *Store) Stats(ctx context.Context) (*Stats, error) {
+	var (
+		active atomic.Int64
+		mu     sync.Mutex
+		infos  []string
+	)
+
+	err := s.forEachShard(ctx, func(ctx context.Context, shard redis.Cmdable) error {
+		// Get Redis info (simplified for interface compatibility)
+		if result, err := shard.Info(ctx, "keyspace").Result(); err == nil {
+			info := strings.TrimSpace(result)
+			if client, ok := shard.(*redis.Client); ok && s.client != shard {
+				info = client.Options().Addr + ": " + info
+			}
+			mu.Lock()
+			infos = append(infos, info)
+			mu.Unlock()
 		}
-	}
 
-	// Count sessions with our prefix using SCAN (non-blocking)
-	pattern := s.keyPrefix + "*"
-	var keys []string
-	iter := s.client.Scan(ctx, 0, pattern, 0).Iterator()
-	for iter.Next(ctx) {
-		keys = append(keys, iter.Val())
+		// Count sessions with our prefix using SCAN (non-blocking)
+		iter := shard.Scan(ctx, 0, s.keyPrefix+"*", 0).Iterator()
+		for iter.Next(ctx) {
+			if strings.HasPrefix(iter.Val(), s.keyPrefix+internalKeyPrefix) {
+				continue
+			}
+			active.Add(1)
+		}
+		if err := iter.Err(); err != nil {
+			return fmt.Errorf("failed to scan sessions: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
-	if err := iter.Err(); err != nil {
-		return nil, fmt.Errorf("failed to scan sessions: %w", err)
+
+	info := "keyspace info not available"
+	if len(infos) > 0 {
+		sort.Strings(infos)
+		info = strings.Join(infos, "; ")
 	}
 
 	return &Stats{
-		ActiveSessions: int64(len(keys)),
+		ActiveSessions: active.Load(),
 		TotalCreated:   -1, // Redis doesn't track this
 		TotalDeleted:   -1, // Redis doesn't track this
 		Store:          "redis",
-		Info:           fmt.Sprintf("%v", info),
+		Info:           info,
 	}, nil
-}
\ No newline at end of file
+}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/alicebob/miniredis/v2"
 	"github.com/redis/go-redis/v9"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/session/meta"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
@@ -200,11 +201,9 @@ func TestStatsSimple(t *testing.T) {
 	require.NoError(t, err)
 	assert.NotNil(t, statsInterface)
 
-	// Type assert to verify structure
-	if stats, ok := statsInterface.(*Stats); ok {
-		assert.Equal(t, "redis", stats.Store)
-		assert.Equal(t, int64(2), stats.ActiveSessions)
-	}
+	stats := statsInterface
+	assert.Equal(t, "redis", stats.Store)
+	assert.Equal(t, int64(2), stats.ActiveSessions)
 }
 
 func TestCleanupSimple(t *testing.T) {
@@ -261,4 +260,194 @@ func TestNewStoreWithClient(t *testing.T) {
 	err = store.Get(ctx, "test_session", &retrieved)
 	require.NoError(t, err)
 	assert.Equal(t, testData, retrieved)
-}
\ No newline at end of file
+}
+func TestIndexesSimple(t *testing.T) {
+	s, err := miniredis.Run()
+	require.NoError(t, err)
+	defer s.Close()
+
+	client := redis.NewClient(&redis.Options{Addr: s.Addr()})
+	store := NewStoreWithClient(client, "idx_test:", zap.NewNop())
+	defer store.Close()
+
+	ctx := context.Background()
+	testData := TestData{ID: "user123"}
+
+	_, err = store.Create(ctx, "s1", testData, time.Hour)
+	require.NoError(t, err)
+	_, err = store.Create(ctx, "s2", testData, time.Hour)
+	require.NoError(t, err)
+	_, err = store.Create(ctx, "short_lived", testData, time.Second)
+	require.NoError(t, err)
+
+	require.NoError(t, store.AddIndex(ctx, "sub:alice", "s2"))
+	require.NoError(t, store.AddIndex(ctx, "sub:alice", "s1"))
+	require.NoError(t, store.AddIndex(ctx, "sub:alice", "short_lived"))
+	assert.Error(t, store.AddIndex(ctx, "sub:alice", "missing"), "only existing sessions can be indexed")
+
+	keys, err := store.IndexedKeys(ctx, "sub:alice")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"s1", "s2", "short_lived"}, keys)
+
+	t.Run("Index sets are not counted as sessions", func(t *testing.T) {
+		stats, err := store.Stats(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, int64(3), stats.ActiveSessions)
+	})
+
+	t.Run("Deleted and expired sessions are pruned", func(t *testing.T) {
+		s.FastForward(2 * time.Second)
+		require.NoError(t, store.Delete(ctx, "s1"))
+
+		keys, err := store.IndexedKeys(ctx, "sub:alice")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"s2"}, keys)
+
+		members, err := s.SMembers("idx_test:_idx:sub:alice")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"s2"}, members)
+	})
+
+	t.Run("Remove", func(t *testing.T) {
+		require.NoError(t, store.RemoveIndex(ctx, "sub:alice", "s2"))
+		keys, err := store.IndexedKeys(ctx, "sub:alice")
+		require.NoError(t, err)
+		assert.Empty(t, keys)
+	})
+
+	t.Run("Cleanup", func(t *testing.T) {
+		require.NoError(t, store.AddIndex(ctx, "sid:idp-1", "s2"))
+		require.NoError(t, store.Delete(ctx, "s2"))
+		require.NoError(t, store.Cleanup(ctx))
+		assert.False(t, s.Exists("idx_test:_idx:sid:idp-1"), "empty index sets are removed")
+	})
+}
+
+func TestUserSessionsSimple(t *testing.T) {
+	s, err := miniredis.Run()
+	require.NoError(t, err)
+	defer s.Close()
+
+	client := redis.NewClient(&redis.Options{Addr: s.Addr()})
+	store := NewStoreWithClient(client, "meta_test:", zap.NewNop())
+	defer store.Close()
+
+	ctx := context.Background()
+	base := time.Now().Add(-time.Hour)
+
+	for i, key := range []string{"a1", "a2", "b1", "anonymous"} {
+		_, err := store.Create(ctx, key, TestData{ID: key}, time.Hour)
+		require.NoError(t, err)
+		if key == "anonymous" {
+			continue
+		}
+		require.NoError(t, store.SetMetadata(ctx, key, &meta.Metadata{
+			UserID:    key[:1],
+			IP:        "192.0.2.1",
+			UserAgent: "curl/8.0",
+			Device:    "cli",
+			CreatedAt: base.Add(time.Duration(i) * time.Minute),
+		}))
+	}
+
+	assert.Error(t, store.SetMetadata(ctx, "missing", &meta.Metadata{UserID: "a"}))
+
+	t.Run("Metadata keys are not counted as sessions", func(t *testing.T) {
+		stats, err := store.Stats(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, int64(4), stats.ActiveSessions)
+	})
+
+	t.Run("List user sessions", func(t *testing.T) {
+		seen := time.Now().Add(time.Minute).Truncate(time.Millisecond)
+		require.NoError(t, store.Touch(ctx, "a1", seen))
+		require.NoError(t, store.Touch(ctx, "anonymous", seen), "sessions without metadata are ignored")
+		assert.False(t, s.Exists("meta_test:_meta:anonymous"))
+
+		sessions, err := store.ListUserSessions(ctx, "a")
+		require.NoError(t, err)
+		require.Len(t, sessions, 2)
+		assert.Equal(t, "a1", sessions[0].Key)
+		assert.Equal(t, "a2", sessions[1].Key)
+		assert.Equal(t, "curl/8.0", sessions[0].UserAgent)
+		assert.True(t, sessions[0].LastSeen.Equal(seen))
+		assert.True(t, sessions[0].CreatedAt.Equal(base))
+		assert.NotNil(t, sessions[0].ExpiresAt)
+	})
+
+	t.Run("User sessions share the session index", func(t *testing.T) {
+		keys, err := store.IndexedKeys(ctx, meta.UserIndex("a"))
+		require.NoError(t, err)
+		assert.Equal(t, []string{"a1", "a2"}, keys)
+
+		require.NoError(t, store.SetMetadata(ctx, "b1", &meta.Metadata{UserID: "c"}))
+		sessions, err := store.ListUserSessions(ctx, "b")
+		require.NoError(t, err)
+		assert.Empty(t, sessions)
+		sessions, err = store.ListUserSessions(ctx, "c")
+		require.NoError(t, err)
+		require.Len(t, sessions, 1)
+		assert.Equal(t, "b1", sessions[0].Key)
+		handle := sessions[0].Handle
+		assert.NotEmpty(t, handle)
+		keys, err = store.IndexedKeys(ctx, meta.HandleIndex(handle))
+		require.NoError(t, err)
+		assert.Equal(t, []string{"b1"}, keys)
+		assert.True(t, sessions[0].CreatedAt.Equal(base.Add(2*time.Minute)))
+		require.NoError(t, store.SetMetadata(ctx, "b1", &meta.Metadata{UserID: "b"}))
+		sessions, err = store.ListUserSessions(ctx, "b")
+		require.NoError(t, err)
+		handles := make([]string, len(sessions))
+		for i, info := range sessions {
+			handles[i] = info.Handle
+		}
+		assert.Contains(t, handles, handle, "the handle survives metadata updates")
+	})
+
+	t.Run("Paginate all sessions", func(t *testing.T) {
+		page, err := store.ListSessions(ctx, "", 2)
+		require.NoError(t, err)
+		require.Len(t, page.Sessions, 2)
+		assert.Equal(t, "a1", page.Sessions[0].Key)
+		assert.Equal(t, "a2", page.Sessions[1].Key)
+		require.NotEmpty(t, page.NextCursor)
+
+		page, err = store.ListSessions(ctx, page.NextCursor, 2)
+		require.NoError(t, err)
+		require.Len(t, page.Sessions, 1)
+		assert.Equal(t, "b1", page.Sessions[0].Key)
+		assert.Empty(t, page.NextCursor)
+
+		_, err = store.ListSessions(ctx, "not a cursor", 2)
+		assert.ErrorIs(t, err, meta.ErrInvalidCursor)
+	})
+
+	t.Run("Delete user sessions", func(t *testing.T) {
+		deleted, err := store.DeleteUserSessions(ctx, "a")
+		require.NoError(t, err)
+		assert.Equal(t, 2, deleted)
+
+		assert.False(t, s.Exists("meta_test:a1"))
+		assert.False(t, s.Exists("meta_test:_meta:a1"))
+		assert.False(t, s.Exists("meta_test:_idx:user:a"))
+		assert.True(t, s.Exists("meta_test:b1"))
+
+		page, err := store.ListSessions(ctx, "", 10)
+		require.NoError(t, err)
+		require.Len(t, page.Sessions, 1)
+		assert.Equal(t, "b1", page.Sessions[0].Key)
+	})
+
+	t.Run("Expired sessions are pruned from listings", func(t *testing.T) {
+		s.FastForward(2 * time.Hour)
+
+		page, err := store.ListSessions(ctx, "", 10)
+		require.NoError(t, err)
+		assert.Empty(t, page.Sessions)
+
+		members, err := s.ZMembers("meta_test:_all")
+		if err == nil {
+			assert.Empty(t, members)
+		}
+	})
+}
@@ -0,0 +1,70 @@
+package session
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/config"
+	"go.uber.org/zap"
+)
+
+// Backend creates one type of session store
+type Backend struct {
+	// New creates the store from the session configuration
+	New func(config *config.SessionConfig, logger *zap.Logger) (Store, error)
+	// Validate checks the store-specific configuration (optional)
+	Validate func(config *config.SessionConfig) error
+}
+
+var (
+	backendsMu sync.RWMutex
+	backends   = make(map[string]Backend)
+)
+
+// Register makes a session store available to the factory under name, which
+// selects it in session.store. Stores implemented in other packages register
+// from their init function and are enabled by importing the package. Their
+// settings are read from session.options.
+//
+// Register panics if name is empty or already registered, or if backend.New
+// is nil.
+func Register(name string, backend Backend) {
+	backendsMu.Lock()
+	defer backendsMu.Unlock()
+
+	if name == "" {
+		panic("session: Register with an empty store name")
+	}
+	if backend.New == nil {
+		panic(fmt.Sprintf("session: Register of store %q without a constructor", name))
+	}
+	if _, exists := backends[name]; exists {
+		panic(fmt.Sprintf("session: Register called twice for store %q", name))
+	}
+
+	backends[name] = backend
+	config.RegisterSessionStore(name)
+}
+
+// Backends returns the sorted names of the registered session stores
+func Backends() []string {
+	backendsMu.RLock()
+	defer backendsMu.RUnlock()
+
+	names := make([]string, 0, len(backends))
+	for name := range backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// lookupBackend returns the session store registered under name
+func lookupBackend(name string) (Backend, bool) {
+	backendsMu.RLock()
+	defer backendsMu.RUnlock()
+
+	backend, ok := backends[name]
+	return backend, ok
+}
@@ -0,0 +1,122 @@
+package session
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/config"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/session/memory"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/session/sessiontest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// pluginStore is a store registered the way a store implemented in another
+// package would be
+type pluginStore struct {
+	*memory.Store
+	name string
+}
+
+func init() {
+	Register("plugin", Backend{
+		New: func(config *config.SessionConfig, logger *zap.Logger) (Store, error) {
+			name, _ := config.Options["name"].(string)
+			return &pluginStore{Store: memory.NewStore(&memory.Config{}, logger), name: name}, nil
+		},
+		Validate: func(config *config.SessionConfig) error {
+			if name, _ := config.Options["name"].(string); name == "" {
+				return fmt.Errorf("plugin store name is required")
+			}
+			return nil
+		},
+	})
+}
+
+func TestRegisteredStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+auth:
+  mode: bypass
+session:
+  store: plugin
+  options:
+    name: external
+`), 0o600))
+
+	// The configuration accepts the registered store and its options
+	cfg, err := config.Load(path)
+	require.NoError(t, err)
+	sessionConfig := &cfg.Session
+
+	assert.Contains(t, Backends(), "plugin")
+	require.NoError(t, ValidateConfig(sessionConfig))
+
+	store, err := NewFactory(zap.NewNop()).CreateStore(sessionConfig)
+	require.NoError(t, err)
+	defer store.Close()
+
+	plugin, ok := store.(*MetricsStore).store.(*pluginStore)
+	require.True(t, ok)
+	assert.Equal(t, "external", plugin.name)
+
+	sessionConfig.Options = nil
+	assert.EqualError(t, ValidateConfig(sessionConfig), "plugin store name is required")
+}
+
+func TestRegisterPanics(t *testing.T) {
+	noop := func(*config.SessionConfig, *zap.Logger) (Store, error) { return nil, nil }
+
+	assert.Panics(t, func() { Register("memory", Backend{New: noop}) })
+	assert.Panics(t, func() { Register("", Backend{New: noop}) })
+	assert.Panics(t, func() { Register("incomplete", Backend{}) })
+}
+
+// TestWrappedStoreConformance runs the store conformance suite through the
+// wrappers added by the factory
+func TestWrappedStoreConformance(t *testing.T) {
+	tests := []struct {
+		name   string
+		config config.SessionConfig
+	}{
+		{
+			name: "encrypted memory",
+			config: config.SessionConfig{
+				Store:      "memory",
+				Encryption: config.EncryptionConfig{Enabled: true, Keys: []string{"0123456789abcdef0123456789abcdef"}},
+			},
+		},
+		{
+			name: "cached memory",
+			config: config.SessionConfig{
+				Store: "memory",
+				Cache: config.SessionCacheConfig{Enabled: true, Size: 100, TTL: time.Minute},
+			},
+		},
+		{
+			name: "cached file",
+			config: config.SessionConfig{
+				Store: "file",
+				Cache: config.SessionCacheConfig{Enabled: true, Size: 100, TTL: time.Minute},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sessiontest.Run(t, sessiontest.Config{
+				NewStore: func(t *testing.T) sessiontest.Store {
+					sessionConfig := tt.config
+					sessionConfig.File.Path = filepath.Join(t.TempDir(), "sessions.db")
+					store, err := NewFactory(zap.NewNop()).CreateStore(&sessionConfig)
+					require.NoError(t, err)
+					return store
+				},
+			})
+		})
+	}
+}
@@ -0,0 +1,338 @@
+// Package sessiontest provides a conformance suite that session store
+// implementations run from their own tests.
+package sessiontest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/session/meta"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Store is the part of session.Store exercised by the suite. It is declared
+// here rather than imported so that the store packages, which the session
+// package depends on, can run the suite without an import cycle.
+type Store interface {
+	Create(ctx context.Context, key string, data interface{}, ttl time.Duration) (string, error)
+	Get(ctx context.Context, key string, data interface{}) error
+	Update(ctx context.Context, key string, data interface{}) error
+	Delete(ctx context.Context, key string) error
+	Exists(ctx context.Context, key string) (bool, error)
+	Refresh(ctx context.Context, key string, ttl time.Duration) error
+	Cleanup(ctx context.Context) error
+	Stats(ctx context.Context) (*meta.Stats, error)
+	Close() error
+}
+
+// RawStore is session.RawStore, declared here for the same reason as Store
+type RawStore interface {
+	CreateRaw(ctx context.Context, key string, value []byte, ttl time.Duration) (string, error)
+	GetRaw(ctx context.Context, key string) ([]byte, error)
+	UpdateRaw(ctx context.Context, key string, value []byte) error
+}
+
+// Config configures the conformance suite
+type Config struct {
+	// NewStore returns an empty store; the suite closes it
+	NewStore func(t *testing.T) Store
+	// Advance moves the store's clock forward (default: sleeps for d)
+	Advance func(d time.Duration)
+	// TTL is the lifetime of the sessions that the suite lets expire
+	// (default: 200ms)
+	TTL time.Duration
+}
+
+// Session is the payload stored by the suite
+type Session struct {
+	ID      string                 `json:"id"`
+	Counter int                    `json:"counter"`
+	Claims  map[string]interface{} `json:"claims,omitempty"`
+}
+
+// Run runs the conformance suite against the stores returned by config.NewStore
+func Run(t *testing.T, config Config) {
+	if config.Advance == nil {
+		config.Advance = time.Sleep
+	}
+	if config.TTL <= 0 {
+		config.TTL = 200 * time.Millisecond
+	}
+
+	tests := []struct {
+		name string
+		run  func(t *testing.T, config Config, store Store)
+	}{
+		{"CreateGet", testCreateGet},
+		{"CreateExpired", testCreateExpired},
+		{"Update", testUpdate},
+		{"Delete", testDelete},
+		{"TTL", testTTL},
+		{"Refresh", testRefresh},
+		{"Cleanup", testCleanup},
+		{"Stats", testStats},
+		{"ConcurrentUpdate", testConcurrentUpdate},
+		{"Raw", testRaw},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := config.NewStore(t)
+			defer store.Close()
+			tt.run(t, config, store)
+		})
+	}
+}
+
+func testCreateGet(t *testing.T, config Config, store Store) {
+	ctx := context.Background()
+
+	want := &Session{
+		ID:     "user123",
+		Claims: map[string]interface{}{"email": "user@example.com", "groups": []interface{}{"admins"}},
+	}
+	_, err := store.Create(ctx, "session1", want, time.Hour)
+	require.NoError(t, err)
+
+	var got Session
+	require.NoError(t, store.Get(ctx, "session1", &got))
+	assert.Equal(t, *want, got)
+
+	exists, err := store.Exists(ctx, "session1")
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	assert.ErrorIs(t, store.Get(ctx, "missing", &got), meta.ErrNotFound, "Get of a missing session")
+	exists, err = store.Exists(ctx, "missing")
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
+
+// testCreateExpired checks that an expired session does not block its key.
+// Whether Create replaces a live session is left to the store.
+func testCreateExpired(t *testing.T, config Config, store Store) {
+	ctx := context.Background()
+
+	_, err := store.Create(ctx, "session1", &Session{ID: "first"}, config.TTL)
+	require.NoError(t, err)
+	config.Advance(2 * config.TTL)
+
+	_, err = store.Create(ctx, "session1", &Session{ID: "second"}, time.Hour)
+	require.NoError(t, err)
+	var got Session
+	require.NoError(t, store.Get(ctx, "session1", &got))
+	assert.Equal(t, "second", got.ID)
+}
+
+func testUpdate(t *testing.T, config Config, store Store) {
+	ctx := context.Background()
+
+	_, err := store.Create(ctx, "session1", &Session{ID: "user123", Counter: 1}, time.Hour)
+	require.NoError(t, err)
+	require.NoError(t, store.Update(ctx, "session1", &Session{ID: "user123", Counter: 2}))
+
+	var got Session
+	require.NoError(t, store.Get(ctx, "session1", &got))
+	assert.Equal(t, 2, got.Counter)
+
+	assert.Error(t, store.Update(ctx, "missing", &Session{}), "Update of a missing session")
+	exists, err := store.Exists(ctx, "missing")
+	require.NoError(t, err)
+	assert.False(t, exists, "Update must not create sessions")
+}
+
+func testDelete(t *testing.T, config Config, store Store) {
+	ctx := context.Background()
+
+	_, err := store.Create(ctx, "session1", &Session{ID: "user123"}, time.Hour)
+	require.NoError(t, err)
+	require.NoError(t, store.Delete(ctx, "session1"))
+
+	exists, err := store.Exists(ctx, "session1")
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	var got Session
+	assert.Error(t, store.Get(ctx, "session1", &got))
+}
+
+func testTTL(t *testing.T, config Config, store Store) {
+	ctx := context.Background()
+
+	_, err := store.Create(ctx, "short", &Session{ID: "short"}, config.TTL)
+	require.NoError(t, err)
+	_, err = store.Create(ctx, "long", &Session{ID: "long"}, time.Hour)
+	require.NoError(t, err)
+	_, err = store.Create(ctx, "forever", &Session{ID: "forever"}, 0)
+	require.NoError(t, err)
+
+	config.Advance(2 * config.TTL)
+
+	var got Session
+	assert.Error(t, store.Get(ctx, "short", &got), "Get of an expired session")
+	exists, err := store.Exists(ctx, "short")
+	require.NoError(t, err)
+	assert.False(t, exists)
+	assert.Error(t, store.Update(ctx, "short", &Session{}), "Update of an expired session")
+
+	for _, key := range []string{"long", "forever"} {
+		require.NoError(t, store.Get(ctx, key, &got), key)
+		assert.Equal(t, key, got.ID)
+	}
+}
+
+func testRefresh(t *testing.T, config Config, store Store) {
+	ctx := context.Background()
+
+	_, err := store.Create(ctx, "session1", &Session{ID: "user123"}, config.TTL)
+	require.NoError(t, err)
+
+	config.Advance(config.TTL / 2)
+	require.NoError(t, store.Refresh(ctx, "session1", 3*config.TTL))
+
+	// Past the original expiry
+	config.Advance(config.TTL)
+	exists, err := store.Exists(ctx, "session1")
+	require.NoError(t, err)
+	assert.True(t, exists, "refreshed session expired at its original TTL")
+
+	// Past the refreshed expiry
+	config.Advance(3 * config.TTL)
+	exists, err = store.Exists(ctx, "session1")
+	require.NoError(t, err)
+	assert.False(t, exists, "refreshed session outlived its new TTL")
+
+	assert.Error(t, store.Refresh(ctx, "session1", time.Hour), "Refresh of an expired session")
+	assert.Error(t, store.Refresh(ctx, "missing", time.Hour), "Refresh of a missing session")
+}
+
+func testCleanup(t *testing.T, config Config, store Store) {
+	ctx := context.Background()
+
+	for i := 0; i < 5; i++ {
+		_, err := store.Create(ctx, fmt.Sprintf("expired%d", i), &Session{ID: "expired"}, config.TTL)
+		require.NoError(t, err)
+	}
+	_, err := store.Create(ctx, "live", &Session{ID: "live"}, time.Hour)
+	require.NoError(t, err)
+
+	config.Advance(2 * config.TTL)
+	require.NoError(t, store.Cleanup(ctx))
+
+	var got Session
+	require.NoError(t, store.Get(ctx, "live", &got))
+	for i := 0; i < 5; i++ {
+		exists, err := store.Exists(ctx, fmt.Sprintf("expired%d", i))
+		require.NoError(t, err)
+		assert.False(t, exists)
+	}
+
+	// Cleanup of an empty or clean store is a no-op
+	require.NoError(t, store.Cleanup(ctx))
+}
+
+func testStats(t *testing.T, config Config, store Store) {
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		_, err := store.Create(ctx, fmt.Sprintf("live%d", i), &Session{ID: "live"}, time.Hour)
+		require.NoError(t, err)
+	}
+	_, err := store.Create(ctx, "expired", &Session{ID: "expired"}, config.TTL)
+	require.NoError(t, err)
+
+	config.Advance(2 * config.TTL)
+	require.NoError(t, store.Cleanup(ctx))
+
+	stats, err := store.Stats(ctx)
+	require.NoError(t, err)
+	require.NotNil(t, stats)
+	assert.NotEmpty(t, stats.Store, "Stats must name the store")
+
+	// Stores that cannot count their sessions report -1
+	if stats.ActiveSessions >= 0 {
+		assert.Equal(t, int64(3), stats.ActiveSessions)
+	}
+}
+
+func testConcurrentUpdate(t *testing.T, config Config, store Store) {
+	ctx := context.Background()
+	const workers, updates = 8, 10
+
+	_, err := store.Create(ctx, "shared", &Session{ID: "shared"}, time.Hour)
+	require.NoError(t, err)
+	for w := 0; w < workers; w++ {
+		_, err := store.Create(ctx, fmt.Sprintf("own%d", w), &Session{ID: "own"}, time.Hour)
+		require.NoError(t, err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 2*workers*updates)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 1; i <= updates; i++ {
+				errs <- store.Update(ctx, "shared", &Session{ID: "shared", Counter: w*updates + i})
+				errs <- store.Update(ctx, fmt.Sprintf("own%d", w), &Session{ID: "own", Counter: i})
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	// The shared session holds one of the written values, not a mix
+	var got Session
+	require.NoError(t, store.Get(ctx, "shared", &got))
+	assert.Equal(t, "shared", got.ID)
+	assert.True(t, got.Counter >= 1 && got.Counter <= workers*updates, "unexpected counter %d", got.Counter)
+
+	for w := 0; w < workers; w++ {
+		require.NoError(t, store.Get(ctx, fmt.Sprintf("own%d", w), &got))
+		assert.Equal(t, updates, got.Counter)
+	}
+}
+
+// testRaw checks that stores implementing RawStore keep bytes that are not
+// JSON, or valid UTF-8, unchanged
+func testRaw(t *testing.T, config Config, store Store) {
+	raw, ok := store.(RawStore)
+	if !ok {
+		t.Skip("store does not implement RawStore")
+	}
+	ctx := context.Background()
+
+	value := []byte("test:\x00\xff\xfe{\"")
+	_, err := raw.CreateRaw(ctx, "session1", value, time.Hour)
+	if errors.Is(err, meta.ErrRawNotSupported) {
+		t.Skip("wrapped store does not support raw values")
+	}
+	require.NoError(t, err)
+	got, err := raw.GetRaw(ctx, "session1")
+	require.NoError(t, err)
+	assert.Equal(t, value, got)
+
+	require.NoError(t, raw.UpdateRaw(ctx, "session1", []byte("test:\x01")))
+	got, err = raw.GetRaw(ctx, "session1")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("test:\x01"), got)
+
+	// Data stored as JSON reads back as its JSON
+	_, err = store.Create(ctx, "session2", &Session{ID: "user123"}, time.Hour)
+	require.NoError(t, err)
+	got, err = raw.GetRaw(ctx, "session2")
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"id":"user123","counter":0}`, string(got))
+
+	_, err = raw.GetRaw(ctx, "missing")
+	assert.ErrorIs(t, err, meta.ErrNotFound, "GetRaw of a missing session")
+	assert.Error(t, raw.UpdateRaw(ctx, "missing", value), "UpdateRaw of a missing session")
+}
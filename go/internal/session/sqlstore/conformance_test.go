@@ -0,0 +1,15 @@
+package sqlstore
+
+import (
+	"testing"
+
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/session/sessiontest"
+)
+
+func TestConformance(t *testing.T) {
+	sessiontest.Run(t, sessiontest.Config{
+		NewStore: func(t *testing.T) sessiontest.Store {
+			return newTestStore(t, "")
+		},
+	})
+}
@@ -0,0 +1,85 @@
+package sqlstore
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	// Database drivers selectable through Config.Driver
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/jackc/pgx/v5/stdlib"
+	_ "modernc.org/sqlite"
+)
+
+// dialect captures the differences between the supported databases
+type dialect struct {
+	// name is the configured driver name
+	name string
+	// driver is the database/sql driver name
+	driver string
+	// numbered reports whether placeholders are $1, $2, ... instead of ?
+	numbered bool
+	// insertIgnore starts an insert that skips rows violating a unique key
+	insertIgnore string
+	// insertIgnoreSuffix ends such an insert
+	insertIgnoreSuffix string
+	// singleConn limits the pool to one connection; SQLite allows a single
+	// writer and gives every connection to ":memory:" its own database
+	singleConn bool
+}
+
+var dialects = map[string]*dialect{
+	"postgres": {
+		name:               "postgres",
+		driver:             "pgx",
+		numbered:           true,
+		insertIgnore:       "INSERT INTO",
+		insertIgnoreSuffix: " ON CONFLICT DO NOTHING",
+	},
+	"mysql": {
+		name:         "mysql",
+		driver:       "mysql",
+		insertIgnore: "INSERT IGNORE INTO",
+	},
+	"sqlite": {
+		name:               "sqlite",
+		driver:             "sqlite",
+		insertIgnore:       "INSERT INTO",
+		insertIgnoreSuffix: " ON CONFLICT DO NOTHING",
+		singleConn:         true,
+	},
+}
+
+// lookupDialect returns the dialect of a configured driver name
+func lookupDialect(name string) (*dialect, error) {
+	d, ok := dialects[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("unsupported SQL driver: %s (supported: postgres, mysql, sqlite)", name)
+	}
+	return d, nil
+}
+
+// rebind rewrites ? placeholders for databases that number them
+func (d *dialect) rebind(query string) string {
+	if !d.numbered {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteByte('$')
+			b.WriteString(strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// placeholders returns n comma-separated ? placeholders
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?,", n), ",")
+}
@@ -0,0 +1,98 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// migration is one schema change. Statements use types understood by every
+// supported database; keys are bounded VARCHARs so MySQL can index them and
+// times are Unix nanoseconds in BIGINT columns.
+type migration struct {
+	version    int
+	statements []string
+}
+
+var migrations = []migration{
+	{
+		version: 1,
+		statements: []string{
+			`CREATE TABLE IF NOT EXISTS sessions (
+				session_key     VARCHAR(255) NOT NULL PRIMARY KEY,
+				data            TEXT NOT NULL,
+				expires_at      BIGINT NULL,
+				created_at      BIGINT NOT NULL,
+				updated_at      BIGINT NOT NULL,
+				handle          VARCHAR(64) NULL,
+				user_id         VARCHAR(255) NULL,
+				ip              VARCHAR(64) NULL,
+				user_agent      TEXT NULL,
+				device          VARCHAR(32) NULL,
+				meta_created_at BIGINT NULL,
+				last_seen       BIGINT NULL
+			)`,
+			`CREATE INDEX idx_sessions_expires_at ON sessions (expires_at)`,
+			`CREATE INDEX idx_sessions_listing ON sessions (meta_created_at, handle)`,
+			`CREATE TABLE IF NOT EXISTS session_indexes (
+				index_name  VARCHAR(255) NOT NULL,
+				session_key VARCHAR(255) NOT NULL,
+				PRIMARY KEY (index_name, session_key)
+			)`,
+			`CREATE INDEX idx_session_indexes_key ON session_indexes (session_key)`,
+		},
+	},
+}
+
+// migrate applies the migrations newer than the recorded schema version
+func (s *Store) migrate(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS session_schema_migrations (
+		version    INTEGER NOT NULL PRIMARY KEY,
+		applied_at BIGINT NOT NULL
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create migrations table: %w", err)
+	}
+
+	var current sql.NullInt64
+	if err := s.db.QueryRowContext(ctx, `SELECT MAX(version) FROM session_schema_migrations`).Scan(&current); err != nil {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+
+	for _, m := range migrations {
+		if int64(m.version) <= current.Int64 {
+			continue
+		}
+		if err := s.applyMigration(ctx, m); err != nil {
+			return fmt.Errorf("failed to apply migration %d: %w", m.version, err)
+		}
+		s.logger.Info("Applied session schema migration", zap.Int("version", m.version))
+	}
+	return nil
+}
+
+// applyMigration runs a migration and records it. MySQL commits DDL
+// implicitly, so a failed migration may leave part of it applied there.
+func (s *Store) applyMigration(ctx context.Context, m migration) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, statement := range m.statements {
+		if _, err := tx.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, s.dialect.rebind(`INSERT INTO session_schema_migrations (version, applied_at) VALUES (?, ?)`),
+		m.version, time.Now().UnixNano())
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,745 @@
+// Package sqlstore implements a session store on a relational database
+// through database/sql. PostgreSQL, MySQL and SQLite are supported; the
+// schema is created and upgraded by built-in migrations.
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/session/meta"
+	"go.uber.org/zap"
+)
+
+// DefaultCleanupBatchSize is the number of expired sessions deleted per
+// cleanup transaction
+const DefaultCleanupBatchSize = 500
+
+// errSessionExists is returned when creating a key held by a live session
+var errSessionExists = errors.New("session already exists")
+
+// Stats holds session store statistics
+type Stats = meta.Stats
+
+// Config holds SQL session store configuration
+type Config struct {
+	// Driver is postgres, mysql or sqlite
+	Driver string
+	// DSN is the driver-specific data source name
+	DSN string
+
+	// Connection pool settings; zero values keep the database/sql defaults
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+
+	// CleanupInterval for removing expired sessions
+	CleanupInterval time.Duration
+	// CleanupBatchSize bounds the sessions deleted per transaction
+	CleanupBatchSize int
+}
+
+// Store implements session.Store on a SQL database
+type Store struct {
+	db               *sql.DB
+	dialect          *dialect
+	logger           *zap.Logger
+	cleanupBatchSize int
+	cleanupDone      chan struct{}
+	cleanupWG        sync.WaitGroup
+	closeOnce        sync.Once
+	totalCreated     atomic.Int64
+	totalDeleted     atomic.Int64
+}
+
+// NewStore opens the database and applies pending schema migrations
+func NewStore(config *Config, logger *zap.Logger) (*Store, error) {
+	if config == nil {
+		return nil, fmt.Errorf("SQL store configuration is required")
+	}
+	d, err := lookupDialect(config.Driver)
+	if err != nil {
+		return nil, err
+	}
+	if config.DSN == "" {
+		return nil, fmt.Errorf("SQL data source name is required")
+	}
+
+	db, err := sql.Open(d.driver, config.DSN)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	if d.singleConn {
+		db.SetMaxOpenConns(1)
+	} else if config.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(config.MaxOpenConns)
+	}
+	if config.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(config.MaxIdleConns)
+	}
+	if config.ConnMaxLifetime > 0 {
+		db.SetConnMaxLifetime(config.ConnMaxLifetime)
+	}
+	if config.ConnMaxIdleTime > 0 {
+		db.SetConnMaxIdleTime(config.ConnMaxIdleTime)
+	}
+
+	store := &Store{
+		db:               db,
+		dialect:          d,
+		logger:           logger,
+		cleanupBatchSize: config.CleanupBatchSize,
+		cleanupDone:      make(chan struct{}),
+	}
+	if store.cleanupBatchSize <= 0 {
+		store.cleanupBatchSize = DefaultCleanupBatchSize
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+	if err := store.migrate(ctx); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	// Start cleanup routine
+	if config.CleanupInterval > 0 {
+		store.startCleanup(config.CleanupInterval)
+	}
+
+	return store, nil
+}
+
+// startCleanup starts the background cleanup routine
+func (s *Store) startCleanup(interval time.Duration) {
+	s.cleanupWG.Add(1)
+	go func() {
+		defer s.cleanupWG.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := s.cleanup(context.Background()); err != nil {
+					s.logger.Warn("Failed to clean up expired sessions", zap.Error(err))
+				}
+			case <-s.cleanupDone:
+				return
+			}
+		}
+	}()
+}
+
+// cleanup deletes expired sessions in batches, each in its own transaction,
+// so large backlogs do not hold long locks
+func (s *Store) cleanup(ctx context.Context) error {
+	now := time.Now().UnixNano()
+	total := 0
+
+	for {
+		keys, err := s.queryKeys(ctx, `SELECT session_key FROM sessions
+			WHERE expires_at IS NOT NULL AND expires_at <= ?
+			ORDER BY expires_at LIMIT ?`, now, s.cleanupBatchSize)
+		if err != nil {
+			return err
+		}
+		if len(keys) == 0 {
+			break
+		}
+
+		deleted, err := s.deleteKeys(ctx, keys, now)
+		if err != nil {
+			return err
+		}
+		total += deleted
+
+		if len(keys) < s.cleanupBatchSize {
+			break
+		}
+	}
+
+	if total > 0 {
+		s.totalDeleted.Add(int64(total))
+		s.logger.Debug("Cleaned up expired sessions", zap.Int("count", total))
+	}
+	return nil
+}
+
+// deleteKeys deletes sessions and their index entries; with expiredBefore
+// set only sessions expired at that time are deleted
+func (s *Store) deleteKeys(ctx context.Context, keys []string, expiredBefore int64) (int, error) {
+	args := make([]interface{}, 0, len(keys)+1)
+	for _, key := range keys {
+		args = append(args, key)
+	}
+
+	query := `DELETE FROM sessions WHERE session_key IN (` + placeholders(len(keys)) + `)`
+	if expiredBefore > 0 {
+		query += ` AND expires_at IS NOT NULL AND expires_at <= ?`
+		args = append(args, expiredBefore)
+	}
+
+	deleted := 0
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, s.dialect.rebind(query), args...)
+		if err != nil {
+			return err
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		deleted = int(n)
+
+		// Drop index entries of keys no longer in sessions
+		_, err = tx.ExecContext(ctx, s.dialect.rebind(`DELETE FROM session_indexes
+			WHERE session_key IN (`+placeholders(len(keys))+`)
+			AND NOT EXISTS (SELECT 1 FROM sessions s WHERE s.session_key = session_indexes.session_key)`),
+			args[:len(keys)]...)
+		return err
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete sessions: %w", err)
+	}
+	return deleted, nil
+}
+
+// Create creates a new session with the given key and data
+func (s *Store) Create(ctx context.Context, key string, data interface{}, ttl time.Duration) (string, error) {
+	// Serialize data to JSON
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal session data: %w", err)
+	}
+
+	now := time.Now()
+	var expiresAt sql.NullInt64
+	if ttl > 0 {
+		expiresAt = sql.NullInt64{Int64: now.Add(ttl).UnixNano(), Valid: true}
+	}
+
+	err = s.inTx(ctx, func(tx *sql.Tx) error {
+		// An expired session under the same key is replaced
+		var existing sql.NullInt64
+		err := tx.QueryRowContext(ctx, s.dialect.rebind(`SELECT expires_at FROM sessions WHERE session_key = ?`), key).Scan(&existing)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+		case err != nil:
+			return err
+		case !existing.Valid || existing.Int64 >= now.UnixNano():
+			return errSessionExists
+		default:
+			if err := deleteSessionTx(ctx, tx, s.dialect, key); err != nil {
+				return err
+			}
+		}
+
+		_, err = tx.ExecContext(ctx, s.dialect.rebind(`INSERT INTO sessions
+			(session_key, data, expires_at, created_at, updated_at) VALUES (?, ?, ?, ?, ?)`),
+			key, string(jsonData), expiresAt, now.UnixNano(), now.UnixNano())
+		return err
+	})
+	if errors.Is(err, errSessionExists) {
+		return "", err
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to create session: %w", err)
+	}
+	s.totalCreated.Add(1)
+
+	s.logger.Debug("Session created",
+		zap.String("key", key),
+		zap.Duration("ttl", ttl),
+	)
+
+	return key, nil
+}
+
+// Get retrieves session data by key
+func (s *Store) Get(ctx context.Context, key string, data interface{}) error {
+	var raw string
+	var expiresAt sql.NullInt64
+	err := s.db.QueryRowContext(ctx, s.dialect.rebind(`SELECT data, expires_at FROM sessions WHERE session_key = ?`), key).
+		Scan(&raw, &expiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return meta.ErrNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get session: %w", err)
+	}
+
+	// Check if session is expired
+	if expiresAt.Valid && time.Now().UnixNano() > expiresAt.Int64 {
+		s.deleteExpired(ctx, key)
+		return meta.ErrExpired
+	}
+
+	// Deserialize JSON data
+	if err := json.Unmarshal([]byte(raw), data); err != nil {
+		return fmt.Errorf("failed to unmarshal session data: %w", err)
+	}
+
+	s.logger.Debug("Session retrieved", zap.String("key", key))
+	return nil
+}
+
+// Update updates existing session data
+func (s *Store) Update(ctx context.Context, key string, data interface{}) error {
+	// Serialize new data
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to marshal session data: %w", err)
+	}
+
+	now := time.Now().UnixNano()
+	result, err := s.db.ExecContext(ctx, s.dialect.rebind(`UPDATE sessions SET data = ?, updated_at = ?
+		WHERE session_key = ? AND (expires_at IS NULL OR expires_at >= ?)`),
+		string(jsonData), now, key, now)
+	if err != nil {
+		return fmt.Errorf("failed to update session: %w", err)
+	}
+	if err := s.requireLive(ctx, key, result); err != nil {
+		return err
+	}
+
+	s.logger.Debug("Session updated", zap.String("key", key))
+	return nil
+}
+
+// Delete removes a session by key
+func (s *Store) Delete(ctx context.Context, key string) error {
+	deleted := false
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, s.dialect.rebind(`DELETE FROM sessions WHERE session_key = ?`), key)
+		if err != nil {
+			return err
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		deleted = n > 0
+
+		_, err = tx.ExecContext(ctx, s.dialect.rebind(`DELETE FROM session_indexes WHERE session_key = ?`), key)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete session: %w", err)
+	}
+	if !deleted {
+		return meta.ErrNotFound
+	}
+	s.totalDeleted.Add(1)
+
+	s.logger.Debug("Session deleted", zap.String("key", key))
+	return nil
+}
+
+// Exists checks if a session exists
+func (s *Store) Exists(ctx context.Context, key string) (bool, error) {
+	var expiresAt sql.NullInt64
+	err := s.db.QueryRowContext(ctx, s.dialect.rebind(`SELECT expires_at FROM sessions WHERE session_key = ?`), key).Scan(&expiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to check session existence: %w", err)
+	}
+
+	// Check if session is expired
+	if expiresAt.Valid && time.Now().UnixNano() > expiresAt.Int64 {
+		s.deleteExpired(ctx, key)
+		return false, nil
+	}
+	return true, nil
+}
+
+// Refresh extends the TTL of a session
+func (s *Store) Refresh(ctx context.Context, key string, ttl time.Duration) error {
+	now := time.Now()
+	var expiresAt sql.NullInt64
+	if ttl > 0 {
+		expiresAt = sql.NullInt64{Int64: now.Add(ttl).UnixNano(), Valid: true}
+	}
+
+	result, err := s.db.ExecContext(ctx, s.dialect.rebind(`UPDATE sessions SET expires_at = ?, updated_at = ?
+		WHERE session_key = ? AND (expires_at IS NULL OR expires_at >= ?)`),
+		expiresAt, now.UnixNano(), key, now.UnixNano())
+	if err != nil {
+		return fmt.Errorf("failed to refresh session: %w", err)
+	}
+	if err := s.requireLive(ctx, key, result); err != nil {
+		return err
+	}
+
+	s.logger.Debug("Session TTL refreshed",
+		zap.String("key", key),
+		zap.Duration("ttl", ttl),
+	)
+	return nil
+}
+
+// requireLive turns an update that matched no live session into the
+// memory store's not found or expired errors
+func (s *Store) requireLive(ctx context.Context, key string, result sql.Result) error {
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return nil
+	}
+
+	// MySQL reports rows changed rather than matched, so an update writing
+	// identical values also lands here
+	exists, err := s.Exists(ctx, key)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return nil
+	}
+	return meta.ErrNotFound
+}
+
+// deleteExpired removes a session found expired on read
+func (s *Store) deleteExpired(ctx context.Context, key string) {
+	deleted, err := s.deleteKeys(ctx, []string{key}, time.Now().UnixNano())
+	if err != nil {
+		s.logger.Debug("Failed to delete expired session", zap.Error(err), zap.String("key", key))
+		return
+	}
+	s.totalDeleted.Add(int64(deleted))
+}
+
+// AddIndex associates an existing session key with the index
+func (s *Store) AddIndex(ctx context.Context, index, key string) error {
+	exists, err := s.Exists(ctx, key)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return meta.ErrNotFound
+	}
+
+	_, err = s.db.ExecContext(ctx, s.dialect.rebind(s.dialect.insertIgnore+
+		` session_indexes (index_name, session_key) VALUES (?, ?)`+s.dialect.insertIgnoreSuffix), index, key)
+	if err != nil {
+		return fmt.Errorf("failed to add session index: %w", err)
+	}
+	return nil
+}
+
+// RemoveIndex removes a session key from the index
+func (s *Store) RemoveIndex(ctx context.Context, index, key string) error {
+	_, err := s.db.ExecContext(ctx, s.dialect.rebind(`DELETE FROM session_indexes WHERE index_name = ? AND session_key = ?`), index, key)
+	if err != nil {
+		return fmt.Errorf("failed to remove session index: %w", err)
+	}
+	return nil
+}
+
+// IndexedKeys returns the keys of the live sessions in the index
+func (s *Store) IndexedKeys(ctx context.Context, index string) ([]string, error) {
+	keys, err := s.queryKeys(ctx, `SELECT i.session_key FROM session_indexes i
+		JOIN sessions s ON s.session_key = i.session_key
+		WHERE i.index_name = ? AND (s.expires_at IS NULL OR s.expires_at >= ?)
+		ORDER BY i.session_key`, index, time.Now().UnixNano())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read session index: %w", err)
+	}
+	return keys, nil
+}
+
+// SetMetadata attaches owner and client metadata to an existing session
+func (s *Store) SetMetadata(ctx context.Context, key string, metadata *meta.Metadata) error {
+	now := time.Now()
+	lastSeen := metadata.LastSeen
+	if lastSeen.IsZero() {
+		lastSeen = now
+	}
+
+	// CreatedAt is kept if metadata already exists, then taken from the
+	// argument, then from the session; so is the handle
+	var createdAt interface{}
+	if !metadata.CreatedAt.IsZero() {
+		createdAt = metadata.CreatedAt.UnixNano()
+	}
+	handle, err := meta.NewHandle()
+	if err != nil {
+		return err
+	}
+
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		result, err := tx.ExecContext(ctx, s.dialect.rebind(`UPDATE sessions SET
+				user_id = ?, ip = ?, user_agent = ?, device = ?, last_seen = ?,
+				meta_created_at = COALESCE(meta_created_at, ?, created_at),
+				handle = COALESCE(handle, ?)
+			WHERE session_key = ? AND (expires_at IS NULL OR expires_at >= ?)`),
+			metadata.UserID, metadata.IP, metadata.UserAgent, metadata.Device, lastSeen.UnixNano(),
+			createdAt, handle, key, now.UnixNano())
+		if err != nil {
+			return fmt.Errorf("failed to set session metadata: %w", err)
+		}
+		if n, err := result.RowsAffected(); err != nil {
+			return err
+		} else if n == 0 {
+			// MySQL reports rows changed rather than matched, so an update
+			// writing identical values also lands here
+			var live int
+			err := tx.QueryRowContext(ctx, s.dialect.rebind(`SELECT COUNT(*) FROM sessions
+				WHERE session_key = ? AND (expires_at IS NULL OR expires_at >= ?)`), key, now.UnixNano()).Scan(&live)
+			if err != nil {
+				return err
+			}
+			if live == 0 {
+				return meta.ErrNotFound
+			}
+		}
+		if err := tx.QueryRowContext(ctx, s.dialect.rebind(`SELECT handle FROM sessions WHERE session_key = ?`), key).Scan(&handle); err != nil {
+			return fmt.Errorf("failed to read session handle: %w", err)
+		}
+
+		// Move the session to the index of its user and index its handle
+		userIndex := meta.UserIndex(metadata.UserID)
+		_, err = tx.ExecContext(ctx, s.dialect.rebind(`DELETE FROM session_indexes
+			WHERE session_key = ? AND index_name LIKE ? AND index_name <> ?`), key, meta.UserIndex("")+"%", userIndex)
+		if err != nil {
+			return fmt.Errorf("failed to update session index: %w", err)
+		}
+		for _, index := range []string{userIndex, meta.HandleIndex(handle)} {
+			_, err = tx.ExecContext(ctx, s.dialect.rebind(s.dialect.insertIgnore+
+				` session_indexes (index_name, session_key) VALUES (?, ?)`+s.dialect.insertIgnoreSuffix), index, key)
+			if err != nil {
+				return fmt.Errorf("failed to update session index: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// Touch records activity on a session that has metadata, at most once per
+// meta.TouchInterval
+func (s *Store) Touch(ctx context.Context, key string, seen time.Time) error {
+	_, err := s.db.ExecContext(ctx, s.dialect.rebind(`UPDATE sessions SET last_seen = ?
+		WHERE session_key = ? AND user_id IS NOT NULL AND last_seen <= ?`),
+		seen.UnixNano(), key, seen.Add(-meta.TouchInterval).UnixNano())
+	if err != nil {
+		return fmt.Errorf("failed to touch session: %w", err)
+	}
+	return nil
+}
+
+// ListUserSessions returns the live sessions of a user, oldest first
+func (s *Store) ListUserSessions(ctx context.Context, userID string) ([]*meta.Info, error) {
+	infos, err := s.queryInfos(ctx, `WHERE session_key IN (SELECT session_key FROM session_indexes WHERE index_name = ?)
+		AND user_id IS NOT NULL AND (expires_at IS NULL OR expires_at >= ?)
+		ORDER BY meta_created_at, handle`, meta.UserIndex(userID), time.Now().UnixNano())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list user sessions: %w", err)
+	}
+	return infos, nil
+}
+
+// DeleteUserSessions deletes every session of a user
+func (s *Store) DeleteUserSessions(ctx context.Context, userID string) (int, error) {
+	now := time.Now().UnixNano()
+	keys, err := s.queryKeys(ctx, `SELECT session_key FROM session_indexes WHERE index_name = ?`, meta.UserIndex(userID))
+	if err != nil {
+		return 0, fmt.Errorf("failed to list user sessions: %w", err)
+	}
+	if len(keys) == 0 {
+		return 0, nil
+	}
+
+	live, err := s.queryKeys(ctx, `SELECT i.session_key FROM session_indexes i
+		JOIN sessions s ON s.session_key = i.session_key
+		WHERE i.index_name = ? AND (s.expires_at IS NULL OR s.expires_at >= ?)`, meta.UserIndex(userID), now)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list user sessions: %w", err)
+	}
+
+	removed, err := s.deleteKeys(ctx, keys, 0)
+	if err != nil {
+		return 0, err
+	}
+	s.totalDeleted.Add(int64(removed))
+
+	s.logger.Debug("User sessions deleted", zap.String("user_id", userID), zap.Int("count", len(live)))
+	return len(live), nil
+}
+
+// ListSessions returns sessions with metadata, oldest first
+func (s *Store) ListSessions(ctx context.Context, cursor string, limit int) (*meta.Page, error) {
+	after, err := meta.DecodeCursor(cursor)
+	if err != nil {
+		return nil, err
+	}
+	limit = meta.PageSize(limit)
+
+	where := `WHERE user_id IS NOT NULL AND (expires_at IS NULL OR expires_at >= ?)`
+	args := []interface{}{time.Now().UnixNano()}
+	if after != "" {
+		createdAt, handle, err := splitSortKey(after)
+		if err != nil {
+			return nil, err
+		}
+		where += ` AND (meta_created_at > ? OR (meta_created_at = ? AND handle > ?))`
+		args = append(args, createdAt, createdAt, handle)
+	}
+	args = append(args, limit+1)
+
+	infos, err := s.queryInfos(ctx, where+` ORDER BY meta_created_at, handle LIMIT ?`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+
+	page := &meta.Page{Sessions: infos}
+	if len(infos) > limit {
+		page.Sessions = infos[:limit]
+		last := page.Sessions[limit-1]
+		page.NextCursor = meta.EncodeCursor(meta.SortKey(last.CreatedAt, last.Handle))
+	}
+	return page, nil
+}
+
+// splitSortKey parses a meta.SortKey into its creation time and handle
+func splitSortKey(sortKey string) (int64, string, error) {
+	i := strings.IndexByte(sortKey, '|')
+	if i < 0 {
+		return 0, "", meta.ErrInvalidCursor
+	}
+	createdAt, err := strconv.ParseInt(sortKey[:i], 10, 64)
+	if err != nil {
+		return 0, "", meta.ErrInvalidCursor
+	}
+	return createdAt, sortKey[i+1:], nil
+}
+
+// queryInfos lists sessions with metadata matching the query suffix
+func (s *Store) queryInfos(ctx context.Context, suffix string, args ...interface{}) ([]*meta.Info, error) {
+	rows, err := s.db.QueryContext(ctx, s.dialect.rebind(`SELECT session_key, expires_at, handle,
+		user_id, ip, user_agent, device, meta_created_at, last_seen FROM sessions `+suffix), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	infos := make([]*meta.Info, 0)
+	for rows.Next() {
+		var info meta.Info
+		var expiresAt, createdAt, lastSeen sql.NullInt64
+		var handle, ip, userAgent, device sql.NullString
+		if err := rows.Scan(&info.Key, &expiresAt, &handle, &info.UserID, &ip, &userAgent, &device, &createdAt, &lastSeen); err != nil {
+			return nil, err
+		}
+		info.Handle = handle.String
+		info.IP = ip.String
+		info.UserAgent = userAgent.String
+		info.Device = device.String
+		info.CreatedAt = time.Unix(0, createdAt.Int64)
+		info.LastSeen = time.Unix(0, lastSeen.Int64)
+		if expiresAt.Valid {
+			t := time.Unix(0, expiresAt.Int64)
+			info.ExpiresAt = &t
+		}
+		infos = append(infos, &info)
+	}
+	return infos, rows.Err()
+}
+
+// queryKeys returns the single string column of a query
+func (s *Store) queryKeys(ctx context.Context, query string, args ...interface{}) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, s.dialect.rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := make([]string, 0)
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// inTx runs fn in a transaction, committing if it succeeds
+func (s *Store) inTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// deleteSessionTx removes a session and its index entries within a transaction
+func deleteSessionTx(ctx context.Context, tx *sql.Tx, d *dialect, key string) error {
+	if _, err := tx.ExecContext(ctx, d.rebind(`DELETE FROM session_indexes WHERE session_key = ?`), key); err != nil {
+		return err
+	}
+	_, err := tx.ExecContext(ctx, d.rebind(`DELETE FROM sessions WHERE session_key = ?`), key)
+	return err
+}
+
+// Close stops the cleanup routine and closes the database
+func (s *Store) Close() error {
+	var err error
+	s.closeOnce.Do(func() {
+		close(s.cleanupDone)
+		s.cleanupWG.Wait()
+
+		err = s.db.Close()
+		s.logger.Debug("SQL session store closed")
+	})
+	return err
+}
+
+// Cleanup manually triggers cleanup of expired sessions
+func (s *Store) Cleanup(ctx context.Context) error {
+	return s.cleanup(ctx)
+}
+
+// Stats returns session store statistics
+func (s *Store) Stats(ctx context.Context) (*Stats, error) {
+	var active int64
+	err := s.db.QueryRowContext(ctx, s.dialect.rebind(`SELECT COUNT(*) FROM sessions
+		WHERE expires_at IS NULL OR expires_at >= ?`), time.Now().UnixNano()).Scan(&active)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count sessions: %w", err)
+	}
+
+	dbStats := s.db.Stats()
+	return &Stats{
+		ActiveSessions: active,
+		TotalCreated:   s.totalCreated.Load(),
+		TotalDeleted:   s.totalDeleted.Load(),
+		Store:          "sql",
+		Info: fmt.Sprintf("driver=%s open_connections=%d in_use=%d idle=%d",
+			s.dialect.name, dbStats.OpenConnections, dbStats.InUse, dbStats.Idle),
+	}, nil
+}
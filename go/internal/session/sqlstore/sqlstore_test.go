@@ -0,0 +1,370 @@
+package sqlstore
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/session/meta"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// TestData represents test session data
+type TestData struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+// newTestStore opens an SQLite store in a temporary file
+func newTestStore(t *testing.T, dsn string) *Store {
+	if dsn == "" {
+		dsn = filepath.Join(t.TempDir(), "sessions.db")
+	}
+	store, err := NewStore(&Config{Driver: "sqlite", DSN: dsn, CleanupBatchSize: 2}, zap.NewNop())
+	require.NoError(t, err)
+	return store
+}
+
+func TestNewStore(t *testing.T) {
+	_, err := NewStore(&Config{Driver: "oracle", DSN: "x"}, zap.NewNop())
+	assert.ErrorContains(t, err, "unsupported SQL driver")
+
+	_, err = NewStore(&Config{Driver: "sqlite"}, zap.NewNop())
+	assert.Error(t, err)
+
+	t.Run("migrations run once", func(t *testing.T) {
+		dsn := filepath.Join(t.TempDir(), "sessions.db")
+		store := newTestStore(t, dsn)
+		require.NoError(t, store.Close())
+		assert.NoError(t, store.Close(), "Close is idempotent")
+
+		reopened := newTestStore(t, dsn)
+		defer reopened.Close()
+
+		var count, version int
+		require.NoError(t, reopened.db.QueryRow(`SELECT COUNT(*), MAX(version) FROM session_schema_migrations`).Scan(&count, &version))
+		assert.Equal(t, len(migrations), count)
+		assert.Equal(t, migrations[len(migrations)-1].version, version)
+	})
+}
+
+func TestRebind(t *testing.T) {
+	postgres, err := lookupDialect("postgres")
+	require.NoError(t, err)
+	assert.Equal(t, "SELECT a FROM t WHERE b = $1 AND c IN ($2,$3)",
+		postgres.rebind("SELECT a FROM t WHERE b = ? AND c IN ("+placeholders(2)+")"))
+
+	mysql, err := lookupDialect("MySQL")
+	require.NoError(t, err)
+	assert.Equal(t, "SELECT a FROM t WHERE b = ?", mysql.rebind("SELECT a FROM t WHERE b = ?"))
+}
+
+func TestStoreOperations(t *testing.T) {
+	store := newTestStore(t, "")
+	defer store.Close()
+
+	ctx := context.Background()
+	testData := &TestData{ID: "123", Name: "Test User", Email: "test@example.com"}
+
+	t.Run("Create and Get", func(t *testing.T) {
+		sessionID, err := store.Create(ctx, "session1", testData, time.Hour)
+		require.NoError(t, err)
+		assert.Equal(t, "session1", sessionID)
+
+		var retrieved TestData
+		require.NoError(t, store.Get(ctx, "session1", &retrieved))
+		assert.Equal(t, *testData, retrieved)
+
+		_, err = store.Create(ctx, "session1", testData, time.Hour)
+		assert.EqualError(t, err, "session already exists")
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		updated := &TestData{ID: "123", Name: "Updated User", Email: "updated@example.com"}
+		require.NoError(t, store.Update(ctx, "session1", updated))
+
+		var retrieved TestData
+		require.NoError(t, store.Get(ctx, "session1", &retrieved))
+		assert.Equal(t, *updated, retrieved)
+	})
+
+	t.Run("Exists", func(t *testing.T) {
+		exists, err := store.Exists(ctx, "session1")
+		require.NoError(t, err)
+		assert.True(t, exists)
+
+		exists, err = store.Exists(ctx, "missing")
+		require.NoError(t, err)
+		assert.False(t, exists)
+	})
+
+	t.Run("Refresh", func(t *testing.T) {
+		require.NoError(t, store.Refresh(ctx, "session1", 2*time.Hour))
+		assert.EqualError(t, store.Refresh(ctx, "missing", time.Hour), "session not found")
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		require.NoError(t, store.Delete(ctx, "session1"))
+
+		var retrieved TestData
+		assert.EqualError(t, store.Get(ctx, "session1", &retrieved), "session not found")
+		assert.EqualError(t, store.Delete(ctx, "session1"), "session not found")
+		assert.EqualError(t, store.Update(ctx, "session1", testData), "session not found")
+	})
+}
+
+func TestSessionExpiration(t *testing.T) {
+	store := newTestStore(t, "")
+	defer store.Close()
+
+	ctx := context.Background()
+	_, err := store.Create(ctx, "short", &TestData{ID: "1"}, 50*time.Millisecond)
+	require.NoError(t, err)
+	_, err = store.Create(ctx, "forever", &TestData{ID: "2"}, 0)
+	require.NoError(t, err)
+
+	time.Sleep(100 * time.Millisecond)
+
+	var retrieved TestData
+	assert.EqualError(t, store.Get(ctx, "short", &retrieved), "session expired")
+	assert.EqualError(t, store.Get(ctx, "short", &retrieved), "session not found")
+	require.NoError(t, store.Get(ctx, "forever", &retrieved))
+
+	// An expired key can be created again
+	_, err = store.Create(ctx, "recreated", &TestData{ID: "3"}, 50*time.Millisecond)
+	require.NoError(t, err)
+	time.Sleep(100 * time.Millisecond)
+	_, err = store.Create(ctx, "recreated", &TestData{ID: "3"}, time.Hour)
+	assert.NoError(t, err)
+}
+
+func TestCleanup(t *testing.T) {
+	store := newTestStore(t, "")
+	defer store.Close()
+
+	ctx := context.Background()
+	// More expired sessions than one cleanup batch
+	for i := 0; i < 5; i++ {
+		key := fmt.Sprintf("expiring%d", i)
+		_, err := store.Create(ctx, key, &TestData{ID: "1"}, 50*time.Millisecond)
+		require.NoError(t, err)
+		require.NoError(t, store.AddIndex(ctx, "sub:alice", key))
+	}
+	_, err := store.Create(ctx, "live", &TestData{ID: "2"}, time.Hour)
+	require.NoError(t, err)
+	require.NoError(t, store.AddIndex(ctx, "sub:alice", "live"))
+
+	time.Sleep(100 * time.Millisecond)
+	require.NoError(t, store.Cleanup(ctx))
+
+	stats, err := store.Stats(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), stats.ActiveSessions)
+	assert.Equal(t, int64(5), stats.TotalDeleted)
+	assert.Equal(t, "sql", stats.Store)
+
+	// Index entries go with their sessions
+	var indexed int
+	require.NoError(t, store.db.QueryRow(`SELECT COUNT(*) FROM session_indexes`).Scan(&indexed))
+	assert.Equal(t, 1, indexed)
+}
+
+func TestAutomaticCleanup(t *testing.T) {
+	store, err := NewStore(&Config{
+		Driver:          "sqlite",
+		DSN:             filepath.Join(t.TempDir(), "sessions.db"),
+		CleanupInterval: 50 * time.Millisecond,
+	}, zap.NewNop())
+	require.NoError(t, err)
+	defer store.Close()
+
+	ctx := context.Background()
+	_, err = store.Create(ctx, "expiring", &TestData{ID: "1"}, 10*time.Millisecond)
+	require.NoError(t, err)
+
+	assert.Eventually(t, func() bool {
+		var count int
+		err := store.db.QueryRow(`SELECT COUNT(*) FROM sessions`).Scan(&count)
+		return err == nil && count == 0
+	}, time.Second, 20*time.Millisecond)
+}
+
+func TestConcurrentAccess(t *testing.T) {
+	store := newTestStore(t, "")
+	defer store.Close()
+
+	ctx := context.Background()
+	_, err := store.Create(ctx, "shared", &TestData{ID: "0"}, time.Hour)
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			key := fmt.Sprintf("session%d", i)
+			_, err := store.Create(ctx, key, &TestData{ID: key}, time.Hour)
+			assert.NoError(t, err)
+			assert.NoError(t, store.Update(ctx, "shared", &TestData{ID: key}))
+
+			var retrieved TestData
+			assert.NoError(t, store.Get(ctx, key, &retrieved))
+		}(i)
+	}
+	wg.Wait()
+
+	stats, err := store.Stats(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(11), stats.ActiveSessions)
+}
+
+func TestIndexes(t *testing.T) {
+	store := newTestStore(t, "")
+	defer store.Close()
+
+	ctx := context.Background()
+	_, err := store.Create(ctx, "user:alice", &TestData{ID: "alice"}, time.Hour)
+	require.NoError(t, err)
+	_, err = store.Create(ctx, "user:alice2", &TestData{ID: "alice"}, time.Hour)
+	require.NoError(t, err)
+
+	require.NoError(t, store.AddIndex(ctx, "sub:alice", "user:alice"))
+	require.NoError(t, store.AddIndex(ctx, "sub:alice", "user:alice"), "adding twice is a no-op")
+	require.NoError(t, store.AddIndex(ctx, "sub:alice", "user:alice2"))
+	require.NoError(t, store.AddIndex(ctx, "sid:abc", "user:alice"))
+	assert.Error(t, store.AddIndex(ctx, "sub:alice", "missing"))
+
+	keys, err := store.IndexedKeys(ctx, "sub:alice")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"user:alice", "user:alice2"}, keys)
+
+	require.NoError(t, store.RemoveIndex(ctx, "sub:alice", "user:alice2"))
+	keys, err = store.IndexedKeys(ctx, "sub:alice")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"user:alice"}, keys)
+
+	require.NoError(t, store.Delete(ctx, "user:alice"))
+	keys, err = store.IndexedKeys(ctx, "sid:abc")
+	require.NoError(t, err)
+	assert.Empty(t, keys)
+}
+
+func TestUserSessions(t *testing.T) {
+	store := newTestStore(t, "")
+	defer store.Close()
+
+	ctx := context.Background()
+	created := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, userID := range []string{"alice", "bob", "alice"} {
+		key := fmt.Sprintf("session%d", i)
+		_, err := store.Create(ctx, key, &TestData{ID: userID}, time.Hour)
+		require.NoError(t, err)
+		require.NoError(t, store.SetMetadata(ctx, key, &meta.Metadata{
+			UserID:    userID,
+			Device:    "desktop",
+			CreatedAt: created.Add(time.Duration(i) * time.Minute),
+		}))
+	}
+	// Sessions without metadata are not listed
+	_, err := store.Create(ctx, "auth:state", &TestData{ID: "state"}, time.Hour)
+	require.NoError(t, err)
+	assert.EqualError(t, store.SetMetadata(ctx, "missing", &meta.Metadata{UserID: "alice"}), "session not found")
+
+	t.Run("ListUserSessions", func(t *testing.T) {
+		infos, err := store.ListUserSessions(ctx, "alice")
+		require.NoError(t, err)
+		require.Len(t, infos, 2)
+		assert.Equal(t, "session0", infos[0].Key)
+		assert.Equal(t, "session2", infos[1].Key)
+		assert.NotNil(t, infos[0].ExpiresAt)
+	})
+
+	t.Run("SetMetadata keeps CreatedAt", func(t *testing.T) {
+		require.NoError(t, store.SetMetadata(ctx, "session0", &meta.Metadata{UserID: "alice", Device: "mobile"}))
+		infos, err := store.ListUserSessions(ctx, "alice")
+		require.NoError(t, err)
+		assert.True(t, created.Equal(infos[0].CreatedAt))
+		assert.Equal(t, "mobile", infos[0].Device)
+	})
+
+	t.Run("User sessions share the session index", func(t *testing.T) {
+		keys, err := store.IndexedKeys(ctx, meta.UserIndex("alice"))
+		require.NoError(t, err)
+		assert.Equal(t, []string{"session0", "session2"}, keys)
+
+		require.NoError(t, store.SetMetadata(ctx, "session2", &meta.Metadata{UserID: "carol"}))
+		infos, err := store.ListUserSessions(ctx, "alice")
+		require.NoError(t, err)
+		require.Len(t, infos, 1)
+		infos, err = store.ListUserSessions(ctx, "carol")
+		require.NoError(t, err)
+		require.Len(t, infos, 1)
+		assert.Equal(t, "session2", infos[0].Key)
+		handle := infos[0].Handle
+		assert.NotEmpty(t, handle)
+		keys, err = store.IndexedKeys(ctx, meta.HandleIndex(handle))
+		require.NoError(t, err)
+		assert.Equal(t, []string{"session2"}, keys)
+		require.NoError(t, store.SetMetadata(ctx, "session2", &meta.Metadata{UserID: "alice"}))
+		infos, err = store.ListUserSessions(ctx, "alice")
+		require.NoError(t, err)
+		handles := make([]string, len(infos))
+		for i, info := range infos {
+			handles[i] = info.Handle
+		}
+		assert.Contains(t, handles, handle, "the handle survives metadata updates")
+	})
+
+	t.Run("Touch", func(t *testing.T) {
+		seen := time.Now().Add(2 * meta.TouchInterval)
+		require.NoError(t, store.Touch(ctx, "session1", seen))
+		infos, err := store.ListUserSessions(ctx, "bob")
+		require.NoError(t, err)
+		assert.True(t, seen.Equal(infos[0].LastSeen))
+
+		// Activity within the interval is not written
+		require.NoError(t, store.Touch(ctx, "session1", seen.Add(time.Second)))
+		infos, err = store.ListUserSessions(ctx, "bob")
+		require.NoError(t, err)
+		assert.True(t, seen.Equal(infos[0].LastSeen))
+	})
+
+	t.Run("ListSessions pages", func(t *testing.T) {
+		page, err := store.ListSessions(ctx, "", 2)
+		require.NoError(t, err)
+		require.Len(t, page.Sessions, 2)
+		assert.Equal(t, "session0", page.Sessions[0].Key)
+		assert.Equal(t, "session1", page.Sessions[1].Key)
+		require.NotEmpty(t, page.NextCursor)
+
+		page, err = store.ListSessions(ctx, page.NextCursor, 2)
+		require.NoError(t, err)
+		require.Len(t, page.Sessions, 1)
+		assert.Equal(t, "session2", page.Sessions[0].Key)
+		assert.Empty(t, page.NextCursor)
+
+		_, err = store.ListSessions(ctx, "bogus", 2)
+		assert.ErrorIs(t, err, meta.ErrInvalidCursor)
+	})
+
+	t.Run("DeleteUserSessions", func(t *testing.T) {
+		deleted, err := store.DeleteUserSessions(ctx, "alice")
+		require.NoError(t, err)
+		assert.Equal(t, 2, deleted)
+
+		infos, err := store.ListUserSessions(ctx, "alice")
+		require.NoError(t, err)
+		assert.Empty(t, infos)
+
+		page, err := store.ListSessions(ctx, "", 10)
+		require.NoError(t, err)
+		require.Len(t, page.Sessions, 1)
+		assert.Equal(t, "bob", page.Sessions[0].UserID)
+	})
+}
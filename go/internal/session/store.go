@@ -3,8 +3,39 @@ package session
 import (
 	"context"
 	"time"
+
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/session/meta"
+)
+
+// Metadata describes the owner and client of a session
+type Metadata = meta.Metadata
+
+// SessionInfo describes a stored session for listings
+type SessionInfo = meta.Info
+
+// SessionPage is one page of a session listing
+type SessionPage = meta.Page
+
+// ErrInvalidCursor is returned by ListSessions for a malformed cursor
+var ErrInvalidCursor = meta.ErrInvalidCursor
+
+// ErrSessionNotFound is returned for a missing session. Get returns
+// ErrSessionExpired, which matches it, for an expired one.
+var (
+	ErrSessionNotFound = meta.ErrNotFound
+	ErrSessionExpired  = meta.ErrExpired
 )
 
+// ErrRawNotSupported is returned by the RawStore methods of store wrappers
+// when the wrapped store does not implement RawStore
+var ErrRawNotSupported = meta.ErrRawNotSupported
+
+// HandleIndex returns the index that leads from a listed session's handle
+// to its key
+func HandleIndex(handle string) string {
+	return meta.HandleIndex(handle)
+}
+
 // Store defines the interface for session storage
 type Store interface {
 	// Create creates a new session with the given key and data
@@ -35,14 +66,66 @@ type Store interface {
 	Cleanup(ctx context.Context) error
 
 	// Stats returns session store statistics (optional)
-	Stats(ctx context.Context) (interface{}, error)
+	Stats(ctx context.Context) (*Stats, error)
+
+	// SetMetadata attaches owner and client metadata to an existing session
+	// and adds it to the meta.UserIndex index of its user, moving it when the
+	// user changes. The first call assigns the session a random handle,
+	// indexed by HandleIndex; it and CreatedAt are kept on later calls.
+	SetMetadata(ctx context.Context, key string, metadata *Metadata) error
+
+	// Touch records activity on a session that has metadata. It runs on every
+	// authenticated request, so stores may skip activity within
+	// meta.TouchInterval of the recorded last seen time.
+	Touch(ctx context.Context, key string, seen time.Time) error
+
+	// ListUserSessions returns the live sessions of a user, oldest first;
+	// it reads the same index as Indexer.IndexedKeys(meta.UserIndex(userID))
+	ListUserSessions(ctx context.Context, userID string) ([]*SessionInfo, error)
+
+	// DeleteUserSessions deletes every session of a user and returns how many were deleted
+	DeleteUserSessions(ctx context.Context, userID string) (int, error)
+
+	// ListSessions returns sessions with metadata, oldest first, starting
+	// after the cursor of the previous page. Cursors hold handles, not keys.
+	ListSessions(ctx context.Context, cursor string, limit int) (*SessionPage, error)
+}
+
+// Stats holds session store statistics; every store returns this type
+type Stats = meta.Stats
+
+// Indexer is implemented by stores that maintain secondary indexes from an
+// attribute (e.g. "sub:<subject>") to the keys of the sessions carrying it.
+// Index entries are removed when their session is deleted or expires.
+type Indexer interface {
+	// AddIndex associates an existing session key with the index
+	AddIndex(ctx context.Context, index, key string) error
+
+	// RemoveIndex removes a session key from the index
+	RemoveIndex(ctx context.Context, index, key string) error
+
+	// IndexedKeys returns the keys of the live sessions in the index
+	IndexedKeys(ctx context.Context, index string) ([]string, error)
+}
+
+// RawStore is implemented by stores that can hold session data as opaque
+// bytes instead of JSON. TypedStore hands it the output of codecs other than
+// JSON, which it gives other stores as a base64 JSON string.
+type RawStore interface {
+	// CreateRaw creates a new session holding value
+	CreateRaw(ctx context.Context, key string, value []byte, ttl time.Duration) (string, error)
+
+	// GetRaw returns the stored bytes of a session: a raw value, or the JSON
+	// of data stored with Create or Update
+	GetRaw(ctx context.Context, key string) ([]byte, error)
+
+	// UpdateRaw replaces the data of an existing session with value
+	UpdateRaw(ctx context.Context, key string, value []byte) error
 }
 
-// Stats holds session store statistics
-type Stats struct {
-	ActiveSessions int64  `json:"active_sessions"`
-	TotalCreated   int64  `json:"total_created"`
-	TotalDeleted   int64  `json:"total_deleted"`
-	StoreType      string `json:"store_type"`
-	Info           string `json:"info,omitempty"`
-}
\ No newline at end of file
+// IndexRevoker is implemented by stores that cannot list the sessions in an
+// index (the cookie store) but can still invalidate all of them at once
+type IndexRevoker interface {
+	// RevokeIndex invalidates every session added to the index so far
+	RevokeIndex(ctx context.Context, index string) error
+}
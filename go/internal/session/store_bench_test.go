@@ -0,0 +1,178 @@
+package session
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/session/file"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/session/memory"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/session/sqlstore"
+	"go.uber.org/zap"
+)
+
+// Run with: go test -run '^$' -bench . ./internal/session/
+
+// benchSession resembles a user session with a handful of claims
+type benchSession struct {
+	ID     string                 `json:"id"`
+	Email  string                 `json:"email"`
+	Groups []string               `json:"groups"`
+	Claims map[string]interface{} `json:"claims"`
+}
+
+func newBenchSession(i int) *benchSession {
+	return &benchSession{
+		ID:     fmt.Sprintf("user-%d", i),
+		Email:  fmt.Sprintf("user-%d@example.com", i),
+		Groups: []string{"developers", "admins"},
+		Claims: map[string]interface{}{"iss": "https://idp.example.com", "aud": "mcp-proxy", "sub": fmt.Sprintf("user-%d", i)},
+	}
+}
+
+// benchStores lists the stores compared by the benchmarks
+var benchStores = []struct {
+	name string
+	open func(b *testing.B) Store
+}{
+	{
+		name: "memory",
+		open: func(b *testing.B) Store {
+			return memory.NewStore(&memory.Config{}, zap.NewNop())
+		},
+	},
+	{
+		name: "file",
+		open: func(b *testing.B) Store {
+			store, err := file.NewStore(&file.Config{Path: filepath.Join(b.TempDir(), "sessions.db")}, zap.NewNop())
+			if err != nil {
+				b.Fatal(err)
+			}
+			return store
+		},
+	},
+	{
+		name: "sqlite",
+		open: func(b *testing.B) Store {
+			store, err := sqlstore.NewStore(&sqlstore.Config{Driver: "sqlite", DSN: filepath.Join(b.TempDir(), "sessions.db")}, zap.NewNop())
+			if err != nil {
+				b.Fatal(err)
+			}
+			return store
+		},
+	},
+}
+
+// seed creates n sessions named session<i>
+func seed(b *testing.B, store Store, n int) {
+	ctx := context.Background()
+	for i := 0; i < n; i++ {
+		if _, err := store.Create(ctx, fmt.Sprintf("session%d", i), newBenchSession(i), time.Hour); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkStoreCreate(b *testing.B) {
+	for _, bs := range benchStores {
+		b.Run(bs.name, func(b *testing.B) {
+			store := bs.open(b)
+			defer store.Close()
+			ctx := context.Background()
+			data := newBenchSession(0)
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := store.Create(ctx, fmt.Sprintf("session%d", i), data, time.Hour); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkStoreGet(b *testing.B) {
+	const sessions = 1000
+	for _, bs := range benchStores {
+		b.Run(bs.name, func(b *testing.B) {
+			store := bs.open(b)
+			defer store.Close()
+			seed(b, store, sessions)
+			ctx := context.Background()
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				var data benchSession
+				if err := store.Get(ctx, fmt.Sprintf("session%d", i%sessions), &data); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkStoreUpdate(b *testing.B) {
+	const sessions = 1000
+	for _, bs := range benchStores {
+		b.Run(bs.name, func(b *testing.B) {
+			store := bs.open(b)
+			defer store.Close()
+			seed(b, store, sessions)
+			ctx := context.Background()
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := store.Update(ctx, fmt.Sprintf("session%d", i%sessions), newBenchSession(i)); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkStoreGetParallel(b *testing.B) {
+	const sessions = 1000
+	for _, bs := range benchStores {
+		b.Run(bs.name, func(b *testing.B) {
+			store := bs.open(b)
+			defer store.Close()
+			seed(b, store, sessions)
+			ctx := context.Background()
+
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				i := 0
+				for pb.Next() {
+					var data benchSession
+					if err := store.Get(ctx, fmt.Sprintf("session%d", i%sessions), &data); err != nil {
+						b.Error(err)
+						return
+					}
+					i++
+				}
+			})
+		})
+	}
+}
+
+func BenchmarkStoreCleanup(b *testing.B) {
+	const sessions = 1000
+	for _, bs := range benchStores {
+		b.Run(bs.name, func(b *testing.B) {
+			store := bs.open(b)
+			defer store.Close()
+			seed(b, store, sessions)
+			ctx := context.Background()
+
+			// Nothing has expired: measures the cost of a cleanup pass
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := store.Cleanup(ctx); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,171 @@
+package session
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// TypedStore is a type-safe view of a Store for sessions of type T.
+//
+// With the JSON codec values are handed to the store as is, so the stored
+// format is unchanged. Other codecs store the bytes
+//
+//	"<codec>:" + encoded value
+//
+// in stores that implement RawStore, and otherwise a JSON string
+//
+//	"<codec>:" + base64(encoded value)
+//
+// They read values written with any codec in either form, so switching from
+// JSON to another codec keeps existing sessions. Switching back to JSON ends
+// the sessions written with another codec.
+type TypedStore[T any] struct {
+	store Store
+	codec Codec
+}
+
+// NewTypedStore creates a typed view of store; a nil codec selects JSON
+func NewTypedStore[T any](store Store, codec Codec) *TypedStore[T] {
+	if codec == nil {
+		codec = JSONCodec{}
+	}
+	return &TypedStore[T]{store: store, codec: codec}
+}
+
+// Store returns the underlying store
+func (s *TypedStore[T]) Store() Store {
+	return s.store
+}
+
+// Codec returns the codec used to encode sessions
+func (s *TypedStore[T]) Codec() Codec {
+	return s.codec
+}
+
+// write encodes data and hands it to the store: to writeRaw when the codec
+// is not JSON and the store supports raw values, otherwise to write
+func (s *TypedStore[T]) write(data *T, writeRaw func(RawStore, []byte) error, write func(interface{}) error) error {
+	if _, ok := s.codec.(JSONCodec); ok {
+		return write(data)
+	}
+	encoded, err := s.codec.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to encode session data with %s: %w", s.codec.Name(), err)
+	}
+	if raw, ok := s.store.(RawStore); ok {
+		value := append([]byte(s.codec.Name()+":"), encoded...)
+		if err := writeRaw(raw, value); !errors.Is(err, ErrRawNotSupported) {
+			return err
+		}
+	}
+	return write(s.codec.Name() + ":" + base64.StdEncoding.EncodeToString(encoded))
+}
+
+// Create stores a new session
+func (s *TypedStore[T]) Create(ctx context.Context, key string, data *T, ttl time.Duration) (string, error) {
+	var id string
+	err := s.write(data, func(raw RawStore, value []byte) (err error) {
+		id, err = raw.CreateRaw(ctx, key, value, ttl)
+		return err
+	}, func(value interface{}) (err error) {
+		id, err = s.store.Create(ctx, key, value, ttl)
+		return err
+	})
+	return id, err
+}
+
+// Get retrieves a session
+func (s *TypedStore[T]) Get(ctx context.Context, key string) (*T, error) {
+	data := new(T)
+	if _, ok := s.codec.(JSONCodec); ok {
+		if err := s.store.Get(ctx, key, data); err != nil {
+			return nil, err
+		}
+		return data, nil
+	}
+
+	var raw []byte
+	err := ErrRawNotSupported
+	if rawStore, ok := s.store.(RawStore); ok {
+		raw, err = rawStore.GetRaw(ctx, key)
+	}
+	if errors.Is(err, ErrRawNotSupported) {
+		err = s.store.Get(ctx, key, (*json.RawMessage)(&raw))
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := decode(raw, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// decode decodes a stored value written with any codec, in either form
+func decode(raw []byte, data interface{}) error {
+	// Raw bytes, which JSON values cannot start with
+	if name, encoded, ok := bytes.Cut(raw, []byte(":")); ok {
+		if codec, err := CodecByName(string(name)); err == nil && codec.Name() != "json" {
+			if err := codec.Unmarshal(encoded, data); err != nil {
+				return fmt.Errorf("failed to decode session data with %s: %w", name, err)
+			}
+			return nil
+		}
+	}
+
+	var value string
+	if err := json.Unmarshal(raw, &value); err != nil {
+		// Plain JSON, written with the JSON codec
+		if err := json.Unmarshal(raw, data); err != nil {
+			return fmt.Errorf("failed to unmarshal session data: %w", err)
+		}
+		return nil
+	}
+
+	name, encoded, ok := strings.Cut(value, ":")
+	if !ok {
+		return fmt.Errorf("failed to decode session data: unknown format")
+	}
+	codec, err := CodecByName(name)
+	if err != nil || name == "" || name == "json" {
+		return fmt.Errorf("failed to decode session data: unknown codec %q", name)
+	}
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return fmt.Errorf("failed to decode session data: %w", err)
+	}
+	if err := codec.Unmarshal(decoded, data); err != nil {
+		return fmt.Errorf("failed to decode session data with %s: %w", name, err)
+	}
+	return nil
+}
+
+// Update replaces the data of an existing session
+func (s *TypedStore[T]) Update(ctx context.Context, key string, data *T) error {
+	return s.write(data, func(raw RawStore, value []byte) error {
+		return raw.UpdateRaw(ctx, key, value)
+	}, func(value interface{}) error {
+		return s.store.Update(ctx, key, value)
+	})
+}
+
+// Delete removes a session
+func (s *TypedStore[T]) Delete(ctx context.Context, key string) error {
+	return s.store.Delete(ctx, key)
+}
+
+// Exists checks if a session exists
+func (s *TypedStore[T]) Exists(ctx context.Context, key string) (bool, error) {
+	return s.store.Exists(ctx, key)
+}
+
+// Refresh extends the TTL of a session
+func (s *TypedStore[T]) Refresh(ctx context.Context, key string, ttl time.Duration) error {
+	return s.store.Refresh(ctx, key, ttl)
+}
@@ -0,0 +1,99 @@
+package session
+
+import (
+	"context"
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	goredis "github.com/redis/go-redis/v9"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/session/memory"
+	"github.com/sh03m2a5h/mcp-oidc-proxy-go/internal/session/redis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestTypedStore(t *testing.T) {
+	for _, codec := range []Codec{JSONCodec{}, MsgpackCodec{}, GobCodec{}} {
+		t.Run(codec.Name(), func(t *testing.T) {
+			inner := memory.NewStore(&memory.Config{}, zap.NewNop())
+			defer inner.Close()
+			store := NewTypedStore[claimsSession](inner, codec)
+			ctx := context.Background()
+
+			want := newClaimsSession()
+			_, err := store.Create(ctx, "user:123", want, time.Hour)
+			require.NoError(t, err)
+
+			got, err := store.Get(ctx, "user:123")
+			require.NoError(t, err)
+			assert.Equal(t, want.Claims, got.Claims)
+
+			got.Groups = []string{"users"}
+			require.NoError(t, store.Update(ctx, "user:123", got))
+			got, err = store.Get(ctx, "user:123")
+			require.NoError(t, err)
+			assert.Equal(t, []string{"users"}, got.Groups)
+
+			require.NoError(t, store.Refresh(ctx, "user:123", time.Hour))
+			require.NoError(t, store.Delete(ctx, "user:123"))
+			exists, err := store.Exists(ctx, "user:123")
+			require.NoError(t, err)
+			assert.False(t, exists)
+
+			_, err = store.Get(ctx, "user:123")
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestTypedStoreFormats(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := goredis.NewClient(&goredis.Options{Addr: mr.Addr()})
+	inner := redis.NewStoreWithClient(client, "test:", zap.NewNop())
+	defer inner.Close()
+	ctx := context.Background()
+
+	// The JSON codec keeps the stored format of untyped callers
+	_, err := NewTypedStore[claimsSession](inner, nil).Create(ctx, "json", newClaimsSession(), time.Hour)
+	require.NoError(t, err)
+	value, err := mr.Get("test:json")
+	require.NoError(t, err)
+	assert.Contains(t, value, `"id":"user123"`)
+
+	// Other codecs hand their bytes to the store as is
+	msgpackStore := NewTypedStore[claimsSession](inner, MsgpackCodec{})
+	_, err = msgpackStore.Create(ctx, "msgpack", newClaimsSession(), time.Hour)
+	require.NoError(t, err)
+	value, err = mr.Get("test:msgpack")
+	require.NoError(t, err)
+	encoded, err := MsgpackCodec{}.Marshal(newClaimsSession())
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(value, "msgpack:"), value)
+	assert.Len(t, value, len("msgpack:")+len(encoded))
+
+	// Stores without raw values get a base64 JSON string
+	_, err = NewTypedStore[claimsSession](NewMetricsStore(struct{ Store }{inner}, "redis"), MsgpackCodec{}).
+		Create(ctx, "msgpack-string", newClaimsSession(), time.Hour)
+	require.NoError(t, err)
+	value, err = mr.Get("test:msgpack-string")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(value, `"msgpack:`), value)
+	assert.Len(t, value, len(`"msgpack:"`)+base64.StdEncoding.EncodedLen(len(encoded)))
+
+	// Sessions written with JSON or another codec, in either form, stay
+	// readable after the codec changes
+	gobStore := NewTypedStore[claimsSession](inner, GobCodec{})
+	for _, key := range []string{"json", "msgpack", "msgpack-string"} {
+		got, err := gobStore.Get(ctx, key)
+		require.NoError(t, err, key)
+		assert.Equal(t, "user123", got.ID)
+	}
+
+	mr.Set("test:unknown", `"yaml:aWQ6IHVzZXIxMjM="`)
+	_, err = gobStore.Get(ctx, "unknown")
+	assert.Error(t, err)
+}